@@ -3,9 +3,13 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/arl/go-detour/detour"
 	"github.com/arl/go-detour/recast"
+	"github.com/arl/go-detour/sample"
 	"github.com/arl/go-detour/sample/solomesh"
 	"github.com/arl/go-detour/sample/tilemesh"
 	"github.com/spf13/cobra"
@@ -18,23 +22,221 @@ var buildCmd = &cobra.Command{
 	Long: `Build a navigation mesh from input geometry in OBJ.
 Build process is controlled by the provided build settings. Generated
 navmesh is saved to OUTFILE in binary format, readable with go-detour
-and/or detour.`,
+and/or detour.
+
+With --dir, OUTFILE is a directory in which the navmesh is saved as one file
+per tile, plus a JSON manifest. Rebuilding a navmesh only modifies the files
+of the tiles that changed, making baked navmeshes easier to diff and merge
+when they're committed in a version control system. Commands reading a
+navmesh accept such a directory in place of a binary file.
+
+Input geometry is either given with --input or listed in the 'inputs'
+section of the build settings, or both. Each listed input can be transformed
+before being merged with the others:
+
+  inputs:
+  - path: floor.obj
+  - path: bridge.obj
+    transform:
+      translate: [10, 0, 5]
+      rotate: [0, 90, 0]
+      scale: 2
+
+Relative paths are relative to the build settings file.
+
+The walkable triangles of an input get its area id, either a number or one of
+the sample area names: ground, water, road, door, grass or jump. An input may
+also override the maximum walkable slope, in degrees, of the agent:
+
+  inputs:
+  - path: terrain.obj
+  - path: roads.obj
+    area: road
+    maxslope: 60
+
+--input may be repeated, an area and a maximum slope may follow the path,
+separated by commas: --input terrain.obj --input roads.obj,road,60
+
+Build settings are read from --config, in YAML or, if the file has the .json
+extension, in JSON. Settings missing from the file take their default value.
+A preset, given with --preset or with a 'preset' entry in the settings file,
+replaces the default settings with the ones of the preset, the settings file
+then overrides them (see 'recast config --help' for the list of presets). With
+--preset, the settings file is optional.
+
+The 'contourflags' setting selects the contour edges tessellated so that
+they're not longer than 'edgemaxlen': 1 for the walls, 2 for the edges between
+areas, 3 for both, 0 for none.
+
+The settings file may define several agent profiles, one navmesh is then built
+per profile and saved to OUTFILE with the profile name appended to its base
+name (navmesh_small.bin, navmesh_large.bin...). Each profile starts from the
+settings of the file, or from the ones of its preset, and overrides them:
+
+  profiles:
+  - name: small
+    agentradius: 0.4
+  - name: large
+    preset: giant
+
+Solo navmeshes rasterize the input geometry once for all the profiles sharing
+the same cell size, cell height, maximum slope and maximum climb.
+
+The input geometry may also be a geometry set (.gset), as saved by RecastDemo,
+defining convex volumes and off-mesh connections in addition to the mesh. A
+geometry set must then be the only input. The build settings it may contain
+are ignored, they're always read from --config.
+
+With --surface, the navmesh is not built: the walkable surface, extracted from
+the compact heightfield, is saved to OUTFILE as an OBJ mesh instead. Only solo
+navmeshes support this option.`,
 	Run: doBuild,
 }
 
-var cfgVal, inputVal string
+var (
+	cfgVal            string
+	inputVals         []string
+	dirVal, timersVal bool
+	surfaceVal        bool
+	progressVal       bool
+)
 
 func init() {
 	RootCmd.AddCommand(buildCmd)
 	buildCmd.Flags().StringVar(&cfgVal, "config", "recast.yml", "build settings")
 	buildCmd.Flags().StringVar(&typeVal, "type", "solo", "navmesh type, 'solo' or 'tile'")
-	buildCmd.Flags().StringVar(&inputVal, "input", "", "input geometry OBJ file (required)")
+	buildCmd.Flags().StringVar(&presetVal, "preset", "", "build settings preset, 'human', 'vehicle' or 'giant'")
+	buildCmd.Flags().StringArrayVar(&inputVals, "input", nil, "input geometry OBJ or geometry set file, with optional area and max slope (path[,area[,maxslope]])")
+	buildCmd.Flags().BoolVar(&dirVal, "dir", false, "save navmesh as a directory of tile files")
+	buildCmd.Flags().BoolVar(&timersVal, "timers", false, "print the time spent in each build step")
+	buildCmd.Flags().BoolVar(&surfaceVal, "surface", false, "save the walkable surface as an OBJ mesh instead of the navmesh")
+	buildCmd.Flags().BoolVar(&progressVal, "progress", false, "print the build progress")
+}
+
+// inputMesh is an input geometry file, as listed in the build settings.
+type inputMesh struct {
+	Path      string
+	Transform recast.MeshTransform
+	Area      areaID
+	MaxSlope  float32
+}
+
+// inputSettings holds the input geometry section of the build settings.
+type inputSettings struct {
+	Inputs []inputMesh
+}
+
+// areaID is the area id of an input mesh, given by number or by name.
+type areaID uint8
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a *areaID) UnmarshalText(text []byte) error {
+	if id, ok := sample.AreaByName(string(text)); ok {
+		*a = areaID(id)
+		return nil
+	}
+	id, err := strconv.ParseUint(string(text), 10, 8)
+	if err != nil {
+		return fmt.Errorf("invalid area '%s'", text)
+	}
+	*a = areaID(id)
+	return nil
+}
+
+// parseInputFlag parses the value of an --input flag, path[,area[,maxslope]].
+func parseInputFlag(val string) (inputMesh, error) {
+	fields := strings.Split(val, ",")
+	in := inputMesh{Path: fields[0]}
+	if len(fields) > 3 {
+		return in, fmt.Errorf("invalid input '%v'", val)
+	}
+	if len(fields) > 1 {
+		if err := in.Area.UnmarshalText([]byte(fields[1])); err != nil {
+			return in, err
+		}
+	}
+	if len(fields) > 2 {
+		slope, err := strconv.ParseFloat(fields[2], 32)
+		if err != nil {
+			return in, fmt.Errorf("invalid max slope '%v'", fields[2])
+		}
+		in.MaxSlope = float32(slope)
+	}
+	return in, nil
+}
+
+// setInputGeom sets the input geometry of a navmesh builder, either from a
+// geometry set or from the input meshes. Each input mesh is transformed then
+// added to the input geometry, with its area id and maximum slope.
+func setInputGeom(geom *recast.InputGeom, inputs []inputMesh) error {
+	for _, in := range inputs {
+		if filepath.Ext(in.Path) != ".gset" {
+			continue
+		}
+		if len(inputs) != 1 {
+			return fmt.Errorf("a geometry set must be the only input")
+		}
+		if _, err := geom.LoadGeomSet(in.Path); err != nil {
+			return fmt.Errorf("couldn't load '%v': %v", in.Path, err)
+		}
+		return nil
+	}
+
+	for _, in := range inputs {
+		r, err := os.Open(in.Path)
+		if err != nil {
+			return err
+		}
+		mesh := recast.NewMeshLoaderOBJ()
+		err = mesh.Load(r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("couldn't load '%v': %v", in.Path, err)
+		}
+		mesh.Transform(in.Transform)
+
+		// PolyAreaGround is also the null area, walkable triangles get the
+		// default area, converted to the ground area once built.
+		if err = geom.AddMesh(mesh, uint8(in.Area), in.MaxSlope); err != nil {
+			return fmt.Errorf("couldn't add '%v': %v", in.Path, err)
+		}
+	}
+	return nil
 }
 
 func doBuild(cmd *cobra.Command, args []string) {
-	// check existence of input geometry flags
-	if len(inputVal) == 0 {
-		fmt.Printf("missing input geometry file (--input)")
+	// unmarshall build settings, the settings file is optional with a preset
+	mustExist := len(presetVal) == 0 || cmd.Flags().Changed("config")
+	cfg, err := loadBuildSettings(typeVal, presetVal, cfgVal, mustExist)
+	check(err)
+	var inputs inputSettings
+	if fileExists(cfgVal) == nil {
+		err = unmarshalConfigFile(cfgVal, &inputs)
+		check(err)
+	}
+
+	// gather input geometry files
+	for i := range inputs.Inputs {
+		if path := inputs.Inputs[i].Path; !filepath.IsAbs(path) {
+			inputs.Inputs[i].Path = filepath.Join(filepath.Dir(cfgVal), path)
+		}
+	}
+	var flagInputs []inputMesh
+	for _, val := range inputVals {
+		in, err := parseInputFlag(val)
+		check(err)
+		flagInputs = append(flagInputs, in)
+	}
+	inputs.Inputs = append(flagInputs, inputs.Inputs...)
+	if len(inputs.Inputs) == 0 {
+		fmt.Println("missing input geometry file (--input or inputs in build settings)")
+		return
+	}
+
+	profiles, err := loadProfiles(typeVal, cfg, cfgVal)
+	check(err)
+	if len(profiles) != 0 && surfaceVal {
+		fmt.Println("--surface doesn't support agent profiles")
 		return
 	}
 
@@ -43,53 +245,55 @@ func doBuild(cmd *cobra.Command, args []string) {
 	//
 
 	var (
-		navMesh *detour.NavMesh
-		err     error
-		ok      bool
+		navMeshes []*detour.NavMesh
+		surface   *recast.SurfaceMesh
+		geom      recast.InputGeom
+		ok        bool
 	)
 	ctx := recast.NewBuildContext(true)
+	check(setInputGeom(&geom, inputs.Inputs))
+	if progressVal {
+		ctx.SetProgressFunc(printProgress(typeVal == "tile"))
+	}
+
+	settings := []recast.BuildSettings{cfg}
+	if len(profiles) != 0 {
+		settings = settings[:0]
+		for _, p := range profiles {
+			settings = append(settings, p.Settings)
+		}
+	}
 
 	switch typeVal {
 
 	case "solo":
 
-		// unmarshall build settings
-		var cfg recast.BuildSettings
-		err = unmarshalYAMLFile(cfgVal, &cfg)
-		check(err)
-
-		// read input geometry
 		soloMesh := solomesh.New(ctx)
-		var r *os.File
-		r, err = os.Open(inputVal)
-		check(err)
-		defer r.Close()
-
 		soloMesh.SetSettings(cfg)
-		if err = soloMesh.LoadGeometry(r); err != nil {
-			check(err)
+		*soloMesh.InputGeom() = geom
+		if surfaceVal {
+			surface, ok = soloMesh.BuildSurface()
+		} else {
+			// profiles share the rasterization of the input geometry
+			navMeshes, ok = soloMesh.BuildProfiles(settings)
 		}
-		navMesh, ok = soloMesh.Build()
 
 	case "tile":
 
-		// unmarshall build settings
-		var cfg recast.BuildSettings
-		err = unmarshalYAMLFile(cfgVal, &cfg)
-		check(err)
-
-		// read input geometry
-		tileMesh := tilemesh.New(ctx)
-		var r *os.File
-		r, err = os.Open(inputVal)
-		check(err)
-		defer r.Close()
-
-		tileMesh.SetSettings(cfg)
-		if err = tileMesh.LoadGeometry(r); err != nil {
-			check(err)
+		if surfaceVal {
+			fmt.Println("--surface is only supported by solo navmeshes")
+			return
+		}
+		for _, s := range settings {
+			tileMesh := tilemesh.New(ctx)
+			tileMesh.SetSettings(s)
+			*tileMesh.InputGeom() = geom
+			var navMesh *detour.NavMesh
+			if navMesh, ok = tileMesh.Build(); !ok {
+				break
+			}
+			navMeshes = append(navMeshes, navMesh)
 		}
-		navMesh, ok = tileMesh.Build()
 
 	default:
 		fmt.Printf("unknown (or unimplemented) navmesh type '%v'\n", typeVal)
@@ -97,32 +301,117 @@ func doBuild(cmd *cobra.Command, args []string) {
 	}
 
 	ctx.DumpLog(os.Stdout, "")
+	if timersVal {
+		fmt.Println("build times:")
+		for _, e := range ctx.TimerReport() {
+			fmt.Printf("  %-28s %v\n", e.Label.String()+":", e.Duration)
+		}
+	}
 
 	//
 	// save
 	//
 
 	if !ok {
-		fmt.Printf("couldn't build navmesh for %v\n", inputVal)
+		fmt.Println("couldn't build navmesh")
 		return
 	}
 
 	// check output file name
 	out := "navmesh.bin"
+	switch {
+	case surfaceVal:
+		out = "surface.obj"
+	case dirVal:
+		out = "navmesh"
+	}
 	if len(args) >= 1 {
 		out = args[0]
 	}
-	if err = fileExists(out); err == nil {
-		msg := fmt.Sprintf("\n'%v' already exists, overwrite? [y/N]", out)
-		if overwrite := askForConfirmation(msg); !overwrite {
-			fmt.Println("aborted")
+
+	if surfaceVal {
+		if !confirmOverwrite(out, out) {
 			return
 		}
+		check(saveSurface(surface, out))
+		fmt.Println("success")
+		fmt.Printf("walkable surface written to '%v'\n", out)
+		return
 	}
 
-	err = navMesh.SaveToFile(out)
-	check(err)
+	var written []string
+	for i, navMesh := range navMeshes {
+		path := out
+		if len(profiles) != 0 {
+			path = profileOutput(out, profiles[i].Name)
+		}
+		exists := path
+		if dirVal {
+			exists = filepath.Join(path, detour.ManifestFile)
+		}
+		if !confirmOverwrite(path, exists) {
+			return
+		}
+		if dirVal {
+			err = navMesh.EncodeDir(path)
+		} else {
+			err = navMesh.SaveToFile(path)
+		}
+		check(err)
+		written = append(written, path)
+	}
 
 	fmt.Println("success")
-	fmt.Printf("navmesh written to '%v'\n", out)
+	for _, path := range written {
+		fmt.Printf("navmesh written to '%v'\n", path)
+	}
+}
+
+// printProgress returns a function printing the build progress on stderr.
+// With tiles, only the progress of the whole build is printed, not the one of
+// each step of each tile.
+func printProgress(tiles bool) recast.ProgressFunc {
+	return func(stage string, percent float32) {
+		if tiles && stage != tilemesh.ProgressStage {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "\r%-28s %3.0f%%", stage+":", percent)
+		if percent == 100 {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
+
+// confirmOverwrite asks for confirmation before overwriting out, if the
+// exists file exists, and reports whether out can be written.
+func confirmOverwrite(out, exists string) bool {
+	if err := fileExists(exists); err != nil {
+		return true
+	}
+	msg := fmt.Sprintf("\n'%v' already exists, overwrite? [y/N]", out)
+	if overwrite := askForConfirmation(msg); !overwrite {
+		fmt.Println("aborted")
+		return false
+	}
+	return true
+}
+
+// profileOutput returns the output path of the navmesh of an agent profile,
+// that is out with the profile name appended to its base name.
+func profileOutput(out, name string) string {
+	ext := filepath.Ext(out)
+	return strings.TrimSuffix(out, ext) + "_" + name + ext
+}
+
+// saveSurface saves the walkable surface mesh to an OBJ file.
+func saveSurface(surface *recast.SurfaceMesh, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = surface.WriteOBJ(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/arl/go-detour/detour"
+	"github.com/spf13/cobra"
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check NAVMESH",
+	Short: "check a navmesh for structural consistency",
+	Long: `Read a navigation mesh from binary file then validate its structure:
+tile references and salts, polygon vertices and neighbours, link lists,
+reciprocity of the links between polygons, detail meshes, off-mesh connections
+and bounding volume trees.
+
+Problems are printed on standard output, the command exits with a non-zero
+status if any is found.`,
+	Run: doCheck,
+}
+
+func init() {
+	RootCmd.AddCommand(checkCmd)
+}
+
+func doCheck(cmd *cobra.Command, args []string) {
+	// check existence of navmesh
+	if len(args) < 1 {
+		fmt.Printf("no input navmesh file")
+		return
+	}
+
+	navmesh, err := loadNavMesh(args[0])
+	check(err)
+
+	issues, err := detour.ValidateNavMesh(navmesh)
+	check(err)
+	for _, is := range issues {
+		fmt.Println(is)
+	}
+	if len(issues) != 0 {
+		fmt.Printf("'%v': %d problem(s) found\n", args[0], len(issues))
+		os.Exit(-1)
+	}
+	fmt.Printf("'%v': ok\n", args[0])
+}
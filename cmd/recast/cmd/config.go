@@ -4,10 +4,6 @@ import (
 	"fmt"
 	"os"
 
-	yaml "gopkg.in/yaml.v2"
-
-	"github.com/arl/go-detour/sample/solomesh"
-	"github.com/arl/go-detour/sample/tilemesh"
 	"github.com/spf13/cobra"
 )
 
@@ -15,50 +11,36 @@ import (
 var configCmd = &cobra.Command{
 	Use:   "config [FILE]",
 	Short: "generate a config file with default build settings",
-	Long: `Write to FILE a build config in YAML format, pre-filled with the
-default settings to build a navmesh of type TYPE.
+	Long: `Write to FILE a build config, pre-filled with the default settings to
+build a navmesh of type TYPE. The config is written in JSON if FILE has the
+.json extension, in YAML otherwise.
+
+With --preset, the settings of the named preset are used instead of the
+default ones. Available presets are:
+
+  human    a person sized agent (default settings)
+  vehicle  a wide and low agent, unable to climb steps or steep slopes
+  giant    a very large agent, walking over obstacles of human height
 
-To use the generated file, call "recast build --cfg FILE".`,
+To use the generated file, call "recast build --config FILE".`,
 	Run: doConfig,
 }
 
 var (
-	defaultCfgs = make(map[string][]byte)
-	typeVal     string
+	typeVal   string
+	presetVal string
 )
 
 func init() {
 	RootCmd.AddCommand(configCmd)
 	configCmd.Flags().StringVar(&typeVal, "type", "solo", "navmesh type, 'solo' or 'tile'")
-
-	// register the default build config for solo mesh
-	if buf, err := yaml.Marshal(solomesh.DefaultSettings()); err != nil {
-		fmt.Println("couldn't register solomesh default settings,", err)
-	} else {
-		defaultCfgs["solo"] = buf
-	}
-
-	// register the default build config for tile mesh
-	if buf, err := yaml.Marshal(tilemesh.DefaultSettings()); err != nil {
-		fmt.Println("couldn't register tilemesh default settings,", err)
-	} else {
-		defaultCfgs["tile"] = buf
-	}
-
-	// navmesh default type flag
-	typeVal = "solo"
+	configCmd.Flags().StringVar(&presetVal, "preset", "", "build settings preset, 'human', 'vehicle' or 'giant'")
 }
 
 func doConfig(cmd *cobra.Command, args []string) {
-	// check navmesh type
-	var (
-		cfg []byte
-		ok  bool
-		err error
-	)
-
-	if cfg, ok = defaultCfgs[typeVal]; !ok {
-		fmt.Printf("error, unknown (or unimplemented) navmesh type '%v'\n", typeVal)
+	settings, err := defaultSettings(typeVal, presetVal)
+	if err != nil {
+		fmt.Printf("error, %v\n", err)
 		return
 	}
 
@@ -75,6 +57,9 @@ func doConfig(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	cfg, err := marshalConfig(path, settings)
+	check(err)
+
 	// write config
 	f, err := os.Create(path)
 	check(err)
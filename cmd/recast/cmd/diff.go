@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/arl/go-detour/detour"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff NAVMESH1 NAVMESH2",
+	Short: "compare the structure of two navmeshes",
+	Long: `Read two navigation meshes from binary files then compare them tile by tile:
+polygon, vertex and off-mesh connection counts, then for each polygon its area,
+type, flags, vertices and connectivity. When the number of polygons of a tile
+differs, the number of polygons of each area is compared instead.
+
+Vertices are considered equal if their coordinates differ by at most --tol.
+
+Differences are printed on standard output, the command exits with a non-zero
+status if any is found. It's meant to detect regressions when changing build
+settings or upgrading the package.`,
+	Run: doDiff,
+}
+
+var tolVal float32
+
+func init() {
+	RootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().Float32Var(&tolVal, "tol", 0.001, "tolerance on vertex coordinates")
+}
+
+func doDiff(cmd *cobra.Command, args []string) {
+	// check existence of navmeshes
+	if len(args) < 2 {
+		fmt.Printf("two navmesh files are required")
+		return
+	}
+
+	a, err := loadNavMesh(args[0])
+	check(err)
+	b, err := loadNavMesh(args[1])
+	check(err)
+
+	diffs, err := detour.DiffNavMesh(a, b, tolVal)
+	check(err)
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	if len(diffs) != 0 {
+		fmt.Printf("'%v' and '%v': %d difference(s) found\n", args[0], args[1], len(diffs))
+		os.Exit(-1)
+	}
+	fmt.Printf("'%v' and '%v': no differences\n", args[0], args[1])
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/arl/go-detour/recast"
+	"github.com/arl/go-detour/sample/fixture"
+	"github.com/spf13/cobra"
+)
+
+// genFixturesCmd represents the gen-fixtures command
+var genFixturesCmd = &cobra.Command{
+	Use:   "gen-fixtures [DIR]",
+	Short: "generate canonical test geometries and their navmeshes",
+	Long: `Write to DIR small canonical input geometries in OBJ, along with their
+navigation meshes in binary format, built with the default settings.
+
+The generated fixtures are:
+  plane    flat 10x10 square
+  rooms    two 8x8 rooms joined by a 2x6 corridor
+  floors   two stacked 10x10 floors joined by a ramp
+  donut    20x20 square with an 8x8 hole in its middle
+  tiled    flat 38x38 square, built as 4x4 tiles
+
+These are the geometries used by go-detour own tests, they're the recommended
+way to reproduce a bug with standard geometry. DIR defaults to the current
+directory.`,
+	Run: doGenFixtures,
+}
+
+func init() {
+	RootCmd.AddCommand(genFixturesCmd)
+}
+
+func doGenFixtures(cmd *cobra.Command, args []string) {
+	dir := "."
+	if len(args) >= 1 {
+		dir = args[0]
+	}
+	err := os.MkdirAll(dir, 0755)
+	check(err)
+
+	fixtures := fixture.All()
+
+	// check output files
+	exists := ""
+	for _, f := range fixtures {
+		for _, ext := range []string{".obj", ".bin"} {
+			if path := filepath.Join(dir, f.Name+ext); exists == "" && fileExists(path) == nil {
+				exists = path
+			}
+		}
+	}
+	if exists != "" {
+		msg := fmt.Sprintf("\n'%v' already exists, overwrite fixtures? [y/N]", exists)
+		if overwrite := askForConfirmation(msg); !overwrite {
+			fmt.Println("aborted")
+			return
+		}
+	}
+
+	for _, f := range fixtures {
+		path := filepath.Join(dir, f.Name+".obj")
+		w, err := os.Create(path)
+		check(err)
+		err = f.WriteOBJ(w)
+		w.Close()
+		check(err)
+
+		ctx := recast.NewBuildContext(true)
+		navMesh, err := f.Build(ctx)
+		if err != nil {
+			ctx.DumpLog(os.Stdout, "")
+		}
+		check(err)
+
+		binPath := filepath.Join(dir, f.Name+".bin")
+		err = navMesh.SaveToFile(binPath)
+		check(err)
+		fmt.Printf("%-8v %v, %v\n", f.Name, path, binPath)
+	}
+	fmt.Println("success")
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/arl/go-detour/detour"
+	"github.com/spf13/cobra"
+)
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+	Use:     "info NAVMESH",
+	Aliases: []string{"infos"},
+	Short:   "show infos about a navmesh",
+	Long: `Read a navigation mesh from binary file then print informations on
+standard output: parameters, polygon reference bit layout, bounds, number of
+tiles, polygons, vertices and off-mesh connections.
+
+With --tiles, the same counts are printed for each tile.`,
+	Run: doInfo,
+}
+
+var tilesVal bool
+
+func init() {
+	RootCmd.AddCommand(infoCmd)
+	infoCmd.Flags().BoolVar(&tilesVal, "tiles", false, "print infos of each tile")
+}
+
+// loadNavMesh reads and decodes the navmesh in the binary file at path, or in
+// the directory at path if it has been saved with 'build --dir'.
+func loadNavMesh(path string) (*detour.NavMesh, error) {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return detour.DecodeDir(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return detour.Decode(f)
+}
+
+func doInfo(cmd *cobra.Command, args []string) {
+	// check existence of navmesh
+	if len(args) < 1 {
+		fmt.Printf("no input navmesh file")
+		return
+	}
+	binMesh := args[0]
+
+	navmesh, err := loadNavMesh(binMesh)
+	check(err)
+
+	// marshall navmesh params to json
+	var buf []byte
+	buf, err = json.MarshalIndent(navmesh.Params, "", "  ")
+	check(err)
+	fmt.Printf("successfully loaded '%v'\n", binMesh)
+	fmt.Printf("params:\n%s\n", string(buf))
+
+	salt, tile, poly := navmesh.IDBits()
+	fmt.Printf("polygon reference bits: salt %d, tile %d, poly %d\n", salt, tile, poly)
+
+	var (
+		ntiles, npolys, nverts, ncons int32
+		bmin, bmax                    [3]float32
+	)
+	for i := range navmesh.Tiles {
+		hdr := navmesh.Tiles[i].Header
+		if hdr == nil {
+			continue
+		}
+		if ntiles == 0 {
+			bmin, bmax = hdr.BMin, hdr.BMax
+		}
+		for k := 0; k < 3; k++ {
+			if hdr.BMin[k] < bmin[k] {
+				bmin[k] = hdr.BMin[k]
+			}
+			if hdr.BMax[k] > bmax[k] {
+				bmax[k] = hdr.BMax[k]
+			}
+		}
+		ntiles++
+		npolys += hdr.PolyCount
+		nverts += hdr.VertCount
+		ncons += hdr.OffMeshConCount
+
+		if tilesVal {
+			fmt.Printf("tile (%d, %d, %d): %d polygons, %d vertices, %d off-mesh connections, bounds %v %v\n",
+				hdr.X, hdr.Y, hdr.Layer, hdr.PolyCount, hdr.VertCount, hdr.OffMeshConCount, hdr.BMin, hdr.BMax)
+		}
+	}
+	fmt.Printf("tiles: %d (max %d)\n", ntiles, navmesh.MaxTiles)
+	fmt.Printf("polygons: %d (including off-mesh connections)\n", npolys)
+	fmt.Printf("vertices: %d\n", nverts)
+	fmt.Printf("off-mesh connections: %d\n", ncons)
+	fmt.Printf("bounds: %v %v\n", bmin, bmax)
+
+	mem := navmesh.MemoryStats()
+	fmt.Printf("memory: %d bytes (mesh %d, verts %d, polys %d, links %d, detail %d, bvtree %d, off-mesh connections %d, wall distances %d, data %d)\n",
+		mem.TotalBytes(), mem.Mesh, mem.Total.Verts, mem.Total.Polys, mem.Total.Links,
+		mem.Total.Detail, mem.Total.BvTree, mem.Total.OffMeshCons, mem.Total.WallDist, mem.Total.Data)
+}
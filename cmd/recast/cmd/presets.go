@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/arl/go-detour/recast"
+	"github.com/arl/go-detour/sample"
+	"github.com/arl/go-detour/sample/solomesh"
+	"github.com/arl/go-detour/sample/tilemesh"
+)
+
+// buildPresets are named build settings for typical agent sizes. A preset
+// overrides the rasterization, agent, region and detail mesh settings of the
+// default settings of a navmesh type.
+var buildPresets = map[string]func(s *recast.BuildSettings){
+	// human is a person sized agent, the default settings.
+	"human": func(s *recast.BuildSettings) {
+		s.CellSize, s.CellHeight = 0.3, 0.2
+		s.AgentHeight, s.AgentRadius = 2, 0.6
+		s.AgentMaxClimb, s.AgentMaxSlope = 0.9, 45
+		s.RegionMinSize, s.RegionMergeSize = 8, 20
+		s.EdgeMaxLen, s.EdgeMaxError = 12, 1.3
+		s.DetailSampleDist, s.DetailSampleMaxError = 6, 1
+		s.PartitionType = int32(sample.PartitionMonotone)
+	},
+
+	// vehicle is a wide and low agent, unable to climb steps or steep slopes.
+	"vehicle": func(s *recast.BuildSettings) {
+		s.CellSize, s.CellHeight = 0.5, 0.25
+		s.AgentHeight, s.AgentRadius = 2.5, 1.5
+		s.AgentMaxClimb, s.AgentMaxSlope = 0.3, 20
+		s.RegionMinSize, s.RegionMergeSize = 16, 40
+		s.EdgeMaxLen, s.EdgeMaxError = 24, 1.3
+		s.DetailSampleDist, s.DetailSampleMaxError = 8, 1
+		s.PartitionType = int32(sample.PartitionMonotone)
+	},
+
+	// giant is a very large agent, walking over obstacles of human height.
+	"giant": func(s *recast.BuildSettings) {
+		s.CellSize, s.CellHeight = 0.6, 0.4
+		s.AgentHeight, s.AgentRadius = 8, 2.5
+		s.AgentMaxClimb, s.AgentMaxSlope = 2, 50
+		s.RegionMinSize, s.RegionMergeSize = 12, 30
+		s.EdgeMaxLen, s.EdgeMaxError = 30, 1.5
+		s.DetailSampleDist, s.DetailSampleMaxError = 8, 2
+		s.PartitionType = int32(sample.PartitionMonotone)
+	},
+}
+
+// presetNames returns the sorted names of the build presets.
+func presetNames() []string {
+	var names []string
+	for name := range buildPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// defaultSettings returns the build settings of a navmesh type, with the
+// named preset applied, if not empty.
+func defaultSettings(typ, preset string) (recast.BuildSettings, error) {
+	var s recast.BuildSettings
+	switch typ {
+	case "solo":
+		s = solomesh.DefaultSettings()
+	case "tile":
+		s = tilemesh.DefaultSettings()
+	default:
+		return s, fmt.Errorf("unknown (or unimplemented) navmesh type '%v'", typ)
+	}
+
+	if len(preset) != 0 {
+		apply, ok := buildPresets[preset]
+		if !ok {
+			return s, fmt.Errorf("unknown preset '%v', should be one of %v", preset, strings.Join(presetNames(), ", "))
+		}
+		apply(&s)
+	}
+	return s, nil
+}
+
+// configPreset is the preset section of a build settings file.
+type configPreset struct {
+	Preset string
+}
+
+// loadBuildSettings returns the build settings of a navmesh type.
+//
+// Settings start from the default settings of the navmesh type, with a preset
+// applied, the one given by preset or else the one named in the settings
+// file. The settings file then overrides any setting it defines. If
+// mustExist is false, a missing settings file is not an error.
+func loadBuildSettings(typ, preset, path string, mustExist bool) (recast.BuildSettings, error) {
+	exists := fileExists(path) == nil
+	if !exists && mustExist {
+		return recast.BuildSettings{}, fmt.Errorf("no such file '%v'", path)
+	}
+
+	if exists && len(preset) == 0 {
+		var p configPreset
+		if err := unmarshalConfigFile(path, &p); err != nil {
+			return recast.BuildSettings{}, err
+		}
+		preset = p.Preset
+	}
+
+	s, err := defaultSettings(typ, preset)
+	if err != nil || !exists {
+		return s, err
+	}
+	err = unmarshalConfigFile(path, &s)
+	return s, err
+}
+
+// buildProfile is a named agent profile, a navmesh is built for each.
+type buildProfile struct {
+	Name     string
+	Settings recast.BuildSettings
+}
+
+// configProfiles is the agent profiles section of a build settings file.
+type configProfiles struct {
+	Profiles []map[string]interface{}
+}
+
+// loadProfiles returns the agent profiles defined in the settings file, if
+// any.
+//
+// Each profile has a name, its settings start from base, or from the
+// default settings of the navmesh type with a preset applied if the profile
+// names one. The settings defined in the profile then override them:
+//
+//	profiles:
+//	- name: small
+//	  agentradius: 0.4
+//	- name: large
+//	  preset: giant
+func loadProfiles(typ string, base recast.BuildSettings, path string) ([]buildProfile, error) {
+	if fileExists(path) != nil {
+		return nil, nil
+	}
+	var cfg configProfiles
+	if err := unmarshalConfigFile(path, &cfg); err != nil {
+		return nil, err
+	}
+
+	var (
+		profiles []buildProfile
+		names    = make(map[string]bool)
+	)
+	for i, raw := range cfg.Profiles {
+		// Decode the profile again, as a settings file.
+		buf, err := marshalConfig(path, raw)
+		if err != nil {
+			return nil, err
+		}
+		var hdr struct {
+			Name   string
+			Preset string
+		}
+		if err = unmarshalConfig(path, buf, &hdr); err != nil {
+			return nil, err
+		}
+		if len(hdr.Name) == 0 {
+			return nil, fmt.Errorf("profile %d has no name", i)
+		}
+		if names[hdr.Name] {
+			return nil, fmt.Errorf("duplicate profile name '%v'", hdr.Name)
+		}
+		names[hdr.Name] = true
+
+		p := buildProfile{Name: hdr.Name, Settings: base}
+		if len(hdr.Preset) != 0 {
+			if p.Settings, err = defaultSettings(typ, hdr.Preset); err != nil {
+				return nil, fmt.Errorf("profile '%v': %v", hdr.Name, err)
+			}
+		}
+		if err = unmarshalConfig(path, buf, &p.Settings); err != nil {
+			return nil, fmt.Errorf("profile '%v': %v", hdr.Name, err)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/arl/go-detour/detour"
+	"github.com/arl/go-detour/sample"
+	"github.com/spf13/cobra"
+)
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune NAVMESH",
+	Short: "disable the polygons unreachable from seed positions",
+	Long: `Read a navigation mesh from binary file, flood-fill it from one or more
+seed positions then disable the polygons that can't be reached, like the
+RecastDemo prune tool. Isolated islands, such as roof tops or the inside of
+closed meshes, then don't attract FindNearestPoly results anymore:
+
+  recast prune navmesh.bin --seed 5,0,10 --seed 40,8,3 -o pruned.bin
+
+The links between polygons, off-mesh connections included, are followed. The
+unreachable polygons are disabled by adding --flags to their flags, they're
+ignored by the queries whose filter excludes these flags.
+
+The navmesh is overwritten if no output file is given. A navmesh directory
+(see 'recast build --dir') is written as a directory.`,
+	Run: doPrune,
+}
+
+var (
+	seedsVal        []string
+	pruneExtentsVal string
+	pruneFlagsVal   string
+	pruneOutVal     string
+)
+
+func init() {
+	RootCmd.AddCommand(pruneCmd)
+	pruneCmd.Flags().StringArrayVar(&seedsVal, "seed", nil, "seed position, as x,y,z (repeatable)")
+	pruneCmd.Flags().StringVar(&pruneExtentsVal, "extents", "2,4,2", "search extents of the seed polygons, as x,y,z")
+	pruneCmd.Flags().StringVar(&pruneFlagsVal, "flags", strconv.Itoa(sample.PolyFlagsDisabled), "flags added to the unreachable polygons")
+	pruneCmd.Flags().StringVarP(&pruneOutVal, "output", "o", "", "output navmesh file (defaults to the input file)")
+}
+
+func doPrune(cmd *cobra.Command, args []string) {
+	// check existence of navmesh
+	if len(args) < 1 {
+		fmt.Printf("no input navmesh file")
+		return
+	}
+	if len(seedsVal) == 0 {
+		check(fmt.Errorf("at least one --seed is required"))
+	}
+	extents, err := parseVec3(pruneExtentsVal)
+	check(err)
+	flags, err := strconv.ParseUint(pruneFlagsVal, 0, 16)
+	check(err)
+
+	// read and decode navmesh
+	navmesh, err := loadNavMesh(args[0])
+	check(err)
+
+	st, query := detour.NewNavMeshQuery(navmesh, 2048)
+	if detour.StatusFailed(st) {
+		check(fmt.Errorf("query creation failed, status: %v", st))
+	}
+	filter := detour.NewStandardQueryFilter()
+
+	seeds := make([]detour.PolyRef, 0, len(seedsVal))
+	for _, s := range seedsVal {
+		pos, err := parseVec3(s)
+		check(err)
+		st, ref, _ := query.FindNearestPoly(pos, extents, filter)
+		if detour.StatusFailed(st) || ref == 0 {
+			check(fmt.Errorf("no polygon found near seed position %v", pos))
+		}
+		seeds = append(seeds, ref)
+	}
+
+	n, st := detour.PruneUnreachable(navmesh, seeds, uint16(flags))
+	if detour.StatusFailed(st) {
+		check(fmt.Errorf("prune failed, status: %v", st))
+	}
+
+	out := pruneOutVal
+	if out == "" {
+		out = args[0]
+	}
+	if fi, err := os.Stat(out); err == nil && fi.IsDir() {
+		check(navmesh.EncodeDir(out))
+	} else {
+		check(navmesh.SaveToFile(out))
+	}
+	fmt.Printf("'%v': %d polygon(s) disabled, saved to '%v'\n", args[0], n, out)
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/arl/go-detour/debugdraw"
+	"github.com/arl/go-detour/detour"
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/spf13/cobra"
+)
+
+// queryCmd represents the query command
+var queryCmd = &cobra.Command{
+	Use:   "query NAVMESH",
+	Short: "find a path on a navmesh",
+	Long: `Read a navigation mesh from binary file, find the path between two
+positions, then print it on standard output.
+
+The polygon corridor is found with FindPath, then the straight path with
+FindStraightPath. The path is printed as text (default), as JSON, or as an
+OBJ polyline that can be loaded along the input geometry in any 3D viewer:
+
+  recast query navmesh.bin --from 5,0,10 --to 50,0,30 --format obj > path.obj
+
+With --format svg or png, the navmesh and the path are drawn as seen from
+above, which is handy to attach to a bug report:
+
+  recast query navmesh.bin --from 5,0,10 --to 50,0,30 --format png > path.png
+
+The command exits with a non-zero status if no complete path is found,
+which makes it suitable for smoke tests.`,
+	Run: doQuery,
+}
+
+var (
+	fromVal, toVal, extentsVal string
+	includeVal, excludeVal     string
+	formatVal                  string
+	maxPathVal                 int
+)
+
+func init() {
+	RootCmd.AddCommand(queryCmd)
+	queryCmd.Flags().StringVar(&fromVal, "from", "", "start position, as x,y,z")
+	queryCmd.Flags().StringVar(&toVal, "to", "", "end position, as x,y,z")
+	queryCmd.Flags().StringVar(&extentsVal, "extents", "2,4,2", "search extents of the nearest polygons, as x,y,z")
+	queryCmd.Flags().StringVar(&includeVal, "include", "0xffff", "filter include flags")
+	queryCmd.Flags().StringVar(&excludeVal, "exclude", "0", "filter exclude flags")
+	queryCmd.Flags().StringVar(&formatVal, "format", "text", "output format, 'text', 'json', 'obj', 'svg' or 'png'")
+	queryCmd.Flags().IntVar(&maxPathVal, "max-path", 256, "maximum number of polygons in the path")
+}
+
+// pathPoint is a point of the straight path.
+type pathPoint struct {
+	Pos   [3]float32     `json:"pos"`
+	Flags uint8          `json:"flags"`
+	Ref   detour.PolyRef `json:"ref"`
+}
+
+// queryResult is the result of a path query.
+type queryResult struct {
+	From         [3]float32       `json:"from"`
+	To           [3]float32       `json:"to"`
+	Path         []detour.PolyRef `json:"path"`
+	StraightPath []pathPoint      `json:"straightPath"`
+	Partial      bool             `json:"partial"`
+}
+
+func doQuery(cmd *cobra.Command, args []string) {
+	// check existence of navmesh
+	if len(args) < 1 {
+		fmt.Printf("no input navmesh file")
+		return
+	}
+
+	from, err := parseVec3(fromVal)
+	check(err)
+	to, err := parseVec3(toVal)
+	check(err)
+	extents, err := parseVec3(extentsVal)
+	check(err)
+	include, err := strconv.ParseUint(includeVal, 0, 16)
+	check(err)
+	exclude, err := strconv.ParseUint(excludeVal, 0, 16)
+	check(err)
+
+	// read and decode navmesh
+	navmesh, err := loadNavMesh(args[0])
+	check(err)
+
+	filter := detour.NewStandardQueryFilter()
+	filter.SetIncludeFlags(uint16(include))
+	filter.SetExcludeFlags(uint16(exclude))
+
+	res, err := queryPath(navmesh, from, to, extents, filter, maxPathVal)
+	check(err)
+
+	switch formatVal {
+	case "text":
+		writeQueryText(os.Stdout, res)
+	case "json":
+		buf, err := json.MarshalIndent(res, "", "  ")
+		check(err)
+		fmt.Println(string(buf))
+	case "obj":
+		writeQueryOBJ(os.Stdout, res)
+	case "svg", "png":
+		opts := debugdraw.RenderOptions{Flags: debugdraw.DrawNavMeshOffMeshCons}
+		if formatVal == "png" {
+			opts.Format = debugdraw.FormatPNG
+		}
+		for _, pt := range res.StraightPath {
+			opts.Path = append(opts.Path, d3.NewVec3From(pt.Pos[:]))
+		}
+		check(debugdraw.RenderTopDown(os.Stdout, navmesh, opts))
+	default:
+		check(fmt.Errorf("unknown output format '%v'", formatVal))
+	}
+
+	if res.Partial {
+		check(fmt.Errorf("no complete path from %v to %v", from, to))
+	}
+}
+
+// parseVec3 parses a vector given as x,y,z.
+func parseVec3(s string) (d3.Vec3, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid vector '%v', want x,y,z", s)
+	}
+	v := d3.NewVec3()
+	for i, f := range fields {
+		c, err := strconv.ParseFloat(strings.TrimSpace(f), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector '%v': %v", s, err)
+		}
+		v[i] = float32(c)
+	}
+	return v, nil
+}
+
+// queryPath finds the polygon corridor and the straight path between from
+// and to.
+func queryPath(navmesh *detour.NavMesh, from, to, extents d3.Vec3, filter detour.QueryFilter, maxPath int) (*queryResult, error) {
+	st, query := detour.NewNavMeshQuery(navmesh, 2048)
+	if detour.StatusFailed(st) {
+		return nil, fmt.Errorf("query creation failed, status: %v", st)
+	}
+
+	st, fromRef, fromPos := query.FindNearestPoly(from, extents, filter)
+	if detour.StatusFailed(st) || fromRef == 0 {
+		return nil, fmt.Errorf("no polygon found near start position %v", from)
+	}
+	st, toRef, toPos := query.FindNearestPoly(to, extents, filter)
+	if detour.StatusFailed(st) || toRef == 0 {
+		return nil, fmt.Errorf("no polygon found near end position %v", to)
+	}
+
+	path := make([]detour.PolyRef, maxPath)
+	npath, st := query.FindPath(fromRef, toRef, fromPos, toPos, filter, path)
+	if detour.StatusFailed(st) {
+		return nil, fmt.Errorf("FindPath failed, status: %v", st)
+	}
+	res := &queryResult{
+		Path:    path[:npath],
+		Partial: detour.StatusDetail(st, detour.PartialResult),
+	}
+	copy(res.From[:], fromPos)
+	copy(res.To[:], toPos)
+
+	// In case of partial path, the end point is clamped to the last polygon.
+	endPos := d3.NewVec3From(toPos)
+	if npath > 0 && path[npath-1] != toRef {
+		query.ClosestPointOnPoly(path[npath-1], toPos, endPos, nil)
+	}
+
+	maxStraightPath := maxPath + 2
+	straightPath := make([]d3.Vec3, maxStraightPath)
+	for i := range straightPath {
+		straightPath[i] = d3.NewVec3()
+	}
+	straightPathFlags := make([]uint8, maxStraightPath)
+	straightPathRefs := make([]detour.PolyRef, maxStraightPath)
+	nstraight, st := query.FindStraightPath(fromPos, endPos, path[:npath],
+		straightPath, straightPathFlags, straightPathRefs, 0)
+	if detour.StatusFailed(st) {
+		return nil, fmt.Errorf("FindStraightPath failed, status: %v", st)
+	}
+	for i := 0; i < nstraight; i++ {
+		var pt pathPoint
+		copy(pt.Pos[:], straightPath[i])
+		pt.Flags = straightPathFlags[i]
+		pt.Ref = straightPathRefs[i]
+		res.StraightPath = append(res.StraightPath, pt)
+	}
+	return res, nil
+}
+
+// writeQueryText writes a human readable query result to w.
+func writeQueryText(w io.Writer, res *queryResult) {
+	fmt.Fprintf(w, "from %v to %v\n", res.From, res.To)
+	if res.Partial {
+		fmt.Fprintln(w, "partial path, the end position is not reachable")
+	}
+	fmt.Fprintf(w, "path (%d polygons):\n", len(res.Path))
+	for _, ref := range res.Path {
+		fmt.Fprintf(w, "  0x%x\n", ref)
+	}
+	fmt.Fprintf(w, "straight path (%d points):\n", len(res.StraightPath))
+	for _, pt := range res.StraightPath {
+		fmt.Fprintf(w, "  %f %f %f  flags: 0x%x  ref: 0x%x\n", pt.Pos[0], pt.Pos[1], pt.Pos[2], pt.Flags, pt.Ref)
+	}
+}
+
+// writeQueryOBJ writes the straight path of a query result to w, as an OBJ
+// polyline.
+func writeQueryOBJ(w io.Writer, res *queryResult) {
+	fmt.Fprintf(w, "# path from %v to %v\n", res.From, res.To)
+	for _, pt := range res.StraightPath {
+		fmt.Fprintf(w, "v %f %f %f\n", pt.Pos[0], pt.Pos[1], pt.Pos[2])
+	}
+	if len(res.StraightPath) > 1 {
+		fmt.Fprint(w, "l")
+		for i := range res.StraightPath {
+			fmt.Fprintf(w, " %d", i+1)
+		}
+		fmt.Fprintln(w)
+	}
+}
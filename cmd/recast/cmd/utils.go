@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	yaml "gopkg.in/yaml.v2"
 )
@@ -51,10 +54,35 @@ func check(err error) {
 	}
 }
 
-func unmarshalYAMLFile(path string, out interface{}) error {
+// unmarshalConfigFile decodes the file at path into out, as JSON if path has
+// the .json extension, or as YAML otherwise.
+func unmarshalConfigFile(path string, out interface{}) error {
 	buf, err := ioutil.ReadFile(path)
 	if err != nil {
 		return err
 	}
+	return unmarshalConfig(path, buf, out)
+}
+
+// unmarshalConfig decodes buf into out, as JSON if path has the .json
+// extension, or as YAML otherwise.
+func unmarshalConfig(path string, buf []byte, out interface{}) error {
+	if isJSONFile(path) {
+		return json.Unmarshal(buf, out)
+	}
 	return yaml.Unmarshal(buf, out)
 }
+
+// marshalConfig returns the encoding of in, in JSON if path has the .json
+// extension, or in YAML otherwise.
+func marshalConfig(path string, in interface{}) ([]byte, error) {
+	if isJSONFile(path) {
+		return json.MarshalIndent(in, "", "  ")
+	}
+	return yaml.Marshal(in)
+}
+
+// isJSONFile reports whether path has the .json extension.
+func isJSONFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/arl/go-detour/detour"
+	"github.com/spf13/cobra"
+)
+
+// waypointsCmd represents the waypoints command
+var waypointsCmd = &cobra.Command{
+	Use:   "waypoints NAVMESH",
+	Short: "export the waypoint graph of a navmesh",
+	Long: `Read a navigation mesh from binary file, then export its waypoint graph
+as JSON, for the hierarchical planners or the machine learning agents that
+work on graphs rather than on polygons:
+
+  recast waypoints navmesh.bin -o graph.json
+
+The nodes of the graph are the centers of the polygons and the middles of the
+portals between them, the edges connect each polygon to its portals, in both
+directions, and their costs are the ones of the query filter.
+
+The graph is printed on standard output if no output file is given.`,
+	Run: doWaypoints,
+}
+
+var (
+	wpIncludeVal, wpExcludeVal string
+	wpOutVal                   string
+)
+
+func init() {
+	RootCmd.AddCommand(waypointsCmd)
+	waypointsCmd.Flags().StringVar(&wpIncludeVal, "include", "0xffff", "filter include flags")
+	waypointsCmd.Flags().StringVar(&wpExcludeVal, "exclude", "0", "filter exclude flags")
+	waypointsCmd.Flags().StringVarP(&wpOutVal, "output", "o", "", "output JSON file (defaults to standard output)")
+}
+
+func doWaypoints(cmd *cobra.Command, args []string) {
+	// check existence of navmesh
+	if len(args) < 1 {
+		fmt.Printf("no input navmesh file")
+		return
+	}
+	include, err := strconv.ParseUint(wpIncludeVal, 0, 16)
+	check(err)
+	exclude, err := strconv.ParseUint(wpExcludeVal, 0, 16)
+	check(err)
+
+	// read and decode navmesh
+	navmesh, err := loadNavMesh(args[0])
+	check(err)
+
+	st, query := detour.NewNavMeshQuery(navmesh, 2048)
+	if detour.StatusFailed(st) {
+		check(fmt.Errorf("query creation failed, status: %v", st))
+	}
+	filter := detour.NewStandardQueryFilter()
+	filter.SetIncludeFlags(uint16(include))
+	filter.SetExcludeFlags(uint16(exclude))
+
+	g, st := detour.BuildWaypointGraph(query, filter)
+	if detour.StatusFailed(st) {
+		check(fmt.Errorf("waypoint graph creation failed, status: %v", st))
+	}
+
+	buf, err := json.MarshalIndent(g, "", "  ")
+	check(err)
+	if wpOutVal == "" {
+		fmt.Println(string(buf))
+		return
+	}
+	check(ioutil.WriteFile(wpOutVal, buf, 0644))
+	fmt.Printf("'%v': %d waypoints, %d edges, saved to '%v'\n", args[0], len(g.Nodes), len(g.Edges), wpOutVal)
+}
@@ -0,0 +1,226 @@
+// Package debugdraw draws the navigation meshes and the intermediate recast
+// build results, for debugging purposes.
+//
+// It is a port of the recastnavigation DebugUtils module. The drawing
+// functions emit primitives to a DebugDraw, which can be implemented to feed
+// any rendering backend. Three backends are provided: OBJ, that writes the
+// primitives to a Wavefront OBJ file, and SVG and PNG, that draw them as seen
+// from above. RenderTopDown is a shortcut to draw a navigation mesh, a path
+// and agents into an SVG or PNG image.
+package debugdraw
+
+import (
+	"math"
+
+	"github.com/arl/math32"
+)
+
+// Primitive is the type of the primitives drawn between DebugDraw.Begin and
+// DebugDraw.End.
+type Primitive int
+
+// Primitive types.
+const (
+	Points Primitive = iota // Each vertex is a point.
+	Lines                   // Each pair of vertices is a line segment.
+	Tris                    // Each triple of vertices is a triangle.
+	Quads                   // Each quadruple of vertices is a quad.
+)
+
+// NumVerts returns the number of vertices of a primitive of type p.
+func (p Primitive) NumVerts() int {
+	return int(p) + 1
+}
+
+// DebugDraw is the interface implemented by the debug draw backends.
+//
+// The drawing functions of this package call Begin, then Vertex for each
+// vertex of the primitives, then End.
+type DebugDraw interface {
+	// DepthMask enables or disables the depth test.
+	DepthMask(state bool)
+
+	// Begin starts drawing primitives of type prim. size is the size of the
+	// points or the width of the lines, in pixels.
+	Begin(prim Primitive, size float32)
+
+	// Vertex submits a vertex, at position (x, y, z), with an RGBA color
+	// (see RGBA).
+	Vertex(x, y, z float32, color uint32)
+
+	// End ends drawing primitives.
+	End()
+}
+
+// AreaColorer is an optional interface a DebugDraw can implement to choose
+// the color of each area id.
+type AreaColorer interface {
+	// AreaToCol returns the color of an area id.
+	AreaToCol(area uint8) uint32
+}
+
+// AreaToCol returns the color of an area, as chosen by dd if it implements
+// AreaColorer.
+//
+// The default color is light blue for the area 0 and a color derived from
+// the area id for the others.
+func AreaToCol(dd DebugDraw, area uint8) uint32 {
+	if ac, ok := dd.(AreaColorer); ok {
+		return ac.AreaToCol(area)
+	}
+	if area == 0 {
+		return RGBA(0, 192, 255, 255)
+	}
+	return IntToCol(int(area), 255)
+}
+
+// RGBA packs a color in an uint32, the red component in the lowest byte.
+func RGBA(r, g, b, a uint8) uint32 {
+	return uint32(r) | uint32(g)<<8 | uint32(b)<<16 | uint32(a)<<24
+}
+
+// RGBAf packs a color given as floats in [0, 1] in an uint32.
+func RGBAf(r, g, b, a float32) uint32 {
+	return RGBA(uint8(r*255), uint8(g*255), uint8(b*255), uint8(a*255))
+}
+
+// UnpackRGBA returns the components of a color packed with RGBA.
+func UnpackRGBA(col uint32) (r, g, b, a uint8) {
+	return uint8(col), uint8(col >> 8), uint8(col >> 16), uint8(col >> 24)
+}
+
+// IntToCol returns a color derived from i, with the given alpha. Consecutive
+// integers have distinct colors, which is useful to visualize ids (regions,
+// tiles...).
+func IntToCol(i int, a uint8) uint32 {
+	bit := func(a, b int) int {
+		return (a & (1 << uint(b))) >> uint(b)
+	}
+	r := bit(i, 1) + bit(i, 3)*2 + 1
+	g := bit(i, 2) + bit(i, 4)*2 + 1
+	b := bit(i, 0) + bit(i, 5)*2 + 1
+	return RGBA(uint8(r*63), uint8(g*63), uint8(b*63), a)
+}
+
+// MultCol multiplies the red, green and blue components of col by d/256.
+func MultCol(col, d uint32) uint32 {
+	r := col & 0xff
+	g := (col >> 8) & 0xff
+	b := (col >> 16) & 0xff
+	a := col >> 24
+	return RGBA(uint8((r*d)>>8), uint8((g*d)>>8), uint8((b*d)>>8), uint8(a))
+}
+
+// DarkenCol returns col, darkened by half.
+func DarkenCol(col uint32) uint32 {
+	return ((col >> 1) & 0x007f7f7f) | (col & 0xff000000)
+}
+
+// LerpCol linearly interpolates between ca and cb, u being in [0, 255].
+func LerpCol(ca, cb, u uint32) uint32 {
+	ra, ga, ba, aa := ca&0xff, (ca>>8)&0xff, (ca>>16)&0xff, ca>>24
+	rb, gb, bb, ab := cb&0xff, (cb>>8)&0xff, (cb>>16)&0xff, cb>>24
+	r := (ra*(255-u) + rb*u) / 255
+	g := (ga*(255-u) + gb*u) / 255
+	b := (ba*(255-u) + bb*u) / 255
+	a := (aa*(255-u) + ab*u) / 255
+	return RGBA(uint8(r), uint8(g), uint8(b), uint8(a))
+}
+
+// TransCol returns col with its alpha replaced by a.
+func TransCol(col uint32, a uint8) uint32 {
+	return uint32(a)<<24 | (col & 0x00ffffff)
+}
+
+// appendCircle appends the line segments of an horizontal circle, centered
+// on (x, y, z) and of radius r.
+func appendCircle(dd DebugDraw, x, y, z, r float32, col uint32) {
+	const numSeg = 40
+	var dir [numSeg * 2]float32
+	for i := 0; i < numSeg; i++ {
+		a := float32(i) / numSeg * 2 * math.Pi
+		dir[i*2] = math32.Cos(a)
+		dir[i*2+1] = math32.Sin(a)
+	}
+	for i, j := 0, numSeg-1; i < numSeg; j, i = i, i+1 {
+		dd.Vertex(x+dir[j*2]*r, y, z+dir[j*2+1]*r, col)
+		dd.Vertex(x+dir[i*2]*r, y, z+dir[i*2+1]*r, col)
+	}
+}
+
+// evalArc evaluates the point at u, in [0, 1], of the parabolic arc going
+// from (x0, y0, z0) to (x0+dx, y0+dy, z0+dz) with height h.
+func evalArc(x0, y0, z0, dx, dy, dz, h, u float32) [3]float32 {
+	return [3]float32{
+		x0 + dx*u,
+		y0 + dy*u + h*(1-(u*2-1)*(u*2-1)),
+		z0 + dz*u,
+	}
+}
+
+// appendArc appends the line segments of a parabolic arc from (x0, y0, z0) to
+// (x1, y1, z1), of height h times its length, with arrow heads of sizes as0
+// and as1 at each end (0 for no arrow head).
+func appendArc(dd DebugDraw, x0, y0, z0, x1, y1, z1, h, as0, as1 float32, col uint32) {
+	const (
+		numArcPts   = 8
+		pad         = 0.05
+		arcPtsScale = (1 - pad*2) / numArcPts
+	)
+	dx, dy, dz := x1-x0, y1-y0, z1-z0
+	h *= math32.Sqrt(dx*dx + dy*dy + dz*dz)
+	prev := evalArc(x0, y0, z0, dx, dy, dz, h, pad)
+	for i := 1; i <= numArcPts; i++ {
+		u := pad + float32(i)*arcPtsScale
+		pt := evalArc(x0, y0, z0, dx, dy, dz, h, u)
+		dd.Vertex(prev[0], prev[1], prev[2], col)
+		dd.Vertex(pt[0], pt[1], pt[2], col)
+		prev = pt
+	}
+
+	// End arrows
+	if as0 > 0.001 {
+		p := evalArc(x0, y0, z0, dx, dy, dz, h, pad)
+		q := evalArc(x0, y0, z0, dx, dy, dz, h, pad+0.05)
+		appendArrowHead(dd, p, q, as0, col)
+	}
+	if as1 > 0.001 {
+		p := evalArc(x0, y0, z0, dx, dy, dz, h, 1-pad)
+		q := evalArc(x0, y0, z0, dx, dy, dz, h, 1-(pad+0.05))
+		appendArrowHead(dd, p, q, as1, col)
+	}
+}
+
+// appendArrowHead appends the line segments of an arrow head of size s,
+// pointing at p and oriented by q.
+func appendArrowHead(dd DebugDraw, p, q [3]float32, s float32, col uint32) {
+	const eps = 0.001
+	cross := func(v1, v2 [3]float32) [3]float32 {
+		return [3]float32{
+			v1[1]*v2[2] - v1[2]*v2[1],
+			v1[2]*v2[0] - v1[0]*v2[2],
+			v1[0]*v2[1] - v1[1]*v2[0],
+		}
+	}
+	normalize := func(v *[3]float32) {
+		d := 1 / math32.Sqrt(v[0]*v[0]+v[1]*v[1]+v[2]*v[2])
+		v[0] *= d
+		v[1] *= d
+		v[2] *= d
+	}
+
+	az := [3]float32{q[0] - p[0], q[1] - p[1], q[2] - p[2]}
+	if az[0]*az[0]+az[1]*az[1]+az[2]*az[2] < eps*eps {
+		return
+	}
+	ay := [3]float32{0, 1, 0}
+	normalize(&az)
+	ax := cross(ay, az)
+	ay = cross(az, ax)
+	normalize(&ay)
+
+	dd.Vertex(p[0], p[1], p[2], col)
+	dd.Vertex(p[0]+az[0]*s+ax[0]*s/3, p[1]+az[1]*s+ax[1]*s/3, p[2]+az[2]*s+ax[2]*s/3, col)
+	dd.Vertex(p[0], p[1], p[2], col)
+	dd.Vertex(p[0]+az[0]*s-ax[0]*s/3, p[1]+az[1]*s-ax[1]*s/3, p[2]+az[2]*s-ax[2]*s/3, col)
+}
@@ -0,0 +1,266 @@
+package debugdraw
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arl/go-detour/detour"
+	"github.com/arl/go-detour/recast"
+	"github.com/arl/go-detour/sample/fixture"
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func check(t *testing.T, err error) {
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recorder is a DebugDraw checking the calls sequence and counting the
+// primitives.
+type recorder struct {
+	t      *testing.T
+	inside bool
+	prim   Primitive
+	nverts int
+	counts [4]int
+}
+
+func (r *recorder) DepthMask(state bool) {}
+
+func (r *recorder) Begin(prim Primitive, size float32) {
+	if r.inside {
+		r.t.Fatal("Begin called twice")
+	}
+	r.inside = true
+	r.prim = prim
+	r.nverts = 0
+}
+
+func (r *recorder) Vertex(x, y, z float32, color uint32) {
+	if !r.inside {
+		r.t.Fatal("Vertex called outside of Begin/End")
+	}
+	r.nverts++
+}
+
+func (r *recorder) End() {
+	if !r.inside {
+		r.t.Fatal("End called without Begin")
+	}
+	if r.nverts%r.prim.NumVerts() != 0 {
+		r.t.Errorf("%d vertices is not a multiple of %d", r.nverts, r.prim.NumVerts())
+	}
+	r.counts[r.prim] += r.nverts / r.prim.NumVerts()
+	r.inside = false
+}
+
+func loadNavMesh(t *testing.T, fname string) *detour.NavMesh {
+	f, err := os.Open(filepath.Join("..", "testdata", fname))
+	check(t, err)
+	defer f.Close()
+	mesh, err := detour.Decode(f)
+	check(t, err)
+	return mesh
+}
+
+func TestNavMesh(t *testing.T) {
+	for _, fname := range []string{"mesh2.bin", "offmeshcons.bin"} {
+		mesh := loadNavMesh(t, fname)
+
+		var r recorder
+		r.t = t
+		NavMesh(&r, mesh, DrawNavMeshOffMeshCons|DrawNavMeshColorTiles)
+		if r.counts[Tris] == 0 || r.counts[Lines] == 0 || r.counts[Points] == 0 {
+			t.Errorf("%v: want triangles, lines and points, got %v", fname, r.counts)
+		}
+
+		var polys recorder
+		polys.t = t
+		NavMeshPolysWithFlags(&polys, mesh, 0xffff, RGBA(255, 0, 0, 255))
+		if polys.counts[Tris] == 0 {
+			t.Errorf("%v: want polygons with flags", fname)
+		}
+		if polys.counts[Tris] > r.counts[Tris] {
+			t.Errorf("%v: %d triangles with flags, %d in total", fname, polys.counts[Tris], r.counts[Tris])
+		}
+	}
+}
+
+func TestBackends(t *testing.T) {
+	mesh := loadNavMesh(t, "mesh1.bin")
+
+	var buf bytes.Buffer
+	obj := NewOBJ(&buf)
+	NavMesh(obj, mesh, 0)
+	check(t, obj.Err())
+
+	// the drawn triangles are loadable as an OBJ mesh
+	var r recorder
+	r.t = t
+	NavMesh(&r, mesh, 0)
+	loader := recast.NewMeshLoaderOBJ()
+	check(t, loader.Load(bytes.NewReader(buf.Bytes())))
+	if int(loader.TriCount()) != r.counts[Tris] {
+		t.Errorf("OBJ has %d triangles, want %d", loader.TriCount(), r.counts[Tris])
+	}
+
+	// with the vertex colors
+	buf.Reset()
+	obj = NewOBJ(&buf)
+	obj.Colors = true
+	NavMesh(obj, mesh, 0)
+	check(t, obj.Err())
+	if line, _ := buf.ReadString('\n'); len(strings.Fields(line)) != 7 {
+		t.Errorf("got vertex %q, want a position and a color", line)
+	}
+
+	svg := NewSVG()
+	NavMesh(svg, mesh, 0)
+	buf.Reset()
+	n, err := svg.WriteTo(&buf)
+	check(t, err)
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "<svg") || !strings.HasSuffix(out, "</svg>\n") {
+		t.Errorf("invalid svg document")
+	}
+	if got := strings.Count(out, "<polygon"); got != r.counts[Tris] {
+		t.Errorf("SVG has %d polygons, want %d", got, r.counts[Tris])
+	}
+}
+
+func TestRecast(t *testing.T) {
+	// build the intermediate results of the donut fixture
+	var obj bytes.Buffer
+	check(t, fixture.Donut().WriteOBJ(&obj))
+	geom := recast.NewMeshLoaderOBJ()
+	check(t, geom.Load(&obj))
+
+	ctx := recast.NewBuildContext(false)
+	var cfg recast.Config
+	cfg.Cs, cfg.Ch = 0.3, 0.2
+	cfg.WalkableSlopeAngle = 45
+	cfg.WalkableHeight, cfg.WalkableClimb, cfg.WalkableRadius = 10, 4, 2
+	cfg.MaxEdgeLen, cfg.MaxSimplificationError = 40, 1.3
+	cfg.MinRegionArea, cfg.MergeRegionArea = 64, 400
+	cfg.MaxVertsPerPoly = 6
+	cfg.DetailSampleDist, cfg.DetailSampleMaxError = 1.8, 0.2
+	recast.CalcBounds(geom.Verts(), geom.VertCount(), cfg.BMin[:], cfg.BMax[:])
+	cfg.Width, cfg.Height = recast.CalcGridSize(cfg.BMin[:], cfg.BMax[:], cfg.Cs)
+
+	solid := recast.NewHeightfield(cfg.Width, cfg.Height, cfg.BMin[:], cfg.BMax[:], cfg.Cs, cfg.Ch)
+	areas := make([]uint8, geom.TriCount())
+	recast.MarkWalkableTriangles(ctx, cfg.WalkableSlopeAngle, geom.Verts(), geom.VertCount(), geom.Tris(), geom.TriCount(), areas)
+	if !recast.RasterizeTriangles(ctx, geom.Verts(), geom.VertCount(), geom.Tris(), areas, geom.TriCount(), solid, cfg.WalkableClimb) {
+		t.Fatal("couldn't rasterize triangles")
+	}
+	chf := &recast.CompactHeightfield{}
+	if !recast.BuildCompactHeightfield(ctx, cfg.WalkableHeight, cfg.WalkableClimb, solid, chf) {
+		t.Fatal("couldn't build compact heightfield")
+	}
+	if !recast.ErodeWalkableArea(ctx, cfg.WalkableRadius, chf) ||
+		!recast.BuildRegionsMonotone(ctx, chf, 0, cfg.MinRegionArea, cfg.MergeRegionArea) {
+		t.Fatal("couldn't build regions")
+	}
+	cset := &recast.ContourSet{}
+	if !recast.BuildContours(ctx, chf, cfg.MaxSimplificationError, cfg.MaxEdgeLen, cset, recast.ContourTessWallEdges) {
+		t.Fatal("couldn't build contours")
+	}
+	pmesh, ok := recast.BuildPolyMesh(ctx, cset, cfg.MaxVertsPerPoly)
+	if !ok {
+		t.Fatal("couldn't build poly mesh")
+	}
+	dmesh, ok := recast.BuildPolyMeshDetail(ctx, pmesh, chf, cfg.DetailSampleDist, cfg.DetailSampleMaxError)
+	if !ok {
+		t.Fatal("couldn't build detail mesh")
+	}
+
+	tests := []struct {
+		name string
+		draw func(dd DebugDraw)
+		prim Primitive
+		want int
+	}{
+		{"CompactHeightfield", func(dd DebugDraw) { CompactHeightfield(dd, chf) }, Quads, int(chf.SpanCount)},
+		{"CompactHeightfieldRegions", func(dd DebugDraw) { CompactHeightfieldRegions(dd, chf) }, Quads, int(chf.SpanCount)},
+		{"Contours", func(dd DebugDraw) { Contours(dd, cset, 1) }, Lines, -1},
+		{"RawContours", func(dd DebugDraw) { RawContours(dd, cset, 0.5) }, Lines, -1},
+		{"PolyMesh", func(dd DebugDraw) { PolyMesh(dd, pmesh) }, Points, int(pmesh.NVerts)},
+		{"PolyMeshDetail", func(dd DebugDraw) { PolyMeshDetail(dd, dmesh) }, Tris, int(dmesh.NTris)},
+	}
+	for _, tt := range tests {
+		r := recorder{t: t}
+		tt.draw(&r)
+		if got := r.counts[tt.prim]; got == 0 || (tt.want >= 0 && got != tt.want) {
+			t.Errorf("%v: got %d primitives, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestColors(t *testing.T) {
+	col := RGBA(10, 20, 30, 40)
+	if r, g, b, a := UnpackRGBA(col); r != 10 || g != 20 || b != 30 || a != 40 {
+		t.Errorf("UnpackRGBA(RGBA(10, 20, 30, 40)) = %d, %d, %d, %d", r, g, b, a)
+	}
+	if got := TransCol(col, 255); got != RGBA(10, 20, 30, 255) {
+		t.Errorf("TransCol = 0x%x", got)
+	}
+	if got := DarkenCol(col); got != RGBA(5, 10, 15, 40) {
+		t.Errorf("DarkenCol = 0x%x", got)
+	}
+	if got := LerpCol(RGBA(0, 0, 0, 0), RGBA(255, 255, 255, 255), 255); got != RGBA(255, 255, 255, 255) {
+		t.Errorf("LerpCol = 0x%x", got)
+	}
+	for i := 0; i < 7; i++ {
+		if IntToCol(i, 255) == IntToCol(i+1, 255) {
+			t.Errorf("IntToCol(%d) == IntToCol(%d)", i, i+1)
+		}
+	}
+}
+
+func TestRenderTopDown(t *testing.T) {
+	mesh := loadNavMesh(t, "mesh1.bin")
+	path := []d3.Vec3{{37.298489, -1.776901, 11.652311}, {42.457218, 7.797607, 17.778244}}
+
+	var buf bytes.Buffer
+	opts := RenderOptions{Format: FormatSVG, Path: path, Agents: path[:1]}
+	check(t, RenderTopDown(&buf, mesh, opts))
+	if out := buf.String(); !strings.HasPrefix(out, "<svg") || !strings.Contains(out, "<polygon") {
+		t.Errorf("invalid svg document")
+	}
+
+	buf.Reset()
+	opts.Format = FormatPNG
+	opts.Width = 256
+	check(t, RenderTopDown(&buf, mesh, opts))
+	img, err := png.Decode(&buf)
+	check(t, err)
+	if b := img.Bounds(); b.Dx() != 256 && b.Dy() != 256 {
+		t.Errorf("image size is %v, want 256 on the largest side", b.Size())
+	}
+
+	// the navmesh polygons are drawn over the white background
+	var drawn int
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if r, g, b, _ := img.At(x, y).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+				drawn++
+			}
+		}
+	}
+	if drawn < b.Dx()*b.Dy()/10 {
+		t.Errorf("only %d drawn pixels in a %v image", drawn, b.Size())
+	}
+
+	if err = RenderTopDown(&buf, mesh, RenderOptions{Format: Format(-1)}); err == nil {
+		t.Error("unknown format should fail")
+	}
+}
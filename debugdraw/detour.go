@@ -0,0 +1,288 @@
+package debugdraw
+
+import (
+	"github.com/arl/go-detour/detour"
+)
+
+// These mirror unexported detour values.
+const (
+	extLink  uint16 = 0x8000     // Neighbour flag of the edges on a tile border.
+	nullLink uint32 = 0xffffffff // End of a link list.
+
+	offMeshConnectionType uint8 = 1 // Type of the off-mesh connection polygons.
+)
+
+// NavMeshFlags are the flags of the NavMesh drawing function.
+type NavMeshFlags int
+
+// NavMesh drawing flags.
+const (
+	DrawNavMeshOffMeshCons NavMeshFlags = 1 << iota // Draw the off-mesh connections.
+	DrawNavMeshColorTiles                           // Color the polygons by tile, instead of by area.
+)
+
+// NavMesh draws the polygons, the polygon boundaries and the vertices of all
+// the tiles of a navigation mesh.
+func NavMesh(dd DebugDraw, mesh *detour.NavMesh, flags NavMeshFlags) {
+	for i := range mesh.Tiles {
+		tile := &mesh.Tiles[i]
+		if tile.Header == nil {
+			continue
+		}
+		drawMeshTile(dd, mesh, tile, flags)
+	}
+}
+
+// NavMeshPolysWithFlags draws, with color col, the polygons of a navigation
+// mesh having at least one of polyFlags.
+func NavMeshPolysWithFlags(dd DebugDraw, mesh *detour.NavMesh, polyFlags uint16, col uint32) {
+	for i := range mesh.Tiles {
+		tile := &mesh.Tiles[i]
+		if tile.Header == nil {
+			continue
+		}
+		base := detour.PolyRef(mesh.TileRef(tile))
+		for j := int32(0); j < tile.Header.PolyCount; j++ {
+			if tile.Polys[j].Flags&polyFlags == 0 {
+				continue
+			}
+			NavMeshPoly(dd, mesh, base|detour.PolyRef(j), col)
+		}
+	}
+}
+
+// NavMeshPoly draws a polygon of a navigation mesh with color col.
+func NavMeshPoly(dd DebugDraw, mesh *detour.NavMesh, ref detour.PolyRef, col uint32) {
+	var (
+		tile     *detour.MeshTile
+		poly     *detour.Poly
+		salt, it uint32
+		ip       uint32
+	)
+	if detour.StatusFailed(mesh.TileAndPolyByRef(ref, &tile, &poly)) {
+		return
+	}
+	mesh.DecodePolyID(ref, &salt, &it, &ip)
+
+	dd.DepthMask(false)
+	c := TransCol(col, 64)
+
+	if poly.Type() == offMeshConnectionType {
+		con := &tile.OffMeshCons[int32(ip)-tile.Header.OffMeshBase]
+		dd.Begin(Lines, 2)
+		// Connection arc.
+		as0 := float32(0)
+		if con.Flags&1 != 0 {
+			as0 = 0.6
+		}
+		appendArc(dd, con.Pos[0], con.Pos[1], con.Pos[2], con.Pos[3], con.Pos[4], con.Pos[5], 0.25, as0, 0.6, c)
+		dd.End()
+	} else {
+		dd.Begin(Tris, 1)
+		drawPolyTris(dd, tile, ip, c)
+		dd.End()
+	}
+
+	dd.DepthMask(true)
+}
+
+// drawPolyTris draws the detail triangles of a ground polygon.
+func drawPolyTris(dd DebugDraw, tile *detour.MeshTile, ip uint32, col uint32) {
+	poly := &tile.Polys[ip]
+	pd := &tile.DetailMeshes[ip]
+	for j := uint32(0); j < uint32(pd.TriCount); j++ {
+		t := tile.DetailTris[(pd.TriBase+j)*4:]
+		for k := 0; k < 3; k++ {
+			v := detailVertex(tile, poly, pd, t[k])
+			dd.Vertex(v[0], v[1], v[2], col)
+		}
+	}
+}
+
+// detailVertex returns the vertex i of the detail mesh of a polygon.
+func detailVertex(tile *detour.MeshTile, poly *detour.Poly, pd *detour.PolyDetail, i uint8) []float32 {
+	if i < poly.VertCount {
+		return tile.Verts[uint32(poly.Verts[i])*3:]
+	}
+	return tile.DetailVerts[(pd.VertBase+uint32(i-poly.VertCount))*3:]
+}
+
+// drawMeshTile draws a navigation mesh tile.
+func drawMeshTile(dd DebugDraw, mesh *detour.NavMesh, tile *detour.MeshTile, flags NavMeshFlags) {
+	var salt, it, ip uint32
+	base := detour.PolyRef(mesh.TileRef(tile))
+	mesh.DecodePolyID(base, &salt, &it, &ip)
+	tileColor := IntToCol(int(it), 128)
+
+	dd.DepthMask(false)
+
+	dd.Begin(Tris, 1)
+	for i := int32(0); i < tile.Header.PolyCount; i++ {
+		poly := &tile.Polys[i]
+		if poly.Type() == offMeshConnectionType {
+			// Skip off-mesh links.
+			continue
+		}
+
+		col := TransCol(AreaToCol(dd, poly.Area()), 64)
+		if flags&DrawNavMeshColorTiles != 0 {
+			col = tileColor
+		}
+		drawPolyTris(dd, tile, uint32(i), col)
+	}
+	dd.End()
+
+	// Draw inter poly boundaries
+	drawPolyBoundaries(dd, tile, RGBA(0, 48, 64, 32), 1.5, true)
+
+	// Draw outer poly boundaries
+	drawPolyBoundaries(dd, tile, RGBA(0, 48, 64, 220), 2.5, false)
+
+	if flags&DrawNavMeshOffMeshCons != 0 {
+		dd.Begin(Lines, 2)
+		for i := int32(0); i < tile.Header.PolyCount; i++ {
+			poly := &tile.Polys[i]
+			if poly.Type() != offMeshConnectionType {
+				// Skip regular polys.
+				continue
+			}
+
+			col := DarkenCol(TransCol(AreaToCol(dd, poly.Area()), 220))
+			con := &tile.OffMeshCons[i-tile.Header.OffMeshBase]
+			va := tile.Verts[uint32(poly.Verts[0])*3:]
+			vb := tile.Verts[uint32(poly.Verts[1])*3:]
+
+			// Check to see if start and end end-points have links.
+			var startSet, endSet bool
+			for k := poly.FirstLink; k != nullLink; k = tile.Links[k].Next {
+				switch tile.Links[k].Edge {
+				case 0:
+					startSet = true
+				case 1:
+					endSet = true
+				}
+			}
+
+			// End points and their on-mesh locations.
+			dd.Vertex(va[0], va[1], va[2], col)
+			dd.Vertex(con.Pos[0], con.Pos[1], con.Pos[2], col)
+			col2 := RGBA(220, 32, 16, 196)
+			if startSet {
+				col2 = col
+			}
+			appendCircle(dd, con.Pos[0], con.Pos[1]+0.1, con.Pos[2], con.Rad, col2)
+
+			dd.Vertex(vb[0], vb[1], vb[2], col)
+			dd.Vertex(con.Pos[3], con.Pos[4], con.Pos[5], col)
+			col2 = RGBA(220, 32, 16, 196)
+			if endSet {
+				col2 = col
+			}
+			appendCircle(dd, con.Pos[3], con.Pos[4]+0.1, con.Pos[5], con.Rad, col2)
+
+			// End point vertices.
+			vcol := RGBA(0, 48, 64, 196)
+			dd.Vertex(con.Pos[0], con.Pos[1], con.Pos[2], vcol)
+			dd.Vertex(con.Pos[0], con.Pos[1]+0.2, con.Pos[2], vcol)
+			dd.Vertex(con.Pos[3], con.Pos[4], con.Pos[5], vcol)
+			dd.Vertex(con.Pos[3], con.Pos[4]+0.2, con.Pos[5], vcol)
+
+			// Connection arc.
+			as0 := float32(0)
+			if con.Flags&1 != 0 {
+				as0 = 0.6
+			}
+			appendArc(dd, con.Pos[0], con.Pos[1], con.Pos[2], con.Pos[3], con.Pos[4], con.Pos[5], 0.25, as0, 0.6, col)
+		}
+		dd.End()
+	}
+
+	vcol := RGBA(0, 0, 0, 196)
+	dd.Begin(Points, 3)
+	for i := int32(0); i < tile.Header.VertCount; i++ {
+		v := tile.Verts[i*3:]
+		dd.Vertex(v[0], v[1], v[2], vcol)
+	}
+	dd.End()
+
+	dd.DepthMask(true)
+}
+
+// drawPolyBoundaries draws the edges of the polygons of a tile, either the
+// edges between polygons (inner) or the outer edges.
+func drawPolyBoundaries(dd DebugDraw, tile *detour.MeshTile, col uint32, linew float32, inner bool) {
+	const thr = 0.01 * 0.01
+
+	dd.Begin(Lines, linew)
+	for i := int32(0); i < tile.Header.PolyCount; i++ {
+		poly := &tile.Polys[i]
+		if poly.Type() == offMeshConnectionType {
+			continue
+		}
+		pd := &tile.DetailMeshes[i]
+
+		for j, nj := uint8(0), poly.VertCount; j < nj; j++ {
+			c := col
+			if inner {
+				if poly.Neis[j] == 0 {
+					continue
+				}
+				if poly.Neis[j]&extLink != 0 {
+					con := false
+					for k := poly.FirstLink; k != nullLink; k = tile.Links[k].Next {
+						if tile.Links[k].Edge == j {
+							con = true
+							break
+						}
+					}
+					if con {
+						c = RGBA(255, 255, 255, 48)
+					} else {
+						c = RGBA(0, 0, 0, 48)
+					}
+				} else {
+					c = RGBA(0, 48, 64, 32)
+				}
+			} else if poly.Neis[j] != 0 {
+				continue
+			}
+
+			v0 := tile.Verts[uint32(poly.Verts[j])*3:]
+			v1 := tile.Verts[uint32(poly.Verts[(j+1)%nj])*3:]
+
+			// Draw detail mesh edges which align with the actual poly edge.
+			// This is really slow.
+			for k := uint32(0); k < uint32(pd.TriCount); k++ {
+				t := tile.DetailTris[(pd.TriBase+k)*4:]
+				var tv [3][]float32
+				for m := 0; m < 3; m++ {
+					tv[m] = detailVertex(tile, poly, pd, t[m])
+				}
+				for m, n := 0, 2; m < 3; n, m = m, m+1 {
+					if distancePtLine2D(tv[n], v0, v1) < thr && distancePtLine2D(tv[m], v0, v1) < thr {
+						dd.Vertex(tv[n][0], tv[n][1], tv[n][2], c)
+						dd.Vertex(tv[m][0], tv[m][1], tv[m][2], c)
+					}
+				}
+			}
+		}
+	}
+	dd.End()
+}
+
+// distancePtLine2D returns the squared distance, in the xz-plane, between pt
+// and the line (p, q).
+func distancePtLine2D(pt, p, q []float32) float32 {
+	pqx := q[0] - p[0]
+	pqz := q[2] - p[2]
+	dx := pt[0] - p[0]
+	dz := pt[2] - p[2]
+	d := pqx*pqx + pqz*pqz
+	t := pqx*dx + pqz*dz
+	if d != 0 {
+		t /= d
+	}
+	dx = p[0] + t*pqx - pt[0]
+	dz = p[2] + t*pqz - pt[2]
+	return dx*dx + dz*dz
+}
@@ -0,0 +1,83 @@
+package debugdraw
+
+import (
+	"fmt"
+	"io"
+)
+
+// OBJ is a DebugDraw writing the drawn primitives in Wavefront OBJ format.
+//
+// Triangles and quads are written as faces, lines as line elements and points
+// as point elements. Colors, alpha and sizes are ignored, unless Colors is set.
+type OBJ struct {
+	// Colors enables writing the vertex colors after the vertex positions
+	// (v x y z r g b), an extension supported by most viewers but not by
+	// recast.MeshLoaderOBJ.
+	Colors bool
+
+	w      io.Writer
+	err    error
+	prim   Primitive
+	nverts int // Number of vertices written so far.
+	npend  int // Number of vertices of the current primitive.
+}
+
+// NewOBJ returns an OBJ debug draw writing to w.
+func NewOBJ(w io.Writer) *OBJ {
+	return &OBJ{w: w}
+}
+
+// DepthMask does nothing.
+func (o *OBJ) DepthMask(state bool) {}
+
+// Begin starts drawing primitives of type prim.
+func (o *OBJ) Begin(prim Primitive, size float32) {
+	o.prim = prim
+	o.npend = 0
+}
+
+// Vertex writes a vertex, and the element it completes if any.
+func (o *OBJ) Vertex(x, y, z float32, color uint32) {
+	if o.err != nil {
+		return
+	}
+	if o.Colors {
+		r, g, b, _ := UnpackRGBA(color)
+		_, o.err = fmt.Fprintf(o.w, "v %f %f %f %.3f %.3f %.3f\n",
+			x, y, z, float32(r)/255, float32(g)/255, float32(b)/255)
+	} else {
+		_, o.err = fmt.Fprintf(o.w, "v %f %f %f\n", x, y, z)
+	}
+	o.nverts++
+	o.npend++
+	if o.err != nil || o.npend < o.prim.NumVerts() {
+		return
+	}
+
+	var elem string
+	switch o.prim {
+	case Points:
+		elem = "p"
+	case Lines:
+		elem = "l"
+	default:
+		elem = "f"
+	}
+	// OBJ indices are 1-based
+	for i := o.nverts - o.npend + 1; i <= o.nverts; i++ {
+		elem += fmt.Sprintf(" %d", i)
+	}
+	_, o.err = fmt.Fprintln(o.w, elem)
+	o.npend = 0
+}
+
+// End ends drawing primitives, the vertices of an incomplete primitive are
+// left unused.
+func (o *OBJ) End() {
+	o.npend = 0
+}
+
+// Err returns the first error that occurred while writing, if any.
+func (o *OBJ) Err() error {
+	return o.err
+}
@@ -0,0 +1,138 @@
+package debugdraw
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/arl/math32"
+)
+
+// PNG is a DebugDraw rasterizing the primitives as seen from above, into a
+// PNG image.
+//
+// The x-axis of the world is the x-axis of the image, the z-axis of the world
+// is its y-axis, heights are ignored. The primitives are buffered, they're
+// rasterized by Image or WriteTo once everything is drawn, in order, on a
+// white background.
+type PNG struct {
+	topDown
+}
+
+// NewPNG returns an empty PNG debug draw.
+func NewPNG() *PNG {
+	return &PNG{newTopDown()}
+}
+
+// Image rasterizes the drawn primitives and returns the image.
+func (p *PNG) Image() *image.RGBA {
+	bmin, ext, scale := p.view()
+	w := int(math32.Max(math32.Ceil(ext[0]*scale), 1))
+	h := int(math32.Max(math32.Ceil(ext[1]*scale), 1))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	// pixel coordinates of the vertex v
+	px := func(v topDownVertex) [2]float32 {
+		return [2]float32{(v.x - bmin[0]) * scale, (v.z - bmin[1]) * scale}
+	}
+	for _, e := range p.elems {
+		col := e.verts[0].color
+		switch e.prim {
+		case Points:
+			fillDisc(img, px(e.verts[0]), math32.Max(e.size/2, 0.5), col)
+		case Lines:
+			a, b := px(e.verts[0]), px(e.verts[1])
+			dx, dy := b[0]-a[0], b[1]-a[1]
+			d := math32.Sqrt(dx*dx + dy*dy)
+			if d < 1e-6 {
+				continue
+			}
+			// the line is drawn as a quad
+			hw := math32.Max(e.size, 1) / 2
+			nx, ny := -dy/d*hw, dx/d*hw
+			fillConvex(img, [][2]float32{
+				{a[0] + nx, a[1] + ny},
+				{b[0] + nx, b[1] + ny},
+				{b[0] - nx, b[1] - ny},
+				{a[0] - nx, a[1] - ny},
+			}, col)
+		default:
+			pts := make([][2]float32, len(e.verts))
+			for i, v := range e.verts {
+				pts[i] = px(v)
+			}
+			fillConvex(img, pts, col)
+		}
+	}
+	return img
+}
+
+// WriteTo rasterizes the drawn primitives and writes the PNG image to w.
+func (p *PNG) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	err := png.Encode(cw, p.Image())
+	return cw.n, err
+}
+
+// blend blends col over the pixel (x, y) of img.
+func blend(img *image.RGBA, x, y int, col uint32) {
+	r, g, b, a := UnpackRGBA(col)
+	dst := img.RGBAAt(x, y)
+	mix := func(s, d uint8) uint8 {
+		return uint8((uint32(s)*uint32(a) + uint32(d)*(255-uint32(a))) / 255)
+	}
+	img.SetRGBA(x, y, color.RGBA{
+		R: mix(r, dst.R),
+		G: mix(g, dst.G),
+		B: mix(b, dst.B),
+		A: uint8(uint32(a) + uint32(dst.A)*(255-uint32(a))/255),
+	})
+}
+
+// fillConvex fills the convex polygon pts, with any winding. A pixel is
+// filled if its center is inside the polygon.
+func fillConvex(img *image.RGBA, pts [][2]float32, col uint32) {
+	bmin := [2]float32{math.MaxFloat32, math.MaxFloat32}
+	bmax := [2]float32{-math.MaxFloat32, -math.MaxFloat32}
+	for _, p := range pts {
+		bmin[0], bmin[1] = math32.Min(bmin[0], p[0]), math32.Min(bmin[1], p[1])
+		bmax[0], bmax[1] = math32.Max(bmax[0], p[0]), math32.Max(bmax[1], p[1])
+	}
+	r := image.Rect(int(bmin[0]), int(bmin[1]), int(bmax[0])+1, int(bmax[1])+1).Intersect(img.Bounds())
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cx, cy := float32(x)+0.5, float32(y)+0.5
+			var pos, neg bool
+			for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+				e := (pts[i][0]-pts[j][0])*(cy-pts[j][1]) - (pts[i][1]-pts[j][1])*(cx-pts[j][0])
+				if e > 0 {
+					pos = true
+				} else if e < 0 {
+					neg = true
+				}
+			}
+			if !(pos && neg) {
+				blend(img, x, y, col)
+			}
+		}
+	}
+}
+
+// fillDisc fills the disc of center c and radius rad.
+func fillDisc(img *image.RGBA, c [2]float32, rad float32, col uint32) {
+	r := image.Rect(int(c[0]-rad), int(c[1]-rad), int(c[0]+rad)+1, int(c[1]+rad)+1).Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dx, dy := float32(x)+0.5-c[0], float32(y)+0.5-c[1]
+			if dx*dx+dy*dy <= rad*rad {
+				blend(img, x, y, col)
+			}
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package debugdraw
+
+import (
+	"github.com/arl/go-detour/recast"
+)
+
+// These mirror unexported recast values.
+const (
+	nullArea     uint8  = 0       // The null area id.
+	meshNullIdx  uint16 = 0xffff  // Invalid index in a poly mesh.
+	borderVertex int32  = 0x10000 // Contour vertex flag of the tile borders.
+)
+
+// spanColor returns the color of a span of the given area.
+func spanColor(dd DebugDraw, area uint8) uint32 {
+	switch area {
+	case recast.WalkableArea:
+		return RGBA(0, 192, 255, 64)
+	case nullArea:
+		return RGBA(0, 0, 0, 64)
+	}
+	return AreaToCol(dd, area)
+}
+
+// CompactHeightfield draws the top of the spans of a compact heightfield,
+// colored by area.
+func CompactHeightfield(dd DebugDraw, chf *recast.CompactHeightfield) {
+	drawCompactHeightfield(dd, chf, func(i uint32) uint32 {
+		return spanColor(dd, chf.Areas[i])
+	})
+}
+
+// CompactHeightfieldRegions draws the top of the spans of a compact
+// heightfield, colored by region.
+func CompactHeightfieldRegions(dd DebugDraw, chf *recast.CompactHeightfield) {
+	drawCompactHeightfield(dd, chf, func(i uint32) uint32 {
+		if reg := chf.Spans[i].Reg; reg != 0 {
+			return IntToCol(int(reg), 192)
+		}
+		return RGBA(0, 0, 0, 64)
+	})
+}
+
+func drawCompactHeightfield(dd DebugDraw, chf *recast.CompactHeightfield, color func(i uint32) uint32) {
+	cs := chf.Cs
+	ch := chf.Ch
+
+	dd.Begin(Quads, 1)
+	for y := int32(0); y < chf.Height; y++ {
+		for x := int32(0); x < chf.Width; x++ {
+			fx := chf.BMin[0] + float32(x)*cs
+			fz := chf.BMin[2] + float32(y)*cs
+			c := &chf.Cells[x+y*chf.Width]
+
+			for i, ni := c.Index, c.Index+uint32(c.Count); i < ni; i++ {
+				s := &chf.Spans[i]
+				col := color(i)
+				fy := chf.BMin[1] + float32(s.Y+1)*ch
+				dd.Vertex(fx, fy, fz, col)
+				dd.Vertex(fx, fy, fz+cs, col)
+				dd.Vertex(fx+cs, fy, fz+cs, col)
+				dd.Vertex(fx+cs, fy, fz, col)
+			}
+		}
+	}
+	dd.End()
+}
+
+// Contours draws the simplified contours of a contour set, colored by
+// region. alpha is the opacity, in [0, 1].
+func Contours(dd DebugDraw, cset *recast.ContourSet, alpha float32) {
+	drawContours(dd, cset, alpha, false)
+}
+
+// RawContours draws the raw contours of a contour set, colored by region.
+// alpha is the opacity, in [0, 1].
+func RawContours(dd DebugDraw, cset *recast.ContourSet, alpha float32) {
+	drawContours(dd, cset, alpha, true)
+}
+
+func drawContours(dd DebugDraw, cset *recast.ContourSet, alpha float32, raw bool) {
+	orig := cset.BMin
+	cs := cset.Cs
+	ch := cset.Ch
+
+	a := uint8(255)
+	if alpha < 1 {
+		a = uint8(alpha * 255)
+	}
+
+	verts := func(c *recast.Contour) ([]int32, int32) {
+		if raw {
+			return c.RVerts, c.NRVerts
+		}
+		return c.Verts, c.NVerts
+	}
+	pos := func(v []int32, i int, off float32) (x, y, z float32) {
+		return orig[0] + float32(v[0])*cs,
+			orig[1] + float32(v[1]+1+int32(i&1))*ch + off,
+			orig[2] + float32(v[2])*cs
+	}
+
+	dd.Begin(Lines, 2.5)
+	for i := int32(0); i < cset.NConts; i++ {
+		c := &cset.Conts[i]
+		cverts, nverts := verts(c)
+		if nverts == 0 {
+			continue
+		}
+		color := IntToCol(int(c.Reg), a)
+		bcolor := LerpCol(color, RGBA(255, 255, 255, a), 128)
+		for j, k := int32(0), nverts-1; j < nverts; k, j = j, j+1 {
+			va := cverts[k*4:]
+			vb := cverts[j*4:]
+			col := color
+			if !raw && va[3]&borderVertex != 0 {
+				col = bcolor
+			}
+			x, y, z := pos(va, int(i), 0)
+			dd.Vertex(x, y, z, col)
+			x, y, z = pos(vb, int(i), 0)
+			dd.Vertex(x, y, z, col)
+		}
+	}
+	dd.End()
+
+	dd.Begin(Points, 3)
+	for i := int32(0); i < cset.NConts; i++ {
+		c := &cset.Conts[i]
+		cverts, nverts := verts(c)
+		color := DarkenCol(IntToCol(int(c.Reg), a))
+		for j := int32(0); j < nverts; j++ {
+			v := cverts[j*4:]
+			off := float32(0)
+			colv := color
+			if v[3]&borderVertex != 0 {
+				colv = RGBA(255, 255, 255, a)
+				off = ch * 2
+			}
+			x, y, z := pos(v, int(i), off)
+			dd.Vertex(x, y, z, colv)
+		}
+	}
+	dd.End()
+}
+
+// PolyMesh draws the polygons of a poly mesh colored by area, their edges and
+// their vertices.
+func PolyMesh(dd DebugDraw, mesh *recast.PolyMesh) {
+	nvp := mesh.Nvp
+	cs := mesh.Cs
+	ch := mesh.Ch
+	orig := mesh.BMin
+
+	vertex := func(vi uint16, off float32, col uint32) {
+		v := mesh.Verts[int(vi)*3:]
+		x := orig[0] + float32(v[0])*cs
+		y := orig[1] + float32(v[1]+1)*ch + off
+		z := orig[2] + float32(v[2])*cs
+		dd.Vertex(x, y, z, col)
+	}
+	// next returns the index of the vertex following j in polygon p.
+	next := func(p []uint16, j int32) int32 {
+		if j+1 >= nvp || p[j+1] == meshNullIdx {
+			return 0
+		}
+		return j + 1
+	}
+
+	dd.Begin(Tris, 1)
+	for i := int32(0); i < mesh.NPolys; i++ {
+		p := mesh.Polys[i*nvp*2:]
+		color := spanColor(dd, mesh.Areas[i])
+		for j := int32(2); j < nvp; j++ {
+			if p[j] == meshNullIdx {
+				break
+			}
+			vertex(p[0], 0, color)
+			vertex(p[j-1], 0, color)
+			vertex(p[j], 0, color)
+		}
+	}
+	dd.End()
+
+	// Draw neighbours edges
+	coln := RGBA(0, 48, 64, 32)
+	dd.Begin(Lines, 1.5)
+	for i := int32(0); i < mesh.NPolys; i++ {
+		p := mesh.Polys[i*nvp*2:]
+		for j := int32(0); j < nvp; j++ {
+			if p[j] == meshNullIdx {
+				break
+			}
+			if p[nvp+j]&0x8000 != 0 {
+				continue
+			}
+			vertex(p[j], 0, coln)
+			vertex(p[next(p, j)], 0, coln)
+		}
+	}
+	dd.End()
+
+	// Draw boundary edges
+	colb := RGBA(0, 48, 64, 220)
+	dd.Begin(Lines, 2.5)
+	for i := int32(0); i < mesh.NPolys; i++ {
+		p := mesh.Polys[i*nvp*2:]
+		for j := int32(0); j < nvp; j++ {
+			if p[j] == meshNullIdx {
+				break
+			}
+			if p[nvp+j]&0x8000 == 0 {
+				continue
+			}
+			col := colb
+			if p[nvp+j]&0xf != 0xf {
+				// Portal to a neighbour tile.
+				col = RGBA(255, 255, 255, 128)
+			}
+			vertex(p[j], 0, col)
+			vertex(p[next(p, j)], 0, col)
+		}
+	}
+	dd.End()
+
+	colv := RGBA(0, 0, 0, 220)
+	dd.Begin(Points, 3)
+	for i := int32(0); i < mesh.NVerts; i++ {
+		vertex(uint16(i), 0.1, colv)
+	}
+	dd.End()
+}
+
+// PolyMeshDetail draws the triangles of a detail mesh colored by sub-mesh,
+// their edges and their vertices.
+func PolyMeshDetail(dd DebugDraw, dmesh *recast.PolyMeshDetail) {
+	// subMesh returns the vertices and the triangles of the sub-mesh i.
+	subMesh := func(i int32) (verts []float32, nverts int32, tris []uint8, ntris int32) {
+		m := dmesh.Meshes[i*4:]
+		return dmesh.Verts[m[0]*3:], m[1], dmesh.Tris[m[2]*4:], m[3]
+	}
+	vertex := func(verts []float32, vi int, col uint32) {
+		v := verts[vi*3:]
+		dd.Vertex(v[0], v[1], v[2], col)
+	}
+
+	dd.Begin(Tris, 1)
+	for i := int32(0); i < dmesh.NMeshes; i++ {
+		verts, _, tris, ntris := subMesh(i)
+		color := IntToCol(int(i), 192)
+		for j := int32(0); j < ntris; j++ {
+			t := tris[j*4:]
+			vertex(verts, int(t[0]), color)
+			vertex(verts, int(t[1]), color)
+			vertex(verts, int(t[2]), color)
+		}
+	}
+	dd.End()
+
+	// Internal edges, drawn once.
+	coli := RGBA(0, 0, 0, 64)
+	dd.Begin(Lines, 1)
+	for i := int32(0); i < dmesh.NMeshes; i++ {
+		verts, _, tris, ntris := subMesh(i)
+		for j := int32(0); j < ntris; j++ {
+			t := tris[j*4:]
+			for k, kp := 0, 2; k < 3; kp, k = k, k+1 {
+				ef := (t[3] >> uint(kp*2)) & 0x3
+				if ef == 0 && t[kp] < t[k] {
+					vertex(verts, int(t[kp]), coli)
+					vertex(verts, int(t[k]), coli)
+				}
+			}
+		}
+	}
+	dd.End()
+
+	// External edges.
+	cole := RGBA(0, 0, 0, 64)
+	dd.Begin(Lines, 2)
+	for i := int32(0); i < dmesh.NMeshes; i++ {
+		verts, _, tris, ntris := subMesh(i)
+		for j := int32(0); j < ntris; j++ {
+			t := tris[j*4:]
+			for k, kp := 0, 2; k < 3; kp, k = k, k+1 {
+				if ef := (t[3] >> uint(kp*2)) & 0x3; ef != 0 {
+					vertex(verts, int(t[kp]), cole)
+					vertex(verts, int(t[k]), cole)
+				}
+			}
+		}
+	}
+	dd.End()
+
+	colv := RGBA(0, 0, 0, 64)
+	dd.Begin(Points, 3)
+	for i := int32(0); i < dmesh.NMeshes; i++ {
+		verts, nverts, _, _ := subMesh(i)
+		for j := int32(0); j < nverts; j++ {
+			vertex(verts, int(j), colv)
+		}
+	}
+	dd.End()
+}
@@ -0,0 +1,107 @@
+package debugdraw
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/arl/go-detour/detour"
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// Format is the image format of RenderTopDown.
+type Format int
+
+// Image formats.
+const (
+	FormatSVG Format = iota // Scalable Vector Graphics.
+	FormatPNG               // Portable Network Graphics.
+)
+
+// RenderOptions are the options of RenderTopDown.
+type RenderOptions struct {
+	// Format is the image format.
+	Format Format
+
+	// Width is the width, in pixels, of the largest side of the image.
+	// Defaults to 1024.
+	Width float32
+
+	// Flags are the navigation mesh drawing flags.
+	Flags NavMeshFlags
+
+	// Path is a path to draw over the navigation mesh, usually the points
+	// of a straight path.
+	Path []d3.Vec3
+
+	// Agents are agent positions to draw over the navigation mesh.
+	Agents []d3.Vec3
+
+	// AgentRadius is the radius of the agents. Defaults to 0.6.
+	AgentRadius float32
+}
+
+// RenderTopDown draws the navigation mesh and, optionally, a path and agent
+// positions, as seen from above, and writes the image to w.
+//
+// This is a shortcut to draw a navigation mesh on the SVG or PNG backends,
+// useful to attach a picture to a bug report, or to look at a navigation mesh
+// without a 3D viewer.
+func RenderTopDown(w io.Writer, m *detour.NavMesh, opts RenderOptions) error {
+	var (
+		dd  DebugDraw
+		td  *topDown
+		out io.WriterTo
+	)
+	switch opts.Format {
+	case FormatSVG:
+		svg := NewSVG()
+		dd, td, out = svg, &svg.topDown, svg
+	case FormatPNG:
+		png := NewPNG()
+		dd, td, out = png, &png.topDown, png
+	default:
+		return fmt.Errorf("unknown image format %d", opts.Format)
+	}
+	if opts.Width > 0 {
+		td.Width = opts.Width
+	}
+
+	NavMesh(dd, m, opts.Flags)
+
+	if len(opts.Path) > 0 {
+		col := RGBA(255, 64, 16, 220)
+		dd.Begin(Lines, 3)
+		for i := 1; i < len(opts.Path); i++ {
+			p, q := opts.Path[i-1], opts.Path[i]
+			dd.Vertex(p[0], p[1], p[2], col)
+			dd.Vertex(q[0], q[1], q[2], col)
+		}
+		dd.End()
+		dd.Begin(Points, 6)
+		for _, p := range opts.Path {
+			dd.Vertex(p[0], p[1], p[2], col)
+		}
+		dd.End()
+	}
+
+	if len(opts.Agents) > 0 {
+		rad := opts.AgentRadius
+		if rad <= 0 {
+			rad = 0.6
+		}
+		col := RGBA(0, 0, 0, 220)
+		dd.Begin(Lines, 2)
+		for _, p := range opts.Agents {
+			appendCircle(dd, p[0], p[1], p[2], rad, col)
+		}
+		dd.End()
+		dd.Begin(Points, 4)
+		for _, p := range opts.Agents {
+			dd.Vertex(p[0], p[1], p[2], col)
+		}
+		dd.End()
+	}
+
+	_, err := out.WriteTo(w)
+	return err
+}
@@ -0,0 +1,80 @@
+package debugdraw
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/arl/math32"
+)
+
+// SVG is a DebugDraw drawing the primitives as seen from above, in SVG
+// format.
+//
+// The x-axis of the world is the x-axis of the image, the z-axis of the world
+// is its y-axis, heights are ignored. The primitives are buffered, they're
+// written with WriteTo once everything is drawn.
+type SVG struct {
+	topDown
+}
+
+// NewSVG returns an empty SVG debug draw.
+func NewSVG() *SVG {
+	return &SVG{newTopDown()}
+}
+
+// WriteTo writes the SVG image to w.
+func (s *SVG) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: bufio.NewWriter(w)}
+
+	bmin, ext, scale := s.view()
+	fmt.Fprintf(cw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="%f %f %f %f">`+"\n",
+		int(math32.Ceil(ext[0]*scale)), int(math32.Ceil(ext[1]*scale)), bmin[0], bmin[1], ext[0], ext[1])
+	for _, e := range s.elems {
+		r, g, b, a := UnpackRGBA(e.verts[0].color)
+		col := fmt.Sprintf(`rgb(%d,%d,%d)`, r, g, b)
+		op := float32(a) / 255
+		switch e.prim {
+		case Points:
+			v := e.verts[0]
+			fmt.Fprintf(cw, `<circle cx="%f" cy="%f" r="%f" fill="%s" fill-opacity="%.3f"/>`+"\n",
+				v.x, v.z, e.size/2/scale, col, op)
+		case Lines:
+			v0, v1 := e.verts[0], e.verts[1]
+			fmt.Fprintf(cw, `<line x1="%f" y1="%f" x2="%f" y2="%f" stroke="%s" stroke-opacity="%.3f" stroke-width="%f"/>`+"\n",
+				v0.x, v0.z, v1.x, v1.z, col, op, e.size/scale)
+		default:
+			fmt.Fprint(cw, `<polygon points="`)
+			for i, v := range e.verts {
+				if i > 0 {
+					fmt.Fprint(cw, " ")
+				}
+				fmt.Fprintf(cw, "%f,%f", v.x, v.z)
+			}
+			fmt.Fprintf(cw, `" fill="%s" fill-opacity="%.3f"/>`+"\n", col, op)
+		}
+	}
+	fmt.Fprintln(cw, "</svg>")
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.(*bufio.Writer).Flush()
+}
+
+// countWriter counts the bytes written to w, and keeps the first error.
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,85 @@
+package debugdraw
+
+import (
+	"math"
+
+	"github.com/arl/math32"
+)
+
+// topDown buffers the primitives drawn on a DebugDraw as seen from above, and
+// their bounds. It is the common part of the SVG and PNG backends.
+//
+// The x-axis of the world is the x-axis of the image, the z-axis of the world
+// is its y-axis, heights are ignored.
+type topDown struct {
+	// Width is the width, in pixels, of the largest side of the image.
+	// Defaults to 1024.
+	Width float32
+
+	elems      []topDownElem
+	prim       Primitive
+	size       float32
+	pend       []topDownVertex
+	bmin, bmax [2]float32
+}
+
+type topDownVertex struct {
+	x, z  float32
+	color uint32
+}
+
+type topDownElem struct {
+	prim  Primitive
+	size  float32
+	verts []topDownVertex
+}
+
+func newTopDown() topDown {
+	return topDown{
+		Width: 1024,
+		bmin:  [2]float32{math.MaxFloat32, math.MaxFloat32},
+		bmax:  [2]float32{-math.MaxFloat32, -math.MaxFloat32},
+	}
+}
+
+// DepthMask does nothing, primitives are drawn in order.
+func (td *topDown) DepthMask(state bool) {}
+
+// Begin starts drawing primitives of type prim.
+func (td *topDown) Begin(prim Primitive, size float32) {
+	td.prim = prim
+	td.size = size
+	td.pend = td.pend[:0]
+}
+
+// Vertex adds a vertex to the current primitive.
+func (td *topDown) Vertex(x, y, z float32, color uint32) {
+	td.pend = append(td.pend, topDownVertex{x, z, color})
+	td.bmin[0] = math32.Min(td.bmin[0], x)
+	td.bmin[1] = math32.Min(td.bmin[1], z)
+	td.bmax[0] = math32.Max(td.bmax[0], x)
+	td.bmax[1] = math32.Max(td.bmax[1], z)
+	if len(td.pend) == td.prim.NumVerts() {
+		verts := make([]topDownVertex, len(td.pend))
+		copy(verts, td.pend)
+		td.elems = append(td.elems, topDownElem{td.prim, td.size, verts})
+		td.pend = td.pend[:0]
+	}
+}
+
+// End ends drawing primitives.
+func (td *topDown) End() {
+	td.pend = td.pend[:0]
+}
+
+// view returns the world bounds of the drawn primitives, their extents and
+// the number of pixels per world unit.
+func (td *topDown) view() (bmin, ext [2]float32, scale float32) {
+	bmin = td.bmin
+	ext = [2]float32{td.bmax[0] - td.bmin[0], td.bmax[1] - td.bmin[1]}
+	if len(td.elems) == 0 {
+		bmin, ext = [2]float32{}, [2]float32{1, 1}
+	}
+	scale = td.Width / math32.Max(math32.Max(ext[0], ext[1]), 1e-6)
+	return bmin, ext, scale
+}
@@ -0,0 +1,137 @@
+package detour
+
+import (
+	"sync"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// DefaultBatchMaxPath is the default maximum number of polygons of the paths
+// found by NavMeshQuery.FindPaths.
+const DefaultBatchMaxPath = 256
+
+// PathRequest is a path query of NavMeshQuery.FindPaths. Its fields are the
+// arguments of FindPath.
+type PathRequest struct {
+	StartRef PolyRef     // The reference id of the start polygon.
+	EndRef   PolyRef     // The reference id of the end polygon.
+	StartPos d3.Vec3     // A position within the start polygon. [(x, y, z)]
+	EndPos   d3.Vec3     // A position within the end polygon. [(x, y, z)]
+	Filter   QueryFilter // The polygon filter to apply to the query.
+}
+
+// PathResult is the result of a PathRequest.
+type PathResult struct {
+	Path   []PolyRef // The polygons of the path. (Start to end.)
+	Status Status    // The status flags returned by FindPath.
+}
+
+// BatchPathOptions holds the parameters of NavMeshQuery.FindPaths.
+//
+// A zero value field means the default value is used.
+type BatchPathOptions struct {
+	// MaxPath is the maximum number of polygons of each path. Defaults to
+	// DefaultBatchMaxPath.
+	MaxPath int
+
+	// Workers is the number of goroutines processing the requests. With 0 or
+	// 1, the requests are processed sequentially, by the calling goroutine.
+	Workers int
+}
+
+// FindPaths finds the paths of a batch of requests.
+//
+//	Arguments:
+//	 requests  The path requests.
+//	 opts      The batch options, nil to use the default ones.
+//
+//	Return values:
+//	 results   The results, in the same order as the requests.
+//
+// This is equivalent to calling FindPath for each request, but the search
+// buffers of the query are reused across requests and the paths share a
+// single backing slice, so the number of allocations doesn't depend on the
+// number of requests.
+//
+// With more than one worker, the requests are split among the workers, the
+// first one using q and the other ones their own NavMeshQuery, with the same
+// node pool settings as q. The navigation mesh must not be modified while
+// FindPaths runs.
+//
+// Note: this method uses the node pool of q, it may not be used by multiple
+// clients at the same time.
+func (q *NavMeshQuery) FindPaths(requests []PathRequest, opts *BatchPathOptions) []PathResult {
+	var o BatchPathOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxPath <= 0 {
+		o.MaxPath = DefaultBatchMaxPath
+	}
+	if o.Workers > len(requests) {
+		o.Workers = len(requests)
+	}
+
+	results := make([]PathResult, len(requests))
+	if o.Workers <= 1 {
+		q.findPaths(requests, results, o.MaxPath)
+		return results
+	}
+
+	// Split the requests in contiguous chunks, one per worker.
+	var wg sync.WaitGroup
+	chunk := (len(requests) + o.Workers - 1) / o.Workers
+	for w := 0; w < o.Workers; w++ {
+		lo, hi := w*chunk, (w+1)*chunk
+		if hi > len(requests) {
+			hi = len(requests)
+		}
+		if lo >= hi {
+			break
+		}
+
+		wq := q
+		if w > 0 {
+			var st Status
+			st, wq = NewNavMeshQuery(q.nav, q.nodePool.chunkSize)
+			if StatusFailed(st) {
+				for i := lo; i < hi; i++ {
+					results[i].Status = st
+				}
+				continue
+			}
+			wq.nodePool.SetGrowthLimit(q.nodePool.GrowthLimit())
+		}
+		wg.Add(1)
+		go func(wq *NavMeshQuery, lo, hi int) {
+			defer wg.Done()
+			wq.findPaths(requests[lo:hi], results[lo:hi], o.MaxPath)
+		}(wq, lo, hi)
+	}
+	wg.Wait()
+	return results
+}
+
+// findPaths finds the paths of requests, sequentially, and stores them in
+// results.
+func (q *NavMeshQuery) findPaths(requests []PathRequest, results []PathResult, maxPath int) {
+	var (
+		path = make([]PolyRef, maxPath)
+		all  []PolyRef
+		ends = make([]int, len(requests))
+	)
+	for i := range requests {
+		r := &requests[i]
+		n, st := q.FindPath(r.StartRef, r.EndRef, r.StartPos, r.EndPos, r.Filter, path)
+		results[i].Status = st
+		all = append(all, path[:n]...)
+		ends[i] = len(all)
+	}
+
+	// Slice the paths once all is not reallocated anymore.
+	start := 0
+	for i := range results {
+		results[i].Path = all[start:ends[i]:ends[i]]
+		start = ends[i]
+	}
+}
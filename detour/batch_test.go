@@ -0,0 +1,53 @@
+package detour
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindPaths(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 2048)
+	filter := NewStandardQueryFilter()
+
+	// paths between polygons spread over the navmesh
+	refs := allPolyRefs(mesh)
+	var requests []PathRequest
+	for i := 0; i < len(refs); i += 7 {
+		start, end := refs[i], refs[(i*13+len(refs)/2)%len(refs)]
+		startPos, _ := q.PolyCentroid(start)
+		endPos, _ := q.PolyCentroid(end)
+		requests = append(requests, PathRequest{start, end, startPos, endPos, filter})
+	}
+
+	// expected results, one FindPath at a time
+	want := make([]PathResult, len(requests))
+	for i, r := range requests {
+		path := make([]PolyRef, 64)
+		n, st := q.FindPath(r.StartRef, r.EndRef, r.StartPos, r.EndPos, r.Filter, path)
+		want[i] = PathResult{path[:n:n], st}
+	}
+
+	for _, opts := range []*BatchPathOptions{
+		{MaxPath: 64},
+		{MaxPath: 64, Workers: 4},
+		{MaxPath: 64, Workers: len(requests) + 10},
+	} {
+		got := q.FindPaths(requests, opts)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FindPaths(%+v) returned different results than FindPath", *opts)
+		}
+	}
+
+	// default options
+	if got := q.FindPaths(requests[:1], nil); !reflect.DeepEqual(got[0].Path, want[0].Path) {
+		t.Errorf("FindPaths(nil) = %x, want %x", got[0].Path, want[0].Path)
+	}
+
+	// invalid request
+	got := q.FindPaths([]PathRequest{{Filter: filter}}, nil)
+	if !StatusFailed(got[0].Status) || len(got[0].Path) != 0 {
+		t.Errorf("invalid request got status 0x%x, path %x", got[0].Status, got[0].Path)
+	}
+}
@@ -0,0 +1,102 @@
+package detour
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// benchFixtures are the navmeshes of testdata/fixture the queries are
+// benchmarked on.
+var benchFixtures = []string{"plane", "donut", "floors", "rooms", "tiled"}
+
+// benchQuery is a query between the centers of two polygons.
+type benchQuery struct {
+	startRef, endRef PolyRef
+	startPos, endPos d3.Vec3
+}
+
+// loadBenchQueries loads a fixture navmesh and returns a query object and
+// random queries between its polygons, always the same.
+func loadBenchQueries(b *testing.B, name string) (*NavMeshQuery, []benchQuery) {
+	mesh, err := loadTestNavMesh("fixture/" + name + ".bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, q := NewNavMeshQuery(mesh, 2048)
+
+	var (
+		refs    []PolyRef
+		centers []d3.Vec3
+	)
+	mesh.EachPoly(func(ref PolyRef, poly *Poly, tile *MeshTile) bool {
+		if poly.Type() != polyTypeOffMeshConnection {
+			refs = append(refs, ref)
+			centers = append(centers, CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts))
+		}
+		return true
+	})
+
+	rng := rand.New(rand.NewSource(1))
+	queries := make([]benchQuery, 64)
+	for i := range queries {
+		s, e := rng.Intn(len(refs)), rng.Intn(len(refs))
+		queries[i] = benchQuery{refs[s], refs[e], centers[s], centers[e]}
+	}
+	return q, queries
+}
+
+func BenchmarkFixtureFindPath(b *testing.B) {
+	for _, name := range benchFixtures {
+		b.Run(name, func(b *testing.B) {
+			q, queries := loadBenchQueries(b, name)
+			filter := NewStandardQueryFilter()
+			path := make([]PolyRef, 256)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bq := &queries[i%len(queries)]
+				q.FindPath(bq.startRef, bq.endRef, bq.startPos, bq.endPos, filter, path)
+			}
+		})
+	}
+}
+
+func BenchmarkFixtureRaycast(b *testing.B) {
+	for _, name := range benchFixtures {
+		b.Run(name, func(b *testing.B) {
+			q, queries := loadBenchQueries(b, name)
+			filter := NewStandardQueryFilter()
+			hit := RaycastHit{Path: make([]PolyRef, 64)}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bq := &queries[i%len(queries)]
+				q.Raycast(bq.startRef, bq.startPos, bq.endPos, filter, 0, &hit, 0)
+			}
+		})
+	}
+}
+
+func BenchmarkFixtureFindNearestPoly(b *testing.B) {
+	for _, name := range benchFixtures {
+		b.Run(name, func(b *testing.B) {
+			q, queries := loadBenchQueries(b, name)
+			filter := NewStandardQueryFilter()
+			extents := d3.NewVec3XYZ(2, 4, 2)
+			centers := make([]d3.Vec3, len(queries))
+			for i, bq := range queries {
+				centers[i] = d3.NewVec3XYZ(bq.startPos[0]+0.5, bq.startPos[1]+1, bq.startPos[2]+0.5)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				q.FindNearestPoly(centers[i%len(centers)], extents, filter)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package detour
+
+import (
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// PolyEdit describes a modification of the flags and/or the area of a set of
+// polygons.
+//
+// Flags in SetFlags are raised, then flags in ClearFlags are lowered. The area
+// is only modified if SetArea is true.
+type PolyEdit struct {
+	SetFlags   uint16 // Flags to raise.
+	ClearFlags uint16 // Flags to lower.
+	SetArea    bool   // Whether the area should be modified.
+	Area       uint8  // The new area id. [Limit: < maxAreas]
+}
+
+func (e *PolyEdit) apply(m *NavMesh, ref PolyRef, p *Poly) {
+	m.setPolyFlags(ref, p, (p.Flags|e.SetFlags)&^e.ClearFlags)
+	if e.SetArea {
+		p.SetArea(e.Area)
+	}
+}
+
+// PolyState is the state of a polygon, as it was before being modified by a
+// brush.
+type PolyState struct {
+	Ref   PolyRef // The polygon reference.
+	Flags uint16  // The polygon flags.
+	Area  uint8   // The polygon area id.
+}
+
+// brushQuery is the polyQuery applying an edit to the polygons accepted by a
+// brush, recording their previous state.
+type brushQuery struct {
+	nav    *NavMesh
+	accept func(tile *MeshTile, poly *Poly) bool
+	edit   PolyEdit
+	prev   []PolyState
+}
+
+func (q *brushQuery) process(tile *MeshTile, polys []*Poly, refs []PolyRef, count int32) {
+	for i := int32(0); i < count; i++ {
+		p := polys[i]
+		if !q.accept(tile, p) {
+			continue
+		}
+		q.prev = append(q.prev, PolyState{Ref: refs[i], Flags: p.Flags, Area: p.Area()})
+		q.edit.apply(q.nav, refs[i], p)
+	}
+}
+
+// PaintBox applies an edit to all the polygons overlapping an axis-aligned box.
+//
+//	Arguments:
+//	 bmin    The minimum bounds of the box. [(x, y, z)]
+//	 bmax    The maximum bounds of the box. [(x, y, z)]
+//	 filter  The polygon filter to apply to the query.
+//	 edit    The modification to apply.
+//
+//	Return values:
+//	 prev    The state of the modified polygons, before the edit.
+//	 st      The status flags for the query.
+//
+// Polygons are resolved across all the tiles touched by the box. The returned
+// states can be passed to NavMesh.RestorePolys in order to undo the edit.
+func (q *NavMeshQuery) PaintBox(bmin, bmax d3.Vec3, filter QueryFilter, edit PolyEdit) (prev []PolyState, st Status) {
+	if len(bmin) != 3 || len(bmax) != 3 {
+		return nil, Failure | InvalidParam
+	}
+
+	center := bmin.Lerp(bmax, 0.5)
+	extents := bmax.Sub(center)
+
+	pmin, pmax := d3.NewVec3(), d3.NewVec3()
+	query := &brushQuery{
+		nav:  q.nav,
+		edit: edit,
+		accept: func(tile *MeshTile, poly *Poly) bool {
+			polyBounds(tile, poly, pmin, pmax)
+			return OverlapBounds(bmin, bmax, pmin, pmax)
+		},
+	}
+	st = q.queryPolygons4(center, extents, filter, query)
+	if StatusFailed(st) {
+		return nil, st
+	}
+	return query.prev, Success
+}
+
+// PaintCircle applies an edit to all the polygons overlapping a vertical
+// cylinder.
+//
+//	Arguments:
+//	 center      The center of the circle. [(x, y, z)]
+//	 radius      The radius of the circle, in the xz-plane.
+//	 halfHeight  The search distance along the y-axis, above and below center.
+//	 filter      The polygon filter to apply to the query.
+//	 edit        The modification to apply.
+//
+//	Return values:
+//	 prev    The state of the modified polygons, before the edit.
+//	 st      The status flags for the query.
+//
+// Polygons are resolved across all the tiles touched by the circle. The
+// returned states can be passed to NavMesh.RestorePolys in order to undo the
+// edit.
+func (q *NavMeshQuery) PaintCircle(center d3.Vec3, radius, halfHeight float32, filter QueryFilter, edit PolyEdit) (prev []PolyState, st Status) {
+	if len(center) != 3 || radius < 0 || halfHeight < 0 {
+		return nil, Failure | InvalidParam
+	}
+
+	var (
+		verts  [VertsPerPolygon * 3]float32
+		ed, et [VertsPerPolygon]float32
+	)
+	r2 := radius * radius
+	query := &brushQuery{
+		nav:  q.nav,
+		edit: edit,
+		accept: func(tile *MeshTile, poly *Poly) bool {
+			nv := int32(poly.VertCount)
+			for i := int32(0); i < nv; i++ {
+				vi := poly.Verts[i] * 3
+				copy(verts[i*3:i*3+3], tile.Verts[vi:vi+3])
+			}
+			if distancePtPolyEdgesSqr(center, verts[:], nv, ed[:], et[:]) {
+				// circle center is inside the polygon
+				return true
+			}
+			for i := int32(0); i < nv; i++ {
+				if ed[i] <= r2 {
+					return true
+				}
+			}
+			return false
+		},
+	}
+	extents := d3.NewVec3XYZ(radius, halfHeight, radius)
+	st = q.queryPolygons4(center, extents, filter, query)
+	if StatusFailed(st) {
+		return nil, st
+	}
+	return query.prev, Success
+}
+
+// RestorePolys restores the flags and area of polygons to a previously saved
+// state, as returned by NavMeshQuery.PaintBox or NavMeshQuery.PaintCircle.
+//
+// States are restored in reverse order, so that the oldest state of a polygon
+// present more than once wins. Invalid references are skipped, in which case
+// the returned status also has InvalidParam set.
+func (m *NavMesh) RestorePolys(states []PolyState) Status {
+	st := Status(Success)
+	for i := len(states) - 1; i >= 0; i-- {
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		if StatusFailed(m.TileAndPolyByRef(states[i].Ref, &tile, &poly)) {
+			st |= InvalidParam
+			continue
+		}
+		m.setPolyFlags(states[i].Ref, poly, states[i].Flags)
+		poly.SetArea(states[i].Area)
+	}
+	return st
+}
+
+// polyBounds computes the bounding box of a polygon.
+func polyBounds(tile *MeshTile, poly *Poly, bmin, bmax d3.Vec3) {
+	vi := poly.Verts[0] * 3
+	bmin.Assign(tile.Verts[vi : vi+3])
+	bmax.Assign(tile.Verts[vi : vi+3])
+	for j := uint8(1); j < poly.VertCount; j++ {
+		vi = poly.Verts[j] * 3
+		d3.Vec3Min(bmin, tile.Verts[vi:vi+3])
+		d3.Vec3Max(bmax, tile.Verts[vi:vi+3])
+	}
+}
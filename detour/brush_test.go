@@ -0,0 +1,140 @@
+package detour
+
+import (
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func TestPaintAndRestorePolys(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	st, q := NewNavMeshQuery(mesh, 100)
+	if StatusFailed(st) {
+		t.Fatalf("query creation failed with status 0x%x", st)
+	}
+	filter := NewStandardQueryFilter()
+
+	edit := PolyEdit{SetFlags: 0x8000, SetArea: true, Area: 7}
+
+	paintTests := []struct {
+		msg   string
+		paint func() ([]PolyState, Status)
+	}{
+		{
+			"circle",
+			func() ([]PolyState, Status) {
+				return q.PaintCircle(d3.Vec3{5, 0, 10}, 3, 2, filter, edit)
+			},
+		},
+		{
+			"box",
+			func() ([]PolyState, Status) {
+				return q.PaintBox(d3.Vec3{2, -2, 7}, d3.Vec3{8, 2, 13}, filter, edit)
+			},
+		},
+	}
+
+	for _, tt := range paintTests {
+		prev, st := tt.paint()
+		if StatusFailed(st) {
+			t.Fatalf("%s, paint failed with status 0x%x", tt.msg, st)
+		}
+		if len(prev) == 0 {
+			t.Fatalf("%s, no polygons painted", tt.msg)
+		}
+
+		for _, ps := range prev {
+			flags, _ := mesh.PolyFlags(ps.Ref)
+			area, _ := mesh.PolyArea(ps.Ref)
+			if flags != ps.Flags|edit.SetFlags || area != edit.Area {
+				t.Errorf("%s, poly 0x%x not edited, got flags 0x%x area %d", tt.msg, ps.Ref, flags, area)
+			}
+		}
+
+		if st = mesh.RestorePolys(prev); st != Success {
+			t.Fatalf("%s, restore failed with status 0x%x", tt.msg, st)
+		}
+
+		for _, ps := range prev {
+			flags, _ := mesh.PolyFlags(ps.Ref)
+			area, _ := mesh.PolyArea(ps.Ref)
+			if flags != ps.Flags || area != ps.Area {
+				t.Errorf("%s, poly 0x%x not restored, got flags 0x%x area %d, want 0x%x %d",
+					tt.msg, ps.Ref, flags, area, ps.Flags, ps.Area)
+			}
+		}
+	}
+}
+
+func TestPaintCircleIsSubsetOfBox(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	_, q := NewNavMeshQuery(mesh, 100)
+	filter := NewStandardQueryFilter()
+
+	// an empty edit doesn't modify polygons but still reports them
+	var edit PolyEdit
+	circle, _ := q.PaintCircle(d3.Vec3{20, 0, 20}, 4, 2, filter, edit)
+	box, _ := q.PaintBox(d3.Vec3{16, -2, 16}, d3.Vec3{24, 2, 24}, filter, edit)
+
+	if len(circle) == 0 {
+		t.Fatalf("no polygons touched by the circle")
+	}
+
+	inBox := make(map[PolyRef]bool)
+	for _, ps := range box {
+		inBox[ps.Ref] = true
+	}
+	for _, ps := range circle {
+		if !inBox[ps.Ref] {
+			t.Errorf("poly 0x%x touched by the circle but not by the enclosing box", ps.Ref)
+		}
+	}
+}
+
+func TestSetFlagsForArea(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	refs := allPolyRefs(mesh)
+	before := make(map[PolyRef]uint16)
+	for i, ref := range refs {
+		if i%3 == 0 {
+			mesh.SetPolyArea(ref, 7)
+		}
+		before[ref], _ = mesh.PolyFlags(ref)
+	}
+
+	const set, clear = 0x8000, 0x0001
+	n, st := mesh.SetFlagsForArea(7, set, clear)
+	if st != Success {
+		t.Fatalf("SetFlagsForArea failed with status 0x%x", st)
+	}
+	if want := (len(refs) + 2) / 3; n != want {
+		t.Errorf("got %d modified polys, want %d", n, want)
+	}
+
+	for _, ref := range refs {
+		area, _ := mesh.PolyArea(ref)
+		flags, _ := mesh.PolyFlags(ref)
+		want := before[ref]
+		if area == 7 {
+			want = want&^clear | set
+		}
+		if flags != want {
+			t.Errorf("poly 0x%x (area %d), got flags 0x%x, want 0x%x", ref, area, flags, want)
+		}
+	}
+
+	// applying the same masks again doesn't modify anything
+	if n, _ = mesh.SetFlagsForArea(7, set, clear); n != 0 {
+		t.Errorf("got %d modified polys, want 0", n)
+	}
+
+	if _, st = mesh.SetFlagsForArea(64, set, clear); st != Failure|InvalidParam {
+		t.Errorf("want status 0x%x with invalid area, got 0x%x", Failure|InvalidParam, st)
+	}
+}
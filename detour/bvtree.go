@@ -0,0 +1,121 @@
+package detour
+
+import (
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+// SetBuildBvTrees sets whether AddTile builds the bounding volume tree of the
+// tiles added without one.
+//
+// Tiles built by external tools sometimes lack a BV tree, without which the
+// spatial queries (FindNearestPoly, QueryPolygons...) have to test every
+// polygon of a tile.
+//
+// see BuildTileBvTree
+func (m *NavMesh) SetBuildBvTrees(build bool) {
+	m.buildBvTrees = build
+}
+
+// BuildTileBvTree builds the bounding volume tree of a tile, replacing the
+// one it may have.
+//
+//	Arguments:
+//	 ref      The reference of the tile.
+//
+// Return the status flags for the operation.
+//
+// The tree is built from the tile polygons and detail meshes, the same way
+// CreateNavMeshData does. The tile data is updated accordingly, so the tree is
+// part of the data returned by RemoveTile, and of the encoded navigation mesh.
+func (m *NavMesh) BuildTileBvTree(ref TileRef) Status {
+	tile := m.TileByRef(ref)
+	if tile == nil || tile.Header == nil {
+		return Failure | InvalidParam
+	}
+	buildTileBvTree(tile)
+	return Success
+}
+
+// buildTileBvTree builds the bounding volume tree of a tile, and updates the
+// tile header and data.
+func buildTileBvTree(tile *MeshTile) {
+	hdr := tile.Header
+
+	// Keep the quantization factor of the tile if it has one, else quantize
+	// the longest side of the tile bounds over the whole uint16 range.
+	qfac := hdr.BvQuantFactor
+	if qfac <= 0 {
+		ext := math32.Max(hdr.BMax[0]-hdr.BMin[0], math32.Max(hdr.BMax[1]-hdr.BMin[1], hdr.BMax[2]-hdr.BMin[2]))
+		qfac = 1
+		if ext > 0 {
+			qfac = 0xfffe / ext
+		}
+	}
+
+	npolys := hdr.OffMeshBase
+	if npolys > 0 {
+		items := tileBvItems(tile, npolys, qfac)
+		nodes := make([]BvNode, 2*npolys)
+		var nnodes int32
+		subdivide(items, npolys, 0, npolys, &nnodes, nodes)
+		tile.BvTree = nodes[:nnodes]
+	} else {
+		tile.BvTree = nil
+	}
+
+	hdr.BvNodeCount = int32(len(tile.BvTree))
+	hdr.BvQuantFactor = qfac
+	tile.DataSize = int32(hdr.dataSize())
+	tile.Data = tileData(tile)
+}
+
+// bvTreeSize returns the number of nodes of a BV tree. The tree is rooted at
+// its first node, the nodes following the subtree of the root are unused.
+func bvTreeSize(tree []BvNode) int32 {
+	if len(tree) == 0 {
+		return 0
+	}
+	if root := tree[0].I; root < 0 {
+		return -root
+	}
+	return 1
+}
+
+// tileBvItems returns the quantized bounds of the first npolys polygons of a
+// tile, computed from their vertices and detail vertices.
+func tileBvItems(tile *MeshTile, npolys int32, qfac float32) []bvItem {
+	hdr := tile.Header
+	quantize := func(v, orig float32) uint16 {
+		return uint16(int32Clamp(int32((v-orig)*qfac), 0, 0xffff))
+	}
+
+	items := make([]bvItem, npolys)
+	for i := int32(0); i < npolys; i++ {
+		poly := &tile.Polys[i]
+		var bmin, bmax [3]float32
+		copy(bmin[:], tile.Verts[poly.Verts[0]*3:poly.Verts[0]*3+3])
+		copy(bmax[:], bmin[:])
+		for j := uint8(1); j < poly.VertCount; j++ {
+			v := tile.Verts[poly.Verts[j]*3:]
+			d3.Vec3Min(bmin[:], v)
+			d3.Vec3Max(bmax[:], v)
+		}
+		if i < hdr.DetailMeshCount {
+			pd := &tile.DetailMeshes[i]
+			for j := uint32(0); j < uint32(pd.VertCount); j++ {
+				v := tile.DetailVerts[(pd.VertBase+j)*3:]
+				d3.Vec3Min(bmin[:], v)
+				d3.Vec3Max(bmax[:], v)
+			}
+		}
+
+		it := &items[i]
+		it.i = i
+		for k := 0; k < 3; k++ {
+			it.BMin[k] = quantize(bmin[k], hdr.BMin[k])
+			it.BMax[k] = quantize(bmax[k], hdr.BMin[k])
+		}
+	}
+	return items
+}
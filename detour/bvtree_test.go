@@ -0,0 +1,121 @@
+package detour
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// nearestPolyDists returns the distances to the nearest polygon of the
+// centers of all the polygons of mesh, shifted a bit.
+func nearestPolyDists(t *testing.T, mesh *NavMesh) []float32 {
+	_, q := NewNavMeshQuery(mesh, 2048)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+
+	var dists []float32
+	pt := d3.NewVec3()
+	mesh.EachPoly(func(ref PolyRef, poly *Poly, tile *MeshTile) bool {
+		if poly.Type() == polyTypeOffMeshConnection {
+			return true
+		}
+		c := CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts)
+		c = d3.NewVec3XYZ(c[0]+0.7, c[1]+1, c[2]-0.3)
+		st, _, dist, _ := q.FindNearestPolyEx(c, extents, filter, pt)
+		if StatusFailed(st) {
+			t.Fatalf("FindNearestPolyEx failed with status 0x%x", st)
+		}
+		dists = append(dists, dist)
+		return true
+	})
+	return dists
+}
+
+// stripBvTree returns the data of tile, without its bounding volume tree.
+func stripBvTree(tile *MeshTile) []byte {
+	stripped := *tile
+	hdr := *tile.Header
+	hdr.BvNodeCount = 0
+	stripped.Header = &hdr
+	stripped.BvTree = nil
+	stripped.DataSize = int32(hdr.dataSize())
+	return tileData(&stripped)
+}
+
+func TestBuildTileBvTree(t *testing.T) {
+	for _, fname := range []string{"mesh1.bin", "mesh2.bin", "offmeshcons.bin", "fixture/floors.bin"} {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+		want := nearestPolyDists(t, mesh)
+
+		for i := range mesh.Tiles {
+			tile := &mesh.Tiles[i]
+			if tile.Header == nil {
+				continue
+			}
+			if st := mesh.BuildTileBvTree(mesh.TileRef(tile)); StatusFailed(st) {
+				t.Fatalf("%v: BuildTileBvTree failed with status 0x%x", fname, st)
+			}
+		}
+		issues, err := ValidateNavMesh(mesh)
+		checkt(t, err)
+		if len(issues) != 0 {
+			t.Errorf("%v: want no issues, got %v", fname, issues)
+		}
+
+		// The rebuilt trees are encoded along with the tiles.
+		var buf bytes.Buffer
+		checkt(t, mesh.Encode(&buf))
+		mesh, err = Decode(&buf)
+		checkt(t, err)
+
+		got := nearestPolyDists(t, mesh)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%v: query %d: nearest poly at distance %v, want %v", fname, i, got[i], want[i])
+				break
+			}
+		}
+	}
+
+	var mesh NavMesh
+	if st := mesh.BuildTileBvTree(0); !StatusFailed(st) {
+		t.Errorf("BuildTileBvTree should fail with an invalid tile ref")
+	}
+}
+
+func TestAddTileBuildBvTrees(t *testing.T) {
+	src, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+	want := nearestPolyDists(t, src)
+
+	for _, build := range []bool{false, true} {
+		var mesh NavMesh
+		if st := mesh.Init(&src.Params); StatusFailed(st) {
+			t.Fatalf("Init failed with status 0x%x", st)
+		}
+		mesh.SetBuildBvTrees(build)
+		for i := range src.Tiles {
+			tile := &src.Tiles[i]
+			if tile.Header == nil {
+				continue
+			}
+			st, ref := mesh.AddTile(stripBvTree(tile), src.TileRef(tile))
+			if StatusFailed(st) {
+				t.Fatalf("AddTile failed with status 0x%x", st)
+			}
+			if n := mesh.TileByRef(ref).Header.BvNodeCount; (n != 0) != build {
+				t.Errorf("SetBuildBvTrees(%t): tile added with %d bv nodes", build, n)
+			}
+		}
+
+		got := nearestPolyDists(t, &mesh)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("SetBuildBvTrees(%t): query %d: nearest poly at distance %v, want %v", build, i, got[i], want[i])
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package detour
+
+import (
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+// PortalWidth returns the width, in the xz-plane, of the portal between two
+// adjacent polygons.
+//
+//	Arguments:
+//	 from    The reference of the polygon to leave.
+//	 to      The reference of the polygon to enter.
+//
+//	Return values:
+//	 width   The width of the portal.
+//	 st      The status flags for the query.
+//
+// Portals on tile borders are clamped to the part shared by both polygons.
+// The portals leading to or from an off-mesh connection are points, so their
+// width is 0.
+//
+// Note: this method may be used by multiple clients without side effects.
+func (q *NavMeshQuery) PortalWidth(from, to PolyRef) (width float32, st Status) {
+	var (
+		fromType, toType uint8
+		left, right      = d3.NewVec3(), d3.NewVec3()
+	)
+	if st = q.portalPoints6(from, to, left, right, &fromType, &toType); StatusFailed(st) {
+		return 0, st
+	}
+	dx := right[0] - left[0]
+	dz := right[2] - left[2]
+	return math32.Sqrt(dx*dx + dz*dz), Success
+}
+
+// FindNarrowPortal finds the first portal of a polygon corridor that is too
+// narrow for an agent of the given radius.
+//
+//	Arguments:
+//	 path    The polygon corridor. (Start to end.)
+//	 radius  The radius of the agent. [Limit: >= 0]
+//
+//	Return values:
+//	 idx     The index of the polygon before the narrow portal, in path.
+//	         (The portal between path[idx] and path[idx+1].) -1 if the agent
+//	         fits through all the portals.
+//	 st      The status flags for the query.
+//
+// The polygons of a tile are already eroded by the walkable radius the tile
+// was built with (MeshHeader.WalkableRadius), so a portal is too narrow if it
+// is less than 2*(radius - WalkableRadius) wide. The portals of off-mesh
+// connections are ignored.
+//
+// This is meant to revalidate a corridor when the radius of an agent changes
+// at runtime (E.g. crouching or mounting). A corridor with a narrow portal
+// should be truncated before that portal, or a new path should be searched.
+//
+// Note: this method may be used by multiple clients without side effects.
+func (q *NavMeshQuery) FindNarrowPortal(path []PolyRef, radius float32) (idx int, st Status) {
+	if len(path) == 0 || !(radius >= 0) || math32.IsInf(radius, 0) {
+		return -1, Failure | InvalidParam
+	}
+
+	left, right := d3.NewVec3(), d3.NewVec3()
+	for i := 0; i+1 < len(path); i++ {
+		var (
+			fromTile, toTile *MeshTile
+			fromPoly, toPoly *Poly
+		)
+		if StatusFailed(q.nav.TileAndPolyByRef(path[i], &fromTile, &fromPoly)) ||
+			StatusFailed(q.nav.TileAndPolyByRef(path[i+1], &toTile, &toPoly)) {
+			return -1, Failure | InvalidParam
+		}
+		if fromPoly.Type() == polyTypeOffMeshConnection || toPoly.Type() == polyTypeOffMeshConnection {
+			continue
+		}
+
+		clearance := radius - fromTile.Header.WalkableRadius
+		if clearance <= 0 {
+			continue
+		}
+		if st = q.portalPoints8(path[i], fromPoly, fromTile, path[i+1], toPoly, toTile, left, right); StatusFailed(st) {
+			// Not adjacent polygons.
+			return -1, st
+		}
+		dx := right[0] - left[0]
+		dz := right[2] - left[2]
+		if dx*dx+dz*dz < 4*clearance*clearance {
+			return i, Success
+		}
+	}
+	return -1, Success
+}
@@ -0,0 +1,68 @@
+package detour
+
+import (
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func TestFindNarrowPortal(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+
+	_, q := NewNavMeshQuery(mesh, 1000)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	_, orgRef, org := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, filter)
+	_, dstRef, dst := q.FindNearestPoly(d3.Vec3{42.457218, 7.797607, 17.778244}, extents, filter)
+
+	path := make([]PolyRef, 100)
+	npath, st := q.FindPath(orgRef, dstRef, org, dst, filter, path)
+	if StatusFailed(st) {
+		t.Fatalf("FindPath failed with status 0x%x", st)
+	}
+	path = path[:npath]
+
+	// find the narrowest portal of the corridor
+	narrowest, minWidth := -1, float32(0)
+	for i := 0; i+1 < len(path); i++ {
+		w, st := q.PortalWidth(path[i], path[i+1])
+		if StatusFailed(st) {
+			t.Fatalf("PortalWidth(0x%x, 0x%x) failed with status 0x%x", path[i], path[i+1], st)
+		}
+		if w <= 0 {
+			t.Fatalf("PortalWidth(0x%x, 0x%x) = %f, want > 0", path[i], path[i+1], w)
+		}
+		if narrowest == -1 || w < minWidth {
+			narrowest, minWidth = i, w
+		}
+	}
+
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	mesh.TileAndPolyByRef(path[0], &tile, &poly)
+	wr := tile.Header.WalkableRadius
+
+	// the build radius always fits
+	if idx, st := q.FindNarrowPortal(path, wr); st != Success || idx != -1 {
+		t.Errorf("FindNarrowPortal(walkable radius) = %d, 0x%x, want -1, 0x%x", idx, st, Success)
+	}
+	// just wide enough for the narrowest portal
+	if idx, st := q.FindNarrowPortal(path, wr+minWidth/2-1e-3); st != Success || idx != -1 {
+		t.Errorf("FindNarrowPortal(fitting radius) = %d, 0x%x, want -1, 0x%x", idx, st, Success)
+	}
+	// too wide for the narrowest portal, but not for the ones before
+	idx, st := q.FindNarrowPortal(path, wr+minWidth/2+1e-3)
+	if st != Success || idx == -1 || idx > narrowest {
+		t.Errorf("FindNarrowPortal(too large radius) = %d, 0x%x, want <= %d, 0x%x", idx, st, narrowest, Success)
+	}
+
+	if _, st := q.FindNarrowPortal(nil, 1); st != Failure|InvalidParam {
+		t.Errorf("want status 0x%x with empty path, got 0x%x", Failure|InvalidParam, st)
+	}
+	if _, st := q.FindNarrowPortal([]PolyRef{path[0], path[2]}, 100); StatusSucceed(st) {
+		t.Errorf("want failure with non adjacent polygons, got 0x%x", st)
+	}
+}
@@ -7,6 +7,20 @@ import (
 
 // Computational geometry helper functions.
 
+// isValidVec3 reports whether v is a usable position or vector, that is it
+// has 3 components and all of them are finite.
+func isValidVec3(v d3.Vec3) bool {
+	if len(v) < 3 {
+		return false
+	}
+	for i := 0; i < 3; i++ {
+		if math32.IsNaN(v[i]) || math32.IsInf(v[i], 0) {
+			return false
+		}
+	}
+	return true
+}
+
 // TriArea2D derives the signed xz-plane area of the triangle ABC, or the
 // relationship of line AB to point C.
 //
@@ -84,6 +98,65 @@ func OverlapBounds(amin, amax, bmin, bmax []float32) bool {
 	return true
 }
 
+// projectPoly projects the vertices of a polygon on an axis, in the xz-plane,
+// and returns the range they cover.
+func projectPoly(axis, poly []float32, npoly int) (rmin, rmax float32) {
+	rmin = axis[0]*poly[0] + axis[2]*poly[2]
+	rmax = rmin
+	for i := 1; i < npoly; i++ {
+		d := axis[0]*poly[i*3] + axis[2]*poly[i*3+2]
+		rmin = math32.Min(rmin, d)
+		rmax = math32.Max(rmax, d)
+	}
+	return rmin, rmax
+}
+
+// overlapPolyPoly2D reports whether two convex polygons overlap in the
+// xz-plane.
+//
+//	Arguments:
+//	 polya   Polygon A vertices. [(x, y, z) * npolya]
+//	 npolya  The number of vertices in polygon A.
+//	 polyb   Polygon B vertices. [(x, y, z) * npolyb]
+//	 npolyb  The number of vertices in polygon B.
+func overlapPolyPoly2D(polya []float32, npolya int, polyb []float32, npolyb int) bool {
+	const eps = 1e-4
+
+	// separated reports whether one of the edge normals of p is a separating
+	// axis between polya and polyb.
+	separated := func(p []float32, np int) bool {
+		for i, j := 0, np-1; i < np; j, i = i, i+1 {
+			va := p[j*3 : j*3+3]
+			vb := p[i*3 : i*3+3]
+			n := [3]float32{vb[2] - va[2], 0, -(vb[0] - va[0])}
+			amin, amax := projectPoly(n[:], polya, npolya)
+			bmin, bmax := projectPoly(n[:], polyb, npolyb)
+			if amin+eps > bmax || amax-eps < bmin {
+				return true
+			}
+		}
+		return false
+	}
+	return !separated(polya, npolya) && !separated(polyb, npolyb)
+}
+
+// pointInPolygon returns true if the point is inside the polygon, in the
+// xz-plane. All points are projected onto the xz-plane, so the y-values are
+// ignored.
+func pointInPolygon(pt, verts []float32, nverts int) bool {
+	// TODO: Replace pnpoly with triArea2D tests?
+	c := false
+	for i, j := 0, nverts-1; i < nverts; j, i = i, i+1 {
+		vi := verts[i*3 : i*3+3]
+		vj := verts[j*3 : j*3+3]
+		if ((vi[2] > pt[2]) != (vj[2] > pt[2])) &&
+			(pt[0] < (vj[0]-vi[0])*(pt[2]-vi[2])/(vj[2]-vi[2])+vi[0]) {
+			c = !c
+		}
+	}
+	return c
+}
+
 func distancePtPolyEdgesSqr(pt, verts []float32, nverts int32, ed, et []float32) bool {
 	// TODO: Replace pnpoly with triArea2D tests?
 	c := false
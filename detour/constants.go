@@ -1,10 +1,42 @@
 package detour
 
+import "fmt"
+
+const navMeshSetMagic = 'M'<<24 | 'S'<<16 | 'E'<<8 | 'T'
+
+// Versions of the navigation mesh set format, that is the format of the files
+// written by NavMesh.Encode and of the manifests written by NavMesh.EncodeDir.
+//
+// The tiles stored in those files are in the Detour tile format, which has its
+// own version, kept identical to the one of the C++ library so that tiles can
+// be exchanged with it.
+//
+// Any change to the way navigation mesh sets are serialized must either keep
+// the files of the current version readable and identical, or bump
+// NavMeshSetVersion. Files of older versions are still read, down to
+// MinNavMeshSetVersion, which is only raised when the support of old files is
+// deliberately dropped. testdata/compat holds a file for each readable
+// version, TestFormatCompatibility checks they are all read and that the file
+// of the current version is still encoded byte for byte.
 const (
-	navMeshSetMagic   = 'M'<<24 | 'S'<<16 | 'E'<<8 | 'T'
-	navMeshSetVersion = 1
+	// NavMeshSetVersion is the version of the files written by this package.
+	NavMeshSetVersion = 1
+
+	// MinNavMeshSetVersion is the oldest version of the files read by this
+	// package.
+	MinNavMeshSetVersion = 1
 )
 
+// checkSetVersion returns an error if the navigation mesh set format version v
+// can't be read.
+func checkSetVersion(v uint32) error {
+	if v < MinNavMeshSetVersion || v > NavMeshSetVersion {
+		return fmt.Errorf("unsupported version: %d (supported versions: %d to %d)",
+			v, MinNavMeshSetVersion, NavMeshSetVersion)
+	}
+	return nil
+}
+
 const (
 	// VertsPerPolygon is the maximum number of vertices per navigation polygon.
 	VertsPerPolygon uint32 = 6
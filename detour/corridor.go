@@ -0,0 +1,253 @@
+package detour
+
+import (
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// MergeCorridors merges a path corridor with a corridor found from its last
+// polygon, for example after its target has moved.
+//
+//	Arguments:
+//	 path      The corridor to extend, its length is the maximum number of
+//	           polygons of the merged corridor.
+//	 npath     The number of polygons of the corridor.
+//	 res       The corridor to append, starting at path[npath-1].
+//
+//	Return values:
+//	 n         The number of polygons of the merged corridor, stored in path.
+//	 ok        false if res doesn't start at the end of the corridor, in which
+//	           case path is not modified.
+//
+// The trackbacks, that is the polygons entered and left right after by the
+// same edge, are removed from the merged corridor. If path is too small, the
+// end of res is dropped.
+func MergeCorridors(path []PolyRef, npath int, res []PolyRef) (n int, ok bool) {
+	if npath <= 0 || len(res) == 0 || path[npath-1] != res[0] {
+		return npath, false
+	}
+
+	n = npath - 1 + copy(path[npath-1:], res)
+
+	// Remove trackbacks
+	for j := 1; j+1 < n; j++ {
+		if path[j-1] == path[j+1] {
+			copy(path[j-1:], path[j+1:n])
+			n -= 2
+			j -= 2
+			if j < 0 {
+				j = 0
+			}
+		}
+	}
+	return n, true
+}
+
+// MergeCorridorStartMoved updates the start of a path corridor after the
+// agent moved along the surface from its first polygon.
+//
+//	Arguments:
+//	 path      The corridor to update, its length is the maximum number of
+//	           polygons of the updated corridor.
+//	 npath     The number of polygons of the corridor.
+//	 visited   The polygons visited by the move, as returned by
+//	           MoveAlongSurface.
+//
+// Return the number of polygons of the updated corridor, stored in path. It
+// starts with the polygon the agent is in. If the move didn't visit any
+// polygon of the corridor, the corridor is not modified.
+func MergeCorridorStartMoved(path []PolyRef, npath int, visited []PolyRef) int {
+	// Find furthest common polygon.
+	furthestPath, furthestVisited := -1, -1
+	for i := npath - 1; i >= 0 && furthestPath == -1; i-- {
+		for j := len(visited) - 1; j >= 0; j-- {
+			if path[i] == visited[j] {
+				furthestPath, furthestVisited = i, j
+			}
+		}
+	}
+
+	// If no intersection found just return current path.
+	if furthestPath == -1 {
+		return npath
+	}
+
+	// Concatenate paths.
+
+	// Adjust beginning of the buffer to include the visited.
+	req := len(visited) - furthestVisited
+	if req > len(path) {
+		req = len(path)
+	}
+	orig := furthestPath + 1
+	size := npath - orig
+	if req+size > len(path) {
+		size = len(path) - req
+	}
+	copy(path[req:req+size], path[orig:orig+size])
+
+	// Store visited
+	for i := 0; i < req; i++ {
+		path[i] = visited[len(visited)-1-i]
+	}
+	return req + size
+}
+
+// MergeCorridorEndMoved updates the end of a path corridor after its target
+// moved along the surface from its last polygon.
+//
+//	Arguments:
+//	 path      The corridor to update, its length is the maximum number of
+//	           polygons of the updated corridor.
+//	 npath     The number of polygons of the corridor.
+//	 visited   The polygons visited by the target move, as returned by
+//	           MoveAlongSurface.
+//
+// Return the number of polygons of the updated corridor, stored in path. If
+// the move didn't visit any polygon of the corridor, the corridor is not
+// modified.
+func MergeCorridorEndMoved(path []PolyRef, npath int, visited []PolyRef) int {
+	// Find furthest common polygon.
+	furthestPath, furthestVisited := -1, -1
+	for i := 0; i < npath && furthestPath == -1; i++ {
+		for j := len(visited) - 1; j >= 0; j-- {
+			if path[i] == visited[j] {
+				furthestPath, furthestVisited = i, j
+			}
+		}
+	}
+
+	// If no intersection found just return current path.
+	if furthestPath == -1 {
+		return npath
+	}
+
+	// Concatenate paths.
+	ppos := furthestPath + 1
+	vpos := furthestVisited + 1
+	return ppos + copy(path[ppos:], visited[vpos:])
+}
+
+// MergeCorridorStartShortcut replaces the start of a path corridor with a
+// shortcut, ending on a polygon of the corridor.
+//
+//	Arguments:
+//	 path      The corridor to update, its length is the maximum number of
+//	           polygons of the updated corridor.
+//	 npath     The number of polygons of the corridor.
+//	 visited   The polygons of the shortcut, starting at the first polygon of
+//	           the corridor, as returned by Raycast.
+//
+// Return the number of polygons of the updated corridor, stored in path. If
+// the shortcut doesn't end on a polygon of the corridor, the corridor is not
+// modified.
+func MergeCorridorStartShortcut(path []PolyRef, npath int, visited []PolyRef) int {
+	// Find furthest common polygon.
+	furthestPath, furthestVisited := -1, -1
+	for i := npath - 1; i >= 0 && furthestPath == -1; i-- {
+		for j := len(visited) - 1; j >= 0; j-- {
+			if path[i] == visited[j] {
+				furthestPath, furthestVisited = i, j
+			}
+		}
+	}
+
+	// If no intersection found just return current path.
+	if furthestPath == -1 {
+		return npath
+	}
+
+	// Concatenate paths.
+
+	// Adjust beginning of the buffer to include the visited.
+	req := furthestVisited
+	if req <= 0 {
+		return npath
+	}
+	if req > len(path) {
+		req = len(path)
+	}
+	orig := furthestPath
+	size := npath - orig
+	if req+size > len(path) {
+		size = len(path) - req
+	}
+	copy(path[req:req+size], path[orig:orig+size])
+
+	// Store visited
+	copy(path[:req], visited)
+	return req + size
+}
+
+// ReplanPath updates a path corridor after its target has changed, by
+// searching a path from the end of the corridor to the new target and merging
+// it into the corridor.
+//
+//	Arguments:
+//	 path      The corridor to update, its length is the maximum number of
+//	           polygons of the updated corridor.
+//	 npath     The number of polygons of the corridor.
+//	 endPos    The position of the previous target, in path[npath-1].
+//	           [(x, y, z)]
+//	 targetRef The reference id of the polygon of the new target.
+//	 targetPos The position of the new target. [(x, y, z)]
+//	 filter    The polygon filter to apply to the query.
+//	 maxIter   The maximum number of search iterations. [Limit: > 0]
+//
+//	Return values:
+//	 n         The number of polygons of the updated corridor, stored in path.
+//	 st        The status flags for the query.
+//
+// This is meant for small target moves: the search is limited to maxIter
+// iterations, if it doesn't reach the new target the corridor goes toward it
+// as far as possible and the PartialResult flag is set, a full path should
+// then be searched later. If the new target is already in the corridor, the
+// corridor is simply truncated.
+//
+// The trackbacks of the merged corridor are removed (See: MergeCorridors).
+//
+// Note: this method uses the node pool of the sliced path queries, it
+// cancels any sliced path query in progress.
+func (q *NavMeshQuery) ReplanPath(path []PolyRef, npath int, endPos d3.Vec3,
+	targetRef PolyRef, targetPos d3.Vec3, filter QueryFilter, maxIter int) (n int, st Status) {
+
+	if npath <= 0 || npath > len(path) || maxIter <= 0 || filter == nil ||
+		!isValidVec3(endPos) || !isValidVec3(targetPos) || !q.nav.IsValidPolyRef(targetRef) {
+		return npath, Failure | InvalidParam
+	}
+
+	// The target is still in the corridor.
+	for i := 0; i < npath; i++ {
+		if path[i] == targetRef {
+			return i + 1, Success
+		}
+	}
+
+	st = q.InitSlicedFindPath(path[npath-1], targetRef, endPos, targetPos, filter, 0)
+	if StatusFailed(st) {
+		return npath, st
+	}
+	st = q.UpdateSlicedFindPath(maxIter, nil)
+	if StatusFailed(st) {
+		return npath, st
+	}
+
+	res := make([]PolyRef, len(path))
+	nres, fst := q.FinalizeSlicedFindPath(res, len(res))
+	if StatusFailed(fst) {
+		return npath, fst
+	}
+
+	n, ok := MergeCorridors(path, npath, res[:nres])
+	if !ok {
+		return npath, Failure
+	}
+
+	st = Success
+	if res[nres-1] != targetRef {
+		st |= PartialResult
+	}
+	if npath-1+nres > len(path) {
+		st |= BufferTooSmall
+	}
+	return n, st
+}
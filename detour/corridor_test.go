@@ -0,0 +1,139 @@
+package detour
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func TestMergeCorridors(t *testing.T) {
+	tests := []struct {
+		msg        string
+		path, res  []PolyRef
+		max        int
+		want       []PolyRef
+		wantFailed bool
+	}{
+		{"append", []PolyRef{1, 2, 3}, []PolyRef{3, 4, 5}, 8, []PolyRef{1, 2, 3, 4, 5}, false},
+		{"trackback", []PolyRef{1, 2, 3}, []PolyRef{3, 2, 6}, 8, []PolyRef{1, 2, 6}, false},
+		{"double trackback", []PolyRef{1, 2, 3}, []PolyRef{3, 2, 1, 7}, 8, []PolyRef{1, 7}, false},
+		{"truncated", []PolyRef{1, 2, 3}, []PolyRef{3, 4, 5, 6}, 4, []PolyRef{1, 2, 3, 4}, false},
+		{"disjoint", []PolyRef{1, 2, 3}, []PolyRef{4, 5}, 8, []PolyRef{1, 2, 3}, true},
+	}
+	for _, tt := range tests {
+		path := make([]PolyRef, tt.max)
+		copy(path, tt.path)
+		n, ok := MergeCorridors(path, len(tt.path), tt.res)
+		if ok == tt.wantFailed || !reflect.DeepEqual(path[:n], tt.want) {
+			t.Errorf("%s: got %v, %t, want %v, %t", tt.msg, path[:n], ok, tt.want, !tt.wantFailed)
+		}
+	}
+}
+
+func TestMergeCorridorMoves(t *testing.T) {
+	tests := []struct {
+		msg           string
+		merge         func([]PolyRef, int, []PolyRef) int
+		path, visited []PolyRef
+		want          []PolyRef
+	}{
+		{"start moved forward", MergeCorridorStartMoved, []PolyRef{1, 2, 3, 4}, []PolyRef{1, 2, 3}, []PolyRef{3, 4}},
+		{"start moved out", MergeCorridorStartMoved, []PolyRef{1, 2, 3, 4}, []PolyRef{2, 8}, []PolyRef{8, 2, 3, 4}},
+		{"start moved back", MergeCorridorStartMoved, []PolyRef{1, 2, 3}, []PolyRef{1, 9}, []PolyRef{9, 1, 2, 3}},
+		{"start moved away", MergeCorridorStartMoved, []PolyRef{1, 2, 3}, []PolyRef{7, 8}, []PolyRef{1, 2, 3}},
+		{"end moved", MergeCorridorEndMoved, []PolyRef{1, 2, 3}, []PolyRef{3, 4, 5}, []PolyRef{1, 2, 3, 4, 5}},
+		{"end moved back", MergeCorridorEndMoved, []PolyRef{1, 2, 3}, []PolyRef{3, 2}, []PolyRef{1, 2}},
+		{"end moved away", MergeCorridorEndMoved, []PolyRef{1, 2, 3}, []PolyRef{7, 8}, []PolyRef{1, 2, 3}},
+		{"shortcut", MergeCorridorStartShortcut, []PolyRef{1, 2, 3, 4, 5}, []PolyRef{1, 9, 4}, []PolyRef{1, 9, 4, 5}},
+		{"no shortcut", MergeCorridorStartShortcut, []PolyRef{1, 2, 3}, []PolyRef{1}, []PolyRef{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		path := make([]PolyRef, 8)
+		copy(path, tt.path)
+		n := tt.merge(path, len(tt.path), tt.visited)
+		if !reflect.DeepEqual(path[:n], tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.msg, path[:n], tt.want)
+		}
+	}
+}
+
+func TestReplanPath(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 2048)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+
+	_, orgRef, org := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, filter)
+	_, dstRef, dst := q.FindNearestPoly(d3.Vec3{42.457218, 7.797607, 17.778244}, extents, filter)
+	path := make([]PolyRef, 256)
+	npath, _ := q.FindPath(orgRef, dstRef, org, dst, filter, path)
+	corridor := append([]PolyRef(nil), path[:npath]...)
+
+	// the target moves to the start of the corridor
+	n, st := q.ReplanPath(path, npath, dst, corridor[1], org, filter, 10)
+	if StatusFailed(st) || !reflect.DeepEqual(path[:n], corridor[:2]) {
+		t.Errorf("got %v, status 0x%x, want %v", path[:n], st, corridor[:2])
+	}
+
+	// the target moves to the neighbour of a polygon of the corridor
+	var (
+		newRef PolyRef
+		newPos d3.Vec3
+	)
+	for i := len(corridor) - 1; i >= 0 && newRef == 0; i-- {
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		mesh.TileAndPolyByRefUnsafe(corridor[i], &tile, &poly)
+		for l := poly.FirstLink; l != nullLink; l = tile.Links[l].Next {
+			if ref := tile.Links[l].Ref; !containsRef(corridor, ref) {
+				newRef = ref
+				newPos, _ = q.PolyCentroid(ref)
+				break
+			}
+		}
+	}
+	if newRef == 0 {
+		t.Fatal("no polygon found next to the corridor")
+	}
+
+	copy(path, corridor)
+	n, st = q.ReplanPath(path, len(corridor), dst, newRef, newPos, filter, 100)
+	if StatusFailed(st) || StatusDetail(st, PartialResult) {
+		t.Fatalf("ReplanPath failed with status 0x%x", st)
+	}
+	got := path[:n]
+	if got[0] != orgRef || got[n-1] != newRef {
+		t.Fatalf("got corridor %v, want a corridor from 0x%x to 0x%x", got, orgRef, newRef)
+	}
+	for i := 0; i+2 < n; i++ {
+		if got[i] == got[i+2] {
+			t.Errorf("corridor %v has a trackback at %d", got, i)
+		}
+	}
+	for i := 0; i+1 < n; i++ {
+		var fromType, toType uint8
+		if StatusFailed(q.portalPoints6(got[i], got[i+1], d3.NewVec3(), d3.NewVec3(), &fromType, &toType)) {
+			t.Errorf("polygons 0x%x and 0x%x of the corridor are not neighbours", got[i], got[i+1])
+		}
+	}
+
+	// not enough iterations to reach a far target
+	copy(path, corridor[:1])
+	_, st = q.ReplanPath(path, 1, org, dstRef, dst, filter, 1)
+	if StatusFailed(st) || !StatusDetail(st, PartialResult) {
+		t.Errorf("ReplanPath with a single iteration should return a partial result, got status 0x%x", st)
+	}
+}
+
+func containsRef(refs []PolyRef, ref PolyRef) bool {
+	for _, r := range refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
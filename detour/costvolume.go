@@ -0,0 +1,274 @@
+package detour
+
+import (
+	"math"
+
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+// CostVolumeFilter is a QueryFilter that multiplies the traversal costs of
+// another filter inside a set of temporary convex volumes.
+//
+// Cost volumes bias pathfinding at runtime (E.g. to make agents avoid fire or
+// danger zones) without modifying the polygon areas of the navigation mesh.
+// They are added with NavMeshQuery.AddCostVolume, which resolves the set of
+// polygons touched by the volume once, and can be removed at any time.
+//
+// The polygon set of a volume is not updated when tiles are added or removed
+// from the navigation mesh, in which case the volume should be added again.
+//
+// see QueryFilter, NavMeshQuery.AddCostVolume
+type CostVolumeFilter struct {
+	// The filter which costs are biased.
+	QueryFilter
+
+	volumes []costVolume
+	nextID  int
+
+	// Cost multiplier per polygon, combining all the volumes.
+	mult map[PolyRef]float32
+}
+
+// costVolume is a cost volume, as registered on a CostVolumeFilter.
+type costVolume struct {
+	id    int
+	cost  float32
+	polys []PolyRef
+}
+
+// NewCostVolumeFilter returns a new cost volume filter with no volumes,
+// wrapping the base filter.
+func NewCostVolumeFilter(base QueryFilter) *CostVolumeFilter {
+	return &CostVolumeFilter{
+		QueryFilter: base,
+		nextID:      1,
+		mult:        make(map[PolyRef]float32),
+	}
+}
+
+// Cost returns cost to move from the beginning to the end of a line segment
+// that is fully contained within a polygon.
+//
+// It is the cost returned by the wrapped filter, multiplied by the cost of all
+// the volumes touching the current polygon.
+//
+// see QueryFilter.Cost
+func (f *CostVolumeFilter) Cost(pa, pb d3.Vec3,
+	prevRef PolyRef, prevTile *MeshTile, prevPoly *Poly,
+	curRef PolyRef, curTile *MeshTile, curPoly *Poly,
+	nextRef PolyRef, nextTile *MeshTile, nextPoly *Poly) float32 {
+
+	cost := f.QueryFilter.Cost(pa, pb,
+		prevRef, prevTile, prevPoly,
+		curRef, curTile, curPoly,
+		nextRef, nextTile, nextPoly)
+	if m, ok := f.mult[curRef]; ok {
+		cost *= m
+	}
+	return cost
+}
+
+// CostMultiplier returns the combined cost multiplier of all the volumes
+// touching a polygon, 1 if there are none.
+func (f *CostVolumeFilter) CostMultiplier(ref PolyRef) float32 {
+	if m, ok := f.mult[ref]; ok {
+		return m
+	}
+	return 1
+}
+
+// RemoveCostVolume removes the cost volume which identifier is id. It returns
+// false if there is no such volume.
+func (f *CostVolumeFilter) RemoveCostVolume(id int) bool {
+	for i := range f.volumes {
+		if f.volumes[i].id != id {
+			continue
+		}
+		f.volumes = append(f.volumes[:i], f.volumes[i+1:]...)
+		f.updateMultipliers()
+		return true
+	}
+	return false
+}
+
+// ClearCostVolumes removes all the cost volumes.
+func (f *CostVolumeFilter) ClearCostVolumes() {
+	f.volumes = nil
+	f.mult = make(map[PolyRef]float32)
+}
+
+// updateMultipliers recomputes the per polygon cost multipliers from the
+// volumes.
+func (f *CostVolumeFilter) updateMultipliers() {
+	f.mult = make(map[PolyRef]float32)
+	for i := range f.volumes {
+		f.addMultipliers(&f.volumes[i])
+	}
+}
+
+func (f *CostVolumeFilter) addMultipliers(vol *costVolume) {
+	for _, ref := range vol.polys {
+		if m, ok := f.mult[ref]; ok {
+			f.mult[ref] = m * vol.cost
+		} else {
+			f.mult[ref] = vol.cost
+		}
+	}
+}
+
+// acceptPolysQuery is the polyQuery collecting all the polygons accepted by a
+// function.
+type acceptPolysQuery struct {
+	accept func(tile *MeshTile, poly *Poly) bool
+	refs   []PolyRef
+}
+
+func (q *acceptPolysQuery) process(tile *MeshTile, polys []*Poly, refs []PolyRef, count int32) {
+	for i := int32(0); i < count; i++ {
+		if q.accept(tile, polys[i]) {
+			q.refs = append(q.refs, refs[i])
+		}
+	}
+}
+
+// AddCostVolume registers a convex cost volume on a cost volume filter.
+//
+//	Arguments:
+//	 f       The filter to add the volume to.
+//	 verts   The vertices of the convex polygon defining the volume, in the
+//	         xz-plane. [(x, y, z) * nverts]
+//	 hmin    The minimum height of the volume.
+//	 hmax    The maximum height of the volume.
+//	 cost    The cost multiplier to apply to the polygons touched by the
+//	         volume. [Limit: >= 1]
+//
+//	Return values:
+//	 id      The identifier of the volume, to be passed to
+//	         CostVolumeFilter.RemoveCostVolume.
+//	 st      The status flags for the query.
+//
+// The polygons touched by the volume are the ones overlapping the convex
+// polygon in the xz-plane, whose vertical extent overlaps [hmin, hmax], and
+// that pass the filter wrapped by f.
+//
+// As for any QueryFilter, a cost multiplier less than 1 is likely to lead to
+// problems during pathfinding.
+func (q *NavMeshQuery) AddCostVolume(f *CostVolumeFilter, verts []float32, hmin, hmax, cost float32) (id int, st Status) {
+	nverts := len(verts) / 3
+	if f == nil || f.QueryFilter == nil || nverts < 3 || hmin > hmax ||
+		math32.IsNaN(cost) || math32.IsInf(cost, 0) || cost < 1 {
+		return 0, Failure | InvalidParam
+	}
+	for i := 0; i < nverts*3; i++ {
+		if math32.IsNaN(verts[i]) || math32.IsInf(verts[i], 0) {
+			return 0, Failure | InvalidParam
+		}
+	}
+
+	// Compute the volume bounds.
+	bmin := d3.NewVec3XYZ(verts[0], hmin, verts[2])
+	bmax := d3.NewVec3XYZ(verts[0], hmax, verts[2])
+	for i := 1; i < nverts; i++ {
+		bmin[0] = math32.Min(bmin[0], verts[i*3])
+		bmin[2] = math32.Min(bmin[2], verts[i*3+2])
+		bmax[0] = math32.Max(bmax[0], verts[i*3])
+		bmax[2] = math32.Max(bmax[2], verts[i*3+2])
+	}
+
+	var (
+		pverts     [VertsPerPolygon * 3]float32
+		pmin, pmax = d3.NewVec3(), d3.NewVec3()
+	)
+	query := &acceptPolysQuery{
+		accept: func(tile *MeshTile, poly *Poly) bool {
+			polyBounds(tile, poly, pmin, pmax)
+			if pmin[1] > hmax || pmax[1] < hmin {
+				return false
+			}
+			nv := int(poly.VertCount)
+			for i := 0; i < nv; i++ {
+				vi := poly.Verts[i] * 3
+				copy(pverts[i*3:i*3+3], tile.Verts[vi:vi+3])
+			}
+			return overlapPolyPoly2D(verts, nverts, pverts[:], nv)
+		},
+	}
+	center := bmin.Lerp(bmax, 0.5)
+	st = q.queryPolygons4(center, bmax.Sub(center), f.QueryFilter, query)
+	if StatusFailed(st) {
+		return 0, st
+	}
+
+	vol := costVolume{id: f.nextID, cost: cost, polys: query.refs}
+	f.nextID++
+	f.volumes = append(f.volumes, vol)
+	f.addMultipliers(&vol)
+	return vol.id, Success
+}
+
+// AddOffMeshConnectionCosts registers cost volumes around the endpoints of
+// the off-mesh connections that can't be traversed with a cost volume filter.
+//
+//	Arguments:
+//	 f       The filter to add the volumes to.
+//	 radius  The radius of the volumes, around each endpoint. [Limit: > 0]
+//	 cost    The cost multiplier to apply to the polygons touched by the
+//	         volumes. [Limit: >= 1]
+//
+//	Return values:
+//	 ids     The identifiers of the added volumes.
+//	 st      The status flags for the query.
+//
+// Agents that can't use a connection have no reason to path through its
+// entrances, where the agents that can use it queue. Biasing the cost of the
+// polygons around those entrances keeps agents with different capabilities
+// from jamming at jump points.
+//
+// A connection can't be traversed if its polygon doesn't pass the filter
+// wrapped by f.
+func (q *NavMeshQuery) AddOffMeshConnectionCosts(f *CostVolumeFilter, radius, cost float32) (ids []int, st Status) {
+	if f == nil || f.QueryFilter == nil || !(radius > 0) || math32.IsInf(radius, 0) {
+		return nil, Failure | InvalidParam
+	}
+
+	// Approximate the circle around an endpoint with an octagon enclosing it.
+	const nverts = 8
+	var verts [nverts * 3]float32
+	r := radius / math32.Cos(math.Pi/nverts)
+
+	for i := int32(0); i < q.nav.MaxTiles; i++ {
+		tile := &q.nav.Tiles[i]
+		if tile.Header == nil {
+			continue
+		}
+		base := q.nav.polyRefBase(tile)
+		for j := range tile.OffMeshCons {
+			con := &tile.OffMeshCons[j]
+			ref := base | PolyRef(con.Poly)
+			if f.PassFilter(ref, tile, &tile.Polys[con.Poly]) {
+				continue
+			}
+			for k := 0; k < 2; k++ {
+				pos := con.Pos[k*3 : k*3+3]
+				for v := 0; v < nverts; v++ {
+					a := float32(v) * 2 * math.Pi / nverts
+					verts[v*3] = pos[0] + r*math32.Cos(a)
+					verts[v*3+1] = pos[1]
+					verts[v*3+2] = pos[2] + r*math32.Sin(a)
+				}
+				hmin := pos[1] - tile.Header.WalkableHeight
+				hmax := pos[1] + tile.Header.WalkableHeight
+				id, st := q.AddCostVolume(f, verts[:], hmin, hmax, cost)
+				if StatusFailed(st) {
+					for _, id := range ids {
+						f.RemoveCostVolume(id)
+					}
+					return nil, st
+				}
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, Success
+}
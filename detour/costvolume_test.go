@@ -0,0 +1,160 @@
+package detour
+
+import (
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func TestCostVolumeFilter(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	st, q := NewNavMeshQuery(mesh, 1000)
+	if StatusFailed(st) {
+		t.Fatalf("query creation failed with status 0x%x", st)
+	}
+
+	filter := NewCostVolumeFilter(NewStandardQueryFilter())
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	_, orgRef, org := q.FindNearestPoly(d3.Vec3{19.966858, 0, 33.455986}, extents, filter)
+	_, dstRef, dst := q.FindNearestPoly(d3.Vec3{14.059669, 0, 14.318811}, extents, filter)
+
+	// findPath returns whether the path from org to dst goes through ref.
+	findPath := func(ref PolyRef) bool {
+		path := make([]PolyRef, 100)
+		n, st := q.FindPath(orgRef, dstRef, org, dst, filter, path)
+		if StatusFailed(st) {
+			t.Fatalf("FindPath failed with status 0x%x", st)
+		}
+		for _, r := range path[:n] {
+			if r == ref {
+				return true
+			}
+		}
+		return false
+	}
+
+	// a small volume, only touching a polygon of the shortest path
+	const avoided = PolyRef(0x540002)
+	verts := []float32{
+		20.35, 0, 20.8,
+		20.75, 0, 20.8,
+		20.75, 0, 21.2,
+		20.35, 0, 21.2,
+	}
+
+	if !findPath(avoided) {
+		t.Fatalf("path should go through poly 0x%x without cost volume", avoided)
+	}
+
+	id, st := q.AddCostVolume(filter, verts, -1, 1, 100)
+	if StatusFailed(st) {
+		t.Fatalf("AddCostVolume failed with status 0x%x", st)
+	}
+	if m := filter.CostMultiplier(avoided); m != 100 {
+		t.Errorf("CostMultiplier(0x%x) = %f, want 100", avoided, m)
+	}
+	if findPath(avoided) {
+		t.Errorf("path should avoid poly 0x%x with a cost volume", avoided)
+	}
+
+	// volumes touching the same polygon combine their costs
+	id2, _ := q.AddCostVolume(filter, verts, -1, 1, 2)
+	if m := filter.CostMultiplier(avoided); m != 200 {
+		t.Errorf("CostMultiplier(0x%x) = %f, want 200", avoided, m)
+	}
+
+	if !filter.RemoveCostVolume(id) {
+		t.Errorf("RemoveCostVolume(%d) should succeed", id)
+	}
+	if filter.RemoveCostVolume(id) {
+		t.Errorf("RemoveCostVolume(%d) should fail for a removed volume", id)
+	}
+	if m := filter.CostMultiplier(avoided); m != 2 {
+		t.Errorf("CostMultiplier(0x%x) = %f, want 2", avoided, m)
+	}
+
+	filter.RemoveCostVolume(id2)
+	if !findPath(avoided) {
+		t.Errorf("path should go through poly 0x%x once volumes are removed", avoided)
+	}
+
+	// a volume above the polygons touches nothing
+	q.AddCostVolume(filter, verts, 10, 11, 100)
+	if m := filter.CostMultiplier(avoided); m != 1 {
+		t.Errorf("CostMultiplier(0x%x) = %f, want 1", avoided, m)
+	}
+	filter.ClearCostVolumes()
+
+	// invalid volumes
+	if _, st := q.AddCostVolume(filter, verts[:6], -1, 1, 100); !StatusDetail(st, InvalidParam) {
+		t.Errorf("AddCostVolume with 2 vertices should fail with InvalidParam, got 0x%x", st)
+	}
+	if _, st := q.AddCostVolume(filter, verts, -1, 1, 0.5); !StatusDetail(st, InvalidParam) {
+		t.Errorf("AddCostVolume with cost < 1 should fail with InvalidParam, got 0x%x", st)
+	}
+}
+
+// noOffMeshFilter is a filter excluding all the off-mesh connections.
+type noOffMeshFilter struct {
+	*StandardQueryFilter
+}
+
+func (f noOffMeshFilter) PassFilter(ref PolyRef, tile *MeshTile, poly *Poly) bool {
+	return poly.Type() != polyTypeOffMeshConnection && f.StandardQueryFilter.PassFilter(ref, tile, poly)
+}
+
+func TestAddOffMeshConnectionCosts(t *testing.T) {
+	mesh, err := loadTestNavMesh("offmeshcons.bin")
+	checkt(t, err)
+
+	st, q := NewNavMeshQuery(mesh, 1000)
+	if StatusFailed(st) {
+		t.Fatalf("query creation failed with status 0x%x", st)
+	}
+
+	var cons []*OffMeshConnection
+	for i := int32(0); i < mesh.MaxTiles; i++ {
+		for j := range mesh.Tiles[i].OffMeshCons {
+			cons = append(cons, &mesh.Tiles[i].OffMeshCons[j])
+		}
+	}
+	if len(cons) == 0 {
+		t.Fatalf("test navmesh should have off-mesh connections")
+	}
+
+	// all connections pass the standard filter, no volumes get added
+	filter := NewCostVolumeFilter(NewStandardQueryFilter())
+	ids, st := q.AddOffMeshConnectionCosts(filter, 1, 10)
+	if StatusFailed(st) || len(ids) != 0 {
+		t.Fatalf("got %d volumes and status 0x%x, want none and success", len(ids), st)
+	}
+
+	filter = NewCostVolumeFilter(noOffMeshFilter{NewStandardQueryFilter()})
+	ids, st = q.AddOffMeshConnectionCosts(filter, 1, 10)
+	if StatusFailed(st) {
+		t.Fatalf("AddOffMeshConnectionCosts failed with status 0x%x", st)
+	}
+	if len(ids) != 2*len(cons) {
+		t.Fatalf("got %d volumes, want 2 per connection (%d)", len(ids), 2*len(cons))
+	}
+
+	// the polygons under the connection endpoints get a higher cost
+	extents := d3.NewVec3XYZ(1, 2, 1)
+	for _, con := range cons {
+		for k := 0; k < 2; k++ {
+			_, ref, _ := q.FindNearestPoly(con.Pos[k*3:k*3+3], extents, filter)
+			if ref == 0 {
+				continue
+			}
+			if m := filter.CostMultiplier(ref); m < 10 {
+				t.Errorf("CostMultiplier(0x%x) = %f, want at least 10", ref, m)
+			}
+		}
+	}
+
+	if _, st := q.AddOffMeshConnectionCosts(filter, 0, 10); !StatusDetail(st, InvalidParam) {
+		t.Errorf("AddOffMeshConnectionCosts with null radius should fail with InvalidParam, got 0x%x", st)
+	}
+}
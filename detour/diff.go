@@ -0,0 +1,258 @@
+package detour
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/arl/math32"
+)
+
+// Difference is a difference between two navigation meshes found by
+// DiffNavMesh.
+type Difference struct {
+	X, Y, Layer int32  // The location of the tile in the tile grid, or -1 for mesh parameters.
+	Poly        int32  // The index of the polygon in the tile, or -1.
+	Msg         string // The description of the difference.
+}
+
+func (d Difference) String() string {
+	switch {
+	case d.Layer < 0:
+		return fmt.Sprintf("navmesh: %s", d.Msg)
+	case d.Poly < 0:
+		return fmt.Sprintf("tile (%d, %d, %d): %s", d.X, d.Y, d.Layer, d.Msg)
+	}
+	return fmt.Sprintf("tile (%d, %d, %d): polygon %d: %s", d.X, d.Y, d.Layer, d.Poly, d.Msg)
+}
+
+// tileLoc is the location of a tile in the tile grid.
+type tileLoc struct {
+	x, y, layer int32
+}
+
+// less orders tile locations by row, column then layer.
+func (l tileLoc) less(o tileLoc) bool {
+	if l.y != o.y {
+		return l.y < o.y
+	}
+	if l.x != o.x {
+		return l.x < o.x
+	}
+	return l.layer < o.layer
+}
+
+// DiffNavMesh compares the structure of two navigation meshes and returns the
+// differences found, or nil if they are equivalent.
+//
+//	Arguments:
+//	 a, b     The navigation meshes to compare.
+//	 tol      The maximum distance, along each axis, between 2 vertices
+//	          considered equal.
+//
+// The tiles are matched by location. For each tile present in both meshes,
+// DiffNavMesh compares the polygon, vertex and off-mesh connection counts. If
+// the tiles have the same number of polygons, it then compares, polygon by
+// polygon, the area, the type, the flags, the vertices and the connectivity:
+// the internal neighbours and the polygons linked to, identified by their tile
+// location and index so that salts and tile indices don't matter. Otherwise
+// the number of polygons of each area is compared.
+//
+// DiffNavMesh is meant to detect regressions between 2 builds of the same
+// input geometry, E.g. after changing the build settings or upgrading this
+// package. The detail meshes and the bounding volume trees are not compared.
+//
+// returned error will be different from nil if a navmesh is not initialized.
+func DiffNavMesh(a, b *NavMesh, tol float32) ([]Difference, error) {
+	for _, m := range []*NavMesh{a, b} {
+		if m == nil || m.MaxTiles == 0 || len(m.Tiles) == 0 {
+			return nil, fmt.Errorf("navmesh is not initialized")
+		}
+	}
+
+	var diffs []Difference
+	if a.Params.Orig != b.Params.Orig {
+		diffs = append(diffs, Difference{-1, -1, -1, -1,
+			fmt.Sprintf("origin %v != %v", a.Params.Orig, b.Params.Orig)})
+	}
+	if a.Params.TileWidth != b.Params.TileWidth || a.Params.TileHeight != b.Params.TileHeight {
+		diffs = append(diffs, Difference{-1, -1, -1, -1,
+			fmt.Sprintf("tile size %vx%v != %vx%v",
+				a.Params.TileWidth, a.Params.TileHeight, b.Params.TileWidth, b.Params.TileHeight)})
+	}
+
+	tilesA, tilesB := tilesByLoc(a), tilesByLoc(b)
+	locs := make([]tileLoc, 0, len(tilesA))
+	for loc := range tilesA {
+		locs = append(locs, loc)
+	}
+	for loc := range tilesB {
+		if _, ok := tilesA[loc]; !ok {
+			locs = append(locs, loc)
+		}
+	}
+	sort.Slice(locs, func(i, j int) bool { return locs[i].less(locs[j]) })
+
+	for _, loc := range locs {
+		report := func(poly int32, format string, args ...interface{}) {
+			diffs = append(diffs, Difference{
+				X:     loc.x,
+				Y:     loc.y,
+				Layer: loc.layer,
+				Poly:  poly,
+				Msg:   fmt.Sprintf(format, args...),
+			})
+		}
+
+		ta, tb := tilesA[loc], tilesB[loc]
+		switch {
+		case tb == nil:
+			report(-1, "only in first navmesh")
+		case ta == nil:
+			report(-1, "only in second navmesh")
+		default:
+			diffTiles(a, b, ta, tb, tol, report)
+		}
+	}
+	return diffs, nil
+}
+
+// tilesByLoc returns the loaded tiles of m, indexed by location.
+func tilesByLoc(m *NavMesh) map[tileLoc]*MeshTile {
+	tiles := make(map[tileLoc]*MeshTile)
+	for i := range m.Tiles {
+		if hdr := m.Tiles[i].Header; hdr != nil {
+			tiles[tileLoc{hdr.X, hdr.Y, hdr.Layer}] = &m.Tiles[i]
+		}
+	}
+	return tiles
+}
+
+// diffTiles compares 2 tiles at the same location.
+func diffTiles(ma, mb *NavMesh, ta, tb *MeshTile, tol float32, report func(poly int32, format string, args ...interface{})) {
+	ha, hb := ta.Header, tb.Header
+	for _, c := range []struct {
+		name string
+		a, b int32
+	}{
+		{"polygons", ha.PolyCount, hb.PolyCount},
+		{"vertices", ha.VertCount, hb.VertCount},
+		{"off-mesh connections", ha.OffMeshConCount, hb.OffMeshConCount},
+	} {
+		if c.a != c.b {
+			report(-1, "%d %s != %d", c.a, c.name, c.b)
+		}
+	}
+
+	if ha.PolyCount != hb.PolyCount {
+		// Polygons can't be matched, compare the areas.
+		var areasA, areasB [maxAreas]int32
+		for i := int32(0); i < ha.PolyCount; i++ {
+			areasA[ta.Polys[i].Area()]++
+		}
+		for i := int32(0); i < hb.PolyCount; i++ {
+			areasB[tb.Polys[i].Area()]++
+		}
+		for area := range areasA {
+			if areasA[area] != areasB[area] {
+				report(-1, "%d polygons of area %d != %d", areasA[area], area, areasB[area])
+			}
+		}
+		return
+	}
+
+	var linksA, linksB []polyLink
+	for i := int32(0); i < ha.PolyCount; i++ {
+		pa, pb := &ta.Polys[i], &tb.Polys[i]
+		if pa.Area() != pb.Area() {
+			report(i, "area %d != %d", pa.Area(), pb.Area())
+		}
+		if pa.Type() != pb.Type() {
+			report(i, "type %d != %d", pa.Type(), pb.Type())
+		}
+		if pa.Flags != pb.Flags {
+			report(i, "flags 0x%x != 0x%x", pa.Flags, pb.Flags)
+		}
+		if pa.VertCount != pb.VertCount {
+			report(i, "%d vertices != %d", pa.VertCount, pb.VertCount)
+			continue
+		}
+
+		for j := uint8(0); j < pa.VertCount; j++ {
+			va := ta.Verts[int(pa.Verts[j])*3:]
+			vb := tb.Verts[int(pb.Verts[j])*3:]
+			for k := 0; k < 3; k++ {
+				if d := math32.Abs(va[k] - vb[k]); d > tol {
+					report(i, "vertex %d: (%v, %v, %v) != (%v, %v, %v)", j,
+						va[0], va[1], va[2], vb[0], vb[1], vb[2])
+					break
+				}
+			}
+			if pa.Neis[j] != pb.Neis[j] {
+				report(i, "edge %d: neighbour 0x%x != 0x%x", j, pa.Neis[j], pb.Neis[j])
+			}
+		}
+
+		linksA = ma.polyLinks(ta, pa, linksA[:0])
+		linksB = mb.polyLinks(tb, pb, linksB[:0])
+		if !equalLinks(linksA, linksB) {
+			report(i, "links %v != %v", linksA, linksB)
+		}
+	}
+}
+
+// polyLink identifies the target of a link independently of the salts and of
+// the tile indices.
+type polyLink struct {
+	loc  tileLoc
+	poly uint32
+	edge uint8
+}
+
+func (l polyLink) String() string {
+	return fmt.Sprintf("(%d, %d, %d):%d", l.loc.x, l.loc.y, l.loc.layer, l.poly)
+}
+
+// polyLinks appends the links of a polygon to links, sorted, and returns the
+// extended slice.
+func (m *NavMesh) polyLinks(tile *MeshTile, poly *Poly, links []polyLink) []polyLink {
+	count := int32(0)
+	for l := poly.FirstLink; l != nullLink && l < uint32(len(tile.Links)); l = tile.Links[l].Next {
+		link := &tile.Links[l]
+		it := m.decodePolyIDTile(link.Ref)
+		if it < uint32(len(m.Tiles)) && m.Tiles[it].Header != nil {
+			hdr := m.Tiles[it].Header
+			links = append(links, polyLink{
+				loc:  tileLoc{hdr.X, hdr.Y, hdr.Layer},
+				poly: m.decodePolyIDPoly(link.Ref),
+				edge: link.Edge,
+			})
+		}
+		if count++; count > tile.Header.MaxLinkCount {
+			break
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		li, lj := links[i], links[j]
+		switch {
+		case li.loc != lj.loc:
+			return li.loc.less(lj.loc)
+		case li.poly != lj.poly:
+			return li.poly < lj.poly
+		}
+		return li.edge < lj.edge
+	})
+	return links
+}
+
+// equalLinks reports whether 2 sorted link lists are equal.
+func equalLinks(a, b []polyLink) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package detour
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffNavMesh(t *testing.T) {
+	for _, fname := range []string{"mesh1.bin", "offmeshcons.bin", "fixture/tiled.bin"} {
+		a, err := loadTestNavMesh(fname)
+		checkt(t, err)
+		b, err := loadTestNavMesh(fname)
+		checkt(t, err)
+		diffs, err := DiffNavMesh(a, b, 0)
+		checkt(t, err)
+		if len(diffs) != 0 {
+			t.Errorf("%v: want no differences, got %v", fname, diffs)
+		}
+	}
+
+	if _, err := DiffNavMesh(&NavMesh{}, &NavMesh{}, 0); err == nil {
+		t.Errorf("want an error with uninitialized navmeshes")
+	}
+}
+
+func TestDiffNavMeshChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		tol    float32
+		change func(mesh *NavMesh, tile *MeshTile)
+		want   string // empty if no difference should be found
+	}{
+		{
+			"area",
+			0,
+			func(mesh *NavMesh, tile *MeshTile) { tile.Polys[3].SetArea(tile.Polys[3].Area() + 1) },
+			"polygon 3: area",
+		},
+		{
+			"flags",
+			0,
+			func(mesh *NavMesh, tile *MeshTile) { tile.Polys[2].Flags ^= 0x8 },
+			"polygon 2: flags",
+		},
+		{
+			"vertex out of tolerance",
+			0.1,
+			func(mesh *NavMesh, tile *MeshTile) { tile.Verts[tile.Polys[0].Verts[1]*3+1] += 0.2 },
+			"polygon 0: vertex 1",
+		},
+		{
+			"vertex within tolerance",
+			0.1,
+			func(mesh *NavMesh, tile *MeshTile) { tile.Verts[tile.Polys[0].Verts[1]*3+1] += 0.05 },
+			"",
+		},
+		{
+			"removed tile",
+			0,
+			func(mesh *NavMesh, tile *MeshTile) { mesh.RemoveTile(mesh.TileRef(tile)) },
+			"only in first navmesh",
+		},
+		{
+			"links",
+			0,
+			func(mesh *NavMesh, tile *MeshTile) {
+				// drop the first link of polygon 0
+				l := tile.Polys[0].FirstLink
+				tile.Polys[0].FirstLink = tile.Links[l].Next
+			},
+			"polygon 0: links",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := loadTestNavMesh("mesh2.bin")
+			checkt(t, err)
+			b, err := loadTestNavMesh("mesh2.bin")
+			checkt(t, err)
+
+			var tile *MeshTile
+			for i := range b.Tiles {
+				if b.Tiles[i].Header != nil && b.Tiles[i].Header.PolyCount > 3 {
+					tile = &b.Tiles[i]
+					break
+				}
+			}
+			tt.change(b, tile)
+
+			diffs, err := DiffNavMesh(a, b, tt.tol)
+			checkt(t, err)
+			if tt.want == "" {
+				if len(diffs) != 0 {
+					t.Errorf("want no differences, got %v", diffs)
+				}
+				return
+			}
+			if len(diffs) == 0 {
+				t.Fatalf("want a difference containing %q, got none", tt.want)
+			}
+			if s := diffs[0].String(); !strings.Contains(s, tt.want) {
+				t.Errorf("want a difference containing %q, got %q", tt.want, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Package detour defines the navigation mesh, built from the mesh data created
+// by the recast package, and the queries that can be run on it.
+//
+// The main types are:
+//
+//   - NavMesh, the navigation mesh, made of one or more tiles.
+//   - NavMeshQuery, providing pathfinding and spatial queries on a NavMesh.
+//   - QueryFilter, defining which polygons can be traversed and at what cost.
+//
+// # Error handling
+//
+// Exported functions and methods validate their inputs and report failures
+// through their returned Status (E.g. Failure|InvalidParam for an invalid
+// polygon reference, a nil or too short vector, or corrupted tile data), they
+// do not panic. Results should be checked with StatusFailed or StatusSucceed
+// before being used.
+//
+// The only exceptions are the Serialize methods, for which an undersized
+// destination buffer is considered a programming error.
+//
+// # Binary format
+//
+// NavMesh.Encode, Decode and DecodeIndex use the binary layout of the
+// navigation meshes saved by the Sample_TileMesh sample of RecastDemo, the
+// original C++ editor. So the navigation meshes baked in RecastDemo can be
+// loaded in Go, and vice versa. The layout is, in little-endian:
+//
+//   - a 40 bytes set header: 'MSET' magic, version 1, the number of tiles and
+//     the NavMeshParams.
+//   - for each tile, an 8 bytes tile header: the tile reference and the size
+//     of the tile data, followed by the tile data.
+//   - the tile data is laid out as CreateNavMeshData produces it, the same way
+//     as dtCreateNavMeshData does, C struct padding included: a 100 bytes
+//     MeshHeader ('DNAV' magic, version 7) then the vertices, polygons, links,
+//     detail meshes, detail vertices, detail triangles, BV tree nodes and
+//     off-mesh connections.
+//
+// Every field is explicitly encoded, one by one, so the format doesn't depend on
+// the platform struct padding, alignment or word size.
+//
+// Polygon references are 32 bits wide, navigation meshes built by Detour with
+// DT_POLYREF64 are not supported. Links are runtime data, rebuilt when a tile
+// is added, so the links of the tiles of a multi-tile navigation mesh may not
+// be saved byte for byte as they were read.
+package detour
@@ -0,0 +1,90 @@
+package detour
+
+// NavMeshListener holds the functions called when a navigation mesh is
+// modified at runtime. The functions are optional.
+//
+// This lets the systems depending on a navigation mesh (path caches, debug
+// draw overlays...) react to its changes instead of polling it.
+//
+// see NavMesh.Subscribe
+type NavMeshListener struct {
+	// OnTileAdded is called after a tile has been added, and connected to its
+	// neighbours.
+	OnTileAdded func(ref TileRef, tile *MeshTile)
+
+	// OnTileRemoved is called before a tile is removed, while its polygons
+	// and links are still valid.
+	OnTileRemoved func(ref TileRef, tile *MeshTile)
+
+	// OnPolyFlagsChanged is called after the flags of a polygon have been
+	// modified, with their previous and new values.
+	OnPolyFlagsChanged func(ref PolyRef, oldFlags, newFlags uint16)
+}
+
+// navMeshListener is a listener, as registered on a NavMesh.
+type navMeshListener struct {
+	id int
+	NavMeshListener
+}
+
+// Subscribe registers a listener of the navigation mesh modifications, and
+// returns its identifier.
+//
+// The functions of the listeners are called synchronously, in the order the
+// listeners were registered, by the methods modifying the navigation mesh:
+// AddTile, RemoveTile, SetPolyFlags, SetFlagsForArea, RestorePolys,
+// NavMeshQuery.PaintBox, NavMeshQuery.PaintCircle and PruneUnreachable. They
+// may not modify the navigation mesh.
+//
+// The flags modified directly through the Poly structs aren't notified.
+func (m *NavMesh) Subscribe(l NavMeshListener) (id int) {
+	m.nextListenerID++
+	m.listeners = append(m.listeners, navMeshListener{id: m.nextListenerID, NavMeshListener: l})
+	return m.nextListenerID
+}
+
+// Unsubscribe removes the listener which identifier is id. It returns false if
+// there is no such listener.
+func (m *NavMesh) Unsubscribe(id int) bool {
+	for i := range m.listeners {
+		if m.listeners[i].id != id {
+			continue
+		}
+		m.listeners = append(m.listeners[:i], m.listeners[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// tileAdded notifies the listeners that a tile has been added.
+func (m *NavMesh) tileAdded(ref TileRef, tile *MeshTile) {
+	for _, l := range m.listeners {
+		if l.OnTileAdded != nil {
+			l.OnTileAdded(ref, tile)
+		}
+	}
+}
+
+// tileRemoved notifies the listeners that a tile is about to be removed.
+func (m *NavMesh) tileRemoved(ref TileRef, tile *MeshTile) {
+	for _, l := range m.listeners {
+		if l.OnTileRemoved != nil {
+			l.OnTileRemoved(ref, tile)
+		}
+	}
+}
+
+// setPolyFlags sets the flags of a polygon, and notifies the listeners if
+// they changed.
+func (m *NavMesh) setPolyFlags(ref PolyRef, poly *Poly, flags uint16) {
+	old := poly.Flags
+	if old == flags {
+		return
+	}
+	poly.Flags = flags
+	for _, l := range m.listeners {
+		if l.OnPolyFlagsChanged != nil {
+			l.OnPolyFlagsChanged(ref, old, flags)
+		}
+	}
+}
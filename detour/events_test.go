@@ -0,0 +1,76 @@
+package detour
+
+import "testing"
+
+func TestNavMeshListener(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	var (
+		added, removed []TileRef
+		flags          []PolyRef
+	)
+	id := mesh.Subscribe(NavMeshListener{
+		OnTileAdded: func(ref TileRef, tile *MeshTile) {
+			if mesh.TileByRef(ref) != tile {
+				t.Errorf("OnTileAdded: tile 0x%x isn't in the navmesh", ref)
+			}
+			added = append(added, ref)
+		},
+		OnTileRemoved: func(ref TileRef, tile *MeshTile) {
+			if tile.Header == nil || mesh.TileByRef(ref) != tile {
+				t.Errorf("OnTileRemoved: tile 0x%x should still be valid", ref)
+			}
+			removed = append(removed, ref)
+		},
+		OnPolyFlagsChanged: func(ref PolyRef, oldFlags, newFlags uint16) {
+			if f, _ := mesh.PolyFlags(ref); f != newFlags || oldFlags == newFlags {
+				t.Errorf("OnPolyFlagsChanged(0x%x, 0x%x, 0x%x): polygon flags are 0x%x", ref, oldFlags, newFlags, f)
+			}
+			flags = append(flags, ref)
+		},
+	})
+
+	// Tiles
+	tref := mesh.TileRef(mesh.Tile(0))
+	data, st := mesh.RemoveTile(tref)
+	if StatusFailed(st) {
+		t.Fatalf("RemoveTile failed with status 0x%x", st)
+	}
+	st, newRef := mesh.AddTile(data, 0)
+	if StatusFailed(st) {
+		t.Fatalf("AddTile failed with status 0x%x", st)
+	}
+	if len(removed) != 1 || removed[0] != tref || len(added) != 1 || added[0] != newRef {
+		t.Errorf("got removed %x, added %x, want [%x], [%x]", removed, added, tref, newRef)
+	}
+
+	// Polygon flags
+	ref := allPolyRefs(mesh)[0]
+	f, _ := mesh.PolyFlags(ref)
+	mesh.SetPolyFlags(ref, f)
+	if len(flags) != 0 {
+		t.Errorf("setting the same flags should not be notified")
+	}
+	mesh.SetPolyFlags(ref, f^1)
+	if len(flags) != 1 || flags[0] != ref {
+		t.Errorf("got flags changes %x, want [%x]", flags, ref)
+	}
+
+	area, _ := mesh.PolyArea(ref)
+	flags = nil
+	n, _ := mesh.SetFlagsForArea(area, 0x8000, 0)
+	if n == 0 || len(flags) != n {
+		t.Errorf("SetFlagsForArea modified %d polygons, got %d notifications", n, len(flags))
+	}
+
+	// No more notifications once unsubscribed.
+	if !mesh.Unsubscribe(id) || mesh.Unsubscribe(id) {
+		t.Fatalf("Unsubscribe should succeed once")
+	}
+	flags = nil
+	mesh.SetPolyFlags(ref, f)
+	if len(flags) != 0 {
+		t.Errorf("got notifications after Unsubscribe")
+	}
+}
@@ -0,0 +1,288 @@
+package detour
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+var updateGolden = flag.Bool("update", false, "record the golden files of the query regression tests")
+
+// goldenMeshes are the navmeshes of the test data on which the queries of the
+// golden files are run.
+var goldenMeshes = []string{
+	"mesh1.bin",
+	"mesh2.bin",
+	"offmeshcons.bin",
+	"fixture/donut.bin",
+	"fixture/floors.bin",
+	"fixture/tiled.bin",
+}
+
+const (
+	goldenQueryCount = 20   // number of recorded queries of each kind
+	goldenTol        = 1e-3 // tolerance on positions and hit parameters
+)
+
+// goldenQueries holds the recorded queries run on a navmesh, and their
+// results.
+type goldenQueries struct {
+	Nearest  []goldenNearest
+	Paths    []goldenPath
+	Raycasts []goldenRaycast
+}
+
+type goldenNearest struct {
+	Center [3]float32
+	Status Status
+	Ref    PolyRef
+	Point  [3]float32
+}
+
+type goldenPath struct {
+	Start, End [3]float32
+	Status     Status
+	Path       []PolyRef
+	Straight   [][3]float32
+}
+
+type goldenRaycast struct {
+	Start, End [3]float32
+	Status     Status
+	T          float32
+	Normal     [3]float32
+	Edge       int
+	Path       []PolyRef
+}
+
+// goldenFile returns the path of the golden file of a navmesh of the test
+// data.
+func goldenFile(mesh string) string {
+	name := strings.TrimSuffix(strings.Replace(mesh, "/", "_", -1), ".bin")
+	return filepath.Join("..", "testdata", "golden", name+".json")
+}
+
+// genGoldenQueries generates the inputs of random queries on mesh. Path and
+// raycast end points are on the navmesh.
+func genGoldenQueries(tb testing.TB, mesh *NavMesh, q *NavMeshQuery, rng *rand.Rand) *goldenQueries {
+	var bmin, bmax [3]float32
+	first := true
+	for i := range mesh.Tiles {
+		hdr := mesh.Tiles[i].Header
+		if hdr == nil {
+			continue
+		}
+		for k := 0; k < 3; k++ {
+			if first || hdr.BMin[k] < bmin[k] {
+				bmin[k] = hdr.BMin[k]
+			}
+			if first || hdr.BMax[k] > bmax[k] {
+				bmax[k] = hdr.BMax[k]
+			}
+		}
+		first = false
+	}
+
+	randPos := func() (p [3]float32) {
+		for k := 0; k < 3; k++ {
+			p[k] = bmin[k] + rng.Float32()*(bmax[k]-bmin[k])
+		}
+		return p
+	}
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(bmax[0]-bmin[0], bmax[1]-bmin[1], bmax[2]-bmin[2])
+	randPosOnMesh := func() (p [3]float32) {
+		pos := randPos()
+		st, ref, pt := q.FindNearestPoly(pos[:], extents, filter)
+		if StatusFailed(st) || ref == 0 {
+			tb.Fatalf("no polygon found near %v", pos)
+		}
+		copy(p[:], pt)
+		return p
+	}
+
+	gq := &goldenQueries{}
+	for i := 0; i < goldenQueryCount; i++ {
+		gq.Nearest = append(gq.Nearest, goldenNearest{Center: randPos()})
+		gq.Paths = append(gq.Paths, goldenPath{Start: randPosOnMesh(), End: randPosOnMesh()})
+		gq.Raycasts = append(gq.Raycasts, goldenRaycast{Start: randPosOnMesh(), End: randPosOnMesh()})
+	}
+	return gq
+}
+
+// runGoldenQueries runs the queries of gq and records their results in gq.
+func runGoldenQueries(q *NavMeshQuery, gq *goldenQueries) {
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	path := make([]PolyRef, 256)
+	straight := make([]float32, 3*256)
+	visited := make([]PolyRef, 64)
+
+	for i := range gq.Nearest {
+		n := &gq.Nearest[i]
+		var pt d3.Vec3
+		n.Status, n.Ref, pt = q.FindNearestPoly(n.Center[:], extents, filter)
+		n.Point = [3]float32{}
+		copy(n.Point[:], pt)
+	}
+
+	for i := range gq.Paths {
+		p := &gq.Paths[i]
+		_, startRef, _ := q.FindNearestPoly(p.Start[:], extents, filter)
+		_, endRef, _ := q.FindNearestPoly(p.End[:], extents, filter)
+
+		var npath int
+		npath, p.Status = q.FindPath(startRef, endRef, p.Start[:], p.End[:], filter, path)
+		p.Path = append([]PolyRef(nil), path[:npath]...)
+		p.Straight = nil
+		if StatusFailed(p.Status) || npath == 0 {
+			continue
+		}
+		nstraight, _ := q.FindStraightPathFlat(p.Start[:], p.End[:], path[:npath], straight, nil, nil, 0)
+		for j := 0; j < nstraight; j++ {
+			p.Straight = append(p.Straight, [3]float32{straight[j*3], straight[j*3+1], straight[j*3+2]})
+		}
+	}
+
+	for i := range gq.Raycasts {
+		r := &gq.Raycasts[i]
+		_, startRef, _ := q.FindNearestPoly(r.Start[:], extents, filter)
+
+		hit := RaycastHit{Path: visited}
+		r.Status = q.Raycast(startRef, r.Start[:], r.End[:], filter, 0, &hit, 0)
+		r.T, r.Edge = hit.T, hit.HitEdgeIndex
+		r.Normal = [3]float32{}
+		copy(r.Normal[:], hit.HitNormal)
+		r.Path = append([]PolyRef(nil), hit.VisitedPath()...)
+	}
+}
+
+func approxVec3(a, b [3]float32) bool {
+	for k := 0; k < 3; k++ {
+		if math32.Abs(a[k]-b[k]) > goldenTol {
+			return false
+		}
+	}
+	return true
+}
+
+func equalRefs(a, b []PolyRef) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// compareGoldenQueries reports the differences between the recorded and the
+// current results of the queries.
+func compareGoldenQueries(t *testing.T, want, got *goldenQueries) {
+	for i, w := range want.Nearest {
+		g := got.Nearest[i]
+		if g.Status != w.Status || g.Ref != w.Ref || !approxVec3(g.Point, w.Point) {
+			t.Errorf("FindNearestPoly(%v) = (%v, 0x%x, %v), want (%v, 0x%x, %v)",
+				w.Center, g.Status, g.Ref, g.Point, w.Status, w.Ref, w.Point)
+		}
+	}
+
+	for i, w := range want.Paths {
+		g := got.Paths[i]
+		if g.Status != w.Status || !equalRefs(g.Path, w.Path) {
+			t.Errorf("FindPath(%v, %v) = (%v, %v), want (%v, %v)",
+				w.Start, w.End, g.Status, g.Path, w.Status, w.Path)
+			continue
+		}
+		ok := len(g.Straight) == len(w.Straight)
+		for j := 0; ok && j < len(w.Straight); j++ {
+			ok = approxVec3(g.Straight[j], w.Straight[j])
+		}
+		if !ok {
+			t.Errorf("FindStraightPath(%v, %v) = %v, want %v", w.Start, w.End, g.Straight, w.Straight)
+		}
+	}
+
+	for i, w := range want.Raycasts {
+		g := got.Raycasts[i]
+		sameT := g.T == w.T || math32.Abs(g.T-w.T) <= goldenTol
+		if g.Status != w.Status || !sameT || g.Edge != w.Edge ||
+			!approxVec3(g.Normal, w.Normal) || !equalRefs(g.Path, w.Path) {
+			t.Errorf("Raycast(%v, %v) = (%v, t=%v, normal=%v, edge=%d, %v), want (%v, t=%v, normal=%v, edge=%d, %v)",
+				w.Start, w.End, g.Status, g.T, g.Normal, g.Edge, g.Path,
+				w.Status, w.T, w.Normal, w.Edge, w.Path)
+		}
+	}
+}
+
+// loadGoldenQueries reads the golden file of a navmesh.
+func loadGoldenQueries(tb testing.TB, mesh string) *goldenQueries {
+	buf, err := ioutil.ReadFile(goldenFile(mesh))
+	if err != nil {
+		tb.Fatalf("%v (run 'go test -run TestGoldenQueries -update' to record the golden files)", err)
+	}
+	gq := &goldenQueries{}
+	if err := json.Unmarshal(buf, gq); err != nil {
+		tb.Fatal(err)
+	}
+	return gq
+}
+
+// TestGoldenQueries runs the queries recorded in the golden files and compares
+// their results with the recorded ones. Run it with -update to generate new
+// queries and record their results, after an intended behavior change.
+func TestGoldenQueries(t *testing.T) {
+	for i, name := range goldenMeshes {
+		t.Run(name, func(t *testing.T) {
+			mesh, err := loadTestNavMesh(name)
+			checkt(t, err)
+			_, q := NewNavMeshQuery(mesh, 2048)
+
+			if *updateGolden {
+				gq := genGoldenQueries(t, mesh, q, rand.New(rand.NewSource(int64(i+1))))
+				runGoldenQueries(q, gq)
+				buf, err := json.MarshalIndent(gq, "", "  ")
+				checkt(t, err)
+				checkt(t, ioutil.WriteFile(goldenFile(name), append(buf, '\n'), 0644))
+				return
+			}
+
+			want := loadGoldenQueries(t, name)
+			got := loadGoldenQueries(t, name)
+			runGoldenQueries(q, got)
+			compareGoldenQueries(t, want, got)
+		})
+	}
+}
+
+func BenchmarkGoldenQueries(b *testing.B) {
+	type run struct {
+		q  *NavMeshQuery
+		gq *goldenQueries
+	}
+	var runs []run
+	for _, name := range goldenMeshes {
+		mesh, err := loadTestNavMesh(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, q := NewNavMeshQuery(mesh, 2048)
+		runs = append(runs, run{q, loadGoldenQueries(b, name)})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, r := range runs {
+			runGoldenQueries(r.q, r.gq)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package detour
+
+import (
+	"container/heap"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// PathHierarchy is a coarse graph of the navigation mesh used to speed up
+// long path searches on big tiled navigation meshes.
+//
+// The polygons are grouped in clusters, one per tile. The portals between the
+// polygons of different clusters are the nodes of the coarse graph, and the
+// costs between the portals of a cluster are precomputed. PathHierarchy.FindPath
+// first searches the coarse graph, then searches the polygon path only in the
+// clusters the coarse path goes through, which is much faster than a polygon
+// search over the whole navigation mesh. On small navigation meshes, made of a
+// few tiles, the coarse search costs more than it saves, and
+// NavMeshQuery.FindPath is faster.
+//
+// The hierarchy isn't updated when tiles are added or removed, or when polygon
+// flags change, in which case it should be built again.
+//
+// see NewPathHierarchy
+type PathHierarchy struct {
+	nav    *NavMesh
+	filter QueryFilter
+
+	portals  []hierPortal
+	clusters map[uint32][]int32 // Portal indices per cluster.
+}
+
+// hierPortal is a portal between 2 clusters, a node of the coarse graph.
+type hierPortal struct {
+	refs     [2]PolyRef // Polygons on each side of the portal.
+	clusters [2]uint32  // Clusters of refs.
+	pos      [3]float32 // Middle of the portal.
+	edges    []hierEdge // Edges towards the other portals of both clusters.
+}
+
+// hierEdge is an edge between two portals of the same cluster.
+type hierEdge struct {
+	to   int32
+	cost float32
+}
+
+// NewPathHierarchy builds the path hierarchy of the navigation mesh of q.
+//
+//	Arguments:
+//	 q       The query object of the navigation mesh, its node pool is used
+//	         to compute the costs within the clusters.
+//	 filter  The polygon filter, used for the precomputed costs and for the
+//	         searches.
+//
+//	Return values:
+//	 h       The path hierarchy.
+//	 st      The status flags, OutOfNodes if the node pool of q is too small
+//	         to search a whole tile, in which case some costs may be missing.
+//
+// The precomputed costs assume the filter costs are symmetric, as the ones of
+// StandardQueryFilter.
+func NewPathHierarchy(q *NavMeshQuery, filter QueryFilter) (h *PathHierarchy, st Status) {
+	if filter == nil {
+		return nil, Failure | InvalidParam
+	}
+
+	h = &PathHierarchy{
+		nav:      q.nav,
+		filter:   filter,
+		clusters: make(map[uint32][]int32),
+	}
+
+	// Find the portals between clusters.
+	var (
+		neiTile *MeshTile
+		neiPoly *Poly
+		mid     = d3.NewVec3()
+		found   = make(map[[2]PolyRef]bool)
+	)
+	h.nav.EachPoly(func(ref PolyRef, poly *Poly, tile *MeshTile) bool {
+		if !filter.PassFilter(ref, tile, poly) {
+			return true
+		}
+		c := h.cluster(ref)
+		for i := poly.FirstLink; i != nullLink; i = tile.Links[i].Next {
+			neiRef := tile.Links[i].Ref
+			if neiRef == 0 || h.cluster(neiRef) == c {
+				continue
+			}
+			key := [2]PolyRef{ref, neiRef}
+			if neiRef < ref {
+				key = [2]PolyRef{neiRef, ref}
+			}
+			if found[key] {
+				continue
+			}
+			h.nav.TileAndPolyByRefUnsafe(neiRef, &neiTile, &neiPoly)
+			if !filter.PassFilter(neiRef, neiTile, neiPoly) ||
+				StatusFailed(q.edgeMidPoint(ref, poly, tile, neiRef, neiPoly, neiTile, mid)) {
+				continue
+			}
+			found[key] = true
+
+			p := hierPortal{
+				refs:     [2]PolyRef{ref, neiRef},
+				clusters: [2]uint32{c, h.cluster(neiRef)},
+			}
+			copy(p.pos[:], mid)
+			idx := int32(len(h.portals))
+			h.portals = append(h.portals, p)
+			h.clusters[p.clusters[0]] = append(h.clusters[p.clusters[0]], idx)
+			h.clusters[p.clusters[1]] = append(h.clusters[p.clusters[1]], idx)
+		}
+		return true
+	})
+
+	// Precompute the costs between the portals of each cluster.
+	st = Success
+	for i := range h.portals {
+		p := &h.portals[i]
+		for side := 0; side < 2; side++ {
+			c := p.clusters[side]
+			st |= h.searchCluster(q, c, p.refs[side], p.pos[:])
+			for _, j := range h.clusters[c] {
+				if j == int32(i) {
+					continue
+				}
+				if cost, ok := h.portalCost(q, c, j); ok {
+					p.edges = append(p.edges, hierEdge{to: j, cost: cost})
+				}
+			}
+		}
+	}
+	return h, st & (Success | OutOfNodes)
+}
+
+// PortalCount returns the number of portals between clusters, that is the
+// number of nodes of the coarse graph.
+func (h *PathHierarchy) PortalCount() int {
+	return len(h.portals)
+}
+
+// cluster returns the cluster of a polygon.
+func (h *PathHierarchy) cluster(ref PolyRef) uint32 {
+	var salt, it, ip uint32
+	h.nav.DecodePolyID(ref, &salt, &it, &ip)
+	return it
+}
+
+// searchCluster computes the costs from a position to all the polygons of a
+// cluster, in the node pool of q. The search doesn't leave the cluster.
+func (h *PathHierarchy) searchCluster(q *NavMeshQuery, c uint32, startRef PolyRef, startPos d3.Vec3) (st Status) {
+	q.nodePool.Clear()
+	q.openList.clear()
+
+	startNode := q.nodePool.Node(startRef, 0)
+	startNode.Pos.Assign(startPos)
+	startNode.PIdx = 0
+	startNode.Cost = 0
+	startNode.Total = 0
+	startNode.ID = startRef
+	startNode.Flags = nodeOpen
+	q.openList.push(startNode)
+
+	var (
+		bestTile, parentTile, neiTile *MeshTile
+		bestPoly, parentPoly, neiPoly *Poly
+	)
+	st = Success
+	for !q.openList.empty() {
+		bestNode := q.openList.pop()
+		bestNode.Flags &= ^nodeOpen
+		bestNode.Flags |= nodeClosed
+
+		bestRef := bestNode.ID
+		q.nav.TileAndPolyByRefUnsafe(bestRef, &bestTile, &bestPoly)
+
+		var parentRef PolyRef
+		parentTile, parentPoly = nil, nil
+		if bestNode.PIdx != 0 {
+			parentRef = q.nodePool.NodeAtIdx(int32(bestNode.PIdx)).ID
+			q.nav.TileAndPolyByRefUnsafe(parentRef, &parentTile, &parentPoly)
+		}
+
+		for i := bestPoly.FirstLink; i != nullLink; i = bestTile.Links[i].Next {
+			neiRef := bestTile.Links[i].Ref
+			if neiRef == 0 || neiRef == parentRef || h.cluster(neiRef) != c {
+				continue
+			}
+			q.nav.TileAndPolyByRefUnsafe(neiRef, &neiTile, &neiPoly)
+			if !h.filter.PassFilter(neiRef, neiTile, neiPoly) {
+				continue
+			}
+
+			neiNode := q.nodePool.Node(neiRef, 0)
+			if neiNode == nil {
+				st |= OutOfNodes
+				continue
+			}
+			if neiNode.Flags == 0 {
+				q.edgeMidPoint(bestRef, bestPoly, bestTile, neiRef, neiPoly, neiTile, neiNode.Pos)
+			}
+
+			cost := bestNode.Cost + h.filter.Cost(bestNode.Pos, neiNode.Pos,
+				parentRef, parentTile, parentPoly,
+				bestRef, bestTile, bestPoly,
+				neiRef, neiTile, neiPoly)
+			if neiNode.Flags&(nodeOpen|nodeClosed) != 0 && cost >= neiNode.Cost {
+				continue
+			}
+
+			neiNode.PIdx = q.nodePool.NodeIdx(bestNode)
+			neiNode.ID = neiRef
+			neiNode.Flags &= ^nodeClosed
+			neiNode.Cost = cost
+			neiNode.Total = cost
+			if neiNode.Flags&nodeOpen != 0 {
+				q.openList.modify(neiNode)
+			} else {
+				neiNode.Flags |= nodeOpen
+				q.openList.push(neiNode)
+			}
+		}
+	}
+	return st
+}
+
+// portalCost returns the cost to reach portal j of cluster c, after a search
+// of the cluster with searchCluster.
+func (h *PathHierarchy) portalCost(q *NavMeshQuery, c uint32, j int32) (cost float32, ok bool) {
+	p := &h.portals[j]
+	ref := p.refs[0]
+	if p.clusters[0] != c {
+		ref = p.refs[1]
+	}
+	return h.nodeCost(q, ref, p.pos[:])
+}
+
+// nodeCost returns the cost to reach pos, in the polygon ref, after a search
+// with searchCluster.
+func (h *PathHierarchy) nodeCost(q *NavMeshQuery, ref PolyRef, pos d3.Vec3) (cost float32, ok bool) {
+	node := q.nodePool.FindNode(ref, 0)
+	if node == nil || node.Flags&nodeClosed == 0 {
+		return 0, false
+	}
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	q.nav.TileAndPolyByRefUnsafe(ref, &tile, &poly)
+	return node.Cost + h.filter.Cost(node.Pos, pos, 0, nil, nil, ref, tile, poly, 0, nil, nil), true
+}
+
+// FindPath finds a path from the start polygon to the end polygon, like
+// NavMeshQuery.FindPath, searching the coarse graph of the hierarchy first.
+//
+//	Arguments:
+//	 q         The query object of the navigation mesh.
+//	 startRef  The reference id of the start polygon.
+//	 endRef    The reference id of the end polygon.
+//	 startPos  A position within the start polygon. [(x, y, z)]
+//	 endPos    A position within the end polygon. [(x, y, z)]
+//	 path      An ordered list of polygon references representing the path.
+//	           (Start to end.)
+//
+//	Return values:
+//	 pathCount The number of polygons returned in the path array.
+//	 st        The status flags for the query.
+//
+// The polygons are searched with the filter of the hierarchy, in the clusters
+// of the start and end polygons, and in the clusters the coarse path goes
+// through. The path is the same as the one of NavMeshQuery.FindPath if the
+// shortest path goes through these clusters, otherwise it's slightly longer.
+//
+// If the end polygon isn't reachable in the coarse graph, the polygons are
+// searched over the whole navigation mesh, so that the path is the same
+// partial path as the one of NavMeshQuery.FindPath.
+//
+// Note: this method uses the node pool of q, it may not be used by multiple
+// clients at the same time.
+func (h *PathHierarchy) FindPath(q *NavMeshQuery,
+	startRef, endRef PolyRef,
+	startPos, endPos d3.Vec3,
+	path []PolyRef) (pathCount int, st Status) {
+
+	// Validate input
+	if q.nav != h.nav || !h.nav.IsValidPolyRef(startRef) || !h.nav.IsValidPolyRef(endRef) ||
+		!isValidVec3(startPos) || !isValidVec3(endPos) || len(path) == 0 {
+		return 0, Failure | InvalidParam
+	}
+
+	cs, ce := h.cluster(startRef), h.cluster(endRef)
+	if cs == ce {
+		return q.FindPath(startRef, endRef, startPos, endPos, h.filter, path)
+	}
+
+	clusters, ok := h.coarsePath(q, startRef, endRef, startPos, endPos)
+	if !ok {
+		return q.FindPath(startRef, endRef, startPos, endPos, h.filter, path)
+	}
+	return q.FindPath(startRef, endRef, startPos, endPos, &clusterFilter{h.filter, h, clusters}, path)
+}
+
+// coarsePath searches the coarse graph from the start to the end positions,
+// and returns the clusters the coarse path goes through.
+func (h *PathHierarchy) coarsePath(q *NavMeshQuery,
+	startRef, endRef PolyRef,
+	startPos, endPos d3.Vec3) (clusters map[uint32]bool, ok bool) {
+
+	cs, ce := h.cluster(startRef), h.cluster(endRef)
+
+	// The goal is a virtual node after the portals, connected to the portals
+	// of the end cluster.
+	goal := int32(len(h.portals))
+	endCosts := make(map[int32]float32)
+	h.searchCluster(q, ce, endRef, endPos)
+	for _, j := range h.clusters[ce] {
+		if cost, ok := h.portalCost(q, ce, j); ok {
+			endCosts[j] = cost
+		}
+	}
+
+	var (
+		open   portalQueue
+		costs  = make(map[int32]float32)
+		parent = make(map[int32]int32)
+		closed = make(map[int32]bool)
+	)
+	heuristic := func(i int32) float32 {
+		if i == goal {
+			return 0
+		}
+		return EuclideanHeuristic(h.portals[i].pos[:], endPos) * HScale
+	}
+	push := func(i, from int32, cost float32) {
+		if old, ok := costs[i]; (ok && cost >= old) || closed[i] {
+			return
+		}
+		costs[i] = cost
+		parent[i] = from
+		heap.Push(&open, portalItem{idx: i, total: cost + heuristic(i)})
+	}
+
+	h.searchCluster(q, cs, startRef, startPos)
+	for _, j := range h.clusters[cs] {
+		if cost, ok := h.portalCost(q, cs, j); ok {
+			push(j, -1, cost)
+		}
+	}
+
+	for open.Len() > 0 {
+		it := heap.Pop(&open).(portalItem)
+		if closed[it.idx] || it.total > costs[it.idx]+heuristic(it.idx) {
+			continue
+		}
+		closed[it.idx] = true
+		if it.idx == goal {
+			break
+		}
+
+		p := &h.portals[it.idx]
+		for _, e := range p.edges {
+			push(e.to, it.idx, costs[it.idx]+e.cost)
+		}
+		if cost, ok := endCosts[it.idx]; ok {
+			push(goal, it.idx, costs[it.idx]+cost)
+		}
+	}
+	if !closed[goal] {
+		return nil, false
+	}
+
+	// Collect the clusters of the portals of the coarse path.
+	clusters = map[uint32]bool{cs: true, ce: true}
+	for i := parent[goal]; i >= 0; i = parent[i] {
+		clusters[h.portals[i].clusters[0]] = true
+		clusters[h.portals[i].clusters[1]] = true
+	}
+	return clusters, true
+}
+
+// clusterFilter is a QueryFilter only passing the polygons of a set of
+// clusters.
+type clusterFilter struct {
+	QueryFilter
+	h        *PathHierarchy
+	clusters map[uint32]bool
+}
+
+// PassFilter returns true if the polygon can be visited.
+func (f *clusterFilter) PassFilter(ref PolyRef, tile *MeshTile, poly *Poly) bool {
+	return f.clusters[f.h.cluster(ref)] && f.QueryFilter.PassFilter(ref, tile, poly)
+}
+
+// portalItem is an item of a portalQueue.
+type portalItem struct {
+	idx   int32
+	total float32
+}
+
+// portalQueue is the priority queue of the coarse graph search, ordered by
+// total cost. It implements heap.Interface.
+type portalQueue []portalItem
+
+func (pq portalQueue) Len() int            { return len(pq) }
+func (pq portalQueue) Less(i, j int) bool  { return pq[i].total < pq[j].total }
+func (pq portalQueue) Swap(i, j int)       { pq[i], pq[j] = pq[j], pq[i] }
+func (pq *portalQueue) Push(x interface{}) { *pq = append(*pq, x.(portalItem)) }
+
+func (pq *portalQueue) Pop() interface{} {
+	old := *pq
+	it := old[len(old)-1]
+	*pq = old[:len(old)-1]
+	return it
+}
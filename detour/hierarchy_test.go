@@ -0,0 +1,131 @@
+package detour
+
+import (
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// straightPathLength returns the length of the straight path along a polygon
+// corridor.
+func straightPathLength(q *NavMeshQuery, startPos, endPos d3.Vec3, path []PolyRef) float32 {
+	pts := make([]d3.Vec3, 256)
+	for i := range pts {
+		pts[i] = d3.NewVec3()
+	}
+	n, _ := q.FindStraightPath(startPos, endPos, path, pts, make([]uint8, len(pts)), make([]PolyRef, len(pts)), 0)
+	var length float32
+	for i := 1; i < n; i++ {
+		length += pts[i-1].Dist(pts[i])
+	}
+	return length
+}
+
+func TestPathHierarchyFindPath(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 2048)
+	filter := NewStandardQueryFilter()
+
+	h, st := NewPathHierarchy(q, filter)
+	if st != Success {
+		t.Fatalf("NewPathHierarchy returned status 0x%x", st)
+	}
+	if h.PortalCount() == 0 {
+		t.Fatalf("no portals between the tiles")
+	}
+
+	var (
+		refs       = allPolyRefs(mesh)
+		path, want = make([]PolyRef, 256), make([]PolyRef, 256)
+		tile       *MeshTile
+		poly       *Poly
+	)
+	center := func(ref PolyRef) d3.Vec3 {
+		mesh.TileAndPolyByRefUnsafe(ref, &tile, &poly)
+		return CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts)
+	}
+	for i := 0; i < len(refs); i += 3 {
+		for j := 1; j < len(refs); j += 7 {
+			startRef, endRef := refs[i], refs[j]
+			startPos, endPos := center(startRef), center(endRef)
+
+			nwant, wst := q.FindPath(startRef, endRef, startPos, endPos, filter, want)
+			n, st := h.FindPath(q, startRef, endRef, startPos, endPos, path)
+			if StatusFailed(st) {
+				t.Fatalf("FindPath(0x%x, 0x%x) failed with status 0x%x", startRef, endRef, st)
+			}
+			if st&PartialResult != wst&PartialResult {
+				t.Fatalf("FindPath(0x%x, 0x%x): status 0x%x, want 0x%x", startRef, endRef, st, wst)
+			}
+			if path[0] != startRef || (wst&PartialResult == 0 && path[n-1] != endRef) {
+				t.Fatalf("FindPath(0x%x, 0x%x): path %x doesn't join the polygons", startRef, endRef, path[:n])
+			}
+			for k := 0; k+1 < n; k++ {
+				if StatusFailed(q.portalPoints6(path[k], path[k+1], d3.NewVec3(), d3.NewVec3(), new(uint8), new(uint8))) {
+					t.Fatalf("FindPath(0x%x, 0x%x): 0x%x and 0x%x aren't neighbours", startRef, endRef, path[k], path[k+1])
+				}
+			}
+			if wst&PartialResult != 0 {
+				continue
+			}
+			l, lwant := straightPathLength(q, startPos, endPos, path[:n]), straightPathLength(q, startPos, endPos, want[:nwant])
+			if l > lwant*1.05 {
+				t.Errorf("FindPath(0x%x, 0x%x): path length %f, want about %f", startRef, endRef, l, lwant)
+			}
+		}
+	}
+}
+
+func benchmarkLongPath(b *testing.B, hierarchical bool) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, q := NewNavMeshQuery(mesh, 2048)
+	filter := NewStandardQueryFilter()
+	h, _ := NewPathHierarchy(q, filter)
+
+	// The furthest apart polygons joined by a path.
+	var (
+		startRef, endRef PolyRef
+		startPos, endPos d3.Vec3
+		maxDist          float32
+		path             = make([]PolyRef, 256)
+		tile             *MeshTile
+		poly             *Poly
+	)
+	refs := allPolyRefs(mesh)
+	centers := make([]d3.Vec3, len(refs))
+	for i, ref := range refs {
+		mesh.TileAndPolyByRefUnsafe(ref, &tile, &poly)
+		centers[i] = CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts)
+	}
+	for i := range refs {
+		for j := range refs {
+			if d := centers[i].Dist(centers[j]); d > maxDist {
+				if _, st := q.FindPath(refs[i], refs[j], centers[i], centers[j], filter, path); st == Success {
+					startRef, endRef, startPos, endPos, maxDist = refs[i], refs[j], centers[i], centers[j], d
+				}
+			}
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if hierarchical {
+			h.FindPath(q, startRef, endRef, startPos, endPos, path)
+		} else {
+			q.FindPath(startRef, endRef, startPos, endPos, filter, path)
+		}
+	}
+}
+
+func BenchmarkFindPathLong(b *testing.B) {
+	benchmarkLongPath(b, false)
+}
+
+func BenchmarkPathHierarchyFindPathLong(b *testing.B) {
+	benchmarkLongPath(b, true)
+}
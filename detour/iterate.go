@@ -0,0 +1,131 @@
+package detour
+
+// TileCount returns the number of tiles loaded in the navigation mesh.
+func (m *NavMesh) TileCount() int {
+	n := 0
+	for i := range m.Tiles {
+		if m.Tiles[i].Header != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// PolyCount returns the number of polygons of the tiles loaded in the
+// navigation mesh, off-mesh connections included.
+func (m *NavMesh) PolyCount() int {
+	n := 0
+	for i := range m.Tiles {
+		if hdr := m.Tiles[i].Header; hdr != nil {
+			n += int(hdr.PolyCount)
+		}
+	}
+	return n
+}
+
+// TileHeader returns the header of the tile which reference is ref, or nil if
+// ref isn't a valid tile reference.
+//
+// The header holds the tile location, bounds and counts, and the dimensions
+// of the agents the tile has been built for. It must not be modified.
+func (m *NavMesh) TileHeader(ref TileRef) *MeshHeader {
+	tile := m.TileByRef(ref)
+	if tile == nil {
+		return nil
+	}
+	return tile.Header
+}
+
+// Tile returns the i-th loaded tile of the navigation mesh, the empty tile
+// slots being skipped, or nil if i is out of the range [0, TileCount()).
+//
+// Tile scans the tile slots, use EachTile to visit all the tiles.
+func (m *NavMesh) Tile(i int) *MeshTile {
+	if i < 0 {
+		return nil
+	}
+	for j := range m.Tiles {
+		if m.Tiles[j].Header == nil {
+			continue
+		}
+		if i == 0 {
+			return &m.Tiles[j]
+		}
+		i--
+	}
+	return nil
+}
+
+// EachTile calls fn for each tile loaded in the navigation mesh, with its
+// reference, until fn returns false.
+//
+// It returns false if fn did.
+func (m *NavMesh) EachTile(fn func(ref TileRef, tile *MeshTile) bool) bool {
+	for i := range m.Tiles {
+		tile := &m.Tiles[i]
+		if tile.Header == nil {
+			continue
+		}
+		if !fn(m.TileRef(tile), tile) {
+			return false
+		}
+	}
+	return true
+}
+
+// EachPoly calls fn for each polygon of the tiles loaded in the navigation
+// mesh, off-mesh connections included, with its reference and its tile,
+// until fn returns false.
+//
+// It returns false if fn did.
+func (m *NavMesh) EachPoly(fn func(ref PolyRef, poly *Poly, tile *MeshTile) bool) bool {
+	for i := range m.Tiles {
+		tile := &m.Tiles[i]
+		if tile.Header == nil {
+			continue
+		}
+		if !m.EachTilePoly(tile, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// EachTilePoly calls fn for each polygon of a tile of the navigation mesh,
+// off-mesh connections included, with its reference, until fn returns false.
+//
+// It returns false if fn did.
+func (m *NavMesh) EachTilePoly(tile *MeshTile, fn func(ref PolyRef, poly *Poly, tile *MeshTile) bool) bool {
+	if tile == nil || tile.Header == nil {
+		return true
+	}
+	base := m.polyRefBase(tile)
+	for i := int32(0); i < tile.Header.PolyCount; i++ {
+		if !fn(base|PolyRef(i), &tile.Polys[i], tile) {
+			return false
+		}
+	}
+	return true
+}
+
+// EachOffMeshConnection calls fn for each off-mesh connection of the tiles
+// loaded in the navigation mesh, with the reference of its polygon and its
+// tile, until fn returns false.
+//
+// It returns false if fn did.
+func (m *NavMesh) EachOffMeshConnection(fn func(ref PolyRef, con *OffMeshConnection, tile *MeshTile) bool) bool {
+	for i := range m.Tiles {
+		tile := &m.Tiles[i]
+		if tile.Header == nil {
+			continue
+		}
+		base := m.polyRefBase(tile)
+		for j := range tile.OffMeshCons {
+			con := &tile.OffMeshCons[j]
+			if !fn(base|PolyRef(con.Poly), con, tile) {
+				return false
+			}
+		}
+	}
+	return true
+}
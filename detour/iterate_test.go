@@ -0,0 +1,125 @@
+package detour
+
+import "testing"
+
+func TestEachPoly(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	var refs []PolyRef
+	complete := mesh.EachPoly(func(ref PolyRef, poly *Poly, tile *MeshTile) bool {
+		var (
+			wantTile *MeshTile
+			wantPoly *Poly
+		)
+		if StatusFailed(mesh.TileAndPolyByRef(ref, &wantTile, &wantPoly)) {
+			t.Fatalf("EachPoly yielded an invalid ref %d", ref)
+		}
+		if wantTile != tile || wantPoly != poly {
+			t.Errorf("EachPoly yielded ref %d with the wrong tile or poly", ref)
+		}
+		refs = append(refs, ref)
+		return true
+	})
+	if !complete {
+		t.Errorf("EachPoly returned false, want true")
+	}
+	if want := allPolyRefs(mesh); !equalRefs(refs, want) {
+		t.Errorf("EachPoly yielded %d refs, want %d", len(refs), len(want))
+	}
+
+	// Early stop.
+	n := 0
+	complete = mesh.EachPoly(func(PolyRef, *Poly, *MeshTile) bool {
+		n++
+		return n < 3
+	})
+	if complete || n != 3 {
+		t.Errorf("EachPoly stopped after %d polys (complete=%v), want 3 (false)", n, complete)
+	}
+}
+
+func TestTileAccessors(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	count := mesh.TileCount()
+	if count < 2 {
+		t.Fatalf("TileCount() = %d, want at least 2", count)
+	}
+
+	var tiles []*MeshTile
+	mesh.EachTile(func(ref TileRef, tile *MeshTile) bool {
+		if mesh.TileByRef(ref) != tile {
+			t.Errorf("EachTile yielded ref %d for another tile", ref)
+		}
+		tiles = append(tiles, tile)
+		return true
+	})
+	if len(tiles) != count {
+		t.Fatalf("EachTile yielded %d tiles, want %d", len(tiles), count)
+	}
+	for i, tile := range tiles {
+		if got := mesh.Tile(i); got != tile {
+			t.Errorf("Tile(%d) isn't the %dth tile yielded by EachTile", i, i)
+		}
+	}
+	if mesh.Tile(-1) != nil || mesh.Tile(count) != nil {
+		t.Errorf("Tile() out of range should return nil")
+	}
+
+	// Removing a tile leaves an empty slot, which must be skipped.
+	if _, st := mesh.RemoveTile(mesh.TileRef(tiles[0])); StatusFailed(st) {
+		t.Fatalf("RemoveTile failed: %v", st)
+	}
+	if got := mesh.TileCount(); got != count-1 {
+		t.Errorf("TileCount() = %d after RemoveTile, want %d", got, count-1)
+	}
+	if got := mesh.Tile(0); got != tiles[1] {
+		t.Errorf("Tile(0) after RemoveTile isn't the second tile")
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	mesh, err := loadTestNavMesh("offmeshcons.bin")
+	checkt(t, err)
+
+	var npolys, noffmesh int
+	mesh.EachPoly(func(ref PolyRef, poly *Poly, tile *MeshTile) bool {
+		npolys++
+		if poly.IsOffMeshConnection() {
+			noffmesh++
+		}
+		return true
+	})
+	if got := mesh.PolyCount(); got != npolys {
+		t.Errorf("PolyCount() = %d, want %d", got, npolys)
+	}
+
+	var cons int
+	complete := mesh.EachOffMeshConnection(func(ref PolyRef, con *OffMeshConnection, tile *MeshTile) bool {
+		var poly *Poly
+		if StatusFailed(mesh.TileAndPolyByRef(ref, new(*MeshTile), &poly)) || !poly.IsOffMeshConnection() {
+			t.Errorf("EachOffMeshConnection yielded ref 0x%x, which isn't an off-mesh connection", ref)
+		}
+		cons++
+		return true
+	})
+	if !complete || cons == 0 || cons != noffmesh {
+		t.Errorf("EachOffMeshConnection yielded %d connections (complete=%v), want %d", cons, complete, noffmesh)
+	}
+
+	tile := mesh.Tile(0)
+	ref := mesh.TileRef(tile)
+	if hdr := mesh.TileHeader(ref); hdr != tile.Header {
+		t.Errorf("TileHeader(0x%x) isn't the header of the tile", ref)
+	}
+	removed := int(tile.Header.PolyCount)
+	mesh.RemoveTile(ref)
+	if hdr := mesh.TileHeader(ref); hdr != nil {
+		t.Errorf("TileHeader of a removed tile should be nil")
+	}
+	if got := mesh.PolyCount(); got != npolys-removed {
+		t.Errorf("PolyCount() = %d after RemoveTile, want %d", got, npolys-removed)
+	}
+}
@@ -0,0 +1,185 @@
+package detour
+
+import (
+	"math"
+
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+const (
+	// MaxLocalSegs is the maximum number of wall segments of a LocalBoundary.
+	MaxLocalSegs = 8
+
+	// MaxLocalPolys is the maximum number of polygons of a LocalBoundary.
+	MaxLocalPolys = 16
+)
+
+// localSegment is a wall segment of a LocalBoundary.
+type localSegment struct {
+	s [6]float32 // Segment start/end
+	d float32    // Distance for pruning.
+}
+
+// LocalBoundary holds the wall segments of the navigation mesh around a
+// position, the nearest first.
+//
+// It's meant to feed local steering or obstacle avoidance with the walls to
+// avoid: Update collects the walls around a position, then IsValid tells
+// whether they are still valid (E.g. after polygon flags have been changed)
+// or Update must be called again. The segments are only valid around the
+// position passed to Update, they should be updated when the agent has moved
+// away from Center, by a fraction of the collision query range.
+type LocalBoundary struct {
+	center [3]float32
+	segs   [MaxLocalSegs]localSegment
+	nsegs  int
+	polys  [MaxLocalPolys]PolyRef
+	npolys int
+}
+
+// NewLocalBoundary returns an empty local boundary.
+func NewLocalBoundary() *LocalBoundary {
+	lb := &LocalBoundary{}
+	lb.Reset()
+	return lb
+}
+
+// Reset empties the local boundary.
+func (lb *LocalBoundary) Reset() {
+	lb.center = [3]float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+	lb.npolys = 0
+	lb.nsegs = 0
+}
+
+// addSegment inserts a segment, keeping the segments sorted by distance.
+func (lb *LocalBoundary) addSegment(dist float32, s []float32) {
+	// Insert neighbour based on the distance.
+	var seg *localSegment
+	switch {
+	case lb.nsegs == 0:
+		seg = &lb.segs[0]
+	case dist >= lb.segs[lb.nsegs-1].d:
+		// Further than the last segment, skip.
+		if lb.nsegs >= MaxLocalSegs {
+			return
+		}
+		// Last, add at the end.
+		seg = &lb.segs[lb.nsegs]
+	default:
+		// Insert inbetween.
+		i := 0
+		for ; i < lb.nsegs; i++ {
+			if dist <= lb.segs[i].d {
+				break
+			}
+		}
+		tgt := i + 1
+		n := lb.nsegs - i
+		if n > MaxLocalSegs-tgt {
+			n = MaxLocalSegs - tgt
+		}
+		if n > 0 {
+			copy(lb.segs[tgt:tgt+n], lb.segs[i:i+n])
+		}
+		seg = &lb.segs[i]
+	}
+
+	seg.d = dist
+	copy(seg.s[:], s)
+
+	if lb.nsegs < MaxLocalSegs {
+		lb.nsegs++
+	}
+}
+
+// Update collects the wall segments around a position.
+//
+//	Arguments:
+//	 ref                  The reference id of the polygon containing pos.
+//	 pos                  The position. [(x, y, z)]
+//	 collisionQueryRange  The distance up to which walls are collected.
+//	 navquery             The query used to find the walls.
+//	 filter               The polygon filter to apply to the query.
+//
+// The polygons around pos are found with FindLocalNeighbourhood, then the
+// MaxLocalSegs wall segments of these polygons the nearest to pos, within
+// collisionQueryRange, are stored. With a zero ref, the local boundary is
+// reset.
+//
+// Note: Update uses the tiny node pool of navquery, it may not be called while
+// navquery is used by another client.
+func (lb *LocalBoundary) Update(ref PolyRef, pos d3.Vec3, collisionQueryRange float32,
+	navquery *NavMeshQuery, filter QueryFilter) {
+
+	const maxSegsPerPoly = VertsPerPolygon * 3
+
+	if ref == 0 {
+		lb.Reset()
+		return
+	}
+
+	copy(lb.center[:], pos)
+
+	// First query non-overlapping polygons.
+	lb.npolys, _ = navquery.FindLocalNeighbourhood(ref, pos, collisionQueryRange,
+		filter, lb.polys[:], nil)
+
+	// Secondly, store all polygon edges.
+	lb.nsegs = 0
+	var segs [maxSegsPerPoly * 6]float32
+	for j := 0; j < lb.npolys; j++ {
+		nsegs, _ := navquery.PolyWallSegments(lb.polys[j], filter, segs[:], nil)
+		for k := 0; k < nsegs; k++ {
+			s := segs[k*6 : k*6+6]
+			// Skip too distant segments.
+			var tseg float32
+			distSqr := distancePtSegSqr2D(pos, s[0:3], s[3:6], &tseg)
+			if distSqr > math32.Sqr(collisionQueryRange) {
+				continue
+			}
+			lb.addSegment(distSqr, s)
+		}
+	}
+}
+
+// IsValid reports whether the polygons of the local boundary are still valid
+// and pass filter, that is whether its segments can still be used.
+func (lb *LocalBoundary) IsValid(navquery *NavMeshQuery, filter QueryFilter) bool {
+	if lb.npolys == 0 {
+		return false
+	}
+
+	// Check that all polygons still pass query filter.
+	for i := 0; i < lb.npolys; i++ {
+		if !navquery.IsValidPolyRef(lb.polys[i], filter) {
+			return false
+		}
+	}
+	return true
+}
+
+// Center returns the position passed to the last Update.
+func (lb *LocalBoundary) Center() d3.Vec3 {
+	return lb.center[:]
+}
+
+// SegmentCount returns the number of wall segments.
+func (lb *LocalBoundary) SegmentCount() int {
+	return lb.nsegs
+}
+
+// Segment returns the i-th wall segment, the segments being sorted by
+// distance to Center. [(ax, ay, az, bx, by, bz)]
+//
+// The returned slice references the storage of lb, it's modified by the next
+// Update.
+func (lb *LocalBoundary) Segment(i int) []float32 {
+	return lb.segs[i].s[:]
+}
+
+// Polys returns the polygons around Center from which the wall segments have
+// been collected.
+func (lb *LocalBoundary) Polys() []PolyRef {
+	return lb.polys[:lb.npolys]
+}
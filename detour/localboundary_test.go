@@ -0,0 +1,153 @@
+package detour
+
+import (
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+func TestPolyWallSegments(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 2048)
+	filter := NewStandardQueryFilter()
+
+	var (
+		segs  [VertsPerPolygon * 3 * 6]float32
+		refs  [VertsPerPolygon * 3]PolyRef
+		walls [VertsPerPolygon * 3 * 6]float32
+	)
+	for _, ref := range allPolyRefs(mesh) {
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		mesh.TileAndPolyByRefUnsafe(ref, &tile, &poly)
+		if poly.Type() == polyTypeOffMeshConnection {
+			continue
+		}
+
+		nsegs, st := q.PolyWallSegments(ref, filter, segs[:], refs[:])
+		if st != Success || nsegs < int(poly.VertCount) {
+			t.Fatalf("polygon 0x%x: got %d segments, status %v, want at least %d segments", ref, nsegs, st, poly.VertCount)
+		}
+		nwalls, st := q.PolyWallSegments(ref, filter, walls[:], nil)
+		if st != Success {
+			t.Fatalf("polygon 0x%x: got status %v for walls", ref, st)
+		}
+
+		// The walls are the segments without neighbour.
+		k := 0
+		for i := 0; i < nsegs; i++ {
+			if refs[i] != 0 {
+				if !mesh.IsValidPolyRef(refs[i]) {
+					t.Errorf("polygon 0x%x: segment %d: invalid neighbour 0x%x", ref, i, refs[i])
+				}
+				continue
+			}
+			if k >= nwalls || !d3.Vec3(segs[i*6:i*6+3]).Approx(walls[k*6:k*6+3]) ||
+				!d3.Vec3(segs[i*6+3:i*6+6]).Approx(walls[k*6+3:k*6+6]) {
+				t.Fatalf("polygon 0x%x: walls don't match the segments without neighbour", ref)
+			}
+			k++
+		}
+		if k != nwalls {
+			t.Errorf("polygon 0x%x: %d walls, want %d", ref, nwalls, k)
+		}
+	}
+
+	ref := allPolyRefs(mesh)[0]
+	if n, st := q.PolyWallSegments(ref, filter, segs[:6], refs[:1]); n != 1 || !st.Is(BufferTooSmall) {
+		t.Errorf("got (%d, %v) with room for 1 segment, want 1 segment and BufferTooSmall", n, st)
+	}
+	if _, st := q.PolyWallSegments(ref, filter, segs[:5], nil); !StatusFailed(st) {
+		t.Errorf("got status %v without room for a segment, want Failure", st)
+	}
+}
+
+func TestFindLocalNeighbourhood(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 2048)
+	filter := NewStandardQueryFilter()
+
+	var refs, parents [32]PolyRef
+	for _, start := range allPolyRefs(mesh)[:50] {
+		center, _ := q.PolyCentroid(start)
+		n, st := q.FindLocalNeighbourhood(start, center, 5, filter, refs[:], parents[:])
+		if StatusFailed(st) || n == 0 || refs[0] != start || parents[0] != 0 {
+			t.Fatalf("polygon 0x%x: got %d polygons, status %v", start, n, st)
+		}
+		found := map[PolyRef]bool{start: true}
+		for i := 1; i < n; i++ {
+			if found[refs[i]] {
+				t.Errorf("polygon 0x%x: 0x%x found twice", start, refs[i])
+			}
+			found[refs[i]] = true
+			if !found[parents[i]] {
+				t.Errorf("polygon 0x%x: parent 0x%x of 0x%x not found before it", start, parents[i], refs[i])
+			}
+		}
+	}
+}
+
+func TestLocalBoundary(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 2048)
+	filter := NewStandardQueryFilter()
+
+	const queryRange = 3
+	lb := NewLocalBoundary()
+	if lb.SegmentCount() != 0 || lb.IsValid(q, filter) {
+		t.Fatalf("new local boundary should be empty and invalid")
+	}
+
+	updated := 0
+	for _, ref := range allPolyRefs(mesh) {
+		pos, st := q.PolyCentroid(ref)
+		if StatusFailed(st) {
+			continue
+		}
+		lb.Update(ref, pos, queryRange, q, filter)
+		if !lb.Center().Approx(pos) || !lb.IsValid(q, filter) || lb.Polys()[0] != ref {
+			t.Fatalf("polygon 0x%x: local boundary should be valid around %v", ref, pos)
+		}
+		if lb.SegmentCount() == 0 {
+			continue
+		}
+		updated++
+
+		// Segments are sorted by distance and within range.
+		prev := float32(-1)
+		for i := 0; i < lb.SegmentCount(); i++ {
+			s := lb.Segment(i)
+			var tseg float32
+			d := distancePtSegSqr2D(pos, s[0:3], s[3:6], &tseg)
+			if d < prev || d > math32.Sqr(queryRange) {
+				t.Fatalf("polygon 0x%x: segment %d at squared distance %v, previous at %v", ref, i, d, prev)
+			}
+			prev = d
+		}
+	}
+	if updated == 0 {
+		t.Fatal("no wall segments found")
+	}
+
+	// Excluding a polygon invalidates the boundary.
+	poly := lb.Polys()[0]
+	flags, _ := mesh.PolyFlags(poly)
+	mesh.SetPolyFlags(poly, flags|0x8000)
+	exclude := NewStandardQueryFilter()
+	exclude.SetExcludeFlags(0x8000)
+	if lb.IsValid(q, exclude) {
+		t.Errorf("local boundary should be invalid after excluding one of its polygons")
+	}
+	mesh.SetPolyFlags(poly, flags)
+
+	lb.Update(0, lb.Center(), queryRange, q, filter)
+	if lb.SegmentCount() != 0 || lb.IsValid(q, filter) {
+		t.Errorf("updating with a zero ref should reset the local boundary")
+	}
+}
@@ -0,0 +1,97 @@
+package detour
+
+import "unsafe"
+
+// MemoryUsage is the memory used by navigation mesh data, in bytes, broken
+// down by kind of data.
+//
+// The sizes are the ones of the in-memory structures, allocated capacity
+// included, they're larger than the size of the serialized data.
+type MemoryUsage struct {
+	Verts       int // Polygon vertices.
+	Polys       int // Polygons.
+	Links       int // Links between polygons, allocated ones included.
+	Detail      int // Detail meshes, vertices and triangles.
+	BvTree      int // Bounding volume tree nodes.
+	OffMeshCons int // Off-mesh connections.
+	WallDist    int // Baked wall distances.
+	Data        int // Copy of the serialized tile data.
+}
+
+// Total returns the sum of all the kinds of data.
+func (u MemoryUsage) Total() int {
+	return u.Verts + u.Polys + u.Links + u.Detail + u.BvTree + u.OffMeshCons + u.WallDist + u.Data
+}
+
+// add adds o to u.
+func (u *MemoryUsage) add(o MemoryUsage) {
+	u.Verts += o.Verts
+	u.Polys += o.Polys
+	u.Links += o.Links
+	u.Detail += o.Detail
+	u.BvTree += o.BvTree
+	u.OffMeshCons += o.OffMeshCons
+	u.WallDist += o.WallDist
+	u.Data += o.Data
+}
+
+// TileMemoryUsage is the memory used by a tile of a navigation mesh.
+type TileMemoryUsage struct {
+	Ref TileRef // The reference of the tile.
+	MemoryUsage
+}
+
+// MemoryStats is the memory used by a navigation mesh.
+type MemoryStats struct {
+	Tiles []TileMemoryUsage // The memory used by each loaded tile.
+	Total MemoryUsage       // The memory used by all the loaded tiles.
+
+	// The memory used by the navigation mesh itself: the tile slots and the
+	// tile lookup table, allocated for MaxTiles tiles whether they're loaded
+	// or not.
+	Mesh int
+}
+
+// TotalBytes returns the memory used by the navigation mesh, in bytes.
+func (s *MemoryStats) TotalBytes() int {
+	return s.Mesh + s.Total.Total()
+}
+
+// MemoryUsage returns the memory used by the tile.
+func (s *MeshTile) MemoryUsage() MemoryUsage {
+	return MemoryUsage{
+		Verts: 4 * cap(s.Verts),
+		Polys: int(unsafe.Sizeof(Poly{})) * cap(s.Polys),
+		Links: int(unsafe.Sizeof(Link{})) * cap(s.Links),
+		Detail: int(unsafe.Sizeof(PolyDetail{}))*cap(s.DetailMeshes) +
+			4*cap(s.DetailVerts) + cap(s.DetailTris),
+		BvTree:      int(unsafe.Sizeof(BvNode{})) * cap(s.BvTree),
+		OffMeshCons: int(unsafe.Sizeof(OffMeshConnection{})) * cap(s.OffMeshCons),
+		WallDist:    4 * cap(s.WallDistances),
+		Data:        cap(s.Data),
+	}
+}
+
+// MemoryStats returns the memory used by the navigation mesh, per tile and in
+// total.
+//
+// It's meant to budget the memory of navigation, on consoles or mobile
+// devices for example. The sizes don't include the queries, which have their
+// own node pools. (See NodePool)
+func (m *NavMesh) MemoryStats() MemoryStats {
+	var stats MemoryStats
+	stats.Mesh = int(unsafe.Sizeof(*m)) +
+		int(unsafe.Sizeof(MeshTile{}))*cap(m.Tiles) +
+		int(unsafe.Sizeof((*MeshTile)(nil)))*cap(m.posLookup)
+
+	m.EachTile(func(ref TileRef, tile *MeshTile) bool {
+		u := TileMemoryUsage{Ref: ref, MemoryUsage: tile.MemoryUsage()}
+		if tile.Header != nil {
+			u.Data += int(unsafe.Sizeof(*tile.Header))
+		}
+		stats.Tiles = append(stats.Tiles, u)
+		stats.Total.add(u.MemoryUsage)
+		return true
+	})
+	return stats
+}
@@ -0,0 +1,31 @@
+package detour
+
+import "testing"
+
+func TestMemoryStats(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	stats := mesh.MemoryStats()
+	if len(stats.Tiles) != mesh.TileCount() {
+		t.Fatalf("got stats for %d tiles, want %d", len(stats.Tiles), mesh.TileCount())
+	}
+
+	var sum MemoryUsage
+	for _, tu := range stats.Tiles {
+		tile := mesh.TileByRef(tu.Ref)
+		if tile == nil {
+			t.Fatalf("tile ref %v is not valid", tu.Ref)
+		}
+		if tu.Verts < 3*4*int(tile.Header.VertCount) || tu.Polys == 0 || tu.Data < len(tile.Data) {
+			t.Errorf("tile %v: memory usage %+v is too small", tu.Ref, tu.MemoryUsage)
+		}
+		sum.add(tu.MemoryUsage)
+	}
+	if sum != stats.Total {
+		t.Errorf("total is %+v, want the sum of the tiles %+v", stats.Total, sum)
+	}
+	if stats.Mesh == 0 || stats.TotalBytes() != stats.Mesh+sum.Total() {
+		t.Errorf("TotalBytes() = %d, want %d + %d", stats.TotalBytes(), stats.Mesh, sum.Total())
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"os"
 	"unsafe"
@@ -60,10 +59,19 @@ type NavMesh struct {
 	saltBits              uint32        // Number of salt bits in the tile ID.
 	tileBits              uint32        // Number of tile bits in the tile ID.
 	polyBits              uint32        // Number of poly bits in the tile ID.
+	generation            uint64        // Number of tiles added so far.
+
+	listeners      []navMeshListener // Listeners of the modifications.
+	nextListenerID int               // Identifier of the next listener.
+
+	buildBvTrees bool // Build the BV tree of the tiles added without one.
 }
 
 // Decode reads a tiled navigation mesh from r and returns it.
 //
+// Only the navigation mesh is read, data following it in r is left unread.
+// (See: DecodeAll)
+//
 // returned error will be different from nil in case of failure.
 func Decode(r io.Reader) (*NavMesh, error) {
 	// Read header.
@@ -81,8 +89,8 @@ func Decode(r io.Reader) (*NavMesh, error) {
 		return nil, fmt.Errorf("wrong magic number: %x", hdr.Magic)
 	}
 
-	if hdr.Version != navMeshSetVersion {
-		return nil, fmt.Errorf("wrong version: %d", hdr.Version)
+	if err := checkSetVersion(hdr.Version); err != nil {
+		return nil, err
 	}
 
 	var mesh NavMesh
@@ -103,7 +111,7 @@ func Decode(r io.Reader) (*NavMesh, error) {
 			return nil, err
 		}
 
-		if tileHdr.TileRef == 0 || tileHdr.DataSize == 0 {
+		if tileHdr.TileRef == 0 || tileHdr.DataSize <= 0 {
 			break
 		}
 
@@ -111,7 +119,7 @@ func Decode(r io.Reader) (*NavMesh, error) {
 		if data == nil {
 			break
 		}
-		_, err = r.Read(data)
+		_, err = io.ReadFull(r, data)
 		if err != nil {
 			return nil, err
 		}
@@ -124,52 +132,18 @@ func Decode(r io.Reader) (*NavMesh, error) {
 }
 
 // SaveToFile saves the navigation mesh as a binary file.
+//
+// see Encode
 func (m *NavMesh) SaveToFile(fn string) error {
 	f, err := os.Create(fn)
 	if err != nil {
 		return err
 	}
-
-	// Store header.
-	var header navMeshSetHeader
-	header.Magic = navMeshSetMagic
-	header.Version = navMeshSetVersion
-	header.NumTiles = 0
-	for i := int32(0); i < m.MaxTiles; i++ {
-		if m.Tiles[i].DataSize == 0 {
-			continue
-		}
-		header.NumTiles++
-	}
-	header.Params = m.Params
-
-	if _, err = header.WriteTo(f); err != nil {
-		return fmt.Errorf("Error writing header: %v", err)
-	}
-
-	// Store tiles.
-	for i := int32(0); i < m.MaxTiles; i++ {
-		tile := &m.Tiles[i]
-		if tile.DataSize == 0 {
-			continue
-		}
-
-		var tileHeader navMeshTileHeader
-		tileHeader.TileRef = m.TileRef(tile)
-		tileHeader.DataSize = tile.DataSize
-		if _, err = tileHeader.WriteTo(f); err != nil {
-			return err
-		}
-		var data []byte = make([]byte, tile.DataSize)
-		// first Serialize the tile header
-		tile.Header.serialize(data)
-		// then the tile itself
-		tile.serialize(data[tile.Header.size():])
-		if _, err = f.Write(data); err != nil {
-			return err
-		}
+	if err = m.Encode(f); err != nil {
+		f.Close()
+		return err
 	}
-	return nil
+	return f.Close()
 }
 
 // InitForSingleTile set up the navigation mesh for single tile use.
@@ -184,6 +158,9 @@ func (m *NavMesh) SaveToFile(fn string) error {
 //	see CreateNavMeshData
 func (m *NavMesh) InitForSingleTile(data []uint8, flags int) Status {
 	var header MeshHeader
+	if len(data) < header.size() {
+		return Failure | InvalidParam
+	}
 	header.unserialize(data)
 
 	// Make sure the data is in right format.
@@ -217,6 +194,13 @@ func (m *NavMesh) InitForSingleTile(data []uint8, flags int) Status {
 //
 // Return the status flags for the operation.
 func (m *NavMesh) Init(params *NavMeshParams) Status {
+	// Make sure the tile and poly ids fit in a reference, along with at least
+	// 8 salt bits.
+	if params.MaxTiles > 1<<24 || params.MaxPolys > 1<<24 ||
+		math32.Ilog2(math32.NextPow2(params.MaxTiles))+math32.Ilog2(math32.NextPow2(params.MaxPolys)) > 24 {
+		return Failure | InvalidParam
+	}
+
 	m.Params = *params
 	m.Orig = d3.NewVec3From(params.Orig[0:3])
 	m.TileWidth = params.TileWidth
@@ -281,9 +265,15 @@ func (m *NavMesh) Init(params *NavMeshParams) Status {
 // not be reused in other nav meshes until the tile has been successfully
 // removed from this nav mesh.
 //
+// If SetBuildBvTrees has been called, the bounding volume tree of a tile
+// added without one is built.
+//
 // see CreateNavMeshData, removeTileBvTree
 func (m *NavMesh) AddTile(data []byte, lastRef TileRef) (Status, TileRef) {
 	var hdr MeshHeader
+	if len(data) < hdr.size() {
+		return Failure | InvalidParam, 0
+	}
 	hdr.unserialize(data)
 
 	// Make sure the data is in right format.
@@ -293,6 +283,16 @@ func (m *NavMesh) AddTile(data []byte, lastRef TileRef) (Status, TileRef) {
 	if hdr.Version != navMeshVersion {
 		return Failure | WrongVersion, 0
 	}
+	if !hdr.isValid() || len(data) < hdr.dataSize() {
+		return Failure | InvalidParam, 0
+	}
+
+	// Make sure the tile contents are consistent.
+	var contents MeshTile
+	contents.unserialize(&hdr, data[hdr.size():])
+	if !contents.isValid(&hdr) {
+		return Failure | InvalidParam, 0
+	}
 
 	// Make sure the location is free.
 	if m.TileAt(hdr.X, hdr.Y, hdr.Layer) != nil {
@@ -310,8 +310,7 @@ func (m *NavMesh) AddTile(data []byte, lastRef TileRef) (Status, TileRef) {
 	} else {
 		// Try to relocate the tile to specific index with same salt.
 		tileIndex := int32(m.decodePolyIDTile(PolyRef(lastRef)))
-		if tileIndex >= m.MaxTiles {
-			log.Fatalln("tileIndex >= m.m_maxTiles", tileIndex, m.MaxTiles)
+		if tileIndex < 0 || tileIndex >= m.MaxTiles {
 			return Failure | OutOfMemory, 0
 		}
 		// Try to find the specific tile id from the free list.
@@ -324,7 +323,6 @@ func (m *NavMesh) AddTile(data []byte, lastRef TileRef) (Status, TileRef) {
 		}
 		// Could not find the correct location.
 		if tile != target {
-			log.Fatalln("couldn't find the correct tile location")
 			return Failure | OutOfMemory, 0
 		}
 		// Remove from freelist
@@ -340,7 +338,6 @@ func (m *NavMesh) AddTile(data []byte, lastRef TileRef) (Status, TileRef) {
 
 	// Make sure we could allocate a tile.
 	if tile == nil {
-		log.Fatalln("couldn't allocate tile")
 		return Failure | OutOfMemory, 0
 	}
 
@@ -349,28 +346,56 @@ func (m *NavMesh) AddTile(data []byte, lastRef TileRef) (Status, TileRef) {
 	tile.Next = m.posLookup[h]
 	m.posLookup[h] = tile
 
-	tile.unserialize(&hdr, data[hdr.size():])
+	tile.Verts = contents.Verts
+	tile.Polys = contents.Polys
+	tile.Links = contents.Links
+	tile.DetailMeshes = contents.DetailMeshes
+	tile.DetailVerts = contents.DetailVerts
+	tile.DetailTris = contents.DetailTris
+	tile.BvTree = contents.BvTree
+	tile.OffMeshCons = contents.OffMeshCons
 
 	// If there are no items in the bvtree, reset the tree pointer.
 	if len(tile.BvTree) == 0 {
 		tile.BvTree = nil
 	}
 
-	// Build links freelist
-	tile.LinksFreeList = 0
-	tile.Links[hdr.MaxLinkCount-1].Next = nullLink
-
-	var i int32
-	for ; i < hdr.MaxLinkCount-1; i++ {
-		tile.Links[i].Next = uint32(i + 1)
-	}
-
 	// Init tile.
 	tile.Header = &hdr
 	tile.Data = make([]byte, len(data))
 	copy(tile.Data, data)
 	tile.DataSize = int32(len(data))
 	tile.Flags = 0
+	m.generation++
+	tile.generation = m.generation
+
+	if m.buildBvTrees && tile.BvTree == nil {
+		buildTileBvTree(tile)
+	}
+
+	m.connectTile(tile)
+
+	ref := m.TileRef(tile)
+	m.tileAdded(ref, tile)
+	return Success, ref
+}
+
+// connectTile builds the links of a tile, whose links are all free, and
+// connects it with its neighbours.
+func (m *NavMesh) connectTile(tile *MeshTile) {
+	hdr := tile.Header
+
+	// Build links freelist
+	tile.LinksFreeList = nullLink
+	if hdr.MaxLinkCount > 0 {
+		tile.LinksFreeList = 0
+		tile.Links[hdr.MaxLinkCount-1].Next = nullLink
+	}
+
+	var i int32
+	for ; i < hdr.MaxLinkCount-1; i++ {
+		tile.Links[i].Next = uint32(i + 1)
+	}
 
 	m.connectIntLinks(tile)
 
@@ -408,8 +433,6 @@ func (m *NavMesh) AddTile(data []byte, lastRef TileRef) (Status, TileRef) {
 			m.connectExtOffMeshLinks(neis[j], tile, oppositeTile(i))
 		}
 	}
-
-	return Success, m.TileRef(tile)
 }
 
 // Removes the specified tile from the navigation mesh.
@@ -442,6 +465,8 @@ func (m *NavMesh) RemoveTile(ref TileRef) (data []uint8, st Status) {
 		data = tile.Data
 	}
 
+	m.tileRemoved(ref, tile)
+
 	// Remove tile from hash lookup.
 	h := computeTileHash(tile.Header.X, tile.Header.Y, m.TileLUTMask)
 	var (
@@ -462,28 +487,7 @@ func (m *NavMesh) RemoveTile(ref TileRef) (data []uint8, st Status) {
 	}
 
 	// Remove connections to neighbour tiles.
-	const MAX_NEIS = 32
-	var (
-		neis  [MAX_NEIS]*MeshTile
-		nneis int
-	)
-
-	// Disconnect from other layers in current tile.
-	nneis = int(m.TilesAt(tile.Header.X, tile.Header.Y, neis[:], MAX_NEIS))
-	for j := 0; j < nneis; j++ {
-		if neis[j] == tile {
-			continue
-		}
-		m.unconnectLinks(neis[j], tile)
-	}
-
-	// Disconnect from neighbour tiles.
-	for i := 0; i < 8; i++ {
-		nneis = int(m.neighbourTilesAt(tile.Header.X, tile.Header.Y, int32(i), neis[:], MAX_NEIS))
-		for j := 0; j < nneis; j++ {
-			m.unconnectLinks(neis[j], tile)
-		}
-	}
+	m.disconnectTile(tile)
 
 	tile.Header = nil
 	tile.Flags = 0
@@ -496,6 +500,7 @@ func (m *NavMesh) RemoveTile(ref TileRef) (data []uint8, st Status) {
 	tile.DetailTris = nil
 	tile.BvTree = nil
 	tile.OffMeshCons = nil
+	tile.WallDistances = nil
 	tile.Data = nil
 	tile.DataSize = 0
 
@@ -512,6 +517,64 @@ func (m *NavMesh) RemoveTile(ref TileRef) (data []uint8, st Status) {
 	return data, Success
 }
 
+// disconnectTile removes the links of the neighbours of a tile to that tile.
+func (m *NavMesh) disconnectTile(tile *MeshTile) {
+	const MAX_NEIS = 32
+	var (
+		neis  [MAX_NEIS]*MeshTile
+		nneis int
+	)
+
+	// Disconnect from other layers in current tile.
+	nneis = int(m.TilesAt(tile.Header.X, tile.Header.Y, neis[:], MAX_NEIS))
+	for j := 0; j < nneis; j++ {
+		if neis[j] == tile {
+			continue
+		}
+		m.unconnectLinks(neis[j], tile)
+	}
+
+	// Disconnect from neighbour tiles.
+	for i := 0; i < 8; i++ {
+		nneis = int(m.neighbourTilesAt(tile.Header.X, tile.Header.Y, int32(i), neis[:], MAX_NEIS))
+		for j := 0; j < nneis; j++ {
+			m.unconnectLinks(neis[j], tile)
+		}
+	}
+}
+
+// RelinkTile rebuilds all the links of a tile, and the links of its
+// neighbours to it.
+//
+//	Arguments:
+//	 ref      The reference of the tile to relink.
+//
+// This is meant to restore a consistent link state after the polygons, the
+// vertices or the off-mesh connections of a tile have been modified in place,
+// without removing and adding back the tile. The internal links are built
+// again, the off-mesh connections are based again to their starting polygons
+// and the tile is connected again to its neighbours, as done by AddTile.
+//
+// The tile header must still match its content, in particular the links
+// needed by the modified tile must not exceed MaxLinkCount. The references
+// to the tile polygons stay valid, but the generation of the tile is updated.
+// (See: RefGeneration)
+//
+// see AddTile
+func (m *NavMesh) RelinkTile(ref TileRef) Status {
+	tile := m.TileByRef(ref)
+	if tile == nil || tile.Header == nil {
+		return Failure | InvalidParam
+	}
+
+	m.disconnectTile(tile)
+	m.generation++
+	tile.generation = m.generation
+	m.connectTile(tile)
+
+	return Success
+}
+
 // TileAt returns the tile at the specified grid location.
 //
 //	Arguments:
@@ -831,16 +894,15 @@ func (m *NavMesh) FindNearestPolyInTile(tile *MeshTile, center, extents, nearest
 	bmax := center.Add(extents)
 
 	// Get nearby polygons from proximity grid.
-	var polys [128]PolyRef
-	polyCount := m.queryPolygonsInTile(tile, bmin, bmax, polys[:], 128)
+	var buf [128]PolyRef
+	polys := m.QueryPolygonsInTile(tile, bmin, bmax, buf[:0])
 
 	// Find nearest polygon amongst the nearby polygons.
 	var (
 		nearest            PolyRef
 		nearestDistanceSqr float32 = math.MaxFloat32
 	)
-	for i := int32(0); i < polyCount; i++ {
-		ref := polys[i]
+	for _, ref := range polys {
 		var (
 			posOverPoly bool
 			d           float32
@@ -872,56 +934,39 @@ func (m *NavMesh) FindNearestPolyInTile(tile *MeshTile, center, extents, nearest
 	return nearest
 }
 
-// QueryPolygonsInTile queries polygons within a tile.
-func (m *NavMesh) queryPolygonsInTile(
-	tile *MeshTile,
-	qmin, qmax d3.Vec3,
-	polys []PolyRef,
-	maxPolys int32) int32 {
+// QueryPolygonsInTile appends to polys the references of the polygons of a
+// tile whose bounds overlap the box (qmin, qmax), and returns the extended
+// slice.
+//
+// The tile BV tree is used if it has one, in which case the polygon bounds are
+// the quantized bounds of the tree nodes, which include the detail meshes. Off-
+// mesh connections are never returned.
+func (m *NavMesh) QueryPolygonsInTile(tile *MeshTile, qmin, qmax d3.Vec3, polys []PolyRef) []PolyRef {
+	var ts TileQueryStats
+	return m.queryPolygonsInTile(tile, qmin, qmax, polys, &ts)
+}
 
-	if tile.BvTree != nil {
-		var (
-			node            *BvNode
-			nodeIdx, endIdx int32
-			tbmin, tbmax    d3.Vec3
-			qfac            float32
-			bmin, bmax      [3]uint16
-		)
-		nodeIdx = 0
-		endIdx = tile.Header.BvNodeCount
-
-		tbmin = d3.NewVec3From(tile.Header.BMin[:])
-		tbmax = d3.NewVec3From(tile.Header.BMax[:])
-		qfac = tile.Header.BvQuantFactor
-
-		// Calculate quantized box
-		// clamp query box to world box.
-		minx := f32.Clamp(qmin[0], tbmin[0], tbmax[0]) - tbmin[0]
-		miny := f32.Clamp(qmin[1], tbmin[1], tbmax[1]) - tbmin[1]
-		minz := f32.Clamp(qmin[2], tbmin[2], tbmax[2]) - tbmin[2]
-		maxx := f32.Clamp(qmax[0], tbmin[0], tbmax[0]) - tbmin[0]
-		maxy := f32.Clamp(qmax[1], tbmin[1], tbmax[1]) - tbmin[1]
-		maxz := f32.Clamp(qmax[2], tbmin[2], tbmax[2]) - tbmin[2]
-		// Quantize
-		bmin[0] = uint16(uint32(qfac*minx) & 0xfffe)
-		bmin[1] = uint16(uint32(qfac*miny) & 0xfffe)
-		bmin[2] = uint16(uint32(qfac*minz) & 0xfffe)
-		bmax[0] = uint16(uint32(qfac*maxx+1) | 1)
-		bmax[1] = uint16(uint32(qfac*maxy+1) | 1)
-		bmax[2] = uint16(uint32(qfac*maxz+1) | 1)
-
-		// Traverse tree
-		base := m.polyRefBase(tile)
-		var n int32
-		for nodeIdx < endIdx {
-			node = &tile.BvTree[nodeIdx]
+// queryPolygonsInTile is QueryPolygonsInTile, also counting the BV nodes
+// visited and the polygons tested into ts.
+func (m *NavMesh) queryPolygonsInTile(tile *MeshTile, qmin, qmax []float32, polys []PolyRef, ts *TileQueryStats) []PolyRef {
+	base := m.polyRefBase(tile)
+
+	if len(tile.BvTree) > 0 {
+		ts.HasBvTree = true
+		bmin, bmax := quantizeQueryBox(tile.Header, qmin, qmax)
+
+		// Traverse tree, the nodes following the subtree of the root are
+		// unused.
+		endIdx := bvTreeSize(tile.BvTree)
+		for nodeIdx := int32(0); nodeIdx < endIdx; {
+			node := &tile.BvTree[nodeIdx]
 			overlap := OverlapQuantBounds(bmin[:], bmax[:], node.BMin[:], node.BMax[:])
 			isLeafNode := node.I >= 0
-
-			if isLeafNode && overlap {
-				if n < maxPolys {
-					n++
-					polys[n] = base | PolyRef(node.I)
+			ts.BvNodes++
+			if isLeafNode {
+				ts.PolysTested++
+				if overlap {
+					polys = append(polys, base|PolyRef(node.I))
 				}
 			}
 
@@ -932,40 +977,36 @@ func (m *NavMesh) queryPolygonsInTile(
 				nodeIdx += escapeIndex
 			}
 		}
-
-		return n
-
+		return polys
 	}
 
-	var (
-		bmin, bmax [3]float32
-		n, i       int32
-	)
-	base := m.polyRefBase(tile)
-	for i = 0; i < tile.Header.PolyCount; i++ {
+	var bmin, bmax [3]float32
+	for i := int32(0); i < tile.Header.PolyCount; i++ {
 		p := &tile.Polys[i]
 		// Do not return off-mesh connection polygons.
 		if p.Type() == polyTypeOffMeshConnection {
 			continue
 		}
-		// Calc polygon bounds.
-		v := tile.Verts[p.Verts[0]*3 : 3]
-		d3.Vec3(bmin[:]).Assign(v)
-		d3.Vec3(bmax[:]).Assign(v)
-		var j uint8
-		for j = 1; j < p.VertCount; j++ {
-			v = tile.Verts[p.Verts[j]*3 : 3]
-			d3.Vec3Min(bmin[:], v)
-			d3.Vec3Max(bmax[:], v)
-		}
+		ts.PolysTested++
+		polyBounds(tile, p, bmin[:], bmax[:])
 		if OverlapBounds(qmin, qmax, bmin[:], bmax[:]) {
-			if n < maxPolys {
-				n++
-				polys[n] = base | PolyRef(i)
-			}
+			polys = append(polys, base|PolyRef(i))
 		}
 	}
-	return n
+	return polys
+}
+
+// quantizeQueryBox returns the box (qmin, qmax), clamped to the bounds of a
+// tile and quantized as the nodes of its BV tree.
+func quantizeQueryBox(hdr *MeshHeader, qmin, qmax []float32) (bmin, bmax [3]uint16) {
+	qfac := hdr.BvQuantFactor
+	for k := 0; k < 3; k++ {
+		minv := f32.Clamp(qmin[k], hdr.BMin[k], hdr.BMax[k]) - hdr.BMin[k]
+		maxv := f32.Clamp(qmax[k], hdr.BMin[k], hdr.BMax[k]) - hdr.BMin[k]
+		bmin[k] = uint16(uint32(qfac*minv) & 0xfffe)
+		bmax[k] = uint16(uint32(qfac*maxv+1) | 1)
+	}
+	return bmin, bmax
 }
 
 // decodePolyIdPoly extracts the polygon's index (within its tile) from the
@@ -984,96 +1025,151 @@ func (m *NavMesh) decodePolyIDPoly(ref PolyRef) uint32 {
 //	 [in] pos          The position to check. [(x, y, z)]
 //	 [out]closest      The closest point on the polygon. [(x, y, z)]
 //	 [out]posOverPoly  True of the position is over the polygon.
+//
+// When pos is over the polygon, closest is right below, or above, it, on the
+// detail surface. Otherwise closest is on the nearest boundary edge of the
+// detail mesh, so that its height is exactly the one of the detail surface at
+// the polygon edge.
 func (m *NavMesh) closestPointOnPoly(ref PolyRef, pos, closest d3.Vec3, posOverPoly *bool) {
 	var (
 		tile *MeshTile
 		poly *Poly
 	)
-
 	m.TileAndPolyByRefUnsafe(ref, &tile, &poly)
 
-	// Off-mesh connections don't have detail polygons.
-	if poly.Type() == polyTypeOffMeshConnection {
-		var (
-			v0, v1    d3.Vec3
-			d0, d1, u float32
-		)
-		v0 = tile.Verts[poly.Verts[0]*3 : 3]
-		v1 = tile.Verts[poly.Verts[1]*3 : 3]
-		d0 = pos.Dist(v0)
-		d1 = pos.Dist(v1)
-		u = d0 / (d0 + d1)
-		closest = v0.Lerp(v1, u)
+	closest.Assign(pos)
+	if m.polyHeight(tile, poly, pos, &closest[1]) {
 		if posOverPoly != nil {
-			*posOverPoly = false
+			*posOverPoly = true
 		}
 		return
 	}
+	if posOverPoly != nil {
+		*posOverPoly = false
+	}
 
-	ip := (uintptr(unsafe.Pointer(poly)) - uintptr(unsafe.Pointer(&tile.Polys[0]))) / unsafe.Sizeof(*poly)
-	pd := &tile.DetailMeshes[uint32(ip)]
+	// Off-mesh connections don't have detail polygons.
+	if poly.Type() == polyTypeOffMeshConnection {
+		v0 := d3.Vec3(tile.Verts[poly.Verts[0]*3 : poly.Verts[0]*3+3])
+		v1 := d3.Vec3(tile.Verts[poly.Verts[1]*3 : poly.Verts[1]*3+3])
+		var t float32
+		distancePtSegSqr2D(pos, v0, v1, &t)
+		d3.Vec3Lerp(closest, v0, v1, t)
+		return
+	}
 
-	// Clamp point to be inside the polygon.
-	verts := make([]float32, VertsPerPolygon*3)
-	edged := make([]float32, VertsPerPolygon)
-	edget := make([]float32, VertsPerPolygon)
-	nv := poly.VertCount
-	var i uint8
-	for i = 0; i < nv; i++ {
-		idx := i * 3
-		jdx := poly.Verts[i] * 3
-		copy(verts[idx:], tile.Verts[jdx:jdx+3])
+	// Outside poly that is not an off-mesh connection. The tiles built
+	// without the detail edge flags have no boundary edges, then all the
+	// detail edges are searched.
+	if !closestPointOnDetailEdges(tile, poly, pos, closest, true) {
+		closestPointOnDetailEdges(tile, poly, pos, closest, false)
 	}
+}
 
-	closest.Assign(pos)
-	if !distancePtPolyEdgesSqr(pos, verts, int32(nv), edged, edget) {
-		// Point is outside the polygon, clamp to nearest edge.
-		dmin := edged[0]
-		var imin uint8
-		for i = 1; i < nv; i++ {
-			if edged[i] < dmin {
-				dmin = edged[i]
-				imin = i
-			}
-		}
-		va := d3.NewVec3From(verts[imin*3 : imin*3+3])
-		vidx := ((imin + 1) % nv) * 3
-		vb := d3.NewVec3From(verts[vidx : vidx+3])
-		closest = va.Lerp(vb, edget[imin])
+// polyHeight finds the height of the detail surface of a polygon at the
+// provided position.
+//
+//	Arguments:
+//	 [in] tile     The tile of the polygon.
+//	 [in] poly     The polygon.
+//	 [in] pos      The position. [(x, y, z)]
+//	 [out]height   The height of the detail surface. [opt]
+//
+// Returns true if pos is within the xz-bounds of the polygon. Off-mesh
+// connections have no surface, polyHeight always returns false for them.
+func (m *NavMesh) polyHeight(tile *MeshTile, poly *Poly, pos d3.Vec3, height *float32) bool {
+	// Off-mesh connections do not have detail polys and getting height
+	// over them does not make sense.
+	if poly.Type() == polyTypeOffMeshConnection {
+		return false
+	}
 
-		if posOverPoly != nil {
-			*posOverPoly = false
-		}
-	} else {
-		if posOverPoly != nil {
-			*posOverPoly = true
-		}
+	var verts [VertsPerPolygon * 3]float32
+	nv := int(poly.VertCount)
+	for i := 0; i < nv; i++ {
+		copy(verts[i*3:i*3+3], tile.Verts[poly.Verts[i]*3:poly.Verts[i]*3+3])
+	}
+	if !pointInPolygon(pos, verts[:], nv) {
+		return false
+	}
+	if height == nil {
+		return true
 	}
 
 	// Find height at the location.
-	var j uint8
-	for j = 0; j < pd.TriCount; j++ {
-		vidx := (pd.TriBase + uint32(j)) * 4
-		t := tile.DetailTris[vidx : vidx+3]
-		var (
-			v [3]d3.Vec3
-			k int
-		)
-		for k = 0; k < 3; k++ {
-			if t[k] < poly.VertCount {
-				vidx := poly.Verts[t[k]] * 3
-				v[k] = tile.Verts[vidx : vidx+3]
-			} else {
-				vidx := (pd.VertBase + uint32(t[k]-poly.VertCount)) * 3
-				v[k] = tile.DetailVerts[vidx : vidx+3]
-			}
-		}
+	ip := (uintptr(unsafe.Pointer(poly)) - uintptr(unsafe.Pointer(&tile.Polys[0]))) / unsafe.Sizeof(*poly)
+	pd := &tile.DetailMeshes[uint32(ip)]
+	for j := uint8(0); j < pd.TriCount; j++ {
+		v := tile.detailTriVerts(poly, pd, j)
 		var h float32
-		if closestHeightPointTriangle(closest, v[0], v[1], v[2], &h) {
-			closest[1] = h
-			break
+		if closestHeightPointTriangle(pos, v[0], v[1], v[2], &h) {
+			*height = h
+			return true
 		}
 	}
+
+	// If all triangle checks failed above (can happen with degenerate
+	// triangles or larger floating point values) the point is on an edge, so
+	// just select closest. This should almost never happen so the extra
+	// iteration here is ok.
+	var closest [3]float32
+	closestPointOnDetailEdges(tile, poly, pos, closest[:], false)
+	*height = closest[1]
+	return true
+}
+
+// detailEdgeBoundary is the flag of the detail triangle edges lying on the
+// polygon boundary.
+const detailEdgeBoundary = 0x01
+
+// detailTriEdgeFlags returns the flags of the edge i of a detail triangle.
+func detailTriEdgeFlags(triFlags uint8, i int) uint8 {
+	return (triFlags >> (uint(i) * 2)) & 0x3
+}
+
+// closestPointOnDetailEdges finds the point of the edges of the detail mesh
+// of a polygon the nearest to pos, on the xz-plane. If onlyBoundary is true,
+// only the edges on the polygon boundary are considered.
+//
+// Returns false, leaving closest untouched, if no edge has been considered.
+func closestPointOnDetailEdges(tile *MeshTile, poly *Poly, pos, closest d3.Vec3, onlyBoundary bool) bool {
+	const anyBoundaryEdge = detailEdgeBoundary<<0 | detailEdgeBoundary<<2 | detailEdgeBoundary<<4
+
+	ip := (uintptr(unsafe.Pointer(poly)) - uintptr(unsafe.Pointer(&tile.Polys[0]))) / unsafe.Sizeof(*poly)
+	pd := &tile.DetailMeshes[uint32(ip)]
+
+	var (
+		dmin       = float32(math.MaxFloat32)
+		tmin       float32
+		pmin, pmax d3.Vec3
+	)
+	for i := uint8(0); i < pd.TriCount; i++ {
+		tris := tile.DetailTris[(pd.TriBase+uint32(i))*4:]
+		if onlyBoundary && tris[3]&anyBoundaryEdge == 0 {
+			continue
+		}
+
+		v := tile.detailTriVerts(poly, pd, i)
+		for k, j := 0, 2; k < 3; j, k = k, k+1 {
+			if onlyBoundary && detailTriEdgeFlags(tris[3], j)&detailEdgeBoundary == 0 {
+				// Only looking at boundary edges and this is internal.
+				continue
+			}
+
+			var t float32
+			if d := distancePtSegSqr2D(pos, v[j], v[k], &t); d < dmin {
+				dmin = d
+				tmin = t
+				pmin = v[j]
+				pmax = v[k]
+			}
+		}
+	}
+	if pmin == nil {
+		return false
+	}
+	d3.Vec3Lerp(closest, pmin, pmax, tmin)
+	return true
 }
 
 // TileAndPolyByRefUnsafe returns the tile and polygon for the specified polygon
@@ -1614,6 +1710,179 @@ func (m *NavMesh) TileAndPolyByRef(ref PolyRef, tile **MeshTile, poly **Poly) St
 	return Success
 }
 
+// SetPolyFlags sets the user-defined flags for the specified polygon.
+//
+//	Arguments:
+//	 ref     The polygon reference.
+//	 flags   The new flags for the polygon.
+//
+// Return the status flags for the operation.
+func (m *NavMesh) SetPolyFlags(ref PolyRef, flags uint16) Status {
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if st := m.TileAndPolyByRef(ref, &tile, &poly); StatusFailed(st) {
+		return st
+	}
+	m.setPolyFlags(ref, poly, flags)
+	return Success
+}
+
+// PolyFlags returns the user-defined flags for the specified polygon.
+//
+//	Arguments:
+//	 ref     The polygon reference.
+//
+//	Return values:
+//	 flags   The polygon flags.
+//	 st      The status flags for the operation.
+func (m *NavMesh) PolyFlags(ref PolyRef) (flags uint16, st Status) {
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if st = m.TileAndPolyByRef(ref, &tile, &poly); StatusFailed(st) {
+		return
+	}
+	return poly.Flags, Success
+}
+
+// SetPolyArea sets the user-defined area for the specified polygon.
+//
+//	Arguments:
+//	 ref     The polygon reference.
+//	 area    The new area id for the polygon. [Limit: < maxAreas]
+//
+// Return the status flags for the operation.
+func (m *NavMesh) SetPolyArea(ref PolyRef, area uint8) Status {
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if st := m.TileAndPolyByRef(ref, &tile, &poly); StatusFailed(st) {
+		return st
+	}
+	poly.SetArea(area)
+	return Success
+}
+
+// PolyArea returns the user-defined area for the specified polygon.
+//
+//	Arguments:
+//	 ref     The polygon reference.
+//
+//	Return values:
+//	 area    The area id of the polygon.
+//	 st      The status flags for the operation.
+func (m *NavMesh) PolyArea(ref PolyRef) (area uint8, st Status) {
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if st = m.TileAndPolyByRef(ref, &tile, &poly); StatusFailed(st) {
+		return
+	}
+	return poly.Area(), Success
+}
+
+// SetFlagsForArea modifies the user-defined flags of all the polygons having a
+// given area id, in all the tiles of the navigation mesh.
+//
+//	Arguments:
+//	 area      The area id of the polygons to modify. [Limit: < maxAreas]
+//	 setMask   The flags to set.
+//	 clearMask The flags to clear.
+//
+//	Return values:
+//	 count     The number of modified polygons.
+//	 st        The status flags for the operation.
+//
+// Flags in clearMask are cleared before the ones in setMask are set, so a flag
+// present in both masks ends up set. Off-mesh connection polygons are
+// modified as well.
+//
+// This is the way to apply runtime events to whole areas (E.g. all the water
+// becomes walkable when frozen), without iterating over the tiles and
+// polygons.
+func (m *NavMesh) SetFlagsForArea(area uint8, setMask, clearMask uint16) (count int, st Status) {
+	if int32(area) >= maxAreas {
+		return 0, Failure | InvalidParam
+	}
+
+	for i := int32(0); i < m.MaxTiles; i++ {
+		tile := &m.Tiles[i]
+		if tile.Header == nil {
+			continue
+		}
+		base := m.polyRefBase(tile)
+		for j := int32(0); j < tile.Header.PolyCount; j++ {
+			poly := &tile.Polys[j]
+			if poly.Area() != area {
+				continue
+			}
+			flags := poly.Flags&^clearMask | setMask
+			if flags != poly.Flags {
+				m.setPolyFlags(base|PolyRef(j), poly, flags)
+				count++
+			}
+		}
+	}
+	return count, Success
+}
+
+// OffMeshConnectionPolyEndPoints returns the endpoints of an off-mesh
+// connection, ordered by direction of travel.
+//
+//	Arguments:
+//	 prevRef   The reference of the polygon before the connection.
+//	 polyRef   The reference of the off-mesh connection polygon.
+//	 startPos  The start position of the off-mesh connection. [(x, y, z)]
+//	 endPos    The end position of the off-mesh connection. [(x, y, z)]
+//
+// Off-mesh connections are stored in the navigation mesh as special 2-vertex
+// polygons with a single edge. At least one of the vertices is expected to be
+// inside a normal polygon. So an off-mesh connection is "entered" from a
+// normal polygon at one of its endpoints. This is the polygon identified by
+// the prevRef parameter.
+func (m *NavMesh) OffMeshConnectionPolyEndPoints(prevRef, polyRef PolyRef, startPos, endPos d3.Vec3) Status {
+	if polyRef == 0 || len(startPos) < 3 || len(endPos) < 3 {
+		return Failure | InvalidParam
+	}
+
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if st := m.TileAndPolyByRef(polyRef, &tile, &poly); StatusFailed(st) {
+		return st
+	}
+
+	// Make sure that the current poly is indeed off-mesh link.
+	if poly.Type() != polyTypeOffMeshConnection {
+		return Failure
+	}
+
+	// Figure out which way to hand out the vertices.
+	idx0, idx1 := 0, 1
+
+	// Find link that points to first vertex.
+	for i := poly.FirstLink; i != nullLink; i = tile.Links[i].Next {
+		if tile.Links[i].Edge == 0 {
+			if tile.Links[i].Ref != prevRef {
+				idx0, idx1 = 1, 0
+			}
+			break
+		}
+	}
+
+	v0 := poly.Verts[idx0] * 3
+	v1 := poly.Verts[idx1] * 3
+	copy(startPos, tile.Verts[v0:v0+3])
+	copy(endPos, tile.Verts[v1:v1+3])
+	return Success
+}
+
 // CalcTileLoc calculates the tile grid location for the specified world
 // position.
 //
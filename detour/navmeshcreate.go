@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
-	"unsafe"
+	"strings"
 
 	"github.com/arl/gogeo/f32"
 	"github.com/arl/gogeo/f32/d3"
@@ -289,11 +289,10 @@ func createBVTree(params *NavMeshCreateParams, nodes []BvNode) int32 {
 			it.BMax[1] = uint16(int32Clamp(int32((bmax[1]-params.BMin[1])*quantFactor), 0, 0xffff))
 			it.BMax[2] = uint16(int32Clamp(int32((bmax[2]-params.BMin[2])*quantFactor), 0, 0xffff))
 		} else {
-			panic("UNTESTED")
 			p := params.Polys[i*params.Nvp*2:]
-			it.BMin[0] = params.Verts[p[0]*3+0]
-			it.BMin[1] = params.Verts[p[0]*3+1]
-			it.BMin[2] = params.Verts[p[0]*3+2]
+			it.BMin[0] = params.Verts[int32(p[0])*3+0]
+			it.BMin[1] = params.Verts[int32(p[0])*3+1]
+			it.BMin[2] = params.Verts[int32(p[0])*3+2]
 
 			it.BMax[0] = it.BMin[0]
 			it.BMax[1] = it.BMin[1]
@@ -303,9 +302,9 @@ func createBVTree(params *NavMeshCreateParams, nodes []BvNode) int32 {
 				if p[j] == meshNullIdx {
 					break
 				}
-				x := params.Verts[p[j]*3+0]
-				y := params.Verts[p[j]*3+1]
-				z := params.Verts[p[j]*3+2]
+				x := params.Verts[int32(p[j])*3+0]
+				y := params.Verts[int32(p[j])*3+1]
+				z := params.Verts[int32(p[j])*3+2]
 
 				if x < it.BMin[0] {
 					it.BMin[0] = x
@@ -533,6 +532,112 @@ type NavMeshCreateParams struct {
 	BuildBvTree bool
 }
 
+// CreateParamsError is the error returned when NavMeshCreateParams are not
+// valid. It lists all the violated constraints.
+type CreateParamsError struct {
+	Problems []string // Description of each violated constraint.
+}
+
+func (e *CreateParamsError) Error() string {
+	return "invalid navmesh creation params: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the tile creation data.
+//
+// It returns nil if params are valid, or a *CreateParamsError listing all
+// the violated constraints, such as out of range counts, slices too short to
+// hold the given number of elements, or degenerate bounds.
+func (params *NavMeshCreateParams) Validate() error {
+	var problems []string
+	addf := func(format string, v ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, v...))
+	}
+	// minLen checks that a slice holds n elements.
+	minLen := func(name string, got, n int) {
+		if got < n {
+			addf("%s has %d elements, want at least %d", name, got, n)
+		}
+	}
+
+	// Polygon mesh.
+	validNvp := params.Nvp >= 3 && params.Nvp <= int32(VertsPerPolygon)
+	if !validNvp {
+		addf("Nvp is %d, want between 3 and %d", params.Nvp, VertsPerPolygon)
+	}
+	switch {
+	case params.VertCount <= 0:
+		addf("VertCount is %d, want at least 1", params.VertCount)
+	case params.VertCount >= 0xffff:
+		addf("VertCount is %d, want less than %d", params.VertCount, 0xffff)
+	default:
+		minLen("Verts", len(params.Verts), 3*int(params.VertCount))
+	}
+	if params.PolyCount <= 0 {
+		addf("PolyCount is %d, want at least 1", params.PolyCount)
+	} else {
+		npolys := int(params.PolyCount)
+		if validNvp {
+			minLen("Polys", len(params.Polys), npolys*2*int(params.Nvp))
+		}
+		minLen("PolyFlags", len(params.PolyFlags), npolys)
+		minLen("PolyAreas", len(params.PolyAreas), npolys)
+	}
+
+	// Height detail, optional.
+	if params.DetailMeshes == nil {
+		if params.DetailVertsCount != 0 || params.DetailTriCount != 0 {
+			addf("detail vertices or triangles without DetailMeshes")
+		}
+	} else {
+		if params.PolyCount > 0 {
+			minLen("DetailMeshes", len(params.DetailMeshes), 4*int(params.PolyCount))
+		}
+		if params.DetailVertsCount < 0 {
+			addf("DetailVertsCount is %d, want at least 0", params.DetailVertsCount)
+		} else {
+			minLen("DetailVerts", len(params.DetailVerts), 3*int(params.DetailVertsCount))
+		}
+		if params.DetailTriCount <= 0 {
+			addf("DetailTriCount is %d with DetailMeshes, want at least 1", params.DetailTriCount)
+		} else {
+			minLen("DetailTris", len(params.DetailTris), 4*int(params.DetailTriCount))
+		}
+	}
+
+	// Off-mesh connections, optional.
+	if n := int(params.OffMeshConCount); n < 0 {
+		addf("OffMeshConCount is %d, want at least 0", n)
+	} else if n > 0 {
+		minLen("OffMeshConVerts", len(params.OffMeshConVerts), 6*n)
+		minLen("OffMeshConRad", len(params.OffMeshConRad), n)
+		minLen("OffMeshConFlags", len(params.OffMeshConFlags), n)
+		minLen("OffMeshConAreas", len(params.OffMeshConAreas), n)
+		minLen("OffMeshConDir", len(params.OffMeshConDir), n)
+		if params.OffMeshConUserID != nil {
+			minLen("OffMeshConUserID", len(params.OffMeshConUserID), n)
+		}
+	}
+
+	// Tile and configuration.
+	if params.TileLayer < 0 {
+		addf("TileLayer is %d, want at least 0", params.TileLayer)
+	}
+	if params.BMin[0] >= params.BMax[0] || params.BMin[1] > params.BMax[1] || params.BMin[2] >= params.BMax[2] {
+		addf("degenerate bounds %v %v", params.BMin, params.BMax)
+	}
+	if params.Cs <= 0 {
+		addf("Cs is %g, want more than 0", params.Cs)
+	}
+	if params.Ch <= 0 {
+		addf("Ch is %g, want more than 0", params.Ch)
+	}
+
+	if len(problems) != 0 {
+		return &CreateParamsError{Problems: problems}
+	}
+	return nil
+}
+
 // CreateNavMeshData builds navigation mesh tile data from the provided tile
 // creation data.
 //
@@ -543,19 +648,13 @@ type NavMeshCreateParams struct {
 //
 // Return true if the tile data was successfully created.
 //
+// The params are first validated, the returned error is a *CreateParamsError
+// if they're not valid. (See: NavMeshCreateParams.Validate)
+//
 // see NavMesh, NavMesh.AddTile()
 func CreateNavMeshData(params *NavMeshCreateParams) ([]uint8, error) {
-	if params.Nvp > int32(VertsPerPolygon) {
-		return nil, fmt.Errorf("wrong value for params.Nvp")
-	}
-	if params.VertCount >= 0xffff {
-		return nil, fmt.Errorf("wrong value for params.VertCount")
-	}
-	if params.VertCount == 0 || params.Verts == nil {
-		return nil, fmt.Errorf("wrong value for params.VertCount or params.Verts")
-	}
-	if params.PolyCount == 0 || params.Polys == nil {
-		return nil, fmt.Errorf("wrong value for params.PolyCount or params.Polys")
+	if err := params.Validate(); err != nil {
+		return nil, err
 	}
 
 	nvp := params.Nvp
@@ -698,15 +797,15 @@ func CreateNavMeshData(params *NavMeshCreateParams) ([]uint8, error) {
 	// Calculate data size in order to allocate buffer
 	headerSize := hdr.size()
 	vertsSize := 4 * 3 * totVertCount
-	polysSize := int(unsafe.Sizeof(Poly{})) * totPolyCount
-	linksSize := int(unsafe.Sizeof(Link{})) * int(maxLinkCount)
-	detailMeshesSize := int(unsafe.Sizeof(PolyDetail{})) * int(params.PolyCount)
+	polysSize := polySize * totPolyCount
+	linksSize := linkSize * int(maxLinkCount)
+	detailMeshesSize := polyDetailSize * int(params.PolyCount)
 	detailVertsSize := 4 * 3 * int(uniqueDetailVertCount)
 	detailTrisSize := 4 * int(detailTriCount)
 	if params.BuildBvTree {
-		bvTreeSize = int(unsafe.Sizeof(BvNode{})) * int(params.PolyCount*2)
+		bvTreeSize = bvNodeSize * int(params.PolyCount*2)
 	}
-	offMeshConsSize := int(unsafe.Sizeof(OffMeshConnection{})) * int(storedOffMeshConCount)
+	offMeshConsSize := offMeshConnectionSize * int(storedOffMeshConCount)
 
 	dataSize := headerSize + vertsSize + polysSize + linksSize +
 		detailMeshesSize + detailVertsSize + detailTrisSize +
@@ -719,7 +818,10 @@ func CreateNavMeshData(params *NavMeshCreateParams) ([]uint8, error) {
 	navDMeshes := make([]PolyDetail, params.PolyCount)
 	navDVerts := make([]float32, 3*uniqueDetailVertCount)
 	navDTris := make([]uint8, 4*detailTriCount)
-	navBvtree := make([]BvNode, params.PolyCount*2)
+	var navBvtree []BvNode
+	if params.BuildBvTree {
+		navBvtree = make([]BvNode, params.PolyCount*2)
+	}
 	offMeshCons := make([]OffMeshConnection, storedOffMeshConCount)
 
 	// Fill header
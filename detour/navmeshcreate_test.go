@@ -0,0 +1,76 @@
+package detour
+
+import (
+	"strings"
+	"testing"
+)
+
+// quadParams returns the creation params of a tile made of a single quad,
+// without height detail.
+func quadParams() *NavMeshCreateParams {
+	const nvp = 6
+	return &NavMeshCreateParams{
+		Verts:     []uint16{0, 0, 0, 0, 0, 10, 10, 0, 10, 10, 0, 0},
+		VertCount: 4,
+		Polys: []uint16{
+			0, 1, 2, 3, meshNullIdx, meshNullIdx,
+			meshNullIdx, meshNullIdx, meshNullIdx, meshNullIdx, meshNullIdx, meshNullIdx,
+		},
+		PolyFlags: []uint16{1},
+		PolyAreas: []uint8{0},
+		PolyCount: 1,
+		Nvp:       nvp,
+		BMin:      [3]float32{0, 0, 0},
+		BMax:      [3]float32{3, 1, 3},
+		Cs:        0.3,
+		Ch:        0.2,
+	}
+}
+
+func TestCreateNavMeshDataValidation(t *testing.T) {
+	if _, err := CreateNavMeshData(quadParams()); err != nil {
+		t.Fatalf("valid params, got error %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *NavMeshCreateParams)
+		want   []string // substrings of the problems
+	}{
+		{"nvp", func(p *NavMeshCreateParams) { p.Nvp = int32(VertsPerPolygon) + 1 }, []string{"Nvp is 7"}},
+		{"vert count", func(p *NavMeshCreateParams) { p.VertCount = 0xffff }, []string{"VertCount is 65535"}},
+		{"short verts", func(p *NavMeshCreateParams) { p.Verts = p.Verts[:6] }, []string{"Verts has 6 elements, want at least 12"}},
+		{"no polys", func(p *NavMeshCreateParams) { p.PolyCount = 0 }, []string{"PolyCount is 0"}},
+		{"detail", func(p *NavMeshCreateParams) {
+			p.DetailMeshes = []int32{0, 4, 0, 2}
+		}, []string{"DetailTriCount is 0"}},
+		{"detail without meshes", func(p *NavMeshCreateParams) { p.DetailTriCount = 2 }, []string{"without DetailMeshes"}},
+		{"off-mesh connections", func(p *NavMeshCreateParams) {
+			p.OffMeshConCount = 1
+			p.OffMeshConVerts = make([]float32, 6)
+		}, []string{"OffMeshConRad", "OffMeshConFlags", "OffMeshConAreas", "OffMeshConDir"}},
+		{"several problems", func(p *NavMeshCreateParams) {
+			p.BMax[0] = p.BMin[0]
+			p.Cs = 0
+			p.PolyFlags = nil
+		}, []string{"degenerate bounds", "Cs is 0", "PolyFlags has 0 elements"}},
+	}
+	for _, tt := range tests {
+		params := quadParams()
+		tt.modify(params)
+		_, err := CreateNavMeshData(params)
+		perr, ok := err.(*CreateParamsError)
+		if !ok {
+			t.Errorf("%s: got error %v, want a *CreateParamsError", tt.name, err)
+			continue
+		}
+		if len(perr.Problems) != len(tt.want) {
+			t.Errorf("%s: got problems %q, want %d", tt.name, perr.Problems, len(tt.want))
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q should contain %q", tt.name, err, want)
+			}
+		}
+	}
+}
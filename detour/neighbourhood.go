@@ -0,0 +1,379 @@
+package detour
+
+import (
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+// maxNeighbourhoodStack is the maximum number of polygons waiting to be
+// expanded by FindLocalNeighbourhood.
+const maxNeighbourhoodStack = 48
+
+// FindLocalNeighbourhood finds the non-overlapping navigation polygons in the
+// local neighbourhood around the center position.
+//
+//	Arguments:
+//	 startRef      The reference id of the polygon where the search starts.
+//	 centerPos     The center of the query circle. [(x, y, z)]
+//	 radius        The radius of the query circle.
+//	 filter        The polygon filter to apply to the query.
+//	 resultRef     The reference ids of the polygons touched by the circle.
+//	 resultParent  The reference ids of the parent polygons for each result.
+//	               Zero if a result polygon has no parent. [opt]
+//
+//	Return values:
+//	 resultCount   The number of polygons found.
+//	 st            The status flags for the query.
+//
+// This method is optimized for a small search radius and small number of
+// result polygons.
+//
+// Candidate polygons are found by searching the navigation graph beginning at
+// the start polygon.
+//
+// The value of the center point is used as the start point for cost
+// calculations. It is not projected onto the navigation mesh.
+//
+// The maximum number of polygons is len(resultRef), resultParent, if not nil,
+// must be at least as long.
+//
+// Note: this method uses the tiny node pool of q, it may not be used by
+// multiple clients at the same time.
+func (q *NavMeshQuery) FindLocalNeighbourhood(
+	startRef PolyRef,
+	centerPos d3.Vec3,
+	radius float32,
+	filter QueryFilter,
+	resultRef, resultParent []PolyRef) (resultCount int, st Status) {
+
+	// Validate input
+	if !q.nav.IsValidPolyRef(startRef) || !isValidVec3(centerPos) ||
+		!(radius >= 0) || math32.IsInf(radius, 0) || filter == nil ||
+		len(resultRef) == 0 || (resultParent != nil && len(resultParent) < len(resultRef)) {
+		return 0, Failure | InvalidParam
+	}
+
+	var (
+		stack  [maxNeighbourhoodStack]*Node
+		nstack int
+	)
+
+	q.tinyNodePool.Clear()
+
+	startNode := q.tinyNodePool.Node(startRef, 0)
+	startNode.PIdx = 0
+	startNode.ID = startRef
+	startNode.Flags = nodeClosed
+	stack[nstack] = startNode
+	nstack++
+
+	radiusSqr := math32.Sqr(radius)
+
+	var (
+		paBuf, pbBuf     [VertsPerPolygon * 3]float32
+		vaBuf, vbBuf     [3]float32
+		pa, pb           = paBuf[:], pbBuf[:]
+		va, vb           = d3.Vec3(vaBuf[:]), d3.Vec3(vbBuf[:])
+		maxResult        = len(resultRef)
+		n                int
+		curTile, neiTile *MeshTile
+		curPoly, neiPoly *Poly
+	)
+
+	st = Success
+
+	resultRef[n] = startNode.ID
+	if resultParent != nil {
+		resultParent[n] = 0
+	}
+	n++
+
+	for nstack > 0 {
+		// Pop front.
+		curNode := stack[0]
+		copy(stack[:nstack-1], stack[1:nstack])
+		nstack--
+
+		// Get poly and tile.
+		// The API input has been checked already, skip checking internal data.
+		curRef := curNode.ID
+		q.nav.TileAndPolyByRefUnsafe(curRef, &curTile, &curPoly)
+
+		for i := curPoly.FirstLink; i != nullLink; i = curTile.Links[i].Next {
+			link := &curTile.Links[i]
+			neighbourRef := link.Ref
+			// Skip invalid neighbours.
+			if neighbourRef == 0 {
+				continue
+			}
+
+			// Skip if cannot alloca more nodes.
+			neighbourNode := q.tinyNodePool.Node(neighbourRef, 0)
+			if neighbourNode == nil {
+				continue
+			}
+			// Skip visited.
+			if neighbourNode.Flags&nodeClosed != 0 {
+				continue
+			}
+
+			// Expand to neighbour
+			q.nav.TileAndPolyByRefUnsafe(neighbourRef, &neiTile, &neiPoly)
+
+			// Skip off-mesh connections.
+			if neiPoly.Type() == polyTypeOffMeshConnection {
+				continue
+			}
+
+			// Do not advance if the polygon is excluded by the filter.
+			if !filter.PassFilter(neighbourRef, neiTile, neiPoly) {
+				continue
+			}
+
+			// Find edge and calc distance to the edge.
+			if StatusFailed(q.portalPoints8(curRef, curPoly, curTile, neighbourRef, neiPoly, neiTile, va, vb)) {
+				continue
+			}
+
+			// If the circle is not touching the next polygon, skip it.
+			var tseg float32
+			if distancePtSegSqr2D(centerPos, va, vb, &tseg) > radiusSqr {
+				continue
+			}
+
+			// Mark node visited, this is done before the overlap test so that
+			// we will not visit the poly again if the test fails.
+			neighbourNode.Flags |= nodeClosed
+			neighbourNode.PIdx = q.tinyNodePool.NodeIdx(curNode)
+
+			// Check that the polygon does not collide with existing polygons.
+
+			// Collect vertices of the neighbour poly.
+			npa := int(neiPoly.VertCount)
+			for k := 0; k < npa; k++ {
+				copy(pa[k*3:k*3+3], neiTile.Verts[int(neiPoly.Verts[k])*3:])
+			}
+
+			overlap := false
+			for j := 0; j < n; j++ {
+				pastRef := resultRef[j]
+
+				// Connected polys do not overlap.
+				connected := false
+				for k := curPoly.FirstLink; k != nullLink; k = curTile.Links[k].Next {
+					if curTile.Links[k].Ref == pastRef {
+						connected = true
+						break
+					}
+				}
+				if connected {
+					continue
+				}
+
+				// Potentially overlapping.
+				var (
+					pastTile *MeshTile
+					pastPoly *Poly
+				)
+				q.nav.TileAndPolyByRefUnsafe(pastRef, &pastTile, &pastPoly)
+
+				// Get vertices and test overlap
+				npb := int(pastPoly.VertCount)
+				for k := 0; k < npb; k++ {
+					copy(pb[k*3:k*3+3], pastTile.Verts[int(pastPoly.Verts[k])*3:])
+				}
+
+				if overlapPolyPoly2D(pa, npa, pb, npb) {
+					overlap = true
+					break
+				}
+			}
+			if overlap {
+				continue
+			}
+
+			// This poly is fine, store and advance to the poly.
+			if n < maxResult {
+				resultRef[n] = neighbourRef
+				if resultParent != nil {
+					resultParent[n] = curRef
+				}
+				n++
+			} else {
+				st |= BufferTooSmall
+			}
+
+			if nstack < maxNeighbourhoodStack {
+				stack[nstack] = neighbourNode
+				nstack++
+			}
+		}
+	}
+
+	return n, st
+}
+
+// maxSegInterval is the maximum number of portal intervals on a polygon edge.
+const maxSegInterval = 16
+
+// segInterval is the interval of a polygon edge leading to a neighbour
+// polygon.
+type segInterval struct {
+	ref        PolyRef
+	tmin, tmax int16
+}
+
+// insertInterval inserts an interval in a list of intervals sorted by tmin.
+func insertInterval(ints []segInterval, nints *int, tmin, tmax int16, ref PolyRef) {
+	if *nints+1 > len(ints) {
+		return
+	}
+	// Find insertion point.
+	idx := 0
+	for idx < *nints {
+		if tmax <= ints[idx].tmin {
+			break
+		}
+		idx++
+	}
+	// Move current results.
+	copy(ints[idx+1:*nints+1], ints[idx:*nints])
+	// Store
+	ints[idx] = segInterval{ref: ref, tmin: tmin, tmax: tmax}
+	*nints++
+}
+
+// PolyWallSegments returns the segments of a polygon, optionally including
+// the portals.
+//
+//	Arguments:
+//	 ref           The reference id of the polygon.
+//	 filter        The polygon filter to apply to the query.
+//	 segmentVerts  The segments. [(ax, ay, az, bx, by, bz) * segmentCount]
+//	 segmentRefs   The reference ids of each segment's neighbor polygon, or
+//	               zero if the segment is a wall. [opt]
+//
+//	Return values:
+//	 segmentCount  The number of segments returned.
+//	 st            The status flags for the query.
+//
+// If segmentRefs is nil, only the wall segments are returned, otherwise the
+// portal segments are returned too. A segment is a portal if its neighbour
+// polygon passes the filter, and a wall otherwise.
+//
+// The maximum number of segments is len(segmentVerts)/6, segmentRefs, if not
+// nil, must be at least as long.
+//
+// Note: this method may be used by multiple clients without side effects.
+func (q *NavMeshQuery) PolyWallSegments(
+	ref PolyRef,
+	filter QueryFilter,
+	segmentVerts []float32,
+	segmentRefs []PolyRef) (segmentCount int, st Status) {
+
+	maxSegments := len(segmentVerts) / 6
+	if filter == nil || maxSegments == 0 ||
+		(segmentRefs != nil && len(segmentRefs) < maxSegments) {
+		return 0, Failure | InvalidParam
+	}
+
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if StatusFailed(q.nav.TileAndPolyByRef(ref, &tile, &poly)) {
+		return 0, Failure | InvalidParam
+	}
+
+	var (
+		n     int
+		ints  [maxSegInterval]segInterval
+		nints int
+	)
+	storePortals := segmentRefs != nil
+	st = Success
+
+	// addSegment stores the part of the edge from vj to vi between tmin and
+	// tmax.
+	addSegment := func(vj, vi d3.Vec3, tmin, tmax float32, ref PolyRef) {
+		if n >= maxSegments {
+			st |= BufferTooSmall
+			return
+		}
+		seg := segmentVerts[n*6 : n*6+6]
+		d3.Vec3Lerp(seg[0:3], vj, vi, tmin)
+		d3.Vec3Lerp(seg[3:6], vj, vi, tmax)
+		if segmentRefs != nil {
+			segmentRefs[n] = ref
+		}
+		n++
+	}
+
+	for i, j := 0, int(poly.VertCount)-1; i < int(poly.VertCount); j, i = i, i+1 {
+		vj := d3.Vec3(tile.Verts[int(poly.Verts[j])*3 : int(poly.Verts[j])*3+3])
+		vi := d3.Vec3(tile.Verts[int(poly.Verts[i])*3 : int(poly.Verts[i])*3+3])
+
+		// Skip non-solid edges.
+		nints = 0
+		if poly.Neis[j]&extLink != 0 {
+			// Tile border.
+			for k := poly.FirstLink; k != nullLink; k = tile.Links[k].Next {
+				link := &tile.Links[k]
+				if int(link.Edge) != j || link.Ref == 0 {
+					continue
+				}
+				var (
+					neiTile *MeshTile
+					neiPoly *Poly
+				)
+				q.nav.TileAndPolyByRefUnsafe(link.Ref, &neiTile, &neiPoly)
+				if filter.PassFilter(link.Ref, neiTile, neiPoly) {
+					insertInterval(ints[:], &nints, int16(link.BMin), int16(link.BMax), link.Ref)
+				}
+			}
+		} else {
+			// Internal edge
+			var neiRef PolyRef
+			if poly.Neis[j] != 0 {
+				idx := uint32(poly.Neis[j] - 1)
+				neiRef = q.nav.polyRefBase(tile) | PolyRef(idx)
+				if !filter.PassFilter(neiRef, tile, &tile.Polys[idx]) {
+					neiRef = 0
+				}
+			}
+
+			// If the edge leads to another polygon and portals are not stored, skip.
+			if neiRef != 0 && !storePortals {
+				continue
+			}
+
+			addSegment(vj, vi, 0, 1, neiRef)
+			continue
+		}
+
+		// Add sentinels
+		insertInterval(ints[:], &nints, -1, 0, 0)
+		insertInterval(ints[:], &nints, 255, 256, 0)
+
+		// Store segments.
+		for k := 1; k < nints; k++ {
+			// Portal segment.
+			if storePortals && ints[k].ref != 0 {
+				tmin := float32(ints[k].tmin) / 255.0
+				tmax := float32(ints[k].tmax) / 255.0
+				addSegment(vj, vi, tmin, tmax, ints[k].ref)
+			}
+
+			// Wall segment.
+			imin := ints[k-1].tmax
+			imax := ints[k].tmin
+			if imin != imax {
+				tmin := float32(imin) / 255.0
+				tmax := float32(imax) / 255.0
+				addSegment(vj, vi, tmin, tmax, 0)
+			}
+		}
+	}
+
+	return n, st
+}
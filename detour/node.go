@@ -1,7 +1,6 @@
 package detour
 
 import (
-	"log"
 	"unsafe"
 
 	assert "github.com/arl/assertgo"
@@ -28,13 +27,6 @@ const (
 	nodeParentDetached // parent of the node is not adjacent. Found using raycast.
 )
 
-// NodeIndex is the index of a node inside the pool.
-type NodeIndex uint16
-
-const (
-	nullIdx NodeIndex = ^NodeIndex(0)
-)
-
 const (
 	nodeParentBits uint32 = 24
 	nodeStateBits  uint32 = 2
@@ -55,6 +47,8 @@ type Node struct {
 	//Flags uint8
 	Flags NodeFlags
 	ID    PolyRef // Polygon ref the node corresponds to.
+
+	idx uint32 // Index of the node in its pool.
 }
 
 func newNode() Node {
@@ -69,53 +63,160 @@ const (
 
 // NodePool is a pool of nodes, it allocated them and allows
 // them to be reused.
+//
+// The nodes are indexed by polygon reference and state in an open-addressing
+// hash table. By default the pool holds a fixed number of nodes and the
+// searches fail with OutOfNodes when all of them are in use. With a growth
+// limit (See: SetGrowthLimit), the pool allocates more nodes instead, up to
+// that limit. The nodes already allocated never move, so pointers to them
+// stay valid when the pool grows.
 type NodePool struct {
-	nodes       []Node
-	first, next []NodeIndex
-	maxNodes    int32
-	hashSize    int32
-	nodeCount   int32
+	chunks    [][]Node // Allocated nodes, all the chunks but the last are full.
+	chunkSize int32
+	table     []uint32 // Open-addressing table of node indices + 1, 0 if empty.
+	maxNodes  int32
+	growLimit int32
+	nodeCount int32
+	stats     NodePoolStats
 }
 
-func newNodePool(maxNodes, hashSize int32) *NodePool {
-	np := &NodePool{
-		maxNodes: maxNodes,
-		hashSize: hashSize,
-	}
-	assert.True(math32.NextPow2(uint32(np.hashSize)) == uint32(np.hashSize),
-		"m_hashSize should be a power of 2")
+// NodePoolStats holds the usage statistics of a NodePool, to help choosing
+// the number of nodes of a NavMeshQuery.
+type NodePoolStats struct {
+	PeakNodeCount int32 // Maximum number of nodes simultaneously in use.
+	Grows         int32 // Number of times the pool has grown.
+	Exhausted     int32 // Number of node allocations that failed, the pool being full.
+}
 
+// maxPoolNodes is the maximum number of nodes in a pool, node indices + 1
+// being stored in nodeParentBits bits.
+const maxPoolNodes = int32(1<<nodeParentBits) - 2
+
+func newNodePool(maxNodes int32) *NodePool {
 	// pidx is special as 0 means "none" and 1 is the first node.
 	// For that reason we have 1 fewer nodes available than the
 	// number of values it can contain.
-	assert.True(np.maxNodes > 0 && np.maxNodes <= int32(nullIdx) &&
-		np.maxNodes <= (1<<nodeParentBits)-1, "NodePool, max nodes check failed")
+	assert.True(maxNodes > 0 && maxNodes <= maxPoolNodes,
+		"NodePool, max nodes check failed")
 
-	np.nodes = make([]Node, np.maxNodes)
-	for i := range np.nodes {
-		np.nodes[i] = newNode()
+	np := &NodePool{
+		chunkSize: maxNodes,
+		growLimit: maxNodes,
 	}
-	np.next = make([]NodeIndex, np.maxNodes)
-	np.first = make([]NodeIndex, hashSize)
+	np.addNodes(maxNodes)
+	np.table = make([]uint32, tableSize(maxNodes))
+	return np
+}
 
-	assert.True(len(np.nodes) > 0, "Nodes should not be empty")
-	assert.True(len(np.next) > 0, "Next should not be empty")
-	assert.True(len(np.first) > 0, "First should not be empty")
+// tableSize returns the size of the hash table for n nodes, keeping its load
+// factor under 0.5.
+func tableSize(n int32) int32 {
+	return int32(math32.NextPow2(uint32(2 * n)))
+}
 
-	for idx := range np.first {
-		np.first[idx] = nullIdx
+// addNodes allocates n more nodes.
+//
+// The chunks have a capacity of chunkSize nodes, and a partially used last
+// chunk is filled before a new one is allocated, so all the chunks but the
+// last one are full, as node expects, and the nodes never move.
+func (np *NodePool) addNodes(n int32) {
+	for n > 0 {
+		last := len(np.chunks) - 1
+		if last < 0 || len(np.chunks[last]) == cap(np.chunks[last]) {
+			np.chunks = append(np.chunks, make([]Node, 0, np.chunkSize))
+			last++
+		}
+		chunk := np.chunks[last]
+		m := int32(cap(chunk) - len(chunk))
+		if m > n {
+			m = n
+		}
+		for i := int32(0); i < m; i++ {
+			chunk = append(chunk, newNode())
+		}
+		np.chunks[last] = chunk
+		np.maxNodes += m
+		n -= m
 	}
-	for idx := range np.next {
-		np.next[idx] = nullIdx
+}
+
+// grow allocates more nodes, if the growth limit allows it, and returns
+// whether it did.
+func (np *NodePool) grow() bool {
+	n := np.growLimit - np.maxNodes
+	if n <= 0 {
+		return false
 	}
-	return np
+	if n > np.chunkSize {
+		n = np.chunkSize
+	}
+	np.addNodes(n)
+	np.stats.Grows++
+
+	if size := tableSize(np.maxNodes); size > int32(len(np.table)) {
+		// Rehash the nodes in use.
+		np.table = make([]uint32, size)
+		for i := int32(0); i < np.nodeCount; i++ {
+			node := np.node(uint32(i))
+			np.table[np.slot(node.ID, node.State)] = uint32(i + 1)
+		}
+	}
+	return true
+}
+
+// node returns the node at index i.
+func (np *NodePool) node(i uint32) *Node {
+	return &np.chunks[i/uint32(np.chunkSize)][i%uint32(np.chunkSize)]
+}
+
+// slot returns the slot of the hash table holding the node of a polygon
+// reference and state, or the empty slot where it should be inserted.
+func (np *NodePool) slot(id PolyRef, state uint8) uint32 {
+	mask := uint32(len(np.table) - 1)
+	s := hashRef(id) & mask
+	for {
+		i := np.table[s]
+		if i == 0 {
+			return s
+		}
+		if n := np.node(i - 1); n.ID == id && n.State == state {
+			return s
+		}
+		s = (s + 1) & mask
+	}
+}
+
+// SetGrowthLimit sets the maximum number of nodes the pool can grow to.
+//
+// When all the nodes are in use, the pool allocates more nodes instead of
+// failing, as long as it holds less than limit nodes. A limit lower than
+// the current number of nodes disables the growth, which is the default.
+// [Limit: <= 16777214]
+func (np *NodePool) SetGrowthLimit(limit int32) {
+	if limit > maxPoolNodes {
+		limit = maxPoolNodes
+	}
+	np.growLimit = limit
+}
+
+// GrowthLimit returns the maximum number of nodes the pool can grow to.
+func (np *NodePool) GrowthLimit() int32 {
+	if np.growLimit < np.maxNodes {
+		return np.maxNodes
+	}
+	return np.growLimit
 }
 
 // Clear clears the node pool.
 func (np *NodePool) Clear() {
-	assert.True(int(np.hashSize) == len(np.first), "np.HashSize == len(np.First)")
-	for idx := range np.first {
-		np.first[idx] = nullIdx
+	// Only empty the slots in use, there are less of them than slots.
+	mask := uint32(len(np.table) - 1)
+	for i := int32(0); i < np.nodeCount; i++ {
+		s := hashRef(np.node(uint32(i)).ID) & mask
+		for np.table[s] != uint32(i+1) {
+			s = (s + 1) & mask
+		}
+		np.table[s] = 0
 	}
 	np.nodeCount = 0
 }
@@ -125,50 +226,45 @@ func (np *NodePool) Clear() {
 // If there is none then allocate. There can be more than one node for the same
 // polyRef but with different extra state information
 func (np *NodePool) Node(id PolyRef, state uint8) *Node {
-	bucket := hashRef(id) & uint32(np.hashSize-1)
-
-	var i NodeIndex
-	i = np.first[bucket]
-	var node *Node
-	for i != nullIdx {
-		if np.nodes[i].ID == id && np.nodes[i].State == state {
-			return &np.nodes[i]
-		}
-		i = np.next[i]
+	s := np.slot(id, state)
+	if i := np.table[s]; i != 0 {
+		return np.node(i - 1)
 	}
 
 	if np.nodeCount >= np.maxNodes {
-		return nil
+		if !np.grow() {
+			np.stats.Exhausted++
+			return nil
+		}
+		// The table may have been resized.
+		s = np.slot(id, state)
 	}
 
-	i = NodeIndex(np.nodeCount)
+	i := uint32(np.nodeCount)
 	np.nodeCount++
+	if np.nodeCount > np.stats.PeakNodeCount {
+		np.stats.PeakNodeCount = np.nodeCount
+	}
 
 	// Init node
-	node = &np.nodes[i]
+	node := np.node(i)
 	node.PIdx = 0
 	node.Cost = 0
 	node.Total = 0
 	node.ID = id
 	node.State = state
 	node.Flags = 0
+	node.idx = i
 
-	np.next[i] = np.first[bucket]
-	np.first[bucket] = i
-
+	np.table[s] = i + 1
 	return node
 }
 
 // FindNode finds the node that corresponds to the given polygon reference and
 // having the given state
 func (np *NodePool) FindNode(id PolyRef, state uint8) *Node {
-	bucket := hashRef(id) & uint32(np.hashSize-1)
-	i := np.first[bucket]
-	for i != nullIdx {
-		if np.nodes[i].ID == id && np.nodes[i].State == state {
-			return &np.nodes[i]
-		}
-		i = np.next[i]
+	if i := np.table[np.slot(id, state)]; i != 0 {
+		return np.node(i - 1)
 	}
 	return nil
 }
@@ -182,17 +278,14 @@ func (np *NodePool) FindNodes(id PolyRef, nodes []*Node, maxNodes int32) uint32
 	assert.True(len(nodes) >= int(maxNodes), "nodes should contain at least maxNodes elements")
 
 	var n uint32
-	bucket := hashRef(id) & uint32(np.hashSize-1)
-	i := np.first[bucket]
-	for i != nullIdx {
-		if np.nodes[i].ID == id {
-			if n >= uint32(maxNodes) {
-				return n
-			}
-			nodes[n] = &np.nodes[i]
+	for state := uint8(0); state < uint8(maxStatesPerNode); state++ {
+		if n >= uint32(maxNodes) {
+			return n
+		}
+		if node := np.FindNode(id, state); node != nil {
+			nodes[n] = node
 			n++
 		}
-		i = np.next[i]
 	}
 	return n
 }
@@ -202,9 +295,7 @@ func (np *NodePool) NodeIdx(node *Node) uint32 {
 	if node == nil {
 		return 0
 	}
-
-	ip := (uintptr(unsafe.Pointer(node)) - uintptr(unsafe.Pointer(&np.nodes[0]))) / unsafe.Sizeof(*node)
-	return uint32(ip + 1)
+	return node.idx + 1
 }
 
 // NodeAtIdx returns the node at given index.
@@ -212,41 +303,39 @@ func (np *NodePool) NodeAtIdx(idx int32) *Node {
 	if idx == 0 {
 		return nil
 	}
-	return &np.nodes[idx-1]
+	return np.node(uint32(idx - 1))
 }
 
 // MemUsed returns the number of bytes currently in use in this
 // node pool.
 func (np *NodePool) MemUsed() int32 {
-	log.Fatal("use of unsafe in getMemUsed")
 	return int32(unsafe.Sizeof(*np)) +
-		int32(unsafe.Sizeof(Node{}))*np.maxNodes +
-		int32(unsafe.Sizeof(NodeIndex(0)))*np.maxNodes +
-		int32(unsafe.Sizeof(NodeIndex(0)))*np.hashSize
+		int32(unsafe.Sizeof(Node{}))*np.chunkSize*int32(len(np.chunks)) +
+		int32(unsafe.Sizeof(uint32(0)))*int32(len(np.table))
 }
 
-// MaxNodes returns the maximum number of nodes the pool can
-// contain.
+// MaxNodes returns the number of nodes the pool currently holds. It's the
+// maximum number of nodes it can contain, unless it can grow.
 func (np *NodePool) MaxNodes() int32 {
 	return np.maxNodes
 }
 
 // HashSize returns the hash size.
 func (np *NodePool) HashSize() int32 {
-	return np.hashSize
+	return int32(len(np.table))
 }
 
-// First returns the index of the first node.
-func (np *NodePool) First(bucket int32) NodeIndex {
-	return np.first[bucket]
+// NodeCount returns the current node count in the pool.
+func (np *NodePool) NodeCount() int32 {
+	return np.nodeCount
 }
 
-// Next returns the next node index after the given one.
-func (np *NodePool) Next(i int32) NodeIndex {
-	return np.next[i]
+// Stats returns the usage statistics of the pool.
+func (np *NodePool) Stats() NodePoolStats {
+	return np.stats
 }
 
-// NodeCount returns the current node count in the pool.
-func (np *NodePool) NodeCount() int32 {
-	return np.nodeCount
+// ResetStats resets the usage statistics of the pool.
+func (np *NodePool) ResetStats() {
+	np.stats = NodePoolStats{}
 }
@@ -0,0 +1,149 @@
+package detour
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func TestNodePoolGrowth(t *testing.T) {
+	np := newNodePool(4)
+
+	var nodes []*Node
+	for ref := PolyRef(1); ref <= 4; ref++ {
+		nodes = append(nodes, np.Node(ref, 0))
+	}
+	if np.Node(5, 0) != nil {
+		t.Fatal("full pool without growth limit should not allocate")
+	}
+	if st := np.Stats(); st.Exhausted != 1 || st.PeakNodeCount != 4 || st.Grows != 0 {
+		t.Errorf("got stats %+v", st)
+	}
+
+	np.SetGrowthLimit(10)
+	for ref := PolyRef(5); ref <= 10; ref++ {
+		node := np.Node(ref, 1)
+		if node == nil {
+			t.Fatalf("node %d not allocated, the pool should have grown", ref)
+		}
+		nodes = append(nodes, node)
+	}
+	if np.Node(11, 0) != nil {
+		t.Fatal("pool should not grow past its growth limit")
+	}
+	if st := np.Stats(); st.Exhausted != 2 || st.PeakNodeCount != 10 || st.Grows != 2 {
+		t.Errorf("got stats %+v", st)
+	}
+	if np.MaxNodes() != 10 {
+		t.Errorf("MaxNodes() = %d, want 10", np.MaxNodes())
+	}
+
+	// nodes didn't move
+	for i, node := range nodes {
+		ref, state := PolyRef(i+1), uint8(0)
+		if i >= 4 {
+			state = 1
+		}
+		if got := np.FindNode(ref, state); got != node {
+			t.Errorf("FindNode(%d, %d) = %p, want %p", ref, state, got, node)
+		}
+		if got := np.NodeAtIdx(int32(np.NodeIdx(node))); got != node {
+			t.Errorf("NodeAtIdx(NodeIdx(node %d)) = %p, want %p", ref, got, node)
+		}
+	}
+	if np.FindNode(5, 0) != nil {
+		t.Error("node 5 has been allocated with state 1, not 0")
+	}
+
+	np.Clear()
+	if np.NodeCount() != 0 || np.FindNode(1, 0) != nil || np.FindNode(10, 1) != nil {
+		t.Error("Clear should remove all the nodes")
+	}
+	np.ResetStats()
+	if st := np.Stats(); st != (NodePoolStats{}) {
+		t.Errorf("got stats %+v after reset", st)
+	}
+}
+
+func TestNodePoolRaiseGrowthLimit(t *testing.T) {
+	// The last chunk is partially used after the first growth, it must be
+	// filled before the next chunk is used.
+	np := newNodePool(100)
+	var nodes []*Node
+	alloc := func(n int) {
+		for i := 0; i < n; i++ {
+			node := np.Node(PolyRef(len(nodes)+1), 0)
+			if node == nil {
+				t.Fatalf("node %d not allocated", len(nodes)+1)
+			}
+			nodes = append(nodes, node)
+		}
+	}
+
+	np.SetGrowthLimit(150)
+	alloc(150)
+	np.SetGrowthLimit(300)
+	alloc(150)
+	if np.Node(301, 0) != nil {
+		t.Fatal("pool should not grow past its growth limit")
+	}
+	if np.MaxNodes() != 300 {
+		t.Errorf("MaxNodes() = %d, want 300", np.MaxNodes())
+	}
+
+	for i, node := range nodes {
+		ref := PolyRef(i + 1)
+		if node.ID != ref {
+			t.Fatalf("node %d has been overwritten by node %d", ref, node.ID)
+		}
+		if got := np.FindNode(ref, 0); got != node {
+			t.Errorf("FindNode(%d, 0) = %p, want %p", ref, got, node)
+		}
+		if got := np.NodeAtIdx(int32(np.NodeIdx(node))); got != node {
+			t.Errorf("NodeAtIdx(NodeIdx(node %d)) = %p, want %p", ref, got, node)
+		}
+	}
+}
+
+func TestFindPathNodePoolGrowth(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+	filter := NewStandardQueryFilter()
+	org := d3.Vec3{37.298489, -1.776901, 11.652311}
+	dst := d3.Vec3{42.457218, 7.797607, 17.778244}
+
+	findPath := func(q *NavMeshQuery) ([]PolyRef, Status) {
+		extents := d3.NewVec3XYZ(2, 4, 2)
+		_, orgRef, orgPos := q.FindNearestPoly(org, extents, filter)
+		_, dstRef, dstPos := q.FindNearestPoly(dst, extents, filter)
+		path := make([]PolyRef, 256)
+		n, st := q.FindPath(orgRef, dstRef, orgPos, dstPos, filter, path)
+		return path[:n], st
+	}
+
+	_, q := NewNavMeshQuery(mesh, 2048)
+	want, st := findPath(q)
+	if StatusFailed(st) || StatusDetail(st, PartialResult) {
+		t.Fatalf("FindPath failed with status 0x%x", st)
+	}
+	peak := q.NodePool().Stats().PeakNodeCount
+
+	// too few nodes
+	_, q = NewNavMeshQuery(mesh, 4)
+	if _, st = findPath(q); !StatusDetail(st, OutOfNodes) {
+		t.Fatalf("FindPath with 4 nodes should run out of nodes, got status 0x%x", st)
+	}
+
+	q.NodePool().SetGrowthLimit(2048)
+	got, st := findPath(q)
+	if StatusFailed(st) || StatusDetail(st, OutOfNodes|PartialResult) {
+		t.Fatalf("FindPath with a growable pool failed with status 0x%x", st)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got path %v, want %v", got, want)
+	}
+	if stats := q.NodePool().Stats(); stats.PeakNodeCount != peak || stats.Grows == 0 {
+		t.Errorf("got stats %+v, want a peak of %d nodes and some growth", stats, peak)
+	}
+}
@@ -1,7 +1,6 @@
 package detour
 
 import (
-	"log"
 	"unsafe"
 
 	assert "github.com/arl/assertgo"
@@ -66,6 +65,11 @@ func (q *nodeQueue) pop() *Node {
 }
 
 func (q *nodeQueue) push(node *Node) {
+	if int(q.size) == len(q.heap) {
+		// The node pool has grown.
+		q.heap = append(q.heap, nil)
+		q.capacity = int32(len(q.heap)) - 1
+	}
 	q.size++
 	q.bubbleUp(q.size-1, node)
 }
@@ -84,7 +88,6 @@ func (q *nodeQueue) empty() bool {
 }
 
 func (q *nodeQueue) memUsed() int32 {
-	log.Fatal("use of unsafe in memUsed")
 	return int32(unsafe.Sizeof(*q)) +
 		int32(unsafe.Sizeof(Node{}))*(q.capacity+1)
 }
@@ -0,0 +1,284 @@
+package detour
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+var nopanicIters = flag.Int("nopanic.iters", 500, "number of random queries per navmesh of the no-panic tests")
+
+// noPanicMeshes are the navmeshes the public queries are fed with random
+// inputs.
+var noPanicMeshes = []string{
+	"mesh1.bin", "mesh2.bin", "offmeshcons.bin",
+	"fixture/plane.bin", "fixture/donut.bin", "fixture/floors.bin", "fixture/rooms.bin", "fixture/tiled.bin",
+}
+
+// randVec3 returns a random position, possibly invalid (nil or too short).
+func randVec3(rng *rand.Rand, bmin, bmax []float32) d3.Vec3 {
+	switch rng.Intn(20) {
+	case 0:
+		return nil
+	case 1:
+		return d3.Vec3{1, 2}
+	}
+	v := d3.NewVec3()
+	for i := range v {
+		// extend the bounds so that some points lie outside of the mesh
+		ext := (bmax[i] - bmin[i]) * 0.2
+		v[i] = bmin[i] - ext + rng.Float32()*(bmax[i]-bmin[i]+2*ext)
+	}
+	return v
+}
+
+// randPolyRef returns a random polygon reference, valid most of the time.
+func randPolyRef(rng *rand.Rand, refs []PolyRef) PolyRef {
+	switch rng.Intn(10) {
+	case 0:
+		return 0
+	case 1:
+		return PolyRef(rng.Uint32())
+	case 2:
+		// valid tile and salt, out of range poly index
+		return refs[rng.Intn(len(refs))] | 0xffff
+	}
+	return refs[rng.Intn(len(refs))]
+}
+
+// allPolyRefs returns the references of all the polygons of a navmesh.
+func allPolyRefs(m *NavMesh) []PolyRef {
+	var refs []PolyRef
+	for i := int32(0); i < m.MaxTiles; i++ {
+		tile := &m.Tiles[i]
+		if tile.Header == nil {
+			continue
+		}
+		base := m.polyRefBase(tile)
+		for j := int32(0); j < tile.Header.PolyCount; j++ {
+			refs = append(refs, base|PolyRef(j))
+		}
+	}
+	return refs
+}
+
+// noPanic calls f and reports a test error if f panics.
+func noPanic(t *testing.T, name string, args []interface{}, f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("%s(%s) panicked: %v", name, fmt.Sprint(args...), r)
+		}
+	}()
+	f()
+}
+
+func TestPublicQueriesDontPanic(t *testing.T) {
+	for _, fname := range noPanicMeshes {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+
+		st, q := NewNavMeshQuery(mesh, 512)
+		if StatusFailed(st) {
+			t.Fatalf("query creation failed with status 0x%x", st)
+		}
+
+		refs := allPolyRefs(mesh)
+		bmin := mesh.Tiles[0].Header.BMin[:]
+		bmax := mesh.Tiles[0].Header.BMax[:]
+		for i := int32(0); i < mesh.MaxTiles; i++ {
+			if hdr := mesh.Tiles[i].Header; hdr != nil {
+				d3.Vec3Min(bmin, hdr.BMin[:])
+				d3.Vec3Max(bmax, hdr.BMax[:])
+			}
+		}
+
+		rng := rand.New(rand.NewSource(1))
+		filter := NewStandardQueryFilter()
+		for i := 0; i < *nopanicIters; i++ {
+			var (
+				ref0, ref1 = randPolyRef(rng, refs), randPolyRef(rng, refs)
+				pos0, pos1 = randVec3(rng, bmin, bmax), randVec3(rng, bmin, bmax)
+				ext        = randVec3(rng, []float32{0, 0, 0}, []float32{5, 5, 5})
+				args       = []interface{}{fname, " ", ref0, " ", ref1, " ", pos0, " ", pos1, " ", ext}
+				path       = make([]PolyRef, 1+rng.Intn(64))
+				npath      int
+			)
+
+			noPanic(t, "FindNearestPoly", args, func() {
+				q.FindNearestPoly(pos0, ext, filter)
+			})
+			noPanic(t, "ClosestPointOnPoly", args, func() {
+				var over bool
+				q.ClosestPointOnPoly(ref0, pos0, d3.NewVec3(), &over)
+			})
+			noPanic(t, "ClosestPointOnPolyBoundary", args, func() {
+				q.ClosestPointOnPolyBoundary(ref0, pos0, d3.NewVec3())
+			})
+			noPanic(t, "PolyHeight", args, func() {
+				q.PolyHeight(ref0, pos0)
+			})
+			noPanic(t, "IsValidPolyRef", args, func() {
+				q.IsValidPolyRef(ref0, filter)
+			})
+			noPanic(t, "FindPath", args, func() {
+				npath, _ = q.FindPath(ref0, ref1, pos0, pos1, filter, path)
+			})
+			noPanic(t, "FindStraightPath", args, func() {
+				n := 1 + rng.Intn(32)
+				straight := make([]d3.Vec3, n)
+				for j := range straight {
+					straight[j] = d3.NewVec3()
+				}
+				// also feed random, possibly invalid, corridors
+				corridor := path[:npath]
+				if rng.Intn(4) == 0 {
+					corridor = []PolyRef{ref0, ref1}
+				}
+				q.FindStraightPath(pos0, pos1, corridor, straight, make([]uint8, n), make([]PolyRef, n), int32(rng.Intn(4)))
+			})
+			noPanic(t, "Raycast", args, func() {
+				hit := RaycastHit{Path: make([]PolyRef, 16)}
+				q.Raycast(ref0, pos0, pos1, filter, rng.Intn(2), &hit, randPolyRef(rng, refs))
+			})
+			noPanic(t, "Raycast2", args, func() {
+				q.Raycast2(ref0, pos0, pos1, filter, d3.NewVec3(), path, len(path))
+			})
+			noPanic(t, "SlicedFindPath", args, func() {
+				st := q.InitSlicedFindPath(ref0, ref1, pos0, pos1, filter, uint32(rng.Intn(2)))
+				var iters int
+				for StatusInProgress(st) {
+					st = q.UpdateSlicedFindPath(rng.Intn(16), &iters)
+				}
+				if rng.Intn(2) == 0 {
+					q.FinalizeSlicedFindPath(path, len(path))
+				} else {
+					q.FinalizeSlicedFindPathPartial(path[:npath], npath, path, len(path))
+				}
+			})
+			noPanic(t, "FindNearestPolyEx", args, func() {
+				q.FindNearestPolyEx(pos0, ext, filter, d3.NewVec3())
+			})
+			noPanic(t, "FindNearestPoly2D", args, func() {
+				q.FindNearestPoly2D(pos0, ext, rng.Float32()*4, filter, d3.NewVec3())
+			})
+			noPanic(t, "PointInPoly", args, func() {
+				q.PointInPoly(ref0, pos0, rng.Float32()*4)
+			})
+			noPanic(t, "PolyCentroid", args, func() {
+				q.PolyCentroid(ref0)
+				q.PolyRepresentativePoint(ref0)
+			})
+			noPanic(t, "PortalWidth", args, func() {
+				q.PortalWidth(ref0, ref1)
+			})
+			noPanic(t, "FindNarrowPortal", args, func() {
+				q.FindNarrowPortal(path[:npath], rng.Float32()*2)
+			})
+			noPanic(t, "FindPathWithOptions", args, func() {
+				opts := FindPathOptions{HeuristicScale: rng.Float32() * 2}
+				q.FindPathWithOptions(ref0, ref1, pos0, pos1, filter, path, &opts)
+			})
+			noPanic(t, "FindStraightPathFlat", args, func() {
+				n := 1 + rng.Intn(32)
+				q.FindStraightPathFlat(pos0, pos1, path[:npath], make([]float32, n*3), make([]uint8, n), make([]PolyRef, n), int32(rng.Intn(4)))
+			})
+			noPanic(t, "MoveAlongSurface", args, func() {
+				q.MoveAlongSurface(ref0, pos0, pos1, filter, d3.NewVec3(), make([]PolyRef, 1+rng.Intn(16)))
+			})
+			noPanic(t, "SmoothPath", args, func() {
+				opts := SmoothPathOptions{StepSize: rng.Float32() * 2, Slop: rng.Float32()}
+				q.SmoothPath(pos0, pos1, path[:npath], filter, &opts, make([]d3.Vec3, 1+rng.Intn(64)))
+			})
+			noPanic(t, "ReplanPath", args, func() {
+				corridor := append([]PolyRef(nil), path...)
+				q.ReplanPath(corridor, npath, pos0, ref1, pos1, filter, rng.Intn(64))
+			})
+			noPanic(t, "FindLocalNeighbourhood", args, func() {
+				n := 1 + rng.Intn(16)
+				q.FindLocalNeighbourhood(ref0, pos0, rng.Float32()*10, filter, make([]PolyRef, n), make([]PolyRef, n))
+			})
+			noPanic(t, "PolyWallSegments", args, func() {
+				n := rng.Intn(16)
+				q.PolyWallSegments(ref0, filter, make([]float32, n*6), make([]PolyRef, n))
+			})
+			noPanic(t, "FindDistanceToWall", args, func() {
+				q.FindDistanceToWall(ref0, pos0, rng.Float32()*10, filter)
+			})
+			noPanic(t, "RefToken", args, func() {
+				tok := mesh.NewRefToken(ref0, pos0)
+				mesh.IsTokenValid(tok)
+				q.ResolveToken(&tok, ext, filter)
+				mesh.RefGeneration(ref0)
+			})
+			noPanic(t, "OffMeshConnectionPolyEndPoints", args, func() {
+				mesh.OffMeshConnectionPolyEndPoints(ref0, ref1, d3.NewVec3(), d3.NewVec3())
+			})
+			noPanic(t, "NavMesh accessors", args, func() {
+				var (
+					tile *MeshTile
+					poly *Poly
+				)
+				mesh.TileAndPolyByRef(ref0, &tile, &poly)
+				mesh.IsValidPolyRef(ref0)
+				mesh.PolyFlags(ref0)
+				mesh.PolyArea(ref0)
+				mesh.TileByRef(TileRef(ref0))
+			})
+		}
+	}
+}
+
+func TestAddTileWithInvalidDataDoesntPanic(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	var valid []byte
+	for i := int32(0); i < mesh.MaxTiles; i++ {
+		if mesh.Tiles[i].Header != nil {
+			valid = mesh.Tiles[i].Data
+			break
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		var data []byte
+		switch rng.Intn(3) {
+		case 0:
+			// random garbage
+			data = make([]byte, rng.Intn(512))
+			rng.Read(data)
+		default:
+			// truncated valid tile
+			data = append([]byte(nil), valid[:rng.Intn(len(valid))]...)
+		}
+		noPanic(t, "AddTile", []interface{}{len(data)}, func() {
+			if st, _ := mesh.AddTile(data, TileRef(rng.Uint32())); StatusSucceed(st) {
+				t.Errorf("AddTile with invalid data (len %d) should fail", len(data))
+			}
+		})
+	}
+}
+
+func TestDecodeCorruptedDataDoesntPanic(t *testing.T) {
+	valid, err := ioutil.ReadFile(filepath.Join("..", "testdata", "mesh2.bin"))
+	checkt(t, err)
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		data := append([]byte(nil), valid[:rng.Intn(len(valid))]...)
+		// flip some bytes
+		for j := rng.Intn(4); j > 0 && len(data) > 0; j-- {
+			data[rng.Intn(len(data))] = byte(rng.Intn(256))
+		}
+		noPanic(t, "Decode", []interface{}{len(data)}, func() {
+			Decode(bytes.NewReader(data))
+		})
+	}
+}
@@ -209,5 +209,18 @@ func TestOffMeshConnections(t *testing.T) {
 				t.Errorf("straightPath[%d] = %v, want %v", i, straightPath[i], tt.wantStraightPath[i])
 			}
 		}
+
+		// the spline path jumps along the off-mesh connections
+		spline := make([]d3.Vec3, 1+(straightPathCount-1)*4)
+		for i := range spline {
+			spline[i] = d3.NewVec3()
+		}
+		splineCount, st := query.SplinePath(straightPath[:straightPathCount], straightPathFlags, straightPathRefs, 4, filter, spline)
+		if StatusFailed(st) {
+			t.Fatal("query.SplinePath failed:", st)
+		}
+		if !spline[splineCount-1].Approx(straightPath[straightPathCount-1]) {
+			t.Errorf("spline path ends at %v, want %v", spline[splineCount-1], straightPath[straightPathCount-1])
+		}
 	}
 }
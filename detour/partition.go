@@ -0,0 +1,165 @@
+package detour
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ManifestFile is the name of the manifest of a navigation mesh encoded in a
+// directory, by EncodeDir.
+const ManifestFile = "navmesh.json"
+
+// dirManifest is the content of the manifest of a navigation mesh encoded in
+// a directory.
+type dirManifest struct {
+	Version uint32         `json:"version"`
+	Params  NavMeshParams  `json:"params"`
+	Tiles   []dirTileEntry `json:"tiles"`
+}
+
+// dirTileEntry describes a tile file of a navigation mesh encoded in a
+// directory.
+type dirTileEntry struct {
+	Ref   TileRef `json:"ref"`
+	X     int32   `json:"x"`
+	Y     int32   `json:"y"`
+	Layer int32   `json:"layer"`
+	File  string  `json:"file"`
+}
+
+// tileFileName returns the name of the file of the tile at (x, y, layer).
+func tileFileName(x, y, layer int32) string {
+	return fmt.Sprintf("tile_%d_%d_%d.bin", x, y, layer)
+}
+
+// tileData returns the serialized data of tile, as stored by AddTile.
+func tileData(tile *MeshTile) []byte {
+	data := make([]byte, tile.DataSize)
+	// first Serialize the tile header
+	tile.Header.serialize(data)
+	// then the tile itself
+	tile.serialize(data[tile.Header.size():])
+	return data
+}
+
+// EncodeDir writes the navigation mesh to directory dir, creating it if
+// needed, in the format read by DecodeDir.
+//
+// Instead of a single binary file, the navigation mesh is written as a small
+// JSON manifest (see ManifestFile), holding the parameters and the list of
+// tiles, plus one binary file per tile. Rebuilding some tiles of the mesh only
+// modifies their files, which makes the changes of a navigation mesh committed
+// in a version control system easy to review and merge.
+//
+// Tile files in dir that are not part of the navigation mesh anymore are
+// removed, other files are left untouched.
+func (m *NavMesh) EncodeDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	manifest := dirManifest{
+		Version: NavMeshSetVersion,
+		Params:  m.Params,
+		Tiles:   []dirTileEntry{},
+	}
+	written := make(map[string]bool)
+	for i := int32(0); i < m.MaxTiles; i++ {
+		tile := &m.Tiles[i]
+		if tile.DataSize == 0 {
+			continue
+		}
+
+		hdr := tile.Header
+		e := dirTileEntry{
+			Ref:   m.TileRef(tile),
+			X:     hdr.X,
+			Y:     hdr.Y,
+			Layer: hdr.Layer,
+			File:  tileFileName(hdr.X, hdr.Y, hdr.Layer),
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, e.File), tileData(tile), 0644); err != nil {
+			return err
+		}
+		manifest.Tiles = append(manifest.Tiles, e)
+		written[e.File] = true
+	}
+
+	buf, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+	if err := ioutil.WriteFile(filepath.Join(dir, ManifestFile), buf, 0644); err != nil {
+		return err
+	}
+
+	// remove the files of the tiles that don't exist anymore
+	stale, err := filepath.Glob(filepath.Join(dir, "tile_*.bin"))
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		if written[filepath.Base(path)] {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeDir reads a navigation mesh written by EncodeDir in directory dir and
+// returns it.
+//
+// Tiles are added with the reference they had when they were encoded.
+//
+// returned error will be different from nil in case of failure.
+func DecodeDir(dir string) (*NavMesh, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var manifest dirManifest
+	if err = json.Unmarshal(buf, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if err := checkSetVersion(manifest.Version); err != nil {
+		return nil, err
+	}
+
+	var mesh NavMesh
+	status := mesh.Init(&manifest.Params)
+	if StatusFailed(status) {
+		return nil, fmt.Errorf("status failed 0x%x", status)
+	}
+
+	for _, e := range manifest.Tiles {
+		// tile files are always in dir
+		if e.File != filepath.Base(e.File) {
+			return nil, fmt.Errorf("invalid tile file name: %q", e.File)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, e.File))
+		if err != nil {
+			return nil, err
+		}
+		var hdr MeshHeader
+		if len(data) < hdr.size() {
+			return nil, fmt.Errorf("%s: data size too small: %d", e.File, len(data))
+		}
+		hdr.unserialize(data)
+		if hdr.X != e.X || hdr.Y != e.Y || hdr.Layer != e.Layer {
+			return nil, fmt.Errorf("%s: tile is at (%d, %d, %d), manifest says (%d, %d, %d)",
+				e.File, hdr.X, hdr.Y, hdr.Layer, e.X, e.Y, e.Layer)
+		}
+		status, _ := mesh.AddTile(data, e.Ref)
+		if StatusFailed(status) {
+			return nil, fmt.Errorf("couldn't add tile %s, status: 0x%x", e.File, status)
+		}
+	}
+	return &mesh, nil
+}
@@ -0,0 +1,117 @@
+package detour
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func encodeBytes(t *testing.T, m *NavMesh) []byte {
+	var buf bytes.Buffer
+	checkt(t, m.Encode(&buf))
+	return buf.Bytes()
+}
+
+func TestEncodeDecodeDir(t *testing.T) {
+	for _, fname := range []string{"mesh1.bin", "offmeshcons.bin", "fixture/tiled.bin"} {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+
+		dir, err := ioutil.TempDir("", "navmesh")
+		checkt(t, err)
+		defer os.RemoveAll(dir)
+
+		if err = mesh.EncodeDir(dir); err != nil {
+			t.Fatalf("%s, EncodeDir failed: %v", fname, err)
+		}
+		decoded, err := DecodeDir(dir)
+		if err != nil {
+			t.Fatalf("%s, DecodeDir failed: %v", fname, err)
+		}
+
+		// both formats hold the same navigation mesh
+		if !bytes.Equal(encodeBytes(t, mesh), encodeBytes(t, decoded)) {
+			t.Errorf("%s, decoded mesh differs from the original", fname)
+		}
+
+		// encoding again doesn't modify the files
+		before, err := filepath.Glob(filepath.Join(dir, "*"))
+		checkt(t, err)
+		contents := make(map[string][]byte)
+		for _, path := range before {
+			contents[path], err = ioutil.ReadFile(path)
+			checkt(t, err)
+		}
+		checkt(t, decoded.EncodeDir(dir))
+		for path, want := range contents {
+			got, err := ioutil.ReadFile(path)
+			checkt(t, err)
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s, %s modified by a second encoding", fname, filepath.Base(path))
+			}
+		}
+	}
+}
+
+func TestEncodeDirRemovedTile(t *testing.T) {
+	mesh, err := loadTestNavMesh("fixture/tiled.bin")
+	checkt(t, err)
+
+	dir, err := ioutil.TempDir("", "navmesh")
+	checkt(t, err)
+	defer os.RemoveAll(dir)
+	checkt(t, mesh.EncodeDir(dir))
+
+	// an unrelated file is kept
+	other := filepath.Join(dir, "README")
+	checkt(t, ioutil.WriteFile(other, []byte("baked navmesh\n"), 0644))
+
+	ref := mesh.TileRefAt(1, 1, 0)
+	if ref == 0 {
+		t.Fatal("no tile at (1, 1, 0)")
+	}
+	if _, st := mesh.RemoveTile(ref); StatusFailed(st) {
+		t.Fatalf("RemoveTile failed with status 0x%x", st)
+	}
+	checkt(t, mesh.EncodeDir(dir))
+
+	if _, err = os.Stat(filepath.Join(dir, tileFileName(1, 1, 0))); !os.IsNotExist(err) {
+		t.Errorf("file of the removed tile should have been deleted, got %v", err)
+	}
+	if _, err = os.Stat(other); err != nil {
+		t.Errorf("unrelated file should have been kept, got %v", err)
+	}
+
+	decoded, err := DecodeDir(dir)
+	checkt(t, err)
+	if want, got := allPolyRefs(mesh), allPolyRefs(decoded); !reflect.DeepEqual(want, got) {
+		t.Errorf("decoded mesh has polys %x, want %x", got, want)
+	}
+}
+
+func TestDecodeDirErrors(t *testing.T) {
+	mesh, err := loadTestNavMesh("fixture/tiled.bin")
+	checkt(t, err)
+
+	dir, err := ioutil.TempDir("", "navmesh")
+	checkt(t, err)
+	defer os.RemoveAll(dir)
+
+	if _, err = DecodeDir(dir); err == nil {
+		t.Error("DecodeDir should fail without manifest")
+	}
+
+	checkt(t, mesh.EncodeDir(dir))
+	checkt(t, os.Remove(filepath.Join(dir, tileFileName(0, 0, 0))))
+	if _, err = DecodeDir(dir); err == nil {
+		t.Error("DecodeDir should fail with a missing tile file")
+	}
+
+	checkt(t, ioutil.WriteFile(filepath.Join(dir, ManifestFile), []byte("{"), 0644))
+	if _, err = DecodeDir(dir); err == nil {
+		t.Error("DecodeDir should fail with an invalid manifest")
+	}
+}
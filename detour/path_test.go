@@ -232,3 +232,271 @@ func TestFindPathSpecialCases(t *testing.T) {
 		}
 	}
 }
+
+func TestSlicedFindPathAnyAngle(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+
+	st, q := NewNavMeshQuery(mesh, 1000)
+	if StatusFailed(st) {
+		t.Fatalf("query creation failed with status 0x%x", st)
+	}
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	_, orgRef, org := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, filter)
+	_, dstRef, dst := q.FindNearestPoly(d3.Vec3{42.457218, 7.797607, 17.778244}, extents, filter)
+
+	for _, options := range []uint32{0, FindPathAnyAngle} {
+		st := q.InitSlicedFindPath(orgRef, dstRef, org, dst, filter, options)
+		for StatusInProgress(st) {
+			st = q.UpdateSlicedFindPath(4, nil)
+		}
+		if StatusFailed(st) {
+			t.Fatalf("options %d, UpdateSlicedFindPath failed with status 0x%x", options, st)
+		}
+
+		// count the nodes reached through a raycast shortcut
+		var shortcuts int
+		for n := q.query.lastBestNode; n != nil; n = q.nodePool.NodeAtIdx(int32(n.PIdx)) {
+			if n.Flags&nodeParentDetached != 0 {
+				shortcuts++
+			}
+		}
+		if options == 0 && shortcuts != 0 {
+			t.Errorf("options %d, got %d shortcuts, want none", options, shortcuts)
+		}
+		if options == FindPathAnyAngle && shortcuts == 0 {
+			t.Errorf("options %d, got no shortcuts", options)
+		}
+
+		path := make([]PolyRef, 100)
+		n, st := q.FinalizeSlicedFindPath(path, len(path))
+		if StatusFailed(st) || StatusDetail(st, PartialResult) {
+			t.Fatalf("options %d, FinalizeSlicedFindPath returned status 0x%x", options, st)
+		}
+		if path[0] != orgRef || path[n-1] != dstRef {
+			t.Errorf("options %d, path %x should go from 0x%x to 0x%x", options, path[:n], orgRef, dstRef)
+		}
+
+		// the corridor must be made of adjacent polygons
+		for i := 1; i < n; i++ {
+			var (
+				tile *MeshTile
+				poly *Poly
+			)
+			mesh.TileAndPolyByRef(path[i-1], &tile, &poly)
+			linked := false
+			for l := poly.FirstLink; l != nullLink; l = tile.Links[l].Next {
+				if tile.Links[l].Ref == path[i] {
+					linked = true
+					break
+				}
+			}
+			if !linked {
+				t.Errorf("options %d, polys 0x%x and 0x%x of path %x are not adjacent", options, path[i-1], path[i], path[:n])
+			}
+		}
+	}
+}
+
+func TestFindPathWithOptions(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+
+	st, q := NewNavMeshQuery(mesh, 1000)
+	if StatusFailed(st) {
+		t.Fatalf("query creation failed with status 0x%x", st)
+	}
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	_, orgRef, org := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, filter)
+	_, dstRef, dst := q.FindNearestPoly(d3.Vec3{42.457218, 7.797607, 17.778244}, extents, filter)
+
+	want := make([]PolyRef, 100)
+	nwant, _ := q.FindPath(orgRef, dstRef, org, dst, filter, want)
+
+	var ncalls int
+	countingHeuristic := func(pos, endPos d3.Vec3) float32 {
+		ncalls++
+		return EuclideanHeuristic(pos, endPos)
+	}
+
+	optsTests := []struct {
+		msg  string
+		opts *FindPathOptions
+	}{
+		{"nil options", nil},
+		{"default options", &FindPathOptions{}},
+		{"dijkstra", &FindPathOptions{Heuristic: ZeroHeuristic}},
+		{"custom heuristic", &FindPathOptions{Heuristic: countingHeuristic, HeuristicScale: 1}},
+	}
+
+	for _, tt := range optsTests {
+		path := make([]PolyRef, 100)
+		n, st := q.FindPathWithOptions(orgRef, dstRef, org, dst, filter, path, tt.opts)
+		if st != Success {
+			t.Fatalf("%s, FindPathWithOptions returned status 0x%x", tt.msg, st)
+		}
+		if n != nwant {
+			t.Errorf("%s, got path %x, want %x", tt.msg, path[:n], want[:nwant])
+			continue
+		}
+		for i := range path[:n] {
+			if path[i] != want[i] {
+				t.Errorf("%s, got path %x, want %x", tt.msg, path[:n], want[:nwant])
+				break
+			}
+		}
+	}
+	if ncalls == 0 {
+		t.Errorf("custom heuristic has not been called")
+	}
+
+	// a partial path ends on the polygon nearest to the end position,
+	// whatever the heuristic
+	blocked := want[nwant/2]
+	flags, _ := mesh.PolyFlags(blocked)
+	mesh.SetPolyFlags(blocked, 0)
+	defer mesh.SetPolyFlags(blocked, flags)
+
+	partial := make([]PolyRef, 100)
+	npartial, _ := q.FindPath(orgRef, dstRef, org, dst, filter, partial)
+	for _, tt := range optsTests {
+		path := make([]PolyRef, 100)
+		n, st := q.FindPathWithOptions(orgRef, dstRef, org, dst, filter, path, tt.opts)
+		if !StatusDetail(st, PartialResult) || path[n-1] != partial[npartial-1] {
+			t.Errorf("%s, got path %x and status 0x%x, want partial path to 0x%x",
+				tt.msg, path[:n], st, partial[npartial-1])
+		}
+	}
+}
+
+// straightPathFixture returns a query on mesh1.bin, and a path corridor
+// between two of its positions.
+func straightPathFixture(tb testing.TB) (q *NavMeshQuery, org, dst d3.Vec3, path []PolyRef) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	_, q = NewNavMeshQuery(mesh, 1000)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	_, orgRef, org := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, filter)
+	_, dstRef, dst := q.FindNearestPoly(d3.Vec3{42.457218, 7.797607, 17.778244}, extents, filter)
+
+	path = make([]PolyRef, 100)
+	npath, st := q.FindPath(orgRef, dstRef, org, dst, filter, path)
+	if StatusFailed(st) {
+		tb.Fatalf("FindPath failed with status 0x%x", st)
+	}
+	return q, org, dst, path[:npath]
+}
+
+func TestFindStraightPathAllocs(t *testing.T) {
+	q, org, dst, path := straightPathFixture(t)
+
+	straightPath := make([]d3.Vec3, 100)
+	for i := range straightPath {
+		straightPath[i] = d3.NewVec3()
+	}
+	straightPathFlags := make([]uint8, 100)
+	straightPathRefs := make([]PolyRef, 100)
+
+	for _, options := range []int32{0, int32(StraightPathAreaCrossings), int32(StraightPathAllCrossings)} {
+		allocs := testing.AllocsPerRun(100, func() {
+			q.FindStraightPath(org, dst, path, straightPath, straightPathFlags, straightPathRefs, options)
+		})
+		if allocs != 0 {
+			t.Errorf("FindStraightPath with options 0x%x allocates %v times, want 0", options, allocs)
+		}
+	}
+}
+
+func TestFindStraightPathFlat(t *testing.T) {
+	q, org, dst, path := straightPathFixture(t)
+
+	const max = 100
+	straightPath := make([]d3.Vec3, max)
+	for i := range straightPath {
+		straightPath[i] = d3.NewVec3()
+	}
+	flags, refs := make([]uint8, max), make([]PolyRef, max)
+	flat := make([]float32, max*3)
+	flatFlags, flatRefs := make([]uint8, max), make([]PolyRef, max)
+
+	for _, options := range []int32{0, int32(StraightPathAreaCrossings), int32(StraightPathAllCrossings)} {
+		n, st := q.FindStraightPath(org, dst, path, straightPath, flags, refs, options)
+		nflat, stflat := q.FindStraightPathFlat(org, dst, path, flat, flatFlags, flatRefs, options)
+		if st != stflat || n != nflat {
+			t.Fatalf("options 0x%x: got (%d, 0x%x) with flat buffer, want (%d, 0x%x)", options, nflat, stflat, n, st)
+		}
+		for i := 0; i < n; i++ {
+			if !straightPath[i].Approx(d3.Vec3(flat[i*3:i*3+3])) || flags[i] != flatFlags[i] || refs[i] != flatRefs[i] {
+				t.Errorf("options 0x%x: point %d differs, got (%v, %d, %d), want (%v, %d, %d)",
+					options, i, flat[i*3:i*3+3], flatFlags[i], flatRefs[i], straightPath[i], flags[i], refs[i])
+			}
+		}
+
+		allocs := testing.AllocsPerRun(100, func() {
+			q.FindStraightPathFlat(org, dst, path, flat, flatFlags, flatRefs, options)
+		})
+		if allocs != 0 {
+			t.Errorf("FindStraightPathFlat with options 0x%x allocates %v times, want 0", options, allocs)
+		}
+	}
+
+	// The flat buffer holds 2 points.
+	n, st := q.FindStraightPathFlat(org, dst, path, flat[:7], nil, nil, 0)
+	if n != 2 || st&BufferTooSmall == 0 {
+		t.Errorf("got (%d, 0x%x) with a 2 points buffer, want 2 points and BufferTooSmall", n, st)
+	}
+	if _, st := q.FindStraightPathFlat(org, dst, path, flat[:2], nil, nil, 0); !StatusFailed(st) || st&InvalidParam == 0 {
+		t.Errorf("got status 0x%x with a buffer too small for a point, want InvalidParam failure", st)
+	}
+}
+
+func TestFindStraightPathPartialFullBuffer(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 512)
+
+	// The last polygon isn't a neighbour of the previous one, the straight
+	// path is partial and its portal crossings fill the 2 points buffer
+	// before its end point is appended.
+	corridor := []PolyRef{307, 309, 318, 320, 301}
+	org := d3.Vec3{6.810684, 8.3304825, -11.800152}
+	dst := d3.Vec3{6.2106857, -2.2695167, 17.59985}
+	straightPath := []d3.Vec3{d3.NewVec3(), d3.NewVec3()}
+	noPanic(t, "FindStraightPath", nil, func() {
+		n, st := q.FindStraightPath(org, dst, corridor, straightPath, make([]uint8, 2), make([]PolyRef, 2), int32(StraightPathAllCrossings))
+		if n != 2 || st&PartialResult == 0 {
+			t.Errorf("got (%d, 0x%x), want 2 points and PartialResult", n, st)
+		}
+	})
+}
+
+func benchmarkFindStraightPath(b *testing.B, options int32) {
+	q, org, dst, path := straightPathFixture(b)
+
+	straightPath := make([]d3.Vec3, 100)
+	for i := range straightPath {
+		straightPath[i] = d3.NewVec3()
+	}
+	straightPathFlags := make([]uint8, 100)
+	straightPathRefs := make([]PolyRef, 100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.FindStraightPath(org, dst, path, straightPath, straightPathFlags, straightPathRefs, options)
+	}
+}
+
+func BenchmarkFindStraightPath(b *testing.B) {
+	benchmarkFindStraightPath(b, 0)
+}
+
+func BenchmarkFindStraightPathAllCrossings(b *testing.B) {
+	benchmarkFindStraightPath(b, int32(StraightPathAllCrossings))
+}
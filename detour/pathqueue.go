@@ -0,0 +1,280 @@
+package detour
+
+import (
+	"sync"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// PathQueueRef is the handle of a request of a PathQueue.
+type PathQueueRef uint32
+
+// InvalidPathQueueRef is the handle returned by PathQueue.Request when the
+// queue is full.
+const InvalidPathQueueRef PathQueueRef = 0
+
+// DefaultPathQueueKeepAlive is the default number of updates a PathQueue
+// keeps a completed request before dropping it.
+const DefaultPathQueueKeepAlive = 2
+
+// pathQuery is a request of a PathQueue.
+type pathQuery struct {
+	ref PathQueueRef
+
+	// Path find start and end location.
+	startPos, endPos [3]float32
+	startRef, endRef PolyRef
+
+	// Result.
+	path   []PolyRef
+	npath  int
+	status Status
+
+	keepAlive int
+	filter    QueryFilter
+}
+
+// PathQueue is an asynchronous path finder.
+//
+// Path requests are queued then found incrementally, with sliced path
+// queries, by the calls to Update. This spreads the cost of path finding over
+// several frames, or moves it to another goroutine.
+//
+// A PathQueue has its own NavMeshQuery, so it doesn't interfere with the
+// other queries of the navigation mesh. Its methods are safe for concurrent
+// use, so Update can be called from a background goroutine while the requests
+// are submitted and polled from another one:
+//
+//	go func() {
+//	    for range ticker.C {
+//	        pq.Update(100)
+//	    }
+//	}()
+//
+// The navigation mesh must not be modified while a request is in progress.
+type PathQueue struct {
+	// KeepAlive is the number of updates a completed request is kept, waiting
+	// for its result to be read by PathResult, before it's dropped to make
+	// room for other requests. With 0, completed requests are kept until
+	// their result is read.
+	KeepAlive int
+
+	mu          sync.Mutex
+	queue       []pathQuery
+	nextHandle  PathQueueRef
+	maxPathSize int
+	queueHead   int
+	navquery    *NavMeshQuery
+}
+
+// NewPathQueue creates a path queue.
+//
+//	Arguments:
+//	 nav             The navigation mesh in which the paths are found.
+//	 maxQueue        The maximum number of pending requests.
+//	 maxPathSize     The maximum number of polygons of each path.
+//	 maxSearchNodes  The maximum number of search nodes of the query.
+//	                 [Limits: 0 < value <= 65535]
+//
+// Return the status flags for the initialization of the queue and the queue.
+func NewPathQueue(nav *NavMesh, maxQueue, maxPathSize int, maxSearchNodes int32) (Status, *PathQueue) {
+	if maxQueue <= 0 || maxPathSize <= 0 {
+		return Failure | InvalidParam, nil
+	}
+
+	st, navquery := NewNavMeshQuery(nav, maxSearchNodes)
+	if StatusFailed(st) {
+		return st, nil
+	}
+
+	pq := &PathQueue{
+		KeepAlive:   DefaultPathQueueKeepAlive,
+		queue:       make([]pathQuery, maxQueue),
+		nextHandle:  1,
+		maxPathSize: maxPathSize,
+		navquery:    navquery,
+	}
+	paths := make([]PolyRef, maxQueue*maxPathSize)
+	for i := range pq.queue {
+		pq.queue[i].path = paths[i*maxPathSize : (i+1)*maxPathSize : (i+1)*maxPathSize]
+	}
+	return Success, pq
+}
+
+// Update advances the pending requests, processing them in turn until there
+// is nothing left to do or maxIters iterations have been spent.
+//
+// The completed requests whose result has not been read for KeepAlive
+// updates are dropped.
+func (pq *PathQueue) Update(maxIters int) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	// Update path request until there is nothing to update or up to maxIters
+	// pathfinder iterations has been consumed.
+	iterCount := maxIters
+	for i := 0; i < len(pq.queue); i++ {
+		q := &pq.queue[pq.queueHead%len(pq.queue)]
+
+		// Skip inactive requests.
+		if q.ref == InvalidPathQueueRef {
+			pq.queueHead++
+			continue
+		}
+
+		// Handle completed request.
+		if StatusSucceed(q.status) || StatusFailed(q.status) {
+			// If the path result has not been read in few frames, free the
+			// slot.
+			q.keepAlive++
+			if pq.KeepAlive > 0 && q.keepAlive > pq.KeepAlive {
+				q.ref = InvalidPathQueueRef
+				q.status = 0
+			}
+			pq.queueHead++
+			continue
+		}
+
+		// Handle query start.
+		if q.status == 0 {
+			q.status = pq.navquery.InitSlicedFindPath(q.startRef, q.endRef,
+				q.startPos[:], q.endPos[:], q.filter, 0)
+		}
+		// Handle query in progress.
+		if StatusInProgress(q.status) {
+			var iters int
+			q.status = pq.navquery.UpdateSlicedFindPath(iterCount, &iters)
+			iterCount -= iters
+		}
+		if StatusSucceed(q.status) {
+			q.npath, q.status = pq.navquery.FinalizeSlicedFindPath(q.path, pq.maxPathSize)
+		}
+
+		if iterCount <= 0 {
+			break
+		}
+		pq.queueHead++
+	}
+}
+
+// Request queues a path request.
+//
+//	Arguments:
+//	 startRef  The reference id of the start polygon.
+//	 endRef    The reference id of the end polygon.
+//	 startPos  A position within the start polygon. [(x, y, z)]
+//	 endPos    A position within the end polygon. [(x, y, z)]
+//	 filter    The polygon filter to apply to the query.
+//
+// Returns the handle of the request, or InvalidPathQueueRef if the queue is
+// full.
+//
+// The filter is used for the duration of the request, it must not be modified
+// until the request is completed.
+func (pq *PathQueue) Request(startRef, endRef PolyRef, startPos, endPos d3.Vec3, filter QueryFilter) PathQueueRef {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	// Find empty slot
+	slot := -1
+	for i := range pq.queue {
+		if pq.queue[i].ref == InvalidPathQueueRef {
+			slot = i
+			break
+		}
+	}
+	// Could not find slot.
+	if slot == -1 {
+		return InvalidPathQueueRef
+	}
+
+	ref := pq.nextHandle
+	pq.nextHandle++
+	if pq.nextHandle == InvalidPathQueueRef {
+		pq.nextHandle++
+	}
+
+	q := &pq.queue[slot]
+	q.ref = ref
+	copy(q.startPos[:], startPos)
+	q.startRef = startRef
+	copy(q.endPos[:], endPos)
+	q.endRef = endRef
+
+	q.status = 0
+	q.npath = 0
+	q.filter = filter
+	q.keepAlive = 0
+	return ref
+}
+
+// RequestStatus returns the status of a request: InProgress while the path is
+// being found, the status of the path query once completed, or Failure if ref
+// is not a pending request (E.g. it has been dropped or its result has already
+// been read).
+func (pq *PathQueue) RequestStatus(ref PathQueueRef) Status {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	if q := pq.find(ref); q != nil {
+		if q.status == 0 {
+			// Not started yet.
+			return InProgress
+		}
+		return q.status
+	}
+	return Failure
+}
+
+// PathResult copies the path found by a completed request into path and
+// releases the request.
+//
+//	Arguments:
+//	 ref       The handle of the request.
+//	 path      The path, its length is the maximum number of polygons that
+//	           can be copied.
+//
+//	Return values:
+//	 npath     The number of polygons copied into path.
+//	 st        The status flags of the path query, or Failure if ref is not
+//	           a completed request.
+func (pq *PathQueue) PathResult(ref PathQueueRef, path []PolyRef) (npath int, st Status) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	q := pq.find(ref)
+	if q == nil || !(StatusSucceed(q.status) || StatusFailed(q.status)) {
+		return 0, Failure
+	}
+
+	details := q.status & StatusDetailMask
+	failed := StatusFailed(q.status)
+
+	// Free request for reuse.
+	q.ref = InvalidPathQueueRef
+	q.status = 0
+	q.filter = nil
+
+	if failed {
+		return 0, Failure | details
+	}
+	// Copy path
+	npath = copy(path, q.path[:q.npath])
+	if npath < q.npath {
+		details |= BufferTooSmall
+	}
+	return npath, Success | details
+}
+
+// find returns the request with handle ref, or nil.
+func (pq *PathQueue) find(ref PathQueueRef) *pathQuery {
+	if ref == InvalidPathQueueRef {
+		return nil
+	}
+	for i := range pq.queue {
+		if pq.queue[i].ref == ref {
+			return &pq.queue[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package detour
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// pathQueueRequests returns path requests between polygons spread over the
+// navmesh, and their expected results.
+func pathQueueRequests(t *testing.T, mesh *NavMesh) ([]PathRequest, []PathResult) {
+	_, q := NewNavMeshQuery(mesh, 2048)
+	filter := NewStandardQueryFilter()
+
+	refs := allPolyRefs(mesh)
+	var (
+		requests []PathRequest
+		want     []PathResult
+	)
+	for i := 0; i < len(refs); i += 11 {
+		start, end := refs[i], refs[(i*13+len(refs)/2)%len(refs)]
+		startPos, _ := q.PolyCentroid(start)
+		endPos, _ := q.PolyCentroid(end)
+		requests = append(requests, PathRequest{start, end, startPos, endPos, filter})
+
+		path := make([]PolyRef, 64)
+		n, st := q.FindPath(start, end, startPos, endPos, filter, path)
+		want = append(want, PathResult{path[:n:n], st})
+	}
+	return requests, want
+}
+
+// pathQueueBusy reports whether any of the requests is in progress.
+func pathQueueBusy(pq *PathQueue, refs []PathQueueRef) bool {
+	for _, ref := range refs {
+		if StatusInProgress(pq.RequestStatus(ref)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPathQueue(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+	requests, want := pathQueueRequests(t, mesh)
+
+	const maxQueue = 4
+	st, pq := NewPathQueue(mesh, maxQueue, 64, 2048)
+	if StatusFailed(st) {
+		t.Fatalf("NewPathQueue failed with status %v", st)
+	}
+
+	path := make([]PolyRef, 64)
+	for i := 0; i < len(requests); i += maxQueue {
+		var refs []PathQueueRef
+		for j := i; j < i+maxQueue && j < len(requests); j++ {
+			r := requests[j]
+			ref := pq.Request(r.StartRef, r.EndRef, r.StartPos, r.EndPos, r.Filter)
+			if ref == InvalidPathQueueRef {
+				t.Fatalf("request %d: queue should not be full", j)
+			}
+			refs = append(refs, ref)
+		}
+		if len(refs) == maxQueue {
+			r := requests[i]
+			if ref := pq.Request(r.StartRef, r.EndRef, r.StartPos, r.EndPos, r.Filter); ref != InvalidPathQueueRef {
+				t.Fatalf("Request should return InvalidPathQueueRef when the queue is full")
+			}
+		}
+
+		// Few iterations per update, so that requests take several updates.
+		for n := 0; n < 1000 && pathQueueBusy(pq, refs); n++ {
+			pq.Update(10)
+		}
+		for k, ref := range refs {
+			n, st := pq.PathResult(ref, path)
+			got := PathResult{path[:n:n], st}
+			if !reflect.DeepEqual(got, want[i+k]) {
+				t.Errorf("request %d: got %v, want %v", i+k, got, want[i+k])
+			}
+			if st := pq.RequestStatus(ref); !StatusFailed(st) {
+				t.Errorf("request %d: status after reading the result is %v, want Failure", i+k, st)
+			}
+		}
+	}
+}
+
+func TestPathQueueKeepAlive(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+	requests, _ := pathQueueRequests(t, mesh)
+	r := requests[0]
+
+	_, pq := NewPathQueue(mesh, 1, 64, 2048)
+	ref := pq.Request(r.StartRef, r.EndRef, r.StartPos, r.EndPos, r.Filter)
+	pq.Update(1000)
+	if st := pq.RequestStatus(ref); !StatusSucceed(st) {
+		t.Fatalf("request should be completed, got status %v", st)
+	}
+	for i := 0; i < DefaultPathQueueKeepAlive; i++ {
+		pq.Update(1000)
+	}
+	if st := pq.RequestStatus(ref); !StatusSucceed(st) {
+		t.Fatalf("request should be kept for %d updates, got status %v", DefaultPathQueueKeepAlive, st)
+	}
+	pq.Update(1000)
+	if st := pq.RequestStatus(ref); !StatusFailed(st) {
+		t.Fatalf("request should be dropped, got status %v", st)
+	}
+
+	// Keep results until they're read.
+	pq.KeepAlive = 0
+	ref = pq.Request(r.StartRef, r.EndRef, r.StartPos, r.EndPos, r.Filter)
+	for i := 0; i < 10; i++ {
+		pq.Update(1000)
+	}
+	if n, st := pq.PathResult(ref, make([]PolyRef, 1)); n != 1 || !st.Is(Success|BufferTooSmall) {
+		t.Errorf("PathResult with a 1 polygon buffer = (%d, %v), want (1, SUCCESS|BUFFER_TOO_SMALL)", n, st)
+	}
+}
+
+func TestPathQueueBackground(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+	requests, want := pathQueueRequests(t, mesh)
+
+	_, pq := NewPathQueue(mesh, len(requests), 64, 2048)
+	pq.KeepAlive = 0
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				pq.Update(20)
+			}
+		}
+	}()
+
+	refs := make([]PathQueueRef, len(requests))
+	for i, r := range requests {
+		refs[i] = pq.Request(r.StartRef, r.EndRef, r.StartPos, r.EndPos, r.Filter)
+	}
+	path := make([]PolyRef, 64)
+	for i, ref := range refs {
+		for StatusInProgress(pq.RequestStatus(ref)) {
+			runtime.Gosched()
+		}
+		n, st := pq.PathResult(ref, path)
+		if got := (PathResult{path[:n:n], st}); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("request %d: got %v, want %v", i, got, want[i])
+		}
+	}
+	close(done)
+	wg.Wait()
+}
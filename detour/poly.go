@@ -24,7 +24,8 @@ type Poly struct {
 
 	// bit-packed area id and polygon type.
 	//
-	// Note: use SetArea/SetType/Area/Type functions to access those values.
+	// Note: use SetArea/SetType/Area/Type/IsOffMeshConnection functions to
+	// access those values.
 	// This value is exported in order to be accessible from reflect during
 	// unmarshalling from binary data.
 	AreaAndType uint8
@@ -50,6 +51,12 @@ func (p *Poly) Type() uint8 {
 	return p.AreaAndType >> 6
 }
 
+// IsOffMeshConnection reports whether the polygon is an off-mesh connection,
+// rather than a convex polygon of the mesh surface.
+func (p *Poly) IsOffMeshConnection() bool {
+	return p.Type() == polyTypeOffMeshConnection
+}
+
 // CalcPolyCenter derives and returns the centroid of a convex polygon.
 //
 //	idx     polygon indices. [(vertIndex) * nidx]
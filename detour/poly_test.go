@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
 )
 
 func TestCalcPolyCenter(t *testing.T) {
@@ -88,3 +89,454 @@ func TestFindNearestPolySpecialCases(t *testing.T) {
 		}
 	}
 }
+
+func TestPolyHeight(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	_, q := NewNavMeshQuery(mesh, 100)
+
+	heightTests := []struct {
+		msg        string
+		ref        PolyRef
+		pos        d3.Vec3
+		wantSt     Status
+		wantHeight float32
+	}{
+		{"inside poly", 0x440000, d3.Vec3{3.6002522, 10, 10.873747}, Success, 0.189468},
+		{"outside poly", 0x440000, d3.Vec3{-50, 0, -50}, Failure | InvalidParam, 0},
+		{"invalid ref", 0, d3.Vec3{3.6002522, 0, 10.873747}, Failure | InvalidParam, 0},
+	}
+
+	for _, tt := range heightTests {
+		h, st := q.PolyHeight(tt.ref, tt.pos)
+		if st != tt.wantSt {
+			t.Errorf("%s, want status 0x%x, got 0x%x", tt.msg, tt.wantSt, st)
+		}
+		if StatusSucceed(st) && !math32.Approx(h, tt.wantHeight) {
+			t.Errorf("%s, want height %f, got %f", tt.msg, tt.wantHeight, h)
+		}
+	}
+}
+
+func TestPolyCentroidAndRepresentativePoint(t *testing.T) {
+	for _, fname := range []string{"mesh1.bin", "mesh2.bin", "offmeshcons.bin"} {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+
+		_, q := NewNavMeshQuery(mesh, 100)
+
+		for _, ref := range allPolyRefs(mesh) {
+			var (
+				tile *MeshTile
+				poly *Poly
+			)
+			mesh.TileAndPolyByRef(ref, &tile, &poly)
+
+			points := map[string]func(PolyRef) (d3.Vec3, Status){
+				"PolyCentroid":            q.PolyCentroid,
+				"PolyRepresentativePoint": q.PolyRepresentativePoint,
+			}
+			for name, f := range points {
+				pt, st := f(ref)
+				if StatusFailed(st) {
+					t.Fatalf("%s, %s(0x%x) failed with status 0x%x", fname, name, ref, st)
+				}
+
+				if poly.Type() == polyTypeOffMeshConnection {
+					v0 := tile.Verts[poly.Verts[0]*3 : poly.Verts[0]*3+3]
+					v1 := tile.Verts[poly.Verts[1]*3 : poly.Verts[1]*3+3]
+					if !pt.Approx(d3.Vec3(v0).Lerp(v1, 0.5)) {
+						t.Errorf("%s, %s(0x%x) = %v, want connection middle", fname, name, ref, pt)
+					}
+					continue
+				}
+
+				// the point should be inside the polygon, on its detail surface
+				closest := d3.NewVec3()
+				var over bool
+				q.ClosestPointOnPoly(ref, pt, closest, &over)
+				if !over {
+					t.Errorf("%s, %s(0x%x) = %v is not inside the polygon", fname, name, ref, pt)
+				}
+				if h, st := q.PolyHeight(ref, pt); StatusFailed(st) || math32.Abs(h-pt[1]) > 1e-3 {
+					t.Errorf("%s, %s(0x%x) = %v, want height %f", fname, name, ref, pt, h)
+				}
+			}
+		}
+	}
+
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 100)
+	if _, st := q.PolyCentroid(0); st != Failure|InvalidParam {
+		t.Errorf("PolyCentroid(0) should fail with InvalidParam, got 0x%x", st)
+	}
+	if _, st := q.PolyRepresentativePoint(0); st != Failure|InvalidParam {
+		t.Errorf("PolyRepresentativePoint(0) should fail with InvalidParam, got 0x%x", st)
+	}
+}
+
+func TestClosestPointOnPolyBoundary(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	_, q := NewNavMeshQuery(mesh, 100)
+	closest, detail := d3.NewVec3(), d3.NewVec3()
+
+	for _, ref := range allPolyRefs(mesh) {
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		mesh.TileAndPolyByRef(ref, &tile, &poly)
+		if poly.Type() == polyTypeOffMeshConnection {
+			continue
+		}
+		center := CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts)
+
+		// a point inside the polygon is returned as is, whatever its height
+		inside := d3.NewVec3XYZ(center[0], center[1]+10, center[2])
+		if st := q.ClosestPointOnPolyBoundary(ref, inside, closest); st != Success {
+			t.Fatalf("ClosestPointOnPolyBoundary(0x%x) failed with status 0x%x", ref, st)
+		}
+		if !closest.Approx(inside) {
+			t.Errorf("ClosestPointOnPolyBoundary(0x%x, %v) = %v, want %v", ref, inside, closest, inside)
+		}
+
+		// a point outside the polygon is clamped to the same xz position as
+		// the accurate version
+		outside := d3.NewVec3XYZ(center[0]+100, center[1], center[2]+100)
+		q.ClosestPointOnPolyBoundary(ref, outside, closest)
+		q.ClosestPointOnPoly(ref, outside, detail, nil)
+		if math32.Abs(closest[0]-detail[0]) > 1e-3 || math32.Abs(closest[2]-detail[2]) > 1e-3 {
+			t.Errorf("ClosestPointOnPolyBoundary(0x%x, %v) = %v, want xz of %v", ref, outside, closest, detail)
+		}
+	}
+
+	if st := q.ClosestPointOnPolyBoundary(0, d3.NewVec3(), closest); st != Failure|InvalidParam {
+		t.Errorf("want status 0x%x with invalid ref, got 0x%x", Failure|InvalidParam, st)
+	}
+}
+
+func benchmarkClosestPoint(b *testing.B, closestPoint func(q *NavMeshQuery, ref PolyRef, pos, closest d3.Vec3)) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, q := NewNavMeshQuery(mesh, 100)
+	// query each polygon from a point slightly off its center, as when
+	// clamping a movement
+	var (
+		refs []PolyRef
+		pos  []d3.Vec3
+	)
+	for _, ref := range allPolyRefs(mesh) {
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		mesh.TileAndPolyByRef(ref, &tile, &poly)
+		if poly.Type() == polyTypeOffMeshConnection {
+			continue
+		}
+		c := CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts)
+		refs = append(refs, ref)
+		pos = append(pos, d3.NewVec3XYZ(c[0]+0.5, c[1]+1, c[2]+0.5))
+	}
+	closest := d3.NewVec3()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := i % len(refs)
+		closestPoint(q, refs[j], pos[j], closest)
+	}
+}
+
+func BenchmarkClosestPointOnPoly(b *testing.B) {
+	benchmarkClosestPoint(b, func(q *NavMeshQuery, ref PolyRef, pos, closest d3.Vec3) {
+		q.ClosestPointOnPoly(ref, pos, closest, nil)
+	})
+}
+
+func BenchmarkClosestPointOnPolyBoundary(b *testing.B) {
+	benchmarkClosestPoint(b, func(q *NavMeshQuery, ref PolyRef, pos, closest d3.Vec3) {
+		q.ClosestPointOnPolyBoundary(ref, pos, closest)
+	})
+}
+
+func BenchmarkFindNearestPoly(b *testing.B) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, q := NewNavMeshQuery(mesh, 100)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	var centers []d3.Vec3
+	for _, ref := range allPolyRefs(mesh) {
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		mesh.TileAndPolyByRef(ref, &tile, &poly)
+		if poly.Type() == polyTypeOffMeshConnection {
+			continue
+		}
+		c := CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts)
+		centers = append(centers, d3.NewVec3XYZ(c[0]+0.5, c[1]+1, c[2]+0.5))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.FindNearestPoly(centers[i%len(centers)], extents, filter)
+	}
+}
+
+func TestFindNearestPolyEx(t *testing.T) {
+	mesh, err := loadTestNavMesh("fixture/plane.bin")
+	checkt(t, err)
+
+	_, q := NewNavMeshQuery(mesh, 100)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+
+	tests := []struct {
+		msg          string
+		center       d3.Vec3
+		wantOverPoly bool
+	}{
+		{"above the plane", d3.Vec3{5, 1, 5}, true},
+		{"beside the plane", d3.Vec3{-1, 0, 5}, false},
+	}
+	for _, tt := range tests {
+		pt := d3.NewVec3()
+		st, ref, distSqr, overPoly := q.FindNearestPolyEx(tt.center, extents, filter, pt)
+		if StatusFailed(st) || ref == 0 {
+			t.Fatalf("%s: no polygon found, status 0x%x", tt.msg, st)
+		}
+		if overPoly != tt.wantOverPoly {
+			t.Errorf("%s: isOverPoly = %v, want %v", tt.msg, overPoly, tt.wantOverPoly)
+		}
+		if want := tt.center.Sub(pt).LenSqr(); math32.Abs(distSqr-want) > 1e-4 {
+			t.Errorf("%s: distSqr = %f, want %f", tt.msg, distSqr, want)
+		}
+		if overPoly && (pt[0] != tt.center[0] || pt[2] != tt.center[2]) {
+			t.Errorf("%s: nearest point %v should be right below %v", tt.msg, pt, tt.center)
+		}
+
+		// FindNearestPoly gives the same result
+		_, ref2, pt2 := q.FindNearestPoly(tt.center, extents, filter)
+		if ref2 != ref || !pt2.Approx(pt) {
+			t.Errorf("%s: FindNearestPoly = 0x%x %v, want 0x%x %v", tt.msg, ref2, pt2, ref, pt)
+		}
+	}
+
+	// nothing found
+	pt := d3.NewVec3()
+	if _, ref, distSqr, overPoly := q.FindNearestPolyEx(d3.Vec3{50, 0, 50}, extents, filter, pt); ref != 0 || distSqr != 0 || overPoly || !pt.Approx(d3.NewVec3()) {
+		t.Errorf("got ref 0x%x, pt %v, distSqr %f, overPoly %v, want zero values", ref, pt, distSqr, overPoly)
+	}
+	if _, ref, pt := q.FindNearestPoly(d3.Vec3{50, 0, 50}, extents, filter); ref != 0 || pt != nil {
+		t.Errorf("FindNearestPoly = 0x%x %v, want zero values", ref, pt)
+	}
+}
+
+func TestQueryAllocs(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 100)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+
+	center := d3.Vec3{37.298489, -1.776901, 11.652311}
+	_, startRef, startPos := q.FindNearestPoly(center, extents, filter)
+	endPos := d3.Vec3{42.457218, 7.797607, 17.778244}
+	hit := RaycastHit{Path: make([]PolyRef, 64)}
+	polys := make([]PolyRef, 16)
+	pt := d3.NewVec3()
+
+	tests := []struct {
+		name string
+		want float64
+		f    func()
+	}{
+		{"Raycast", 0, func() { q.Raycast(startRef, startPos, endPos, filter, 0, &hit, 0) }},
+		{"queryPolygons6", 0, func() { q.queryPolygons6(center, extents, filter, polys, int32(len(polys))) }},
+		{"FindNearestPolyEx", 0, func() { q.FindNearestPolyEx(center, extents, filter, pt) }},
+		{"FindNearestPoly2D", 0, func() { q.FindNearestPoly2D(center, extents, 4, filter, pt) }},
+		// the nearest point is returned in a new slice
+		{"FindNearestPoly", 1, func() { q.FindNearestPoly(center, extents, filter) }},
+	}
+	for _, tt := range tests {
+		if n := testing.AllocsPerRun(100, tt.f); n > tt.want {
+			t.Errorf("%s: %v allocations per run, want %v", tt.name, n, tt.want)
+		}
+	}
+}
+
+func TestPointInPoly(t *testing.T) {
+	mesh, err := loadTestNavMesh("fixture/floors.bin")
+	checkt(t, err)
+
+	_, q := NewNavMeshQuery(mesh, 100)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(0.5, 0.5, 0.5)
+
+	// polygons of the lower and upper floors, at the same xz location
+	_, lower, lowerPos := q.FindNearestPoly(d3.Vec3{3, 0, 3}, extents, filter)
+	_, upper, upperPos := q.FindNearestPoly(d3.Vec3{3, 4, 3}, extents, filter)
+	if lower == 0 || upper == 0 || lower == upper {
+		t.Fatalf("want 2 distinct floor polygons, got 0x%x and 0x%x", lower, upper)
+	}
+
+	tests := []struct {
+		msg    string
+		ref    PolyRef
+		pos    d3.Vec3
+		height float32
+		want   bool
+	}{
+		{"on the lower floor", lower, lowerPos, 0.5, true},
+		{"on the upper floor", upper, upperPos, 0.5, true},
+		{"upper floor position, lower polygon", lower, upperPos, 0.5, false},
+		{"upper floor position, lower polygon, heights ignored", lower, upperPos, -1, true},
+		{"slightly above the upper floor", upper, d3.Vec3{upperPos[0], upperPos[1] + 0.3, upperPos[2]}, 0.5, true},
+		{"outside of the floors", lower, d3.Vec3{-5, 0, 3}, -1, false},
+	}
+	for _, tt := range tests {
+		inside, st := q.PointInPoly(tt.ref, tt.pos, tt.height)
+		if StatusFailed(st) {
+			t.Fatalf("%s: PointInPoly failed with status 0x%x", tt.msg, st)
+		}
+		if inside != tt.want {
+			t.Errorf("%s: PointInPoly(0x%x, %v, %v) = %v, want %v", tt.msg, tt.ref, tt.pos, tt.height, inside, tt.want)
+		}
+	}
+
+	if _, st := q.PointInPoly(0, lowerPos, 0); st != Failure|InvalidParam {
+		t.Errorf("want status 0x%x with invalid ref, got 0x%x", Failure|InvalidParam, st)
+	}
+}
+
+func TestFindNearestPoly2D(t *testing.T) {
+	mesh, err := loadTestNavMesh("fixture/floors.bin")
+	checkt(t, err)
+
+	_, q := NewNavMeshQuery(mesh, 100)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+
+	_, lower, _ := q.FindNearestPoly(d3.Vec3{5, 0, 5}, d3.NewVec3XYZ(0.5, 0.5, 0.5), filter)
+	if lower == 0 {
+		t.Fatalf("want a lower floor polygon")
+	}
+
+	// On the ground, under the ramp: FindNearestPoly picks the ramp above,
+	// FindNearestPoly2D the floor beside.
+	center := d3.Vec3{11, 0, 5}
+	_, ref, _ := q.FindNearestPoly(center, extents, filter)
+	if ref == lower {
+		t.Fatalf("FindNearestPoly(%v) should pick the ramp", center)
+	}
+	pt := d3.NewVec3()
+	st, ref, over := q.FindNearestPoly2D(center, extents, 1, filter, pt)
+	if StatusFailed(st) || ref != lower || over || math32.Abs(pt[1]-center[1]) > 1 {
+		t.Errorf("FindNearestPoly2D(%v) = 0x%x %v %v, want the lower floor polygon 0x%x", center, ref, pt, over, lower)
+	}
+
+	// Over the lower floor.
+	center = d3.Vec3{5, 0.5, 5}
+	if _, ref, over = q.FindNearestPoly2D(center, extents, 1, filter, pt); ref != lower || !over || pt[0] != 5 || pt[2] != 5 {
+		t.Errorf("FindNearestPoly2D(%v) = 0x%x %v %v, want 0x%x right below", center, ref, pt, over, lower)
+	}
+
+	// Between the floors, out of the tolerance.
+	if st, ref, _ = q.FindNearestPoly2D(d3.Vec3{5, 2, 5}, extents, 0.5, filter, pt); StatusFailed(st) || ref != 0 {
+		t.Errorf("FindNearestPoly2D between the floors = 0x%x, status 0x%x, want no polygon", ref, st)
+	}
+
+	if st, _, _ = q.FindNearestPoly2D(center, extents, -1, filter, pt); st != Failure|InvalidParam {
+		t.Errorf("want status 0x%x with a negative tolerance, got 0x%x", Failure|InvalidParam, st)
+	}
+}
+
+// onDetailSurface reports whether pos is on a detail triangle of the polygon,
+// or on one of their edges. (The detail triangles may be degenerate and
+// overlap on the xz-plane.)
+func onDetailSurface(tile *MeshTile, poly *Poly, pd *PolyDetail, pos d3.Vec3) bool {
+	const tol = 1e-3
+	for j := uint8(0); j < pd.TriCount; j++ {
+		v := tile.detailTriVerts(poly, pd, j)
+		var h float32
+		if closestHeightPointTriangle(pos, v[0], v[1], v[2], &h) && math32.Abs(pos[1]-h) <= tol {
+			return true
+		}
+		for k, l := 0, 2; k < 3; l, k = k, k+1 {
+			var t float32
+			if distancePtSegSqr2D(pos, v[l], v[k], &t) < tol*tol &&
+				math32.Abs(pos[1]-(v[l][1]+(v[k][1]-v[l][1])*t)) <= tol {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestClosestPointOnPolyDetailHeight(t *testing.T) {
+	for _, fname := range []string{"mesh1.bin", "mesh2.bin", "fixture/floors.bin"} {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+		_, q := NewNavMeshQuery(mesh, 100)
+
+		mesh.EachPoly(func(ref PolyRef, poly *Poly, tile *MeshTile) bool {
+			if poly.Type() == polyTypeOffMeshConnection {
+				return true
+			}
+			pd := &tile.DetailMeshes[mesh.decodePolyIDPoly(ref)]
+
+			var center [3]float32
+			nv := int(poly.VertCount)
+			for i := 0; i < nv; i++ {
+				for k := 0; k < 3; k++ {
+					center[k] += tile.Verts[int(poly.Verts[i])*3+k] / float32(nv)
+				}
+			}
+
+			closest := d3.NewVec3()
+			for i, j := 0, nv-1; i < nv; j, i = i, i+1 {
+				vi := d3.Vec3(tile.Verts[poly.Verts[i]*3 : poly.Verts[i]*3+3])
+				vj := d3.Vec3(tile.Verts[poly.Verts[j]*3 : poly.Verts[j]*3+3])
+				mid := d3.NewVec3()
+				d3.Vec3Lerp(mid, vj, vi, 0.5)
+
+				// Inside, near the edge, then outside, beyond the edge.
+				for _, u := range []float32{0.9, 1.5} {
+					pos := d3.NewVec3()
+					d3.Vec3Lerp(pos, center[:], mid, u)
+					pos[1] += 0.5
+
+					var over bool
+					if st := q.ClosestPointOnPoly(ref, pos, closest, &over); StatusFailed(st) {
+						t.Fatalf("%s: ClosestPointOnPoly(0x%x, %v) failed: %v", fname, ref, pos, st)
+					}
+					if over != (u < 1) {
+						t.Errorf("%s: ClosestPointOnPoly(0x%x, %v): posOverPoly = %v", fname, ref, pos, over)
+						continue
+					}
+					if !onDetailSurface(tile, poly, pd, closest) {
+						t.Errorf("%s: ClosestPointOnPoly(0x%x, %v) = %v, not on the detail mesh", fname, ref, pos, closest)
+					}
+					if over {
+						if h, _ := q.PolyHeight(ref, pos); h != closest[1] {
+							t.Errorf("%s: PolyHeight(0x%x, %v) = %v, want %v", fname, ref, pos, h, closest[1])
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package detour
 
 import (
-	"log"
 	"math"
 
 	"github.com/arl/gogeo/f32/d3"
@@ -21,18 +20,21 @@ type polyQuery interface {
 type findNearestPolyQuery struct {
 	query              *NavMeshQuery
 	center             d3.Vec3
-	nearestDistanceSqr float32
+	nearestDistanceSqr float32 // Distance used to compare polygons.
 	nearestRef         PolyRef
 	nearestPoint       d3.Vec3
+	nearestOverPoly    bool    // True if center is over the nearest polygon.
+	nearestPointDist   float32 // Squared distance from center to nearestPoint.
 }
 
-func newFindNearestPolyQuery(query *NavMeshQuery, center d3.Vec3) *findNearestPolyQuery {
-	return &findNearestPolyQuery{
+// init initializes the query, the nearest point is stored in nearestPoint.
+func (q *findNearestPolyQuery) init(query *NavMeshQuery, center, nearestPoint d3.Vec3) {
+	*q = findNearestPolyQuery{
 		query:              query,
 		center:             center,
 		nearestDistanceSqr: math.MaxFloat32,
 		nearestRef:         0,
-		nearestPoint:       d3.NewVec3(),
+		nearestPoint:       nearestPoint,
 	}
 }
 
@@ -67,6 +69,64 @@ func (q *findNearestPolyQuery) process(tile *MeshTile, polys []*Poly, refs []Pol
 
 			q.nearestDistanceSqr = d
 			q.nearestRef = ref
+			q.nearestOverPoly = posOverPoly
+			q.nearestPointDist = diff.LenSqr()
+		}
+	}
+}
+
+// findNearestPoly2DQuery finds the polygon nearest to a point on the
+// xz-plane, within a vertical tolerance.
+type findNearestPoly2DQuery struct {
+	query           *NavMeshQuery
+	center          d3.Vec3
+	heightTol       float32 // The maximum height difference.
+	nearestDist2D   float32 // Squared xz-plane distance to the nearest polygon.
+	nearestHeight   float32 // Height difference with the nearest polygon.
+	nearestRef      PolyRef
+	nearestPoint    d3.Vec3
+	nearestOverPoly bool // True if center is over the nearest polygon.
+}
+
+// init initializes the query, the nearest point is stored in nearestPoint.
+func (q *findNearestPoly2DQuery) init(query *NavMeshQuery, center d3.Vec3, heightTol float32, nearestPoint d3.Vec3) {
+	*q = findNearestPoly2DQuery{
+		query:         query,
+		center:        center,
+		heightTol:     heightTol,
+		nearestDist2D: math.MaxFloat32,
+		nearestHeight: math.MaxFloat32,
+		nearestPoint:  nearestPoint,
+	}
+}
+
+func (q *findNearestPoly2DQuery) process(tile *MeshTile, polys []*Poly, refs []PolyRef, count int32) {
+	closestPtPoly := d3.NewVec3()
+	for i := int32(0); i < count; i++ {
+		ref := refs[i]
+		posOverPoly := false
+		q.query.ClosestPointOnPoly(ref, q.center, closestPtPoly, &posOverPoly)
+
+		// Skip the polygons too far above or below.
+		dy := math32.Abs(q.center[1] - closestPtPoly[1])
+		if dy > q.heightTol {
+			continue
+		}
+
+		// Favor the nearest polygon on the xz-plane, zero if center is over
+		// it, then the nearest vertically.
+		var d float32
+		if !posOverPoly {
+			dx := q.center[0] - closestPtPoly[0]
+			dz := q.center[2] - closestPtPoly[2]
+			d = dx*dx + dz*dz
+		}
+		if d < q.nearestDist2D || (d == q.nearestDist2D && dy < q.nearestHeight) {
+			q.nearestPoint.Assign(closestPtPoly)
+			q.nearestDist2D = d
+			q.nearestHeight = dy
+			q.nearestRef = ref
+			q.nearestOverPoly = posOverPoly
 		}
 	}
 }
@@ -78,9 +138,8 @@ type collectPolysQuery struct {
 	overflow     bool
 }
 
-func newCollectPolysQuery(polys []PolyRef, maxPolys int32) *collectPolysQuery {
-
-	return &collectPolysQuery{
+func (q *collectPolysQuery) init(polys []PolyRef, maxPolys int32) {
+	*q = collectPolysQuery{
 		polys:        polys,
 		maxPolys:     maxPolys,
 		numCollected: 0,
@@ -97,7 +156,6 @@ func (q *collectPolysQuery) process(tile *MeshTile, polys []*Poly, refs []PolyRe
 		toCopy = numLeft
 	}
 
-	log.Fatal("untested")
 	copy(q.polys[q.numCollected:], refs[0:toCopy])
 	q.numCollected += toCopy
 }
@@ -0,0 +1,95 @@
+package detour
+
+// ReachablePolys returns the polygons of the navigation mesh reachable from
+// the seed polygons, seeds included, by following the links between polygons,
+// off-mesh connections included.
+//
+//	Arguments:
+//	 m       The navigation mesh.
+//	 seeds   The reference ids of the polygons to start from.
+//	 filter  The polygon filter, the polygons it rejects are neither visited
+//	         nor crossed. [opt]
+//
+//	Return values:
+//	 reached  The set of the reachable polygons.
+//	 st       The status flags, Failure if a seed isn't a valid polygon.
+func ReachablePolys(m *NavMesh, seeds []PolyRef, filter QueryFilter) (reached map[PolyRef]bool, st Status) {
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+
+	reached = make(map[PolyRef]bool)
+	stack := make([]PolyRef, 0, len(seeds))
+	for _, ref := range seeds {
+		if StatusFailed(m.TileAndPolyByRef(ref, &tile, &poly)) {
+			return nil, Failure | InvalidParam
+		}
+		if filter != nil && !filter.PassFilter(ref, tile, poly) {
+			continue
+		}
+		if !reached[ref] {
+			reached[ref] = true
+			stack = append(stack, ref)
+		}
+	}
+
+	// Flood fill, depth first.
+	for len(stack) > 0 {
+		ref := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		m.TileAndPolyByRefUnsafe(ref, &tile, &poly)
+		for i := poly.FirstLink; i != nullLink; i = tile.Links[i].Next {
+			neiRef := tile.Links[i].Ref
+			if neiRef == 0 || reached[neiRef] {
+				continue
+			}
+			if filter != nil {
+				var (
+					neiTile *MeshTile
+					neiPoly *Poly
+				)
+				m.TileAndPolyByRefUnsafe(neiRef, &neiTile, &neiPoly)
+				if !filter.PassFilter(neiRef, neiTile, neiPoly) {
+					continue
+				}
+			}
+			reached[neiRef] = true
+			stack = append(stack, neiRef)
+		}
+	}
+	return reached, Success
+}
+
+// PruneUnreachable disables the polygons of the navigation mesh that can't be
+// reached from the seed polygons, by adding disableFlags to their flags.
+//
+//	Arguments:
+//	 m             The navigation mesh.
+//	 seeds         The reference ids of the polygons to start from.
+//	 disableFlags  The flags added to the unreachable polygons.
+//
+//	Return values:
+//	 n             The number of polygons disabled.
+//	 st            The status flags, Failure if a seed isn't a valid polygon.
+//
+// This is the navmesh prune tool of RecastDemo: the isolated islands of a
+// navigation mesh (roof tops, the inside of closed meshes...) are disabled,
+// so that queries with a filter excluding disableFlags, FindNearestPoly for
+// example, don't return them. The polygons are disabled, not removed, the
+// mesh can be saved then loaded again to keep the result.
+func PruneUnreachable(m *NavMesh, seeds []PolyRef, disableFlags uint16) (n int, st Status) {
+	reached, st := ReachablePolys(m, seeds, nil)
+	if StatusFailed(st) {
+		return 0, st
+	}
+	m.EachPoly(func(ref PolyRef, poly *Poly, tile *MeshTile) bool {
+		if !reached[ref] {
+			m.setPolyFlags(ref, poly, poly.Flags|disableFlags)
+			n++
+		}
+		return true
+	})
+	return n, Success
+}
@@ -0,0 +1,46 @@
+package detour
+
+import "testing"
+
+func TestPruneUnreachable(t *testing.T) {
+	const disabled = 0x10
+
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+
+	refs := allPolyRefs(mesh)
+	seed := refs[0]
+	reached, st := ReachablePolys(mesh, []PolyRef{seed}, nil)
+	if StatusFailed(st) {
+		t.Fatalf("ReachablePolys failed: %v", st)
+	}
+	if !reached[seed] || len(reached) == len(refs) {
+		t.Fatalf("mesh1.bin should have islands, %d/%d polygons reached", len(reached), len(refs))
+	}
+
+	n, st := PruneUnreachable(mesh, []PolyRef{seed}, disabled)
+	if StatusFailed(st) {
+		t.Fatalf("PruneUnreachable failed: %v", st)
+	}
+	if want := len(refs) - len(reached); n != want {
+		t.Errorf("PruneUnreachable disabled %d polygons, want %d", n, want)
+	}
+	for _, ref := range refs {
+		flags, _ := mesh.PolyFlags(ref)
+		if got := flags&disabled != 0; got == reached[ref] {
+			t.Errorf("polygon %d: disabled = %v, reachable = %v", ref, got, reached[ref])
+		}
+	}
+
+	// Filtering the disabled polygons out, the same polygons are reached.
+	filter := NewStandardQueryFilter()
+	filter.SetExcludeFlags(disabled)
+	refiltered, _ := ReachablePolys(mesh, []PolyRef{seed}, filter)
+	if len(refiltered) != len(reached) {
+		t.Errorf("ReachablePolys reached %d polygons with a filter, want %d", len(refiltered), len(reached))
+	}
+
+	if _, st := PruneUnreachable(mesh, []PolyRef{0}, disabled); !StatusFailed(st) {
+		t.Errorf("PruneUnreachable should fail with an invalid seed")
+	}
+}
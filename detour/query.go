@@ -1,12 +1,10 @@
 package detour
 
 import (
-	"log"
 	"math"
 	"unsafe"
 
 	assert "github.com/arl/assertgo"
-	"github.com/arl/gogeo/f32"
 	"github.com/arl/gogeo/f32/d3"
 	"github.com/arl/math32"
 )
@@ -31,6 +29,9 @@ const (
 
 // RaycastHit provides information about a raycast hit
 // filled by NavMeshQuery.Raycast
+//
+// Path is the only field set by the caller, all the other ones are reset by
+// Raycast, so the same RaycastHit can be reused for several raycasts.
 type RaycastHit struct {
 	// The hit parameter. (math.MaxFloat32 if no wall hit.)
 	T float32
@@ -41,19 +42,36 @@ type RaycastHit struct {
 	// The index of the edge on the final polygon where the wall was hit.
 	HitEdgeIndex int
 
-	// Pointer to an array of reference ids of the visited polygons. [opt]
+	// Path receives the reference ids of the visited polygons, it's set by
+	// the caller. Its length is the maximum number of visited polygons that
+	// can be stored, nil if they are not needed. [opt]
 	Path []PolyRef
 
-	// The number of visited polygons. [opt]
+	// The number of visited polygons stored in Path.
 	PathCount int
 
-	// The maximum number of polygons the @p path array can hold.
-	MaxPath int
-
 	//  The cost of the path until hit.
 	PathCost float32
 }
 
+// VisitedPath returns the visited polygons stored in Path.
+func (hit *RaycastHit) VisitedPath() []PolyRef {
+	return hit.Path[:hit.PathCount]
+}
+
+// reset resets the results, keeping Path and the HitNormal storage.
+func (hit *RaycastHit) reset() {
+	if len(hit.HitNormal) < 3 {
+		hit.HitNormal = d3.NewVec3()
+	} else {
+		hit.HitNormal[0], hit.HitNormal[1], hit.HitNormal[2] = 0, 0, 0
+	}
+	hit.T = 0
+	hit.HitEdgeIndex = 0
+	hit.PathCount = 0
+	hit.PathCost = 0
+}
+
 // NavMeshQuery provides the ability to perform pathfinding related queries
 // against a navigation mesh.
 //
@@ -65,6 +83,10 @@ type RaycastHit struct {
 // change to the closed list. No impact on an in-progress sliced path query.
 // Etc.). When that is the case it will be clearly stated in the method comment.
 //
+// A NavMeshQuery isn't safe for concurrent use, even by the methods without
+// side effects, which may reuse its temporaries. Use a NavMeshQuery per
+// goroutine.
+//
 // Walls and portals: A wall is a polygon segment that is considered impassable.
 // A portal is a passable segment between polygons. A portal may be treated as a
 // wall based on the QueryFilter used for a query.
@@ -76,6 +98,37 @@ type NavMeshQuery struct {
 	tinyNodePool *NodePool  // Pointer to small node pool.
 	nodePool     *NodePool  // Pointer to node pool.
 	openList     *nodeQueue // Pointer to open list queue.
+
+	stats *SpatialQueryStats // Spatial query statistics, nil if disabled.
+
+	scratch queryScratch // Temporaries reused across queries.
+}
+
+// queryBatchSize is the number of polygons passed at once to a polyQuery.
+const queryBatchSize = 32
+
+// queryScratch holds the temporaries of the hot queries, so that they don't
+// allocate on each call. A NavMeshQuery is not safe for concurrent use, so
+// sharing them between its methods is fine, as long as a method doesn't call
+// another one using the same temporaries.
+type queryScratch struct {
+	// Polygons batch of queryPolygonsInTile. The polyQuery implementations
+	// must not run another polygon query with the same NavMeshQuery.
+	batchRefs  [queryBatchSize]PolyRef
+	batchPolys [queryBatchSize]*Poly
+
+	// Polygons of a tile overlapping the query box, in queryPolygonsInTile.
+	tileRefs []PolyRef
+
+	// Polygon queries of FindNearestPolyEx, FindNearestPoly2D and
+	// queryPolygons6.
+	nearest   findNearestPolyQuery
+	nearest2D findNearestPoly2DQuery
+	collect   collectPolysQuery
+	nearestPt [3]float32
+
+	// Positions along the ray of Raycast.
+	curPos, lastPos [3]float32
 }
 
 type queryData struct {
@@ -108,8 +161,11 @@ func newQueryData() queryData {
 // Must be the first function called after construction, before other
 // functions are used.
 // This function can be used multiple times.
+//
+// By default, the searches fail with OutOfNodes when they need more than
+// maxNodes nodes. NodePool().SetGrowthLimit lets the node pool grow instead.
 func NewNavMeshQuery(nav *NavMesh, maxNodes int32) (Status, *NavMeshQuery) {
-	if maxNodes > int32(nullIdx) || maxNodes > int32(1<<nodeParentBits)-1 {
+	if maxNodes <= 0 || maxNodes > 65535 {
 		return Failure | InvalidParam, nil
 	}
 
@@ -120,7 +176,7 @@ func NewNavMeshQuery(nav *NavMesh, maxNodes int32) (Status, *NavMeshQuery) {
 		if q.nodePool != nil {
 			q.nodePool = nil
 		}
-		q.nodePool = newNodePool(maxNodes, int32(math32.NextPow2(uint32(maxNodes/4))))
+		q.nodePool = newNodePool(maxNodes)
 		if q.nodePool == nil {
 			return Failure | OutOfMemory, nil
 		}
@@ -129,7 +185,7 @@ func NewNavMeshQuery(nav *NavMesh, maxNodes int32) (Status, *NavMeshQuery) {
 	}
 
 	if q.tinyNodePool == nil {
-		q.tinyNodePool = newNodePool(64, 32)
+		q.tinyNodePool = newNodePool(64)
 		if q.tinyNodePool == nil {
 			return Failure | OutOfMemory, nil
 		}
@@ -181,12 +237,89 @@ func (q *NavMeshQuery) FindPath(
 	startPos, endPos d3.Vec3,
 	filter QueryFilter,
 	path []PolyRef) (pathCount int, st Status) {
+	return q.FindPathWithOptions(startRef, endRef, startPos, endPos, filter, path, nil)
+}
+
+// Heuristic estimates the cost of the path from pos to the end position of a
+// path query.
+//
+// In order for A* to find the optimal path, a heuristic should never
+// overestimate the actual cost, taking the query filter costs into account.
+type Heuristic func(pos, endPos d3.Vec3) float32
+
+// EuclideanHeuristic is the default path heuristic, it returns the straight
+// line distance between pos and endPos.
+func EuclideanHeuristic(pos, endPos d3.Vec3) float32 {
+	return pos.Dist(endPos)
+}
+
+// ZeroHeuristic is a heuristic that always returns zero, turning the A*
+// search into a Dijkstra search. It explores more nodes but always finds the
+// path of least cost, whatever the area costs.
+func ZeroHeuristic(pos, endPos d3.Vec3) float32 {
+	return 0
+}
+
+// FindPathOptions controls the search heuristic of
+// NavMeshQuery.FindPathWithOptions.
+type FindPathOptions struct {
+	// Heuristic estimates the remaining cost to the end position. If nil,
+	// EuclideanHeuristic is used.
+	Heuristic Heuristic
+
+	// HeuristicScale is the factor applied to the heuristic. If zero, HScale
+	// is used. Scales greater than 1 trade optimality for speed.
+	HeuristicScale float32
+}
+
+// FindPathWithOptions finds a path from the start polygon to the end polygon,
+// as FindPath does, with a custom search heuristic.
+//
+//	Arguments:
+//	 startRef  The reference id of the start polygon.
+//	 endRef    The reference id of the end polygon.
+//	 startPos  A position within the start polygon. [(x, y, z)]
+//	 endPos    A position within the end polygon. [(x, y, z)]
+//	 filter    The polygon filter to apply to the query.
+//	 path      This slice will be filled with an ordered list of polygon
+//	           references representing the path. (Start to end.)
+//	 opts      The search options. [opt]
+//
+//	Returns:
+//	 pathCount the number of polygons in the found path slice.
+//	 st        status code (may be a partial result)
+//
+// The default heuristic, the Euclidean distance scaled by HScale, assumes
+// that traversal costs are at least equal to the traveled distance. On
+// weighted terrains, where area costs are not close to 1, a custom heuristic
+// gives better paths or faster searches.
+//
+// Note: this method may be used by multiple clients without side effects.
+func (q *NavMeshQuery) FindPathWithOptions(
+	startRef, endRef PolyRef,
+	startPos, endPos d3.Vec3,
+	filter QueryFilter,
+	path []PolyRef,
+	opts *FindPathOptions) (pathCount int, st Status) {
 	// Validate input
 	if !q.nav.IsValidPolyRef(startRef) || !q.nav.IsValidPolyRef(endRef) ||
-		len(startPos) < 3 || len(endPos) < 3 || filter == nil || path == nil || len(path) == 0 {
+		!isValidVec3(startPos) || !isValidVec3(endPos) || filter == nil || len(path) == 0 {
 		return pathCount, Failure | InvalidParam
 	}
 
+	var (
+		heuristicFn Heuristic = EuclideanHeuristic
+		hscale      float32   = HScale
+	)
+	if opts != nil {
+		if opts.Heuristic != nil {
+			heuristicFn = opts.Heuristic
+		}
+		if opts.HeuristicScale != 0 {
+			hscale = opts.HeuristicScale
+		}
+	}
+
 	if startRef == endRef {
 		path[0] = startRef
 		return 1, Success
@@ -203,13 +336,15 @@ func (q *NavMeshQuery) FindPath(
 	startNode.Pos.Assign(startPos)
 	startNode.PIdx = 0
 	startNode.Cost = 0
-	startNode.Total = startPos.Dist(endPos) * HScale
+	startNode.Total = heuristicFn(startPos, endPos) * hscale
 	startNode.ID = startRef
 	startNode.Flags = nodeOpen
 	q.openList.push(startNode)
 
+	// The nearest node to the end position is tracked by distance, rather
+	// than by heuristic which may not reflect it (E.g. ZeroHeuristic).
 	lastBestNode = startNode
-	lastBestNodeCost = startNode.Total
+	lastBestNodeCost = startPos.Dist(endPos)
 
 	outOfNodes := false
 
@@ -288,12 +423,9 @@ func (q *NavMeshQuery) FindPath(
 			// If the node is visited the first time, calculate node position.
 			if neighbourNode.Flags == 0 {
 
-				status := q.edgeMidPoint(bestRef, bestPoly, bestTile,
+				q.edgeMidPoint(bestRef, bestPoly, bestTile,
 					neighbourRef, neighbourPoly, neighbourTile,
 					neighbourNode.Pos[:])
-				if StatusFailed(status) {
-					log.Println("getEdgeMidPoint failed:", status)
-				}
 			}
 
 			// Calculate cost and heuristic.
@@ -320,7 +452,7 @@ func (q *NavMeshQuery) FindPath(
 					bestRef, bestTile, bestPoly,
 					neighbourRef, neighbourTile, neighbourPoly)
 				cost = bestNode.Cost + curCost
-				heuristic = neighbourNode.Pos.Dist(endPos) * HScale
+				heuristic = heuristicFn(neighbourNode.Pos, endPos) * hscale
 			}
 
 			total := cost + heuristic
@@ -351,8 +483,12 @@ func (q *NavMeshQuery) FindPath(
 			}
 
 			// Update nearest node to target so far.
-			if heuristic < lastBestNodeCost {
-				lastBestNodeCost = heuristic
+			var dist float32
+			if neighbourRef != endRef {
+				dist = neighbourNode.Pos.Dist(endPos)
+			}
+			if dist < lastBestNodeCost {
+				lastBestNodeCost = dist
 				lastBestNode = neighbourNode
 			}
 		}
@@ -409,7 +545,11 @@ const (
 // straight path.
 //
 // The straightPath, straightPathFlags and straightPathRefs slices must already
-// be allocated and contain the same number of elements.
+// be allocated and contain the same number of elements. FindStraightPath
+// doesn't allocate memory.
+//
+// See FindStraightPathFlat for a variant writing the points into a flat
+// buffer.
 //
 // Note: this method may be used by multiple clients without side effects.
 func (q *NavMeshQuery) FindStraightPath(
@@ -420,26 +560,119 @@ func (q *NavMeshQuery) FindStraightPath(
 	straightPathRefs []PolyRef,
 	options int32) (straightPathCount int, st Status) {
 
+	for i := range straightPath {
+		if len(straightPath[i]) < 3 {
+			return 0, Failure | InvalidParam
+		}
+	}
+	verts := straightPathVerts{vecs: straightPath, isVecs: true}
+	return q.findStraightPath(startPos, endPos, path, &verts,
+		straightPathFlags, straightPathRefs, options)
+}
+
+// FindStraightPathFlat finds the straight path from the start to the end
+// position within the polygon corridor, writing the path points in a flat
+// buffer.
+//
+//	Arguments:
+//	 startPos          Path start position. [(x, y, z)]
+//	 endPos            Path end position. [(x, y, z)]
+//	 path              An array of polygon references that represent the
+//	                   path corridor.
+//	 straightPath      Points describing the straight path, as consecutive
+//	                   (x, y, z) triplets. [Length: == 3 * straightPathCount].
+//	 straightPathFlags Flags describing each point.
+//	                   (See: StraightPathFlags)
+//	 straightPathRefs  The reference id of the polygon that is being
+//	                   entered at each point.
+//	 options           Query options. (see: StraightPathOptions)
+//
+// Returns The status flags for the query and the number of point in the
+// straight path.
+//
+// The maximum number of points is len(straightPath)/3; straightPathFlags and
+// straightPathRefs, if not empty, must contain at least that number of
+// elements. FindStraightPathFlat doesn't allocate memory.
+//
+// Note: this method may be used by multiple clients without side effects.
+func (q *NavMeshQuery) FindStraightPathFlat(
+	startPos, endPos d3.Vec3,
+	path []PolyRef,
+	straightPath []float32,
+	straightPathFlags []uint8,
+	straightPathRefs []PolyRef,
+	options int32) (straightPathCount int, st Status) {
+
+	verts := straightPathVerts{flat: straightPath}
+	return q.findStraightPath(startPos, endPos, path, &verts,
+		straightPathFlags, straightPathRefs, options)
+}
+
+// straightPathVerts gives access to the points of a straight path, be they
+// stored in a slice of vectors or in a flat buffer.
+type straightPathVerts struct {
+	vecs   []d3.Vec3
+	flat   []float32
+	isVecs bool
+}
+
+// len returns the maximum number of points.
+func (v *straightPathVerts) len() int {
+	if v.isVecs {
+		return len(v.vecs)
+	}
+	return len(v.flat) / 3
+}
+
+// at returns the i-th point.
+func (v *straightPathVerts) at(i int) d3.Vec3 {
+	if v.isVecs {
+		return v.vecs[i]
+	}
+	return d3.Vec3(v.flat[i*3 : i*3+3 : i*3+3])
+}
+
+// findStraightPath implements FindStraightPath and FindStraightPathFlat.
+func (q *NavMeshQuery) findStraightPath(
+	startPos, endPos d3.Vec3,
+	path []PolyRef,
+	straightPath *straightPathVerts,
+	straightPathFlags []uint8,
+	straightPathRefs []PolyRef,
+	options int32) (straightPathCount int, st Status) {
+
 	// parameter check
-	if len(straightPath) == 0 {
+	if straightPath.len() == 0 {
 		return 0, Failure | InvalidParam
 	}
 	if len(path) == 0 {
 		return 0, Failure | InvalidParam
 	}
+	if !isValidVec3(startPos) || !isValidVec3(endPos) {
+		return 0, Failure | InvalidParam
+	}
 
 	var (
 		stat  Status
 		count int
 	)
 
+	// The temporary points are backed by arrays, on the stack, so that finding
+	// a straight path doesn't allocate.
+	var (
+		closestStartBuf, closestEndBuf [3]float32
+		apexBuf, portalLeftBuf         [3]float32
+		portalRightBuf, leftBuf        [3]float32
+		rightBuf                       [3]float32
+	)
+
 	// TODO: Should this be callers responsibility?
-	closestStartPos := d3.NewVec3()
+	closestStartPos := d3.Vec3(closestStartBuf[:])
 	if StatusFailed(q.ClosestPointOnPolyBoundary(path[0], startPos, closestStartPos)) {
 		return 0, Failure | InvalidParam
 	}
 
-	closestEndPos := d3.NewVec3()
+	closestEndPos := d3.Vec3(closestEndBuf[:])
 	if StatusFailed(q.ClosestPointOnPolyBoundary(path[len(path)-1], endPos, closestEndPos)) {
 		return 0, Failure | InvalidParam
 	}
@@ -449,14 +682,18 @@ func (q *NavMeshQuery) FindStraightPath(
 		straightPath, straightPathFlags, straightPathRefs,
 		&count)
 	if stat != InProgress {
-		//fmt.Println("FindStraightPath returns", stat, count)
 		return count, stat
 	}
 
 	if len(path) > 1 {
-		portalApex := d3.NewVec3From(closestStartPos)
-		portalLeft := d3.NewVec3From(portalApex)
-		portalRight := d3.NewVec3From(portalApex)
+		portalApex := d3.Vec3(apexBuf[:])
+		portalLeft := d3.Vec3(portalLeftBuf[:])
+		portalRight := d3.Vec3(portalRightBuf[:])
+		portalApex.Assign(closestStartPos)
+		portalLeft.Assign(portalApex)
+		portalRight.Assign(portalApex)
+		left := d3.Vec3(leftBuf[:])
+		right := d3.Vec3(rightBuf[:])
 		var (
 			apexIndex     int
 			leftIndex     int
@@ -469,8 +706,6 @@ func (q *NavMeshQuery) FindStraightPath(
 		rightPolyRef := path[0]
 
 		for i := 0; i < len(path); i++ {
-			left := d3.NewVec3()
-			right := d3.NewVec3()
 			var toType uint8
 
 			if i+1 < len(path) {
@@ -499,10 +734,9 @@ func (q *NavMeshQuery) FindStraightPath(
 						&count)
 
 					stat = Success | PartialResult
-					if count >= len(straightPath) {
+					if count >= straightPath.len() {
 						stat |= BufferTooSmall
 					}
-					//fmt.Println("FindStraightPath 2 returns", stat, count)
 					return count, stat
 				}
 
@@ -538,7 +772,6 @@ func (q *NavMeshQuery) FindStraightPath(
 							straightPath, straightPathFlags, straightPathRefs,
 							&count, options)
 						if stat != InProgress {
-							//fmt.Println("FindStraightPath 3 returns", stat, count)
 							return count, stat
 						}
 					}
@@ -559,7 +792,6 @@ func (q *NavMeshQuery) FindStraightPath(
 						straightPath, straightPathFlags, straightPathRefs,
 						&count)
 					if stat != InProgress {
-						//fmt.Println("FindStraightPath 4 returns", stat, count)
 						return count, stat
 					}
 
@@ -593,7 +825,6 @@ func (q *NavMeshQuery) FindStraightPath(
 							straightPath, straightPathFlags, straightPathRefs,
 							&count, options)
 						if stat != InProgress {
-							//fmt.Println("FindStraightPath 5 returns", stat, count)
 							return count, stat
 						}
 					}
@@ -614,7 +845,6 @@ func (q *NavMeshQuery) FindStraightPath(
 						straightPath, straightPathFlags, straightPathRefs,
 						&count)
 					if stat != InProgress {
-						//fmt.Println("FindStraightPath 6 returns", stat, count)
 						return count, stat
 					}
 
@@ -637,7 +867,6 @@ func (q *NavMeshQuery) FindStraightPath(
 				straightPath, straightPathFlags, straightPathRefs,
 				&count, options)
 			if stat != InProgress {
-				//fmt.Println("FindStraightPath 7 returns", stat, count)
 				return count, stat
 			}
 		}
@@ -649,10 +878,9 @@ func (q *NavMeshQuery) FindStraightPath(
 		&count)
 
 	stat = Success
-	if count >= len(straightPath) {
+	if count >= straightPath.len() {
 		stat |= BufferTooSmall
 	}
-	//fmt.Println("FindStraightPath 8 returns", stat, count)
 	return count, stat
 }
 
@@ -661,13 +889,16 @@ func (q *NavMeshQuery) appendPortals(
 	startIdx, endIdx int,
 	endPos d3.Vec3,
 	path []PolyRef,
-	straightPath []d3.Vec3,
+	straightPath *straightPathVerts,
 	straightPathFlags []uint8,
 	straightPathRefs []PolyRef,
 	straightPathCount *int,
 	options int32) Status {
 
-	startPos := straightPath[*straightPathCount-1]
+	startPos := straightPath.at(*straightPathCount - 1)
+	var leftBuf, rightBuf, ptBuf [3]float32
+	left, right, pt := d3.Vec3(leftBuf[:]), d3.Vec3(rightBuf[:]), d3.Vec3(ptBuf[:])
+
 	// Append or update last vertex
 	var stat Status
 	for i := startIdx; i < endIdx; i++ {
@@ -691,8 +922,6 @@ func (q *NavMeshQuery) appendPortals(
 			return Failure | InvalidParam
 		}
 
-		left := d3.NewVec3()
-		right := d3.NewVec3()
 		if StatusFailed(q.portalPoints8(from, fromPoly, fromTile, to, toPoly, toTile, left, right)) {
 			break
 		}
@@ -706,7 +935,6 @@ func (q *NavMeshQuery) appendPortals(
 
 		// Append intersection
 		if hit, _, t := IntersectSegSeg2D(startPos, endPos, left, right); hit {
-			pt := d3.NewVec3()
 			d3.Vec3Lerp(pt, left, right, t)
 
 			stat = q.appendVertex(pt, 0, path[i+1],
@@ -725,12 +953,12 @@ func (q *NavMeshQuery) appendVertex(
 	pos d3.Vec3,
 	flags uint8,
 	ref PolyRef,
-	straightPath []d3.Vec3,
+	straightPath *straightPathVerts,
 	straightPathFlags []uint8,
 	straightPathRefs []PolyRef,
 	straightPathCount *int) Status {
 
-	if (*straightPathCount) > 0 && pos.Approx(straightPath[*straightPathCount-1]) {
+	if (*straightPathCount) > 0 && pos.Approx(straightPath.at(*straightPathCount-1)) {
 		// The vertices are equal, update flags and poly.
 		if len(straightPathFlags) > 0 {
 			straightPathFlags[*straightPathCount-1] = flags
@@ -739,8 +967,14 @@ func (q *NavMeshQuery) appendVertex(
 			straightPathRefs[*straightPathCount-1] = ref
 		}
 	} else {
+		// The path may already be full when the vertices of a partial path
+		// are appended.
+		if (*straightPathCount) >= straightPath.len() {
+			return Success | BufferTooSmall
+		}
+
 		// Append new vertex.
-		straightPath[*straightPathCount].Assign(pos)
+		straightPath.at(*straightPathCount).Assign(pos)
 		if len(straightPathFlags) > 0 {
 			straightPathFlags[*straightPathCount] = flags
 		}
@@ -750,7 +984,7 @@ func (q *NavMeshQuery) appendVertex(
 		(*straightPathCount)++
 
 		// If there is no space to append more vertices, return.
-		if (*straightPathCount) >= len(straightPath) {
+		if (*straightPathCount) >= straightPath.len() {
 			return Success | BufferTooSmall
 		}
 
@@ -937,6 +1171,11 @@ func (q *NavMeshQuery) pathToNode(
 // pos does not have to be within the bounds of the polygon or navigation mesh.
 // See ClosestPointOnPolyBoundary() for a limited but faster option.
 //
+// If pos is within the xz-bounds of the polygon, posOverPoly is set to true
+// and closest is right below, or above, pos on the detail surface, its height
+// is the one returned by PolyHeight. Otherwise closest is the nearest point
+// of the boundary edges of the detail mesh, on the xz-plane.
+//
 // Note: this method may be used by multiple clients without side effects.
 func (q *NavMeshQuery) ClosestPointOnPoly(ref PolyRef, pos, closest d3.Vec3, posOverPoly *bool) Status {
 	assert.True(q.nav != nil, "NavMesh should not be nil")
@@ -951,101 +1190,31 @@ func (q *NavMeshQuery) ClosestPointOnPoly(ref PolyRef, pos, closest d3.Vec3, pos
 	if tile == nil {
 		return Failure | InvalidParam
 	}
-
-	// Off-mesh connections don't have detail polygons.
-	if poly.Type() == polyTypeOffMeshConnection {
-		var (
-			v0, v1    d3.Vec3
-			d0, d1, u float32
-		)
-		vidx := poly.Verts[0] * 3
-		v0 = tile.Verts[vidx : vidx+3]
-		vidx = poly.Verts[1] * 3
-		v1 = tile.Verts[vidx : vidx+3]
-		d0 = pos.Dist(v0)
-		d1 = pos.Dist(v1)
-		u = d0 / (d0 + d1)
-		d3.Vec3Lerp(closest, v0, v1, u)
-		if posOverPoly != nil {
-			*posOverPoly = false
-		}
-		return Success
-	}
-
-	ip := (uintptr(unsafe.Pointer(poly)) - uintptr(unsafe.Pointer(&tile.Polys[0]))) / unsafe.Sizeof(*poly)
-	pd := &tile.DetailMeshes[uint32(ip)]
-
-	// Clamp point to be inside the polygon.
-	verts := make([]float32, VertsPerPolygon*3)
-	edged := make([]float32, VertsPerPolygon)
-	edget := make([]float32, VertsPerPolygon)
-	nv := poly.VertCount
-	var i uint8
-	for i = 0; i < nv; i++ {
-		idx := i * 3
-		jdx := poly.Verts[i] * 3
-		copy(verts[idx:idx+3], tile.Verts[jdx:jdx+3])
+	if !isValidVec3(pos) || len(closest) < 3 {
+		return Failure | InvalidParam
 	}
 
-	closest.Assign(pos)
-	if !distancePtPolyEdgesSqr(pos, verts, int32(nv), edged, edget) {
-		// Point is outside the polygon, clamp to nearest edge.
-		dmin := edged[0]
-		var imin uint8
-		for i = 1; i < nv; i++ {
-			if edged[i] < dmin {
-				dmin = edged[i]
-				imin = i
-			}
-		}
-		idx := imin * 3
-		va := verts[idx : idx+3]
-		idx = ((imin + 1) % nv) * 3
-		vb := verts[idx : idx+3]
-		d3.Vec3Lerp(closest, va, vb, edget[imin])
-
-		if posOverPoly != nil {
-			*posOverPoly = false
-		}
-	} else {
-		if posOverPoly != nil {
-			*posOverPoly = true
-		}
-	}
-
-	// Find height at the location.
-	var j uint8
-	var idx int
-	for j = 0; j < pd.TriCount; j++ {
-		idx = int((pd.TriBase + uint32(j)) * 4)
-		t := tile.DetailTris[idx : idx+3]
-		v := make([][]float32, 3)
-		var k int
-		for k = 0; k < 3; k++ {
-			if t[k] < poly.VertCount {
-				idx = int(poly.Verts[t[k]] * 3)
-				v[k] = tile.Verts[idx : idx+3]
-			} else {
-				idx = int((pd.VertBase + uint32(t[k]-poly.VertCount)) * 3)
-				v[k] = tile.DetailVerts[idx : idx+3]
-			}
-		}
-		var h float32
-		if closestHeightPointTriangle(closest, v[0], v[1], v[2], &h) {
-			closest[1] = h
-			break
-		}
-	}
+	q.nav.closestPointOnPoly(ref, pos, closest, posOverPoly)
 	return Success
 }
 
-// ClosestPointOnPolyBoundary uses the detail polygons to find the surface
-// height. (Much faster than closestPointOnPoly())
+// ClosestPointOnPolyBoundary returns a point on the boundary closest to the
+// source point if the source point is outside the polygon's xz-bounds.
+//
+//	Arguments:
+//	 ref      The reference id to the polygon.
+//	 pos      The position to check. [(x, y, z)]
+//	 closest  The closest point. [(x, y, z)]
+//
+// Faster than ClosestPointOnPoly, since the detail mesh is not used.
+// This makes it the method of choice to clamp movements, when the surface
+// height is not needed or is computed afterward.
 //
 // If the provided position lies within the polygon's xz-bounds (above or
-// below), then pos and closest will be equal. The height of closest will be the
-// polygon boundary. The height detail is not used. pos does not have to be
-// within the bounds of the polybon or the navigation mesh.
+// below), then pos and closest will be equal. Otherwise closest is on the
+// polygon boundary, and its height is interpolated from the polygon vertices.
+// The height detail is not used. pos does not have to be within the bounds of
+// the polygon or the navigation mesh.
 //
 // Note: this method may be used by multiple clients without side effects.
 func (q *NavMeshQuery) ClosestPointOnPolyBoundary(ref PolyRef, pos, closest d3.Vec3) Status {
@@ -1056,6 +1225,9 @@ func (q *NavMeshQuery) ClosestPointOnPolyBoundary(ref PolyRef, pos, closest d3.V
 	if StatusFailed(q.nav.TileAndPolyByRef(ref, &tile, &poly)) {
 		return Failure | InvalidParam
 	}
+	if !isValidVec3(pos) || len(closest) < 3 {
+		return Failure | InvalidParam
+	}
 
 	// Collect vertices.
 	var (
@@ -1065,7 +1237,8 @@ func (q *NavMeshQuery) ClosestPointOnPolyBoundary(ref PolyRef, pos, closest d3.V
 		nv    int32
 	)
 	for i := uint8(0); i < poly.VertCount; i++ {
-		copy(verts[nv*3:nv*3+3], tile.Verts[poly.Verts[i]*3:poly.Verts[i]*3+3])
+		v := tile.Verts[poly.Verts[i]*3 : poly.Verts[i]*3+3]
+		verts[nv*3], verts[nv*3+1], verts[nv*3+2] = v[0], v[1], v[2]
 		nv++
 	}
 
@@ -1092,6 +1265,232 @@ func (q *NavMeshQuery) ClosestPointOnPolyBoundary(ref PolyRef, pos, closest d3.V
 	return Success
 }
 
+// PolyHeight returns the height of the polygon at the provided position,
+// using the height detail. (Most accurate.)
+//
+//	Arguments:
+//	 ref      The reference id of the polygon.
+//	 pos      A position within the xz-bounds of the polygon. [(x, y, z)]
+//
+//	Return values:
+//	 height   The height at the surface of the polygon.
+//	 st       The status flags for the query.
+//
+// Will return Failure|InvalidParam if the provided position is outside the
+// xz-bounds of the polygon. For off-mesh connections, the height is
+// interpolated along the connection segment.
+//
+// Note: this method may be used by multiple clients without side effects.
+func (q *NavMeshQuery) PolyHeight(ref PolyRef, pos d3.Vec3) (height float32, st Status) {
+	assert.True(q.nav != nil, "NavMesh should not be nil")
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if !isValidVec3(pos) || StatusFailed(q.nav.TileAndPolyByRef(ref, &tile, &poly)) {
+		return 0, Failure | InvalidParam
+	}
+
+	if poly.Type() == polyTypeOffMeshConnection {
+		var t float32
+		v0 := d3.Vec3(tile.Verts[poly.Verts[0]*3 : poly.Verts[0]*3+3])
+		v1 := d3.Vec3(tile.Verts[poly.Verts[1]*3 : poly.Verts[1]*3+3])
+		distancePtSegSqr2D(pos, v0, v1, &t)
+		return v0[1] + (v1[1]-v0[1])*t, Success
+	}
+
+	if !q.nav.polyHeight(tile, poly, pos, &height) {
+		return 0, Failure | InvalidParam
+	}
+	return height, Success
+}
+
+// PointInPoly reports whether a position is inside a polygon.
+//
+//	Arguments:
+//	 ref      The reference id of the polygon.
+//	 pos      The position to test. [(x, y, z)]
+//	 height   The maximum vertical distance between pos and the surface of
+//	          the polygon, or a negative value to ignore heights.
+//
+//	Return values:
+//	 inside   True if pos is inside the polygon.
+//	 st       The status flags for the query.
+//
+// The containment test is done in the xz-plane, it's much cheaper than
+// ClosestPointOnPoly. If height is not negative, pos must also be at most
+// height above or below the detail surface of the polygon, which allows to
+// tell overlapping polygons apart, like the floors of a building. Points on
+// the polygon edges may be reported on either side.
+//
+// Off-mesh connections have no surface, nothing is ever inside them.
+//
+// Note: this method may be used by multiple clients without side effects.
+func (q *NavMeshQuery) PointInPoly(ref PolyRef, pos d3.Vec3, height float32) (inside bool, st Status) {
+	assert.True(q.nav != nil, "NavMesh should not be nil")
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if !isValidVec3(pos) || StatusFailed(q.nav.TileAndPolyByRef(ref, &tile, &poly)) {
+		return false, Failure | InvalidParam
+	}
+	if poly.Type() == polyTypeOffMeshConnection {
+		return false, Success
+	}
+
+	var verts [VertsPerPolygon * 3]float32
+	nv := int(poly.VertCount)
+	for i := 0; i < nv; i++ {
+		copy(verts[i*3:i*3+3], tile.Verts[poly.Verts[i]*3:poly.Verts[i]*3+3])
+	}
+	if !pointInPolygon(pos, verts[:], nv) {
+		return false, Success
+	}
+	if height < 0 {
+		return true, Success
+	}
+
+	h, hst := q.PolyHeight(ref, pos)
+	if StatusFailed(hst) {
+		// pos is on the polygon boundary, outside of the detail triangles.
+		return false, Success
+	}
+	return math32.Abs(pos[1]-h) <= height, Success
+}
+
+// PolyCentroid returns the centroid of a polygon.
+//
+//	Arguments:
+//	 ref      The reference id of the polygon.
+//
+//	Return values:
+//	 centroid The centroid of the polygon. [(x, y, z)]
+//	 st       The status flags for the query.
+//
+// The centroid is the area-weighted center of the polygon in the xz-plane,
+// its height is sampled on the height detail. As navigation mesh polygons are
+// convex, the centroid always lies inside the polygon, which is not the case
+// of the average of the polygon vertices when the detail surface is not
+// planar. For off-mesh connections, the centroid is the middle of the
+// connection segment.
+//
+// Note: this method may be used by multiple clients without side effects.
+func (q *NavMeshQuery) PolyCentroid(ref PolyRef) (centroid d3.Vec3, st Status) {
+	assert.True(q.nav != nil, "NavMesh should not be nil")
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if StatusFailed(q.nav.TileAndPolyByRef(ref, &tile, &poly)) {
+		return nil, Failure | InvalidParam
+	}
+
+	vert := func(i uint8) d3.Vec3 {
+		vidx := poly.Verts[i] * 3
+		return tile.Verts[vidx : vidx+3]
+	}
+
+	if poly.Type() == polyTypeOffMeshConnection {
+		return vert(0).Lerp(vert(1), 0.5), Success
+	}
+
+	// Sum the centroids of the triangle fan, weighted by their area.
+	var area float32
+	centroid = d3.NewVec3()
+	v0 := vert(0)
+	for i := uint8(2); i < poly.VertCount; i++ {
+		va, vb := vert(i-1), vert(i)
+		a := math32.Abs(TriArea2D(v0, va, vb))
+		area += a
+		for k := 0; k < 3; k += 2 {
+			centroid[k] += a * (v0[k] + va[k] + vb[k]) / 3
+		}
+	}
+	if area > 0 {
+		centroid[0] /= area
+		centroid[2] /= area
+	} else {
+		// Degenerate polygon, fallback to the vertices average.
+		centroid[0], centroid[2] = 0, 0
+		for i := uint8(0); i < poly.VertCount; i++ {
+			centroid[0] += vert(i)[0]
+			centroid[2] += vert(i)[2]
+		}
+		centroid[0] /= float32(poly.VertCount)
+		centroid[2] /= float32(poly.VertCount)
+	}
+
+	// Sample the height on the detail mesh, falling back to the closest
+	// point on the polygon (E.g. for a degenerate polygon).
+	if h, st := q.PolyHeight(ref, centroid); StatusSucceed(st) {
+		centroid[1] = h
+		return centroid, Success
+	}
+	closest := d3.NewVec3()
+	if st := q.ClosestPointOnPoly(ref, centroid, closest, nil); StatusFailed(st) {
+		return nil, st
+	}
+	return closest, Success
+}
+
+// PolyRepresentativePoint returns a point that is guaranteed to lie inside a
+// polygon, on its detail surface.
+//
+//	Arguments:
+//	 ref      The reference id of the polygon.
+//
+//	Return values:
+//	 pt       The representative point of the polygon. [(x, y, z)]
+//	 st       The status flags for the query.
+//
+// The representative point is the center of the largest triangle of the
+// polygon height detail. Being strictly inside a detail triangle, it stays
+// away from the polygon edges and doesn't depend on height sampling. For
+// off-mesh connections, the representative point is the middle of the
+// connection segment.
+//
+// Note: this method may be used by multiple clients without side effects.
+func (q *NavMeshQuery) PolyRepresentativePoint(ref PolyRef) (pt d3.Vec3, st Status) {
+	assert.True(q.nav != nil, "NavMesh should not be nil")
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if StatusFailed(q.nav.TileAndPolyByRef(ref, &tile, &poly)) {
+		return nil, Failure | InvalidParam
+	}
+
+	if poly.Type() == polyTypeOffMeshConnection {
+		v0 := d3.Vec3(tile.Verts[poly.Verts[0]*3 : poly.Verts[0]*3+3])
+		v1 := d3.Vec3(tile.Verts[poly.Verts[1]*3 : poly.Verts[1]*3+3])
+		return v0.Lerp(v1, 0.5), Success
+	}
+
+	ip := (uintptr(unsafe.Pointer(poly)) - uintptr(unsafe.Pointer(&tile.Polys[0]))) / unsafe.Sizeof(*poly)
+	pd := &tile.DetailMeshes[uint32(ip)]
+	if pd.TriCount == 0 {
+		return q.PolyCentroid(ref)
+	}
+
+	var (
+		best    [3]d3.Vec3
+		maxArea float32 = -1
+	)
+	for j := uint8(0); j < pd.TriCount; j++ {
+		v := tile.detailTriVerts(poly, pd, j)
+		if a := math32.Abs(TriArea2D(v[0], v[1], v[2])); a > maxArea {
+			maxArea = a
+			best = v
+		}
+	}
+	pt = d3.NewVec3()
+	for k := 0; k < 3; k++ {
+		pt[k] = (best[0][k] + best[1][k] + best[2][k]) / 3
+	}
+	return pt, Success
+}
+
 // FindNearestPoly finds the polygon nearest to the specified center point.
 //
 //	Arguments:
@@ -1109,27 +1508,137 @@ func (q *NavMeshQuery) ClosestPointOnPolyBoundary(ref PolyRef, pos, closest d3.V
 // will be 'Success', but ref will be zero. So if in doubt, check ref before
 // using pt.
 //
-// Note: this method may be used by multiple clients without side effects.
+// Note: this method doesn't impact an in-progress sliced path query, but it
+// reuses temporaries of q, so it isn't safe for concurrent use.
+//
+// Note: pt is allocated on each call. Use FindNearestPolyEx to write the
+// nearest point in a vector provided by the caller instead.
+//
+// see FindNearestPolyEx, FindNearestPoly2D
 func (q *NavMeshQuery) FindNearestPoly(center, extents d3.Vec3,
 	filter QueryFilter) (st Status, ref PolyRef, pt d3.Vec3) {
 
+	pt = d3.NewVec3()
+	if st, ref, _, _ = q.FindNearestPolyEx(center, extents, filter, pt); ref == 0 {
+		pt = nil
+	}
+	return
+}
+
+// FindNearestPolyEx finds the polygon nearest to the specified center point,
+// like FindNearestPoly, and also returns the distance to the nearest point
+// and whether the center is over the nearest polygon.
+//
+//	Arguments:
+//	 center   The center of the search box.
+//	 extents  A vector which components represent the
+//	          search distance along each axis.
+//	 filter   The polygon filter to apply to the query.
+//	 [out]nearestPt  The nearest point on the polygon, only written if a
+//	                 polygon is found. [(x, y, z)]
+//
+//	Return values:
+//	 st         The status flags for the query.
+//	 ref        The reference id of the nearest polygon.
+//	 distSqr    The squared distance between center and nearestPt.
+//	 isOverPoly True if center is directly over the nearest polygon, in which
+//	            case nearestPt is right below, or above, center.
+//
+// When the center is over a polygon, and closer to it than the walkable climb
+// height, that polygon is favored over polygons that are closer in straight
+// line, so distSqr isn't necessarily the smallest distance to the polygons in
+// the search box.
+//
+// Note: If the search box does not intersect any polygons the returned status
+// will be 'Success', but ref will be zero. So if in doubt, check ref before
+// using nearestPt.
+//
+// Note: this method doesn't impact an in-progress sliced path query, but it
+// reuses temporaries of q, so it isn't safe for concurrent use. It doesn't
+// allocate.
+func (q *NavMeshQuery) FindNearestPolyEx(center, extents d3.Vec3,
+	filter QueryFilter, nearestPt d3.Vec3) (st Status, ref PolyRef, distSqr float32, isOverPoly bool) {
+
 	assert.True(q.nav != nil, "Nav should not be nil")
 
-	query := newFindNearestPolyQuery(q, center)
+	query := &q.scratch.nearest
+	query.init(q, center, q.scratch.nearestPt[:])
 	st = q.queryPolygons4(center, extents, filter, query)
 	if StatusFailed(st) {
 		return
 	}
 
-	// Only allocate pt if we actually found
-	// a poly so the nearest point pt is valid.
+	// Only write nearestPt if we actually found
+	// a poly so the nearest point is valid.
 	if ref = query.nearestRef; ref != 0 {
-		pt = d3.NewVec3From(query.nearestPoint)
+		nearestPt.Assign(query.nearestPoint)
+		distSqr = query.nearestPointDist
+		isOverPoly = query.nearestOverPoly
 	}
 	st = Success
 	return
 }
 
+// FindNearestPoly2D finds the polygon nearest to the specified center point on
+// the xz-plane, within a vertical tolerance.
+//
+//	Arguments:
+//	 center      The center of the search box.
+//	 extents     A vector which components represent the
+//	             search distance along each axis.
+//	 heightTol   The maximum height difference between center and the
+//	             polygon. [Limit: >= 0]
+//	 filter      The polygon filter to apply to the query.
+//	 [out]nearestPt  The nearest point on the polygon, only written if a
+//	                 polygon is found. [(x, y, z)]
+//
+//	Return values:
+//	 st          The status flags for the query.
+//	 ref         The reference id of the nearest polygon.
+//	 isOverPoly  True if center is directly over, or under, the nearest
+//	             polygon, in which case nearestPt is right below, or above,
+//	             center.
+//
+// FindNearestPoly picks the polygon nearest in straight line, which, in a
+// multi-floor building, may be on the floor below or above center when center
+// isn't right over a polygon, near a wall for example. FindNearestPoly2D
+// ignores the polygons more than heightTol above or below center, then picks
+// the polygon nearest on the xz-plane, a polygon center is over first. Among
+// the polygons at the same xz-plane distance, the nearest vertically is
+// picked.
+//
+// The search box should be at least heightTol high. If no polygon is found
+// within the tolerance, the returned status will be 'Success', but ref will
+// be zero.
+//
+// Note: this method doesn't impact an in-progress sliced path query, but it
+// reuses temporaries of q, so it isn't safe for concurrent use. It doesn't
+// allocate.
+//
+// see FindNearestPoly
+func (q *NavMeshQuery) FindNearestPoly2D(center, extents d3.Vec3, heightTol float32,
+	filter QueryFilter, nearestPt d3.Vec3) (st Status, ref PolyRef, isOverPoly bool) {
+
+	assert.True(q.nav != nil, "Nav should not be nil")
+
+	if !(heightTol >= 0) {
+		return Failure | InvalidParam, 0, false
+	}
+
+	query := &q.scratch.nearest2D
+	query.init(q, center, heightTol, q.scratch.nearestPt[:])
+	st = q.queryPolygons4(center, extents, filter, query)
+	if StatusFailed(st) {
+		return st, 0, false
+	}
+
+	if ref = query.nearestRef; ref != 0 {
+		nearestPt.Assign(query.nearestPoint)
+		isOverPoly = query.nearestOverPoly
+	}
+	return Success, ref, isOverPoly
+}
+
 // queryPolygons6 finds polygons that overlap the search box.
 //
 //	Arguments:
@@ -1148,7 +1657,8 @@ func (q *NavMeshQuery) FindNearestPoly(center, extents d3.Vec3,
 // will be filled to capacity. The method of choosing which polygons from the
 // full set are included in the partial result set is undefined.
 //
-// Note: this method may be used by multiple clients without side effects.
+// Note: this method doesn't impact an in-progress sliced path query, but it
+// reuses temporaries of q, so it isn't safe for concurrent use.
 func (q *NavMeshQuery) queryPolygons6(
 	center, extents []float32,
 	filter QueryFilter,
@@ -1160,7 +1670,8 @@ func (q *NavMeshQuery) queryPolygons6(
 		return
 	}
 
-	collector := newCollectPolysQuery(polys, maxPolys)
+	collector := &q.scratch.collect
+	collector.init(polys, maxPolys)
 
 	st = q.queryPolygons4(center, extents, filter, collector)
 	if StatusFailed(st) {
@@ -1189,7 +1700,8 @@ func (q *NavMeshQuery) queryPolygons6(
 // this function. The polyQuery.process function is invoked multiple times until
 // all overlapping polygons have been processed.
 //
-// Note: this method may be used by multiple clients without side effects.
+// Note: this method doesn't impact an in-progress sliced path query, but it
+// reuses temporaries of q, so it isn't safe for concurrent use.
 func (q *NavMeshQuery) queryPolygons4(
 	center, extents d3.Vec3,
 	filter QueryFilter,
@@ -1199,6 +1711,10 @@ func (q *NavMeshQuery) queryPolygons4(
 		return Failure | InvalidParam
 	}
 
+	if q.stats != nil {
+		q.stats.reset()
+	}
+
 	bmin := center.Sub(extents)
 	bmax := center.Add(extents)
 
@@ -1228,116 +1744,31 @@ func (q *NavMeshQuery) queryPolygonsInTile(
 	query polyQuery) {
 
 	assert.True(q.nav != nil, "navmesh should not be nill")
-	batchSize := int32(32)
-
-	polyRefs := make([]PolyRef, batchSize)
-	polys := make([]*Poly, batchSize)
-	var n int32
-
-	if len(tile.BvTree) > 0 {
-
-		var (
-			node            *BvNode
-			nodeIdx, endIdx int32
-			tbmin, tbmax    d3.Vec3
-			qfac            float32
-		)
+	batchSize := int32(queryBatchSize)
 
-		nodeIdx = 0
-		endIdx = tile.Header.BvNodeCount
-
-		tbmin = d3.NewVec3From(tile.Header.BMin[:])
-		tbmax = d3.NewVec3From(tile.Header.BMax[:])
-		qfac = tile.Header.BvQuantFactor
-
-		// Calculate quantized box
-		var bmin, bmax [3]uint16
-
-		// Clamp query box to world box.
-		minx := f32.Clamp(qmin[0], tbmin[0], tbmax[0]) - tbmin[0]
-		miny := f32.Clamp(qmin[1], tbmin[1], tbmax[1]) - tbmin[1]
-		minz := f32.Clamp(qmin[2], tbmin[2], tbmax[2]) - tbmin[2]
-		maxx := f32.Clamp(qmax[0], tbmin[0], tbmax[0]) - tbmin[0]
-		maxy := f32.Clamp(qmax[1], tbmin[1], tbmax[1]) - tbmin[1]
-		maxz := f32.Clamp(qmax[2], tbmin[2], tbmax[2]) - tbmin[2]
-		// Quantize
-		bmin[0] = uint16(qfac*minx) & 0xfffe
-		bmin[1] = uint16(qfac*miny) & 0xfffe
-		bmin[2] = uint16(qfac*minz) & 0xfffe
-		bmax[0] = uint16(qfac*maxx+1) | 1
-		bmax[1] = uint16(qfac*maxy+1) | 1
-		bmax[2] = uint16(qfac*maxz+1) | 1
-
-		// Traverse tree
-		base := q.nav.polyRefBase(tile)
-		// TODO: probably need to use an index or unsafe.Pointer here
-		for nodeIdx < endIdx {
-			node = &tile.BvTree[nodeIdx]
-			overlap := OverlapQuantBounds(bmin[:], bmax[:], node.BMin[:], node.BMax[:])
-			isLeafNode := node.I >= 0
-
-			if isLeafNode && overlap {
-				ref := base | PolyRef(node.I)
-				if filter.PassFilter(ref, tile, &tile.Polys[node.I]) {
-					polyRefs[n] = ref
-					polys[n] = &tile.Polys[node.I]
-
-					if n == batchSize-1 {
-						query.process(tile, polys, polyRefs, batchSize)
-						n = 0
-					} else {
-						n++
-					}
-				}
-			}
+	polyRefs := q.scratch.batchRefs[:]
+	polys := q.scratch.batchPolys[:]
+	var (
+		n  int32
+		ts TileQueryStats
+	)
 
-			if overlap || isLeafNode {
-				nodeIdx++
-			} else {
-				escapeIndex := -node.I
-				nodeIdx += escapeIndex
-			}
+	found := q.nav.queryPolygonsInTile(tile, qmin, qmax, q.scratch.tileRefs[:0], &ts)
+	q.scratch.tileRefs = found
+	for _, ref := range found {
+		p := &tile.Polys[q.nav.decodePolyIDPoly(ref)]
+		if !filter.PassFilter(ref, tile, p) {
+			continue
 		}
-	} else {
-		var bmin, bmax d3.Vec3
-		bmin = d3.NewVec3()
-		bmax = d3.NewVec3()
-		base := q.nav.polyRefBase(tile)
-		for i := int32(0); i < tile.Header.PolyCount; i++ {
-			p := &tile.Polys[i]
-			// Do not return off-mesh connection polygons.
-			if p.Type() == polyTypeOffMeshConnection {
-
-				log.Fatalf("do return off-mesh connection polygons")
-				continue
-			}
-			// Must pass filter
-			ref := base | PolyRef(i)
-			if !filter.PassFilter(ref, tile, p) {
-				continue
-			}
-			// Calc polygon bounds.
-			vidx := p.Verts[0] * 3
-			v := tile.Verts[vidx : vidx+3]
-			bmin.Assign(v)
-			bmax.Assign(v)
-			for j := uint8(1); j < p.VertCount; j++ {
-				vidx = p.Verts[j] * 3
-				v = tile.Verts[vidx : vidx+3]
-				d3.Vec3Min(bmin, v)
-				d3.Vec3Max(bmax, v)
-			}
-			if OverlapBounds(qmin, qmax, bmin[:], bmax[:]) {
-				polyRefs[n] = ref
-				polys[n] = p
+		ts.PolysFound++
+		polyRefs[n] = ref
+		polys[n] = p
 
-				if n == batchSize-1 {
-					query.process(tile, polys, polyRefs, batchSize)
-					n = 0
-				} else {
-					n++
-				}
-			}
+		if n == batchSize-1 {
+			query.process(tile, polys, polyRefs, batchSize)
+			n = 0
+		} else {
+			n++
 		}
 	}
 
@@ -1345,6 +1776,11 @@ func (q *NavMeshQuery) queryPolygonsInTile(
 	if n > 0 {
 		query.process(tile, polys, polyRefs, n)
 	}
+
+	if q.stats != nil {
+		ts.Ref = q.nav.TileRef(tile)
+		q.stats.addTile(ts)
+	}
 }
 
 // NodePool returns the node pool.
@@ -1400,8 +1836,10 @@ func (q *NavMeshQuery) IsValidPolyRef(ref PolyRef, filter QueryFilter) bool {
 //
 // This method is meant to be used for quick, short distance checks.
 //
-// If the path array is too small to hold the result, it will be filled as far
-// as possible from the start position toward the end position.
+// The visited polygons are stored in hit.Path, if not nil. If hit.Path is too
+// small to hold the result, it will be filled as far as possible from the
+// start position toward the end position and the BufferTooSmall flag will be
+// set.
 //
 // # Using the Hit Parameter t of RaycastHit
 //
@@ -1440,6 +1878,12 @@ func (q *NavMeshQuery) Raycast(
 	hit *RaycastHit,
 	prevRef PolyRef) (st Status) {
 
+	if hit == nil {
+		st = Failure | InvalidParam
+		return
+	}
+	hit.reset()
+
 	// Validate input
 	if startRef == 0 || !q.nav.IsValidPolyRef(startRef) {
 		st = Failure | InvalidParam
@@ -1449,6 +1893,10 @@ func (q *NavMeshQuery) Raycast(
 		st = Failure | InvalidParam
 		return
 	}
+	if !isValidVec3(startPos) || !isValidVec3(endPos) || filter == nil {
+		st = Failure | InvalidParam
+		return
+	}
 
 	var (
 		dir, curPos, lastPos d3.Vec3
@@ -1456,10 +1904,11 @@ func (q *NavMeshQuery) Raycast(
 		n                    int
 	)
 
-	lastPos = d3.NewVec3()
-	curPos = d3.NewVec3From(startPos)
+	lastPos = q.scratch.lastPos[:]
+	lastPos.SetXYZ(0, 0, 0)
+	curPos = q.scratch.curPos[:]
+	curPos.Assign(startPos)
 	dir = endPos.Sub(startPos)
-	hit.HitNormal = d3.NewVec3()
 
 	st = Success
 
@@ -1509,10 +1958,10 @@ func (q *NavMeshQuery) Raycast(
 		}
 
 		// Store visited polygons.
-		if n < hit.MaxPath {
+		if n < len(hit.Path) {
 			hit.Path[n] = curRef
 			n++
-		} else {
+		} else if hit.Path != nil {
 			st |= BufferTooSmall
 		}
 
@@ -1701,15 +2150,225 @@ func (q *NavMeshQuery) Raycast(
 func (q *NavMeshQuery) Raycast2(startRef PolyRef, startPos, endPos d3.Vec3,
 	filter QueryFilter,
 	hitNormal d3.Vec3, path []PolyRef, maxPath int) (pathCount int, t float32, st Status) {
-	var hit RaycastHit
-	hit.Path = path
-	hit.MaxPath = maxPath
+	if maxPath < 0 {
+		maxPath = 0
+	}
+	if maxPath < len(path) {
+		path = path[:maxPath]
+	}
+	hit := RaycastHit{Path: path}
 
 	status := q.Raycast(startRef, startPos, endPos, filter, 0, &hit, 0)
 	copy(hitNormal, hit.HitNormal)
 	return hit.PathCount, hit.T, status
 }
 
+// MoveAlongSurface moves from the start to the end position constrained to the
+// navigation mesh.
+//
+//	Arguments:
+//	 startRef  The reference id of the start polygon.
+//	 startPos  A position of the mover within the start polygon. [(x, y, z)]
+//	 endPos    The desired end position of the mover. [(x, y, z)]
+//	 filter    The polygon filter to apply to the query.
+//	 resultPos The result position of the mover. [(x, y, z)]
+//	 visited   This slice will be filled with the reference ids of the
+//	           polygons visited during the move.
+//
+//	Return values:
+//	 visitedCount The number of polygons visited during the move.
+//	 st           The status flags for the query.
+//
+// This method is optimized for small delta movement and a small number of
+// polygons. If used for too great a distance, the result set will form an
+// incomplete path.
+//
+// resultPos will equal the endPos if the end is reached. Otherwise the closest
+// reachable position will be returned.
+//
+// resultPos is not projected onto the surface of the navigation mesh. Use
+// PolyHeight if this is needed.
+//
+// This method treats the end position in the same manner as the Raycast
+// method. (As a 2D point.) See that method's documentation for details.
+//
+// If the visited slice is too small to hold the entire result set, it will be
+// filled as far as possible from the start position toward the end position,
+// and the BufferTooSmall flag will be set.
+func (q *NavMeshQuery) MoveAlongSurface(startRef PolyRef, startPos, endPos d3.Vec3,
+	filter QueryFilter, resultPos d3.Vec3, visited []PolyRef) (visitedCount int, st Status) {
+
+	// Validate input
+	if !q.nav.IsValidPolyRef(startRef) || !isValidVec3(startPos) ||
+		!isValidVec3(endPos) || filter == nil || len(resultPos) < 3 || len(visited) == 0 {
+		return 0, Failure | InvalidParam
+	}
+
+	st = Success
+
+	const maxStack = 48
+	var (
+		stack  [maxStack]*Node
+		nstack int
+	)
+
+	q.tinyNodePool.Clear()
+
+	startNode := q.tinyNodePool.Node(startRef, 0)
+	startNode.PIdx = 0
+	startNode.Cost = 0
+	startNode.Total = 0
+	startNode.ID = startRef
+	startNode.Flags = nodeClosed
+	stack[nstack] = startNode
+	nstack++
+
+	bestPos := d3.NewVec3From(startPos)
+	bestDist := float32(math.MaxFloat32)
+	var bestNode *Node
+
+	// Search constraints
+	searchPos := startPos.Lerp(endPos, 0.5)
+	searchRadSqr := startPos.Dist(endPos)/2.0 + 0.001
+	searchRadSqr *= searchRadSqr
+
+	var verts [VertsPerPolygon * 3]float32
+
+	for nstack > 0 {
+		// Pop front.
+		curNode := stack[0]
+		copy(stack[:nstack-1], stack[1:nstack])
+		nstack--
+
+		// Get poly and tile.
+		// The API input has been checked already, skip checking internal data.
+		curRef := curNode.ID
+		var (
+			curTile *MeshTile
+			curPoly *Poly
+		)
+		q.nav.TileAndPolyByRefUnsafe(curRef, &curTile, &curPoly)
+
+		// Collect vertices.
+		nverts := int(curPoly.VertCount)
+		for i := 0; i < nverts; i++ {
+			vi := curPoly.Verts[i] * 3
+			copy(verts[i*3:i*3+3], curTile.Verts[vi:vi+3])
+		}
+
+		// If target is inside the poly, stop search.
+		if pointInPolygon(endPos, verts[:], nverts) {
+			bestNode = curNode
+			bestPos.Assign(endPos)
+			break
+		}
+
+		// Find wall edges and find nearest point inside the walls.
+		for i, j := 0, nverts-1; i < nverts; j, i = i, i+1 {
+			// Find links to neighbours.
+			const maxNeis = 8
+			var (
+				neis  [maxNeis]PolyRef
+				nneis int
+			)
+
+			if curPoly.Neis[j]&extLink != 0 {
+				// Tile border.
+				for k := curPoly.FirstLink; k != nullLink; k = curTile.Links[k].Next {
+					link := &curTile.Links[k]
+					if int(link.Edge) != j || link.Ref == 0 {
+						continue
+					}
+					var (
+						neiTile *MeshTile
+						neiPoly *Poly
+					)
+					q.nav.TileAndPolyByRefUnsafe(link.Ref, &neiTile, &neiPoly)
+					if filter.PassFilter(link.Ref, neiTile, neiPoly) && nneis < maxNeis {
+						neis[nneis] = link.Ref
+						nneis++
+					}
+				}
+			} else if curPoly.Neis[j] != 0 {
+				idx := uint32(curPoly.Neis[j] - 1)
+				ref := q.nav.polyRefBase(curTile) | PolyRef(idx)
+				if filter.PassFilter(ref, curTile, &curTile.Polys[idx]) {
+					// Internal edge, encode id.
+					neis[nneis] = ref
+					nneis++
+				}
+			}
+
+			vj := d3.Vec3(verts[j*3 : j*3+3])
+			vi := d3.Vec3(verts[i*3 : i*3+3])
+			if nneis == 0 {
+				// Wall edge, calc distance.
+				var tseg float32
+				distSqr := distancePtSegSqr2D(endPos, vj, vi, &tseg)
+				if distSqr < bestDist {
+					// Update nearest distance.
+					d3.Vec3Lerp(bestPos, vj, vi, tseg)
+					bestDist = distSqr
+					bestNode = curNode
+				}
+				continue
+			}
+
+			for k := 0; k < nneis; k++ {
+				neighbourNode := q.tinyNodePool.Node(neis[k], 0)
+				if neighbourNode == nil {
+					continue
+				}
+				// Skip if already visited.
+				if neighbourNode.Flags&nodeClosed != 0 {
+					continue
+				}
+
+				// Skip the link if it is too far from search constraint.
+				// TODO: Maybe should use getPortalPoints(), but this one is way faster.
+				var tseg float32
+				distSqr := distancePtSegSqr2D(searchPos, vj, vi, &tseg)
+				if distSqr > searchRadSqr {
+					continue
+				}
+
+				// Mark as the node as visited and push to queue.
+				if nstack < maxStack {
+					neighbourNode.PIdx = q.tinyNodePool.NodeIdx(curNode)
+					neighbourNode.Flags |= nodeClosed
+					stack[nstack] = neighbourNode
+					nstack++
+				}
+			}
+		}
+	}
+
+	if bestNode != nil {
+		// Reverse the path.
+		var prev *Node
+		node := bestNode
+		for node != nil {
+			next := q.tinyNodePool.NodeAtIdx(int32(node.PIdx))
+			node.PIdx = q.tinyNodePool.NodeIdx(prev)
+			prev = node
+			node = next
+		}
+
+		// Store result
+		for node = prev; node != nil; node = q.tinyNodePool.NodeAtIdx(int32(node.PIdx)) {
+			visited[visitedCount] = node.ID
+			visitedCount++
+			if visitedCount >= len(visited) {
+				st |= BufferTooSmall
+				break
+			}
+		}
+	}
+
+	copy(resultPos, bestPos)
+	return visitedCount, st
+}
+
 // InitSlicedFindPath initializes a sliced path query.
 //
 // Common use case:
@@ -1724,7 +2383,7 @@ func (q *NavMeshQuery) Raycast2(startRef PolyRef, startPos, endPos d3.Vec3,
 //	  startPos A position within the start polygon. [(x, y, z)]
 //	  endPos   A position within the end polygon. [(x, y, z)]
 //	  filter   The polygon filter to apply to the query.
-//	  options  query options (see: #dtFindPathOptions)
+//	  options  query options (see: FindPathAnyAngle)
 //
 //	 Returns
 //	  The status flags for the query.
@@ -1734,18 +2393,18 @@ func (q *NavMeshQuery) Raycast2(startRef PolyRef, startPos, endPos d3.Vec3,
 //
 // The filter pointer is stored and used for the duration of the sliced path
 // query.
+//
+// With the FindPathAnyAngle option, the search tries to shortcut across
+// polygons with raycasts, which produces straighter paths on open terrain.
+// Raycasts are limited to RaycastLimitProportions times the walkable radius
+// of the start tile, so this option has no effect on tiles built with a zero
+// walkable radius.
 func (q *NavMeshQuery) InitSlicedFindPath(startRef, endRef PolyRef,
 	startPos, endPos d3.Vec3,
 	filter QueryFilter, options uint32) Status {
 
-	if q.nav == nil {
-		panic("q.nav should not be nil")
-	}
-	if q.nodePool == nil {
-		panic("q.nodePool should not be nil")
-	}
-	if q.openList == nil {
-		panic("q.openList should not be nil")
+	if q.nav == nil || q.nodePool == nil || q.openList == nil {
+		return Failure | InvalidParam
 	}
 
 	// Init path state.
@@ -1764,7 +2423,8 @@ func (q *NavMeshQuery) InitSlicedFindPath(startRef, endRef PolyRef,
 	}
 
 	// Validate input
-	if !q.nav.IsValidPolyRef(startRef) || !q.nav.IsValidPolyRef(endRef) {
+	if !q.nav.IsValidPolyRef(startRef) || !q.nav.IsValidPolyRef(endRef) ||
+		!isValidVec3(startPos) || !isValidVec3(endPos) || filter == nil {
 		return Failure | InvalidParam
 	}
 
@@ -1820,7 +2480,6 @@ func (q *NavMeshQuery) UpdateSlicedFindPath(maxIter int, doneIters *int) Status
 	}
 
 	var rayHit RaycastHit
-	rayHit.MaxPath = 0
 
 	var iter int
 	for iter < maxIter && !q.openList.empty() {
@@ -1939,8 +2598,6 @@ func (q *NavMeshQuery) UpdateSlicedFindPath(maxIter int, doneIters *int) Status
 
 			// raycast parent
 			foundShortCut := false
-			rayHit.PathCost = 0
-			rayHit.T = 0
 			if tryLOS {
 				_ = q.Raycast(parentRef, parentNode.Pos, neighbourNode.Pos, q.query.filter, RaycastUseCosts, &rayHit, grandpaRef)
 				foundShortCut = rayHit.T >= 1.0
@@ -1969,8 +2626,6 @@ func (q *NavMeshQuery) UpdateSlicedFindPath(maxIter int, doneIters *int) Status
 				cost = cost + endCost
 				heuristic = 0
 			} else {
-				//fmt.Println("neighbourNode.Pos", neighbourNode.Pos)
-				//fmt.Println("q.query.endPos", q.query.endPos)
 				heuristic = neighbourNode.Pos.Dist(q.query.endPos) * HScale
 			}
 
@@ -2043,6 +2698,15 @@ func (q *NavMeshQuery) UpdateSlicedFindPath(maxIter int, doneIters *int) Status
 //
 // TODO: should remove maxPath as it should be the length of the path slice
 func (q *NavMeshQuery) FinalizeSlicedFindPath(path []PolyRef, maxPath int) (pathCount int, st Status) {
+	if len(path) == 0 || maxPath <= 0 {
+		// Reset query.
+		q.query = queryData{}
+		return 0, Failure | InvalidParam
+	}
+	if maxPath > len(path) {
+		maxPath = len(path)
+	}
+
 	if StatusFailed(q.query.status) {
 		// Reset query.
 		q.query = queryData{}
@@ -2058,7 +2722,9 @@ func (q *NavMeshQuery) FinalizeSlicedFindPath(path []PolyRef, maxPath int) (path
 	} else {
 		// Reverse the path.
 		if q.query.lastBestNode == nil {
-			panic("q.query.lastBestNode should not be nil")
+			// Reset query.
+			q.query = queryData{}
+			return 0, Failure
 		}
 
 		if q.query.lastBestNode.ID != q.query.endRef {
@@ -2096,7 +2762,7 @@ func (q *NavMeshQuery) FinalizeSlicedFindPath(path []PolyRef, maxPath int) (path
 				m, _, status = q.Raycast2(node.ID, node.Pos, next.Pos, q.query.filter, normal[:], path[n:], maxPath-n)
 				n += m
 				// raycast ends on poly boundary and the path might include the next poly boundary.
-				if path[n-1] == next.ID {
+				if n > 0 && path[n-1] == next.ID {
 					n-- // remove to avoid duplicates
 				}
 			} else {
@@ -2143,8 +2809,16 @@ func (q *NavMeshQuery) FinalizeSlicedFindPath(path []PolyRef, maxPath int) (path
 //	 st           The status flags for the query.
 func (q *NavMeshQuery) FinalizeSlicedFindPathPartial(existing []PolyRef, existingSize int, path []PolyRef, maxPath int) (pathCount int, st Status) {
 
-	if existingSize == 0 {
-		return 0, Failure
+	if existingSize <= 0 || len(path) == 0 || maxPath <= 0 {
+		// Reset query.
+		q.query = queryData{}
+		return 0, Failure | InvalidParam
+	}
+	if existingSize > len(existing) {
+		existingSize = len(existing)
+	}
+	if maxPath > len(path) {
+		maxPath = len(path)
 	}
 
 	if StatusFailed(q.query.status) {
@@ -2176,7 +2850,9 @@ func (q *NavMeshQuery) FinalizeSlicedFindPathPartial(existing []PolyRef, existin
 		if numNodesFound == 0 {
 			q.query.status |= PartialResult
 			if q.query.lastBestNode == nil {
-				panic("q.query.lastBestNode should not be nil")
+				// Reset query.
+				q.query = queryData{}
+				return 0, Failure
 			}
 			node[0] = q.query.lastBestNode
 		}
@@ -2211,7 +2887,7 @@ func (q *NavMeshQuery) FinalizeSlicedFindPathPartial(existing []PolyRef, existin
 				m, _, status = q.Raycast2(node[0].ID, node[0].Pos, next.Pos, q.query.filter, normal[:], path[n:], maxPath-n)
 				n += m
 				// raycast ends on poly boundary and the path might include the next poly boundary.
-				if path[n-1] == next.ID {
+				if n > 0 && path[n-1] == next.ID {
 					n-- // remove to avoid duplicates
 				}
 			} else {
@@ -84,10 +84,24 @@ func NewStandardQueryFilter() *StandardQueryFilter {
 }
 
 // AreaCost returns the traversal cost of the area which id is i.
-func (qf *StandardQueryFilter) AreaCost(i int32) float32 { return qf.areaCost[i] }
+//
+// It returns 0 if i is not a valid area id. [Limit: 0 <= i < 64]
+func (qf *StandardQueryFilter) AreaCost(i int32) float32 {
+	if i < 0 || i >= maxAreas {
+		return 0
+	}
+	return qf.areaCost[i]
+}
 
 // SetAreaCost sets the traversal cost of the area which id is i.
-func (qf *StandardQueryFilter) SetAreaCost(i int32, cost float32) { qf.areaCost[i] = cost }
+//
+// It does nothing if i is not a valid area id. [Limit: 0 <= i < 64]
+func (qf *StandardQueryFilter) SetAreaCost(i int32, cost float32) {
+	if i < 0 || i >= maxAreas {
+		return
+	}
+	qf.areaCost[i] = cost
+}
 
 // IncludeFlags returns the include flags for the filter.
 //
@@ -0,0 +1,121 @@
+package detour
+
+import (
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func TestStandardQueryFilter(t *testing.T) {
+	f := NewStandardQueryFilter()
+
+	var poly Poly
+	poly.Flags = 0x1
+	poly.SetArea(3)
+
+	if !f.PassFilter(0, nil, &poly) {
+		t.Errorf("default filter should pass a polygon with flags")
+	}
+
+	passTests := []struct {
+		msg      string
+		include  uint16
+		exclude  uint16
+		flags    uint16
+		wantPass bool
+	}{
+		{"no flags", 0xffff, 0, 0, false},
+		{"included", 0x3, 0, 0x1, true},
+		{"not included", 0x2, 0, 0x1, false},
+		{"included and excluded", 0x3, 0x1, 0x1, false},
+		{"excluded other flag", 0x3, 0x2, 0x1, true},
+	}
+
+	for _, tt := range passTests {
+		f.SetIncludeFlags(tt.include)
+		f.SetExcludeFlags(tt.exclude)
+		if f.IncludeFlags() != tt.include || f.ExcludeFlags() != tt.exclude {
+			t.Fatalf("%s, flags not set", tt.msg)
+		}
+		poly.Flags = tt.flags
+		if got := f.PassFilter(0, nil, &poly); got != tt.wantPass {
+			t.Errorf("%s, PassFilter = %t, want %t", tt.msg, got, tt.wantPass)
+		}
+	}
+
+	// cost is the distance multiplied by the area cost
+	pa, pb := d3.Vec3{0, 0, 0}, d3.Vec3{3, 0, 4}
+	if c := f.Cost(pa, pb, 0, nil, nil, 0, nil, &poly, 0, nil, nil); c != 5 {
+		t.Errorf("Cost with default area cost = %f, want 5", c)
+	}
+	f.SetAreaCost(3, 2)
+	if f.AreaCost(3) != 2 {
+		t.Errorf("AreaCost(3) = %f, want 2", f.AreaCost(3))
+	}
+	if c := f.Cost(pa, pb, 0, nil, nil, 0, nil, &poly, 0, nil, nil); c != 10 {
+		t.Errorf("Cost with area cost 2 = %f, want 10", c)
+	}
+
+	// invalid area ids are ignored
+	f.SetAreaCost(maxAreas, 2)
+	f.SetAreaCost(-1, 2)
+	if f.AreaCost(maxAreas) != 0 || f.AreaCost(-1) != 0 {
+		t.Errorf("AreaCost of invalid area ids should be 0")
+	}
+}
+
+var benchCost float32
+
+// The following benchmarks measure the overhead of calling the filter methods
+// through the QueryFilter interface, as the queries do, compared to direct
+// (and inlinable) calls on the concrete type.
+
+func BenchmarkStandardQueryFilterDirect(b *testing.B) {
+	f := NewStandardQueryFilter()
+	var poly Poly
+	poly.Flags = 0x1
+	pa, pb := d3.Vec3{0, 0, 0}, d3.Vec3{3, 0, 4}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if f.PassFilter(0, nil, &poly) {
+			benchCost += f.Cost(pa, pb, 0, nil, nil, 0, nil, &poly, 0, nil, nil)
+		}
+	}
+}
+
+func BenchmarkStandardQueryFilterInterface(b *testing.B) {
+	var f QueryFilter = NewStandardQueryFilter()
+	var poly Poly
+	poly.Flags = 0x1
+	pa, pb := d3.Vec3{0, 0, 0}, d3.Vec3{3, 0, 4}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if f.PassFilter(0, nil, &poly) {
+			benchCost += f.Cost(pa, pb, 0, nil, nil, 0, nil, &poly, 0, nil, nil)
+		}
+	}
+}
+
+// BenchmarkFindPathStandardQueryFilter puts the interface overhead in
+// perspective with the cost of a whole path query.
+func BenchmarkFindPathStandardQueryFilter(b *testing.B) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, q := NewNavMeshQuery(mesh, 1000)
+	f := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	_, orgRef, org := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, f)
+	_, dstRef, dst := q.FindNearestPoly(d3.Vec3{42.457218, 7.797607, 17.778244}, extents, f)
+	path := make([]PolyRef, 100)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, st := q.FindPath(orgRef, dstRef, org, dst, f, path); StatusFailed(st) {
+			b.Fatalf("FindPath failed with status 0x%x", st)
+		}
+	}
+}
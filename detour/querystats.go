@@ -0,0 +1,71 @@
+package detour
+
+// SpatialQueryStats holds the counters of a spatial query, that is a query
+// searching the polygons overlapping a box, like FindNearestPoly or
+// PaintBox.
+//
+// The statistics are only collected when enabled with
+// NavMeshQuery.EnableSpatialStats. They are meant to diagnose slow queries and
+// to validate the quality of the BV trees, not to be used in production.
+type SpatialQueryStats struct {
+	Tiles       int // Number of tiles touched by the query box.
+	BvNodes     int // Number of BV tree nodes visited.
+	PolysTested int // Number of polygons tested against the query box.
+	PolysFound  int // Number of polygons overlapping the query box and passing the filter.
+
+	// PerTile holds the counters of each tile touched by the query box, in
+	// the order they have been visited.
+	PerTile []TileQueryStats
+}
+
+// TileQueryStats holds the counters of a spatial query, for a single tile.
+type TileQueryStats struct {
+	Ref         TileRef // The reference of the tile.
+	HasBvTree   bool    // Whether the tile has a BV tree.
+	BvNodes     int     // Number of BV tree nodes visited.
+	PolysTested int     // Number of polygons tested against the query box.
+	PolysFound  int     // Number of polygons overlapping the query box and passing the filter.
+}
+
+// EnableSpatialStats enables, or disables, the collection of the statistics
+// of the spatial queries.
+//
+// When enabled, the counters are reset at the start of each spatial query and
+// can be retrieved after it with SpatialStats. Collecting them has a small
+// cost, the statistics are disabled by default.
+func (q *NavMeshQuery) EnableSpatialStats(enable bool) {
+	if !enable {
+		q.stats = nil
+		return
+	}
+	if q.stats == nil {
+		q.stats = &SpatialQueryStats{}
+	}
+}
+
+// SpatialStats returns the statistics of the last spatial query.
+//
+// It returns the zero value if the statistics are not enabled. The PerTile
+// slice is reused by the next spatial query, it must be copied to be kept.
+//
+// See EnableSpatialStats.
+func (q *NavMeshQuery) SpatialStats() SpatialQueryStats {
+	if q.stats == nil {
+		return SpatialQueryStats{}
+	}
+	return *q.stats
+}
+
+// reset resets the counters, keeping the PerTile storage.
+func (s *SpatialQueryStats) reset() {
+	*s = SpatialQueryStats{PerTile: s.PerTile[:0]}
+}
+
+// addTile adds the counters of a tile to the totals.
+func (s *SpatialQueryStats) addTile(ts TileQueryStats) {
+	s.Tiles++
+	s.BvNodes += ts.BvNodes
+	s.PolysTested += ts.PolysTested
+	s.PolysFound += ts.PolysFound
+	s.PerTile = append(s.PerTile, ts)
+}
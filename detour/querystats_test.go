@@ -0,0 +1,82 @@
+package detour
+
+import (
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func TestSpatialStats(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 100)
+	filter := NewStandardQueryFilter()
+	center := d3.Vec3{37.298489, -1.776901, 11.652311}
+	extents := d3.NewVec3XYZ(2, 4, 2)
+
+	q.FindNearestPoly(center, extents, filter)
+	if stats := q.SpatialStats(); stats.Tiles != 0 || len(stats.PerTile) != 0 {
+		t.Errorf("stats should be empty when disabled, got %+v", stats)
+	}
+
+	q.EnableSpatialStats(true)
+	_, ref, _ := q.FindNearestPoly(center, extents, filter)
+	if ref == 0 {
+		t.Fatal("no polygon found")
+	}
+	stats := q.SpatialStats()
+	hdr := mesh.Tiles[0].Header
+	if stats.Tiles != 1 || len(stats.PerTile) != 1 {
+		t.Fatalf("got %d tiles, %d tile stats, want 1", stats.Tiles, len(stats.PerTile))
+	}
+	ts := stats.PerTile[0]
+	if ts.Ref != mesh.TileRefAt(0, 0, 0) || !ts.HasBvTree {
+		t.Errorf("got tile stats %+v, want tile 0x%x with a BV tree", ts, mesh.TileRefAt(0, 0, 0))
+	}
+	if stats.BvNodes == 0 || stats.BvNodes > int(hdr.BvNodeCount) {
+		t.Errorf("got %d BV nodes visited, want in [1, %d]", stats.BvNodes, hdr.BvNodeCount)
+	}
+	if stats.PolysFound == 0 || stats.PolysFound > stats.PolysTested || stats.PolysTested > stats.BvNodes {
+		t.Errorf("got %d polys found, %d tested, want 0 < found <= tested <= %d",
+			stats.PolysFound, stats.PolysTested, stats.BvNodes)
+	}
+	if ts.BvNodes != stats.BvNodes || ts.PolysTested != stats.PolysTested || ts.PolysFound != stats.PolysFound {
+		t.Errorf("tile stats %+v differ from the totals %+v", ts, stats)
+	}
+
+	// counters are reset at each query
+	q.FindNearestPoly(center, extents, filter)
+	if again := q.SpatialStats(); again.Tiles != 1 || again.BvNodes != stats.BvNodes {
+		t.Errorf("got %+v on the same query, want %+v", again, stats)
+	}
+
+	// the whole navmesh
+	hext := d3.NewVec3XYZ(1000, 1000, 1000)
+	q.queryPolygons6(center, hext, filter, make([]PolyRef, hdr.PolyCount), hdr.PolyCount)
+	var leaves int
+	nodes := mesh.Tiles[0].BvTree[:bvTreeSize(mesh.Tiles[0].BvTree)]
+	for _, node := range nodes {
+		if node.I >= 0 {
+			leaves++
+		}
+	}
+	if all := q.SpatialStats(); all.PolysTested != leaves || all.BvNodes != len(nodes) {
+		t.Errorf("got %d polys tested, %d BV nodes, want %d and %d",
+			all.PolysTested, all.BvNodes, leaves, len(nodes))
+	}
+
+	// tiled navmesh
+	tiled, err := loadTestNavMesh("fixture/tiled.bin")
+	checkt(t, err)
+	_, q = NewNavMeshQuery(tiled, 100)
+	q.EnableSpatialStats(true)
+	q.queryPolygons6(center, hext, filter, make([]PolyRef, 64), 64)
+	if stats = q.SpatialStats(); stats.Tiles != 16 {
+		t.Errorf("got %d tiles touched, want 16", stats.Tiles)
+	}
+
+	q.EnableSpatialStats(false)
+	if stats = q.SpatialStats(); stats.Tiles != 0 {
+		t.Errorf("stats should be empty when disabled, got %+v", stats)
+	}
+}
@@ -0,0 +1,103 @@
+package detour
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func TestRaycastVisitedPath(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 100)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+
+	_, startRef, startPos := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, filter)
+	dstPos := d3.Vec3{42.457218, 7.797607, 17.778244}
+	_, dstRef, _ := q.FindNearestPoly(dstPos, extents, filter)
+
+	// the first corner of the path to dstPos is visible from startPos
+	path := make([]PolyRef, 64)
+	npath, _ := q.FindPath(startRef, dstRef, startPos, dstPos, filter, path)
+	corners := []d3.Vec3{d3.NewVec3(), d3.NewVec3()}
+	q.FindStraightPath(startPos, dstPos, path[:npath], corners, make([]uint8, 2), make([]PolyRef, 2), 0)
+	endPos := d3.NewVec3()
+	d3.Vec3Lerp(endPos, startPos, corners[1], 0.9)
+	_, endRef, endPos := q.FindNearestPoly(endPos, extents, filter)
+
+	// ray reaching its end
+	hit := RaycastHit{Path: make([]PolyRef, 64)}
+	if st := q.Raycast(startRef, startPos, endPos, filter, 0, &hit, 0); StatusFailed(st) {
+		t.Fatalf("Raycast failed with status 0x%x", st)
+	}
+	if hit.T != math.MaxFloat32 {
+		t.Fatalf("ray should reach its end, got t = %f", hit.T)
+	}
+	visited := append([]PolyRef(nil), hit.VisitedPath()...)
+	if len(visited) < 2 || visited[0] != startRef || visited[len(visited)-1] != endRef {
+		t.Fatalf("got visited polygons %x, want a path from 0x%x to 0x%x", visited, startRef, endRef)
+	}
+	for i := 0; i+1 < len(visited); i++ {
+		var fromType, toType uint8
+		if StatusFailed(q.portalPoints6(visited[i], visited[i+1], d3.NewVec3(), d3.NewVec3(), &fromType, &toType)) {
+			t.Errorf("visited polygons 0x%x and 0x%x are not neighbours", visited[i], visited[i+1])
+		}
+	}
+
+	// Raycast2 returns the same path
+	n, _, _ := q.Raycast2(startRef, startPos, endPos, filter, d3.NewVec3(), path, len(path))
+	if !reflect.DeepEqual(path[:n], visited) {
+		t.Errorf("Raycast2 visited %x, want %x", path[:n], visited)
+	}
+
+	// reusing the hit for a ray hitting a wall resets the results
+	if st := q.Raycast(startRef, startPos, d3.Vec3{startPos[0] + 100, startPos[1], startPos[2]}, filter, 0, &hit, 0); StatusFailed(st) {
+		t.Fatalf("Raycast failed with status 0x%x", st)
+	}
+	if hit.T <= 0 || hit.T >= 1 {
+		t.Fatalf("ray should hit a wall, got t = %f", hit.T)
+	}
+	if hit.PathCount == 0 || hit.VisitedPath()[0] != startRef {
+		t.Errorf("got visited polygons %x, want to start with 0x%x", hit.VisitedPath(), startRef)
+	}
+
+	// path too small
+	hit.Path = make([]PolyRef, 1)
+	st := q.Raycast(startRef, startPos, endPos, filter, 0, &hit, 0)
+	if StatusFailed(st) || !StatusDetail(st, BufferTooSmall) {
+		t.Errorf("Raycast with a small path should return BufferTooSmall, got status 0x%x", st)
+	}
+	if !reflect.DeepEqual(hit.VisitedPath(), visited[:1]) || hit.T != math.MaxFloat32 {
+		t.Errorf("got visited polygons %x and t = %f, want %x and t = MaxFloat32", hit.VisitedPath(), hit.T, visited[:1])
+	}
+
+	// no path
+	hit.Path = nil
+	st = q.Raycast(startRef, startPos, endPos, filter, 0, &hit, 0)
+	if StatusFailed(st) || StatusDetail(st, BufferTooSmall) || hit.PathCount != 0 {
+		t.Errorf("Raycast without path got status 0x%x and %d polygons", st, hit.PathCount)
+	}
+}
+
+func BenchmarkRaycast(b *testing.B) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+	_, q := NewNavMeshQuery(mesh, 100)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+
+	_, startRef, startPos := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, filter)
+	endPos := d3.Vec3{42.457218, 7.797607, 17.778244}
+	hit := RaycastHit{Path: make([]PolyRef, 64)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.Raycast(startRef, startPos, endPos, filter, 0, &hit, 0)
+	}
+}
@@ -0,0 +1,80 @@
+package detour
+
+import (
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// RefGeneration returns the generation of the tile of a polygon reference, or
+// 0 if ref is not valid.
+//
+// Each time a tile is added to the navigation mesh, it's given a new
+// generation. Contrary to the salt encoded in the references, the generation
+// changes when a tile is added back with the reference it had before (See:
+// AddTile), even though the references to its polygons are still valid. As
+// the tile data may have changed, for example after a rebuild, a stored
+// polygon reference is only guaranteed to designate the same polygon if the
+// generation of its tile didn't change.
+func (m *NavMesh) RefGeneration(ref PolyRef) uint64 {
+	if !m.IsValidPolyRef(ref) {
+		return 0
+	}
+	return m.Tiles[m.decodePolyIDTile(ref)].generation
+}
+
+// RefToken is a polygon reference stored by an external system (AI
+// blackboard, saved orders...), along with what's needed to detect that it's
+// stale, and to resolve it again.
+//
+// See NavMesh.NewRefToken, NavMesh.IsTokenValid and NavMeshQuery.ResolveToken.
+type RefToken struct {
+	Ref PolyRef    // The polygon reference.
+	Pos [3]float32 // A position on the polygon, used to resolve a stale reference.
+
+	gen uint64 // Generation of the tile of Ref.
+}
+
+// NewRefToken returns the token of a polygon reference, and of a position on
+// that polygon.
+func (m *NavMesh) NewRefToken(ref PolyRef, pos d3.Vec3) RefToken {
+	tok := RefToken{Ref: ref, gen: m.RefGeneration(ref)}
+	copy(tok.Pos[:], pos)
+	return tok
+}
+
+// IsTokenValid reports whether the reference of tok is still valid and
+// designates the same polygon as when the token was created.
+//
+// This is cheap, a false return means the reference must be resolved again,
+// with NavMeshQuery.ResolveToken.
+func (m *NavMesh) IsTokenValid(tok RefToken) bool {
+	return tok.gen != 0 && m.RefGeneration(tok.Ref) == tok.gen
+}
+
+// ResolveToken resolves again the reference of tok if it's stale, by
+// searching the polygon nearest to its position.
+//
+//	Arguments:
+//	 tok      The token to resolve.
+//	 extents  The search distance along each axis. [(x, y, z)]
+//	 filter   The polygon filter to apply to the query.
+//
+// If the reference is still valid, tok is not modified. Otherwise, tok is
+// updated with the nearest polygon and the nearest point on it. If no polygon
+// is found, the reference of tok is set to 0 and Failure is returned.
+func (q *NavMeshQuery) ResolveToken(tok *RefToken, extents d3.Vec3, filter QueryFilter) Status {
+	if q.nav.IsTokenValid(*tok) {
+		return Success
+	}
+
+	st, ref, pt := q.FindNearestPoly(tok.Pos[:], extents, filter)
+	if StatusFailed(st) {
+		tok.Ref, tok.gen = 0, 0
+		return st
+	}
+	if ref == 0 {
+		tok.Ref, tok.gen = 0, 0
+		return Failure
+	}
+	*tok = q.nav.NewRefToken(ref, pt)
+	return Success
+}
@@ -0,0 +1,75 @@
+package detour
+
+import (
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func TestRefToken(t *testing.T) {
+	mesh, err := loadTestNavMesh("fixture/tiled.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 100)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(1, 4, 1)
+
+	// a position at the center of tile (1, 1)
+	hdr := mesh.TileAt(1, 1, 0).Header
+	center := d3.NewVec3XYZ((hdr.BMin[0]+hdr.BMax[0])/2, hdr.BMax[1], (hdr.BMin[2]+hdr.BMax[2])/2)
+	st, ref, pos := q.FindNearestPoly(center, extents, filter)
+	if StatusFailed(st) || ref == 0 {
+		t.Fatalf("no polygon found at %v", center)
+	}
+
+	tok := mesh.NewRefToken(ref, pos)
+	if !mesh.IsTokenValid(tok) {
+		t.Fatal("new token should be valid")
+	}
+	if mesh.RefGeneration(ref) == 0 {
+		t.Fatal("valid ref should have a generation")
+	}
+	if mesh.IsTokenValid(mesh.NewRefToken(0, pos)) {
+		t.Error("token of a null ref should not be valid")
+	}
+
+	// re-add the tile with the same reference: the ref is valid, not the token
+	tref := mesh.TileRefAt(1, 1, 0)
+	data, st := mesh.RemoveTile(tref)
+	if StatusFailed(st) {
+		t.Fatalf("RemoveTile failed with status 0x%x", st)
+	}
+	if st, _ = mesh.AddTile(data, tref); StatusFailed(st) {
+		t.Fatalf("AddTile failed with status 0x%x", st)
+	}
+	if !mesh.IsValidPolyRef(ref) {
+		t.Fatal("ref should still be valid")
+	}
+	if mesh.IsTokenValid(tok) {
+		t.Error("token should be stale after the tile has been added again")
+	}
+
+	// resolving finds the polygon at the same position
+	if st = q.ResolveToken(&tok, extents, filter); StatusFailed(st) {
+		t.Fatalf("ResolveToken failed with status 0x%x", st)
+	}
+	if !mesh.IsTokenValid(tok) || tok.Ref != ref {
+		t.Errorf("resolved token ref is 0x%x, want valid 0x%x", tok.Ref, ref)
+	}
+
+	// resolving a valid token doesn't modify it
+	before := tok
+	if st = q.ResolveToken(&tok, extents, filter); StatusFailed(st) || tok != before {
+		t.Errorf("ResolveToken modified a valid token, %+v, want %+v", tok, before)
+	}
+
+	// the polygon is gone, no polygon near its position
+	if _, st = mesh.RemoveTile(mesh.TileRefAt(1, 1, 0)); StatusFailed(st) {
+		t.Fatalf("RemoveTile failed with status 0x%x", st)
+	}
+	if mesh.RefGeneration(ref) != 0 {
+		t.Error("removed polygon should have no generation")
+	}
+	if st = q.ResolveToken(&tok, extents, filter); !StatusFailed(st) || tok.Ref != 0 {
+		t.Errorf("ResolveToken = 0x%x, ref 0x%x, want failure and null ref", st, tok.Ref)
+	}
+}
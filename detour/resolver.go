@@ -0,0 +1,145 @@
+package detour
+
+import (
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// PositionResolver finds the polygons of the positions of tracked entities,
+// typically each frame.
+//
+// The last polygon of each entity is cached. As entities move little from one
+// frame to the other, their new position is usually still in the cached
+// polygon, or in one of its neighbours, which is much cheaper to check than
+// searching the nearest polygon. The cached polygon is not used anymore if its
+// tile has been removed or added again since it was cached. (See:
+// NavMesh.RefGeneration)
+//
+// Entities are identified by an integer chosen by the caller.
+//
+// Note: a PositionResolver uses its NavMeshQuery, it may not be used by
+// multiple clients at the same time.
+type PositionResolver struct {
+	q       *NavMeshQuery
+	filter  QueryFilter
+	extents d3.Vec3
+	height  float32
+	cache   map[int]cachedPoly
+	stats   ResolverStats
+}
+
+// cachedPoly is the last polygon of an entity.
+type cachedPoly struct {
+	ref PolyRef
+	gen uint64 // Generation of the tile of ref.
+}
+
+// ResolverStats holds the number of positions resolved by a PositionResolver,
+// for each way they have been resolved.
+type ResolverStats struct {
+	Cached     int // Positions inside the cached polygon.
+	Neighbours int // Positions inside a neighbour of the cached polygon.
+	Nearest    int // Positions resolved by searching the nearest polygon.
+	Failed     int // Positions with no polygon nearby.
+}
+
+// NewPositionResolver returns a PositionResolver.
+//
+//	Arguments:
+//	 q        The query used to resolve the positions.
+//	 filter   The polygon filter to apply.
+//	 extents  The search distance along each axis, when searching the nearest
+//	          polygon. [(x, y, z)]
+//	 height   The maximum vertical distance between a position and the surface
+//	          of the cached polygon, or of its neighbours, for the position to
+//	          be considered inside it. (See: NavMeshQuery.PointInPoly)
+func NewPositionResolver(q *NavMeshQuery, filter QueryFilter, extents d3.Vec3, height float32) *PositionResolver {
+	return &PositionResolver{
+		q:       q,
+		filter:  filter,
+		extents: d3.NewVec3From(extents),
+		height:  height,
+		cache:   make(map[int]cachedPoly),
+	}
+}
+
+// Resolve returns the polygon of the position of an entity.
+//
+//	Arguments:
+//	 id       The entity identifier.
+//	 pos      The position of the entity. [(x, y, z)]
+//
+//	Return values:
+//	 ref      The reference id of the polygon.
+//	 st       The status flags for the query.
+//
+// The cached polygon of the entity is checked first, then its neighbours,
+// then the nearest polygon is searched. In the latter case, pos may be
+// outside of the returned polygon. If no polygon is found, Failure is returned
+// and the entity is forgotten.
+func (r *PositionResolver) Resolve(id int, pos d3.Vec3) (ref PolyRef, st Status) {
+	nav := r.q.nav
+	if c, ok := r.cache[id]; ok && c.gen != 0 && nav.RefGeneration(c.ref) == c.gen {
+		if r.contains(c.ref, pos) {
+			r.stats.Cached++
+			return c.ref, Success
+		}
+
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		nav.TileAndPolyByRefUnsafe(c.ref, &tile, &poly)
+		for i := poly.FirstLink; i != nullLink; i = tile.Links[i].Next {
+			if nref := tile.Links[i].Ref; r.contains(nref, pos) {
+				r.cache[id] = cachedPoly{nref, nav.RefGeneration(nref)}
+				r.stats.Neighbours++
+				return nref, Success
+			}
+		}
+	}
+
+	st, ref, _ = r.q.FindNearestPoly(pos, r.extents, r.filter)
+	if StatusFailed(st) {
+		return 0, st
+	}
+	if ref == 0 {
+		delete(r.cache, id)
+		r.stats.Failed++
+		return 0, Failure
+	}
+	r.cache[id] = cachedPoly{ref, nav.RefGeneration(ref)}
+	r.stats.Nearest++
+	return ref, Success
+}
+
+// contains reports whether pos is inside the polygon ref, which must pass
+// the filter.
+func (r *PositionResolver) contains(ref PolyRef, pos d3.Vec3) bool {
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if StatusFailed(r.q.nav.TileAndPolyByRef(ref, &tile, &poly)) ||
+		!r.filter.PassFilter(ref, tile, poly) {
+		return false
+	}
+	inside, _ := r.q.PointInPoly(ref, pos, r.height)
+	return inside
+}
+
+// Forget removes an entity from the cache.
+func (r *PositionResolver) Forget(id int) {
+	delete(r.cache, id)
+}
+
+// Reset removes all entities from the cache and resets the statistics.
+func (r *PositionResolver) Reset() {
+	r.cache = make(map[int]cachedPoly)
+	r.stats = ResolverStats{}
+}
+
+// Stats returns the number of positions resolved, for each way they have
+// been resolved.
+func (r *PositionResolver) Stats() ResolverStats {
+	return r.stats
+}
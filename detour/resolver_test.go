@@ -0,0 +1,70 @@
+package detour
+
+import (
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func TestPositionResolver(t *testing.T) {
+	mesh, err := loadTestNavMesh("fixture/tiled.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 100)
+	r := NewPositionResolver(q, NewStandardQueryFilter(), d3.NewVec3XYZ(1, 2, 1), 1)
+
+	// tileCenter returns the center of the tile at (x, y), and the reference
+	// of its single polygon.
+	tileCenter := func(x, y int32) (d3.Vec3, PolyRef) {
+		tile := mesh.TileAt(x, y, 0)
+		hdr := tile.Header
+		pos := d3.NewVec3XYZ((hdr.BMin[0]+hdr.BMax[0])/2, 0, (hdr.BMin[2]+hdr.BMax[2])/2)
+		if h, st := q.PolyHeight(mesh.polyRefBase(tile), pos); StatusSucceed(st) {
+			pos[1] = h
+		}
+		return pos, mesh.polyRefBase(tile)
+	}
+
+	pos11, ref11 := tileCenter(1, 1)
+	pos21, ref21 := tileCenter(2, 1)
+	steps := []struct {
+		msg     string
+		id      int
+		pos     d3.Vec3
+		want    PolyRef
+		wantErr bool
+		stats   ResolverStats
+	}{
+		{"first resolve", 1, pos11, ref11, false, ResolverStats{Nearest: 1}},
+		{"same position", 1, pos11, ref11, false, ResolverStats{Nearest: 1, Cached: 1}},
+		{"neighbour", 1, pos21, ref21, false, ResolverStats{Nearest: 1, Cached: 1, Neighbours: 1}},
+		{"other entity", 2, pos21, ref21, false, ResolverStats{Nearest: 2, Cached: 1, Neighbours: 1}},
+		{"too far", 2, d3.NewVec3XYZ(pos21[0], pos21[1]+10, pos21[2]), 0, true, ResolverStats{Nearest: 2, Cached: 1, Neighbours: 1, Failed: 1}},
+		{"above the cached polygon", 1, d3.NewVec3XYZ(pos21[0], pos21[1]+1.5, pos21[2]), ref21, false, ResolverStats{Nearest: 3, Cached: 1, Neighbours: 1, Failed: 1}},
+	}
+	for _, s := range steps {
+		ref, st := r.Resolve(s.id, s.pos)
+		if StatusFailed(st) != s.wantErr || ref != s.want {
+			t.Fatalf("%s: got 0x%x, status 0x%x, want 0x%x", s.msg, ref, st, s.want)
+		}
+		if r.Stats() != s.stats {
+			t.Fatalf("%s: got stats %+v, want %+v", s.msg, r.Stats(), s.stats)
+		}
+	}
+
+	// the cached polygon is stale once its tile has been added again
+	r.Reset()
+	r.Resolve(1, pos21)
+	tref := mesh.TileRefAt(2, 1, 0)
+	data, _ := mesh.RemoveTile(tref)
+	if st, _ := mesh.AddTile(data, tref); StatusFailed(st) {
+		t.Fatalf("AddTile failed with status 0x%x", st)
+	}
+	if ref, _ := r.Resolve(1, pos21); ref != ref21 || r.Stats().Nearest != 2 {
+		t.Errorf("got 0x%x, stats %+v, want 0x%x resolved by nearest polygon search", ref, r.Stats(), ref21)
+	}
+
+	r.Forget(1)
+	if r.Resolve(1, pos21); r.Stats().Nearest != 3 {
+		t.Errorf("forgotten entity should be resolved by nearest polygon search, got stats %+v", r.Stats())
+	}
+}
@@ -0,0 +1,344 @@
+package detour
+
+import (
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+// Default values of SmoothPathOptions.
+const (
+	// DefaultSmoothPathStepSize is the default distance an agent moves at
+	// each iteration of SmoothPath.
+	DefaultSmoothPathStepSize float32 = 0.5
+
+	// DefaultSmoothPathSlop is the default distance under which a steering
+	// target is considered reached by SmoothPath.
+	DefaultSmoothPathSlop float32 = 0.01
+)
+
+// SmoothPathOptions holds the parameters of NavMeshQuery.SmoothPath.
+//
+// A zero value field means the default value is used.
+type SmoothPathOptions struct {
+	// StepSize is the distance moved at each iteration. Shorter steps lead to
+	// smoother paths, made of more points. [Limit: >= 0]
+	StepSize float32
+
+	// Slop is the distance under which a steering target is considered
+	// reached. [Limit: >= 0]
+	Slop float32
+}
+
+// SmoothPath converts a polygon corridor into a smoothed path, by iteratively
+// steering toward the next corner of the straight path and moving along the
+// surface of the navigation mesh.
+//
+//	Arguments:
+//	 startPos    Path start position. [(x, y, z)]
+//	 endPos      Path end position. [(x, y, z)]
+//	 path        An array of polygon references that represent the path
+//	             corridor, as returned by FindPath. (Start to end.)
+//	 filter      The polygon filter to apply to the query.
+//	 opts        The smoothing options, nil to use the default ones.
+//	 smoothPath  This slice will be filled with the points of the smoothed
+//	             path.
+//
+//	Return values:
+//	 smoothPathCount The number of points in the smoothed path.
+//	 st              The status flags for the query.
+//
+// This mimics the movement of an agent following the corridor, and is mostly
+// useful for visualization or to produce paths with regularly spaced points.
+// Consecutive points are at most StepSize apart, except around the off-mesh
+// connections, where the path jumps from one end of the connection to the
+// other one. The points are projected onto the detail surface of the
+// navigation mesh.
+//
+// The smoothPath slice elements must already be allocated. path is not
+// modified, the corridor is copied once. Apart from this copy, the method only
+// allocates a few small buffers, independently of the path length.
+//
+// If smoothPath is too small to hold the full result, it will be filled as far
+// as possible from the start position toward the end position and the
+// BufferTooSmall flag will be set. If the end position couldn't be reached,
+// the PartialResult flag will be set.
+//
+// Note: this method uses the tiny node pool, it may not be used by multiple
+// clients at the same time.
+func (q *NavMeshQuery) SmoothPath(startPos, endPos d3.Vec3, path []PolyRef,
+	filter QueryFilter, opts *SmoothPathOptions, smoothPath []d3.Vec3) (smoothPathCount int, st Status) {
+
+	if len(path) == 0 || !isValidVec3(startPos) || !isValidVec3(endPos) ||
+		filter == nil || len(smoothPath) == 0 {
+		return 0, Failure | InvalidParam
+	}
+	for i := range smoothPath {
+		if len(smoothPath[i]) < 3 {
+			return 0, Failure | InvalidParam
+		}
+	}
+
+	stepSize, slop := DefaultSmoothPathStepSize, DefaultSmoothPathSlop
+	if opts != nil {
+		if opts.StepSize < 0 || opts.Slop < 0 {
+			return 0, Failure | InvalidParam
+		}
+		if opts.StepSize > 0 {
+			stepSize = opts.StepSize
+		}
+		if opts.Slop > 0 {
+			slop = opts.Slop
+		}
+	}
+
+	const maxVisited = 16
+	var (
+		visited  [maxVisited]PolyRef
+		steer    smoothPathSteer
+		iterPos  = d3.NewVec3()
+		target   = d3.NewVec3()
+		result   = d3.NewVec3()
+		moveTgt  = d3.NewVec3()
+		conStart = d3.NewVec3()
+		conEnd   = d3.NewVec3()
+	)
+	steer.init()
+
+	// The corridor is shortened as we move along it, but it may also grow
+	// when the moves visit polygons that are not in it.
+	polys := make([]PolyRef, len(path)+maxVisited)
+	npolys := copy(polys, path)
+
+	if st = q.ClosestPointOnPoly(polys[0], startPos, iterPos, nil); StatusFailed(st) {
+		return 0, st
+	}
+	if st = q.ClosestPointOnPoly(polys[npolys-1], endPos, target, nil); StatusFailed(st) {
+		return 0, st
+	}
+
+	smoothPath[0].Assign(iterPos)
+	smoothPathCount = 1
+
+	// Move towards target a small advancement at a time until target reached
+	// or when ran out of memory to store the path.
+	reached := false
+	for npolys > 0 && smoothPathCount < len(smoothPath) {
+		// Find location to steer towards.
+		if !q.steerTarget(iterPos, target, slop, polys[:npolys], &steer) {
+			// No corner further than slop, we may already be at the target.
+			reached = inRange(iterPos, target, slop, 1)
+			break
+		}
+		steerPos := steer.pos
+		endOfPath := steer.flag&StraightPathEnd != 0
+		offMeshConnection := steer.flag&StraightPathOffMeshConnection != 0
+
+		// Find movement delta.
+		delta := steerPos.Sub(iterPos)
+		l := delta.Len()
+		// If the steer target is end of path or off-mesh link, do not move
+		// past the location.
+		if (endOfPath || offMeshConnection) && l < stepSize {
+			l = 1
+		} else {
+			l = stepSize / l
+		}
+		d3.Vec3Mad(moveTgt, iterPos, delta, l)
+
+		// Move
+		nvisited, mst := q.MoveAlongSurface(polys[0], iterPos, moveTgt, filter, result, visited[:])
+		if StatusFailed(mst) {
+			return smoothPathCount, mst
+		}
+		npolys = MergeCorridorStartMoved(polys, npolys, visited[:nvisited])
+		if shortcut := q.uTurnShortcut(polys, npolys); shortcut[0] != 0 {
+			npolys = MergeCorridorStartShortcut(polys, npolys, shortcut[:])
+		}
+
+		if h, hst := q.PolyHeight(polys[0], result); StatusSucceed(hst) {
+			result[1] = h
+		}
+		iterPos.Assign(result)
+
+		// Handle end of path and off-mesh links when close enough.
+		if endOfPath && inRange(iterPos, steerPos, slop, 1) {
+			// Reached end of path.
+			iterPos.Assign(target)
+			smoothPath[smoothPathCount].Assign(iterPos)
+			smoothPathCount++
+			reached = true
+			break
+		} else if offMeshConnection && inRange(iterPos, steerPos, slop, 1) {
+			// Reached off-mesh connection.
+
+			// Advance the path up to and over the off-mesh connection.
+			var prevRef PolyRef
+			polyRef := polys[0]
+			npos := 0
+			for npos < npolys && polyRef != steer.ref {
+				prevRef = polyRef
+				polyRef = polys[npos]
+				npos++
+			}
+			copy(polys, polys[npos:npolys])
+			npolys -= npos
+
+			// Handle the connection.
+			if StatusSucceed(q.nav.OffMeshConnectionPolyEndPoints(prevRef, polyRef, conStart, conEnd)) {
+				smoothPath[smoothPathCount].Assign(conStart)
+				smoothPathCount++
+				if smoothPathCount >= len(smoothPath) {
+					break
+				}
+
+				// Move position at the other side of the off-mesh link.
+				iterPos.Assign(conEnd)
+				if npolys > 0 {
+					if h, hst := q.PolyHeight(polys[0], iterPos); StatusSucceed(hst) {
+						iterPos[1] = h
+					}
+				}
+			}
+		}
+
+		// Store results.
+		smoothPath[smoothPathCount].Assign(iterPos)
+		smoothPathCount++
+	}
+
+	st = Success
+	if !reached {
+		if smoothPathCount >= len(smoothPath) {
+			st |= BufferTooSmall
+		} else {
+			st |= PartialResult
+		}
+	}
+	return smoothPathCount, st
+}
+
+// smoothPathSteer holds the buffers used to find steering targets, and the
+// steering target found.
+type smoothPathSteer struct {
+	path  [3]d3.Vec3
+	flags [3]uint8
+	refs  [3]PolyRef
+
+	pos  d3.Vec3 // Steering target position.
+	flag uint8   // Straight path flags of the steering target.
+	ref  PolyRef // Reference of the polygon entered at the steering target.
+}
+
+func (s *smoothPathSteer) init() {
+	for i := range s.path {
+		s.path[i] = d3.NewVec3()
+	}
+	s.pos = d3.NewVec3()
+}
+
+// steerTarget finds the first corner of the straight path from startPos to
+// endPos, through the corridor, further than minTargetDist from startPos.
+//
+// If an off-mesh connection is found before, it is the steering target. It
+// returns false if there's no such corner.
+func (q *NavMeshQuery) steerTarget(startPos, endPos d3.Vec3, minTargetDist float32,
+	path []PolyRef, steer *smoothPathSteer) bool {
+
+	// Find steer target.
+	nsteerPath, st := q.FindStraightPath(startPos, endPos, path,
+		steer.path[:], steer.flags[:], steer.refs[:], 0)
+	if StatusFailed(st) || nsteerPath == 0 {
+		return false
+	}
+
+	// Find vertex far enough to steer to.
+	ns := 0
+	for ns < nsteerPath {
+		// Stop at Off-Mesh link or when point is further than slop away.
+		if steer.flags[ns]&StraightPathOffMeshConnection != 0 ||
+			!inRange(steer.path[ns], startPos, minTargetDist, 1000) {
+			break
+		}
+		ns++
+	}
+	// Failed to find good point to steer to.
+	if ns >= nsteerPath {
+		return false
+	}
+
+	steer.pos.Assign(steer.path[ns])
+	steer.pos[1] = startPos[1]
+	steer.flag = steer.flags[ns]
+	steer.ref = steer.refs[ns]
+	return true
+}
+
+// inRange returns true if v1 and v2 are closer than r in the xz-plane and
+// closer than h along the y-axis.
+func inRange(v1, v2 d3.Vec3, r, h float32) bool {
+	dx := v2[0] - v1[0]
+	dy := v2[1] - v1[1]
+	dz := v2[2] - v1[2]
+	return (dx*dx+dz*dz) < r*r && math32.Abs(dy) < h
+}
+
+// uTurnShortcut returns the shortcut of a small U-turn at the start of the
+// corridor, as the first polygon of the corridor then the polygon it is
+// adjacent to, or zero refs if there's no U-turn.
+//
+// A U-turn happens when a polygon further in the corridor is adjacent to the
+// first polygon. This can happen if the target (T) location is at tile
+// boundary, and we're (S) approaching it parallel to the tile edge. The choice
+// at the vertex can be arbitrary:
+//
+//	+---+---+
+//	|:::|:::|
+//	+-S-+-T-+
+//	|:::|   | <-- the step can end up in here, resulting U-turn path.
+//	+---+---+
+func (q *NavMeshQuery) uTurnShortcut(path []PolyRef, npath int) (shortcut [2]PolyRef) {
+	if npath < 3 {
+		return shortcut
+	}
+
+	// Get connected polygons
+	const maxNeis = 16
+	var (
+		neis  [maxNeis]PolyRef
+		nneis int
+		tile  *MeshTile
+		poly  *Poly
+	)
+	if StatusFailed(q.nav.TileAndPolyByRef(path[0], &tile, &poly)) {
+		return shortcut
+	}
+
+	for k := poly.FirstLink; k != nullLink; k = tile.Links[k].Next {
+		link := &tile.Links[k]
+		if link.Ref != 0 && nneis < maxNeis {
+			neis[nneis] = link.Ref
+			nneis++
+		}
+	}
+
+	// If any of the neighbour polygons is within the next few polygons in the
+	// path, short cut to that polygon directly.
+	const maxLookAhead = 6
+	cut := 0
+	i := maxLookAhead
+	if npath < i {
+		i = npath
+	}
+	for i--; i > 1 && cut == 0; i-- {
+		for j := 0; j < nneis; j++ {
+			if path[i] == neis[j] {
+				cut = i
+				break
+			}
+		}
+	}
+	if cut > 1 {
+		shortcut[0], shortcut[1] = path[0], path[cut]
+	}
+	return shortcut
+}
@@ -0,0 +1,211 @@
+package detour
+
+import (
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+func TestMoveAlongSurface(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+
+	_, q := NewNavMeshQuery(mesh, 1000)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	_, orgRef, org := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, filter)
+
+	// a short move stays on the mesh, the first visited polygon is the start one
+	var visited [16]PolyRef
+	result := d3.NewVec3()
+	dst := d3.NewVec3XYZ(org[0]-1, org[1], org[2]-1)
+	n, st := q.MoveAlongSurface(orgRef, org, dst, filter, result, visited[:])
+	if StatusFailed(st) {
+		t.Fatalf("MoveAlongSurface failed with status 0x%x", st)
+	}
+	if n == 0 || visited[0] != orgRef {
+		t.Fatalf("visited %x should start with 0x%x", visited[:n], orgRef)
+	}
+	for _, v := range []d3.Vec3{org, result} {
+		if _, ref, _ := q.FindNearestPoly(v, extents, filter); ref == 0 {
+			t.Errorf("%v should be on the navmesh", v)
+		}
+	}
+
+	// a move toward the void is stopped by the walls
+	far := d3.NewVec3XYZ(org[0]+1000, org[1], org[2])
+	_, st = q.MoveAlongSurface(orgRef, org, far, filter, result, visited[:])
+	if StatusFailed(st) {
+		t.Fatalf("MoveAlongSurface failed with status 0x%x", st)
+	}
+	if result.Approx(far) {
+		t.Errorf("move toward %v should have been blocked", far)
+	}
+
+	if _, st = q.MoveAlongSurface(0, org, dst, filter, result, visited[:]); st != Failure|InvalidParam {
+		t.Errorf("want status 0x%x with invalid start ref, got 0x%x", Failure|InvalidParam, st)
+	}
+	if _, st = q.MoveAlongSurface(orgRef, org, dst, filter, result, nil); st != Failure|InvalidParam {
+		t.Errorf("want status 0x%x with no visited slice, got 0x%x", Failure|InvalidParam, st)
+	}
+}
+
+func TestSmoothPath(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+
+	_, q := NewNavMeshQuery(mesh, 1000)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	_, orgRef, org := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, filter)
+	_, dstRef, dst := q.FindNearestPoly(d3.Vec3{42.457218, 7.797607, 17.778244}, extents, filter)
+
+	path := make([]PolyRef, 100)
+	npath, st := q.FindPath(orgRef, dstRef, org, dst, filter, path)
+	if StatusFailed(st) {
+		t.Fatalf("FindPath failed with status 0x%x", st)
+	}
+	path = path[:npath]
+	corridor := append([]PolyRef(nil), path...)
+
+	newPoints := func(n int) []d3.Vec3 {
+		pts := make([]d3.Vec3, n)
+		for i := range pts {
+			pts[i] = d3.NewVec3()
+		}
+		return pts
+	}
+
+	for _, opts := range []*SmoothPathOptions{nil, {StepSize: 0.25}, {StepSize: 2, Slop: 0.1}} {
+		stepSize := DefaultSmoothPathStepSize
+		if opts != nil {
+			stepSize = opts.StepSize
+		}
+
+		pts := newPoints(2048)
+		n, st := q.SmoothPath(org, dst, path, filter, opts, pts)
+		if st != Success {
+			t.Fatalf("step %f, SmoothPath returned status 0x%x", stepSize, st)
+		}
+		if n < 3 {
+			t.Fatalf("step %f, got only %d points", stepSize, n)
+		}
+		if !pts[0].Approx(org) || !pts[n-1].Approx(dst) {
+			t.Errorf("step %f, smooth path should go from %v to %v, got %v to %v",
+				stepSize, org, dst, pts[0], pts[n-1])
+		}
+		for i := 1; i < n; i++ {
+			a, b := pts[i-1], pts[i]
+			if d := math32.Sqrt((b[0]-a[0])*(b[0]-a[0]) + (b[2]-a[2])*(b[2]-a[2])); d > stepSize+1e-3 {
+				t.Errorf("step %f, points %d and %d are %f apart", stepSize, i-1, i, d)
+			}
+			if _, ref, _ := q.FindNearestPoly(b, extents, filter); ref == 0 {
+				t.Errorf("step %f, point %d %v is not on the navmesh", stepSize, i, b)
+			}
+		}
+	}
+
+	// the corridor is left untouched
+	for i := range path {
+		if path[i] != corridor[i] {
+			t.Fatalf("SmoothPath modified the corridor, got %x, want %x", path, corridor)
+		}
+	}
+
+	// undersized result slice
+	pts := newPoints(4)
+	n, st := q.SmoothPath(org, dst, path, filter, nil, pts)
+	if n != len(pts) || st != Success|BufferTooSmall {
+		t.Errorf("want %d points and status 0x%x, got %d points and 0x%x", len(pts), Success|BufferTooSmall, n, st)
+	}
+
+	// invalid parameters
+	if _, st = q.SmoothPath(org, dst, nil, filter, nil, pts); st != Failure|InvalidParam {
+		t.Errorf("want status 0x%x with empty corridor, got 0x%x", Failure|InvalidParam, st)
+	}
+	if _, st = q.SmoothPath(org, dst, path, filter, &SmoothPathOptions{StepSize: -1}, pts); st != Failure|InvalidParam {
+		t.Errorf("want status 0x%x with negative step, got 0x%x", Failure|InvalidParam, st)
+	}
+}
+
+func BenchmarkSmoothPath(b *testing.B) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	_, q := NewNavMeshQuery(mesh, 1000)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	_, orgRef, org := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, filter)
+	_, dstRef, dst := q.FindNearestPoly(d3.Vec3{42.457218, 7.797607, 17.778244}, extents, filter)
+
+	path := make([]PolyRef, 100)
+	npath, _ := q.FindPath(orgRef, dstRef, org, dst, filter, path)
+	pts := make([]d3.Vec3, 2048)
+	for i := range pts {
+		pts[i] = d3.NewVec3()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.SmoothPath(org, dst, path[:npath], filter, nil, pts)
+	}
+}
+
+func TestSplinePath(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+
+	_, q := NewNavMeshQuery(mesh, 1000)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	_, orgRef, org := q.FindNearestPoly(d3.Vec3{37.298489, -1.776901, 11.652311}, extents, filter)
+	_, dstRef, dst := q.FindNearestPoly(d3.Vec3{42.457218, 7.797607, 17.778244}, extents, filter)
+
+	path := make([]PolyRef, 100)
+	npath, _ := q.FindPath(orgRef, dstRef, org, dst, filter, path)
+	corners := make([]d3.Vec3, 100)
+	for i := range corners {
+		corners[i] = d3.NewVec3()
+	}
+	flags := make([]uint8, 100)
+	refs := make([]PolyRef, 100)
+	ncorners, st := q.FindStraightPath(org, dst, path[:npath], corners, flags, refs, 0)
+	if StatusFailed(st) || ncorners < 3 {
+		t.Fatalf("FindStraightPath returned %d corners, status 0x%x", ncorners, st)
+	}
+
+	const samples = 4
+	spline := make([]d3.Vec3, 1+(ncorners-1)*samples)
+	for i := range spline {
+		spline[i] = d3.NewVec3()
+	}
+	n, st := q.SplinePath(corners[:ncorners], flags, refs, samples, filter, spline)
+	if StatusFailed(st) || n != len(spline) {
+		t.Fatalf("SplinePath returned %d points, want %d, status 0x%x", n, len(spline), st)
+	}
+
+	for i, pt := range spline[:n] {
+		// the curve goes through the corners
+		if i%samples == 0 && !pt.Approx(corners[i/samples]) {
+			t.Errorf("point %d is %v, want corner %v", i, pt, corners[i/samples])
+		}
+		// and is constrained to the navmesh
+		if _, ref, _ := q.FindNearestPoly(pt, d3.NewVec3XYZ(0.1, 1, 0.1), filter); ref == 0 {
+			t.Errorf("point %d %v should be on the navmesh", i, pt)
+		}
+	}
+
+	// too small buffer
+	n, st = q.SplinePath(corners[:ncorners], flags, refs, samples, filter, spline[:5])
+	if n != 5 || !StatusDetail(st, BufferTooSmall) {
+		t.Errorf("got %d points, status 0x%x, want 5 points and BufferTooSmall", n, st)
+	}
+
+	if _, st = q.SplinePath(corners[:ncorners], flags, refs, 0, filter, spline); st != Failure|InvalidParam {
+		t.Errorf("want status 0x%x with no samples, got 0x%x", Failure|InvalidParam, st)
+	}
+}
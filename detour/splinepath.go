@@ -0,0 +1,129 @@
+package detour
+
+import (
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// SplinePath converts a straight path into a smooth curve, for visualization.
+//
+//	Arguments:
+//	 straightPath      The points of the straight path, as returned by
+//	                   FindStraightPath.
+//	 straightPathFlags The flags of the straight path points.
+//	 straightPathRefs  The references of the polygons entered at the straight
+//	                   path points.
+//	 segSamples        The number of points sampled on the curve between two
+//	                   consecutive straight path points. [Limit: > 0]
+//	 filter            The polygon filter to apply to the query.
+//	 splinePath        This slice will be filled with the points of the curve.
+//
+//	Return values:
+//	 splinePathCount   The number of points in the curve.
+//	 st                The status flags for the query.
+//
+// The curve is an uniform Catmull-Rom spline going through all the straight
+// path points, the corners. The sampled points are constrained to the
+// navigation mesh by moving along its surface from the previous point, then
+// projected onto the detail surface, so the curve never cuts through walls,
+// even though it may not exactly follow the spline near the corners. The
+// curve is straight along the off-mesh connections.
+//
+// This is only meant for the visualization of the planned movement of an
+// agent (E.g. the corners of its path corridor), the agent itself should still
+// steer toward the corners.
+//
+// The splinePath slice elements must already be allocated. If splinePath is
+// too small to hold the full result, it will be filled as far as possible from
+// the start of the path and the BufferTooSmall flag will be set.
+//
+// Note: this method uses the tiny node pool, it may not be used by multiple
+// clients at the same time.
+func (q *NavMeshQuery) SplinePath(straightPath []d3.Vec3, straightPathFlags []uint8,
+	straightPathRefs []PolyRef, segSamples int, filter QueryFilter, splinePath []d3.Vec3) (splinePathCount int, st Status) {
+
+	n := len(straightPath)
+	if n == 0 || len(straightPathFlags) < n || len(straightPathRefs) < n ||
+		segSamples <= 0 || filter == nil || len(splinePath) == 0 {
+		return 0, Failure | InvalidParam
+	}
+
+	// offMesh reports whether the segment starting at corner i is an off-mesh
+	// connection.
+	offMesh := func(i int) bool {
+		return straightPathFlags[i]&StraightPathOffMeshConnection != 0
+	}
+
+	var (
+		visited [16]PolyRef
+		target  = d3.NewVec3()
+		result  = d3.NewVec3()
+		cur     = d3.NewVec3From(straightPath[0])
+		curRef  = straightPathRefs[0]
+	)
+	splinePath[0].Assign(cur)
+	splinePathCount = 1
+
+	for i := 0; i < n-1; i++ {
+		p1, p2 := straightPath[i], straightPath[i+1]
+		if offMesh(i) {
+			// Jump to the other side of the off-mesh connection.
+			if splinePathCount >= len(splinePath) {
+				return splinePathCount, Success | BufferTooSmall
+			}
+			cur.Assign(p2)
+			curRef = straightPathRefs[i+1]
+			splinePath[splinePathCount].Assign(cur)
+			splinePathCount++
+			continue
+		}
+
+		// The tangents at the corners neighbouring an off-mesh connection, or
+		// at the path ends, are computed as if the corner was repeated.
+		p0, p3 := p1, p2
+		if i > 0 && !offMesh(i-1) {
+			p0 = straightPath[i-1]
+		}
+		if i+2 < n && !offMesh(i+1) {
+			p3 = straightPath[i+2]
+		}
+
+		for s := 1; s <= segSamples; s++ {
+			if splinePathCount >= len(splinePath) {
+				return splinePathCount, Success | BufferTooSmall
+			}
+
+			u := float32(s) / float32(segSamples)
+			catmullRom(target, p0, p1, p2, p3, u)
+			if s == segSamples {
+				target.Assign(p2)
+			}
+			nvisited, mst := q.MoveAlongSurface(curRef, cur, target, filter, result, visited[:])
+			if StatusFailed(mst) {
+				return splinePathCount, mst
+			}
+			if nvisited > 0 {
+				curRef = visited[nvisited-1]
+			}
+			if h, hst := q.PolyHeight(curRef, result); StatusSucceed(hst) {
+				result[1] = h
+			}
+			cur.Assign(result)
+			splinePath[splinePathCount].Assign(cur)
+			splinePathCount++
+		}
+	}
+	return splinePathCount, Success
+}
+
+// catmullRom sets dst to the point at u, in [0, 1], of the uniform
+// Catmull-Rom spline segment going from p1 to p2.
+func catmullRom(dst, p0, p1, p2, p3 d3.Vec3, u float32) {
+	u2 := u * u
+	u3 := u2 * u
+	for k := 0; k < 3; k++ {
+		dst[k] = 0.5 * (2*p1[k] +
+			(p2[k]-p0[k])*u +
+			(2*p0[k]-5*p1[k]+4*p2[k]-p3[k])*u2 +
+			(3*p1[k]-p0[k]-3*p2[k]+p3[k])*u3)
+	}
+}
@@ -1,6 +1,9 @@
 package detour
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Status represents status flags.
 type Status uint32
@@ -8,20 +11,78 @@ type Status uint32
 // High level status.
 const (
 	Failure    Status = 1 << 31 // Operation failed.
-	Success           = 1 << 30 // Operation succeed.
-	InProgress        = 1 << 29 // Operation still in progress.
+	Success    Status = 1 << 30 // Operation succeed.
+	InProgress Status = 1 << 29 // Operation still in progress.
 
 	// Detail information for status.
-	StatusDetailMask = 0x0ffffff
-	WrongMagic       = 1 << 0 // Input data is not recognized.
-	WrongVersion     = 1 << 1 // Input data is in wrong version.
-	OutOfMemory      = 1 << 2 // Operation ran out of memory.
-	InvalidParam     = 1 << 3 // An input parameter was invalid.
-	BufferTooSmall   = 1 << 4 // Result buffer for the query was too small to store all results.
-	OutOfNodes       = 1 << 5 // Query ran out of nodes during search.
-	PartialResult    = 1 << 6 // Query did not reach the end location, returning best guess.
+	StatusDetailMask Status = 0x0ffffff
+	WrongMagic       Status = 1 << 0 // Input data is not recognized.
+	WrongVersion     Status = 1 << 1 // Input data is in wrong version.
+	OutOfMemory      Status = 1 << 2 // Operation ran out of memory.
+	InvalidParam     Status = 1 << 3 // An input parameter was invalid.
+	BufferTooSmall   Status = 1 << 4 // Result buffer for the query was too small to store all results.
+	OutOfNodes       Status = 1 << 5 // Query ran out of nodes during search.
+	PartialResult    Status = 1 << 6 // Query did not reach the end location, returning best guess.
 )
 
+// statusNames maps each status flag to its name, in the order in which they
+// appear in the string representation of a status.
+var statusNames = [...]struct {
+	flag Status
+	name string
+}{
+	{Failure, "FAILURE"},
+	{Success, "SUCCESS"},
+	{InProgress, "IN_PROGRESS"},
+	{WrongMagic, "WRONG_MAGIC"},
+	{WrongVersion, "WRONG_VERSION"},
+	{OutOfMemory, "OUT_OF_MEMORY"},
+	{InvalidParam, "INVALID_PARAM"},
+	{BufferTooSmall, "BUFFER_TOO_SMALL"},
+	{OutOfNodes, "OUT_OF_NODES"},
+	{PartialResult, "PARTIAL_RESULT"},
+}
+
+// String returns the names of the flags set in s, separated by '|', for
+// example "FAILURE|INVALID_PARAM". Unknown bits are appended in hexadecimal.
+func (s Status) String() string {
+	if s == 0 {
+		return "0"
+	}
+	var (
+		sb   strings.Builder
+		left = s
+	)
+	for _, n := range statusNames {
+		if s&n.flag == 0 {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('|')
+		}
+		sb.WriteString(n.name)
+		left &^= n.flag
+	}
+	if left != 0 {
+		if sb.Len() > 0 {
+			sb.WriteByte('|')
+		}
+		fmt.Fprintf(&sb, "0x%x", uint32(left))
+	}
+	return sb.String()
+}
+
+// Is reports whether all the flags set in target, which must be a Status, are
+// also set in s. It makes errors.Is usable with a status:
+//
+//	if errors.Is(err, detour.InvalidParam) {
+//	    // ...
+//	}
+func (s Status) Is(target error) bool {
+	flag, ok := target.(Status)
+	return ok && flag != 0 && s&flag == flag
+}
+
 // Implementation of the error interface
 func (s Status) Error() string {
 	if s&Failure != 0 {
@@ -39,7 +100,7 @@ func (s Status) Error() string {
 		case PartialResult:
 			return "partial result"
 		default:
-			return fmt.Sprintf("unspecified error 0x%x", uint32(s))
+			return fmt.Sprintf("unspecified error (%v)", s.String())
 		}
 	}
 	if s == InProgress {
@@ -64,6 +125,6 @@ func StatusInProgress(status Status) bool {
 }
 
 // StatusDetail returns true if specific detail is set.
-func StatusDetail(status Status, detail uint32) bool {
-	return (uint32(status) & detail) != 0
+func StatusDetail(status Status, detail Status) bool {
+	return (status & detail) != 0
 }
@@ -0,0 +1,49 @@
+package detour
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStatusString(t *testing.T) {
+	tests := []struct {
+		st   Status
+		want string
+	}{
+		{0, "0"},
+		{Success, "SUCCESS"},
+		{Failure | InvalidParam, "FAILURE|INVALID_PARAM"},
+		{Success | PartialResult | BufferTooSmall, "SUCCESS|BUFFER_TOO_SMALL|PARTIAL_RESULT"},
+		{InProgress | 1<<20, "IN_PROGRESS|0x100000"},
+	}
+	for _, tt := range tests {
+		if got := tt.st.String(); got != tt.want {
+			t.Errorf("Status(0x%x).String() = %q, want %q", uint32(tt.st), got, tt.want)
+		}
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	var err error = Failure | InvalidParam
+	if got := err.Error(); got != "invalid parameter" {
+		t.Errorf("Error() = %q, want %q", got, "invalid parameter")
+	}
+	err = Failure | InvalidParam | OutOfNodes
+	if got, want := err.Error(), "unspecified error (FAILURE|INVALID_PARAM|OUT_OF_NODES)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	wrapped := fmt.Errorf("query: %w", Failure|InvalidParam)
+	if !errors.Is(wrapped, InvalidParam) || !errors.Is(wrapped, Failure|InvalidParam) {
+		t.Errorf("errors.Is should find the InvalidParam flag in %v", wrapped)
+	}
+	if errors.Is(wrapped, OutOfNodes) || errors.Is(wrapped, Success) {
+		t.Errorf("errors.Is should not find unset flags in %v", wrapped)
+	}
+
+	st := Success | PartialResult
+	if !st.Is(PartialResult) || st.Is(Failure) || st.Is(Status(0)) {
+		t.Errorf("unexpected Is results for %v", st)
+	}
+}
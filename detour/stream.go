@@ -0,0 +1,307 @@
+package detour
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Encode writes the navigation mesh to w, in the format read by Decode and
+// DecodeIndex.
+//
+// Tiles are serialized and written one at a time, so the memory used while
+// encoding doesn't depend on the navigation mesh size.
+func (m *NavMesh) Encode(w io.Writer) error {
+	// Store header.
+	var header navMeshSetHeader
+	header.Magic = navMeshSetMagic
+	header.Version = NavMeshSetVersion
+	header.NumTiles = 0
+	for i := int32(0); i < m.MaxTiles; i++ {
+		if m.Tiles[i].DataSize == 0 {
+			continue
+		}
+		header.NumTiles++
+	}
+	header.Params = m.Params
+
+	if _, err := header.WriteTo(w); err != nil {
+		return fmt.Errorf("Error writing header: %v", err)
+	}
+
+	// Store tiles.
+	for i := int32(0); i < m.MaxTiles; i++ {
+		tile := &m.Tiles[i]
+		if tile.DataSize == 0 {
+			continue
+		}
+
+		var tileHeader navMeshTileHeader
+		tileHeader.TileRef = m.TileRef(tile)
+		tileHeader.DataSize = tile.DataSize
+		if _, err := tileHeader.WriteTo(w); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(tileData(tile)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeAll writes several navigation meshes to w, one after the other, in
+// the format read by DecodeAll.
+//
+// This allows to bundle the navigation meshes built for different agent
+// profiles in a single file.
+func EncodeAll(w io.Writer, meshes ...*NavMesh) error {
+	for i, m := range meshes {
+		if err := m.Encode(w); err != nil {
+			return fmt.Errorf("navmesh %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// SaveAllToFile saves several navigation meshes in a single binary file.
+//
+// see EncodeAll
+func SaveAllToFile(fn string, meshes ...*NavMesh) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	if err = EncodeAll(f, meshes...); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// DecodeAll reads all the navigation meshes from r, as written by EncodeAll
+// or by successive calls to Encode, and returns them in order.
+//
+// Zero bytes following the last navigation mesh are considered as padding
+// and ignored, any other data is an error. A stream holding a single
+// navigation mesh is decoded as well.
+//
+// returned error will be different from nil in case of failure.
+func DecodeAll(r io.Reader) ([]*NavMesh, error) {
+	br := bufio.NewReader(r)
+	var meshes []*NavMesh
+	for {
+		magic, err := br.Peek(4)
+		if err == io.EOF && len(magic) == 0 {
+			break
+		}
+		if len(magic) == 4 && binary.LittleEndian.Uint32(magic) == navMeshSetMagic {
+			mesh, err := Decode(br)
+			if err != nil {
+				return nil, fmt.Errorf("navmesh %d: %v", len(meshes), err)
+			}
+			meshes = append(meshes, mesh)
+			continue
+		}
+
+		// Only padding may follow.
+		if err = skipPadding(br); err != nil {
+			return nil, fmt.Errorf("after navmesh %d: %v", len(meshes), err)
+		}
+		break
+	}
+	if len(meshes) == 0 {
+		return nil, fmt.Errorf("no navmesh found")
+	}
+	return meshes, nil
+}
+
+// skipPadding reads r until EOF, and returns an error if a byte is not 0.
+func skipPadding(r io.ByteReader) error {
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if b != 0 {
+			return fmt.Errorf("unexpected data 0x%x", b)
+		}
+	}
+}
+
+// NavMeshIndex is the index of the tiles of an encoded navigation mesh, built
+// by DecodeIndex.
+//
+// It allows to load and unload the tiles on demand, reading their data from
+// the underlying io.ReaderAt only when they are loaded. This is the way to
+// stream huge tiled navigation meshes around the area of interest (E.g. the
+// players), instead of keeping all the tiles in memory.
+//
+// A NavMeshIndex doesn't modify its io.ReaderAt, so it may be shared by
+// multiple goroutines, as long as the navigation meshes they load tiles into
+// are not.
+type NavMeshIndex struct {
+	// Params are the parameters of the encoded navigation mesh.
+	Params NavMeshParams
+
+	// Tiles are the entries of the encoded tiles, in file order.
+	Tiles []TileIndexEntry
+
+	r io.ReaderAt
+}
+
+// TileIndexEntry describes an encoded tile.
+type TileIndexEntry struct {
+	Ref      TileRef    // The reference of the tile when it was encoded.
+	X, Y     int32      // The location of the tile in the tile grid.
+	Layer    int32      // The layer of the tile in the tile grid.
+	BMin     [3]float32 // The minimum bounds of the tile's AABB. [(x, y, z)]
+	BMax     [3]float32 // The maximum bounds of the tile's AABB. [(x, y, z)]
+	Offset   int64      // The offset of the tile data.
+	DataSize int32      // The size of the tile data.
+}
+
+// DecodeIndex reads the header and the tile headers of a tiled navigation mesh
+// from r, and returns the index of its tiles.
+//
+// The tile data is not read, tiles are loaded on demand with LoadTileAt.
+//
+// returned error will be different from nil in case of failure.
+func DecodeIndex(r io.ReaderAt) (*NavMeshIndex, error) {
+	var hdr navMeshSetHeader
+	off := int64(hdr.size())
+	if err := binary.Read(io.NewSectionReader(r, 0, off), binary.LittleEndian, &hdr); err != nil {
+		return nil, err
+	}
+
+	if hdr.Magic != navMeshSetMagic {
+		return nil, fmt.Errorf("wrong magic number: %x", hdr.Magic)
+	}
+
+	if err := checkSetVersion(hdr.Version); err != nil {
+		return nil, err
+	}
+
+	idx := &NavMeshIndex{Params: hdr.Params, r: r}
+
+	var (
+		tileHdr navMeshTileHeader
+		meshHdr MeshHeader
+		buf     = make([]byte, tileHdr.Size()+meshHdr.size())
+		little  = binary.LittleEndian
+	)
+	for i := uint32(0); i < hdr.NumTiles; i++ {
+		if _, err := r.ReadAt(buf[:tileHdr.Size()], off); err != nil {
+			return nil, err
+		}
+		tileHdr.TileRef = TileRef(little.Uint32(buf))
+		tileHdr.DataSize = int32(little.Uint32(buf[4:]))
+		if tileHdr.TileRef == 0 || tileHdr.DataSize <= 0 {
+			break
+		}
+		off += int64(tileHdr.Size())
+
+		if int(tileHdr.DataSize) < meshHdr.size() {
+			return nil, fmt.Errorf("tile %d: data size too small: %d", i, tileHdr.DataSize)
+		}
+		if _, err := r.ReadAt(buf[:meshHdr.size()], off); err != nil {
+			return nil, err
+		}
+		meshHdr.unserialize(buf)
+		if meshHdr.Magic != navMeshMagic {
+			return nil, fmt.Errorf("tile %d: wrong magic number: %x", i, meshHdr.Magic)
+		}
+		if meshHdr.Version != navMeshVersion {
+			return nil, fmt.Errorf("tile %d: wrong version: %d", i, meshHdr.Version)
+		}
+
+		idx.Tiles = append(idx.Tiles, TileIndexEntry{
+			Ref:      tileHdr.TileRef,
+			X:        meshHdr.X,
+			Y:        meshHdr.Y,
+			Layer:    meshHdr.Layer,
+			BMin:     meshHdr.BMin,
+			BMax:     meshHdr.BMax,
+			Offset:   off,
+			DataSize: tileHdr.DataSize,
+		})
+		off += int64(tileHdr.DataSize)
+	}
+	return idx, nil
+}
+
+// NewNavMesh returns a new navigation mesh, initialized with the parameters of
+// the encoded navigation mesh, in which no tiles are loaded.
+func (idx *NavMeshIndex) NewNavMesh() (*NavMesh, error) {
+	var mesh NavMesh
+	params := idx.Params
+	if status := mesh.Init(&params); StatusFailed(status) {
+		return nil, fmt.Errorf("status failed 0x%x", status)
+	}
+	return &mesh, nil
+}
+
+// Entry returns the index entry of the tile at the given location of the tile
+// grid, or nil if the encoded navigation mesh has no such tile.
+func (idx *NavMeshIndex) Entry(x, y, layer int32) *TileIndexEntry {
+	for i := range idx.Tiles {
+		e := &idx.Tiles[i]
+		if e.X == x && e.Y == y && e.Layer == layer {
+			return e
+		}
+	}
+	return nil
+}
+
+// LoadTileAt reads the tile at the given location of the tile grid and adds it
+// to m.
+//
+//	Arguments:
+//	 m       The navigation mesh to add the tile to, usually created with
+//	         NewNavMesh.
+//	 x       The tile's x-location. (x, y, layer)
+//	 y       The tile's y-location. (x, y, layer)
+//	 layer   The tile's layer. (x, y, layer)
+//
+// The tile is added with the reference it had when it was encoded, so the
+// polygon references remain valid across unloads and reloads. If the tile is
+// already loaded, its reference is returned and nothing is read.
+//
+// returned error will be different from nil in case of failure.
+func (idx *NavMeshIndex) LoadTileAt(m *NavMesh, x, y, layer int32) (TileRef, error) {
+	if ref := m.TileRefAt(x, y, layer); ref != 0 {
+		return ref, nil
+	}
+	e := idx.Entry(x, y, layer)
+	if e == nil {
+		return 0, fmt.Errorf("no tile at (%d, %d, %d)", x, y, layer)
+	}
+
+	data := make([]byte, e.DataSize)
+	if _, err := idx.r.ReadAt(data, e.Offset); err != nil {
+		return 0, err
+	}
+	status, ref := m.AddTile(data, e.Ref)
+	if StatusFailed(status) {
+		return 0, fmt.Errorf("couldn't add tile (%d, %d, %d), status: 0x%x", x, y, layer, status)
+	}
+	return ref, nil
+}
+
+// UnloadTileAt removes the tile at the given location of the tile grid from
+// m, if it is loaded.
+//
+// It returns false if there is no such tile in m.
+func (idx *NavMeshIndex) UnloadTileAt(m *NavMesh, x, y, layer int32) bool {
+	ref := m.TileRefAt(x, y, layer)
+	if ref == 0 {
+		return false
+	}
+	_, st := m.RemoveTile(ref)
+	return StatusSucceed(st)
+}
@@ -0,0 +1,294 @@
+package detour
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecodeIndex(t *testing.T) {
+	for _, fname := range []string{"mesh1.bin", "mesh2.bin", "offmeshcons.bin"} {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+
+		var buf bytes.Buffer
+		if err = mesh.Encode(&buf); err != nil {
+			t.Fatalf("%s, Encode failed: %v", fname, err)
+		}
+
+		// Decode reads what Encode writes
+		decoded, err := Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s, Decode failed: %v", fname, err)
+		}
+		if want, got := allPolyRefs(mesh), allPolyRefs(decoded); !reflect.DeepEqual(want, got) {
+			t.Errorf("%s, decoded mesh has polys %x, want %x", fname, got, want)
+		}
+
+		idx, err := DecodeIndex(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s, DecodeIndex failed: %v", fname, err)
+		}
+		if idx.Params != mesh.Params {
+			t.Errorf("%s, got params %+v, want %+v", fname, idx.Params, mesh.Params)
+		}
+
+		lazy, err := idx.NewNavMesh()
+		checkt(t, err)
+		for _, e := range idx.Tiles {
+			if lazy.TileRefAt(e.X, e.Y, e.Layer) != 0 {
+				t.Fatalf("%s, tile (%d, %d, %d) should not be loaded yet", fname, e.X, e.Y, e.Layer)
+			}
+			ref, err := idx.LoadTileAt(lazy, e.X, e.Y, e.Layer)
+			if err != nil {
+				t.Fatalf("%s, LoadTileAt(%d, %d, %d) failed: %v", fname, e.X, e.Y, e.Layer, err)
+			}
+			if ref != e.Ref {
+				t.Errorf("%s, LoadTileAt(%d, %d, %d) = 0x%x, want 0x%x", fname, e.X, e.Y, e.Layer, ref, e.Ref)
+			}
+
+			// loading a tile twice is a no-op
+			if ref, err = idx.LoadTileAt(lazy, e.X, e.Y, e.Layer); err != nil || ref != e.Ref {
+				t.Errorf("%s, reloading tile (%d, %d, %d) = 0x%x, %v", fname, e.X, e.Y, e.Layer, ref, err)
+			}
+		}
+
+		// once all tiles are loaded, the meshes have the same polygons
+		if want, got := allPolyRefs(mesh), allPolyRefs(lazy); !reflect.DeepEqual(want, got) {
+			t.Errorf("%s, lazily loaded mesh has polys %x, want %x", fname, got, want)
+		}
+
+		// unload then reload a tile, polygon references are preserved
+		e := idx.Tiles[0]
+		if !idx.UnloadTileAt(lazy, e.X, e.Y, e.Layer) {
+			t.Fatalf("%s, UnloadTileAt(%d, %d, %d) failed", fname, e.X, e.Y, e.Layer)
+		}
+		if idx.UnloadTileAt(lazy, e.X, e.Y, e.Layer) {
+			t.Errorf("%s, tile (%d, %d, %d) unloaded twice", fname, e.X, e.Y, e.Layer)
+		}
+		if _, err = idx.LoadTileAt(lazy, e.X, e.Y, e.Layer); err != nil {
+			t.Fatalf("%s, LoadTileAt(%d, %d, %d) failed: %v", fname, e.X, e.Y, e.Layer, err)
+		}
+		if want, got := allPolyRefs(mesh), allPolyRefs(lazy); !reflect.DeepEqual(want, got) {
+			t.Errorf("%s, reloaded mesh has polys %x, want %x", fname, got, want)
+		}
+
+		if _, err = idx.LoadTileAt(lazy, -1000, -1000, 0); err == nil {
+			t.Errorf("%s, LoadTileAt should fail on missing tile", fname)
+		}
+	}
+}
+
+func TestDecodeIndexCorruptedData(t *testing.T) {
+	mesh, err := loadTestNavMesh("mesh2.bin")
+	checkt(t, err)
+
+	var buf bytes.Buffer
+	checkt(t, mesh.Encode(&buf))
+	data := buf.Bytes()
+
+	// truncated data
+	for _, n := range []int{0, 10, 50} {
+		if _, err := DecodeIndex(bytes.NewReader(data[:n])); err == nil {
+			t.Errorf("DecodeIndex of %d bytes should fail", n)
+		}
+	}
+
+	// wrong magic
+	corrupted := append([]byte(nil), data...)
+	corrupted[0] ^= 0xff
+	if _, err := DecodeIndex(bytes.NewReader(corrupted)); err == nil {
+		t.Errorf("DecodeIndex with a wrong magic number should fail")
+	}
+}
+
+func TestEncodeRecastDemoLayout(t *testing.T) {
+	// single tile meshes saved by RecastDemo are re-encoded byte for byte
+	for _, fname := range []string{"mesh1.bin", "mesh2.bin"} {
+		want, err := ioutil.ReadFile(filepath.Join("..", "testdata", fname))
+		checkt(t, err)
+
+		mesh, err := Decode(bytes.NewReader(want))
+		checkt(t, err)
+		var buf bytes.Buffer
+		checkt(t, mesh.Encode(&buf))
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s, encoded navmesh differs from the RecastDemo one", fname)
+		}
+	}
+
+	// tiles are stored after the 40 bytes set header, each one prefixed by its
+	// 8 bytes tile header, and starts with the 100 bytes mesh header.
+	mesh, err := loadTestNavMesh("offmeshcons.bin")
+	checkt(t, err)
+	var buf bytes.Buffer
+	checkt(t, mesh.Encode(&buf))
+	data := buf.Bytes()
+
+	little := binary.LittleEndian
+	require := func(cond bool, format string, args ...interface{}) {
+		t.Helper()
+		if !cond {
+			t.Fatalf(format, args...)
+		}
+	}
+	require(little.Uint32(data) == navMeshSetMagic, "wrong set magic")
+	require(little.Uint32(data[4:]) == NavMeshSetVersion, "wrong set version")
+	ntiles := int(little.Uint32(data[8:]))
+	off := 40
+	for i := 0; i < ntiles; i++ {
+		size := int(little.Uint32(data[off+4:]))
+		off += 8
+		require(int32(little.Uint32(data[off:])) == navMeshMagic, "tile %d, wrong magic", i)
+		require(int32(little.Uint32(data[off+4:])) == navMeshVersion, "tile %d, wrong version", i)
+		off += size
+	}
+	require(off == len(data), "tiles end at %d, want %d", off, len(data))
+
+	// off-mesh connections survive an encode/decode round trip
+	decoded, err := Decode(bytes.NewReader(data))
+	checkt(t, err)
+	var ncons int
+	for i := int32(0); i < mesh.MaxTiles; i++ {
+		want, got := mesh.Tiles[i].OffMeshCons, decoded.Tiles[i].OffMeshCons
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("tile %d, got off-mesh connections %+v, want %+v", i, got, want)
+		}
+		ncons += len(want)
+	}
+	require(ncons > 0, "offmeshcons.bin should have off-mesh connections")
+}
+
+func TestEncodeDecodeAll(t *testing.T) {
+	var meshes []*NavMesh
+	for _, fname := range []string{"mesh1.bin", "offmeshcons.bin", "fixture/tiled.bin"} {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+		meshes = append(meshes, mesh)
+	}
+
+	var buf bytes.Buffer
+	checkt(t, EncodeAll(&buf, meshes...))
+	stream := buf.Bytes()
+
+	for _, tt := range []struct {
+		msg  string
+		data []byte
+	}{
+		{"bundle", stream},
+		{"bundle with padding", append(append([]byte(nil), stream...), make([]byte, 13)...)},
+	} {
+		decoded, err := DecodeAll(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatalf("%s: DecodeAll failed: %v", tt.msg, err)
+		}
+		if len(decoded) != len(meshes) {
+			t.Fatalf("%s: got %d navmeshes, want %d", tt.msg, len(decoded), len(meshes))
+		}
+		for i := range meshes {
+			if want, got := allPolyRefs(meshes[i]), allPolyRefs(decoded[i]); !reflect.DeepEqual(want, got) {
+				t.Errorf("%s: navmesh %d has polys %x, want %x", tt.msg, i, got, want)
+			}
+		}
+	}
+
+	// Decode reads the first navmesh only
+	r := bytes.NewReader(stream)
+	first, err := Decode(r)
+	checkt(t, err)
+	if want, got := allPolyRefs(meshes[0]), allPolyRefs(first); !reflect.DeepEqual(want, got) {
+		t.Errorf("Decode: got polys %x, want %x", got, want)
+	}
+	rest, err := DecodeAll(r)
+	checkt(t, err)
+	if len(rest) != len(meshes)-1 {
+		t.Errorf("got %d navmeshes after the first one, want %d", len(rest), len(meshes)-1)
+	}
+
+	// errors
+	for _, tt := range []struct {
+		msg  string
+		data []byte
+	}{
+		{"empty stream", nil},
+		{"trailing garbage", append(append([]byte(nil), stream...), 0, 0, 'x')},
+		{"truncated navmesh", stream[:len(stream)-10]},
+	} {
+		if _, err := DecodeAll(bytes.NewReader(tt.data)); err == nil {
+			t.Errorf("%s: DecodeAll should fail", tt.msg)
+		}
+	}
+}
+
+// TestFormatCompatibility checks that the navigation mesh sets of all the
+// readable versions are read, and that the format of the current version
+// didn't change. (See: NavMeshSetVersion)
+func TestFormatCompatibility(t *testing.T) {
+	compat := filepath.Join("..", "testdata", "compat")
+	for v := MinNavMeshSetVersion; v <= NavMeshSetVersion; v++ {
+		fname := filepath.Join(compat, fmt.Sprintf("navmeshset-v%d.bin", v))
+		data, err := ioutil.ReadFile(fname)
+		if err != nil {
+			t.Fatalf("version %d: missing compatibility file: %v", v, err)
+		}
+		mesh, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("version %d: Decode failed: %v", v, err)
+		}
+		if _, err = DecodeIndex(bytes.NewReader(data)); err != nil {
+			t.Errorf("version %d: DecodeIndex failed: %v", v, err)
+		}
+		dirMesh, err := DecodeDir(filepath.Join(compat, fmt.Sprintf("navmeshdir-v%d", v)))
+		if err != nil {
+			t.Fatalf("version %d: DecodeDir failed: %v", v, err)
+		}
+		if want, got := allPolyRefs(mesh), allPolyRefs(dirMesh); !reflect.DeepEqual(want, got) {
+			t.Errorf("version %d: directory navmesh has polys %x, want %x", v, got, want)
+		}
+
+		if v != NavMeshSetVersion {
+			continue
+		}
+
+		// The current version must be encoded as it was.
+		var buf bytes.Buffer
+		checkt(t, mesh.Encode(&buf))
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("version %d: encoded navmesh differs from %s, either keep the format or bump NavMeshSetVersion", v, fname)
+		}
+
+		dir, err := ioutil.TempDir("", "navmesh")
+		checkt(t, err)
+		defer os.RemoveAll(dir)
+		checkt(t, dirMesh.EncodeDir(dir))
+		files, err := filepath.Glob(filepath.Join(compat, fmt.Sprintf("navmeshdir-v%d", v), "*"))
+		checkt(t, err)
+		for _, f := range files {
+			want, err := ioutil.ReadFile(f)
+			checkt(t, err)
+			got, err := ioutil.ReadFile(filepath.Join(dir, filepath.Base(f)))
+			if err != nil || !bytes.Equal(got, want) {
+				t.Errorf("version %d: encoded directory file %s differs, either keep the format or bump NavMeshSetVersion", v, filepath.Base(f))
+			}
+		}
+	}
+
+	// Unsupported versions are rejected.
+	data, err := ioutil.ReadFile(filepath.Join(compat, fmt.Sprintf("navmeshset-v%d.bin", NavMeshSetVersion)))
+	checkt(t, err)
+	for _, v := range []uint32{MinNavMeshSetVersion - 1, NavMeshSetVersion + 1} {
+		bad := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint32(bad[4:], v)
+		if _, err := Decode(bytes.NewReader(bad)); err == nil {
+			t.Errorf("Decode should reject version %d", v)
+		}
+		if _, err := DecodeIndex(bytes.NewReader(bad)); err == nil {
+			t.Errorf("DecodeIndex should reject version %d", v)
+		}
+	}
+}
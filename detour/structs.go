@@ -111,6 +111,33 @@ func (s *MeshHeader) size() int {
 	return 100
 }
 
+// dataSize returns the size, in bytes, of the tile data described by this
+// header, header included.
+func (s *MeshHeader) dataSize() int {
+	return s.size() +
+		4*3*int(s.VertCount) +
+		polySize*int(s.PolyCount) +
+		linkSize*int(s.MaxLinkCount) +
+		polyDetailSize*int(s.DetailMeshCount) +
+		4*3*int(s.DetailVertCount) +
+		4*int(s.DetailTriCount) +
+		bvNodeSize*int(s.BvNodeCount) +
+		offMeshConnectionSize*int(s.OffMeshConCount)
+}
+
+// isValid reports whether the counts of the header are consistent, that is
+// they are all positive and the off-mesh connection polygons fit in the tile.
+func (s *MeshHeader) isValid() bool {
+	for _, n := range []int32{s.PolyCount, s.VertCount, s.MaxLinkCount,
+		s.DetailMeshCount, s.DetailVertCount, s.DetailTriCount,
+		s.BvNodeCount, s.OffMeshConCount, s.OffMeshBase} {
+		if n < 0 {
+			return false
+		}
+	}
+	return s.OffMeshBase+s.OffMeshConCount <= s.PolyCount
+}
+
 func (s *MeshHeader) serialize(dst []byte) {
 	if len(dst) < s.size() {
 		panic("undersized buffer for MeshHeader")
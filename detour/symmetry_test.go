@@ -0,0 +1,157 @@
+package detour
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+// pathPoint is a position on a navmesh polygon.
+type pathPoint struct {
+	ref PolyRef
+	pos d3.Vec3
+}
+
+// samplePathPoints returns n points at the center of random polygons of a
+// navmesh, always the same for the same navmesh.
+func samplePathPoints(m *NavMesh, n int) []pathPoint {
+	var refs []PolyRef
+	for _, ref := range allPolyRefs(m) {
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		m.TileAndPolyByRefUnsafe(ref, &tile, &poly)
+		if poly.Type() != polyTypeOffMeshConnection {
+			refs = append(refs, ref)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	pts := make([]pathPoint, n)
+	for i := range pts {
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		ref := refs[rnd.Intn(len(refs))]
+		m.TileAndPolyByRefUnsafe(ref, &tile, &poly)
+		pts[i] = pathPoint{ref, CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts)}
+	}
+	return pts
+}
+
+// pathCost returns the cost of a path corridor, as computed by FindPath, that
+// is the length of the polyline going through the middle of the portals.
+func pathCost(tb testing.TB, q *NavMeshQuery, from, to pathPoint, path []PolyRef) float32 {
+	var (
+		left, right      = d3.NewVec3(), d3.NewVec3()
+		mid              = d3.NewVec3()
+		prev             = d3.NewVec3From(from.pos)
+		cost             float32
+		fromType, toType uint8
+	)
+	for i := 0; i+1 < len(path); i++ {
+		if StatusFailed(q.portalPoints6(path[i], path[i+1], left, right, &fromType, &toType)) {
+			tb.Fatalf("no portal between 0x%x and 0x%x", path[i], path[i+1])
+		}
+		d3.Vec3Lerp(mid, left, right, 0.5)
+		cost += prev.Dist(mid)
+		prev.Assign(mid)
+	}
+	return cost + prev.Dist(to.pos)
+}
+
+// findPathCost finds the path between 2 points and returns its cost. ok is
+// false if no complete path has been found.
+func findPathCost(tb testing.TB, q *NavMeshQuery, filter QueryFilter, from, to pathPoint) (cost float32, path []PolyRef, ok bool) {
+	path = make([]PolyRef, 256)
+	n, st := q.FindPath(from.ref, to.ref, from.pos, to.pos, filter, path)
+	if StatusFailed(st) || StatusDetail(st, PartialResult) || n == 0 {
+		return 0, nil, false
+	}
+	path = path[:n]
+	return pathCost(tb, q, from, to, path), path, true
+}
+
+// checkPathSymmetry checks that the path from a to b costs approximately the
+// same as the path from b to a, tol being the relative tolerance. The filter
+// must be symmetric.
+func checkPathSymmetry(t *testing.T, q *NavMeshQuery, filter QueryFilter, a, b pathPoint, tol float32) {
+	t.Helper()
+
+	costAB, pathAB, okAB := findPathCost(t, q, filter, a, b)
+	costBA, pathBA, okBA := findPathCost(t, q, filter, b, a)
+	if okAB != okBA {
+		t.Errorf("path %v -> %v found: %t, path back found: %t", a.pos, b.pos, okAB, okBA)
+		return
+	}
+	if !okAB {
+		return
+	}
+
+	if math32.Abs(costAB-costBA) > tol*costAB+1e-4 {
+		t.Errorf("path %v -> %v costs %f (%d polys), path back costs %f (%d polys)",
+			a.pos, b.pos, costAB, len(pathAB), costBA, len(pathBA))
+	}
+}
+
+// checkTriangleInequality checks that going from a to c directly doesn't cost
+// more than going from a to c through b, tol being the relative tolerance.
+func checkTriangleInequality(t *testing.T, q *NavMeshQuery, filter QueryFilter, a, b, c pathPoint, tol float32) {
+	t.Helper()
+
+	costAC, _, okAC := findPathCost(t, q, filter, a, c)
+	costAB, _, okAB := findPathCost(t, q, filter, a, b)
+	costBC, _, okBC := findPathCost(t, q, filter, b, c)
+	if !okAB || !okBC {
+		return
+	}
+	if !okAC {
+		t.Errorf("no path %v -> %v, though there is one through %v", a.pos, c.pos, b.pos)
+		return
+	}
+
+	if costAC > (costAB+costBC)*(1+tol)+1e-4 {
+		t.Errorf("path %v -> %v costs %f, more than %f through %v",
+			a.pos, c.pos, costAC, costAB+costBC, b.pos)
+	}
+}
+
+func TestPathSymmetry(t *testing.T) {
+	// The position of a search node is the middle of the edge through which
+	// its polygon has first been reached, so the path costs depend on the
+	// search direction, and on the paths explored before. On the simple
+	// fixtures this makes no difference, on the more complex test meshes the
+	// tolerance is large enough to only catch gross errors.
+	tests := []struct {
+		fname          string
+		symTol, triTol float32
+	}{
+		{"fixture/plane.bin", 1e-3, 1e-3},
+		{"fixture/rooms.bin", 1e-3, 1e-3},
+		{"fixture/floors.bin", 1e-3, 1e-3},
+		{"fixture/donut.bin", 1e-3, 1e-3},
+		{"fixture/tiled.bin", 1e-3, 1e-3},
+		{"mesh1.bin", 0.25, 0.05},
+		{"mesh2.bin", 0.25, 0.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fname, func(t *testing.T) {
+			mesh, err := loadTestNavMesh(tt.fname)
+			checkt(t, err)
+			_, q := NewNavMeshQuery(mesh, 2048)
+			filter := NewStandardQueryFilter()
+
+			pts := samplePathPoints(mesh, 60)
+			for i := 0; i+1 < len(pts); i += 2 {
+				checkPathSymmetry(t, q, filter, pts[i], pts[i+1], tt.symTol)
+			}
+			for i := 0; i+2 < len(pts); i += 3 {
+				checkTriangleInequality(t, q, filter, pts[i], pts[i+1], pts[i+2], tt.triTol)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/binary"
 	"io"
 	"math"
+
+	"github.com/arl/gogeo/f32/d3"
 )
 
 // TileRef is a reference to a tile of the navigation mesh.
@@ -49,7 +51,7 @@ type MeshTile struct {
 	// Index to the next free link.
 	LinksFreeList uint32
 
-	// The tile header.
+	// The tile header, nil if the tile slot is empty. (See NavMesh.TileHeader)
 	Header *MeshHeader
 
 	// The tile polygons.
@@ -85,6 +87,11 @@ type MeshTile struct {
 	// [Size: MeshHeader.OffMeshConCount]
 	OffMeshCons []OffMeshConnection
 
+	// The distance of the tile polygons to the nearest wall, nil if they have
+	// not been baked. (See BakeWallDistances)
+	// [Size: MeshHeader.PolyCount]
+	WallDistances []float32
+
 	// The tile data. (Not directly accessed under normal situations.)
 	Data []byte
 
@@ -96,12 +103,33 @@ type MeshTile struct {
 
 	// The next free tile, or the next tile in the spatial grid.
 	Next *MeshTile
+
+	// Generation of the navigation mesh when the tile was added. (See:
+	// NavMesh.RefGeneration)
+	generation uint64
 }
 
 func (s *MeshTile) serialize(dst []byte) {
 	serializeTileData(dst, s.Verts, s.Polys, s.Links, s.DetailMeshes, s.DetailVerts, s.DetailTris, s.BvTree, s.OffMeshCons)
 }
 
+// detailTriVerts returns the vertices of the j-th triangle of the detail
+// mesh pd of poly.
+func (s *MeshTile) detailTriVerts(poly *Poly, pd *PolyDetail, j uint8) [3]d3.Vec3 {
+	var v [3]d3.Vec3
+	t := s.DetailTris[(pd.TriBase+uint32(j))*4:]
+	for k := 0; k < 3; k++ {
+		if t[k] < poly.VertCount {
+			vidx := poly.Verts[t[k]] * 3
+			v[k] = s.Verts[vidx : vidx+3]
+		} else {
+			vidx := (pd.VertBase + uint32(t[k]-poly.VertCount)) * 3
+			v[k] = s.DetailVerts[vidx : vidx+3]
+		}
+	}
+	return v
+}
+
 func (s *MeshTile) unserialize(hdr *MeshHeader, src []byte) {
 	var (
 		little = binary.LittleEndian
@@ -156,7 +184,7 @@ func (s *MeshTile) unserialize(hdr *MeshHeader, src []byte) {
 		m.TriBase = little.Uint32(src[off+4:])
 		m.VertCount = src[off+8]
 		m.TriCount = src[off+9]
-		off += 12
+		off += polyDetailSize
 	}
 	s.DetailVerts = make([]float32, 3*hdr.DetailVertCount)
 	for i := range s.DetailVerts {
@@ -191,13 +219,82 @@ func (s *MeshTile) unserialize(hdr *MeshHeader, src []byte) {
 		o.Pos[5] = math.Float32frombits(little.Uint32(src[off+20:]))
 		o.Rad = math.Float32frombits(little.Uint32(src[off+24:]))
 		o.Poly = little.Uint16(src[off+28:])
-		o.Flags = src[30]
-		o.Side = src[31]
+		o.Flags = src[off+30]
+		o.Side = src[off+31]
 		o.UserID = little.Uint32(src[off+32:])
 		off += 36
 	}
 }
 
+// isValid reports whether the tile contents, as described by hdr, are
+// consistent, that is if all the indices they contain are in range.
+func (s *MeshTile) isValid(hdr *MeshHeader) bool {
+	for i := range s.Polys {
+		p := &s.Polys[i]
+		if p.VertCount < 2 || uint32(p.VertCount) > VertsPerPolygon {
+			return false
+		}
+		for j := uint8(0); j < p.VertCount; j++ {
+			if int32(p.Verts[j]) >= hdr.VertCount {
+				return false
+			}
+			nei := p.Neis[j]
+			if nei&extLink != 0 {
+				if nei&0xff >= 8 {
+					return false
+				}
+			} else if int32(nei) > hdr.PolyCount {
+				return false
+			}
+		}
+		if p.Type() == polyTypeOffMeshConnection {
+			if int32(i) < hdr.OffMeshBase || int32(i)-hdr.OffMeshBase >= hdr.OffMeshConCount {
+				return false
+			}
+			continue
+		}
+		if int32(i) >= hdr.DetailMeshCount {
+			return false
+		}
+		pd := &s.DetailMeshes[i]
+		if int64(pd.VertBase)+int64(pd.VertCount) > int64(hdr.DetailVertCount) ||
+			int64(pd.TriBase)+int64(pd.TriCount) > int64(hdr.DetailTriCount) {
+			return false
+		}
+		for j := uint32(0); j < uint32(pd.TriCount); j++ {
+			t := s.DetailTris[(pd.TriBase+j)*4:]
+			for k := 0; k < 3; k++ {
+				if int(t[k]) >= int(p.VertCount)+int(pd.VertCount) {
+					return false
+				}
+			}
+		}
+	}
+	for i := range s.BvTree {
+		n := &s.BvTree[i]
+		if n.I >= hdr.PolyCount || (n.I < 0 && int64(-n.I) > int64(hdr.BvNodeCount)) {
+			return false
+		}
+	}
+	for i := range s.OffMeshCons {
+		if int32(s.OffMeshCons[i].Poly) >= hdr.PolyCount {
+			return false
+		}
+	}
+	return true
+}
+
+// Serialized sizes, in bytes, of the elements of the tile data. They are the
+// sizes of the corresponding C structs of Detour, padding included, so they
+// don't depend on the memory layout of the Go structs on the target platform.
+const (
+	polySize              = 32
+	linkSize              = 12
+	polyDetailSize        = 12 // 2 bytes of padding
+	bvNodeSize            = 16
+	offMeshConnectionSize = 36
+)
+
 func serializeTileData(dst []byte,
 	verts []float32,
 	polys []Poly,
@@ -256,7 +353,7 @@ func serializeTileData(dst []byte,
 		little.PutUint32(dst[off+4:], m.TriBase)
 		dst[off+8] = m.VertCount
 		dst[off+9] = m.TriCount
-		off += 12
+		off += polyDetailSize
 	}
 	for i := range dverts {
 		little.PutUint32(dst[off:], uint32(math.Float32bits(dverts[i])))
@@ -286,8 +383,8 @@ func serializeTileData(dst []byte,
 		little.PutUint32(dst[off+20:], uint32(math.Float32bits(o.Pos[5])))
 		little.PutUint32(dst[off+24:], uint32(math.Float32bits(o.Rad)))
 		little.PutUint16(dst[off+28:], o.Poly)
-		dst[30] = o.Flags
-		dst[31] = o.Side
+		dst[off+30] = o.Flags
+		dst[off+31] = o.Side
 		little.PutUint32(dst[off+32:], o.UserID)
 		off += 36
 	}
@@ -1,6 +1,9 @@
 package detour
 
 import (
+	"math/rand"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/arl/gogeo/f32/d3"
@@ -20,7 +23,7 @@ func TestFindNearestPolyInTile(t *testing.T) {
 		{
 			d3.Vec3{5, 0, 10},
 			d3.Vec3{0, 1, 0},
-			0x440004,
+			0x440000, // the point is over it
 		},
 		{
 			d3.Vec3{50, 0, 30},
@@ -48,3 +51,260 @@ func TestFindNearestPolyInTile(t *testing.T) {
 		}
 	}
 }
+
+func TestTileDataSerialization(t *testing.T) {
+	for _, fname := range []string{"mesh1.bin", "mesh2.bin", "offmeshcons.bin"} {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+
+		for i := int32(0); i < mesh.MaxTiles; i++ {
+			tile := &mesh.Tiles[i]
+			if tile.Header == nil {
+				continue
+			}
+			if size := tile.Header.dataSize(); size != int(tile.DataSize) {
+				t.Fatalf("%s, tile %d, header data size is %d, want %d", fname, i, size, tile.DataSize)
+			}
+
+			// serialize at an odd offset, the encoding doesn't depend on the
+			// alignment nor on the memory layout of the structs
+			buf := make([]byte, tile.DataSize+1)
+			data := buf[1:]
+			tile.Header.serialize(data)
+			tile.serialize(data[tile.Header.size():])
+
+			var hdr MeshHeader
+			hdr.unserialize(data)
+			if !reflect.DeepEqual(hdr, *tile.Header) {
+				t.Fatalf("%s, tile %d, got header %+v, want %+v", fname, i, hdr, *tile.Header)
+			}
+
+			var got MeshTile
+			got.unserialize(&hdr, data[hdr.size():])
+			for _, tt := range []struct {
+				name      string
+				got, want interface{}
+			}{
+				{"verts", got.Verts, tile.Verts},
+				{"polys", got.Polys, tile.Polys},
+				{"links", got.Links, tile.Links},
+				{"detail meshes", got.DetailMeshes, tile.DetailMeshes},
+				{"detail verts", got.DetailVerts, tile.DetailVerts},
+				{"detail tris", got.DetailTris, tile.DetailTris},
+				{"bv tree", got.BvTree, tile.BvTree},
+				{"off-mesh connections", got.OffMeshCons, tile.OffMeshCons},
+			} {
+				if !reflect.DeepEqual(tt.got, tt.want) {
+					t.Errorf("%s, tile %d, %s differ after serialization", fname, i, tt.name)
+				}
+			}
+		}
+	}
+}
+
+// polyLinks returns the references linked from each polygon of a navmesh.
+func polyLinks(m *NavMesh) map[PolyRef][]PolyRef {
+	links := make(map[PolyRef][]PolyRef)
+	for _, ref := range allPolyRefs(m) {
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		m.TileAndPolyByRefUnsafe(ref, &tile, &poly)
+		var refs []PolyRef
+		for i := poly.FirstLink; i != nullLink; i = tile.Links[i].Next {
+			refs = append(refs, tile.Links[i].Ref)
+		}
+		sort.Slice(refs, func(i, j int) bool { return refs[i] < refs[j] })
+		links[ref] = refs
+	}
+	return links
+}
+
+func TestRelinkTile(t *testing.T) {
+	mesh, err := loadTestNavMesh("fixture/tiled.bin")
+	checkt(t, err)
+	want := polyLinks(mesh)
+
+	if st := mesh.RelinkTile(0); !StatusFailed(st) {
+		t.Errorf("RelinkTile(0) should fail, got status 0x%x", st)
+	}
+
+	// corrupt the links of a tile, and of a neighbour to it
+	tref := mesh.TileRefAt(1, 1, 0)
+	tile := mesh.TileByRef(tref)
+	for i := range tile.Polys {
+		tile.Polys[i].FirstLink = nullLink
+	}
+	nei := mesh.TileAt(2, 1, 0)
+	for i := range nei.Polys {
+		if l := nei.Polys[i].FirstLink; l != nullLink {
+			nei.Links[l].Ref = 0
+		}
+	}
+	nei.Polys[0].FirstLink = nullLink
+
+	gen := tile.generation
+	if st := mesh.RelinkTile(mesh.TileRef(nei)); StatusFailed(st) {
+		t.Fatalf("RelinkTile failed with status 0x%x", st)
+	}
+	if st := mesh.RelinkTile(tref); StatusFailed(st) {
+		t.Fatalf("RelinkTile failed with status 0x%x", st)
+	}
+	if got := polyLinks(mesh); !reflect.DeepEqual(got, want) {
+		t.Errorf("links after RelinkTile differ from the original ones")
+	}
+	if tile.generation == gen {
+		t.Errorf("RelinkTile should update the tile generation")
+	}
+
+	// in place edit: turn an internal edge into a wall
+	mesh, err = loadTestNavMesh("mesh1.bin")
+	checkt(t, err)
+	tref = mesh.TileRefAt(0, 0, 0)
+	tile = mesh.TileByRef(tref)
+	base := mesh.polyRefBase(tile)
+	var from, to PolyRef
+	for i := range tile.Polys {
+		poly := &tile.Polys[i]
+		for j := uint8(0); j < poly.VertCount && from == 0; j++ {
+			if n := poly.Neis[j]; n != 0 && n&extLink == 0 {
+				from, to = base|PolyRef(i), base|PolyRef(n-1)
+				poly.Neis[j] = 0
+				// and the opposite edge
+				opp := &tile.Polys[n-1]
+				for k := uint8(0); k < opp.VertCount; k++ {
+					if opp.Neis[k] == uint16(i+1) {
+						opp.Neis[k] = 0
+					}
+				}
+			}
+		}
+	}
+	if from == 0 {
+		t.Fatal("no internal edge found")
+	}
+	if st := mesh.RelinkTile(tref); StatusFailed(st) {
+		t.Fatalf("RelinkTile failed with status 0x%x", st)
+	}
+	got := polyLinks(mesh)
+	for _, ref := range got[from] {
+		if ref == to {
+			t.Errorf("0x%x should not be linked to 0x%x anymore", from, to)
+		}
+	}
+	for _, ref := range got[to] {
+		if ref == from {
+			t.Errorf("0x%x should not be linked to 0x%x anymore", to, from)
+		}
+	}
+}
+
+// bruteQueryPolygonsInTile is the reference implementation of
+// NavMesh.QueryPolygonsInTile, testing all the polygons of the tile, or all
+// the leaves of its BV tree.
+func bruteQueryPolygonsInTile(m *NavMesh, tile *MeshTile, qmin, qmax d3.Vec3) []PolyRef {
+	var polys []PolyRef
+	base := m.polyRefBase(tile)
+	if len(tile.BvTree) > 0 {
+		bmin, bmax := quantizeQueryBox(tile.Header, qmin, qmax)
+		nodes := tile.BvTree[:bvTreeSize(tile.BvTree)]
+		for i := range nodes {
+			node := &nodes[i]
+			if node.I >= 0 && OverlapQuantBounds(bmin[:], bmax[:], node.BMin[:], node.BMax[:]) {
+				polys = append(polys, base|PolyRef(node.I))
+			}
+		}
+		return polys
+	}
+	bmin, bmax := d3.NewVec3(), d3.NewVec3()
+	for i := range tile.Polys {
+		poly := &tile.Polys[i]
+		if poly.Type() == polyTypeOffMeshConnection {
+			continue
+		}
+		polyBounds(tile, poly, bmin, bmax)
+		if OverlapBounds(qmin, qmax, bmin, bmax) {
+			polys = append(polys, base|PolyRef(i))
+		}
+	}
+	return polys
+}
+
+func sortedRefs(refs []PolyRef) []PolyRef {
+	refs = append([]PolyRef(nil), refs...)
+	sort.Slice(refs, func(i, j int) bool { return refs[i] < refs[j] })
+	return refs
+}
+
+func TestQueryPolygonsInTile(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, fname := range []string{"mesh1.bin", "mesh2.bin", "offmeshcons.bin", "fixture/floors.bin", "fixture/tiled.bin"} {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+
+		for i := range mesh.Tiles {
+			tile := &mesh.Tiles[i]
+			if tile.Header == nil {
+				continue
+			}
+			hdr := tile.Header
+			bvtree := tile.BvTree
+			for _, withTree := range []bool{true, false} {
+				if !withTree {
+					tile.BvTree = nil
+				}
+
+				// The whole tile returns each polygon, off-mesh connections
+				// excepted, once.
+				got := sortedRefs(mesh.QueryPolygonsInTile(tile, hdr.BMin[:], hdr.BMax[:], nil))
+				if len(got) != int(hdr.OffMeshBase) {
+					t.Fatalf("%s, tile %d, bv tree %t: got %d polygons in the tile box, want %d",
+						fname, i, withTree, len(got), hdr.OffMeshBase)
+				}
+				for j, ref := range got {
+					if ref != mesh.polyRefBase(tile)|PolyRef(j) {
+						t.Fatalf("%s, tile %d, bv tree %t: got polygons %x", fname, i, withTree, got)
+					}
+				}
+
+				for k := 0; k < 200; k++ {
+					center, ext := d3.NewVec3(), d3.NewVec3()
+					for a := 0; a < 3; a++ {
+						size := hdr.BMax[a] - hdr.BMin[a]
+						center[a] = hdr.BMin[a] - 0.1*size + 1.2*size*rng.Float32()
+						ext[a] = 0.3 * size * rng.Float32()
+					}
+					qmin, qmax := center.Sub(ext), center.Add(ext)
+
+					// The polygons are appended to the slice.
+					prefix := []PolyRef{0xdead}
+					res := mesh.QueryPolygonsInTile(tile, qmin, qmax, prefix)
+					if res[0] != 0xdead {
+						t.Fatalf("QueryPolygonsInTile should append to the given slice")
+					}
+					got := sortedRefs(res[1:])
+					want := sortedRefs(bruteQueryPolygonsInTile(mesh, tile, qmin, qmax))
+					if !reflect.DeepEqual(got, want) {
+						t.Fatalf("%s, tile %d, bv tree %t, box %v %v: got polygons %x, want %x",
+							fname, i, withTree, qmin, qmax, got, want)
+					}
+
+					// The BV tree bounds are conservative.
+					if withTree {
+						tile.BvTree = nil
+						exact := bruteQueryPolygonsInTile(mesh, tile, qmin, qmax)
+						tile.BvTree = bvtree
+						for _, ref := range exact {
+							j := sort.Search(len(got), func(j int) bool { return got[j] >= ref })
+							if j == len(got) || got[j] != ref {
+								t.Fatalf("%s, tile %d, box %v %v: polygon 0x%x is missing", fname, i, qmin, qmax, ref)
+							}
+						}
+					}
+				}
+			}
+			tile.BvTree = bvtree
+		}
+	}
+}
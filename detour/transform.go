@@ -0,0 +1,215 @@
+package detour
+
+import (
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+// Transform places a navigation mesh in the world: a rotation around the
+// y-axis followed by a translation.
+//
+// The navigation mesh is built, and its coordinates are stored, in a local
+// space. The transform converts local coordinates to world coordinates, it
+// can be changed at any time, for example to follow a moving platform (a
+// ferry, an elevator) for which a static navigation mesh has been baked.
+type Transform struct {
+	Pos d3.Vec3 // The world position of the origin of the local space. [(x, y, z)]
+	Yaw float32 // The rotation around the y-axis, in radians.
+}
+
+// rotate rotates v around the y-axis by the angle whose cosine and sine are c
+// and s, and stores the result in dst. dst and v may be the same vector.
+func rotate(dst, v d3.Vec3, c, s float32) {
+	x, z := v[0], v[2]
+	dst[0] = c*x + s*z
+	dst[1] = v[1]
+	dst[2] = -s*x + c*z
+}
+
+// PointToWorld converts a point from local to world coordinates and stores
+// the result in dst. dst and p may be the same vector.
+func (xf *Transform) PointToWorld(dst, p d3.Vec3) {
+	s, c := math32.Sincos(xf.Yaw)
+	rotate(dst, p, c, s)
+	if len(xf.Pos) >= 3 {
+		dst[0] += xf.Pos[0]
+		dst[1] += xf.Pos[1]
+		dst[2] += xf.Pos[2]
+	}
+}
+
+// PointToLocal converts a point from world to local coordinates and stores
+// the result in dst. dst and p may be the same vector.
+func (xf *Transform) PointToLocal(dst, p d3.Vec3) {
+	dst[0], dst[1], dst[2] = p[0], p[1], p[2]
+	if len(xf.Pos) >= 3 {
+		dst[0] -= xf.Pos[0]
+		dst[1] -= xf.Pos[1]
+		dst[2] -= xf.Pos[2]
+	}
+	s, c := math32.Sincos(xf.Yaw)
+	rotate(dst, dst, c, -s)
+}
+
+// VecToWorld converts a direction from local to world coordinates and stores
+// the result in dst. dst and v may be the same vector.
+func (xf *Transform) VecToWorld(dst, v d3.Vec3) {
+	s, c := math32.Sincos(xf.Yaw)
+	rotate(dst, v, c, s)
+}
+
+// VecToLocal converts a direction from world to local coordinates and stores
+// the result in dst. dst and v may be the same vector.
+func (xf *Transform) VecToLocal(dst, v d3.Vec3) {
+	s, c := math32.Sincos(xf.Yaw)
+	rotate(dst, v, c, -s)
+}
+
+// extentsToLocal returns the half extents, in local coordinates, of the
+// axis-aligned box containing the world box with half extents ext.
+func (xf *Transform) extentsToLocal(dst, ext d3.Vec3) {
+	s, c := math32.Sincos(xf.Yaw)
+	s, c = math32.Abs(s), math32.Abs(c)
+	x, z := ext[0], ext[2]
+	dst[0] = c*x + s*z
+	dst[1] = ext[1]
+	dst[2] = s*x + c*z
+}
+
+// TransformedQuery runs queries on a navigation mesh placed in the world by a
+// transform. Its methods are the ones of NavMeshQuery with the same name, but
+// their positions, in and out, are in world coordinates.
+//
+// The polygon references are the ones of the navigation mesh, they stay valid
+// when the transform changes. Each method converts its inputs to the local
+// space with the current transform, so an agent standing on a moving platform
+// keeps a valid path corridor, only the world positions change. The positions
+// of a path found before the transform changed must be converted again, or
+// queried again.
+type TransformedQuery struct {
+	Query     *NavMeshQuery // The query on the navigation mesh.
+	Transform Transform     // The transform of the navigation mesh.
+}
+
+// NewTransformedQuery returns a TransformedQuery running q with the transform
+// xf.
+func NewTransformedQuery(q *NavMeshQuery, xf Transform) *TransformedQuery {
+	return &TransformedQuery{Query: q, Transform: xf}
+}
+
+// FindNearestPoly finds the polygon nearest to the specified center point.
+//
+// The search box, defined by its half extents, is axis-aligned in world
+// coordinates. It's enlarged to the box axis-aligned in local coordinates
+// containing it. See NavMeshQuery.FindNearestPoly.
+func (tq *TransformedQuery) FindNearestPoly(center, extents d3.Vec3,
+	filter QueryFilter) (st Status, ref PolyRef, pt d3.Vec3) {
+
+	var localCenter, localExt [3]float32
+	tq.Transform.PointToLocal(localCenter[:], center)
+	tq.Transform.extentsToLocal(localExt[:], extents)
+	st, ref, pt = tq.Query.FindNearestPoly(localCenter[:], localExt[:], filter)
+	if StatusSucceed(st) && ref != 0 {
+		tq.Transform.PointToWorld(pt, pt)
+	}
+	return st, ref, pt
+}
+
+// FindPath finds a path from the start polygon to the end polygon. See
+// NavMeshQuery.FindPath.
+func (tq *TransformedQuery) FindPath(
+	startRef, endRef PolyRef,
+	startPos, endPos d3.Vec3,
+	filter QueryFilter,
+	path []PolyRef) (pathCount int, st Status) {
+
+	var localStart, localEnd [3]float32
+	tq.Transform.PointToLocal(localStart[:], startPos)
+	tq.Transform.PointToLocal(localEnd[:], endPos)
+	return tq.Query.FindPath(startRef, endRef, localStart[:], localEnd[:], filter, path)
+}
+
+// FindStraightPath finds the straight path from the start to the end position
+// within the polygon corridor. The points of the straight path are in world
+// coordinates. See NavMeshQuery.FindStraightPath.
+func (tq *TransformedQuery) FindStraightPath(
+	startPos, endPos d3.Vec3,
+	path []PolyRef,
+	straightPath []d3.Vec3,
+	straightPathFlags []uint8,
+	straightPathRefs []PolyRef,
+	options int32) (straightPathCount int, st Status) {
+
+	var localStart, localEnd [3]float32
+	tq.Transform.PointToLocal(localStart[:], startPos)
+	tq.Transform.PointToLocal(localEnd[:], endPos)
+	straightPathCount, st = tq.Query.FindStraightPath(localStart[:], localEnd[:], path,
+		straightPath, straightPathFlags, straightPathRefs, options)
+	for i := 0; i < straightPathCount; i++ {
+		tq.Transform.PointToWorld(straightPath[i], straightPath[i])
+	}
+	return straightPathCount, st
+}
+
+// Raycast casts a 'walkability' ray along the surface of the navigation mesh
+// from the start position toward the end position. The hit normal is in world
+// coordinates. See NavMeshQuery.Raycast.
+func (tq *TransformedQuery) Raycast(
+	startRef PolyRef,
+	startPos, endPos d3.Vec3,
+	filter QueryFilter,
+	options int,
+	hit *RaycastHit,
+	prevRef PolyRef) (st Status) {
+
+	var localStart, localEnd [3]float32
+	tq.Transform.PointToLocal(localStart[:], startPos)
+	tq.Transform.PointToLocal(localEnd[:], endPos)
+	st = tq.Query.Raycast(startRef, localStart[:], localEnd[:], filter, options, hit, prevRef)
+	if hit != nil && len(hit.HitNormal) >= 3 {
+		tq.Transform.VecToWorld(hit.HitNormal, hit.HitNormal)
+	}
+	return st
+}
+
+// MoveAlongSurface moves from the start to the end position constrained to
+// the navigation mesh. The result position is in world coordinates. See
+// NavMeshQuery.MoveAlongSurface.
+func (tq *TransformedQuery) MoveAlongSurface(startRef PolyRef, startPos, endPos d3.Vec3,
+	filter QueryFilter, resultPos d3.Vec3, visited []PolyRef) (visitedCount int, st Status) {
+
+	var localStart, localEnd [3]float32
+	tq.Transform.PointToLocal(localStart[:], startPos)
+	tq.Transform.PointToLocal(localEnd[:], endPos)
+	visitedCount, st = tq.Query.MoveAlongSurface(startRef, localStart[:], localEnd[:],
+		filter, resultPos, visited)
+	if StatusSucceed(st) {
+		tq.Transform.PointToWorld(resultPos, resultPos)
+	}
+	return visitedCount, st
+}
+
+// ClosestPointOnPoly finds the closest point on the specified polygon. See
+// NavMeshQuery.ClosestPointOnPoly.
+func (tq *TransformedQuery) ClosestPointOnPoly(ref PolyRef, pos, closest d3.Vec3, posOverPoly *bool) Status {
+	var localPos [3]float32
+	tq.Transform.PointToLocal(localPos[:], pos)
+	st := tq.Query.ClosestPointOnPoly(ref, localPos[:], closest, posOverPoly)
+	if StatusSucceed(st) {
+		tq.Transform.PointToWorld(closest, closest)
+	}
+	return st
+}
+
+// PolyHeight returns the world height of the polygon at the provided
+// position. See NavMeshQuery.PolyHeight.
+func (tq *TransformedQuery) PolyHeight(ref PolyRef, pos d3.Vec3) (height float32, st Status) {
+	var localPos [3]float32
+	tq.Transform.PointToLocal(localPos[:], pos)
+	height, st = tq.Query.PolyHeight(ref, localPos[:])
+	if StatusSucceed(st) && len(tq.Transform.Pos) >= 3 {
+		// The rotation is around the y-axis, heights are only translated.
+		height += tq.Transform.Pos[1]
+	}
+	return height, st
+}
@@ -0,0 +1,125 @@
+package detour
+
+import (
+	"math"
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+func approxVec3Eps(a, b d3.Vec3, eps float32) bool {
+	for k := 0; k < 3; k++ {
+		if math32.Abs(a[k]-b[k]) > eps {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTransform(t *testing.T) {
+	xf := Transform{Pos: d3.Vec3{10, 2, -3}, Yaw: math.Pi / 2}
+
+	p := d3.NewVec3()
+	xf.PointToWorld(p, d3.Vec3{1, 1, 0})
+	if want := (d3.Vec3{10, 3, -4}); !approxVec3Eps(p, want, 1e-5) {
+		t.Errorf("PointToWorld = %v, want %v", p, want)
+	}
+	xf.PointToLocal(p, p)
+	if want := (d3.Vec3{1, 1, 0}); !approxVec3Eps(p, want, 1e-5) {
+		t.Errorf("PointToLocal(PointToWorld(p)) = %v, want %v", p, want)
+	}
+
+	v := d3.NewVec3()
+	xf.VecToWorld(v, d3.Vec3{0, 1, 1})
+	if want := (d3.Vec3{1, 1, 0}); !approxVec3Eps(v, want, 1e-5) {
+		t.Errorf("VecToWorld = %v, want %v", v, want)
+	}
+	xf.VecToLocal(v, v)
+	if want := (d3.Vec3{0, 1, 1}); !approxVec3Eps(v, want, 1e-5) {
+		t.Errorf("VecToLocal(VecToWorld(v)) = %v, want %v", v, want)
+	}
+
+	// The zero transform is the identity.
+	var id Transform
+	id.PointToWorld(p, d3.Vec3{1, 2, 3})
+	if want := (d3.Vec3{1, 2, 3}); !approxVec3Eps(p, want, 0) {
+		t.Errorf("identity PointToWorld = %v, want %v", p, want)
+	}
+}
+
+func TestTransformedQuery(t *testing.T) {
+	q, org, dst, _ := straightPathFixture(t)
+	xf := Transform{Pos: d3.Vec3{100, 5, -50}, Yaw: 0.7}
+	tq := NewTransformedQuery(q, xf)
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+
+	world := func(p d3.Vec3) d3.Vec3 {
+		w := d3.NewVec3()
+		xf.PointToWorld(w, p)
+		return w
+	}
+	const eps = 1e-3
+
+	// FindNearestPoly
+	_, orgRef, _ := q.FindNearestPoly(org, extents, filter)
+	_, dstRef, _ := q.FindNearestPoly(dst, extents, filter)
+	st, ref, pt := tq.FindNearestPoly(world(org), extents, filter)
+	if StatusFailed(st) || ref != orgRef || !approxVec3Eps(pt, world(org), eps) {
+		t.Fatalf("FindNearestPoly = (%v, 0x%x, %v), want ref 0x%x at %v", st, ref, pt, orgRef, world(org))
+	}
+
+	// FindPath
+	path := make([]PolyRef, 100)
+	npath, _ := q.FindPath(orgRef, dstRef, org, dst, filter, path)
+	tpath := make([]PolyRef, 100)
+	ntpath, st := tq.FindPath(orgRef, dstRef, world(org), world(dst), filter, tpath)
+	if StatusFailed(st) || ntpath != npath || !equalRefs(path[:npath], tpath[:ntpath]) {
+		t.Fatalf("FindPath = %v, want %v", tpath[:ntpath], path[:npath])
+	}
+	path = path[:npath]
+
+	// FindStraightPath
+	straight := make([]d3.Vec3, 32)
+	tstraight := make([]d3.Vec3, 32)
+	for i := range straight {
+		straight[i], tstraight[i] = d3.NewVec3(), d3.NewVec3()
+	}
+	n, _ := q.FindStraightPath(org, dst, path, straight, nil, nil, 0)
+	tn, _ := tq.FindStraightPath(world(org), world(dst), path, tstraight, nil, nil, 0)
+	if n != tn {
+		t.Fatalf("FindStraightPath returned %d points, want %d", tn, n)
+	}
+	for i := 0; i < n; i++ {
+		if !approxVec3Eps(tstraight[i], world(straight[i]), eps) {
+			t.Errorf("straight path point %d = %v, want %v", i, tstraight[i], world(straight[i]))
+		}
+	}
+
+	// Raycast
+	var hit, thit RaycastHit
+	q.Raycast(orgRef, org, dst, filter, 0, &hit, 0)
+	tq.Raycast(orgRef, world(org), world(dst), filter, 0, &thit, 0)
+	normal := d3.NewVec3()
+	xf.VecToWorld(normal, hit.HitNormal)
+	if math32.Abs(hit.T-thit.T) > eps || !approxVec3Eps(thit.HitNormal, normal, eps) {
+		t.Errorf("Raycast = (%v, %v), want (%v, %v)", thit.T, thit.HitNormal, hit.T, normal)
+	}
+
+	// MoveAlongSurface
+	res, tres := d3.NewVec3(), d3.NewVec3()
+	visited := make([]PolyRef, 16)
+	q.MoveAlongSurface(orgRef, org, dst, filter, res, visited)
+	tq.MoveAlongSurface(orgRef, world(org), world(dst), filter, tres, visited)
+	if !approxVec3Eps(tres, world(res), eps) {
+		t.Errorf("MoveAlongSurface = %v, want %v", tres, world(res))
+	}
+
+	// PolyHeight
+	h, _ := q.PolyHeight(orgRef, org)
+	th, st := tq.PolyHeight(orgRef, world(org))
+	if StatusFailed(st) || math32.Abs(th-(h+xf.Pos[1])) > eps {
+		t.Errorf("PolyHeight = %v, want %v", th, h+xf.Pos[1])
+	}
+}
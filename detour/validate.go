@@ -0,0 +1,331 @@
+package detour
+
+import "fmt"
+
+// IDBits returns the number of bits used by each part of the polygon
+// references of the navigation mesh.
+//
+//	Return values:
+//	 salt    The number of salt bits.
+//	 tile    The number of tile bits.
+//	 poly    The number of polygon bits.
+func (m *NavMesh) IDBits() (salt, tile, poly uint32) {
+	return m.saltBits, m.tileBits, m.polyBits
+}
+
+// Issue is a structural problem found in a navigation mesh by
+// ValidateNavMesh.
+type Issue struct {
+	Tile        TileRef // The reference of the tile.
+	X, Y, Layer int32   // The location of the tile in the tile grid.
+	Poly        int32   // The index of the polygon in the tile, or -1.
+	Msg         string  // The description of the problem.
+}
+
+func (is Issue) String() string {
+	if is.Poly < 0 {
+		return fmt.Sprintf("tile (%d, %d, %d): %s", is.X, is.Y, is.Layer, is.Msg)
+	}
+	return fmt.Sprintf("tile (%d, %d, %d): polygon %d: %s", is.X, is.Y, is.Layer, is.Poly, is.Msg)
+}
+
+// ValidateNavMesh validates the structure of a navigation mesh and returns the
+// issues found, or nil if it is consistent.
+//
+// For each loaded tile, ValidateNavMesh verifies that:
+//   - the tile reference, salt and location are consistent,
+//   - the header counts match the tile data,
+//   - the polygons reference existing vertices and neighbours,
+//   - the link lists are well formed and only reference valid polygons,
+//   - the links between ground polygons are reciprocal,
+//   - the detail meshes reference existing vertices and triangles,
+//   - the off-mesh connections reference off-mesh connection polygons,
+//   - the bounding volume tree covers each ground polygon exactly once, and
+//     that its nodes contain their children and their polygons.
+//
+// AddTile only rejects the tile data whose indices are out of range. Beyond
+// that, ValidateNavMesh checks the links and their reciprocity, the bounding
+// volume trees, and the tile salts and references, which may also have been
+// modified after the tiles were added (E.g. by custom tile building or editing
+// code). It is meant to diagnose data issues, not to be called at runtime. It
+// doesn't modify m.
+//
+// returned error will be different from nil if m is not initialized.
+func ValidateNavMesh(m *NavMesh) ([]Issue, error) {
+	if m == nil || m.MaxTiles == 0 || len(m.Tiles) == 0 {
+		return nil, fmt.Errorf("navmesh is not initialized")
+	}
+
+	var issues []Issue
+	for i := range m.Tiles {
+		tile := &m.Tiles[i]
+		if tile.Header == nil {
+			continue
+		}
+		hdr := tile.Header
+		ref := m.TileRef(tile)
+		report := func(poly int32, format string, args ...interface{}) {
+			issues = append(issues, Issue{
+				Tile:  ref,
+				X:     hdr.X,
+				Y:     hdr.Y,
+				Layer: hdr.Layer,
+				Poly:  poly,
+				Msg:   fmt.Sprintf(format, args...),
+			})
+		}
+
+		m.checkTileRef(tile, report)
+		if !m.checkTileCounts(tile, report) {
+			// Don't go any further, it's not safe to index the tile data.
+			continue
+		}
+		m.checkTilePolys(tile, report)
+		m.checkTileDetail(tile, report)
+		m.checkTileBVTree(tile, report)
+	}
+	return issues, nil
+}
+
+// checkTileRef checks that the reference, the salt and the location of a tile
+// are consistent.
+func (m *NavMesh) checkTileRef(tile *MeshTile, report func(poly int32, format string, args ...interface{})) {
+	hdr := tile.Header
+	if tile.Salt == 0 || uint64(tile.Salt) >= 1<<m.saltBits {
+		report(-1, "invalid salt %d", tile.Salt)
+	}
+	ref := m.TileRef(tile)
+	if m.TileByRef(ref) != tile {
+		report(-1, "tile ref 0x%x doesn't resolve to the tile", ref)
+	}
+	if m.TileAt(hdr.X, hdr.Y, hdr.Layer) != tile {
+		report(-1, "tile not found at its location")
+	}
+}
+
+// checkTileCounts checks that the tile header counts match the tile data. It
+// returns false if they don't.
+func (m *NavMesh) checkTileCounts(tile *MeshTile, report func(poly int32, format string, args ...interface{})) bool {
+	hdr := tile.Header
+	ok := true
+	for _, c := range []struct {
+		name      string
+		got, want int
+	}{
+		{"polygons", len(tile.Polys), int(hdr.PolyCount)},
+		{"vertices", len(tile.Verts), 3 * int(hdr.VertCount)},
+		{"links", len(tile.Links), int(hdr.MaxLinkCount)},
+		{"detail meshes", len(tile.DetailMeshes), int(hdr.DetailMeshCount)},
+		{"detail vertices", len(tile.DetailVerts), 3 * int(hdr.DetailVertCount)},
+		{"detail triangles", len(tile.DetailTris), 4 * int(hdr.DetailTriCount)},
+		{"bv nodes", len(tile.BvTree), int(hdr.BvNodeCount)},
+		{"off-mesh connections", len(tile.OffMeshCons), int(hdr.OffMeshConCount)},
+	} {
+		if c.got < c.want {
+			report(-1, "%d %s in data, header says %d", c.got, c.name, c.want)
+			ok = false
+		}
+	}
+	if hdr.OffMeshBase < 0 || hdr.OffMeshBase+hdr.OffMeshConCount != hdr.PolyCount {
+		report(-1, "off-mesh base %d and %d off-mesh connections don't match %d polygons",
+			hdr.OffMeshBase, hdr.OffMeshConCount, hdr.PolyCount)
+		ok = false
+	}
+	if hdr.DetailMeshCount != 0 && hdr.DetailMeshCount != hdr.OffMeshBase {
+		report(-1, "%d detail meshes for %d ground polygons", hdr.DetailMeshCount, hdr.OffMeshBase)
+		ok = false
+	}
+	return ok
+}
+
+// checkTilePolys checks the polygons, the links and the off-mesh connections
+// of a tile.
+func (m *NavMesh) checkTilePolys(tile *MeshTile, report func(poly int32, format string, args ...interface{})) {
+	hdr := tile.Header
+	base := m.polyRefBase(tile)
+	for i := int32(0); i < hdr.PolyCount; i++ {
+		poly := &tile.Polys[i]
+		offMesh := poly.Type() == polyTypeOffMeshConnection
+		if offMesh != (i >= hdr.OffMeshBase) {
+			report(i, "wrong type %d", poly.Type())
+		}
+		minVerts := uint8(3)
+		if offMesh {
+			minVerts = 2
+		}
+		if poly.VertCount < minVerts || uint32(poly.VertCount) > VertsPerPolygon {
+			report(i, "invalid vertex count %d", poly.VertCount)
+			continue
+		}
+		for j := uint8(0); j < poly.VertCount; j++ {
+			if int32(poly.Verts[j]) >= hdr.VertCount {
+				report(i, "vertex %d out of range", poly.Verts[j])
+			}
+			nei := poly.Neis[j]
+			if nei != 0 && nei&extLink == 0 && int32(nei-1) >= hdr.PolyCount {
+				report(i, "neighbour %d out of range", nei-1)
+			}
+		}
+
+		// Walk the link list, the link count guards against loops.
+		count := int32(0)
+		for l := poly.FirstLink; l != nullLink; l = tile.Links[l].Next {
+			if l >= uint32(hdr.MaxLinkCount) {
+				report(i, "link index %d out of range", l)
+				break
+			}
+			if count++; count > hdr.MaxLinkCount {
+				report(i, "loop in link list")
+				break
+			}
+			link := &tile.Links[l]
+			if !m.IsValidPolyRef(link.Ref) {
+				report(i, "link %d to invalid polygon ref 0x%x", l, link.Ref)
+				continue
+			}
+			if link.Edge != 0xff && link.Edge >= poly.VertCount {
+				report(i, "link %d on invalid edge %d", l, link.Edge)
+				continue
+			}
+
+			// Links between ground polygons go both ways.
+			var (
+				nt *MeshTile
+				np *Poly
+			)
+			m.TileAndPolyByRefUnsafe(link.Ref, &nt, &np)
+			if offMesh || link.Edge == 0xff || np.Type() == polyTypeOffMeshConnection {
+				continue
+			}
+			if !m.hasLinkTo(nt, np, base|PolyRef(i)) {
+				report(i, "link to 0x%x is not reciprocal", link.Ref)
+			}
+		}
+	}
+
+	for i := int32(0); i < hdr.OffMeshConCount; i++ {
+		con := &tile.OffMeshCons[i]
+		if int32(con.Poly) < hdr.OffMeshBase || int32(con.Poly) >= hdr.PolyCount {
+			report(-1, "off-mesh connection %d: polygon %d out of range", i, con.Poly)
+		}
+	}
+}
+
+// hasLinkTo reports whether a polygon has a link to ref.
+func (m *NavMesh) hasLinkTo(tile *MeshTile, poly *Poly, ref PolyRef) bool {
+	count := int32(0)
+	for l := poly.FirstLink; l != nullLink && l < uint32(len(tile.Links)); l = tile.Links[l].Next {
+		if tile.Links[l].Ref == ref {
+			return true
+		}
+		if count++; count > tile.Header.MaxLinkCount {
+			break
+		}
+	}
+	return false
+}
+
+// checkTileDetail checks the detail meshes of a tile.
+func (m *NavMesh) checkTileDetail(tile *MeshTile, report func(poly int32, format string, args ...interface{})) {
+	hdr := tile.Header
+	for i := int32(0); i < hdr.DetailMeshCount; i++ {
+		pd := &tile.DetailMeshes[i]
+		if int32(pd.VertBase)+int32(pd.VertCount) > hdr.DetailVertCount {
+			report(i, "detail vertices out of range")
+		}
+		if int32(pd.TriBase)+int32(pd.TriCount) > hdr.DetailTriCount {
+			report(i, "detail triangles out of range")
+			continue
+		}
+		nverts := tile.Polys[i].VertCount + pd.VertCount
+		for j := uint32(0); j < uint32(pd.TriCount); j++ {
+			t := tile.DetailTris[(pd.TriBase+j)*4:]
+			if t[0] >= nverts || t[1] >= nverts || t[2] >= nverts {
+				report(i, "detail triangle %d references invalid vertices", j)
+			}
+		}
+	}
+}
+
+// checkTileBVTree checks the bounding volume tree of a tile.
+func (m *NavMesh) checkTileBVTree(tile *MeshTile, report func(poly int32, format string, args ...interface{})) {
+	hdr := tile.Header
+	if hdr.BvNodeCount == 0 {
+		return
+	}
+
+	contains := func(a, b *BvNode) bool {
+		for k := 0; k < 3; k++ {
+			if b.BMin[k] < a.BMin[k] || b.BMax[k] > a.BMax[k] {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Quantization truncates the bounds, allow 1 unit of error (plus some
+	// slack for floating point rounding).
+	const tol = 1.01
+	bounds := func(node *BvNode, poly *Poly) bool {
+		for j := uint8(0); j < poly.VertCount && uint32(j) < VertsPerPolygon; j++ {
+			if int32(poly.Verts[j]) >= hdr.VertCount {
+				// Already reported.
+				continue
+			}
+			v := tile.Verts[int(poly.Verts[j])*3:]
+			for k := 0; k < 3; k++ {
+				q := (v[k] - hdr.BMin[k]) * hdr.BvQuantFactor
+				if q < float32(node.BMin[k])-tol || q > float32(node.BMax[k])+tol {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	n := bvTreeSize(tile.BvTree)
+	if n > hdr.BvNodeCount {
+		report(-1, "bv tree: root escape index %d out of range", n)
+		return
+	}
+
+	seen := make([]bool, hdr.OffMeshBase)
+	for i := int32(0); i < n; {
+		node := &tile.BvTree[i]
+		if node.I < 0 {
+			// Internal node, its subtree spans the next -I-1 nodes.
+			end := i - node.I
+			if end > n || node.I == -1 {
+				report(-1, "bv node %d: invalid escape index %d", i, -node.I)
+				return
+			}
+			for j := i + 1; j < end; j++ {
+				if !contains(node, &tile.BvTree[j]) {
+					report(-1, "bv node %d: doesn't contain node %d", i, j)
+				}
+			}
+			i++
+			continue
+		}
+
+		// Leaf node.
+		if node.I >= hdr.OffMeshBase {
+			report(-1, "bv node %d: polygon %d out of range", i, node.I)
+			i++
+			continue
+		}
+		if seen[node.I] {
+			report(node.I, "in several bv nodes, including %d", i)
+		}
+		seen[node.I] = true
+		if !bounds(node, &tile.Polys[node.I]) {
+			report(node.I, "out of the bounds of bv node %d", i)
+		}
+		i++
+	}
+	for i, ok := range seen {
+		if !ok {
+			report(int32(i), "not in the bv tree")
+		}
+	}
+}
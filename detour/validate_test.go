@@ -0,0 +1,91 @@
+package detour
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateNavMesh(t *testing.T) {
+	for _, fname := range []string{"mesh1.bin", "mesh2.bin", "offmeshcons.bin", "fixture/tiled.bin", "fixture/floors.bin"} {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+		issues, err := ValidateNavMesh(mesh)
+		checkt(t, err)
+		if len(issues) != 0 {
+			t.Errorf("%v: want no issues, got %v", fname, issues)
+		}
+	}
+
+	if _, err := ValidateNavMesh(&NavMesh{}); err == nil {
+		t.Errorf("want an error with an uninitialized navmesh")
+	}
+}
+
+func TestValidateNavMeshCorrupted(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(mesh *NavMesh, tile *MeshTile)
+		want    string
+	}{
+		{
+			"salt",
+			func(mesh *NavMesh, tile *MeshTile) { tile.Salt = 0 },
+			"salt",
+		},
+		{
+			"vertex out of range",
+			func(mesh *NavMesh, tile *MeshTile) { tile.Polys[0].Verts[0] = uint16(tile.Header.VertCount) },
+			"vertex",
+		},
+		{
+			"link loop",
+			func(mesh *NavMesh, tile *MeshTile) {
+				l := tile.Polys[0].FirstLink
+				tile.Links[l].Next = l
+			},
+			"loop in link list",
+		},
+		{
+			"non reciprocal link",
+			func(mesh *NavMesh, tile *MeshTile) {
+				// drop the links of the first neighbour of polygon 0
+				l := tile.Polys[0].FirstLink
+				var nt *MeshTile
+				var np *Poly
+				mesh.TileAndPolyByRefUnsafe(tile.Links[l].Ref, &nt, &np)
+				np.FirstLink = nullLink
+			},
+			"not reciprocal",
+		},
+		{
+			"bv tree polygon",
+			func(mesh *NavMesh, tile *MeshTile) {
+				for i := range tile.BvTree {
+					if tile.BvTree[i].I >= 0 {
+						tile.BvTree[i].I = tile.Header.PolyCount
+						return
+					}
+				}
+			},
+			"not in the bv tree",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mesh, err := loadTestNavMesh("mesh1.bin")
+			checkt(t, err)
+			tt.corrupt(mesh, mesh.TileAt(0, 0, 0))
+
+			issues, err := ValidateNavMesh(mesh)
+			checkt(t, err)
+			found := false
+			for _, is := range issues {
+				found = found || strings.Contains(is.String(), tt.want)
+			}
+			if !found {
+				t.Errorf("want an issue containing %q, got %v", tt.want, issues)
+			}
+		})
+	}
+}
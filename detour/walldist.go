@@ -0,0 +1,444 @@
+package detour
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"unsafe"
+
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+// FindDistanceToWall finds the distance from the specified position to the
+// nearest polygon wall.
+//
+//	Arguments:
+//	 startRef   The reference id of the polygon containing centerPos.
+//	 centerPos  The center of the search circle. [(x, y, z)]
+//	 maxRadius  The radius of the search circle. [Limit: >= 0]
+//	 filter     The polygon filter to apply to the query.
+//
+//	Return values:
+//	 hitDist    The distance to the nearest wall from centerPos, maxRadius if
+//	            there is no wall within the search circle.
+//	 hitPos     The nearest position on the wall that was hit, nil if there is
+//	            no wall within the search circle. [(x, y, z)]
+//	 hitNormal  The normalized ray formed from the wall point to the source
+//	            point, nil if there is no wall within the search circle.
+//	            [(x, y, z)]
+//	 st         The status flags for the query.
+//
+// The search is a Dijkstra expansion of the polygons touched by the search
+// circle, which is shrunk to the nearest wall found so far. The distances are
+// measured in the xz-plane.
+//
+// A polygon edge is a wall if there is no neighbour polygon on the other side,
+// or if the neighbour polygon doesn't pass the filter.
+//
+// Note: this method uses the node pool of q, it may not be used by multiple
+// clients at the same time.
+//
+// see BakeWallDistances
+func (q *NavMeshQuery) FindDistanceToWall(startRef PolyRef, centerPos d3.Vec3,
+	maxRadius float32, filter QueryFilter) (hitDist float32, hitPos, hitNormal d3.Vec3, st Status) {
+
+	// Validate input
+	if !q.nav.IsValidPolyRef(startRef) || !isValidVec3(centerPos) ||
+		!(maxRadius >= 0) || math32.IsInf(maxRadius, 0) || filter == nil {
+		return 0, nil, nil, Failure | InvalidParam
+	}
+
+	q.nodePool.Clear()
+	q.openList.clear()
+
+	startNode := q.nodePool.Node(startRef, 0)
+	startNode.Pos.Assign(centerPos)
+	startNode.PIdx = 0
+	startNode.Cost = 0
+	startNode.Total = 0
+	startNode.ID = startRef
+	startNode.Flags = nodeOpen
+	q.openList.push(startNode)
+
+	radiusSqr := math32.Sqr(maxRadius)
+	st = Success
+
+	var (
+		bestTile, neiTile *MeshTile
+		bestPoly, neiPoly *Poly
+		tseg              float32
+	)
+	for !q.openList.empty() {
+		bestNode := q.openList.pop()
+		bestNode.Flags &= ^nodeOpen
+		bestNode.Flags |= nodeClosed
+
+		// The API input has been checked already, skip checking internal data.
+		bestRef := bestNode.ID
+		q.nav.TileAndPolyByRefUnsafe(bestRef, &bestTile, &bestPoly)
+
+		var parentRef PolyRef
+		if bestNode.PIdx != 0 {
+			parentRef = q.nodePool.NodeAtIdx(int32(bestNode.PIdx)).ID
+		}
+
+		// Hit test walls.
+		for i, j := 0, int(bestPoly.VertCount)-1; i < int(bestPoly.VertCount); j, i = i, i+1 {
+			// Skip non-solid edges.
+			if bestPoly.Neis[j]&extLink != 0 {
+				// Tile border.
+				solid := true
+				for k := bestPoly.FirstLink; k != nullLink; k = bestTile.Links[k].Next {
+					link := &bestTile.Links[k]
+					if int(link.Edge) != j {
+						continue
+					}
+					if link.Ref != 0 {
+						q.nav.TileAndPolyByRefUnsafe(link.Ref, &neiTile, &neiPoly)
+						if filter.PassFilter(link.Ref, neiTile, neiPoly) {
+							solid = false
+						}
+					}
+					break
+				}
+				if !solid {
+					continue
+				}
+			} else if bestPoly.Neis[j] != 0 {
+				// Internal edge
+				idx := uint32(bestPoly.Neis[j] - 1)
+				ref := q.nav.polyRefBase(bestTile) | PolyRef(idx)
+				if filter.PassFilter(ref, bestTile, &bestTile.Polys[idx]) {
+					continue
+				}
+			}
+
+			// Calc distance to the edge.
+			vj := d3.Vec3(bestTile.Verts[int(bestPoly.Verts[j])*3 : int(bestPoly.Verts[j])*3+3])
+			vi := d3.Vec3(bestTile.Verts[int(bestPoly.Verts[i])*3 : int(bestPoly.Verts[i])*3+3])
+			distSqr := distancePtSegSqr2D(centerPos, vj, vi, &tseg)
+
+			// Edge is too far, skip.
+			if distSqr > radiusSqr {
+				continue
+			}
+
+			// Hit wall, update radius.
+			radiusSqr = distSqr
+			if hitPos == nil {
+				hitPos = d3.NewVec3()
+			}
+			d3.Vec3Lerp(hitPos, vj, vi, tseg)
+		}
+
+		for i := bestPoly.FirstLink; i != nullLink; i = bestTile.Links[i].Next {
+			link := &bestTile.Links[i]
+			neiRef := link.Ref
+			// Skip invalid neighbours and do not follow back to parent.
+			if neiRef == 0 || neiRef == parentRef {
+				continue
+			}
+
+			q.nav.TileAndPolyByRefUnsafe(neiRef, &neiTile, &neiPoly)
+
+			// Skip off-mesh connections.
+			if neiPoly.Type() == polyTypeOffMeshConnection {
+				continue
+			}
+
+			// Calc distance to the edge.
+			va := d3.Vec3(bestTile.Verts[int(bestPoly.Verts[link.Edge])*3:])
+			vb := d3.Vec3(bestTile.Verts[int(bestPoly.Verts[(int(link.Edge)+1)%int(bestPoly.VertCount)])*3:])
+			distSqr := distancePtSegSqr2D(centerPos, va, vb, &tseg)
+
+			// If the circle is not touching the next polygon, skip it.
+			if distSqr > radiusSqr {
+				continue
+			}
+
+			if !filter.PassFilter(neiRef, neiTile, neiPoly) {
+				continue
+			}
+
+			neiNode := q.nodePool.Node(neiRef, 0)
+			if neiNode == nil {
+				st |= OutOfNodes
+				continue
+			}
+			if neiNode.Flags&nodeClosed != 0 {
+				continue
+			}
+
+			// Cost
+			if neiNode.Flags == 0 {
+				q.edgeMidPoint(bestRef, bestPoly, bestTile, neiRef, neiPoly, neiTile, neiNode.Pos)
+			}
+			total := bestNode.Total + bestNode.Pos.Dist(neiNode.Pos)
+
+			// The node is already in open list and the new result is worse, skip.
+			if neiNode.Flags&nodeOpen != 0 && total >= neiNode.Total {
+				continue
+			}
+
+			neiNode.ID = neiRef
+			neiNode.Flags &= ^nodeClosed
+			neiNode.PIdx = q.nodePool.NodeIdx(bestNode)
+			neiNode.Total = total
+
+			if neiNode.Flags&nodeOpen != 0 {
+				q.openList.modify(neiNode)
+			} else {
+				neiNode.Flags |= nodeOpen
+				q.openList.push(neiNode)
+			}
+		}
+	}
+
+	hitDist = math32.Sqrt(radiusSqr)
+	if hitPos != nil {
+		// Calc hit normal.
+		hitNormal = centerPos.Sub(hitPos)
+		hitNormal.Normalize()
+	}
+	return hitDist, hitPos, hitNormal, st
+}
+
+// BakeWallDistances computes the distance to the nearest wall of each polygon
+// of all the tiles of the navigation mesh, so that it can be used at query time
+// without searching the walls. (See NavMesh.WallDistance and
+// WallAvoidanceFilter)
+//
+//	Arguments:
+//	 q          The query object used to search the walls.
+//	 maxRadius  The maximum distance to search for walls, the distance of the
+//	            polygons that are further from any wall is maxRadius.
+//	 filter     The polygon filter defining the walls.
+//
+//	Return values:
+//	 st         The status flags, OutOfNodes if the search of a polygon wall
+//	            ran out of nodes, in which case its distance may be too large.
+//
+// The distance of a polygon is the distance from its center to the nearest
+// wall. The distance of the off-mesh connections, and of the polygons rejected
+// by the filter, is 0.
+//
+// The walls on tile borders depend on the neighbour tiles, so the distances
+// should be baked once all the tiles are added. The distances are dropped when
+// a tile is removed, and aren't part of the tile data, they're stored
+// separately with NavMesh.EncodeWallDistances.
+//
+// see BakeTileWallDistances
+func BakeWallDistances(q *NavMeshQuery, maxRadius float32, filter QueryFilter) (st Status) {
+	st = Success
+	q.nav.EachTile(func(ref TileRef, tile *MeshTile) bool {
+		st |= BakeTileWallDistances(q, ref, maxRadius, filter)
+		return !StatusFailed(st)
+	})
+	return st
+}
+
+// BakeTileWallDistances computes the distance to the nearest wall of each
+// polygon of a tile, like BakeWallDistances does for all the tiles.
+//
+// This is meant for tiles added after the others, their neighbours should be
+// baked again as their border walls may have changed.
+func BakeTileWallDistances(q *NavMeshQuery, ref TileRef, maxRadius float32, filter QueryFilter) (st Status) {
+	tile := q.nav.TileByRef(ref)
+	if tile == nil || tile.Header == nil || filter == nil ||
+		!(maxRadius >= 0) || math32.IsInf(maxRadius, 0) {
+		return Failure | InvalidParam
+	}
+
+	dists := make([]float32, tile.Header.PolyCount)
+	base := q.nav.polyRefBase(tile)
+	st = Success
+	for i := range dists {
+		poly := &tile.Polys[i]
+		pref := base | PolyRef(i)
+		if poly.Type() == polyTypeOffMeshConnection || !filter.PassFilter(pref, tile, poly) {
+			continue
+		}
+		center := CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts)
+		dist, _, _, fst := q.FindDistanceToWall(pref, center, maxRadius, filter)
+		if StatusFailed(fst) {
+			return fst
+		}
+		st |= fst & OutOfNodes
+		dists[i] = dist
+	}
+	tile.WallDistances = dists
+	return st
+}
+
+// WallDistance returns the distance to the nearest wall of a polygon, as baked
+// by BakeWallDistances.
+//
+// st is Failure if the distances of the polygon tile have not been baked, and
+// Failure|InvalidParam if ref is not valid.
+func (m *NavMesh) WallDistance(ref PolyRef) (dist float32, st Status) {
+	var (
+		tile *MeshTile
+		poly *Poly
+	)
+	if st = m.TileAndPolyByRef(ref, &tile, &poly); StatusFailed(st) {
+		return 0, st
+	}
+	if tile.WallDistances == nil {
+		return 0, Failure
+	}
+	var salt, it, ip uint32
+	m.DecodePolyID(ref, &salt, &it, &ip)
+	return tile.WallDistances[ip], Success
+}
+
+// WallAvoidanceFilter is a QueryFilter that raises the traversal costs of
+// another filter in the polygons close to walls, using the distances baked
+// with BakeWallDistances.
+//
+// The cost of a polygon which wall distance is d, less than Radius, is
+// multiplied by 1 + Weight*(1 - d/Radius), so it's raised up to 1+Weight times
+// along the walls. The costs of the polygons of the tiles without baked
+// distances are not modified.
+//
+// see QueryFilter, BakeWallDistances
+type WallAvoidanceFilter struct {
+	// The filter which costs are raised.
+	QueryFilter
+
+	Radius float32 // Distance to the walls under which costs are raised.
+	Weight float32 // Additional cost multiplier along the walls.
+}
+
+// NewWallAvoidanceFilter returns a new wall avoidance filter wrapping the base
+// filter.
+func NewWallAvoidanceFilter(base QueryFilter, radius, weight float32) *WallAvoidanceFilter {
+	return &WallAvoidanceFilter{
+		QueryFilter: base,
+		Radius:      radius,
+		Weight:      weight,
+	}
+}
+
+// Cost returns cost to move from the beginning to the end of a line segment
+// that is fully contained within a polygon.
+//
+// It is the cost returned by the wrapped filter, raised if the current polygon
+// is close to a wall.
+//
+// see QueryFilter.Cost
+func (f *WallAvoidanceFilter) Cost(pa, pb d3.Vec3,
+	prevRef PolyRef, prevTile *MeshTile, prevPoly *Poly,
+	curRef PolyRef, curTile *MeshTile, curPoly *Poly,
+	nextRef PolyRef, nextTile *MeshTile, nextPoly *Poly) float32 {
+
+	cost := f.QueryFilter.Cost(pa, pb,
+		prevRef, prevTile, prevPoly,
+		curRef, curTile, curPoly,
+		nextRef, nextTile, nextPoly)
+	if curTile == nil || curTile.WallDistances == nil || !(f.Radius > 0) {
+		return cost
+	}
+	// The polygon index, from its address in the tile polygons.
+	ip := (uintptr(unsafe.Pointer(curPoly)) - uintptr(unsafe.Pointer(&curTile.Polys[0]))) / unsafe.Sizeof(*curPoly)
+	if d := curTile.WallDistances[ip]; d < f.Radius {
+		cost *= 1 + f.Weight*(1-d/f.Radius)
+	}
+	return cost
+}
+
+// wallDistMagic identifies the wall distances streams.
+const wallDistMagic = 'W'<<24 | 'D'<<16 | 'S'<<8 | 'T'
+
+// wallDistVersion is the version of the wall distances stream format.
+const wallDistVersion = 1
+
+// EncodeWallDistances writes the wall distances baked with BakeWallDistances
+// to w, in the format read by DecodeWallDistances.
+//
+// The distances are written for the tiles that have been baked, identified by
+// their location, so that they can be decoded into another instance of the
+// navigation mesh, or after its tiles have been streamed in.
+func (m *NavMesh) EncodeWallDistances(w io.Writer) error {
+	var ntiles int32
+	m.EachTile(func(_ TileRef, tile *MeshTile) bool {
+		if tile.WallDistances != nil {
+			ntiles++
+		}
+		return true
+	})
+
+	bw := bufio.NewWriter(w)
+	little := binary.LittleEndian
+	for _, v := range []uint32{wallDistMagic, wallDistVersion, uint32(ntiles)} {
+		if err := binary.Write(bw, little, v); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	m.EachTile(func(_ TileRef, tile *MeshTile) bool {
+		if tile.WallDistances == nil {
+			return true
+		}
+		hdr := tile.Header
+		loc := [4]int32{hdr.X, hdr.Y, hdr.Layer, int32(len(tile.WallDistances))}
+		if err = binary.Write(bw, little, loc); err != nil {
+			return false
+		}
+		err = binary.Write(bw, little, tile.WallDistances)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// DecodeWallDistances reads wall distances written by EncodeWallDistances,
+// and sets them on the tiles of the navigation mesh.
+//
+// The distances of the tiles that aren't in the navigation mesh are ignored,
+// an error is returned if a tile doesn't have the number of polygons of the
+// tile the distances have been baked for.
+func (m *NavMesh) DecodeWallDistances(r io.Reader) error {
+	br := bufio.NewReader(r)
+	little := binary.LittleEndian
+
+	var hdr [3]uint32
+	if err := binary.Read(br, little, &hdr); err != nil {
+		return fmt.Errorf("error reading wall distances header: %v", err)
+	}
+	if hdr[0] != wallDistMagic {
+		return fmt.Errorf("wrong magic number: %x", hdr[0])
+	}
+	if hdr[1] != wallDistVersion {
+		return fmt.Errorf("wrong version: %d", hdr[1])
+	}
+
+	for i := uint32(0); i < hdr[2]; i++ {
+		var loc [4]int32
+		if err := binary.Read(br, little, &loc); err != nil {
+			return fmt.Errorf("error reading wall distances of tile %d: %v", i, err)
+		}
+		if loc[3] < 0 || loc[3] > math.MaxUint16 {
+			return fmt.Errorf("tile (%d, %d, %d): invalid polygon count %d", loc[0], loc[1], loc[2], loc[3])
+		}
+		dists := make([]float32, loc[3])
+		if err := binary.Read(br, little, dists); err != nil {
+			return fmt.Errorf("error reading wall distances of tile %d: %v", i, err)
+		}
+		tile := m.TileAt(loc[0], loc[1], loc[2])
+		if tile == nil {
+			continue
+		}
+		if tile.Header.PolyCount != loc[3] {
+			return fmt.Errorf("tile (%d, %d, %d) has %d polygons, wall distances are for %d",
+				loc[0], loc[1], loc[2], tile.Header.PolyCount, loc[3])
+		}
+		tile.WallDistances = dists
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package detour
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+	"github.com/arl/math32"
+)
+
+// nearestWall returns the 2D distance from pos to the nearest wall segment of
+// all the polygons of the navigation mesh.
+func nearestWall(t *testing.T, q *NavMeshQuery, pos d3.Vec3, filter QueryFilter) float32 {
+	nearest := float32(math.MaxFloat32)
+	segs := make([]float32, 6*VertsPerPolygon*4)
+	for _, ref := range allPolyRefs(q.nav) {
+		n, st := q.PolyWallSegments(ref, filter, segs, nil)
+		if StatusFailed(st) {
+			t.Fatalf("PolyWallSegments(0x%x) failed with status 0x%x", ref, st)
+		}
+		for i := 0; i < n; i++ {
+			var tseg float32
+			d := math32.Sqrt(distancePtSegSqr2D(pos, segs[i*6:i*6+3], segs[i*6+3:i*6+6], &tseg))
+			if d < nearest {
+				nearest = d
+			}
+		}
+	}
+	return nearest
+}
+
+func TestFindDistanceToWall(t *testing.T) {
+	for _, fname := range []string{"fixture/plane.bin", "fixture/donut.bin", "fixture/tiled.bin"} {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+		_, q := NewNavMeshQuery(mesh, 2048)
+		filter := NewStandardQueryFilter()
+
+		for _, ref := range allPolyRefs(mesh) {
+			var (
+				tile *MeshTile
+				poly *Poly
+			)
+			mesh.TileAndPolyByRefUnsafe(ref, &tile, &poly)
+			if poly.Type() == polyTypeOffMeshConnection {
+				continue
+			}
+			center := CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts)
+			dist, hitPos, hitNormal, st := q.FindDistanceToWall(ref, center, 100, filter)
+			if StatusFailed(st) {
+				t.Fatalf("%s: FindDistanceToWall(0x%x) failed with status 0x%x", fname, ref, st)
+			}
+			if want := nearestWall(t, q, center, filter); math32.Abs(dist-want) > 1e-3 {
+				t.Errorf("%s: polygon 0x%x: distance to wall = %f, want %f", fname, ref, dist, want)
+			}
+			if hitPos == nil || hitNormal == nil {
+				t.Fatalf("%s: polygon 0x%x: no wall hit", fname, ref)
+			}
+			if d := math32.Sqrt(math32.Sqr(center[0]-hitPos[0]) + math32.Sqr(center[2]-hitPos[2])); math32.Abs(d-dist) > 1e-3 {
+				t.Errorf("%s: polygon 0x%x: hit position %v is %f away, want %f", fname, ref, hitPos, d, dist)
+			}
+
+			// a search circle not reaching any wall
+			if dist > 0 {
+				radius := dist / 2
+				dist, hitPos, _, _ = q.FindDistanceToWall(ref, center, radius, filter)
+				if hitPos != nil || math32.Abs(dist-radius) > 1e-5 {
+					t.Errorf("%s: polygon 0x%x: got hit %v at %f within a circle not reaching walls", fname, ref, hitPos, dist)
+				}
+			}
+		}
+	}
+
+	mesh, err := loadTestNavMesh("fixture/plane.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 100)
+	if _, _, _, st := q.FindDistanceToWall(allPolyRefs(mesh)[0], d3.Vec3{5, 0, 5}, -1, NewStandardQueryFilter()); st != Failure|InvalidParam {
+		t.Errorf("negative radius: got status 0x%x, want Failure|InvalidParam", st)
+	}
+}
+
+func TestBakeWallDistances(t *testing.T) {
+	mesh, err := loadTestNavMesh("fixture/tiled.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 2048)
+	filter := NewStandardQueryFilter()
+
+	refs := allPolyRefs(mesh)
+	if _, st := mesh.WallDistance(refs[0]); st != Failure {
+		t.Fatalf("WallDistance before baking: got status 0x%x, want Failure", st)
+	}
+
+	const maxRadius = 3
+	if st := BakeWallDistances(q, maxRadius, filter); st != Success {
+		t.Fatalf("BakeWallDistances returned status 0x%x", st)
+	}
+	for _, ref := range refs {
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		mesh.TileAndPolyByRefUnsafe(ref, &tile, &poly)
+		dist, st := mesh.WallDistance(ref)
+		if StatusFailed(st) {
+			t.Fatalf("WallDistance(0x%x) failed with status 0x%x", ref, st)
+		}
+		want := float32(0)
+		if poly.Type() != polyTypeOffMeshConnection {
+			center := CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts)
+			want, _, _, _ = q.FindDistanceToWall(ref, center, maxRadius, filter)
+		}
+		if dist != want {
+			t.Errorf("WallDistance(0x%x) = %f, want %f", ref, dist, want)
+		}
+	}
+
+	// Wall distances round trip.
+	var buf bytes.Buffer
+	checkt(t, mesh.EncodeWallDistances(&buf))
+	other, err := loadTestNavMesh("fixture/tiled.bin")
+	checkt(t, err)
+	checkt(t, other.DecodeWallDistances(&buf))
+	for _, ref := range refs {
+		d1, _ := mesh.WallDistance(ref)
+		if d2, st := other.WallDistance(ref); StatusFailed(st) || d1 != d2 {
+			t.Errorf("decoded WallDistance(0x%x) = %f (status 0x%x), want %f", ref, d2, st, d1)
+		}
+	}
+
+	// Distances don't survive tile removal.
+	tile := mesh.Tile(0)
+	if _, st := mesh.RemoveTile(mesh.TileRef(tile)); StatusFailed(st) {
+		t.Fatalf("RemoveTile failed with status 0x%x", st)
+	}
+	if tile.WallDistances != nil {
+		t.Errorf("removed tile still has wall distances")
+	}
+}
+
+func TestWallAvoidanceFilter(t *testing.T) {
+	mesh, err := loadTestNavMesh("fixture/plane.bin")
+	checkt(t, err)
+	_, q := NewNavMeshQuery(mesh, 100)
+	base := NewStandardQueryFilter()
+	if st := BakeWallDistances(q, 10, base); StatusFailed(st) {
+		t.Fatalf("BakeWallDistances returned status 0x%x", st)
+	}
+
+	f := NewWallAvoidanceFilter(base, 10, 2)
+	pa, pb := d3.Vec3{0, 0, 0}, d3.Vec3{1, 0, 0}
+	for _, ref := range allPolyRefs(mesh) {
+		var (
+			tile *MeshTile
+			poly *Poly
+		)
+		mesh.TileAndPolyByRefUnsafe(ref, &tile, &poly)
+		d, _ := mesh.WallDistance(ref)
+		want := base.Cost(pa, pb, 0, nil, nil, ref, tile, poly, 0, nil, nil) * (1 + 2*(1-d/10))
+		if got := f.Cost(pa, pb, 0, nil, nil, ref, tile, poly, 0, nil, nil); math32.Abs(got-want) > 1e-4 {
+			t.Errorf("polygon 0x%x at %f from walls: cost = %f, want %f", ref, d, got, want)
+		}
+	}
+}
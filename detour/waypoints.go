@@ -0,0 +1,175 @@
+package detour
+
+import (
+	"fmt"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// WaypointKind is the kind of a waypoint.
+type WaypointKind uint8
+
+const (
+	// WaypointPoly is a waypoint at the center of a polygon.
+	WaypointPoly WaypointKind = iota
+	// WaypointPortal is a waypoint at the middle of the portal between two
+	// polygons.
+	WaypointPortal
+)
+
+var waypointKindNames = [...]string{"poly", "portal"}
+
+// String returns the name of the waypoint kind.
+func (k WaypointKind) String() string {
+	if int(k) < len(waypointKindNames) {
+		return waypointKindNames[k]
+	}
+	return fmt.Sprintf("WaypointKind(%d)", uint8(k))
+}
+
+// MarshalText implements encoding.TextMarshaler, waypoint kinds are encoded
+// by name.
+func (k WaypointKind) MarshalText() ([]byte, error) {
+	if int(k) >= len(waypointKindNames) {
+		return nil, fmt.Errorf("invalid waypoint kind %d", uint8(k))
+	}
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *WaypointKind) UnmarshalText(text []byte) error {
+	for i, name := range waypointKindNames {
+		if string(text) == name {
+			*k = WaypointKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown waypoint kind '%s'", text)
+}
+
+// Waypoint is a node of a WaypointGraph.
+type Waypoint struct {
+	Kind WaypointKind `json:"kind"`
+	Pos  [3]float32   `json:"pos"`
+
+	// Ref is the reference of the polygon of a poly waypoint, or of one of
+	// the polygons of a portal waypoint, the other one being Nei.
+	Ref PolyRef `json:"ref"`
+	Nei PolyRef `json:"nei,omitempty"`
+}
+
+// WaypointEdge is a directed edge of a WaypointGraph.
+type WaypointEdge struct {
+	From int     `json:"from"` // Index of the start waypoint.
+	To   int     `json:"to"`   // Index of the end waypoint.
+	Cost float32 `json:"cost"` // Cost of the move, as given by the filter.
+}
+
+// WaypointGraph is a coarse graph of the navigation mesh, for the planners,
+// or the machine learning agents, working on graphs rather than on polygons.
+//
+// The waypoints are the centers of the polygons and the middles of the portals
+// between them. Each polygon waypoint is connected to the waypoints of its
+// portals, in both directions, so there's no direct edge between polygons.
+//
+// A WaypointGraph is encoded to JSON with encoding/json.
+type WaypointGraph struct {
+	Nodes []Waypoint     `json:"nodes"`
+	Edges []WaypointEdge `json:"edges"`
+}
+
+// BuildWaypointGraph builds the waypoint graph of the navigation mesh of q.
+//
+//	Arguments:
+//	 q       The query object of the navigation mesh.
+//	 filter  The polygon filter, the polygons it rejects have no waypoint, and
+//	         the filter costs are the costs of the edges.
+//
+//	Return values:
+//	 g       The waypoint graph.
+//	 st      The status flags.
+//
+// The center of an off-mesh connection is the middle of its end points, and
+// its portals are its end points, so that an off-mesh connection is crossed
+// like any other polygon.
+//
+// The cost of an edge is the cost of the segment between its waypoints,
+// within the polygon of the poly waypoint. The waypoints and the edges are
+// ordered by tile and by polygon, so that the graph of a navigation mesh is
+// always the same.
+func BuildWaypointGraph(q *NavMeshQuery, filter QueryFilter) (g *WaypointGraph, st Status) {
+	if filter == nil {
+		return nil, Failure | InvalidParam
+	}
+
+	var (
+		nav      = q.nav
+		polyNode = make(map[PolyRef]int)
+		portals  = make(map[[2]PolyRef]int)
+		mid      = d3.NewVec3()
+	)
+	g = &WaypointGraph{}
+
+	// Polygon waypoints.
+	nav.EachPoly(func(ref PolyRef, poly *Poly, tile *MeshTile) bool {
+		if !filter.PassFilter(ref, tile, poly) {
+			return true
+		}
+		polyNode[ref] = len(g.Nodes)
+		wp := Waypoint{Kind: WaypointPoly, Ref: ref}
+		copy(wp.Pos[:], CalcPolyCenter(poly.Verts[:], int32(poly.VertCount), tile.Verts))
+		g.Nodes = append(g.Nodes, wp)
+		return true
+	})
+
+	// Portal waypoints and edges.
+	var (
+		neiTile *MeshTile
+		neiPoly *Poly
+	)
+	nav.EachPoly(func(ref PolyRef, poly *Poly, tile *MeshTile) bool {
+		from, ok := polyNode[ref]
+		if !ok {
+			return true
+		}
+		center := g.Nodes[from].Pos
+		for i := poly.FirstLink; i != nullLink; i = tile.Links[i].Next {
+			neiRef := tile.Links[i].Ref
+			if _, ok := polyNode[neiRef]; !ok {
+				continue
+			}
+
+			key := [2]PolyRef{ref, neiRef}
+			if neiRef < ref {
+				key = [2]PolyRef{neiRef, ref}
+			}
+			nav.TileAndPolyByRefUnsafe(neiRef, &neiTile, &neiPoly)
+			to, ok := portals[key]
+			if !ok {
+				if StatusFailed(q.edgeMidPoint(ref, poly, tile, neiRef, neiPoly, neiTile, mid)) {
+					continue
+				}
+				to = len(g.Nodes)
+				portals[key] = to
+				wp := Waypoint{Kind: WaypointPortal, Ref: ref, Nei: neiRef}
+				copy(wp.Pos[:], mid)
+				g.Nodes = append(g.Nodes, wp)
+			}
+
+			pos := g.Nodes[to].Pos
+			g.Edges = append(g.Edges,
+				WaypointEdge{
+					From: from,
+					To:   to,
+					Cost: filter.Cost(center[:], pos[:], 0, nil, nil, ref, tile, poly, neiRef, neiTile, neiPoly),
+				},
+				WaypointEdge{
+					From: to,
+					To:   from,
+					Cost: filter.Cost(pos[:], center[:], neiRef, neiTile, neiPoly, ref, tile, poly, 0, nil, nil),
+				})
+		}
+		return true
+	})
+	return g, Success
+}
@@ -0,0 +1,87 @@
+package detour
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestBuildWaypointGraph(t *testing.T) {
+	for _, fname := range []string{"mesh1.bin", "offmeshcons.bin", "fixture/tiled.bin"} {
+		mesh, err := loadTestNavMesh(fname)
+		checkt(t, err)
+		_, q := NewNavMeshQuery(mesh, 100)
+		filter := NewStandardQueryFilter()
+
+		g, st := BuildWaypointGraph(q, filter)
+		if StatusFailed(st) {
+			t.Fatalf("%s: BuildWaypointGraph failed with status 0x%x", fname, st)
+		}
+
+		refs := allPolyRefs(mesh)
+		polyNode := make(map[PolyRef]int)
+		for i, wp := range g.Nodes {
+			if wp.Kind == WaypointPoly {
+				polyNode[wp.Ref] = i
+			}
+		}
+		if len(polyNode) != len(refs) {
+			t.Fatalf("%s: got %d polygon waypoints, want %d", fname, len(polyNode), len(refs))
+		}
+
+		// Each portal waypoint is connected to its 2 polygons, both ways.
+		adj := make(map[int][]int)
+		for _, e := range g.Edges {
+			if e.From < 0 || e.From >= len(g.Nodes) || e.To < 0 || e.To >= len(g.Nodes) {
+				t.Fatalf("%s: edge %+v out of range", fname, e)
+			}
+			if !(e.Cost >= 0) {
+				t.Errorf("%s: edge %+v has a negative cost", fname, e)
+			}
+			adj[e.From] = append(adj[e.From], e.To)
+		}
+		for i, wp := range g.Nodes {
+			if wp.Kind != WaypointPortal {
+				continue
+			}
+			want := map[int]bool{polyNode[wp.Ref]: true, polyNode[wp.Nei]: true}
+			for _, j := range adj[i] {
+				if !want[j] {
+					t.Errorf("%s: portal %d between 0x%x and 0x%x leads to waypoint %d", fname, i, wp.Ref, wp.Nei, j)
+				}
+			}
+			if len(adj[i]) < 2 {
+				t.Errorf("%s: portal %d has %d edges, want 2", fname, i, len(adj[i]))
+			}
+		}
+
+		// The graph connects the same polygons as the navigation mesh.
+		reached, _ := ReachablePolys(mesh, refs[:1], nil)
+		visited := map[int]bool{polyNode[refs[0]]: true}
+		stack := []int{polyNode[refs[0]]}
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, j := range adj[i] {
+				if !visited[j] {
+					visited[j] = true
+					stack = append(stack, j)
+				}
+			}
+		}
+		for ref, i := range polyNode {
+			if visited[i] != reached[ref] {
+				t.Errorf("%s: polygon 0x%x reached in graph: %v, in navmesh: %v", fname, ref, visited[i], reached[ref])
+			}
+		}
+
+		// JSON round trip.
+		buf, err := json.Marshal(g)
+		checkt(t, err)
+		var g2 WaypointGraph
+		checkt(t, json.Unmarshal(buf, &g2))
+		if !reflect.DeepEqual(*g, g2) {
+			t.Errorf("%s: graph changed after a JSON round trip", fname)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package detour
+
+import (
+	"math"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+// WorldLink is a connection between two navigation meshes of a World, like
+// an off-mesh connection whose end points live on different meshes. (E.g. a
+// door between a building interior and the terrain baked separately.)
+type WorldLink struct {
+	FromMesh, ToMesh int        // The indices of the meshes of the end points.
+	FromRef, ToRef   PolyRef    // The polygons of the end points.
+	FromPos, ToPos   [3]float32 // The end points. [(x, y, z)]
+	Cost             float32    // The cost of traversing the link.
+	Bidirectional    bool       // The link can be traversed both ways.
+}
+
+// WorldPathSegment is the part of a World path on a single navigation mesh.
+type WorldPathSegment struct {
+	Mesh     int        // The index of the mesh.
+	Path     []PolyRef  // The polygon corridor on the mesh. (Start to end.)
+	StartPos [3]float32 // The start position of the segment. [(x, y, z)]
+	EndPos   [3]float32 // The end position of the segment. [(x, y, z)]
+
+	// The index of the link taken at the end of the segment, to reach the
+	// next one. -1 for the last segment.
+	Link int
+}
+
+// World holds several navigation meshes connected by links, and finds paths
+// spanning them.
+//
+// Each mesh keeps its own polygon references and queries, a path on a World
+// is a sequence of corridors, one per mesh crossed, connected by links.
+type World struct {
+	meshes  []*NavMesh
+	queries []*NavMeshQuery
+	links   []WorldLink
+}
+
+// NewWorld returns an empty world.
+func NewWorld() *World {
+	return &World{}
+}
+
+// AddMesh adds a navigation mesh to the world.
+//
+//	Arguments:
+//	 mesh      The navigation mesh.
+//	 maxNodes  The maximum number of search nodes of the queries on the mesh.
+//	           [Limits: 0 < value <= 65535]
+//
+//	Return values:
+//	 idx       The index of the mesh in the world.
+//	 st        The status flags.
+func (w *World) AddMesh(mesh *NavMesh, maxNodes int32) (idx int, st Status) {
+	if mesh == nil {
+		return -1, Failure | InvalidParam
+	}
+	st, q := NewNavMeshQuery(mesh, maxNodes)
+	if StatusFailed(st) {
+		return -1, st
+	}
+	w.meshes = append(w.meshes, mesh)
+	w.queries = append(w.queries, q)
+	return len(w.meshes) - 1, Success
+}
+
+// Mesh returns the navigation mesh at index idx.
+func (w *World) Mesh(idx int) *NavMesh {
+	return w.meshes[idx]
+}
+
+// Query returns the query on the navigation mesh at index idx.
+func (w *World) Query(idx int) *NavMeshQuery {
+	return w.queries[idx]
+}
+
+// MeshCount returns the number of navigation meshes.
+func (w *World) MeshCount() int {
+	return len(w.meshes)
+}
+
+// AddLink connects two meshes, or two places of the same mesh.
+//
+//	Arguments:
+//	 fromMesh  The index of the mesh of the start point.
+//	 fromPos   The start point. [(x, y, z)]
+//	 toMesh    The index of the mesh of the end point.
+//	 toPos     The end point. [(x, y, z)]
+//	 extents   The search distance along each axis to snap the end points
+//	           to their mesh. [(x, y, z)]
+//	 cost      The cost of traversing the link. If negative, the distance
+//	           between the end points is used.
+//	 bidir     Whether the link can be traversed both ways.
+//	 filter    The polygon filter used to snap the end points.
+//
+//	Return values:
+//	 idx       The index of the link.
+//	 st        The status flags, Failure if an end point isn't near its mesh.
+func (w *World) AddLink(fromMesh int, fromPos d3.Vec3, toMesh int, toPos d3.Vec3,
+	extents d3.Vec3, cost float32, bidir bool, filter QueryFilter) (idx int, st Status) {
+
+	if fromMesh < 0 || fromMesh >= len(w.meshes) || toMesh < 0 || toMesh >= len(w.meshes) {
+		return -1, Failure | InvalidParam
+	}
+
+	l := WorldLink{FromMesh: fromMesh, ToMesh: toMesh, Bidirectional: bidir}
+	var pt d3.Vec3
+	if st, l.FromRef, pt = w.queries[fromMesh].FindNearestPoly(fromPos, extents, filter); StatusFailed(st) || l.FromRef == 0 {
+		return -1, Failure | InvalidParam
+	}
+	copy(l.FromPos[:], pt)
+	if st, l.ToRef, pt = w.queries[toMesh].FindNearestPoly(toPos, extents, filter); StatusFailed(st) || l.ToRef == 0 {
+		return -1, Failure | InvalidParam
+	}
+	copy(l.ToPos[:], pt)
+
+	l.Cost = cost
+	if cost < 0 {
+		l.Cost = d3.Vec3(l.FromPos[:]).Dist(l.ToPos[:])
+	}
+	w.links = append(w.links, l)
+	return len(w.links) - 1, Success
+}
+
+// Links returns the links of the world, indexed by link index.
+func (w *World) Links() []WorldLink {
+	return w.links
+}
+
+// maxWorldStraightPath is the maximum number of points of the straight paths
+// used to measure the cost of the paths on a mesh.
+const maxWorldStraightPath = 256
+
+// worldNode is a node of the high level graph searched by World.FindPath: the
+// start or end of the path, or an end point of a link.
+type worldNode struct {
+	mesh int
+	ref  PolyRef
+	pos  [3]float32
+}
+
+// worldLeg is a path between 2 nodes of the same mesh.
+type worldLeg struct {
+	path []PolyRef
+	cost float32
+	ok   bool
+}
+
+// FindPath finds a path from a start position on a mesh to an end position,
+// maybe on another mesh.
+//
+//	Arguments:
+//	 startMesh  The index of the mesh of the start position.
+//	 startRef   The reference id of the start polygon.
+//	 startPos   A position within the start polygon. [(x, y, z)]
+//	 endMesh    The index of the mesh of the end position.
+//	 endRef     The reference id of the end polygon.
+//	 endPos     A position within the end polygon. [(x, y, z)]
+//	 filter     The polygon filter to apply to the query.
+//	 maxPath    The maximum number of polygons of each segment.
+//
+//	Return values:
+//	 segments   The path, one segment per mesh crossed, in order.
+//	 st         The status flags for the query.
+//
+// The search first finds the sequence of links to take with a Dijkstra search
+// on the graph made of the start and end positions and of the end points of
+// the links, then the segments between them. The cost of a segment is the
+// length of its straight path, the cost of a link is WorldLink.Cost.
+//
+// The segments are found with FindPath on each mesh, the same polygon can't
+// be used twice on a segment, but may be used on different segments. The
+// straight path of a segment can be found with the query of its mesh, from
+// StartPos to EndPos.
+//
+// Note: this method uses the node pools of the queries of the meshes, it may
+// not be used by multiple clients at the same time.
+func (w *World) FindPath(
+	startMesh int, startRef PolyRef, startPos d3.Vec3,
+	endMesh int, endRef PolyRef, endPos d3.Vec3,
+	filter QueryFilter, maxPath int) (segments []WorldPathSegment, st Status) {
+
+	if startMesh < 0 || startMesh >= len(w.meshes) || endMesh < 0 || endMesh >= len(w.meshes) ||
+		!w.meshes[startMesh].IsValidPolyRef(startRef) || !w.meshes[endMesh].IsValidPolyRef(endRef) ||
+		!isValidVec3(startPos) || !isValidVec3(endPos) || filter == nil || maxPath <= 0 {
+		return nil, Failure | InvalidParam
+	}
+
+	// Graph nodes: 0 is the start, 1 the end, then 2 nodes per link: its
+	// start and its end.
+	nodes := make([]worldNode, 2+2*len(w.links))
+	nodes[0] = worldNode{mesh: startMesh, ref: startRef}
+	copy(nodes[0].pos[:], startPos)
+	nodes[1] = worldNode{mesh: endMesh, ref: endRef}
+	copy(nodes[1].pos[:], endPos)
+	for i, l := range w.links {
+		nodes[2+2*i] = worldNode{mesh: l.FromMesh, ref: l.FromRef, pos: l.FromPos}
+		nodes[3+2*i] = worldNode{mesh: l.ToMesh, ref: l.ToRef, pos: l.ToPos}
+	}
+
+	// departure reports whether the search can leave node i through a link,
+	// or end there.
+	departure := func(i int) bool {
+		if i == 1 {
+			return true
+		}
+		if i < 2 {
+			return false
+		}
+		return i%2 == 0 || w.links[(i-2)/2].Bidirectional
+	}
+
+	legs := make(map[[2]int]worldLeg)
+	pathBuf := make([]PolyRef, maxPath)
+	var straight [maxWorldStraightPath * 3]float32
+	leg := func(from, to int) worldLeg {
+		key := [2]int{from, to}
+		if l, ok := legs[key]; ok {
+			return l
+		}
+		var l worldLeg
+		a, b := &nodes[from], &nodes[to]
+		q := w.queries[a.mesh]
+		n, st := q.FindPath(a.ref, b.ref, a.pos[:], b.pos[:], filter, pathBuf)
+		if !StatusFailed(st) && n > 0 && pathBuf[n-1] == b.ref {
+			l.ok = true
+			l.path = append([]PolyRef(nil), pathBuf[:n]...)
+			nstraight, _ := q.FindStraightPathFlat(a.pos[:], b.pos[:], l.path, straight[:], nil, nil, 0)
+			for k := 1; k < nstraight; k++ {
+				l.cost += d3.Vec3(straight[(k-1)*3 : k*3]).Dist(straight[k*3 : k*3+3])
+			}
+		}
+		legs[key] = l
+		return l
+	}
+
+	// Dijkstra search, the graph is small.
+	var (
+		cost    = make([]float32, len(nodes))
+		prev    = make([]int, len(nodes))
+		viaLink = make([]bool, len(nodes)) // the node is reached by a link
+		closed  = make([]bool, len(nodes))
+	)
+	for i := range cost {
+		cost[i] = math.MaxFloat32
+		prev[i] = -1
+	}
+	cost[0] = 0
+
+	for {
+		// Pop the open node with the lowest cost.
+		cur := -1
+		for i := range nodes {
+			if !closed[i] && cost[i] < math.MaxFloat32 && (cur == -1 || cost[i] < cost[cur]) {
+				cur = i
+			}
+		}
+		if cur == -1 || cur == 1 {
+			break
+		}
+		closed[cur] = true
+
+		if cur != 0 && !viaLink[cur] {
+			// The node has been reached by a path, to leave through its link.
+			li, other := (cur-2)/2, cur^1
+			if c := cost[cur] + w.links[li].Cost; c < cost[other] {
+				cost[other], prev[other], viaLink[other] = c, cur, true
+			}
+			continue
+		}
+
+		// Paths on the mesh of the node, to the nodes the search can leave
+		// from.
+		for i := range nodes {
+			if i == cur || closed[i] || nodes[i].mesh != nodes[cur].mesh || !departure(i) {
+				continue
+			}
+			l := leg(cur, i)
+			if !l.ok {
+				continue
+			}
+			if c := cost[cur] + l.cost; c < cost[i] {
+				cost[i], prev[i], viaLink[i] = c, cur, false
+			}
+		}
+	}
+
+	if prev[1] == -1 {
+		return nil, Failure
+	}
+
+	// Reconstruct the node sequence, from the end.
+	var seq []int
+	for i := 1; i != -1; i = prev[i] {
+		seq = append(seq, i)
+	}
+	for i, j := 0, len(seq)-1; i < j; i, j = i+1, j-1 {
+		seq[i], seq[j] = seq[j], seq[i]
+	}
+
+	// Make a segment for each path between 2 nodes, the other steps are links.
+	for k := 1; k < len(seq); k++ {
+		from, to := seq[k-1], seq[k]
+		if viaLink[to] {
+			segments[len(segments)-1].Link = (from - 2) / 2
+			continue
+		}
+		segments = append(segments, WorldPathSegment{
+			Mesh:     nodes[from].mesh,
+			Path:     legs[[2]int{from, to}].path,
+			StartPos: nodes[from].pos,
+			EndPos:   nodes[to].pos,
+			Link:     -1,
+		})
+	}
+	return segments, Success
+}
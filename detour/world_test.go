@@ -0,0 +1,83 @@
+package detour
+
+import (
+	"testing"
+
+	"github.com/arl/gogeo/f32/d3"
+)
+
+func TestWorldFindPath(t *testing.T) {
+	// Two copies of the same navmesh, as if they had been baked separately.
+	w := NewWorld()
+	for i := 0; i < 2; i++ {
+		mesh, err := loadTestNavMesh("mesh1.bin")
+		checkt(t, err)
+		if idx, st := w.AddMesh(mesh, 2048); StatusFailed(st) || idx != i {
+			t.Fatalf("AddMesh returned (%d, %v)", idx, st)
+		}
+	}
+
+	filter := NewStandardQueryFilter()
+	extents := d3.NewVec3XYZ(2, 4, 2)
+	// org and dst are connected on mesh1.bin
+	org := d3.Vec3{37.298489, -1.776901, 11.652311}
+	dst := d3.Vec3{42.457218, 7.797607, 17.778244}
+	_, orgRef, org := w.Query(0).FindNearestPoly(org, extents, filter)
+	_, dstRef, dst := w.Query(1).FindNearestPoly(dst, extents, filter)
+
+	// Without links, meshes aren't connected.
+	if _, st := w.FindPath(0, orgRef, org, 1, dstRef, dst, filter, 256); !StatusFailed(st) {
+		t.Fatalf("FindPath between unconnected meshes returned %v, want Failure", st)
+	}
+
+	// On a single mesh, the path is the one of the mesh.
+	want := make([]PolyRef, 256)
+	nwant, _ := w.Query(0).FindPath(orgRef, dstRef, org, dst, filter, want)
+	segs, st := w.FindPath(0, orgRef, org, 0, dstRef, dst, filter, 256)
+	if StatusFailed(st) || len(segs) != 1 || segs[0].Mesh != 0 || segs[0].Link != -1 ||
+		!equalRefs(segs[0].Path, want[:nwant]) {
+		t.Fatalf("FindPath on one mesh = (%+v, %v), want path %v", segs, st, want[:nwant])
+	}
+
+	// Link the middle of the path on mesh 0 to the same place on mesh 1.
+	mid, _ := w.Query(0).PolyCentroid(want[nwant/2])
+	link, st := w.AddLink(0, mid, 1, mid, extents, -1, false, filter)
+	if StatusFailed(st) || link != 0 {
+		t.Fatalf("AddLink returned (%d, %v)", link, st)
+	}
+	if l := w.Links()[0]; l.Cost != 0 || l.FromRef != want[nwant/2] || l.ToRef != want[nwant/2] {
+		t.Fatalf("unexpected link %+v", l)
+	}
+
+	segs, st = w.FindPath(0, orgRef, org, 1, dstRef, dst, filter, 256)
+	if StatusFailed(st) || len(segs) != 2 {
+		t.Fatalf("FindPath across meshes = (%+v, %v), want 2 segments", segs, st)
+	}
+	if segs[0].Mesh != 0 || segs[0].Link != 0 || segs[1].Mesh != 1 || segs[1].Link != -1 {
+		t.Errorf("unexpected segments %+v", segs)
+	}
+	if segs[0].Path[0] != orgRef || segs[0].Path[len(segs[0].Path)-1] != want[nwant/2] ||
+		segs[1].Path[0] != want[nwant/2] || segs[1].Path[len(segs[1].Path)-1] != dstRef {
+		t.Errorf("segments don't go through the link: %+v", segs)
+	}
+	if !d3.Vec3(segs[0].StartPos[:]).Approx(org) || !d3.Vec3(segs[1].EndPos[:]).Approx(dst) {
+		t.Errorf("segments should start at %v and end at %v: %+v", org, dst, segs)
+	}
+
+	// The link is one way.
+	if _, st := w.FindPath(1, dstRef, dst, 0, orgRef, org, filter, 256); !StatusFailed(st) {
+		t.Errorf("FindPath through a one way link returned %v, want Failure", st)
+	}
+	w.links[0].Bidirectional = true
+	if segs, st := w.FindPath(1, dstRef, dst, 0, orgRef, org, filter, 256); StatusFailed(st) || len(segs) != 2 {
+		t.Errorf("FindPath through a bidirectional link = (%+v, %v), want 2 segments", segs, st)
+	}
+
+	// Invalid parameters.
+	if _, st := w.FindPath(2, orgRef, org, 1, dstRef, dst, filter, 256); !StatusFailed(st) {
+		t.Errorf("FindPath with an invalid mesh returned %v, want Failure", st)
+	}
+	if _, st := w.AddLink(0, d3.Vec3{1e6, 0, 0}, 1, mid, extents, -1, false, filter); !StatusFailed(st) {
+		t.Errorf("AddLink far from the mesh returned %v, want Failure", st)
+	}
+}
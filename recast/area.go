@@ -1,6 +1,8 @@
 package recast
 
 import (
+	"sort"
+
 	assert "github.com/arl/assertgo"
 	"github.com/arl/gogeo/f32/d3"
 )
@@ -194,6 +196,157 @@ func ErodeWalkableArea(ctx *BuildContext, radius int32, chf *CompactHeightfield)
 	return true
 }
 
+// MedianFilterWalkableArea applies a median filter to walkable area types
+// (based on area id), removing noise.
+//
+//	Arguments:
+//	 ctx     The build context to use during the operation.
+//	 chf     A populated compact heightfield.
+//
+// Returns true if the operation completed successfully.
+//
+// This filter is usually applied after applying area id's using functions
+// such as MarkBoxArea, MarkConvexPolyArea, and MarkCylinderArea.
+//
+// See CompactHeightfield
+func MedianFilterWalkableArea(ctx *BuildContext, chf *CompactHeightfield) bool {
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	w := chf.Width
+	h := chf.Height
+
+	ctx.StartTimer(TimerMedianArea)
+	defer ctx.StopTimer(TimerMedianArea)
+
+	areas := make([]uint8, chf.SpanCount)
+
+	for y := int32(0); y < h; y++ {
+		for x := int32(0); x < w; x++ {
+			c := &chf.Cells[x+y*w]
+			ni := int32(c.Index) + int32(c.Count)
+			for i := int32(c.Index); i < ni; i++ {
+				s := &chf.Spans[i]
+				if chf.Areas[i] == nullArea {
+					areas[i] = chf.Areas[i]
+					continue
+				}
+
+				var nei [9]uint8
+				for j := range nei {
+					nei[j] = chf.Areas[i]
+				}
+
+				for dir := int32(0); dir < 4; dir++ {
+					if GetCon(s, dir) != notConnected {
+						ax := x + GetDirOffsetX(dir)
+						ay := y + GetDirOffsetY(dir)
+						ai := int32(chf.Cells[ax+ay*w].Index) + GetCon(s, dir)
+						if chf.Areas[ai] != nullArea {
+							nei[dir*2+0] = chf.Areas[ai]
+						}
+
+						as := &chf.Spans[ai]
+						dir2 := (dir + 1) & 0x3
+						if GetCon(as, dir2) != notConnected {
+							ax2 := ax + GetDirOffsetX(dir2)
+							ay2 := ay + GetDirOffsetY(dir2)
+							ai2 := int32(chf.Cells[ax2+ay2*w].Index) + GetCon(as, dir2)
+							if chf.Areas[ai2] != nullArea {
+								nei[dir*2+1] = chf.Areas[ai2]
+							}
+						}
+					}
+				}
+				insertSort(nei[:])
+				areas[i] = nei[4]
+			}
+		}
+	}
+
+	copy(chf.Areas, areas)
+
+	return true
+}
+
+func insertSort(a []uint8) {
+	for i := 1; i < len(a); i++ {
+		value := a[i]
+		j := i - 1
+		for ; j >= 0 && a[j] > value; j-- {
+			a[j+1] = a[j]
+		}
+		a[j+1] = value
+	}
+}
+
+// MarkBoxArea applies an area id to all spans within the specified bounding
+// box. (AABB)
+//
+//	Arguments:
+//	 ctx     The build context to use during the operation.
+//	 bmin    The minimum of the bounding box. [(x, y, z)]
+//	 bmax    The maximum of the bounding box. [(x, y, z)]
+//	 areaID  The area id to apply. [Limit: <= WalkableArea]
+//	 chf     A populated compact heightfield.
+//
+// The value of spacial parameters are in world units.
+//
+// See CompactHeightfield, MedianFilterWalkableArea
+func MarkBoxArea(ctx *BuildContext, bmin, bmax []float32, areaID uint8, chf *CompactHeightfield) {
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	ctx.StartTimer(TimerMarkBoxArea)
+	defer ctx.StopTimer(TimerMarkBoxArea)
+
+	minx := int32(((bmin[0] - chf.BMin[0]) / chf.Cs))
+	miny := int32(((bmin[1] - chf.BMin[1]) / chf.Ch))
+	minz := int32(((bmin[2] - chf.BMin[2]) / chf.Cs))
+	maxx := int32(((bmax[0] - chf.BMin[0]) / chf.Cs))
+	maxy := int32(((bmax[1] - chf.BMin[1]) / chf.Ch))
+	maxz := int32(((bmax[2] - chf.BMin[2]) / chf.Cs))
+
+	if maxx < 0 {
+		return
+	}
+	if minx >= chf.Width {
+		return
+	}
+	if maxz < 0 {
+		return
+	}
+	if minz >= chf.Height {
+		return
+	}
+
+	if minx < 0 {
+		minx = 0
+	}
+	if maxx >= chf.Width {
+		maxx = chf.Width - 1
+	}
+	if minz < 0 {
+		minz = 0
+	}
+	if maxz >= chf.Height {
+		maxz = chf.Height - 1
+	}
+
+	for z := minz; z <= maxz; z++ {
+		for x := minx; x <= maxx; x++ {
+			c := &chf.Cells[x+z*chf.Width]
+			i := int32(c.Index)
+			for ni := int32(c.Index) + int32(c.Count); i < ni; i++ {
+				s := &chf.Spans[i]
+				if int32(s.Y) >= miny && int32(s.Y) <= maxy {
+					if chf.Areas[i] != nullArea {
+						chf.Areas[i] = areaID
+					}
+				}
+			}
+		}
+	}
+}
+
 // MarkConvexPolyArea applies the area id to the all spans within the specified
 // convex polygon.
 //
@@ -203,7 +356,7 @@ func ErodeWalkableArea(ctx *BuildContext, radius int32, chf *CompactHeightfield)
 //	 nverts  The number of vertices in the polygon.
 //	 hmin    The height of the base of the polygon.
 //	 hmax    The height of the top of the polygon.
-//	 areaID  The area id to apply. [Limit: <= RC_WALKABLE_AREA]
+//	 areaID  The area id to apply. [Limit: <= WalkableArea]
 //	 chf     A populated compact heightfield.
 //
 // The value of spacial parameters are in world units.
@@ -219,10 +372,22 @@ func MarkConvexPolyArea(ctx *BuildContext, verts []float32, nverts int32,
 
 	ctx.StartTimer(TimerMarkConvexPolyArea)
 	defer ctx.StopTimer(TimerMarkConvexPolyArea)
+	forEachSpanInConvexPoly(verts, nverts, hmin, hmax, chf, func(i int32) {
+		if chf.Areas[i] != nullArea {
+			chf.Areas[i] = areaID
+		}
+	})
+}
+
+// forEachSpanInConvexPoly calls fn with the index of all the spans of chf, of
+// any area, within the specified convex polygon.
+func forEachSpanInConvexPoly(verts []float32, nverts int32, hmin, hmax float32,
+	chf *CompactHeightfield, fn func(i int32)) {
+
 	var bmin, bmax [3]float32
 	copy(bmin[:], verts[:3])
 	copy(bmax[:], verts[:3])
-	for i := int32(1); i*3 < nverts; i++ {
+	for i := int32(1); i < nverts; i++ {
 		v := verts[i*3:]
 		d3.Vec3Min(bmin[:], v)
 		d3.Vec3Max(bmax[:], v)
@@ -269,9 +434,6 @@ func MarkConvexPolyArea(ctx *BuildContext, verts []float32, nverts int32,
 			c := &chf.Cells[x+z*chf.Width]
 			i := int32(c.Index)
 			for ni := int32(c.Index) + int32(c.Count); i < ni; i++ {
-				if chf.Areas[i] == nullArea {
-					continue
-				}
 				s := &chf.Spans[i]
 				if int32(s.Y) >= miny && int32(s.Y) <= maxy {
 					var p [3]float32
@@ -280,6 +442,174 @@ func MarkConvexPolyArea(ctx *BuildContext, verts []float32, nverts int32,
 					p[2] = chf.BMin[2] + (float32(z)+0.5)*chf.Cs
 
 					if pointInPoly(nverts, verts, p[:]) {
+						fn(i)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Modes of a modifier volume, see ModifierVolumeSettings.
+const (
+	// ModifierArea overrides the area id of the walkable spans.
+	ModifierArea int32 = iota
+
+	// ModifierNotWalkable makes all the spans non-walkable.
+	ModifierNotWalkable
+
+	// ModifierWalkable makes all the spans walkable, including the ones that
+	// were not, and applies the area id.
+	ModifierWalkable
+)
+
+// MarkModifierVolumes applies a set of modifier volumes to the spans of a
+// compact heightfield.
+//
+//	Arguments:
+//	 ctx     The build context to use during the operation.
+//	 vols    The modifier volumes.
+//	 chf     A populated compact heightfield.
+//
+// Volumes are applied in increasing priority order, volumes with the same
+// priority being applied in the order they are given. So where volumes
+// overlap, the volume with the highest priority wins. The result only
+// depends on the volumes and on the position of the spans, so it is
+// consistent across tiles.
+//
+// Modifier volumes should be applied before the walkable area is eroded, so
+// that agents keep their distance from the non-walkable volumes.
+//
+// see ModifierVolumeSettings, ErodeWalkableArea
+func MarkModifierVolumes(ctx *BuildContext, vols []ModifierVolumeSettings, chf *CompactHeightfield) {
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	ctx.StartTimer(TimerMarkModifierVolumes)
+	defer ctx.StopTimer(TimerMarkModifierVolumes)
+
+	order := make([]int, len(vols))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return vols[order[i]].Priority < vols[order[j]].Priority
+	})
+
+	for _, idx := range order {
+		vol := &vols[idx]
+		nverts := int32(len(vol.Verts) / 3)
+		if nverts < 3 {
+			ctx.Warningf("MarkModifierVolumes: ignoring volume %d with less than 3 vertices", idx)
+			continue
+		}
+		var fn func(i int32)
+		switch vol.Mode {
+		case ModifierArea:
+			fn = func(i int32) {
+				if chf.Areas[i] != nullArea {
+					chf.Areas[i] = vol.Area
+				}
+			}
+		case ModifierNotWalkable:
+			fn = func(i int32) {
+				chf.Areas[i] = nullArea
+			}
+		case ModifierWalkable:
+			area := vol.Area
+			if area == nullArea {
+				area = WalkableArea
+			}
+			fn = func(i int32) {
+				chf.Areas[i] = area
+			}
+		default:
+			ctx.Warningf("MarkModifierVolumes: ignoring volume %d with unknown mode %d", idx, vol.Mode)
+			continue
+		}
+		forEachSpanInConvexPoly(vol.Verts, nverts, vol.HMin, vol.HMax, chf, fn)
+	}
+}
+
+// MarkCylinderArea applies the area id to all spans within the specified
+// cylinder.
+//
+//	Arguments:
+//	 ctx     The build context to use during the operation.
+//	 pos     The center of the base of the cylinder. [Form: (x, y, z)]
+//	 r       The radius of the cylinder.
+//	 h       The height of the cylinder.
+//	 areaID  The area id to apply. [Limit: <= WalkableArea]
+//	 chf     A populated compact heightfield.
+//
+// The value of spacial parameters are in world units.
+//
+// See CompactHeightfield, MedianFilterWalkableArea
+func MarkCylinderArea(ctx *BuildContext, pos []float32, r, h float32,
+	areaID uint8, chf *CompactHeightfield) {
+
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	ctx.StartTimer(TimerMarkCylinderArea)
+	defer ctx.StopTimer(TimerMarkCylinderArea)
+
+	var bmin, bmax [3]float32
+	bmin[0] = pos[0] - r
+	bmin[1] = pos[1]
+	bmin[2] = pos[2] - r
+	bmax[0] = pos[0] + r
+	bmax[1] = pos[1] + h
+	bmax[2] = pos[2] + r
+	r2 := r * r
+
+	minx := int32(((bmin[0] - chf.BMin[0]) / chf.Cs))
+	miny := int32(((bmin[1] - chf.BMin[1]) / chf.Ch))
+	minz := int32(((bmin[2] - chf.BMin[2]) / chf.Cs))
+	maxx := int32(((bmax[0] - chf.BMin[0]) / chf.Cs))
+	maxy := int32(((bmax[1] - chf.BMin[1]) / chf.Ch))
+	maxz := int32(((bmax[2] - chf.BMin[2]) / chf.Cs))
+
+	if maxx < 0 {
+		return
+	}
+	if minx >= chf.Width {
+		return
+	}
+	if maxz < 0 {
+		return
+	}
+	if minz >= chf.Height {
+		return
+	}
+
+	if minx < 0 {
+		minx = 0
+	}
+	if maxx >= chf.Width {
+		maxx = chf.Width - 1
+	}
+	if minz < 0 {
+		minz = 0
+	}
+	if maxz >= chf.Height {
+		maxz = chf.Height - 1
+	}
+
+	for z := minz; z <= maxz; z++ {
+		for x := minx; x <= maxx; x++ {
+			c := &chf.Cells[x+z*chf.Width]
+			i := int32(c.Index)
+			for ni := int32(c.Index) + int32(c.Count); i < ni; i++ {
+				if chf.Areas[i] == nullArea {
+					continue
+				}
+				s := &chf.Spans[i]
+				if int32(s.Y) >= miny && int32(s.Y) <= maxy {
+					sx := chf.BMin[0] + (float32(x)+0.5)*chf.Cs
+					sz := chf.BMin[2] + (float32(z)+0.5)*chf.Cs
+					dx := sx - pos[0]
+					dz := sz - pos[2]
+
+					if dx*dx+dz*dz < r2 {
 						chf.Areas[i] = areaID
 					}
 				}
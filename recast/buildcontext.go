@@ -1,21 +1,69 @@
 package recast
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"time"
 )
 
-// Recast log categories.
+// LogCategory is the category of a log entry.
 // see BuildContext
-type logCategory int
+type LogCategory int
 
+// Recast log categories.
 const (
-	logProgress logCategory = 1 + iota // A progress log entry.
-	logWarning                         // A warning log entry.
-	logError                           // An error log entry.
+	LogProgress LogCategory = 1 + iota // A progress log entry.
+	LogWarning                         // A warning log entry.
+	LogError                           // An error log entry.
 )
 
+// String returns the short name of the category, that prefixes the log
+// entries.
+func (c LogCategory) String() string {
+	switch c {
+	case LogProgress:
+		return "PROG"
+	case LogWarning:
+		return "WARN"
+	case LogError:
+		return "ERR"
+	}
+	return fmt.Sprintf("LogCategory(%d)", int(c))
+}
+
+// A Logger receives the log entries of a BuildContext as they're written.
+//
+// see BuildContext.SetLogger
+type Logger interface {
+	Log(category LogCategory, msg string)
+}
+
+// LoggerFunc is an adapter allowing the use of an ordinary function as a
+// Logger.
+type LoggerFunc func(category LogCategory, msg string)
+
+// Log calls f(category, msg).
+func (f LoggerFunc) Log(category LogCategory, msg string) {
+	f(category, msg)
+}
+
+// NewWriterLogger returns a Logger writing each log entry to w, on its own
+// line and prefixed by its category.
+func NewWriterLogger(w io.Writer) Logger {
+	return LoggerFunc(func(category LogCategory, msg string) {
+		fmt.Fprintf(w, "%v %s\n", category, msg)
+	})
+}
+
+// ProgressFunc receives the progress of the build steps.
+//
+// stage is the name of the build step, percent its progress, between 0 and
+// 100.
+//
+// see BuildContext.SetProgressFunc
+type ProgressFunc func(stage string, percent float32)
+
 const maxMessages = 1000
 
 // BuildContext provides an interface for optional logging and performance
@@ -33,6 +81,19 @@ type BuildContext struct {
 
 	// True if the performance timers are enabled.
 	timerEnabled bool
+
+	// Receives the log entries, if not nil.
+	logger Logger
+
+	// Closed when the build is cancelled, nil if it can't be.
+	done <-chan struct{}
+
+	// Receives the build progress, if not nil.
+	progressFn ProgressFunc
+
+	// Last reported progress, in whole percents.
+	lastStage   string
+	lastPercent int32
 }
 
 // NewBuildContext returns an initialized buildcontext where state indicated if
@@ -54,6 +115,85 @@ func (ctx *BuildContext) EnableTimer(state bool) {
 	ctx.timerEnabled = state
 }
 
+// SetLogger sets the logger receiving the log entries as they're written, or
+// removes it if l is nil.
+//
+// The log entries are forwarded to l when logging is enabled, in addition to
+// being kept in the context, to which the limit of stored entries doesn't
+// apply.
+func (ctx *BuildContext) SetLogger(l Logger) {
+	ctx.logger = l
+}
+
+// SetContext sets the context whose cancellation aborts the build, or removes
+// it if c is nil.
+//
+// The expensive build steps (rasterization, region building, contouring,
+// polygon mesh and detail mesh building) periodically check whether c is
+// done, in which case they log an error and fail. This allows an editor to
+// abort a build when the user changes its settings.
+//
+// see Cancelled
+func (ctx *BuildContext) SetContext(c context.Context) {
+	ctx.done = nil
+	if c != nil {
+		ctx.done = c.Done()
+	}
+}
+
+// Cancelled reports whether the context set with SetContext is done, that is
+// whether the build has been cancelled.
+func (ctx *BuildContext) Cancelled() bool {
+	if ctx.done == nil {
+		return false
+	}
+	select {
+	case <-ctx.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetProgressFunc sets the function receiving the progress of the build
+// steps, or removes it if fn is nil.
+//
+// The expensive build steps (rasterization, region building, contouring,
+// polygon mesh and detail mesh building) report their progress as they run,
+// from 0 to 100 percents, the stage being the name of their timer. The tile
+// mesh builders report the progress of the whole build, per tile.
+func (ctx *BuildContext) SetProgressFunc(fn ProgressFunc) {
+	ctx.progressFn = fn
+	ctx.lastStage, ctx.lastPercent = "", -1
+}
+
+// ReportProgress reports the progress of a build step, done steps having
+// been completed out of total, to the function set with SetProgressFunc.
+//
+// The progress is only reported when its whole percentage changes, so that
+// it may be called at each step of a loop.
+func (ctx *BuildContext) ReportProgress(stage string, done, total int32) {
+	if ctx.progressFn == nil {
+		return
+	}
+	var percent int32 = 100
+	if total > 0 && done < total {
+		percent = int32(int64(done) * 100 / int64(total))
+	}
+	if stage == ctx.lastStage && percent == ctx.lastPercent {
+		return
+	}
+	ctx.lastStage, ctx.lastPercent = stage, percent
+	ctx.progressFn(stage, float32(percent))
+}
+
+// progress reports the progress of the build step measured by label.
+func (ctx *BuildContext) progress(label TimerLabel, done, total int32) {
+	if ctx.progressFn != nil {
+		ctx.ReportProgress(label.String(), done, total)
+	}
+}
+
 // ResetLog clears all log entries.
 func (ctx *BuildContext) ResetLog() {
 	if ctx.logEnabled {
@@ -64,7 +204,7 @@ func (ctx *BuildContext) ResetLog() {
 // ResetTimers clears all peformance timers. (Resets all to unused.)
 func (ctx *BuildContext) ResetTimers() {
 	if ctx.timerEnabled {
-		for i := 0; i < maxTimers; i++ {
+		for i := range ctx.accTime {
 			ctx.accTime[i] = time.Duration(0)
 		}
 	}
@@ -75,7 +215,7 @@ func (ctx *BuildContext) ResetTimers() {
 // The format string and arguments are forwarded to fmt.Sprintf and thus accepts
 // the same format specifiers.
 func (ctx *BuildContext) Progressf(format string, v ...interface{}) {
-	ctx.log(logProgress, format, v...)
+	ctx.log(LogProgress, format, v...)
 }
 
 // Warningf writes a new log entry in the 'warning' category.
@@ -83,7 +223,7 @@ func (ctx *BuildContext) Progressf(format string, v ...interface{}) {
 // The format string and arguments are forwarded to fmt.Sprintf and thus accepts
 // the same format specifiers.
 func (ctx *BuildContext) Warningf(format string, v ...interface{}) {
-	ctx.log(logWarning, format, v...)
+	ctx.log(LogWarning, format, v...)
 }
 
 // Errorf writes a new log entry in the 'error' category.
@@ -91,24 +231,24 @@ func (ctx *BuildContext) Warningf(format string, v ...interface{}) {
 // The format string and arguments are forwarded to fmt.Sprintf and thus accepts
 // the same format specifiers.
 func (ctx *BuildContext) Errorf(format string, v ...interface{}) {
-	ctx.log(logError, format, v...)
+	ctx.log(LogError, format, v...)
 }
 
 // log writes a new log entry in the specified category.
 //
 // The format string and arguments are forwarded to fmt.Sprintf and thus accepts
 // the same format specifiers.
-func (ctx *BuildContext) log(category logCategory, format string, v ...interface{}) {
-	if ctx.logEnabled && ctx.numMessages < maxMessages {
+func (ctx *BuildContext) log(category LogCategory, format string, v ...interface{}) {
+	if !ctx.logEnabled {
+		return
+	}
+	msg := fmt.Sprintf(format, v...)
+	if ctx.logger != nil {
+		ctx.logger.Log(category, msg)
+	}
+	if ctx.numMessages < maxMessages {
 		// Store message
-		switch category {
-		case logProgress:
-			ctx.messages[ctx.numMessages] = "PROG " + fmt.Sprintf(format, v...)
-		case logWarning:
-			ctx.messages[ctx.numMessages] = "WARN " + fmt.Sprintf(format, v...)
-		case logError:
-			ctx.messages[ctx.numMessages] = "ERR " + fmt.Sprintf(format, v...)
-		}
+		ctx.messages[ctx.numMessages] = category.String() + " " + msg
 		ctx.numMessages++
 	}
 }
@@ -168,3 +308,27 @@ func (ctx *BuildContext) AccumulatedTime(label TimerLabel) time.Duration {
 	}
 	return time.Duration(0)
 }
+
+// TimerEntry is the accumulated time of a performance timer.
+type TimerEntry struct {
+	Label    TimerLabel    // The timer.
+	Duration time.Duration // The accumulated time.
+}
+
+// TimerReport returns the accumulated time of the performance timers that
+// have been used since the last call to ResetTimers, ordered by label.
+//
+// The report is empty if the timers are disabled.
+func (ctx *BuildContext) TimerReport() []TimerEntry {
+	var report []TimerEntry
+	if !ctx.timerEnabled {
+		return report
+	}
+	for i := TimerLabel(0); i < maxTimers; i++ {
+		if ctx.accTime[i] == 0 {
+			continue
+		}
+		report = append(report, TimerEntry{Label: i, Duration: ctx.accTime[i]})
+	}
+	return report
+}
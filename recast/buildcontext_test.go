@@ -0,0 +1,177 @@
+package recast
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildContextLogger(t *testing.T) {
+	ctx := NewBuildContext(true)
+	var buf bytes.Buffer
+	ctx.SetLogger(NewWriterLogger(&buf))
+
+	ctx.Progressf("progress %d", 1)
+	ctx.Warningf("warning %d", 2)
+	ctx.Errorf("error %d", 3)
+
+	want := "PROG progress 1\nWARN warning 2\nERR error 3\n"
+	if got := buf.String(); got != want {
+		t.Errorf("logger got %q, want %q", got, want)
+	}
+	if ctx.LogCount() != 3 || ctx.LogText(1) != "WARN warning 2" {
+		t.Errorf("entries should still be stored, got %d, %q", ctx.LogCount(), ctx.LogText(1))
+	}
+
+	// nothing is forwarded when logging is disabled
+	buf.Reset()
+	ctx.EnableLog(false)
+	ctx.Errorf("disabled")
+	if buf.Len() != 0 {
+		t.Errorf("logger got %q with logging disabled", buf.String())
+	}
+}
+
+func TestBuildContextTimerReport(t *testing.T) {
+	ctx := NewBuildContext(true)
+	ctx.StartTimer(TimerTotal)
+	ctx.StartTimer(TimerBuildContours)
+	time.Sleep(time.Millisecond)
+	ctx.StopTimer(TimerBuildContours)
+	ctx.StopTimer(TimerTotal)
+
+	report := ctx.TimerReport()
+	if len(report) != 2 || report[0].Label != TimerTotal || report[1].Label != TimerBuildContours {
+		t.Fatalf("got report %v, want TimerTotal then TimerBuildContours", report)
+	}
+	if report[1].Duration < time.Millisecond {
+		t.Errorf("got %v for %v, want at least 1ms", report[1].Duration, report[1].Label)
+	}
+	if got := report[1].Label.String(); got != "Build Contours" {
+		t.Errorf("got timer name %q", got)
+	}
+
+	ctx.ResetTimers()
+	if report = ctx.TimerReport(); len(report) != 0 {
+		t.Errorf("got report %v after reset, want empty", report)
+	}
+}
+
+func TestBuildContextCancel(t *testing.T) {
+	mesh, areas, bmin, bmax := loadRasterizationInput(t, "nav_test.obj")
+	chf := loadCompactHeightfield(t, "nav_test.obj")
+	w, h := CalcGridSize(bmin[:], bmax[:], 0.3)
+
+	ctx := NewBuildContext(true)
+	c, cancel := context.WithCancel(context.Background())
+	ctx.SetContext(c)
+	require(t, !ctx.Cancelled(), "build shouldn't be cancelled yet")
+
+	// Build the inputs of each step before cancelling.
+	require(t, BuildDistanceField(ctx, chf), "BuildDistanceField should succeed")
+	require(t, BuildRegions(ctx, chf, 0, 8, 20), "BuildRegions should succeed")
+	var cset ContourSet
+	require(t, BuildContours(ctx, chf, 1.3, 40, &cset, ContourTessWallEdges), "BuildContours should succeed")
+	pmesh, ok := BuildPolyMesh(ctx, &cset, 6)
+	require(t, ok, "BuildPolyMesh should succeed")
+
+	cancel()
+	require(t, ctx.Cancelled(), "build should be cancelled")
+
+	steps := []struct {
+		name string
+		fn   func() bool
+	}{
+		{"RasterizeTriangles", func() bool {
+			solid := NewHeightfield(w, h, bmin[:], bmax[:], 0.3, 0.2)
+			return RasterizeTriangles(ctx, mesh.Verts(), mesh.VertCount(), mesh.Tris(), areas, mesh.TriCount(), solid, 4)
+		}},
+		{"BuildDistanceField", func() bool { return BuildDistanceField(ctx, chf) }},
+		{"BuildRegions", func() bool { return BuildRegions(ctx, chf, 0, 8, 20) }},
+		{"BuildRegionsMonotone", func() bool { return BuildRegionsMonotone(ctx, chf, 0, 8, 20) }},
+		{"BuildContours", func() bool {
+			var cset ContourSet
+			return BuildContours(ctx, chf, 1.3, 40, &cset, ContourTessWallEdges)
+		}},
+		{"BuildPolyMesh", func() bool {
+			_, ok := BuildPolyMesh(ctx, &cset, 6)
+			return ok
+		}},
+		{"BuildPolyMeshDetail", func() bool {
+			_, ok := BuildPolyMeshDetail(ctx, pmesh, chf, 1.8, 0.2)
+			return ok
+		}},
+	}
+	for _, step := range steps {
+		ctx.ResetLog()
+		if step.fn() {
+			t.Errorf("%s should fail once cancelled", step.name)
+			continue
+		}
+		if ctx.LogCount() == 0 || !strings.Contains(ctx.LogText(int32(ctx.LogCount()-1)), "cancelled") {
+			t.Errorf("%s should log the cancellation", step.name)
+		}
+	}
+
+	// removing the context resumes the build
+	ctx.SetContext(nil)
+	require(t, !ctx.Cancelled(), "build shouldn't be cancelled without context")
+	require(t, BuildDistanceField(ctx, chf), "BuildDistanceField should succeed")
+}
+
+func TestBuildContextProgress(t *testing.T) {
+	chf := loadCompactHeightfield(t, "nav_test.obj")
+
+	type report struct {
+		stage   string
+		percent float32
+	}
+	var reports []report
+	ctx := NewBuildContext(false)
+	ctx.SetProgressFunc(func(stage string, percent float32) {
+		reports = append(reports, report{stage, percent})
+	})
+
+	require(t, BuildDistanceField(ctx, chf), "BuildDistanceField should succeed")
+	require(t, BuildRegions(ctx, chf, 0, 8, 20), "BuildRegions should succeed")
+	var cset ContourSet
+	require(t, BuildContours(ctx, chf, 1.3, 40, &cset, ContourTessWallEdges), "BuildContours should succeed")
+	pmesh, ok := BuildPolyMesh(ctx, &cset, 6)
+	require(t, ok, "BuildPolyMesh should succeed")
+	_, ok = BuildPolyMeshDetail(ctx, pmesh, chf, 1.8, 0.2)
+	require(t, ok, "BuildPolyMeshDetail should succeed")
+
+	// Each stage progresses from 0 to 100, in order, reporting each
+	// percentage once.
+	stages := []TimerLabel{
+		TimerBuildDistanceField, TimerBuildRegions, TimerBuildContours,
+		TimerBuildPolymesh, TimerBuildPolyMeshDetail,
+	}
+	var i int
+	for _, label := range stages {
+		var last float32 = -1
+		for ; i < len(reports) && reports[i].stage == label.String(); i++ {
+			if reports[i].percent <= last || reports[i].percent > 100 {
+				t.Fatalf("%v: got progress %v after %v", label, reports[i].percent, last)
+			}
+			last = reports[i].percent
+		}
+		if last != 100 {
+			t.Errorf("%v: last progress is %v, want 100", label, last)
+		}
+	}
+	if i != len(reports) {
+		t.Errorf("unexpected progress report %+v", reports[i])
+	}
+
+	// ReportProgress only reports changes of whole percentages.
+	reports = nil
+	for done := int32(0); done <= 1000; done++ {
+		ctx.ReportProgress("custom", done, 1000)
+	}
+	if len(reports) != 101 || reports[50].percent != 50 {
+		t.Errorf("got %d progress reports, want 101", len(reports))
+	}
+}
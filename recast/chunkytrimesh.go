@@ -16,6 +16,7 @@ type ChunkyTriMesh struct {
 	Nodes           []ChunkyTriMeshNode
 	Nnodes          int32
 	Tris            []int32
+	TriIDs          []int32 // Index of each triangle of Tris in the input mesh
 	Ntris           int32
 	MaxTrisPerChunk int32
 }
@@ -60,7 +61,7 @@ func longestAxis(x, y float32) int {
 
 func subdivide(items []BoundsItem, nitems, imin, imax, trisPerChunk int32,
 	curNode *int32, nodes []ChunkyTriMeshNode, maxNodes int32,
-	curTri *int32, outTris, inTris []int32) {
+	curTri *int32, outTris, outIDs, inTris []int32) {
 
 	inum := imax - imin
 	icur := *curNode
@@ -83,6 +84,7 @@ func subdivide(items []BoundsItem, nitems, imin, imax, trisPerChunk int32,
 		for i := imin; i < imax; i++ {
 			src := inTris[items[i].i*3:]
 			dst := outTris[(*curTri)*3:]
+			outIDs[*curTri] = items[i].i
 			(*curTri)++
 			copy(dst, src[:3])
 		}
@@ -107,9 +109,9 @@ func subdivide(items []BoundsItem, nitems, imin, imax, trisPerChunk int32,
 		isplit := imin + inum/2
 
 		// Left
-		subdivide(items, nitems, imin, isplit, trisPerChunk, curNode, nodes, maxNodes, curTri, outTris, inTris)
+		subdivide(items, nitems, imin, isplit, trisPerChunk, curNode, nodes, maxNodes, curTri, outTris, outIDs, inTris)
 		// Right
-		subdivide(items, nitems, isplit, imax, trisPerChunk, curNode, nodes, maxNodes, curTri, outTris, inTris)
+		subdivide(items, nitems, isplit, imax, trisPerChunk, curNode, nodes, maxNodes, curTri, outTris, outIDs, inTris)
 
 		iescape := (*curNode) - icur
 		// Negative index means escape.
@@ -130,6 +132,7 @@ func createChunkyTriMesh(verts []float32, tris []int32, ntris, trisPerChunk int3
 	if len(cm.Tris) == 0 {
 		return false
 	}
+	cm.TriIDs = make([]int32, ntris)
 
 	cm.Ntris = ntris
 
@@ -168,7 +171,7 @@ func createChunkyTriMesh(verts []float32, tris []int32, ntris, trisPerChunk int3
 	}
 
 	var curTri, curNode int32
-	subdivide(items, ntris, 0, ntris, trisPerChunk, &curNode, cm.Nodes, nchunks*4, &curTri, cm.Tris, tris)
+	subdivide(items, ntris, 0, ntris, trisPerChunk, &curNode, cm.Nodes, nchunks*4, &curTri, cm.Tris, cm.TriIDs, tris)
 
 	items = nil
 
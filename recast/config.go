@@ -1,5 +1,10 @@
 package recast
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Config specifies a configuration to use when performing Recast builds.
 type Config struct {
 	// The width of the field along the x-axis.
@@ -79,3 +84,119 @@ type Config struct {
 	// [Limit: >=0] [Units: wu]
 	DetailSampleMaxError float32
 }
+
+// LargeGridCells is the number of cells of the xz-plane grid above which
+// Config.Validate warns about the memory the build will use.
+const LargeGridCells = 1 << 24
+
+// ConfigError is the error returned when a Config is not valid. It lists all
+// the violated constraints.
+type ConfigError struct {
+	Problems []string // Description of each violated constraint.
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid recast config: " + strings.Join(e.Problems, "; ")
+}
+
+// CellCount returns the number of cells of the xz-plane grid, that is the
+// number of span columns of the heightfield built with cfg.
+//
+// The memory used by the heightfields grows with it, it can be used to
+// predict the cost of a build before running it.
+func (cfg *Config) CellCount() int64 {
+	return int64(cfg.Width) * int64(cfg.Height)
+}
+
+// Validate checks the configuration parameters.
+//
+//	Arguments:
+//	 ctx       The build context to log the warnings to. [opt]
+//
+// It returns nil if cfg is valid, or a *ConfigError listing all the violated
+// constraints. Width and Height must have been set, see CalcGridSize.
+//
+// A valid configuration may still produce a poor build, in which case a
+// warning is logged to ctx: if the grid has more than LargeGridCells cells,
+// or if the height of the bounds exceeds the span height limit, in voxels.
+func (cfg *Config) Validate(ctx *BuildContext) error {
+	var problems []string
+	addf := func(format string, v ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, v...))
+	}
+
+	// Voxel sizes and bounds.
+	if !(cfg.Cs > 0) {
+		addf("Cs is %g, want more than 0", cfg.Cs)
+	}
+	if !(cfg.Ch > 0) {
+		addf("Ch is %g, want more than 0", cfg.Ch)
+	}
+	if !(cfg.BMin[0] < cfg.BMax[0]) || !(cfg.BMin[1] <= cfg.BMax[1]) || !(cfg.BMin[2] < cfg.BMax[2]) {
+		addf("degenerate bounds %v %v", cfg.BMin, cfg.BMax)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		addf("grid size is %d x %d, want at least 1 x 1", cfg.Width, cfg.Height)
+	}
+	if cfg.TileSize < 0 {
+		addf("TileSize is %d, want at least 0", cfg.TileSize)
+	}
+	if cfg.BorderSize < 0 {
+		addf("BorderSize is %d, want at least 0", cfg.BorderSize)
+	}
+
+	// Agent.
+	if !(cfg.WalkableSlopeAngle >= 0 && cfg.WalkableSlopeAngle < 90) {
+		addf("WalkableSlopeAngle is %g, want between 0 and 90", cfg.WalkableSlopeAngle)
+	}
+	if cfg.WalkableHeight < 3 {
+		addf("WalkableHeight is %d, want at least 3", cfg.WalkableHeight)
+	}
+	if cfg.WalkableClimb < 0 {
+		addf("WalkableClimb is %d, want at least 0", cfg.WalkableClimb)
+	}
+	if cfg.WalkableRadius < 0 {
+		addf("WalkableRadius is %d, want at least 0", cfg.WalkableRadius)
+	}
+
+	// Regions, contours and polygons.
+	if cfg.MinRegionArea < 0 {
+		addf("MinRegionArea is %d, want at least 0", cfg.MinRegionArea)
+	}
+	if cfg.MergeRegionArea < 0 {
+		addf("MergeRegionArea is %d, want at least 0", cfg.MergeRegionArea)
+	}
+	if cfg.MaxEdgeLen < 0 {
+		addf("MaxEdgeLen is %d, want at least 0", cfg.MaxEdgeLen)
+	}
+	if cfg.MaxSimplificationError < 0 {
+		addf("MaxSimplificationError is %g, want at least 0", cfg.MaxSimplificationError)
+	}
+	if cfg.MaxVertsPerPoly < 3 {
+		addf("MaxVertsPerPoly is %d, want at least 3", cfg.MaxVertsPerPoly)
+	}
+
+	// Height detail.
+	if cfg.DetailSampleDist < 0 {
+		addf("DetailSampleDist is %g, want at least 0", cfg.DetailSampleDist)
+	}
+	if cfg.DetailSampleMaxError < 0 {
+		addf("DetailSampleMaxError is %g, want at least 0", cfg.DetailSampleMaxError)
+	}
+
+	if len(problems) != 0 {
+		return &ConfigError{Problems: problems}
+	}
+
+	if ctx != nil {
+		if n := cfg.CellCount(); n > LargeGridCells {
+			ctx.Warningf("Config: large grid, %d x %d = %d cells, consider a larger Cs or a tiled build",
+				cfg.Width, cfg.Height, n)
+		}
+		if h := (cfg.BMax[1] - cfg.BMin[1]) / cfg.Ch; h > float32(RC_SPAN_MAX_HEIGHT) {
+			ctx.Warningf("Config: bounds are %.0f voxels high, spans above %d are clamped, consider a larger Ch",
+				h, RC_SPAN_MAX_HEIGHT)
+		}
+	}
+	return nil
+}
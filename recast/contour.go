@@ -185,7 +185,8 @@ func mergeRegionHoles(ctx *BuildContext, region *contourRegion) {
 //	 maxEdgeLen  The maximum allowed length for contour edges along the border
 //	             of the mesh. [Limit: >=0] [Units: vx]
 //	 cset        The resulting contour set. (Must be pre-allocated.)
-//	 buildFlags  The build flags. (See: BuildContoursFlags)
+//	 buildFlags  The build flags, selecting the edges to tessellate.
+//	             (See: ContourTessWallEdges, ContourTessAreaEdges)
 //
 //	Returns true if the operation completed successfully.
 //
@@ -195,7 +196,10 @@ func mergeRegionHoles(ctx *BuildContext, region *contourRegion) {
 // Simplified contours are generated such that the vertices for portals between
 // areas match up. (They are considered mandatory vertices.)
 //
-// Setting maxEdgeLength to zero will disabled the edge length feature.
+// The wall edges, on the border of the walkable surface, and the edges
+// between areas, are tessellated so that they're not longer than maxEdgeLen,
+// if their flag is set in buildFlags. Setting maxEdgeLen to zero, or
+// buildFlags to zero, disables the edge length feature.
 //
 // See the Config documentation for more information on the configuration
 // parameters.
@@ -273,6 +277,11 @@ func BuildContours(ctx *BuildContext, chf *CompactHeightfield,
 	simplified := make([]int32, 64)
 
 	for y := int32(0); y < h; y++ {
+		if ctx.Cancelled() {
+			ctx.Errorf("BuildContours: Build cancelled.")
+			return false
+		}
+		ctx.progress(TimerBuildContours, y, h)
 		for x := int32(0); x < w; x++ {
 			c := &chf.Cells[x+y*w]
 			i := int32(c.Index)
@@ -428,6 +437,8 @@ func BuildContours(ctx *BuildContext, chf *CompactHeightfield,
 
 	}
 
+	ctx.progress(TimerBuildContours, h, h)
+
 	return true
 }
 
@@ -22,6 +22,7 @@ func LogBuildTimes(ctx *BuildContext, totalTime time.Duration) {
 	logLine(ctx, TimerMarkBoxArea, "- Mark Box Area\t\t", pc)
 	logLine(ctx, TimerMarkConvexPolyArea, "- Mark Convex Area\t\t", pc)
 	logLine(ctx, TimerMarkCylinderArea, "- Mark Cylinder Area\t", pc)
+	logLine(ctx, TimerMarkModifierVolumes, "- Mark Modifier Volumes\t", pc)
 	logLine(ctx, TimerBuildDistanceField, "- Build Distance Field\t", pc)
 	logLine(ctx, TimerBuildDistanceFieldDist, "    - Distance\t\t", pc)
 	logLine(ctx, TimerBuildDistanceFieldBlur, "    - Blur\t\t\t", pc)
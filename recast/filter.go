@@ -211,3 +211,36 @@ func FilterWalkableLowHeightSpans(ctx *BuildContext, walkableHeight int32, solid
 		}
 	}
 }
+
+// SpanFilterFunc returns the new area id of a span of the column (x, z) of a
+// heightfield, given its limits and area id.
+type SpanFilterFunc func(x, z int32, smin, smax uint16, area uint8) uint8
+
+// FilterSpans sets the area id of each span of the heightfield to the value
+// returned by fn.
+//
+//	Arguments:
+//	 ctx    The build context to use during the operation.
+//	 solid  A fully built heightfield.  (All spans have been added.)
+//	 fn     The filter function.
+//
+// FilterSpans lets custom rules be applied, along with the other filters, to
+// the spans of the heightfield. (E.g. marking as not walkable the spans out
+// of a playable area, or below a given height.) A span is marked as not
+// walkable by returning the null area id, 0.
+//
+// see Heightfield
+func FilterSpans(ctx *BuildContext, solid *Heightfield, fn SpanFilterFunc) {
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	w := solid.Width
+	h := solid.Height
+
+	for z := int32(0); z < h; z++ {
+		for x := int32(0); x < w; x++ {
+			for s := solid.Spans[x+z*w]; s != nil; s = s.next {
+				s.area = fn(x, z, s.smin, s.smax, s.area)
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package recast
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadGeomSet loads a geometry set file into the input geometry.
+//
+//	Arguments:
+//	 path     The path of the geometry set file.
+//
+//	Return values:
+//	 settings The build settings stored in the geometry set, or nil if it has
+//	          none.
+//	 err      The error, if any.
+//
+// A geometry set, as saved by RecastDemo, references an OBJ mesh file, whose
+// path is relative to the directory of the geometry set, and defines the
+// convex volumes and the off-mesh connections added to the mesh. It may also
+// store build settings.
+//
+// The geometry set format is line based, each line starts with a letter
+// giving its type:
+//
+//	f <mesh path>
+//	s <cellSize> <cellHeight> <agentHeight> <agentRadius> <agentMaxClimb>
+//	  <agentMaxSlope> <regionMinSize> <regionMergeSize> <edgeMaxLen>
+//	  <edgeMaxError> <vertsPerPoly> <detailSampleDist>
+//	  <detailSampleMaxError> <partitionType> <bmin x y z> <bmax x y z>
+//	  <tileSize>
+//	c <start x y z> <end x y z> <radius> <bidir> <area> <flags>
+//	v <nverts> <area> <hmin> <hmax>
+//	<x y z>, repeated nverts times
+//
+// The build settings are all on a single line, the navmesh bounds they
+// contain are ignored. As in RecastDemo, the wall edges are tessellated.
+func (ig *InputGeom) LoadGeomSet(path string) (*BuildSettings, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(path)
+	return ig.loadGeomSet(f, func(name string) (io.ReadCloser, error) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		return os.Open(name)
+	})
+}
+
+// loadGeomSet reads a geometry set from r, opening the mesh file with open.
+func (ig *InputGeom) loadGeomSet(r io.Reader, open func(name string) (io.ReadCloser, error)) (*BuildSettings, error) {
+	var (
+		meshName string
+		settings *BuildSettings
+		cons     []offMeshConnection
+		vols     []ConvexVolume
+		lineno   int
+	)
+
+	scanner := bufio.NewScanner(r)
+	next := func() (string, bool) {
+		if !scanner.Scan() {
+			return "", false
+		}
+		lineno++
+		return strings.TrimSpace(scanner.Text()), true
+	}
+
+	for {
+		row, ok := next()
+		if !ok {
+			break
+		}
+		if len(row) == 0 {
+			continue
+		}
+		switch row[0] {
+		case 'f':
+			meshName = strings.TrimSpace(row[1:])
+		case 's':
+			var (
+				s          BuildSettings
+				bmin, bmax [3]float32
+			)
+			_, err := fmt.Sscan(row[1:],
+				&s.CellSize, &s.CellHeight, &s.AgentHeight, &s.AgentRadius, &s.AgentMaxClimb, &s.AgentMaxSlope,
+				&s.RegionMinSize, &s.RegionMergeSize, &s.EdgeMaxLen, &s.EdgeMaxError, &s.VertsPerPoly,
+				&s.DetailSampleDist, &s.DetailSampleMaxError, &s.PartitionType,
+				&bmin[0], &bmin[1], &bmin[2], &bmax[0], &bmax[1], &bmax[2], &s.TileSize)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid build settings: %v", lineno, err)
+			}
+			s.ContourFlags = ContourTessWallEdges
+			settings = &s
+		case 'c':
+			var (
+				c            offMeshConnection
+				bidir, flags int
+				area         int
+			)
+			_, err := fmt.Sscan(row[1:],
+				&c.verts[0], &c.verts[1], &c.verts[2], &c.verts[3], &c.verts[4], &c.verts[5],
+				&c.rad, &bidir, &area, &flags)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid off-mesh connection: %v", lineno, err)
+			}
+			c.bidir, c.area, c.flags = bidir != 0, uint8(area), uint16(flags)
+			cons = append(cons, c)
+		case 'v':
+			var vol ConvexVolume
+			_, err := fmt.Sscan(row[1:], &vol.NVerts, &vol.Area, &vol.HMin, &vol.HMax)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid convex volume: %v", lineno, err)
+			}
+			if vol.NVerts < 0 || vol.NVerts > maxConvexVolPts {
+				return nil, fmt.Errorf("line %d: invalid convex volume vertex count: %d", lineno, vol.NVerts)
+			}
+			for i := int32(0); i < vol.NVerts; i++ {
+				row, ok := next()
+				if !ok {
+					return nil, fmt.Errorf("line %d: missing convex volume vertices", lineno)
+				}
+				if _, err := fmt.Sscan(row, &vol.Verts[i*3], &vol.Verts[i*3+1], &vol.Verts[i*3+2]); err != nil {
+					return nil, fmt.Errorf("line %d: invalid convex volume vertex: %v", lineno, err)
+				}
+			}
+			vols = append(vols, vol)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(meshName) == 0 {
+		return nil, fmt.Errorf("missing mesh file")
+	}
+
+	mr, err := open(meshName)
+	if err != nil {
+		return nil, err
+	}
+	defer mr.Close()
+	if err = ig.LoadOBJMesh(mr); err != nil {
+		return nil, fmt.Errorf("couldn't load '%v': %v", meshName, err)
+	}
+
+	for _, c := range cons {
+		ig.AddOffMeshConnection(c.verts[:3], c.verts[3:], c.rad, c.bidir, c.area, c.flags)
+	}
+	for i := range vols {
+		vol := &vols[i]
+		ig.AddConvexVolume(vol.Verts[:vol.NVerts*3], vol.HMin, vol.HMax, uint8(vol.Area))
+	}
+	return settings, nil
+}
+
+// offMeshConnection is an off-mesh connection read from a geometry set.
+type offMeshConnection struct {
+	verts [6]float32
+	rad   float32
+	bidir bool
+	area  uint8
+	flags uint16
+}
+
+// SaveGeomSet writes the input geometry to w, as a geometry set.
+//
+//	Arguments:
+//	 w        The writer.
+//	 meshPath The path of the OBJ mesh file of the input geometry, relative to
+//	          the directory of the geometry set file.
+//	 settings The build settings to store, may be nil.
+//
+// The mesh itself is not written. (See: LoadGeomSet)
+func (ig *InputGeom) SaveGeomSet(w io.Writer, meshPath string, settings *BuildSettings) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "f %s\n", meshPath)
+
+	if s := settings; s != nil {
+		bmin, bmax := ig.NavMeshBoundsMin(), ig.NavMeshBoundsMax()
+		fmt.Fprintf(bw, "s %f %f %f %f %f %f %f %f %f %f %f %f %f %d %f %f %f %f %f %f %f\n",
+			s.CellSize, s.CellHeight, s.AgentHeight, s.AgentRadius, s.AgentMaxClimb, s.AgentMaxSlope,
+			s.RegionMinSize, s.RegionMergeSize, s.EdgeMaxLen, s.EdgeMaxError, s.VertsPerPoly,
+			s.DetailSampleDist, s.DetailSampleMaxError, s.PartitionType,
+			bmin[0], bmin[1], bmin[2], bmax[0], bmax[1], bmax[2], s.TileSize)
+	}
+
+	for i := int32(0); i < ig.offMeshConCount; i++ {
+		v := ig.offMeshConVerts[i*3*2 : i*3*2+6]
+		fmt.Fprintf(bw, "c %f %f %f  %f %f %f  %f %d %d %d\n",
+			v[0], v[1], v[2], v[3], v[4], v[5], ig.offMeshConRads[i],
+			ig.offMeshConDirs[i], ig.offMeshConAreas[i], ig.offMeshConFlags[i])
+	}
+
+	for i := int32(0); i < ig.volumeCount; i++ {
+		vol := &ig.volumes[i]
+		fmt.Fprintf(bw, "v %d %d %f %f\n", vol.NVerts, vol.Area, vol.HMin, vol.HMax)
+		for j := int32(0); j < vol.NVerts; j++ {
+			fmt.Fprintf(bw, "%f %f %f\n", vol.Verts[j*3], vol.Verts[j*3+1], vol.Verts[j*3+2])
+		}
+	}
+
+	return bw.Flush()
+}
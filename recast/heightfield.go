@@ -24,6 +24,11 @@ type Span struct {
 
 // A memory pool used for quick allocation of spans within a heightfield.
 //
+// The spans of a heightfield are allocated one after the other from its
+// pools, so that a heightfield only allocates one object per
+// RC_SPANS_PER_POOL spans. The pools are kept, and reused, when the
+// heightfield is reset.
+//
 // see Heightfield
 type spanPool struct {
 	next  *spanPool               // The next span pool.
@@ -41,6 +46,9 @@ type Heightfield struct {
 	Spans    []*Span    // Heightfield of spans (width*height).
 	Pools    *spanPool  // Linked list of span pools.
 	Freelist *Span      // The next free span.
+
+	pool *spanPool // The pool spans are currently allocated from.
+	used int32     // The number of spans allocated from pool.
 }
 
 // See the Config documentation for more information on the configuration parameters.
@@ -57,6 +65,90 @@ func NewHeightfield(width, height int32, bmin, bmax []float32, cs, ch float32) *
 	return hf
 }
 
+// Reset reinitializes the heightfield, as NewHeightfield does, removing all
+// its spans.
+//
+// The memory of the heightfield is reused, so resetting a heightfield rather
+// than creating a new one, for example before building each tile of a tiled
+// navmesh, saves most of the allocations.
+func (hf *Heightfield) Reset(width, height int32, bmin, bmax []float32, cs, ch float32) {
+	hf.Width = width
+	hf.Height = height
+	hf.Cs = cs
+	hf.Ch = ch
+	copy(hf.BMin[:], bmin)
+	copy(hf.BMax[:], bmax)
+
+	if n := width * height; int32(cap(hf.Spans)) >= n {
+		hf.Spans = hf.Spans[:n]
+		for i := range hf.Spans {
+			hf.Spans[i] = nil
+		}
+	} else {
+		hf.Spans = make([]*Span, n)
+	}
+
+	// All the spans are free again.
+	hf.Freelist = nil
+	hf.pool = hf.Pools
+	hf.used = 0
+}
+
+// Clone returns a deep copy of the heightfield.
+//
+// This allows to rasterize the input geometry once, then to filter the
+// heightfield with different agent parameters.
+func (hf *Heightfield) Clone() *Heightfield {
+	c := &Heightfield{}
+	hf.copyTo(c)
+	return c
+}
+
+// CopyHeightfield copies a heightfield into another.
+//
+//	Arguments:
+//	 ctx      The build context to use during the operation.
+//	 src      The heightfield to copy.
+//	 dst      The resulting heightfield. Its memory is reused.
+//
+// Returns true if the operation completed successfully.
+//
+// dst is a deep copy of src, modifying one leaves the other untouched. This
+// allows to snapshot a build stage, for example to compare the effects of
+// different build settings without rasterizing the input geometry again.
+//
+// see Heightfield.Clone, CopyCompactHeightfield, CopyPolyMesh
+func CopyHeightfield(ctx *BuildContext, src, dst *Heightfield) bool {
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	if int32(len(src.Spans)) != src.Width*src.Height {
+		ctx.Errorf("CopyHeightfield: invalid source heightfield.")
+		return false
+	}
+	if src != dst {
+		src.copyTo(dst)
+	}
+	return true
+}
+
+// copyTo resets dst then copies the spans of the heightfield into it.
+func (hf *Heightfield) copyTo(dst *Heightfield) {
+	dst.Reset(hf.Width, hf.Height, hf.BMin[:], hf.BMax[:], hf.Cs, hf.Ch)
+	for i, s := range hf.Spans {
+		var prev *Span
+		for ; s != nil; s = s.next {
+			cs := dst.allocSpan()
+			cs.smin, cs.smax, cs.area, cs.next = s.smin, s.smax, s.area, nil
+			if prev == nil {
+				dst.Spans[i] = cs
+			} else {
+				prev.next = cs
+			}
+			prev = cs
+		}
+	}
+}
+
 func (hf *Heightfield) Free() {
 	if hf == nil {
 		return
@@ -65,44 +157,37 @@ func (hf *Heightfield) Free() {
 	// Delete span array.
 	hf.Spans = make([]*Span, 0)
 	// Delete span pools.
-	for hf.Pools != nil {
-		next := hf.Pools.next
-		hf.Pools = nil
-		hf.Pools = next
-	}
+	hf.Pools = nil
+	hf.Freelist = nil
+	hf.pool = nil
+	hf.used = 0
 }
 
 func (hf *Heightfield) allocSpan() *Span {
-	// If running out of memory, allocate new page and update the freelist.
-	if hf.Freelist == nil || hf.Freelist.next == nil {
-		// Create new page.
-		// Allocate memory for the new pool.
-		pool := &spanPool{}
-		if pool == nil {
-			return nil
-		}
+	// Reuse a freed span first.
+	if it := hf.Freelist; it != nil {
+		hf.Freelist = it.next
+		return it
+	}
 
-		// Add the pool into the list of pools.
-		pool.next = hf.Pools
-		hf.Pools = pool
-		// Add new items to the free list.
-		freelist := hf.Freelist
-		var it *Span
-		for i := len(pool.items) - 1; i > 0; i-- {
-			it = &pool.items[i]
-			it.next = freelist
-			freelist = it
-
-			if i == 0 {
-				break
+	// Then take the next span of the current pool. If it's full, move to the
+	// next pool, allocating it if needed.
+	if hf.pool == nil || hf.used == RC_SPANS_PER_POOL {
+		if hf.pool != nil && hf.pool.next != nil {
+			hf.pool = hf.pool.next
+		} else {
+			pool := &spanPool{}
+			if hf.pool == nil {
+				hf.Pools = pool
+			} else {
+				hf.pool.next = pool
 			}
+			hf.pool = pool
 		}
-		hf.Freelist = it
+		hf.used = 0
 	}
-
-	// Pop item from in front of the free list.
-	it := hf.Freelist
-	hf.Freelist = hf.Freelist.next
+	it := &hf.pool.items[hf.used]
+	hf.used++
 	return it
 }
 
@@ -115,6 +200,45 @@ func (hf *Heightfield) freeSpan(ptr *Span) {
 	hf.Freelist = ptr
 }
 
+// AddSpan adds a span to the specified heightfield.
+//
+//	Arguments:
+//	 ctx           The build context to use during the operation.
+//	 x             The width index where the span is to be added.
+//	               [Limits: 0 <= value < Width]
+//	 z             The height index where the span is to be added.
+//	               [Limits: 0 <= value < Height]
+//	 smin          The minimum height of the span. [Limit: < smax] [Units: vx]
+//	 smax          The maximum height of the span. [Units: vx]
+//	 area          The area id of the span. [Limit: <= WalkableArea]
+//	 flagMergeThr  The merge theshold. [Limit: >= 0] [Units: vx]
+//
+// Returns true if the span has been added.
+//
+// The span is merged with the spans of the column it overlaps, the same way
+// the rasterized triangles are. AddSpan lets spans coming from another source
+// than a triangle mesh, a physics voxelization for example, be added to the
+// heightfield. See also RasterizeSpans.
+func (hf *Heightfield) AddSpan(ctx *BuildContext, x, z int32, smin, smax uint16,
+	area uint8, flagMergeThr int32) bool {
+
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	if x < 0 || x >= hf.Width || z < 0 || z >= hf.Height {
+		ctx.Errorf("AddSpan: cell (%d, %d) out of the %d x %d grid.", x, z, hf.Width, hf.Height)
+		return false
+	}
+	if smin >= smax {
+		ctx.Errorf("AddSpan: empty span [%d, %d].", smin, smax)
+		return false
+	}
+	if !hf.addSpan(x, z, smin, smax, area, flagMergeThr) {
+		ctx.Errorf("AddSpan: Out of memory.")
+		return false
+	}
+	return true
+}
+
 func (hf *Heightfield) addSpan(x, y int32, smin, smax uint16,
 	area uint8, flagMergeThr int32) bool {
 
@@ -226,6 +350,43 @@ type CompactHeightfield struct {
 	Areas          []uint8       // Array containing area id data. [Size: SpanCount]
 }
 
+// CopyCompactHeightfield copies a compact heightfield into another.
+//
+//	Arguments:
+//	 ctx      The build context to use during the operation.
+//	 src      The compact heightfield to copy.
+//	 dst      The resulting compact heightfield. Its memory is reused.
+//
+// Returns true if the operation completed successfully.
+//
+// dst is a deep copy of src, including its distance field and regions, if
+// they have been built.
+//
+// see CopyHeightfield, CopyPolyMesh
+func CopyCompactHeightfield(ctx *BuildContext, src, dst *CompactHeightfield) bool {
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	if int32(len(src.Cells)) != src.Width*src.Height ||
+		int32(len(src.Spans)) != src.SpanCount || int32(len(src.Areas)) != src.SpanCount {
+		ctx.Errorf("CopyCompactHeightfield: invalid source compact heightfield.")
+		return false
+	}
+	if src == dst {
+		return true
+	}
+
+	cells, spans, dist, areas := dst.Cells, dst.Spans, dst.Dist, dst.Areas
+	*dst = *src
+	dst.Cells = append(cells[:0], src.Cells...)
+	dst.Spans = append(spans[:0], src.Spans...)
+	dst.Areas = append(areas[:0], src.Areas...)
+	dst.Dist = nil
+	if src.Dist != nil {
+		dst.Dist = append(dist[:0], src.Dist...)
+	}
+	return true
+}
+
 func (hf *Heightfield) GetHeightFieldSpanCount(ctx *BuildContext) int32 {
 	w := hf.Width
 	h := hf.Height
@@ -0,0 +1,137 @@
+package recast
+
+import (
+	"math"
+
+	"github.com/arl/assertgo"
+	"github.com/arl/math32"
+)
+
+// heightmap is a regular grid of heights covering the xz-plane bounds of a
+// heightfield.
+type heightmap struct {
+	heights    [][]float32 // The heights, [z][x].
+	nx, nz     int         // The number of samples along the x and z axes.
+	ox, oz     float32     // The position of the first sample.
+	sx, sz     float32     // The distance between 2 samples.
+	isx, isz   float32     // The inverse of sx and sz.
+	xbuf, zbuf []float32   // Buffers for the evaluation points.
+}
+
+// at returns the height, bilinearly interpolated, at (x, z).
+func (hm *heightmap) at(x, z float32) float32 {
+	sample := func(pos, origin, inv float32, n int) (int, float32) {
+		u := (pos - origin) * inv
+		i := int(math32.Floor(u))
+		if i < 0 {
+			return 0, 0
+		}
+		if i >= n-1 {
+			return n - 2, 1
+		}
+		return i, u - float32(i)
+	}
+	i, u := sample(x, hm.ox, hm.isx, hm.nx)
+	j, v := sample(z, hm.oz, hm.isz, hm.nz)
+	h0 := hm.heights[j][i]*(1-u) + hm.heights[j][i+1]*u
+	h1 := hm.heights[j+1][i]*(1-u) + hm.heights[j+1][i+1]*u
+	return h0*(1-v) + h1*v
+}
+
+// points returns, appended to dst, the bounds of the range [min, max] and the
+// positions of the samples strictly within it.
+func points(dst []float32, min, max, origin, spacing, inv float32) []float32 {
+	dst = append(dst, min)
+	for i := math32.Floor((min-origin)*inv) + 1; origin+i*spacing < max; i++ {
+		dst = append(dst, origin+i*spacing)
+	}
+	return append(dst, max)
+}
+
+// RasterizeHeightmap rasterizes a regular grid of heights, such as a terrain
+// heightmap, into the specified heightfield.
+//
+//	Arguments:
+//	ctx                 The build context to use during the operation.
+//	solid               An initialized heightfield.
+//	heights             The heights, [z][x]. [Size: >= 2 x 2] [Units: wu]
+//	walkableSlopeAngle  The maximum slope that is considered walkable.
+//	                    [Limits: 0 <= value < 90] [Units: Degrees]
+//	flagMergeThr        The distance where the walkable flag is favored over
+//	                    the non-walkable flag. [Limit: >= 0] [Units: vx]
+//
+// Returns True if the operation completed successfully.
+//
+// The heightmap covers the xz-plane bounds of the heightfield: heights[0][0]
+// is the height at (BMin[0], BMin[2]) and the last sample of the last row the
+// height at (BMax[0], BMax[2]), the surface between the samples is bilinearly
+// interpolated. Each cell of the heightfield gets a span from the lowest to
+// the highest point of the surface over the cell, walkable if the slope of
+// the surface over the cell is below walkableSlopeAngle.
+//
+// This is much faster, and uses much less memory, than triangulating the
+// heightmap before rasterizing the triangles. All the rows of heights must
+// have the same length.
+//
+// see Heightfield, RasterizeSpans
+func RasterizeHeightmap(ctx *BuildContext, solid *Heightfield, heights [][]float32,
+	walkableSlopeAngle float32, flagMergeThr int32) bool {
+
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	if len(heights) < 2 || len(heights[0]) < 2 {
+		ctx.Errorf("RasterizeHeightmap: the heightmap should have at least 2 x 2 samples.")
+		return false
+	}
+	for _, row := range heights {
+		if len(row) != len(heights[0]) {
+			ctx.Errorf("RasterizeHeightmap: the rows of the heightmap should have the same length.")
+			return false
+		}
+	}
+
+	hm := heightmap{
+		heights: heights,
+		nx:      len(heights[0]),
+		nz:      len(heights),
+		ox:      solid.BMin[0],
+		oz:      solid.BMin[2],
+	}
+	hm.sx = (solid.BMax[0] - solid.BMin[0]) / float32(hm.nx-1)
+	hm.sz = (solid.BMax[2] - solid.BMin[2]) / float32(hm.nz-1)
+	hm.isx, hm.isz = 1/hm.sx, 1/hm.sz
+
+	walkableThr := math32.Cos(walkableSlopeAngle / 180.0 * math.Pi)
+
+	terrain := func(x, z int32, spans []SolidSpan) []SolidSpan {
+		x0 := solid.BMin[0] + float32(x)*solid.Cs
+		z0 := solid.BMin[2] + float32(z)*solid.Cs
+		x1, z1 := x0+solid.Cs, z0+solid.Cs
+
+		// The bilinear surface is linear along the axes between the samples,
+		// its extrema over the cell are at the corners of the cell, at the
+		// samples in the cell and where the grid lines cross the cell edges.
+		hm.xbuf = points(hm.xbuf[:0], x0, x1, hm.ox, hm.sx, hm.isx)
+		hm.zbuf = points(hm.zbuf[:0], z0, z1, hm.oz, hm.sz, hm.isz)
+		ymin, ymax := float32(math.MaxFloat32), float32(-math.MaxFloat32)
+		for _, pz := range hm.zbuf {
+			for _, px := range hm.xbuf {
+				y := hm.at(px, pz)
+				ymin = math32.Min(ymin, y)
+				ymax = math32.Max(ymax, y)
+			}
+		}
+
+		// Slope of the surface over the cell, from the heights at its corners.
+		h00, h10 := hm.at(x0, z0), hm.at(x1, z0)
+		h01, h11 := hm.at(x0, z1), hm.at(x1, z1)
+		dx := (h10 + h11 - h00 - h01) / (2 * solid.Cs)
+		dz := (h01 + h11 - h00 - h10) / (2 * solid.Cs)
+		area := nullArea
+		if 1/math32.Sqrt(1+dx*dx+dz*dz) > walkableThr {
+			area = WalkableArea
+		}
+		return append(spans, SolidSpan{YMin: ymin, YMax: ymax, Area: area})
+	}
+	return RasterizeSpans(ctx, solid, terrain, flagMergeThr)
+}
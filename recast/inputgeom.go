@@ -3,6 +3,10 @@ package recast
 import (
 	"fmt"
 	"io"
+	"math"
+	"sort"
+
+	"github.com/arl/math32"
 )
 
 const (
@@ -11,6 +15,8 @@ const (
 	maxConvexVolPts       = 12
 )
 
+// ConvexVolume is a convex volume, added to the input geometry, whose spans
+// get marked with a specific area id during the build.
 type ConvexVolume struct {
 	Verts      [maxConvexVolPts * 3]float32
 	HMin, HMax float32
@@ -37,6 +43,14 @@ type BuildSettings struct {
 	// Agent max slope in degrees
 	AgentMaxSlope float32
 
+	// Radius, in world units, by which the walkable area gets eroded away from
+	// obstructions. If zero, AgentRadius is used.
+	ErosionRadius float32
+
+	// Apply a median filter on the area ids, once the walkable area has been
+	// eroded and the convex volumes marked
+	MedianFilter bool
+
 	// Region minimum size in voxels.
 	// regionMinSize = sqrt(regionMinArea)
 	RegionMinSize float32
@@ -51,6 +65,12 @@ type BuildSettings struct {
 	// Edge max error in voxels
 	EdgeMaxError float32
 
+	// Contour build flags, selecting the edges tessellated so that they're
+	// not longer than EdgeMaxLen: wall edges, edges between areas, or both.
+	// Long edges are not tessellated if zero.
+	// (See: ContourTessWallEdges, ContourTessAreaEdges)
+	ContourFlags int32
+
 	// VertsPerPolys is the number of vertices to consider per polygons
 	VertsPerPoly float32
 
@@ -65,12 +85,76 @@ type BuildSettings struct {
 
 	// Size of the tiles in voxels
 	TileSize float32
+
+	// Convex volumes used to mark areas (water, grass, door, etc.) with
+	// custom area ids
+	ConvexVolumes []ConvexVolumeSettings
+
+	// Modifier volumes making areas walkable, non-walkable or overriding
+	// their area ids, applied before erosion
+	ModifierVolumes []ModifierVolumeSettings
+}
+
+// ModifierVolumeSettings describes a modifier volume, as found in build
+// settings.
+//
+// The volume is a convex polygon, projected onto the xz-plane at HMin then
+// extruded to HMax. It modifies the spans it contains according to its Mode.
+//
+// see MarkModifierVolumes
+type ModifierVolumeSettings struct {
+	// Polygon vertices in world units [(x, y, z) * number of vertices]
+	Verts []float32
+
+	// Minimum and maximum heights of the volume in world units
+	HMin, HMax float32
+
+	// Modifier mode, ModifierArea, ModifierNotWalkable or ModifierWalkable
+	Mode int32
+
+	// Area id to apply, in ModifierArea and ModifierWalkable modes. In
+	// ModifierWalkable mode, zero means WalkableArea
+	Area uint8
+
+	// Volumes are applied by increasing priority, the highest priority wins
+	Priority int32
+}
+
+// ConvexVolumeSettings describes a convex volume, as found in build settings.
+//
+// The volume is a convex polygon, projected onto the xz-plane at HMin then
+// extruded to HMax. All the spans it contains get marked with Area.
+type ConvexVolumeSettings struct {
+	// Polygon vertices in world units [(x, y, z) * number of vertices]
+	Verts []float32
+
+	// Minimum and maximum heights of the volume in world units
+	HMin, HMax float32
+
+	// Area id to apply
+	Area uint8
+}
+
+// MeshPart is a part of the input geometry mesh, added with
+// InputGeom.AddMesh. The walkable triangles of a part get its area id, and
+// their slope may be checked against a specific maximum.
+type MeshPart struct {
+	// Index of the first triangle of the part in the input mesh, and number
+	// of triangles
+	FirstTri, TriCount int32
+
+	// Area id of the walkable triangles, zero means WalkableArea
+	Area uint8
+
+	// Maximum walkable slope in degrees, zero means the agent max slope
+	MaxSlope float32
 }
 
 // InputGeom gathers the geometry used as input for navigation mesh building.
 type InputGeom struct {
 	chunkyMesh *ChunkyTriMesh
 	mesh       *MeshLoaderOBJ
+	parts      []MeshPart
 
 	meshBMin, meshBMax [3]float32
 
@@ -98,11 +182,124 @@ func (ig *InputGeom) LoadOBJMesh(r io.Reader) error {
 	ig.offMeshConCount = 0
 	ig.volumeCount = 0
 
-	ig.mesh = NewMeshLoaderOBJ()
-	if err = ig.mesh.Load(r); err != nil {
+	mesh := NewMeshLoaderOBJ()
+	if err = mesh.Load(r); err != nil {
 		return err
 	}
+	return ig.SetMesh(mesh)
+}
+
+// SetMesh sets the static mesh data of the input geometry. It allows to build
+// the input geometry from a mesh that has been loaded, transformed or merged
+// beforehand.
+func (ig *InputGeom) SetMesh(mesh *MeshLoaderOBJ) error {
+	ig.mesh = mesh
+	ig.parts = nil
+	ig.offMeshConCount = 0
+	ig.volumeCount = 0
+	return ig.updateMesh()
+}
+
+// AddMesh merges mesh into the static mesh data of the input geometry, as a
+// new mesh part.
+//
+//	Arguments:
+//	 mesh     The mesh to add.
+//	 area     The area id of the walkable triangles of mesh, zero means
+//	          WalkableArea.
+//	 maxSlope The maximum walkable slope of the triangles of mesh, overriding
+//	          the agent max slope if not zero. [Units: Degrees]
+//
+// This allows to build a navigation mesh from several meshes, giving for
+// example the road area id to the walkable triangles of a road mesh. The
+// triangles of the mesh set with SetMesh or LoadOBJMesh, if any, use the
+// defaults. The off-mesh connections and the convex volumes are kept.
+//
+// see MeshParts, MarkWalkableTriangles
+func (ig *InputGeom) AddMesh(mesh *MeshLoaderOBJ, area uint8, maxSlope float32) error {
+	if ig.mesh == nil {
+		ig.mesh = NewMeshLoaderOBJ()
+	}
+	ig.parts = append(ig.parts, MeshPart{
+		FirstTri: ig.mesh.TriCount(),
+		TriCount: mesh.TriCount(),
+		Area:     area,
+		MaxSlope: maxSlope,
+	})
+	ig.mesh.Merge(mesh)
+	return ig.updateMesh()
+}
+
+// MeshParts returns the mesh parts added with AddMesh.
+func (ig *InputGeom) MeshParts() []MeshPart {
+	return ig.parts
+}
+
+// MarkWalkableTriangles sets the area id of the walkable triangles of the
+// input mesh, according to the mesh part they belong to.
+//
+//	Arguments:
+//	 ctx                The build context to use during the operation.
+//	 walkableSlopeAngle The maximum slope that is considered walkable, for the
+//	                    triangles of the parts not overriding it.
+//	                    [Limits: 0 <= value < 90] [Units: Degrees]
+//	 ids                The indices of the triangles in the input mesh, as
+//	                    found in ChunkyTriMesh.TriIDs, or nil for the first nt
+//	                    triangles. [Length: >= nt]
+//	 nt                 The number of triangles.
+//	 areas              The triangle area ids. [Length: >= nt]
+//
+// As the package level MarkWalkableTriangles, only sets the area id's of the
+// walkable triangles.
+func (ig *InputGeom) MarkWalkableTriangles(ctx *BuildContext, walkableSlopeAngle float32, ids []int32, nt int32, areas []uint8) {
+	var (
+		verts  = ig.mesh.Verts()
+		tris   = ig.mesh.Tris()
+		defThr = math32.Cos(walkableSlopeAngle / 180.0 * math.Pi)
+		norm   [3]float32
+	)
+
+	// Triangles are mostly looked up in order, keep the current part.
+	var (
+		cur         = -1
+		walkableThr = defThr
+		area        = WalkableArea
+	)
+	for i := int32(0); i < nt; i++ {
+		id := i
+		if ids != nil {
+			id = ids[i]
+		}
+		if cur < 0 || id < ig.parts[cur].FirstTri || id >= ig.parts[cur].FirstTri+ig.parts[cur].TriCount {
+			cur = sort.Search(len(ig.parts), func(j int) bool {
+				return ig.parts[j].FirstTri+ig.parts[j].TriCount > id
+			})
+			if cur == len(ig.parts) || id < ig.parts[cur].FirstTri {
+				cur = -1
+			}
+			walkableThr, area = defThr, WalkableArea
+			if cur >= 0 {
+				p := &ig.parts[cur]
+				if p.MaxSlope != 0 {
+					walkableThr = math32.Cos(p.MaxSlope / 180.0 * math.Pi)
+				}
+				if p.Area != 0 {
+					area = p.Area
+				}
+			}
+		}
+
+		tri := tris[id*3:]
+		calcTriNormal(verts[tri[0]*3:], verts[tri[1]*3:], verts[tri[2]*3:], norm[:])
+		if norm[1] > walkableThr {
+			areas[i] = area
+		}
+	}
+}
 
+// updateMesh updates the bounds and the chunky mesh once the static mesh data
+// has been modified.
+func (ig *InputGeom) updateMesh() error {
 	CalcBounds(ig.mesh.Verts(), ig.mesh.VertCount(), ig.meshBMin[:], ig.meshBMax[:])
 
 	ig.chunkyMesh = new(ChunkyTriMesh)
@@ -209,9 +406,9 @@ func (ig *InputGeom) OffMeshConnectionCount() int32 {
 // AddConvexVolume adds a new convex volume to the input geometry.
 //
 // The convex volume is defined by the verts slice [x, y, z] * Number of
-// vertices.
+// vertices. Volumes with more than 12 vertices are ignored.
 func (ig *InputGeom) AddConvexVolume(verts []float32, minh, maxh float32, area uint8) {
-	if ig.volumeCount >= maxVolumes {
+	if ig.volumeCount >= maxVolumes || len(verts) > maxConvexVolPts*3 {
 		return
 	}
 	vol := &ig.volumes[ig.volumeCount]
@@ -219,7 +416,7 @@ func (ig *InputGeom) AddConvexVolume(verts []float32, minh, maxh float32, area u
 	copy(vol.Verts[:], verts)
 	vol.HMin = minh
 	vol.HMax = maxh
-	vol.NVerts = int32(len(verts))
+	vol.NVerts = int32(len(verts) / 3)
 	vol.Area = int32(area)
 }
 
@@ -229,3 +426,46 @@ func (ig *InputGeom) DeleteConvexVolume(i int) {
 	// copy last volume over the deleted one
 	ig.volumes[i] = ig.volumes[ig.volumeCount]
 }
+
+// AddOffMeshConnection adds a new off-mesh connection to the input geometry.
+//
+//	Arguments:
+//	 spos     The start position of the connection. [(x, y, z)]
+//	 epos     The end position of the connection. [(x, y, z)]
+//	 rad      The radius of the connection endpoints.
+//	 bidir    Whether the connection can be traversed in both directions.
+//	 area     The area id of the connection.
+//	 flags    The polygon flags of the connection.
+//
+// The connection is ignored if the input geometry already has the maximum
+// number of off-mesh connections (256).
+func (ig *InputGeom) AddOffMeshConnection(spos, epos []float32, rad float32, bidir bool, area uint8, flags uint16) {
+	if ig.offMeshConCount >= maxOffMeshConnections {
+		return
+	}
+	i := ig.offMeshConCount
+	ig.offMeshConCount++
+	copy(ig.offMeshConVerts[i*3*2:], spos[:3])
+	copy(ig.offMeshConVerts[i*3*2+3:], epos[:3])
+	ig.offMeshConRads[i] = rad
+	ig.offMeshConDirs[i] = 0
+	if bidir {
+		ig.offMeshConDirs[i] = 1
+	}
+	ig.offMeshConAreas[i] = area
+	ig.offMeshConFlags[i] = flags
+	ig.offMeshConID[i] = uint32(1000 + i)
+}
+
+// DeleteOffMeshConnection deletes the ith off-mesh connection.
+func (ig *InputGeom) DeleteOffMeshConnection(i int) {
+	ig.offMeshConCount--
+	// copy last connection over the deleted one
+	last := int(ig.offMeshConCount)
+	copy(ig.offMeshConVerts[i*3*2:i*3*2+6], ig.offMeshConVerts[last*3*2:last*3*2+6])
+	ig.offMeshConRads[i] = ig.offMeshConRads[last]
+	ig.offMeshConDirs[i] = ig.offMeshConDirs[last]
+	ig.offMeshConAreas[i] = ig.offMeshConAreas[last]
+	ig.offMeshConFlags[i] = ig.offMeshConFlags[last]
+	ig.offMeshConID[i] = ig.offMeshConID[last]
+}
@@ -0,0 +1,50 @@
+package recast
+
+import "unsafe"
+
+// The memory sizes returned by the MemorySize methods are the sizes, in bytes,
+// of the in-memory structures, allocated capacity included. They're meant to
+// budget the memory of a navigation mesh build, on consoles or mobile devices
+// for example.
+
+// MemorySize returns the memory used by the heightfield, in bytes.
+//
+// It includes the grid of span columns and all the span pools, whether their
+// spans are in use or in the free list.
+func (hf *Heightfield) MemorySize() int {
+	n := int(unsafe.Sizeof(*hf)) + int(unsafe.Sizeof((*Span)(nil)))*cap(hf.Spans)
+	for p := hf.Pools; p != nil; p = p.next {
+		n += int(unsafe.Sizeof(*p))
+	}
+	return n
+}
+
+// MemorySize returns the memory used by the compact heightfield, in bytes.
+func (chf *CompactHeightfield) MemorySize() int {
+	return int(unsafe.Sizeof(*chf)) +
+		int(unsafe.Sizeof(CompactCell{}))*cap(chf.Cells) +
+		int(unsafe.Sizeof(CompactSpan{}))*cap(chf.Spans) +
+		2*cap(chf.Dist) + cap(chf.Areas)
+}
+
+// MemorySize returns the memory used by the contour set, in bytes.
+func (cset *ContourSet) MemorySize() int {
+	n := int(unsafe.Sizeof(*cset)) + int(unsafe.Sizeof(Contour{}))*cap(cset.Conts)
+	for i := range cset.Conts {
+		n += 4 * (cap(cset.Conts[i].Verts) + cap(cset.Conts[i].RVerts))
+	}
+	return n
+}
+
+// MemorySize returns the memory used by the polygon mesh, in bytes.
+func (mesh *PolyMesh) MemorySize() int {
+	return int(unsafe.Sizeof(*mesh)) +
+		2*(cap(mesh.Verts)+cap(mesh.Polys)+cap(mesh.Regs)+cap(mesh.Flags)) +
+		cap(mesh.Areas)
+}
+
+// MemorySize returns the memory used by the detail mesh, in bytes.
+func (dmesh *PolyMeshDetail) MemorySize() int {
+	return int(unsafe.Sizeof(*dmesh)) +
+		4*(cap(dmesh.Meshes)+cap(dmesh.Verts)) + cap(dmesh.Tris)
+}
@@ -420,6 +420,11 @@ func BuildPolyMeshDetail(ctx *BuildContext, mesh *PolyMesh, chf *CompactHeightfi
 	dmesh.Tris = make([]uint8, tcap*4)
 
 	for i := int32(0); i < mesh.NPolys; i++ {
+		if ctx.Cancelled() {
+			ctx.Errorf("BuildPolyMeshDetail: Build cancelled.")
+			return nil, false
+		}
+		ctx.progress(TimerBuildPolyMeshDetail, i, mesh.NPolys)
 		p := mesh.Polys[i*nvp*2:]
 
 		// Store polygon vertices for processing.
@@ -513,6 +518,8 @@ func BuildPolyMeshDetail(ctx *BuildContext, mesh *PolyMesh, chf *CompactHeightfi
 		}
 	}
 
+	ctx.progress(TimerBuildPolyMeshDetail, mesh.NPolys, mesh.NPolys)
+
 	return &dmesh, true
 }
 
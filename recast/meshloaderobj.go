@@ -2,6 +2,7 @@ package recast
 
 import (
 	"io"
+	"math"
 
 	"github.com/arl/gobj"
 	"github.com/arl/math32"
@@ -58,7 +59,12 @@ func (mlo *MeshLoaderOBJ) Load(r io.Reader) error {
 		}
 	}
 
-	// Calculate normals.
+	mlo.calcNormals()
+	return nil
+}
+
+// calcNormals computes the triangle normals.
+func (mlo *MeshLoaderOBJ) calcNormals() {
 	// TODO: factor this with recast.calcTriNormal
 	var e0, e1 [3]float32
 	mlo.normals = make([]float32, len(mlo.tris))
@@ -82,8 +88,69 @@ func (mlo *MeshLoaderOBJ) Load(r io.Reader) error {
 			n[2] *= d
 		}
 	}
+}
 
-	return nil
+// MeshTransform is a transform applied to the vertices of a mesh.
+//
+// Vertices are first scaled, then rotated around the x, y and z axes, in that
+// order, and finally translated.
+type MeshTransform struct {
+	// Translation in world units [(x, y, z)]
+	Translate [3]float32
+
+	// Rotation angles around the x, y and z axes, in degrees
+	Rotate [3]float32
+
+	// Uniform scale factor, zero means no scaling
+	Scale float32
+}
+
+// Transform applies xf to the mesh vertices.
+func (mlo *MeshLoaderOBJ) Transform(xf MeshTransform) {
+	scale := xf.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	var sin, cos [3]float32
+	for i, a := range xf.Rotate {
+		sin[i] = math32.Sin(a / 180.0 * math.Pi)
+		cos[i] = math32.Cos(a / 180.0 * math.Pi)
+	}
+
+	for i := 0; i < len(mlo.verts); i += 3 {
+		x := mlo.verts[i] * scale
+		y := mlo.verts[i+1] * scale
+		z := mlo.verts[i+2] * scale
+
+		// around x
+		y, z = y*cos[0]-z*sin[0], y*sin[0]+z*cos[0]
+		// around y
+		x, z = x*cos[1]+z*sin[1], -x*sin[1]+z*cos[1]
+		// around z
+		x, y = x*cos[2]-y*sin[2], x*sin[2]+y*cos[2]
+
+		mlo.verts[i] = x + xf.Translate[0]
+		mlo.verts[i+1] = y + xf.Translate[1]
+		mlo.verts[i+2] = z + xf.Translate[2]
+	}
+
+	if scale < 0 {
+		// a negative scale mirrors the mesh, restore the triangles winding.
+		for i := 0; i < len(mlo.tris); i += 3 {
+			mlo.tris[i+1], mlo.tris[i+2] = mlo.tris[i+2], mlo.tris[i+1]
+		}
+	}
+	mlo.calcNormals()
+}
+
+// Merge appends the vertices and triangles of other to the mesh.
+func (mlo *MeshLoaderOBJ) Merge(other *MeshLoaderOBJ) {
+	base := mlo.VertCount()
+	mlo.verts = append(mlo.verts, other.verts...)
+	for _, idx := range other.tris {
+		mlo.tris = append(mlo.tris, base+idx)
+	}
+	mlo.normals = append(mlo.normals, other.normals...)
 }
 
 func (mlo *MeshLoaderOBJ) Scale() float32 {
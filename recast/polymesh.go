@@ -36,6 +36,41 @@ func (pm *PolyMesh) Free() {
 	pm = nil
 }
 
+// CopyPolyMesh copies a polygon mesh into another.
+//
+//	Arguments:
+//	 ctx      The build context to use during the operation.
+//	 src      The polygon mesh to copy.
+//	 dst      The resulting polygon mesh. Its memory is reused.
+//
+// Returns true if the operation completed successfully.
+//
+// dst is a deep copy of src, modifying one leaves the other untouched.
+//
+// see CopyHeightfield, CopyCompactHeightfield
+func CopyPolyMesh(ctx *BuildContext, src, dst *PolyMesh) bool {
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	if int32(len(src.Verts)) < src.NVerts*3 || int32(len(src.Polys)) < src.NPolys*2*src.Nvp ||
+		int32(len(src.Regs)) < src.NPolys || int32(len(src.Flags)) < src.NPolys ||
+		int32(len(src.Areas)) < src.NPolys {
+		ctx.Errorf("CopyPolyMesh: invalid source polygon mesh.")
+		return false
+	}
+	if src == dst {
+		return true
+	}
+
+	verts, polys, regs, flags, areas := dst.Verts, dst.Polys, dst.Regs, dst.Flags, dst.Areas
+	*dst = *src
+	dst.Verts = append(verts[:0], src.Verts...)
+	dst.Polys = append(polys[:0], src.Polys...)
+	dst.Regs = append(regs[:0], src.Regs...)
+	dst.Flags = append(flags[:0], src.Flags...)
+	dst.Areas = append(areas[:0], src.Areas...)
+	return true
+}
+
 // BuildPolyMesh builds a polygon mesh from the provided contours.
 //
 //	Arguments:
@@ -117,6 +152,11 @@ func BuildPolyMesh(ctx *BuildContext, cset *ContourSet, nvp int32) (*PolyMesh, b
 	tmpPoly := polys[maxVertsPerCont*nvp:]
 
 	for i := int32(0); i < cset.NConts; i++ {
+		if ctx.Cancelled() {
+			ctx.Errorf("BuildPolyMesh: Build cancelled.")
+			return nil, false
+		}
+		ctx.progress(TimerBuildPolymesh, i, cset.NConts)
 		cont := &cset.Conts[i]
 
 		// Skip null contours.
@@ -307,5 +347,7 @@ func BuildPolyMesh(ctx *BuildContext, cset *ContourSet, nvp int32) (*PolyMesh, b
 		ctx.Errorf("BuildPolyMesh: The resulting mesh has too many polygons %d (max %d). Data can be corrupted.", mesh.NPolys, 0xffff)
 	}
 
+	ctx.progress(TimerBuildPolymesh, cset.NConts, cset.NConts)
+
 	return mesh, true
 }
@@ -72,6 +72,11 @@ func RasterizeTriangles(ctx *BuildContext, verts []float32, nv int32,
 	ich := 1.0 / solid.Ch
 	// Rasterize triangles.
 	for i := int32(0); i < nt; i++ {
+		if ctx.Cancelled() {
+			ctx.Errorf("RasterizeTriangles: Build cancelled.")
+			return false
+		}
+		ctx.progress(TimerRasterizeTriangles, i, nt)
 		v0 := verts[tris[i*3+0]*3:]
 		v1 := verts[tris[i*3+1]*3:]
 		v2 := verts[tris[i*3+2]*3:]
@@ -82,6 +87,70 @@ func RasterizeTriangles(ctx *BuildContext, verts []float32, nv int32,
 		}
 	}
 
+	ctx.progress(TimerRasterizeTriangles, nt, nt)
+
+	return true
+}
+
+// TriAreaFunc returns the area id of a triangle, given its index in the
+// triangle indices passed to RasterizeTrianglesFunc.
+//
+// It allows to derive the area ids from the game assets metadata, as the
+// material of each triangle, instead of only from their slope.
+type TriAreaFunc func(tri int32) uint8
+
+// RasterizeTrianglesFunc rasterizes an indexed triangle mesh into the
+// specified heightfield, the area id of each triangle being given by a
+// function.
+//
+//	Arguments:
+//	ctx           The build context to use during the operation.
+//	verts         The vertices. [(x, y, z) * @p nv]
+//	nv            The number of vertices.
+//	tris          The triangle indices. [(vertA, vertB, vertC) * @p nt]
+//	nt            The number of triangles.
+//	area          The function returning the area id of a triangle.
+//	              [Limit: <= #RC_WALKABLE_AREA]
+//	solid         An initialized heightfield.
+//	flagMergeThr  The distance where the walkable flag is favored over the
+//	              non-walkable flag. [Limit: >= 0] [Units: vx]
+//
+//	Returns True if the operation completed successfully.
+//
+// The area function is called once per triangle, in order. Spans will only be
+// added for triangles that overlap the heightfield grid.
+//
+// see Heightfield, RasterizeTriangles
+func RasterizeTrianglesFunc(ctx *BuildContext, verts []float32, nv int32,
+	tris []int32, nt int32, area TriAreaFunc,
+	solid *Heightfield, flagMergeThr int32) bool {
+
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	ctx.StartTimer(TimerRasterizeTriangles)
+	defer ctx.StopTimer(TimerRasterizeTriangles)
+
+	ics := 1.0 / solid.Cs
+	ich := 1.0 / solid.Ch
+	// Rasterize triangles.
+	for i := int32(0); i < nt; i++ {
+		if ctx.Cancelled() {
+			ctx.Errorf("RasterizeTrianglesFunc: Build cancelled.")
+			return false
+		}
+		ctx.progress(TimerRasterizeTriangles, i, nt)
+		v0 := verts[tris[i*3+0]*3:]
+		v1 := verts[tris[i*3+1]*3:]
+		v2 := verts[tris[i*3+2]*3:]
+		// Rasterize.
+		if !rasterizeTri(v0, v1, v2, area(i), solid, solid.BMin[:], solid.BMax[:], solid.Cs, ics, ich, flagMergeThr) {
+			ctx.Errorf("RasterizeTrianglesFunc: Out of memory.")
+			return false
+		}
+	}
+
+	ctx.progress(TimerRasterizeTriangles, nt, nt)
+
 	return true
 }
 
@@ -114,6 +183,11 @@ func RasterizeTriangles2(ctx *BuildContext, verts []float32, areas []uint8, nt i
 	ich := float32(1.0 / solid.Ch)
 	// Rasterize triangles.
 	for i := int32(0); i < nt; i++ {
+		if ctx.Cancelled() {
+			ctx.Errorf("RasterizeTriangles2: Build cancelled.")
+			return false
+		}
+		ctx.progress(TimerRasterizeTriangles, i, nt)
 		v0 := verts[(i*3+0)*3:]
 		v1 := verts[(i*3+1)*3:]
 		v2 := verts[(i*3+2)*3:]
@@ -124,6 +198,85 @@ func RasterizeTriangles2(ctx *BuildContext, verts []float32, areas []uint8, nt i
 		}
 	}
 
+	ctx.progress(TimerRasterizeTriangles, nt, nt)
+
+	return true
+}
+
+// SolidSpan is a span of solid space returned by a SpanFunc.
+type SolidSpan struct {
+	YMin, YMax float32 // The lower and upper limits of the span. [Units: wu]
+	Area       uint8   // The area id of the span. [Limit: <= WalkableArea]
+}
+
+// SpanFunc returns the solid spans of the column (x, z) of a heightfield,
+// appended to spans.
+//
+// The spans of a column may overlap, and may be returned in any order. A flat
+// span, with YMin equal to YMax, gets the minimum height of a voxel, as a flat
+// triangle does.
+type SpanFunc func(x, z int32, spans []SolidSpan) []SolidSpan
+
+// RasterizeSpans adds the solid spans returned by fn, for each column, to the
+// specified heightfield.
+//
+//	Arguments:
+//	ctx           The build context to use during the operation.
+//	solid         An initialized heightfield.
+//	fn            The function returning the solid spans of each column.
+//	flagMergeThr  The distance where the walkable flag is favored over the
+//	              non-walkable flag. [Limit: >= 0] [Units: vx]
+//
+// Returns True if the operation completed successfully.
+//
+// RasterizeSpans lets the heightfield be built, or completed, from another
+// source than a triangle mesh, such as a physics voxelization or a procedural
+// terrain function. The spans are clamped to the heightfield bounds and
+// snapped to its height grid, as the rasterized triangles are.
+//
+// see Heightfield, Heightfield.AddSpan
+func RasterizeSpans(ctx *BuildContext, solid *Heightfield, fn SpanFunc, flagMergeThr int32) bool {
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	ich := 1.0 / solid.Ch
+	by := solid.BMax[1] - solid.BMin[1]
+
+	var spans []SolidSpan
+	for z := int32(0); z < solid.Height; z++ {
+		if ctx.Cancelled() {
+			ctx.Errorf("RasterizeSpans: Build cancelled.")
+			return false
+		}
+		for x := int32(0); x < solid.Width; x++ {
+			spans = fn(x, z, spans[:0])
+			for _, sp := range spans {
+				smin := sp.YMin - solid.BMin[1]
+				smax := sp.YMax - solid.BMin[1]
+				// Skip the invalid spans and the spans outside the heightfield
+				// bbox.
+				if !(smin <= smax) || smax < 0.0 || smin > by {
+					continue
+				}
+				// Clamp the span to the heightfield bbox.
+				if smin < 0.0 {
+					smin = 0
+				}
+				if smax > by {
+					smax = by
+				}
+
+				// Snap the span to the heightfield height grid.
+				ismin := uint16(int32Clamp(int32(math32.Floor(smin*ich)), 0, RC_SPAN_MAX_HEIGHT))
+				ismax := uint16(int32Clamp(int32(math32.Ceil(smax*ich)), int32(ismin+1), RC_SPAN_MAX_HEIGHT))
+
+				if !solid.addSpan(x, z, ismin, ismax, sp.Area, flagMergeThr) {
+					ctx.Errorf("RasterizeSpans: Out of memory.")
+					return false
+				}
+			}
+		}
+	}
+
 	return true
 }
 
@@ -172,11 +325,17 @@ func rasterizeTri(v0, v1, v2 []float32,
 	var nvrow, nvIn int32
 	nvIn = 3
 
-	for y := y0; y <= y1; y++ {
+	for y := y0; y <= y1 && nvIn > 0; y++ {
 		// Clip polygon to row. Store the remaining polygon as well
 		cz := bmin[2] + float32(y)*cs
-		nvrow, nvIn = dividePoly(in, nvIn, inrow, p1, cz+cs, 2)
-		in, p1 = p1, in
+		if allBefore(in, nvIn, cz+cs, 2) {
+			// The polygon ends in this row, no need to clip it.
+			in, inrow = inrow, in
+			nvrow, nvIn = nvIn, 0
+		} else {
+			nvrow, nvIn = dividePoly(in, nvIn, inrow, p1, cz+cs, 2)
+			in, p1 = p1, in
+		}
 		if nvrow < 3 {
 			continue
 		}
@@ -199,11 +358,17 @@ func rasterizeTri(v0, v1, v2 []float32,
 		var nv, nv2 int32
 		nv2 = nvrow
 
-		for x := x0; x <= x1; x++ {
+		for x := x0; x <= x1 && nv2 > 0; x++ {
 			// Clip polygon to column. store the remaining polygon as well
 			cx := bmin[0] + float32(x)*cs
-			nv, nv2 = dividePoly(inrow, nv2, p1, p2, cx+cs, 0)
-			inrow, p2 = p2, inrow
+			if allBefore(inrow, nv2, cx+cs, 0) {
+				// The polygon ends in this column, no need to clip it.
+				p1, inrow = inrow, p1
+				nv, nv2 = nv2, 0
+			} else {
+				nv, nv2 = dividePoly(inrow, nv2, p1, p2, cx+cs, 0)
+				inrow, p2 = p2, inrow
+			}
 			if nv < 3 {
 				continue
 			}
@@ -211,8 +376,11 @@ func rasterizeTri(v0, v1, v2 []float32,
 			// Calculate min and max of the span.
 			smin, smax := p1[1], p1[1]
 			for i := int32(1); i < nv; i++ {
-				smin = math32.Min(smin, p1[i*3+1])
-				smax = math32.Max(smax, p1[i*3+1])
+				if sy := p1[i*3+1]; sy < smin {
+					smin = sy
+				} else if sy > smax {
+					smax = sy
+				}
 			}
 			smin -= bmin[1]
 			smax -= bmin[1]
@@ -277,7 +445,12 @@ func int32Clamp(a, low, high int32) int32 {
 	return a
 }
 
-// divides a convex polygons into two convex polygons on both sides of a line
+// dividePoly divides a convex polygon into two convex polygons on both sides
+// of a line.
+//
+// The vertices of out1 are the ones for which x - v[axis] >= 0, the ones of
+// out2 are the others. Vertices on the line, and the intersections of the
+// polygon edges with it, belong to both polygons.
 func dividePoly(in []float32, nin int32,
 	out1 []float32, out2 []float32,
 	x float32, axis int32) (nout1, nout2 int32) {
@@ -286,47 +459,63 @@ func dividePoly(in []float32, nin int32,
 		d[i] = x - in[i*3+axis]
 	}
 
-	var (
-		m, n     int32
-		s        float32
-		ina, inb bool
-	)
+	var m, n int32
 	for i, j := int32(0), nin-1; i < nin; j, i = i, i+1 {
-		ina = d[j] >= 0
-		inb = d[i] >= 0
+		vi := in[i*3 : i*3+3 : i*3+3]
+		ina := d[j] >= 0
+		inb := d[i] >= 0
 		if ina != inb {
-			s = d[j] / (d[j] - d[i])
-			out1[m*3+0] = in[j*3+0] + (in[i*3+0]-in[j*3+0])*s
-			out1[m*3+1] = in[j*3+1] + (in[i*3+1]-in[j*3+1])*s
-			out1[m*3+2] = in[j*3+2] + (in[i*3+2]-in[j*3+2])*s
-
-			copy(out2[n*3:], out1[m*3:(m+1)*3])
+			vj := in[j*3 : j*3+3 : j*3+3]
+			s := d[j] / (d[j] - d[i])
+			px := vj[0] + (vi[0]-vj[0])*s
+			py := vj[1] + (vi[1]-vj[1])*s
+			pz := vj[2] + (vi[2]-vj[2])*s
+
+			o1 := out1[m*3 : m*3+3 : m*3+3]
+			o1[0], o1[1], o1[2] = px, py, pz
+			o2 := out2[n*3 : n*3+3 : n*3+3]
+			o2[0], o2[1], o2[2] = px, py, pz
 			m++
 			n++
 			// add the i'th point to the right polygon. Do NOT add points that
 			// are on the dividing line since these were already added above
 			if d[i] > 0 {
-				copy(out1[m*3:], in[i*3:(i+1)*3])
+				o1 = out1[m*3 : m*3+3 : m*3+3]
+				o1[0], o1[1], o1[2] = vi[0], vi[1], vi[2]
 				m++
 			} else if d[i] < 0 {
-				copy(out2[n*3:], in[i*3:(i+1)*3])
+				o2 = out2[n*3 : n*3+3 : n*3+3]
+				o2[0], o2[1], o2[2] = vi[0], vi[1], vi[2]
 				n++
 			}
 		} else {
 			// same side add the i'th point to the right polygon. Addition is
 			// done even for points on the dividing line
 			if d[i] >= 0 {
-				copy(out1[m*3:], in[i*3:(i+1)*3])
+				o1 := out1[m*3 : m*3+3 : m*3+3]
+				o1[0], o1[1], o1[2] = vi[0], vi[1], vi[2]
 				m++
 				if d[i] != 0 {
-					j = i
 					continue
 				}
 			}
-			copy(out2[n*3:], in[i*3:(i+1)*3])
+			o2 := out2[n*3 : n*3+3 : n*3+3]
+			o2[0], o2[1], o2[2] = vi[0], vi[1], vi[2]
 			n++
 		}
 	}
 
 	return m, n
 }
+
+// allBefore reports whether all the vertices of a polygon are strictly on
+// the out1 side of the line x (See: dividePoly), in which case dividing the
+// polygon would just copy it into out1.
+func allBefore(in []float32, nin int32, x float32, axis int32) bool {
+	for i := int32(0); i < nin; i++ {
+		if x-in[i*3+axis] <= 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,221 @@
+package recast
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadRasterizationInput loads an OBJ mesh of the test data and computes the
+// areas of its triangles, with the default build settings.
+func loadRasterizationInput(tb testing.TB, name string) (mesh *MeshLoaderOBJ, areas []uint8, bmin, bmax [3]float32) {
+	f, err := os.Open(filepath.Join("..", "testdata", "obj", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	mesh = NewMeshLoaderOBJ()
+	if err = mesh.Load(f); err != nil {
+		tb.Fatal(err)
+	}
+	CalcBounds(mesh.Verts(), mesh.VertCount(), bmin[:], bmax[:])
+	areas = make([]uint8, mesh.TriCount())
+	var ctx BuildContext
+	MarkWalkableTriangles(&ctx, 45, mesh.Verts(), mesh.VertCount(), mesh.Tris(), mesh.TriCount(), areas)
+	return mesh, areas, bmin, bmax
+}
+
+// benchmarkRasterizeTriangles rasterizes a test mesh, into a new heightfield
+// or, if reset is true, into the same heightfield reset each time, as when
+// building the tiles of a navmesh one after the other.
+func benchmarkRasterizeTriangles(b *testing.B, name string, reset bool) {
+	const cs, ch = 0.3, 0.2
+	mesh, areas, bmin, bmax := loadRasterizationInput(b, name)
+	w, h := CalcGridSize(bmin[:], bmax[:], cs)
+
+	var ctx BuildContext
+	solid := NewHeightfield(w, h, bmin[:], bmax[:], cs, ch)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if reset {
+			solid.Reset(w, h, bmin[:], bmax[:], cs, ch)
+		} else {
+			solid = NewHeightfield(w, h, bmin[:], bmax[:], cs, ch)
+		}
+		if !RasterizeTriangles(&ctx, mesh.Verts(), mesh.VertCount(), mesh.Tris(), areas, mesh.TriCount(), solid, 4) {
+			b.Fatal("RasterizeTriangles failed")
+		}
+	}
+}
+
+func BenchmarkRasterizeTrianglesDungeon(b *testing.B) {
+	benchmarkRasterizeTriangles(b, "dungeon.obj", false)
+}
+
+func BenchmarkRasterizeTrianglesDeveler(b *testing.B) {
+	benchmarkRasterizeTriangles(b, "develer.obj", false)
+}
+
+func BenchmarkRasterizeTrianglesNavTest(b *testing.B) {
+	benchmarkRasterizeTriangles(b, "nav_test.obj", false)
+}
+
+func BenchmarkRasterizeTrianglesTwisted(b *testing.B) {
+	benchmarkRasterizeTriangles(b, "twisted.obj", false)
+}
+
+func BenchmarkRasterizeTrianglesResetDungeon(b *testing.B) {
+	benchmarkRasterizeTriangles(b, "dungeon.obj", true)
+}
+
+func BenchmarkRasterizeTrianglesResetDeveler(b *testing.B) {
+	benchmarkRasterizeTriangles(b, "develer.obj", true)
+}
+
+func BenchmarkRasterizeTrianglesResetNavTest(b *testing.B) {
+	benchmarkRasterizeTriangles(b, "nav_test.obj", true)
+}
+
+func BenchmarkRasterizeTrianglesResetTwisted(b *testing.B) {
+	benchmarkRasterizeTriangles(b, "twisted.obj", true)
+}
+
+func TestRasterizeTrianglesFunc(t *testing.T) {
+	const cs, ch = 0.3, 0.2
+	mesh, areas, bmin, bmax := loadRasterizationInput(t, "dungeon.obj")
+	w, h := CalcGridSize(bmin[:], bmax[:], cs)
+
+	// The areas computed by a function, here a material id derived from the
+	// triangle index, replace the ones of the areas slice.
+	material := func(tri int32) uint8 {
+		if areas[tri] == nullArea {
+			return nullArea
+		}
+		return uint8(1 + tri%3)
+	}
+	matAreas := make([]uint8, len(areas))
+	for i := range areas {
+		matAreas[i] = material(int32(i))
+	}
+
+	var ctx BuildContext
+	ref := NewHeightfield(w, h, bmin[:], bmax[:], cs, ch)
+	require(t, RasterizeTriangles(&ctx, mesh.Verts(), mesh.VertCount(), mesh.Tris(), matAreas, mesh.TriCount(), ref, 4),
+		"RasterizeTriangles should succeed")
+
+	var calls int32
+	hf := NewHeightfield(w, h, bmin[:], bmax[:], cs, ch)
+	areaFunc := func(tri int32) uint8 {
+		require(t, tri == calls, "the area function should be called once per triangle, in order")
+		calls++
+		return material(tri)
+	}
+	require(t, RasterizeTrianglesFunc(&ctx, mesh.Verts(), mesh.VertCount(), mesh.Tris(), mesh.TriCount(), areaFunc, hf, 4),
+		"RasterizeTrianglesFunc should succeed")
+	require(t, calls == mesh.TriCount(), "the area function should be called for each triangle")
+
+	for i := range ref.Spans {
+		s, rs := hf.Spans[i], ref.Spans[i]
+		for ; s != nil && rs != nil; s, rs = s.next, rs.next {
+			require(t, s.smin == rs.smin && s.smax == rs.smax && s.area == rs.area, "spans should be identical")
+		}
+		require(t, s == nil && rs == nil, "columns should have the same number of spans")
+	}
+}
+
+func TestRasterizeSpans(t *testing.T) {
+	const cs, ch = 1, 0.5
+	bmin := []float32{0, 0, 0}
+	bmax := []float32{4, 10, 3}
+	w, h := CalcGridSize(bmin, bmax, cs)
+
+	var ctx BuildContext
+	solid := NewHeightfield(w, h, bmin, bmax, cs, ch)
+
+	// A ramp, plus a floating slab over column (1, 1) and a span out of the
+	// bounds, skipped.
+	terrain := func(x, z int32, spans []SolidSpan) []SolidSpan {
+		spans = append(spans, SolidSpan{YMin: -1, YMax: float32(x) + 1, Area: WalkableArea})
+		if x == 1 && z == 1 {
+			spans = append(spans, SolidSpan{YMin: 5, YMax: 6, Area: WalkableArea})
+		}
+		return append(spans, SolidSpan{YMin: 20, YMax: 21, Area: WalkableArea})
+	}
+	require(t, RasterizeSpans(&ctx, solid, terrain, 1), "RasterizeSpans should succeed")
+
+	for z := int32(0); z < h; z++ {
+		for x := int32(0); x < w; x++ {
+			s := solid.Spans[x+z*w]
+			require(t, s != nil && s.smin == 0 && s.smax == uint16(2*(x+1)),
+				"the ground span of each column should be clamped and snapped")
+			if x == 1 && z == 1 {
+				s = s.next
+				require(t, s != nil && s.smin == 10 && s.smax == 12, "the slab should be added")
+			}
+			require(t, s.next == nil, "the span out of the bounds should be skipped")
+		}
+	}
+
+	// Mark the columns at x >= 2 as not walkable.
+	FilterSpans(&ctx, solid, func(x, z int32, smin, smax uint16, area uint8) uint8 {
+		if x >= 2 {
+			return nullArea
+		}
+		return area
+	})
+	for z := int32(0); z < h; z++ {
+		for x := int32(0); x < w; x++ {
+			walkable := solid.Spans[x+z*w].area != nullArea
+			require(t, walkable == (x < 2), "FilterSpans should set the span areas")
+		}
+	}
+
+	require(t, solid.AddSpan(&ctx, 0, 0, 6, 8, WalkableArea, 1), "AddSpan should succeed")
+	require(t, solid.Spans[0].next != nil && solid.Spans[0].next.smin == 6, "AddSpan should add the span")
+	require(t, !solid.AddSpan(&ctx, w, 0, 6, 8, WalkableArea, 1), "AddSpan should fail out of the grid")
+	require(t, !solid.AddSpan(&ctx, 0, 0, 8, 8, WalkableArea, 1), "AddSpan should fail with an empty span")
+}
+
+func TestRasterizeHeightmap(t *testing.T) {
+	const cs, ch = 1, 0.25
+	bmin := []float32{0, 0, 0}
+	bmax := []float32{4, 10, 4}
+	w, h := CalcGridSize(bmin, bmax, cs)
+
+	// 3 x 3 samples, 2 units apart: flat at height 1 for x <= 2, then a
+	// steep ramp up to height 5.
+	heights := [][]float32{
+		{1, 1, 5},
+		{1, 1, 5},
+		{1, 1, 5},
+	}
+
+	var ctx BuildContext
+	solid := NewHeightfield(w, h, bmin, bmax, cs, ch)
+	require(t, RasterizeHeightmap(&ctx, solid, heights, 45, 1), "RasterizeHeightmap should succeed")
+
+	for z := int32(0); z < h; z++ {
+		for x := int32(0); x < w; x++ {
+			s := solid.Spans[x+z*w]
+			require(t, s != nil && s.next == nil, "each column should have a single span")
+			switch x {
+			case 0, 1:
+				require(t, s.smin == 4 && s.smax == 5 && s.area == WalkableArea,
+					"the flat cells should have a thin walkable span")
+			case 2:
+				require(t, s.smin == 4 && s.smax == 12 && s.area == nullArea,
+					"the ramp cells should span the surface and not be walkable")
+			case 3:
+				require(t, s.smin == 12 && s.smax == 20 && s.area == nullArea,
+					"the ramp cells should span the surface and not be walkable")
+			}
+		}
+	}
+
+	require(t, !RasterizeHeightmap(&ctx, solid, [][]float32{{1, 1}}, 45, 1),
+		"RasterizeHeightmap should fail with a single row")
+	require(t, !RasterizeHeightmap(&ctx, solid, [][]float32{{1, 1}, {1}}, 45, 1),
+		"RasterizeHeightmap should fail with rows of different lengths")
+}
@@ -1,6 +1,7 @@
 package recast
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/arl/gogeo/f32/d3"
@@ -132,7 +133,7 @@ type TimerLabel int
 
 const (
 	// TimerTotal is the user defined total time of the build.
-	TimerTotal = iota
+	TimerTotal TimerLabel = iota
 	// TimerTemp is an user defined build time.
 	TimerTemp
 	// TimerRasterizeTriangles is the time to rasterize the triangles.
@@ -213,11 +214,54 @@ const (
 	// TimerMergePolyMeshDetail is the time to merge polygon mesh details.
 	// see: MergePolyMeshDetails
 	TimerMergePolyMeshDetail
+	// TimerMarkModifierVolumes is the time to apply the modifier volumes.
+	// see: MarkModifierVolumes
+	TimerMarkModifierVolumes
 
 	// The maximum number of timers. (Used for iterating timers.)
 	maxTimers
 )
 
+var timerNames = [maxTimers]string{
+	TimerTotal:                   "Total",
+	TimerTemp:                    "Temp",
+	TimerRasterizeTriangles:      "Rasterize Triangles",
+	TimerBuildCompactHeightfield: "Build Compact Heightfield",
+	TimerBuildContours:           "Build Contours",
+	TimerBuildContoursTrace:      "Build Contours Trace",
+	TimerBuildContoursSimplify:   "Build Contours Simplify",
+	TimerFilterBorder:            "Filter Border",
+	TimerFilterWalkable:          "Filter Walkable",
+	TimerMedianArea:              "Median Area",
+	TimerFilterLowObstacles:      "Filter Low Obstacles",
+	TimerBuildPolymesh:           "Build Polymesh",
+	TimerMergePolymesh:           "Merge Polymeshes",
+	TimerErodeArea:               "Erode Area",
+	TimerMarkBoxArea:             "Mark Box Area",
+	TimerMarkCylinderArea:        "Mark Cylinder Area",
+	TimerMarkConvexPolyArea:      "Mark Convex Area",
+	TimerBuildDistanceField:      "Build Distance Field",
+	TimerBuildDistanceFieldDist:  "Build Distance Field Dist",
+	TimerBuildDistanceFieldBlur:  "Build Distance Field Blur",
+	TimerBuildRegions:            "Build Regions",
+	TimerBuildRegionsWatershed:   "Build Regions Watershed",
+	TimerBuildRegionsExpand:      "Build Regions Expand",
+	TimerBuildRegionsFlood:       "Build Regions Flood",
+	TimerBuildRegionsFilter:      "Build Regions Filter",
+	TimerBuildLayers:             "Build Layers",
+	TimerBuildPolyMeshDetail:     "Build Polymesh Detail",
+	TimerMergePolyMeshDetail:     "Merge Polymesh Details",
+	TimerMarkModifierVolumes:     "Mark Modifier Volumes",
+}
+
+// String returns the name of the timer.
+func (l TimerLabel) String() string {
+	if l < 0 || l >= maxTimers {
+		return fmt.Sprintf("TimerLabel(%d)", int(l))
+	}
+	return timerNames[l]
+}
+
 var (
 	xOffset, yOffset [4]int32
 	dirOffset        [5]int32
@@ -1,6 +1,12 @@
 package recast
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/arl/math32"
@@ -635,3 +641,563 @@ func TestRasterizeTriangles(t *testing.T) {
 	require(t, solid.Spans[1+2*w].area == 2, "solid.Spans[1 + 2 * w].area == 2")
 	require(t, solid.Spans[1+2*w].next == nil, "!solid.Spans[1 + 2 * w].next")
 }
+
+// newFlatCompactHeightfield returns a compact heightfield made of a single
+// layer of w x h walkable spans, all at the same height.
+func newFlatCompactHeightfield(t *testing.T, ctx *BuildContext, w, h int32) *CompactHeightfield {
+	bmin := []float32{0, 0, 0}
+	bmax := []float32{float32(w), 1, float32(h)}
+	solid := NewHeightfield(w, h, bmin, bmax, 1, 1)
+	for z := int32(0); z < h; z++ {
+		for x := int32(0); x < w; x++ {
+			require(t, solid.addSpan(x, z, 0, 1, WalkableArea, 1), "addSpan should succeed")
+		}
+	}
+	chf := &CompactHeightfield{}
+	require(t, BuildCompactHeightfield(ctx, 2, 1, solid, chf), "BuildCompactHeightfield should succeed")
+	return chf
+}
+
+// countArea returns the number of spans of chf having the area id area.
+func countArea(chf *CompactHeightfield, area uint8) int {
+	n := 0
+	for i := int32(0); i < chf.SpanCount; i++ {
+		if chf.Areas[i] == area {
+			n++
+		}
+	}
+	return n
+}
+
+func TestMarkBoxArea(t *testing.T) {
+	var ctx BuildContext
+	chf := newFlatCompactHeightfield(t, &ctx, 10, 10)
+
+	MarkBoxArea(&ctx, []float32{2, 0, 2}, []float32{4.5, 1, 3.5}, 3, chf)
+	if n := countArea(chf, 3); n != 6 {
+		t.Fatalf("want 6 spans marked, got %d", n)
+	}
+	require(t, chf.Areas[2+2*chf.Width] == 3, "span (2, 2) should be marked")
+	require(t, chf.Areas[5+2*chf.Width] == WalkableArea, "span (5, 2) should not be marked")
+
+	// a box outside of the heightfield marks nothing
+	MarkBoxArea(&ctx, []float32{20, 0, 20}, []float32{30, 1, 30}, 4, chf)
+	require(t, countArea(chf, 4) == 0, "no spans should be marked outside of the heightfield")
+}
+
+func TestMarkCylinderArea(t *testing.T) {
+	var ctx BuildContext
+	chf := newFlatCompactHeightfield(t, &ctx, 10, 10)
+
+	MarkCylinderArea(&ctx, []float32{5, 0, 5}, 1, 1, 3, chf)
+	if n := countArea(chf, 3); n != 4 {
+		t.Fatalf("want 4 spans marked, got %d", n)
+	}
+	for _, c := range [][2]int32{{4, 4}, {4, 5}, {5, 4}, {5, 5}} {
+		if chf.Areas[c[0]+c[1]*chf.Width] != 3 {
+			t.Errorf("span (%d, %d) should be marked", c[0], c[1])
+		}
+	}
+}
+
+func TestMarkConvexPolyArea(t *testing.T) {
+	var ctx BuildContext
+	chf := newFlatCompactHeightfield(t, &ctx, 10, 10)
+
+	verts := []float32{
+		1, 0, 1,
+		1, 0, 4,
+		4, 0, 4,
+		4, 0, 1,
+	}
+	MarkConvexPolyArea(&ctx, verts, 4, 0, 1, 3, chf)
+	if n := countArea(chf, 3); n != 9 {
+		t.Fatalf("want 9 spans marked, got %d", n)
+	}
+	require(t, chf.Areas[1+1*chf.Width] == 3, "span (1, 1) should be marked")
+	require(t, chf.Areas[4+4*chf.Width] == WalkableArea, "span (4, 4) should not be marked")
+}
+
+func TestMarkModifierVolumes(t *testing.T) {
+	ctx := NewBuildContext(true)
+	chf := newFlatCompactHeightfield(t, ctx, 10, 10)
+
+	square := func(x0, z0, x1, z1 float32) []float32 {
+		return []float32{x0, 0, z0, x0, 0, z1, x1, 0, z1, x1, 0, z0}
+	}
+	vols := []ModifierVolumeSettings{
+		// declared first but applied last, it wins over the other volumes
+		{Verts: square(1, 1, 3, 3), HMin: 0, HMax: 2, Mode: ModifierWalkable, Priority: 2},
+		{Verts: square(1, 1, 6, 6), HMin: 0, HMax: 2, Mode: ModifierArea, Area: 3, Priority: 0},
+		{Verts: square(4, 4, 6, 6), HMin: 0, HMax: 2, Mode: ModifierNotWalkable, Priority: 1},
+		// invalid volumes are skipped
+		{Verts: square(7, 7, 9, 9)[:6], HMin: 0, HMax: 2, Mode: ModifierNotWalkable},
+		{Verts: square(7, 7, 9, 9), HMin: 0, HMax: 2, Mode: 42},
+	}
+	ctx.ResetTimers()
+	MarkModifierVolumes(ctx, vols, chf)
+	if report := ctx.TimerReport(); len(report) != 1 || report[0].Label != TimerMarkModifierVolumes {
+		t.Errorf("got timer report %v, want only %v", report, TimerMarkModifierVolumes)
+	}
+
+	require(t, chf.Areas[1+1*chf.Width] == WalkableArea, "span (1, 1) should be walkable")
+	require(t, chf.Areas[3+1*chf.Width] == 3, "span (3, 1) should have area 3")
+	require(t, chf.Areas[4+4*chf.Width] == nullArea, "span (4, 4) should not be walkable")
+	require(t, chf.Areas[7+7*chf.Width] == WalkableArea, "span (7, 7) should be untouched")
+	if n := countArea(chf, nullArea); n != 4 {
+		t.Fatalf("want 4 non-walkable spans, got %d", n)
+	}
+
+	// a walkable volume turns null spans back into walkable ones
+	MarkModifierVolumes(ctx, []ModifierVolumeSettings{
+		{Verts: square(4, 4, 6, 6), HMin: 0, HMax: 2, Mode: ModifierWalkable, Area: 5},
+	}, chf)
+	require(t, countArea(chf, nullArea) == 0, "all spans should be walkable")
+	require(t, chf.Areas[4+4*chf.Width] == 5, "span (4, 4) should have area 5")
+}
+
+func TestMedianFilterWalkableArea(t *testing.T) {
+	var ctx BuildContext
+	chf := newFlatCompactHeightfield(t, &ctx, 5, 5)
+
+	// isolated span with a different area id, lost among walkable spans
+	chf.Areas[2+2*chf.Width] = 3
+	// nullArea spans are left untouched
+	chf.Areas[0] = nullArea
+
+	require(t, MedianFilterWalkableArea(&ctx, chf), "MedianFilterWalkableArea should succeed")
+	require(t, chf.Areas[2+2*chf.Width] == WalkableArea, "isolated area id should have been filtered out")
+	require(t, chf.Areas[0] == nullArea, "null area should be left untouched")
+}
+
+func TestExtractWalkableSurface(t *testing.T) {
+	var ctx BuildContext
+	chf := newFlatCompactHeightfield(t, &ctx, 4, 3)
+
+	surf, ok := ExtractWalkableSurface(&ctx, chf)
+	require(t, ok, "ExtractWalkableSurface should succeed")
+	// 2 triangles per cell, vertices shared between adjacent cells
+	require(t, surf.TriCount() == 4*3*2, "should have 2 triangles per walkable span")
+	require(t, surf.VertCount() == 5*4, "adjacent quads should share their vertices")
+	require(t, len(surf.Areas) == surf.TriCount(), "should have an area id per triangle")
+	for i := 1; i < len(surf.Verts); i += 3 {
+		require(t, surf.Verts[i] == 1, "vertices should be at the top of the spans")
+	}
+	for i := 0; i < len(surf.Tris); i += 3 {
+		// y component of the triangle normal
+		a, b, c := surf.Tris[i]*3, surf.Tris[i+1]*3, surf.Tris[i+2]*3
+		e0x, e0z := surf.Verts[b]-surf.Verts[a], surf.Verts[b+2]-surf.Verts[a+2]
+		e1x, e1z := surf.Verts[c]-surf.Verts[a], surf.Verts[c+2]-surf.Verts[a+2]
+		require(t, e0z*e1x-e0x*e1z > 0, "triangles should face up")
+	}
+
+	// null spans and border cells are skipped
+	chf.Areas[1+1*chf.Width] = nullArea
+	chf.Areas[2+1*chf.Width] = 3
+	chf.BorderSize = 1
+	surf, ok = ExtractWalkableSurface(&ctx, chf)
+	require(t, ok, "ExtractWalkableSurface should succeed")
+	require(t, surf.TriCount() == 2, "only the inner walkable span should be extracted")
+	require(t, surf.Areas[0] == 3 && surf.Areas[1] == 3, "triangles should have the area id of their span")
+	require(t, surf.Verts[0] == 2 && surf.Verts[2] == 1, "quad should cover the cell (2, 1)")
+}
+
+func TestMeshLoaderOBJTransformMerge(t *testing.T) {
+	newTri := func() *MeshLoaderOBJ {
+		m := NewMeshLoaderOBJ()
+		m.verts = []float32{0, 0, 0, 0, 0, 1, 1, 0, 0}
+		m.tris = []int32{0, 1, 2}
+		m.calcNormals()
+		return m
+	}
+
+	approxVerts := func(got, want []float32) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			if math32.Abs(got[i]-want[i]) > 1e-5 {
+				return false
+			}
+		}
+		return true
+	}
+
+	xfTests := []struct {
+		xf   MeshTransform
+		want []float32
+	}{
+		{MeshTransform{}, []float32{0, 0, 0, 0, 0, 1, 1, 0, 0}},
+		{MeshTransform{Translate: [3]float32{1, 2, 3}}, []float32{1, 2, 3, 1, 2, 4, 2, 2, 3}},
+		{MeshTransform{Scale: 2}, []float32{0, 0, 0, 0, 0, 2, 2, 0, 0}},
+		{MeshTransform{Rotate: [3]float32{0, 90, 0}}, []float32{0, 0, 0, 1, 0, 0, 0, 0, -1}},
+		{
+			MeshTransform{Translate: [3]float32{1, 0, 0}, Rotate: [3]float32{0, 90, 0}, Scale: 2},
+			[]float32{1, 0, 0, 3, 0, 0, 1, 0, -2},
+		},
+	}
+
+	for _, tt := range xfTests {
+		m := newTri()
+		m.Transform(tt.xf)
+		if !approxVerts(m.Verts(), tt.want) {
+			t.Errorf("Transform(%+v), got verts %v, want %v", tt.xf, m.Verts(), tt.want)
+		}
+		// the walkable triangle still faces up
+		if m.Normals()[1] <= 0 {
+			t.Errorf("Transform(%+v), triangle normal %v should face up", tt.xf, m.Normals())
+		}
+	}
+
+	// a negative scale mirrors the mesh but keeps the triangles facing up
+	m := newTri()
+	m.Transform(MeshTransform{Scale: -1, Rotate: [3]float32{180, 0, 0}})
+	if m.Normals()[1] <= 0 {
+		t.Errorf("mirrored triangle normal %v should face up", m.Normals())
+	}
+
+	m = newTri()
+	other := newTri()
+	other.Transform(MeshTransform{Translate: [3]float32{5, 0, 0}})
+	m.Merge(other)
+	if m.VertCount() != 6 || m.TriCount() != 2 || len(m.Normals()) != 6 {
+		t.Fatalf("Merge, got %d verts, %d tris, %d normals, want 6, 2, 6",
+			m.VertCount(), m.TriCount(), len(m.Normals())/3)
+	}
+	if got := m.Tris()[3:]; got[0] != 3 || got[1] != 4 || got[2] != 5 {
+		t.Errorf("Merge, got merged triangle indices %v, want [3 4 5]", got)
+	}
+}
+
+func TestInputGeomAddMesh(t *testing.T) {
+	// flat and 45 degrees sloped triangles, translated along x
+	newTri := func(x, slope float32) *MeshLoaderOBJ {
+		m := NewMeshLoaderOBJ()
+		m.verts = []float32{x, 0, 0, x, slope, 1, x + 1, 0, 0}
+		m.tris = []int32{0, 1, 2}
+		m.calcNormals()
+		return m
+	}
+
+	var ig InputGeom
+	require(t, ig.SetMesh(newTri(0, 0)) == nil, "SetMesh failed")
+	parts := []struct {
+		mesh     *MeshLoaderOBJ
+		area     uint8
+		maxSlope float32
+	}{
+		{newTri(2, 0), 5, 0},
+		{newTri(4, 1), 7, 60},
+		{newTri(6, 1), 9, 0},
+	}
+	for _, p := range parts {
+		require(t, ig.AddMesh(p.mesh, p.area, p.maxSlope) == nil, "AddMesh failed")
+	}
+	if ig.Mesh().TriCount() != 4 || len(ig.MeshParts()) != 3 || ig.MeshParts()[2].FirstTri != 3 {
+		t.Fatalf("got %d triangles and mesh parts %+v", ig.Mesh().TriCount(), ig.MeshParts())
+	}
+
+	// the default triangle gets the walkable area, the sloped one of the last
+	// part isn't walkable with the agent slope.
+	want := []uint8{WalkableArea, 5, 7, nullArea}
+	areas := make([]uint8, 4)
+	ig.MarkWalkableTriangles(nil, 30, nil, 4, areas)
+	if !reflect.DeepEqual(areas, want) {
+		t.Errorf("got areas %v, want %v", areas, want)
+	}
+
+	// same areas, looking triangles up from the chunky mesh
+	cm := ig.ChunkyMesh()
+	areas = make([]uint8, cm.Ntris)
+	ig.MarkWalkableTriangles(nil, 30, cm.TriIDs, cm.Ntris, areas)
+	for i, id := range cm.TriIDs {
+		if areas[i] != want[id] {
+			t.Errorf("chunky triangle %d (%d), got area %d, want %d", i, id, areas[i], want[id])
+		}
+	}
+
+	// SetMesh removes the mesh parts
+	require(t, ig.SetMesh(newTri(0, 0)) == nil, "SetMesh failed")
+	require(t, len(ig.MeshParts()) == 0, "SetMesh should remove the mesh parts")
+}
+
+func TestGeomSet(t *testing.T) {
+	const obj = "v 0 0 0\nv 0 0 10\nv 10 0 10\nv 10 0 0\nf 1 2 3 4\n"
+	open := func(name string) (io.ReadCloser, error) {
+		if name != "plane.obj" {
+			return nil, fmt.Errorf("unexpected mesh file %q", name)
+		}
+		return ioutil.NopCloser(strings.NewReader(obj)), nil
+	}
+
+	var geom InputGeom
+	_, err := geom.loadGeomSet(strings.NewReader("f plane.obj\n"), open)
+	require(t, err == nil, "loading a geometry set with a mesh only should succeed")
+	require(t, geom.Mesh().TriCount() == 2, "mesh should have been loaded")
+
+	geom.AddOffMeshConnection([]float32{1, 0, 1}, []float32{5, 2, 5}, 0.5, true, 5, 8)
+	geom.AddOffMeshConnection([]float32{2, 0, 2}, []float32{3, 0, 3}, 1, false, 2, 1)
+	geom.AddConvexVolume([]float32{1, 0, 1, 1, 0, 4, 4, 0, 4}, -1, 3, 4)
+	settings := BuildSettings{
+		CellSize: 0.3, CellHeight: 0.2, AgentHeight: 2, AgentRadius: 0.6, AgentMaxClimb: 0.9,
+		AgentMaxSlope: 45, RegionMinSize: 8, RegionMergeSize: 20, EdgeMaxLen: 12, EdgeMaxError: 1.3,
+		ContourFlags: ContourTessWallEdges,
+		VertsPerPoly: 6, DetailSampleDist: 6, DetailSampleMaxError: 1, PartitionType: 1, TileSize: 32,
+	}
+
+	var buf bytes.Buffer
+	require(t, geom.SaveGeomSet(&buf, "plane.obj", &settings) == nil, "SaveGeomSet should succeed")
+
+	var loaded InputGeom
+	s, err := loaded.loadGeomSet(&buf, open)
+	require(t, err == nil, "loading a saved geometry set should succeed")
+	require(t, s != nil && reflect.DeepEqual(*s, settings), "build settings should be read back")
+	require(t, loaded.Mesh().TriCount() == 2, "mesh should have been loaded")
+	n := loaded.OffMeshConnectionCount()
+	require(t, n == 2, "off-mesh connections should be read back")
+	require(t, reflect.DeepEqual(loaded.OffMeshConnectionVerts()[:n*6], geom.OffMeshConnectionVerts()[:n*6]) &&
+		reflect.DeepEqual(loaded.OffMeshConnectionRads()[:n], geom.OffMeshConnectionRads()[:n]) &&
+		reflect.DeepEqual(loaded.OffMeshConnectionDirs()[:n], []uint8{1, 0}) &&
+		reflect.DeepEqual(loaded.OffMeshConnectionAreas()[:n], []uint8{5, 2}) &&
+		reflect.DeepEqual(loaded.OffMeshConnectionFlags()[:n], []uint16{8, 1}),
+		"off-mesh connections should be identical")
+	require(t, loaded.ConvexVolumesCount() == 1 &&
+		reflect.DeepEqual(loaded.ConvexVolumes()[0], geom.ConvexVolumes()[0]), "convex volume should be identical")
+
+	loaded.DeleteOffMeshConnection(0)
+	require(t, loaded.OffMeshConnectionCount() == 1 && loaded.OffMeshConnectionAreas()[0] == 2,
+		"the last off-mesh connection should replace the deleted one")
+
+	for _, gset := range []string{
+		"c 1 0 1 5 2 5 0.5 1 5 8\n",
+		"f plane.obj\nv 3 0 0 1\n1 0 1\n",
+		"f plane.obj\ns 0.3 0.2\n",
+		"f missing.obj\n",
+	} {
+		_, err := loaded.loadGeomSet(strings.NewReader(gset), open)
+		require(t, err != nil, fmt.Sprintf("loading geometry set %q should fail", gset))
+	}
+}
+
+func TestHeightfieldClone(t *testing.T) {
+	hf := NewHeightfield(3, 2, []float32{0, 0, 0}, []float32{3, 10, 2}, 1, 1)
+	require(t, hf.addSpan(0, 0, 0, 2, WalkableArea, 1), "addSpan should succeed")
+	require(t, hf.addSpan(0, 0, 5, 7, 3, 1), "addSpan should succeed")
+	require(t, hf.addSpan(2, 1, 1, 4, WalkableArea, 1), "addSpan should succeed")
+
+	c := hf.Clone()
+	require(t, c.Width == hf.Width && c.Height == hf.Height && c.BMin == hf.BMin && c.BMax == hf.BMax &&
+		c.Cs == hf.Cs && c.Ch == hf.Ch, "clone should have the same dimensions")
+	for i := range hf.Spans {
+		s, cs := hf.Spans[i], c.Spans[i]
+		for ; s != nil && cs != nil; s, cs = s.next, cs.next {
+			require(t, s != cs, "clone spans should be copies")
+			require(t, s.smin == cs.smin && s.smax == cs.smax && s.area == cs.area, "clone spans should be identical")
+		}
+		require(t, s == nil && cs == nil, "clone columns should have the same number of spans")
+	}
+
+	// modifying the clone leaves the original untouched
+	c.Spans[0].area = nullArea
+	require(t, c.addSpan(1, 0, 0, 1, WalkableArea, 1), "addSpan should succeed")
+	require(t, hf.Spans[0].area == WalkableArea && hf.Spans[1] == nil, "original heightfield should be untouched")
+}
+
+func TestHeightfieldReset(t *testing.T) {
+	mesh, areas, bmin, bmax := loadRasterizationInput(t, "dungeon.obj")
+	w, h := CalcGridSize(bmin[:], bmax[:], 0.3)
+
+	var ctx BuildContext
+	rasterize := func(hf *Heightfield) {
+		require(t, RasterizeTriangles(&ctx, mesh.Verts(), mesh.VertCount(), mesh.Tris(), areas, mesh.TriCount(), hf, 4),
+			"RasterizeTriangles should succeed")
+	}
+
+	ref := NewHeightfield(w, h, bmin[:], bmax[:], 0.3, 0.2)
+	rasterize(ref)
+
+	// Fill the heightfield with a different grid, then reset it.
+	hf := NewHeightfield(w/2, h/2, bmin[:], bmax[:], 0.6, 0.4)
+	rasterize(hf)
+	pools := hf.Pools
+	hf.Reset(w, h, bmin[:], bmax[:], 0.3, 0.2)
+	require(t, hf.Pools == pools, "Reset should keep the span pools")
+	rasterize(hf)
+
+	require(t, hf.Width == ref.Width && hf.Height == ref.Height && hf.BMin == ref.BMin && hf.BMax == ref.BMax &&
+		hf.Cs == ref.Cs && hf.Ch == ref.Ch, "reset heightfield should have the new dimensions")
+	require(t, len(hf.Spans) == len(ref.Spans), "reset heightfield should have the new number of columns")
+	for i := range ref.Spans {
+		s, rs := hf.Spans[i], ref.Spans[i]
+		for ; s != nil && rs != nil; s, rs = s.next, rs.next {
+			require(t, s.smin == rs.smin && s.smax == rs.smax && s.area == rs.area, "spans should be identical")
+		}
+		require(t, s == nil && rs == nil, "columns should have the same number of spans")
+	}
+}
+
+func TestBuildContoursTessellation(t *testing.T) {
+	var ctx BuildContext
+	// 2 areas of 20x10 cells, side by side
+	chf := newFlatCompactHeightfield(t, &ctx, 40, 10)
+	MarkBoxArea(&ctx, []float32{20, 0, 0}, []float32{40, 1, 10}, 3, chf)
+	require(t, BuildRegionsMonotone(&ctx, chf, 0, 0, 0), "BuildRegionsMonotone should succeed")
+
+	nverts := func(flags int32) int32 {
+		var cset ContourSet
+		require(t, BuildContours(&ctx, chf, 1.3, 4, &cset, flags), "BuildContours should succeed")
+		var n int32
+		for i := int32(0); i < cset.NConts; i++ {
+			n += cset.Conts[i].NVerts
+		}
+		return n
+	}
+
+	none := nverts(0)
+	walls := nverts(ContourTessWallEdges)
+	areas := nverts(ContourTessAreaEdges)
+	both := nverts(ContourTessWallEdges | ContourTessAreaEdges)
+	require(t, walls > none, "tessellating wall edges should add vertices")
+	require(t, areas > none, "tessellating area edges should add vertices")
+	require(t, both > walls && both > areas, "tessellating both should add the vertices of both")
+}
+
+func TestCopyHeightfield(t *testing.T) {
+	var ctx BuildContext
+	src := NewHeightfield(3, 2, []float32{0, 0, 0}, []float32{3, 10, 2}, 1, 1)
+	require(t, src.addSpan(0, 0, 0, 2, WalkableArea, 1), "addSpan should succeed")
+	require(t, src.addSpan(0, 0, 5, 7, 3, 1), "addSpan should succeed")
+	require(t, src.addSpan(2, 1, 1, 4, WalkableArea, 1), "addSpan should succeed")
+
+	// copy into a heightfield of different dimensions, already holding spans
+	dst := NewHeightfield(5, 5, []float32{1, 1, 1}, []float32{6, 6, 6}, 2, 2)
+	require(t, dst.addSpan(4, 4, 0, 1, WalkableArea, 1), "addSpan should succeed")
+	require(t, CopyHeightfield(&ctx, src, dst), "CopyHeightfield should succeed")
+
+	require(t, dst.Width == src.Width && dst.Height == src.Height && dst.BMin == src.BMin && dst.BMax == src.BMax &&
+		dst.Cs == src.Cs && dst.Ch == src.Ch, "copy should have the same dimensions")
+	require(t, len(dst.Spans) == len(src.Spans), "copy should have the same number of columns")
+	for i := range src.Spans {
+		s, cs := src.Spans[i], dst.Spans[i]
+		for ; s != nil && cs != nil; s, cs = s.next, cs.next {
+			require(t, s != cs, "copy spans should be copies")
+			require(t, s.smin == cs.smin && s.smax == cs.smax && s.area == cs.area, "copy spans should be identical")
+		}
+		require(t, s == nil && cs == nil, "copy columns should have the same number of spans")
+	}
+}
+
+func TestCopyCompactHeightfieldAndPolyMesh(t *testing.T) {
+	var ctx BuildContext
+	chf := loadCompactHeightfield(t, "nav_test.obj")
+	require(t, BuildDistanceField(&ctx, chf), "BuildDistanceField should succeed")
+	require(t, BuildRegions(&ctx, chf, 0, 8*8, 20*20), "BuildRegions should succeed")
+
+	var chfCopy CompactHeightfield
+	require(t, CopyCompactHeightfield(&ctx, chf, &chfCopy), "CopyCompactHeightfield should succeed")
+	require(t, reflect.DeepEqual(*chf, chfCopy), "compact heightfield copy should be identical")
+	chfCopy.Spans[0].Reg++
+	chfCopy.Dist[0]++
+	require(t, chf.Spans[0].Reg+1 == chfCopy.Spans[0].Reg && chf.Dist[0]+1 == chfCopy.Dist[0],
+		"modifying the copy should leave the original compact heightfield untouched")
+
+	var cset ContourSet
+	require(t, BuildContours(&ctx, chf, 1.3, 40, &cset, ContourTessWallEdges), "BuildContours should succeed")
+	pmesh, ok := BuildPolyMesh(&ctx, &cset, 6)
+	require(t, ok, "BuildPolyMesh should succeed")
+
+	var pmeshCopy PolyMesh
+	require(t, CopyPolyMesh(&ctx, pmesh, &pmeshCopy), "CopyPolyMesh should succeed")
+	require(t, reflect.DeepEqual(*pmesh, pmeshCopy), "polygon mesh copy should be identical")
+	pmeshCopy.Verts[0]++
+	pmeshCopy.Areas[0]++
+	require(t, pmesh.Verts[0]+1 == pmeshCopy.Verts[0] && pmesh.Areas[0]+1 == pmeshCopy.Areas[0],
+		"modifying the copy should leave the original polygon mesh untouched")
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := Config{
+		Cs:                     0.3,
+		Ch:                     0.2,
+		BMin:                   [3]float32{0, 0, 0},
+		BMax:                   [3]float32{30, 10, 60},
+		WalkableSlopeAngle:     45,
+		WalkableHeight:         10,
+		WalkableClimb:          4,
+		WalkableRadius:         2,
+		MaxEdgeLen:             40,
+		MaxSimplificationError: 1.3,
+		MinRegionArea:          64,
+		MergeRegionArea:        400,
+		MaxVertsPerPoly:        6,
+		DetailSampleDist:       1.8,
+		DetailSampleMaxError:   0.2,
+	}
+	cfg.Width, cfg.Height = CalcGridSize(cfg.BMin[:], cfg.BMax[:], cfg.Cs)
+	if cfg.CellCount() != 100*200 {
+		t.Errorf("CellCount() = %d, want %d", cfg.CellCount(), 100*200)
+	}
+
+	ctx := NewBuildContext(true)
+	if err := cfg.Validate(ctx); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+	if ctx.LogCount() != 0 {
+		t.Errorf("Validate() logged %q, want no warnings", ctx.LogText(0))
+	}
+
+	// A tiny cell size gives a valid config, but a huge grid.
+	big := cfg
+	big.Cs = 0.005
+	big.Width, big.Height = CalcGridSize(big.BMin[:], big.BMax[:], big.Cs)
+	if err := big.Validate(ctx); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+	if ctx.LogCount() != 1 || !strings.Contains(ctx.LogText(0), "large grid") {
+		t.Errorf("Validate() should warn about the large grid, got %d log entries", ctx.LogCount())
+	}
+
+	bad := cfg
+	bad.Ch = 0
+	bad.WalkableHeight = 1
+	bad.MaxVertsPerPoly = 2
+	err := bad.Validate(nil)
+	cerr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("Validate() = %v, want a *ConfigError", err)
+	}
+	if len(cerr.Problems) != 3 {
+		t.Errorf("Validate() found %d problems, want 3: %v", len(cerr.Problems), err)
+	}
+}
+
+func TestMemorySize(t *testing.T) {
+	mesh, areas, bmin, bmax := loadRasterizationInput(t, "dungeon.obj")
+	w, h := CalcGridSize(bmin[:], bmax[:], 0.3)
+
+	var ctx BuildContext
+	hf := NewHeightfield(w, h, bmin[:], bmax[:], 0.3, 0.2)
+	empty := hf.MemorySize()
+	require(t, empty >= int(w*h)*8, "heightfield should count its span columns")
+	require(t, RasterizeTriangles(&ctx, mesh.Verts(), mesh.VertCount(), mesh.Tris(), areas, mesh.TriCount(), hf, 4),
+		"RasterizeTriangles should succeed")
+	require(t, hf.MemorySize() > empty, "heightfield should count its span pools")
+
+	chf := loadCompactHeightfield(t, "nav_test.obj")
+	require(t, BuildDistanceField(&ctx, chf), "BuildDistanceField should succeed")
+	require(t, BuildRegions(&ctx, chf, 0, 8*8, 20*20), "BuildRegions should succeed")
+	require(t, chf.MemorySize() > int(chf.SpanCount)*(12+2+1), "compact heightfield should count its spans")
+
+	var cset ContourSet
+	require(t, BuildContours(&ctx, chf, 1.3, 40, &cset, ContourTessWallEdges), "BuildContours should succeed")
+	require(t, cset.MemorySize() > 4*4*int(cset.Conts[0].NVerts+cset.Conts[0].NRVerts),
+		"contour set should count its contours vertices")
+
+	pmesh, ok := BuildPolyMesh(&ctx, &cset, 6)
+	require(t, ok, "BuildPolyMesh should succeed")
+	require(t, pmesh.MemorySize() > 2*3*int(pmesh.NVerts), "polygon mesh should count its vertices")
+
+	dmesh, ok := BuildPolyMeshDetail(&ctx, pmesh, chf, 6*0.3, 0.2)
+	require(t, ok, "BuildPolyMeshDetail should succeed")
+	require(t, dmesh.MemorySize() > 4*3*int(dmesh.NVerts)+4*int(dmesh.NTris), "detail mesh should count its vertices and triangles")
+}
@@ -72,6 +72,11 @@ func BuildRegionsMonotone(ctx *BuildContext, chf *CompactHeightfield,
 
 	// Sweep one line at a time.
 	for y := borderSize; y < h-borderSize; y++ {
+		if ctx.Cancelled() {
+			ctx.Errorf("BuildRegionsMonotone: Build cancelled.")
+			return false
+		}
+		ctx.progress(TimerBuildRegions, y-borderSize, h-2*borderSize)
 		// Collect spans from this row.
 		prev = make([]int32, id+1)
 		//memset(&prev[0],0,sizeof(int)*id);
@@ -168,9 +173,209 @@ func BuildRegionsMonotone(ctx *BuildContext, chf *CompactHeightfield,
 		chf.Spans[i].Reg = srcReg[i]
 	}
 
+	ctx.progress(TimerBuildRegions, 1, 1)
+
 	return true
 }
 
+// BuildDistanceField builds the distance field for the specified compact
+// heightfield.
+//
+//	Arguments:
+//	 ctx      The build context to use during the operation.
+//	 chf      A populated compact heightfield.
+//
+// Returns true if the operation completed successfully.
+//
+// The distance of a span is its distance to the nearest border of its area,
+// in half cell units, blurred. This is usually the second to the last step
+// in creating a fully built compact heightfield. This step is required before
+// regions are built using BuildRegions.
+//
+// The distance field will be available via the CompactHeightfield.MaxDistance
+// and CompactHeightfield.Dist fields.
+//
+// see CompactHeightfield, BuildRegions
+func BuildDistanceField(ctx *BuildContext, chf *CompactHeightfield) bool {
+	assert.True(ctx != nil, "ctx should not be nil")
+
+	ctx.StartTimer(TimerBuildDistanceField)
+	defer ctx.StopTimer(TimerBuildDistanceField)
+
+	src := make([]uint16, chf.SpanCount)
+	dst := make([]uint16, chf.SpanCount)
+
+	ctx.StartTimer(TimerBuildDistanceFieldDist)
+	chf.MaxDistance = calculateDistanceField(chf, src)
+	ctx.StopTimer(TimerBuildDistanceFieldDist)
+
+	if ctx.Cancelled() {
+		ctx.Errorf("BuildDistanceField: Build cancelled.")
+		return false
+	}
+	ctx.progress(TimerBuildDistanceField, 1, 2)
+
+	ctx.StartTimer(TimerBuildDistanceFieldBlur)
+	// Blur and store distance.
+	chf.Dist = boxBlur(chf, 1, src, dst)
+	ctx.StopTimer(TimerBuildDistanceFieldBlur)
+
+	ctx.progress(TimerBuildDistanceField, 2, 2)
+
+	return true
+}
+
+// calculateDistanceField computes in dist the distance of each span to the
+// nearest area border, with a 2 pass chamfer distance transform, and returns
+// the maximum distance.
+func calculateDistanceField(chf *CompactHeightfield, dist []uint16) uint16 {
+	w := chf.Width
+	h := chf.Height
+
+	// Init distance and points.
+	for i := range dist {
+		dist[i] = 0xffff
+	}
+
+	// Mark boundary cells.
+	for y := int32(0); y < h; y++ {
+		for x := int32(0); x < w; x++ {
+			c := &chf.Cells[x+y*w]
+			ni := int32(c.Index) + int32(c.Count)
+			for i := int32(c.Index); i < ni; i++ {
+				s := &chf.Spans[i]
+				area := chf.Areas[i]
+
+				nc := 0
+				for dir := int32(0); dir < 4; dir++ {
+					if GetCon(s, dir) != notConnected {
+						ax := x + GetDirOffsetX(dir)
+						ay := y + GetDirOffsetY(dir)
+						ai := int32(chf.Cells[ax+ay*w].Index) + GetCon(s, dir)
+						if area == chf.Areas[ai] {
+							nc++
+						}
+					}
+				}
+				if nc != 4 {
+					dist[i] = 0
+				}
+			}
+		}
+	}
+
+	// relax updates the distance of span i with the one of its neighbour in
+	// direction dir, and of the diagonal neighbour in direction dir then
+	// dir2.
+	relax := func(x, y, i, dir, dir2 int32) {
+		s := &chf.Spans[i]
+		if GetCon(s, dir) == notConnected {
+			return
+		}
+		ax := x + GetDirOffsetX(dir)
+		ay := y + GetDirOffsetY(dir)
+		ai := int32(chf.Cells[ax+ay*w].Index) + GetCon(s, dir)
+		if int32(dist[ai])+2 < int32(dist[i]) {
+			dist[i] = dist[ai] + 2
+		}
+
+		as := &chf.Spans[ai]
+		if GetCon(as, dir2) != notConnected {
+			aax := ax + GetDirOffsetX(dir2)
+			aay := ay + GetDirOffsetY(dir2)
+			aai := int32(chf.Cells[aax+aay*w].Index) + GetCon(as, dir2)
+			if int32(dist[aai])+3 < int32(dist[i]) {
+				dist[i] = dist[aai] + 3
+			}
+		}
+	}
+
+	// Pass 1
+	for y := int32(0); y < h; y++ {
+		for x := int32(0); x < w; x++ {
+			c := &chf.Cells[x+y*w]
+			ni := int32(c.Index) + int32(c.Count)
+			for i := int32(c.Index); i < ni; i++ {
+				// (-1,0) and (-1,-1)
+				relax(x, y, i, 0, 3)
+				// (0,-1) and (1,-1)
+				relax(x, y, i, 3, 2)
+			}
+		}
+	}
+
+	// Pass 2
+	for y := h - 1; y >= 0; y-- {
+		for x := w - 1; x >= 0; x-- {
+			c := &chf.Cells[x+y*w]
+			ni := int32(c.Index) + int32(c.Count)
+			for i := int32(c.Index); i < ni; i++ {
+				// (1,0) and (1,1)
+				relax(x, y, i, 2, 1)
+				// (0,1) and (-1,1)
+				relax(x, y, i, 1, 0)
+			}
+		}
+	}
+
+	var maxDist uint16
+	for _, d := range dist {
+		if d > maxDist {
+			maxDist = d
+		}
+	}
+	return maxDist
+}
+
+// boxBlur blurs the distances of src into dst, with a 3x3 box filter. The
+// distances not greater than thr*2 are left unchanged. Returns dst.
+func boxBlur(chf *CompactHeightfield, thr int32, src, dst []uint16) []uint16 {
+	w := chf.Width
+	h := chf.Height
+
+	thr *= 2
+
+	for y := int32(0); y < h; y++ {
+		for x := int32(0); x < w; x++ {
+			c := &chf.Cells[x+y*w]
+			ni := int32(c.Index) + int32(c.Count)
+			for i := int32(c.Index); i < ni; i++ {
+				s := &chf.Spans[i]
+				cd := int32(src[i])
+				if cd <= thr {
+					dst[i] = uint16(cd)
+					continue
+				}
+
+				d := cd
+				for dir := int32(0); dir < 4; dir++ {
+					if GetCon(s, dir) == notConnected {
+						d += cd * 2
+						continue
+					}
+					ax := x + GetDirOffsetX(dir)
+					ay := y + GetDirOffsetY(dir)
+					ai := int32(chf.Cells[ax+ay*w].Index) + GetCon(s, dir)
+					d += int32(src[ai])
+
+					as := &chf.Spans[ai]
+					dir2 := (dir + 1) & 0x3
+					if GetCon(as, dir2) != notConnected {
+						ax2 := ax + GetDirOffsetX(dir2)
+						ay2 := ay + GetDirOffsetY(dir2)
+						ai2 := int32(chf.Cells[ax2+ay2*w].Index) + GetCon(as, dir2)
+						d += int32(src[ai2])
+					} else {
+						d += cd
+					}
+				}
+				dst[i] = uint16((d + 5) / 9)
+			}
+		}
+	}
+	return dst
+}
+
 // BuildRegions builds region data for the heightfield using watershed
 // partitioning.
 //
@@ -185,13 +390,13 @@ func BuildRegionsMonotone(ctx *BuildContext, chf *CompactHeightfield,
 //	                 if possible, be merged with larger regions.
 //	                 [Limit: >=0] [Units: vx]
 //
-// Returns True if the operation completed successfully.
+// Returns true if the operation completed successfully.
 //
 // Non-null regions will consist of connected, non-overlapping walkable spans
 // that form a single contour.
 // Contours will form simple polygons.
 //
-// If multiple regions form an area that is smaller than @p minRegionArea, then
+// If multiple regions form an area that is smaller than `minRegionArea`, then
 // all spans will be re-assigned to the zero (null) region.
 //
 // Watershed partitioning can result in smaller than necessary regions,
@@ -201,8 +406,8 @@ func BuildRegionsMonotone(ctx *BuildContext, chf *CompactHeightfield,
 // See the Config documentation for more information on the configuration
 // parameters.
 //
-// The region data will be available via the CompactHeightfield::maxRegions and
-// CompactSpan::reg fields.
+// The region data will be available via the CompactHeightfield.MaxRegions and
+// CompactSpan.Reg fields.
 //
 // Warning: The distance field must be created using BuildDistanceField
 // before attempting to build regions.
@@ -211,53 +416,46 @@ func BuildRegionsMonotone(ctx *BuildContext, chf *CompactHeightfield,
 func BuildRegions(ctx *BuildContext, chf *CompactHeightfield,
 	borderSize, minRegionArea, mergeRegionArea int32) bool {
 
-	panic("untested")
 	assert.True(ctx != nil, "ctx should not be nil")
 
 	ctx.StartTimer(TimerBuildRegions)
 	defer ctx.StopTimer(TimerBuildRegions)
 
+	if int32(len(chf.Dist)) < chf.SpanCount {
+		ctx.Errorf("BuildRegions: missing distance field.")
+		return false
+	}
+
 	w := chf.Width
 	h := chf.Height
 
-	buf := make([]uint16, chf.SpanCount*4)
 	ctx.StartTimer(TimerBuildRegionsWatershed)
 
 	const (
-		LOG_NB_STACKS = 3
-		NB_STACKS     = 1 << LOG_NB_STACKS
-	)
-	var (
-		lvlStacks [][]int32
-		stack     []int32
-		//visited []int32
+		logNbStacks = 3
+		nbStacks    = 1 << logNbStacks
 	)
-
-	lvlStacks = make([][]int32, NB_STACKS)
+	var lvlStacks [nbStacks][]int32
 	for i := range lvlStacks {
-		lvlStacks[i] = make([]int32, 1024)
+		lvlStacks[i] = make([]int32, 0, 1024)
+	}
+	ws := watershed{
+		stack: make([]int32, 0, 1024),
+		dirty: make([]int32, 0, 1024),
 	}
-	stack = make([]int32, 2024)
-	//visited = make([]int32, 2024)
 
-	srcReg := buf[:]
+	buf := make([]uint16, chf.SpanCount*2)
+	srcReg := buf[:chf.SpanCount]
 	srcDist := buf[chf.SpanCount:]
-	dstReg := buf[chf.SpanCount*2:]
-	dstDist := buf[chf.SpanCount*3:]
-
-	//memset(srcReg, 0, sizeof(unsigned short)*chf.spanCount);
-	//memset(srcDist, 0, sizeof(unsigned short)*chf.spanCount);
 
 	regionID := uint16(1)
-	// original C code:
-	// level := uint16(chf.maxDistance+1) & ~1;
-	level := uint16(int(chf.MaxDistance+1) & int(^1))
+	level := (chf.MaxDistance + 1) &^ 1
 
 	// TODO: Figure better formula, expandIters defines how much the
 	// watershed "overflows" and simplifies the regions. Tying it to
 	// agent radius was usually good indication how greedy it could be.
 	//	const int expandIters = 4 + walkableRadius * 2;
-	const expandIters int = 8
+	const expandIters = 8
 
 	if borderSize > 0 {
 		// Make sure border will not overflow.
@@ -277,73 +475,60 @@ func BuildRegions(ctx *BuildContext, chf *CompactHeightfield,
 		chf.BorderSize = borderSize
 	}
 
+	maxLevel := int32(level)
 	sID := -1
 	for level > 0 {
+		if ctx.Cancelled() {
+			ctx.Errorf("BuildRegions: Build cancelled.")
+			return false
+		}
+		ctx.progress(TimerBuildRegions, maxLevel-int32(level), maxLevel)
 		if level >= 2 {
-			level = level - 2
+			level -= 2
 		} else {
 			level = 0
 		}
-		sID = (sID + 1) & (NB_STACKS - 1)
-
-		//		ctx->startTimer(RC_TIMER_DIVIDE_TO_LEVELS);
+		sID = (sID + 1) & (nbStacks - 1)
 
 		if sID == 0 {
-			sortCellsByLevel(level, chf, srcReg, NB_STACKS, lvlStacks[:], 1)
+			sortCellsByLevel(level, chf, srcReg, lvlStacks[:], 1)
 		} else {
 			// copy left overs from last level
-			appendStacks(lvlStacks[sID-1], lvlStacks[sID], srcReg)
-		}
-
-		//		ctx->stopTimer(RC_TIMER_DIVIDE_TO_LEVELS);
-
-		{
-			ctx.StartTimer(TimerBuildRegionsExpand)
-
-			// Expand current regions until no empty connected cells found.
-			// TODO: CHECK THIS
-			if swapped := expandRegions(expandIters, level, chf, &srcReg, &srcDist, &dstReg, &dstDist, &lvlStacks[sID], false); swapped {
-				srcReg, dstReg = dstReg, srcReg
-				srcDist, dstDist = dstDist, srcDist
-			}
-			ctx.StopTimer(TimerBuildRegionsExpand)
+			lvlStacks[sID] = appendStacks(lvlStacks[sID-1], lvlStacks[sID], srcReg)
 		}
 
-		{
-			ctx.StartTimer(TimerBuildRegionsFlood)
-
-			// Mark new regions with IDs.
-			for j := 0; j < len(lvlStacks[sID]); j += 3 {
-				x := lvlStacks[sID][j]
-				y := lvlStacks[sID][j+1]
-				i := lvlStacks[sID][j+2]
-				if i >= 0 && srcReg[i] == 0 {
-					if floodRegion(x, y, i, level, regionID, chf, srcReg, srcDist, &stack) {
-						if regionID == 0xFFFF {
-							ctx.Errorf("rcBuildRegions: Region ID overflow")
-							return false
-						}
-
-						regionID++
+		ctx.StartTimer(TimerBuildRegionsExpand)
+		// Expand current regions until no empty connected cells found.
+		lvlStacks[sID] = ws.expandRegions(expandIters, level, chf, srcReg, srcDist, lvlStacks[sID], false)
+		ctx.StopTimer(TimerBuildRegionsExpand)
+
+		ctx.StartTimer(TimerBuildRegionsFlood)
+		// Mark new regions with IDs.
+		stack := lvlStacks[sID]
+		for j := 0; j < len(stack); j += 3 {
+			x, y, i := stack[j], stack[j+1], stack[j+2]
+			if i >= 0 && srcReg[i] == 0 {
+				if ws.floodRegion(x, y, i, level, regionID, chf, srcReg, srcDist) {
+					if regionID == 0xFFFF {
+						ctx.Errorf("BuildRegions: Region ID overflow")
+						return false
 					}
+					regionID++
 				}
 			}
-			ctx.StopTimer(TimerBuildRegionsFlood)
 		}
+		ctx.StopTimer(TimerBuildRegionsFlood)
 	}
 
 	// Expand current regions until no empty connected cells found.
-	if swapped := expandRegions(expandIters*8, 0, chf, &srcReg, &srcDist, &dstReg, &dstDist, &stack, true); swapped {
-		srcReg, dstReg = dstReg, srcReg
-		srcDist, dstDist = dstDist, srcDist
-	}
+	ws.stack = ws.expandRegions(expandIters*8, 0, chf, srcReg, srcDist, ws.stack, true)
 
-	ctx.StartTimer(TimerBuildRegionsWatershed)
+	ctx.StopTimer(TimerBuildRegionsWatershed)
 
 	{
 		ctx.StartTimer(TimerBuildRegionsFilter)
 
-		// Merge regions and filter out smalle regions.
+		// Merge regions and filter out small regions.
 		var overlaps []int32
 		chf.MaxRegions = regionID
 		if !mergeAndFilterRegions(ctx, minRegionArea, mergeRegionArea, &chf.MaxRegions, chf, srcReg, &overlaps) {
@@ -352,7 +537,7 @@ func BuildRegions(ctx *BuildContext, chf *CompactHeightfield,
 
 		// If overlapping regions were found during merging, split those regions.
 		if len(overlaps) > 0 {
-			ctx.Errorf("rcBuildRegions: %d overlapping regions.", len(overlaps))
+			ctx.Errorf("BuildRegions: %d overlapping regions.", len(overlaps))
 		}
 		ctx.StopTimer(TimerBuildRegionsFilter)
 	}
@@ -362,6 +547,8 @@ func BuildRegions(ctx *BuildContext, chf *CompactHeightfield,
 		chf.Spans[i].Reg = srcReg[i]
 	}
 
+	ctx.progress(TimerBuildRegions, 1, 1)
+
 	return true
 }
 
@@ -380,81 +567,81 @@ func paintRectRegion(minx, maxx, miny, maxy int32, regID uint16, chf *CompactHei
 	}
 }
 
-func floodRegion(x, y, i int32,
+// watershed holds the buffers used while building the watershed regions, they
+// are allocated once and reused for all the levels.
+type watershed struct {
+	stack []int32 // Flood fill stack. [(x, y, i) * n]
+	dirty []int32 // Spans assigned during an expansion step. [(i, reg, dist) * n]
+}
+
+// floodRegion marks with the region r the spans connected to the span i, at
+// (x, y), whose distance is at least level-2.
+//
+// Returns true if at least one span was marked.
+func (ws *watershed) floodRegion(x, y, i int32,
 	level, r uint16,
 	chf *CompactHeightfield,
-	srcReg, srcDist []uint16,
-	stack *[]int32) bool {
+	srcReg, srcDist []uint16) bool {
+
 	w := chf.Width
 	area := chf.Areas[i]
 
 	// Flood fill mark region.
-	if stack == nil {
-		(*stack) = make([]int32, 0)
-	}
-	*stack = append(*stack, x, y, i)
+	stack := append(ws.stack[:0], x, y, i)
 	srcReg[i] = r
 	srcDist[i] = 0
 
-	var (
-		ci, cy, cx int32
-		lev        uint16
-		count      int32
-	)
+	var lev uint16
 	if level >= 2 {
-		lev = lev - 2
+		lev = level - 2
 	}
+	count := 0
 
-	for len(*stack) > 0 {
-
-		// pop 3 last elements
-		ci, *stack = (*stack)[len(*stack)-1], (*stack)[:len(*stack)-1]
-		cy, *stack = (*stack)[len(*stack)-1], (*stack)[:len(*stack)-1]
-		cx, *stack = (*stack)[len(*stack)-1], (*stack)[:len(*stack)-1]
+	for len(stack) > 0 {
+		n := len(stack) - 3
+		cx, cy, ci := stack[n], stack[n+1], stack[n+2]
+		stack = stack[:n]
 
 		cs := &chf.Spans[ci]
 
 		// Check if any of the neighbours already have a valid region set.
-		var (
-			ar  uint16
-			dir int32
-		)
-		dir = 0
-		for ; dir < 4; dir++ {
+		var ar uint16
+		for dir := int32(0); dir < 4; dir++ {
 			// 8 connected
-			if GetCon(cs, dir) != notConnected {
-				ax := cx + GetDirOffsetX(dir)
-				ay := cy + GetDirOffsetY(dir)
-				ai := int32(chf.Cells[ax+ay*w].Index) + GetCon(cs, dir)
-				if chf.Areas[ai] != area {
-					continue
-				}
-				nr := srcReg[ai]
-				if (nr & borderReg) != 0 {
-					// Do not take borders into account.
+			if GetCon(cs, dir) == notConnected {
+				continue
+			}
+			ax := cx + GetDirOffsetX(dir)
+			ay := cy + GetDirOffsetY(dir)
+			ai := int32(chf.Cells[ax+ay*w].Index) + GetCon(cs, dir)
+			if chf.Areas[ai] != area {
+				continue
+			}
+			nr := srcReg[ai]
+			if (nr & borderReg) != 0 {
+				// Do not take borders into account.
+				continue
+			}
+			if nr != 0 && nr != r {
+				ar = nr
+				break
+			}
+
+			as := &chf.Spans[ai]
+
+			dir2 := (dir + 1) & 0x3
+			if GetCon(as, dir2) != notConnected {
+				ax2 := ax + GetDirOffsetX(dir2)
+				ay2 := ay + GetDirOffsetY(dir2)
+				ai2 := int32(chf.Cells[ax2+ay2*w].Index) + GetCon(as, dir2)
+				if chf.Areas[ai2] != area {
 					continue
 				}
-				if nr != 0 && nr != r {
-					ar = nr
+				nr2 := srcReg[ai2]
+				if nr2 != 0 && nr2 != r {
+					ar = nr2
 					break
 				}
-
-				as := &chf.Spans[ai]
-
-				dir2 := int32((dir + 1) & 0x3)
-				if GetCon(as, dir2) != notConnected {
-					ax2 := ax + GetDirOffsetX(dir2)
-					ay2 := ay + GetDirOffsetY(dir2)
-					ai2 := int32(chf.Cells[ax2+ay2*w].Index) + GetCon(as, dir2)
-					if chf.Areas[ai2] != area {
-						continue
-					}
-					nr2 := srcReg[ai2]
-					if nr2 != 0 && nr2 != r {
-						ar = nr2
-						break
-					}
-				}
 			}
 		}
 		if ar != 0 {
@@ -465,46 +652,54 @@ func floodRegion(x, y, i int32,
 		count++
 
 		// Expand neighbours.
-		for dir = 0; dir < 4; dir++ {
-			if GetCon(cs, dir) != notConnected {
-				ax := cx + GetDirOffsetX(dir)
-				ay := cy + GetDirOffsetY(dir)
-				ai := int32(chf.Cells[ax+ay*w].Index) + GetCon(cs, dir)
-				if chf.Areas[ai] != area {
-					continue
-				}
-				if chf.Dist[ai] >= lev && srcReg[ai] == 0 {
-					srcReg[ai] = r
-					srcDist[ai] = 0
-					*stack = append(*stack, ax, ay, ai)
-				}
+		for dir := int32(0); dir < 4; dir++ {
+			if GetCon(cs, dir) == notConnected {
+				continue
+			}
+			ax := cx + GetDirOffsetX(dir)
+			ay := cy + GetDirOffsetY(dir)
+			ai := int32(chf.Cells[ax+ay*w].Index) + GetCon(cs, dir)
+			if chf.Areas[ai] != area {
+				continue
+			}
+			if chf.Dist[ai] >= lev && srcReg[ai] == 0 {
+				srcReg[ai] = r
+				srcDist[ai] = 0
+				stack = append(stack, ax, ay, ai)
 			}
 		}
 	}
 
+	ws.stack = stack
 	return count > 0
 }
 
-func expandRegions(maxIter int, level uint16,
+// expandRegions grows the regions into the unassigned spans of stack, or, if
+// fillStack is true, into all the unassigned spans whose distance is at least
+// level.
+//
+// Each step assigns the spans next to a region, the assigned spans are then
+// removed from stack. Expansion stops when no span can be assigned, or after
+// maxIter steps if level is not 0. Returns the spans of stack left
+// unassigned.
+func (ws *watershed) expandRegions(maxIter int, level uint16,
 	chf *CompactHeightfield,
-	srcReg, srcDist, dstReg, dstDist *[]uint16,
-	stack *[]int32, fillStack bool) (swapped bool) {
+	srcReg, srcDist []uint16,
+	stack []int32, fillStack bool) []int32 {
 
-	panic("untested")
-	swapped = false
 	w := chf.Width
 	h := chf.Height
 
 	if fillStack {
 		// Find cells revealed by the raised level.
-		*stack = make([]int32, 0)
+		stack = stack[:0]
 		for y := int32(0); y < h; y++ {
 			for x := int32(0); x < w; x++ {
 				c := &chf.Cells[x+y*w]
 				i := int32(c.Index)
 				for ni := int32(c.Index) + int32(c.Count); i < ni; i++ {
-					if chf.Dist[i] >= level && (*srcReg)[i] == 0 && chf.Areas[i] != nullArea {
-						*stack = append(*stack, x, y, i)
+					if chf.Dist[i] >= level && srcReg[i] == 0 && chf.Areas[i] != nullArea {
+						stack = append(stack, x, y, i)
 					}
 				}
 			}
@@ -512,36 +707,30 @@ func expandRegions(maxIter int, level uint16,
 	} else {
 		// use cells in the input stack
 		// mark all cells which already have a region
-		for j := 0; j < len(*stack); j += 3 {
-			i := (*stack)[j+2]
-			if (*srcReg)[i] != 0 {
-				(*stack)[j+2] = -1
+		for j := 0; j < len(stack); j += 3 {
+			if i := stack[j+2]; i >= 0 && srcReg[i] != 0 {
+				stack[j+2] = -1
 			}
 		}
 	}
 
-	var iter int
-	for len(*stack) > 0 {
-		failed := 0
+	iter := 0
+	for len(stack) > 0 {
+		dirty := ws.dirty[:0]
 
-		copy(*dstReg, (*srcReg)[:chf.SpanCount])
-		copy(*dstDist, (*srcDist)[:chf.SpanCount])
-
-		for j := 0; j < len(*stack); j += 3 {
-			x := (*stack)[j+0]
-			y := (*stack)[j+1]
-			i := (*stack)[j+2]
+		// Spans left unassigned are moved to the front of the stack.
+		n := 0
+		for j := 0; j < len(stack); j += 3 {
+			x, y, i := stack[j], stack[j+1], stack[j+2]
 			if i < 0 {
-				failed++
 				continue
 			}
 
-			r := (*srcReg)[i]
+			r := srcReg[i]
 			d2 := int32(0xffff)
 			area := chf.Areas[i]
 			s := &chf.Spans[i]
-			var dir int32
-			for dir = 0; dir < 4; dir++ {
+			for dir := int32(0); dir < 4; dir++ {
 				if GetCon(s, dir) == notConnected {
 					continue
 				}
@@ -551,27 +740,32 @@ func expandRegions(maxIter int, level uint16,
 				if chf.Areas[ai] != area {
 					continue
 				}
-				if (*srcReg)[ai] > 0 && ((*srcReg)[ai]&borderReg) == 0 {
-					if int32((*srcDist)[ai]+2) < int32(d2) {
-						r = (*srcReg)[ai]
-						d2 = int32((*srcDist)[ai] + 2)
+				if srcReg[ai] > 0 && (srcReg[ai]&borderReg) == 0 {
+					if int32(srcDist[ai])+2 < d2 {
+						r = srcReg[ai]
+						d2 = int32(srcDist[ai]) + 2
 					}
 				}
 			}
 			if r != 0 {
-				(*stack)[j+2] = -1 // mark as used
-				(*dstReg)[i] = r
-				(*dstDist)[i] = uint16(d2)
+				dirty = append(dirty, i, int32(r), d2)
 			} else {
-				failed++
+				stack[n], stack[n+1], stack[n+2] = x, y, i
+				n += 3
 			}
 		}
+		stack = stack[:n]
+
+		// The spans of a step are assigned from the regions of the previous
+		// step only, so the assignments are applied once the step is over.
+		for j := 0; j < len(dirty); j += 3 {
+			i := dirty[j]
+			srcReg[i] = uint16(dirty[j+1])
+			srcDist[i] = uint16(dirty[j+2])
+		}
+		ws.dirty = dirty
 
-		*srcReg, *dstReg = *dstReg, *srcReg
-		*srcDist, *dstDist = *dstDist, *srcDist
-		swapped = !swapped
-
-		if failed*3 == len(*stack) {
+		if len(dirty) == 0 {
 			break
 		}
 
@@ -583,21 +777,25 @@ func expandRegions(maxIter int, level uint16,
 		}
 	}
 
-	return swapped
+	return stack
 }
 
+// sortCellsByLevel puts the unassigned spans into the stacks, by distance
+// level. The first stack receives the spans whose level is at least
+// startLevel, each following stack the spans of the next lower level range.
+// loglevelsPerStack is the number of levels per stack, as a bit shift.
 func sortCellsByLevel(startLevel uint16,
 	chf *CompactHeightfield,
 	srcReg []uint16,
-	nbStacks uint32, stacks [][]int32,
-	// the levels per stack (2 in our case) as a bit shift
+	stacks [][]int32,
 	loglevelsPerStack uint16) {
+
 	w := chf.Width
 	h := chf.Height
-	startLevel = startLevel >> loglevelsPerStack
+	start := int(startLevel >> loglevelsPerStack)
 
-	for j := uint32(0); j < nbStacks; j++ {
-		stacks[j] = make([]int32, 0)
+	for j := range stacks {
+		stacks[j] = stacks[j][:0]
 	}
 
 	// put all cells in the level range into the appropriate stacks
@@ -610,24 +808,24 @@ func sortCellsByLevel(startLevel uint16,
 					continue
 				}
 
-				level := chf.Dist[i] >> loglevelsPerStack
-				sID := startLevel - level
-				if uint32(sID) >= nbStacks {
+				level := int(chf.Dist[i] >> loglevelsPerStack)
+				sID := start - level
+				if sID >= len(stacks) {
 					continue
 				}
 				if sID < 0 {
 					sID = 0
 				}
 
-				stacks[sID] = append(stacks[sID], x)
-				stacks[sID] = append(stacks[sID], y)
-				stacks[sID] = append(stacks[sID], i)
+				stacks[sID] = append(stacks[sID], x, y, i)
 			}
 		}
 	}
 }
 
-func appendStacks(srcStack, dstStack []int32, srcReg []uint16) {
+// appendStacks appends to dstStack the spans of srcStack that are still
+// unassigned, and returns it.
+func appendStacks(srcStack, dstStack []int32, srcReg []uint16) []int32 {
 	for j := 0; j < len(srcStack); j += 3 {
 		i := srcStack[j+2]
 		if (i < 0) || (srcReg[i] != 0) {
@@ -635,6 +833,7 @@ func appendStacks(srcStack, dstStack []int32, srcReg []uint16) {
 		}
 		dstStack = append(dstStack, srcStack[j:j+3]...)
 	}
+	return dstStack
 }
 
 type Region struct {
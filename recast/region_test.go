@@ -0,0 +1,148 @@
+package recast
+
+import (
+	"testing"
+)
+
+// loadCompactHeightfield builds the eroded compact heightfield of an OBJ mesh
+// of the test data, with the default build settings.
+func loadCompactHeightfield(tb testing.TB, name string) *CompactHeightfield {
+	const (
+		cs, ch         = 0.3, 0.2
+		walkableHeight = 10 // 2m
+		walkableClimb  = 4  // 0.9m
+		walkableRadius = 2  // 0.6m
+	)
+	mesh, areas, bmin, bmax := loadRasterizationInput(tb, name)
+	w, h := CalcGridSize(bmin[:], bmax[:], cs)
+
+	var ctx BuildContext
+	solid := NewHeightfield(w, h, bmin[:], bmax[:], cs, ch)
+	if !RasterizeTriangles(&ctx, mesh.Verts(), mesh.VertCount(), mesh.Tris(), areas, mesh.TriCount(), solid, walkableClimb) {
+		tb.Fatal("RasterizeTriangles failed")
+	}
+	FilterLowHangingWalkableObstacles(&ctx, walkableClimb, solid)
+	FilterLedgeSpans(&ctx, walkableHeight, walkableClimb, solid)
+	FilterWalkableLowHeightSpans(&ctx, walkableHeight, solid)
+
+	chf := &CompactHeightfield{}
+	if !BuildCompactHeightfield(&ctx, walkableHeight, walkableClimb, solid, chf) {
+		tb.Fatal("BuildCompactHeightfield failed")
+	}
+	if !ErodeWalkableArea(&ctx, walkableRadius, chf) {
+		tb.Fatal("ErodeWalkableArea failed")
+	}
+	return chf
+}
+
+func TestBuildDistanceField(t *testing.T) {
+	var ctx BuildContext
+	chf := newFlatCompactHeightfield(t, &ctx, 10, 10)
+
+	require(t, BuildDistanceField(&ctx, chf), "BuildDistanceField should succeed")
+	require(t, chf.MaxDistance == 8, "max distance should be 8")
+
+	for z := int32(0); z < chf.Height; z++ {
+		for x := int32(0); x < chf.Width; x++ {
+			// distance to the nearest border, in half cells
+			d := iMin(iMin(x, chf.Width-1-x), iMin(z, chf.Height-1-z)) * 2
+			got := int32(chf.Dist[chf.Cells[x+z*chf.Width].Index])
+			switch {
+			case d <= 2:
+				// not blurred
+				require(t, got == d, "border distances should be exact")
+			default:
+				require(t, got > 2 && got <= d, "distances should increase toward the center")
+			}
+		}
+	}
+}
+
+func TestBuildRegionsWatershed(t *testing.T) {
+	for _, name := range []string{"dungeon.obj", "nav_test.obj"} {
+		t.Run(name, func(t *testing.T) {
+			var ctx BuildContext
+			chf := loadCompactHeightfield(t, name)
+
+			require(t, BuildDistanceField(&ctx, chf), "BuildDistanceField should succeed")
+			require(t, BuildRegions(&ctx, chf, 0, 8*8, 20*20), "BuildRegions should succeed")
+			require(t, chf.MaxRegions > 1, "there should be several regions")
+
+			// Each region should be made of connected spans.
+			visited := make([]bool, chf.SpanCount)
+			seen := make(map[uint16]bool)
+			var stack []int32
+			for z := int32(0); z < chf.Height; z++ {
+				for x := int32(0); x < chf.Width; x++ {
+					c := &chf.Cells[x+z*chf.Width]
+					for i := int32(c.Index); i < int32(c.Index)+int32(c.Count); i++ {
+						reg := chf.Spans[i].Reg
+						if reg == 0 || visited[i] {
+							continue
+						}
+						require(t, reg <= chf.MaxRegions, "region ids should not exceed MaxRegions")
+						if seen[reg] {
+							t.Fatalf("region %d is not connected", reg)
+						}
+						seen[reg] = true
+
+						// flood fill the region
+						visited[i] = true
+						stack = append(stack[:0], x, z, i)
+						for len(stack) > 0 {
+							n := len(stack) - 3
+							cx, cz, ci := stack[n], stack[n+1], stack[n+2]
+							stack = stack[:n]
+							s := &chf.Spans[ci]
+							for dir := int32(0); dir < 4; dir++ {
+								if GetCon(s, dir) == notConnected {
+									continue
+								}
+								ax, az := cx+GetDirOffsetX(dir), cz+GetDirOffsetY(dir)
+								ai := int32(chf.Cells[ax+az*chf.Width].Index) + GetCon(s, dir)
+								if chf.Spans[ai].Reg == reg && !visited[ai] {
+									visited[ai] = true
+									stack = append(stack, ax, az, ai)
+								}
+							}
+						}
+					}
+				}
+			}
+			require(t, len(seen) > 1, "there should be several non-empty regions")
+		})
+	}
+}
+
+func benchmarkBuildRegions(b *testing.B, name string, watershed bool) {
+	chf := loadCompactHeightfield(b, name)
+
+	var ctx BuildContext
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if watershed {
+			if !BuildDistanceField(&ctx, chf) || !BuildRegions(&ctx, chf, 0, 8*8, 20*20) {
+				b.Fatal("BuildRegions failed")
+			}
+		} else if !BuildRegionsMonotone(&ctx, chf, 0, 8*8, 20*20) {
+			b.Fatal("BuildRegionsMonotone failed")
+		}
+	}
+}
+
+func BenchmarkBuildRegionsWatershedDungeon(b *testing.B) {
+	benchmarkBuildRegions(b, "dungeon.obj", true)
+}
+
+func BenchmarkBuildRegionsWatershedNavTest(b *testing.B) {
+	benchmarkBuildRegions(b, "nav_test.obj", true)
+}
+
+func BenchmarkBuildRegionsMonotoneDungeon(b *testing.B) {
+	benchmarkBuildRegions(b, "dungeon.obj", false)
+}
+
+func BenchmarkBuildRegionsMonotoneNavTest(b *testing.B) {
+	benchmarkBuildRegions(b, "nav_test.obj", false)
+}
@@ -0,0 +1,111 @@
+package recast
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// A SurfaceMesh is a triangle mesh of the walkable surface of a compact
+// heightfield.
+type SurfaceMesh struct {
+	Verts []float32 // The mesh vertices, in world space. [Form: (x, y, z) * vertex count]
+	Tris  []int32   // The triangles vertex indices. [Form: (vertA, vertB, vertC) * triangle count]
+	Areas []uint8   // The area id of each triangle. [Size: triangle count]
+}
+
+// VertCount returns the number of vertices of the mesh.
+func (m *SurfaceMesh) VertCount() int {
+	return len(m.Verts) / 3
+}
+
+// TriCount returns the number of triangles of the mesh.
+func (m *SurfaceMesh) TriCount() int {
+	return len(m.Tris) / 3
+}
+
+// WriteOBJ writes the mesh to w, in the Wavefront OBJ format.
+func (m *SurfaceMesh) WriteOBJ(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for i := 0; i < len(m.Verts); i += 3 {
+		fmt.Fprintf(bw, "v %f %f %f\n", m.Verts[i], m.Verts[i+1], m.Verts[i+2])
+	}
+	for i := 0; i < len(m.Tris); i += 3 {
+		// OBJ indices are 1-based.
+		fmt.Fprintf(bw, "f %d %d %d\n", m.Tris[i]+1, m.Tris[i+1]+1, m.Tris[i+2]+1)
+	}
+	return bw.Flush()
+}
+
+// ExtractWalkableSurface extracts the top surface of the walkable spans of a
+// compact heightfield, as a triangle mesh.
+//
+//	Arguments:
+//	 ctx      The build context to use during the operation.
+//	 chf      A fully built compact heightfield.
+//
+//	Return values:
+//	 mesh     The walkable surface mesh.
+//	 ok       false if the operation failed.
+//
+// Each walkable span outside of the heightfield border gives a quad, made of 2
+// triangles, covering its cell on the xz-plane at the height of the span floor.
+// The vertices shared by adjacent quads at the same height are merged. The
+// triangles are wound so that their normals point up.
+//
+// The surface is the one the agents can stand on, once the walkable area has
+// been eroded and the areas marked. It may be extracted right after the
+// compact heightfield has been built, without building the regions, contours
+// and polygon meshes, for example to place decals or to analyze the surface.
+func ExtractWalkableSurface(ctx *BuildContext, chf *CompactHeightfield) (*SurfaceMesh, bool) {
+	if chf == nil || int32(len(chf.Cells)) < chf.Width*chf.Height {
+		ctx.Errorf("ExtractWalkableSurface: invalid compact heightfield.")
+		return nil, false
+	}
+
+	type vertKey struct {
+		x, y, z int32
+	}
+
+	var (
+		mesh  SurfaceMesh
+		verts = make(map[vertKey]int32)
+		w, h  = chf.Width, chf.Height
+		bs    = chf.BorderSize
+	)
+
+	vert := func(x, y, z int32) int32 {
+		k := vertKey{x, y, z}
+		if idx, ok := verts[k]; ok {
+			return idx
+		}
+		idx := int32(len(mesh.Verts) / 3)
+		mesh.Verts = append(mesh.Verts,
+			chf.BMin[0]+float32(x)*chf.Cs,
+			chf.BMin[1]+float32(y)*chf.Ch,
+			chf.BMin[2]+float32(z)*chf.Cs)
+		verts[k] = idx
+		return idx
+	}
+
+	for z := bs; z < h-bs; z++ {
+		for x := bs; x < w-bs; x++ {
+			c := &chf.Cells[x+z*w]
+			for i, ni := int32(c.Index), int32(c.Index)+int32(c.Count); i < ni; i++ {
+				area := chf.Areas[i]
+				if area == nullArea {
+					continue
+				}
+				y := int32(chf.Spans[i].Y)
+				v0 := vert(x, y, z)
+				v1 := vert(x, y, z+1)
+				v2 := vert(x+1, y, z+1)
+				v3 := vert(x+1, y, z)
+				mesh.Tris = append(mesh.Tris, v0, v1, v2, v0, v2, v3)
+				mesh.Areas = append(mesh.Areas, area, area)
+			}
+		}
+	}
+
+	return &mesh, true
+}
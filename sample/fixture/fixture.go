@@ -0,0 +1,177 @@
+// Package fixture generates small canonical input geometries, and builds their
+// navigation meshes.
+//
+// The fixtures are standard, easy to reason about, situations: an open plane,
+// rooms joined by a corridor, stacked floors, an obstacle to walk around and a
+// tiled navigation mesh. They are used by the tests and are the recommended
+// way to reproduce a bug with standard geometry. They are available from the
+// command line with 'recast gen-fixtures'.
+package fixture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/arl/go-detour/detour"
+	"github.com/arl/go-detour/recast"
+	"github.com/arl/go-detour/sample/solomesh"
+	"github.com/arl/go-detour/sample/tilemesh"
+)
+
+// Fixture is a canonical input geometry.
+type Fixture struct {
+	Name        string // Name of the fixture, used as file name.
+	Description string // Short description of the geometry.
+	Tiled       bool   // Whether the navmesh is built with tilemesh, or solomesh.
+
+	verts []float32 // Vertices. [(x, y, z) * nverts]
+	tris  []int32   // Triangles, as vertex indices. [(a, b, c) * ntris]
+}
+
+// All returns all the fixtures.
+func All() []*Fixture {
+	return []*Fixture{
+		Plane(),
+		TwoRooms(),
+		TwoFloors(),
+		Donut(),
+		Tiled(),
+	}
+}
+
+// ByName returns the fixture with the given name, or nil if there is none.
+func ByName(name string) *Fixture {
+	for _, f := range All() {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Plane returns a flat 10x10 square.
+func Plane() *Fixture {
+	f := &Fixture{Name: "plane", Description: "flat 10x10 square"}
+	f.quadXZ(0, 0, 10, 10, 0)
+	return f
+}
+
+// TwoRooms returns two 8x8 rooms, joined by a 2 units wide and 6 units long
+// corridor.
+func TwoRooms() *Fixture {
+	f := &Fixture{Name: "rooms", Description: "two 8x8 rooms joined by a 2x6 corridor"}
+	f.quadXZ(0, 0, 8, 8, 0)
+	f.quadXZ(8, 3, 14, 5, 0)
+	f.quadXZ(14, 0, 22, 8, 0)
+	return f
+}
+
+// TwoFloors returns two 10x10 floors, stacked 4 units apart, joined by a ramp.
+//
+// The ramp lies along the floors, it is connected to the ground floor at
+// z = 10 and to the upper floor at z = 0.
+func TwoFloors() *Fixture {
+	f := &Fixture{Name: "floors", Description: "two stacked 10x10 floors joined by a ramp"}
+	f.quadXZ(0, 0, 10, 10, 0)
+	f.quadXZ(0, 0, 10, 10, 4)
+	f.quad(
+		[3]float32{10, 4, 0},
+		[3]float32{10, 0, 10},
+		[3]float32{13, 0, 10},
+		[3]float32{13, 4, 0})
+	return f
+}
+
+// Donut returns a 20x20 square with an 8x8 hole in its middle.
+func Donut() *Fixture {
+	f := &Fixture{Name: "donut", Description: "20x20 square with an 8x8 hole in its middle"}
+	f.quadXZ(0, 0, 20, 6, 0)
+	f.quadXZ(0, 14, 20, 20, 0)
+	f.quadXZ(0, 6, 6, 14, 0)
+	f.quadXZ(14, 6, 20, 14, 0)
+	return f
+}
+
+// Tiled returns a flat square, large enough to be built as 4x4 tiles with the
+// default tilemesh settings.
+func Tiled() *Fixture {
+	f := &Fixture{Name: "tiled", Description: "flat 38x38 square, built as 4x4 tiles", Tiled: true}
+	f.quadXZ(0, 0, 38, 38, 0)
+	return f
+}
+
+// quadXZ adds an horizontal quad, at height y, facing up.
+func (f *Fixture) quadXZ(x0, z0, x1, z1, y float32) {
+	f.quad(
+		[3]float32{x0, y, z0},
+		[3]float32{x0, y, z1},
+		[3]float32{x1, y, z1},
+		[3]float32{x1, y, z0})
+}
+
+// quad adds the quad (a, b, c, d) as 2 triangles. The vertices are given in
+// the same order as in quadXZ, for the quad to face up.
+func (f *Fixture) quad(a, b, c, d [3]float32) {
+	base := int32(len(f.verts) / 3)
+	for _, v := range [][3]float32{a, b, c, d} {
+		f.verts = append(f.verts, v[:]...)
+	}
+	f.tris = append(f.tris,
+		base, base+1, base+2,
+		base, base+2, base+3)
+}
+
+// WriteOBJ writes the geometry of the fixture to w, in OBJ format.
+func (f *Fixture) WriteOBJ(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "# %s: %s\n", f.Name, f.Description); err != nil {
+		return err
+	}
+	for i := 0; i < len(f.verts); i += 3 {
+		if _, err := fmt.Fprintf(w, "v %f %f %f\n", f.verts[i], f.verts[i+1], f.verts[i+2]); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < len(f.tris); i += 3 {
+		// OBJ indices are 1-based
+		if _, err := fmt.Fprintf(w, "f %d %d %d\n", f.tris[i]+1, f.tris[i+1]+1, f.tris[i+2]+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Build builds the navigation mesh of the fixture, with the default settings
+// of solomesh, or tilemesh if the fixture is tiled.
+func (f *Fixture) Build(ctx *recast.BuildContext) (*detour.NavMesh, error) {
+	var buf bytes.Buffer
+	if err := f.WriteOBJ(&buf); err != nil {
+		return nil, err
+	}
+	mesh := recast.NewMeshLoaderOBJ()
+	if err := mesh.Load(&buf); err != nil {
+		return nil, err
+	}
+
+	var (
+		navMesh *detour.NavMesh
+		ok      bool
+	)
+	if f.Tiled {
+		tileMesh := tilemesh.New(ctx)
+		if err := tileMesh.InputGeom().SetMesh(mesh); err != nil {
+			return nil, err
+		}
+		navMesh, ok = tileMesh.Build()
+	} else {
+		soloMesh := solomesh.New(ctx)
+		if err := soloMesh.InputGeom().SetMesh(mesh); err != nil {
+			return nil, err
+		}
+		navMesh, ok = soloMesh.Build()
+	}
+	if !ok {
+		return nil, fmt.Errorf("couldn't build %v navmesh", f.Name)
+	}
+	return navMesh, nil
+}
@@ -0,0 +1,106 @@
+package fixture
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/arl/go-detour/detour"
+	"github.com/arl/go-detour/recast"
+	"github.com/arl/gogeo/f32/d3"
+)
+
+const testDataDir = "../../testdata/fixture/"
+
+func check(t *testing.T, err error) {
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func build(t *testing.T, f *Fixture) *detour.NavMesh {
+	ctx := recast.NewBuildContext(false)
+	mesh, err := f.Build(ctx)
+	check(t, err)
+	return mesh
+}
+
+func TestFixturesGolden(t *testing.T) {
+	for _, f := range All() {
+		var obj, bin bytes.Buffer
+		check(t, f.WriteOBJ(&obj))
+		check(t, build(t, f).Encode(&bin))
+
+		for ext, buf := range map[string][]byte{".obj": obj.Bytes(), ".bin": bin.Bytes()} {
+			path := filepath.Join(testDataDir, f.Name+ext)
+			want, err := ioutil.ReadFile(path)
+			check(t, err)
+			if !bytes.Equal(buf, want) {
+				t.Errorf("%v: generated %v differs from %v", f.Name, ext, path)
+			}
+		}
+	}
+}
+
+func TestFixturesPaths(t *testing.T) {
+	tests := []struct {
+		name        string
+		from, to    d3.Vec3
+		minStraight int  // minimum number of straight path points
+		multiTile   bool // path should cross several tiles
+	}{
+		{"plane", d3.Vec3{2, 0, 2}, d3.Vec3{8, 0, 8}, 2, false},
+		{"rooms", d3.Vec3{2, 0, 2}, d3.Vec3{20, 0, 2}, 4, false},
+		{"floors", d3.Vec3{5, 0, 5}, d3.Vec3{5, 4, 5}, 3, false},
+		{"donut", d3.Vec3{10, 0, 3}, d3.Vec3{10, 0, 17}, 3, false},
+		{"tiled", d3.Vec3{2, 0, 2}, d3.Vec3{36, 0, 36}, 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mesh := build(t, ByName(tt.name))
+			_, q := detour.NewNavMeshQuery(mesh, 2048)
+			filter := detour.NewStandardQueryFilter()
+			extents := d3.NewVec3XYZ(1, 1, 1)
+
+			_, fromRef, from := q.FindNearestPoly(tt.from, extents, filter)
+			_, toRef, to := q.FindNearestPoly(tt.to, extents, filter)
+			if fromRef == 0 || toRef == 0 {
+				t.Fatalf("no polygon near %v or %v", tt.from, tt.to)
+			}
+
+			path := make([]detour.PolyRef, 256)
+			npath, st := q.FindPath(fromRef, toRef, from, to, filter, path)
+			if st != detour.Success {
+				t.Fatalf("FindPath returned status 0x%x", st)
+			}
+			path = path[:npath]
+			if path[len(path)-1] != toRef {
+				t.Fatalf("path doesn't reach the end polygon")
+			}
+
+			straight := make([]d3.Vec3, 256)
+			for i := range straight {
+				straight[i] = d3.NewVec3()
+			}
+			nstraight, st := q.FindStraightPath(from, to, path, straight, nil, nil, 0)
+			if detour.StatusFailed(st) {
+				t.Fatalf("FindStraightPath returned status 0x%x", st)
+			}
+			if nstraight < tt.minStraight {
+				t.Errorf("got %d straight path points, want at least %d", nstraight, tt.minStraight)
+			}
+
+			tiles := make(map[uint32]bool)
+			for _, ref := range path {
+				var salt, it, ip uint32
+				mesh.DecodePolyID(ref, &salt, &it, &ip)
+				tiles[it] = true
+			}
+			if tt.multiTile != (len(tiles) > 1) {
+				t.Errorf("path crosses %d tiles", len(tiles))
+			}
+		})
+	}
+}
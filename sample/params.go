@@ -0,0 +1,75 @@
+package sample
+
+import (
+	"github.com/arl/go-detour/detour"
+	"github.com/arl/go-detour/recast"
+)
+
+// AgentParams are the dimensions of the agents using a navigation mesh.
+type AgentParams struct {
+	Height   float32 // Agent height. [Unit: wu]
+	Radius   float32 // Agent radius. [Unit: wu]
+	MaxClimb float32 // Agent max climb. [Unit: wu]
+}
+
+// AgentParamsFromSettings returns the agent dimensions of build settings.
+func AgentParamsFromSettings(s *recast.BuildSettings) AgentParams {
+	return AgentParams{
+		Height:   s.AgentHeight,
+		Radius:   s.AgentRadius,
+		MaxClimb: s.AgentMaxClimb,
+	}
+}
+
+// NewNavMeshCreateParams returns the parameters to create the navigation mesh
+// data of a polygon mesh, with detour.CreateNavMeshData.
+//
+// The polygons, detail meshes, bounds, cell size and agent dimensions are set,
+// and the BV tree is built. The off-mesh connections (see
+// SetOffMeshConnections) and the tile coordinates are left for the caller to
+// set.
+//
+//	Arguments:
+//	 pmesh    The polygon mesh, the areas and flags of which have been set.
+//	 dmesh    The detail mesh of pmesh. [Opt]
+//	 cfg      The configuration pmesh has been built with.
+//	 agent    The dimensions of the agents.
+func NewNavMeshCreateParams(pmesh *recast.PolyMesh, dmesh *recast.PolyMeshDetail, cfg *recast.Config, agent AgentParams) *detour.NavMeshCreateParams {
+	params := &detour.NavMeshCreateParams{
+		Verts:          pmesh.Verts,
+		VertCount:      pmesh.NVerts,
+		Polys:          pmesh.Polys,
+		PolyAreas:      pmesh.Areas,
+		PolyFlags:      pmesh.Flags,
+		PolyCount:      pmesh.NPolys,
+		Nvp:            pmesh.Nvp,
+		WalkableHeight: agent.Height,
+		WalkableRadius: agent.Radius,
+		WalkableClimb:  agent.MaxClimb,
+		Cs:             cfg.Cs,
+		Ch:             cfg.Ch,
+		BuildBvTree:    true,
+	}
+	if dmesh != nil {
+		params.DetailMeshes = dmesh.Meshes
+		params.DetailVerts = dmesh.Verts
+		params.DetailVertsCount = dmesh.NVerts
+		params.DetailTris = dmesh.Tris
+		params.DetailTriCount = dmesh.NTris
+	}
+	copy(params.BMin[:], pmesh.BMin[:])
+	copy(params.BMax[:], pmesh.BMax[:])
+	return params
+}
+
+// SetOffMeshConnections sets the off-mesh connections of the navigation mesh
+// creation parameters to these of an input geometry.
+func SetOffMeshConnections(params *detour.NavMeshCreateParams, geom *recast.InputGeom) {
+	params.OffMeshConVerts = geom.OffMeshConnectionVerts()
+	params.OffMeshConRad = geom.OffMeshConnectionRads()
+	params.OffMeshConDir = geom.OffMeshConnectionDirs()
+	params.OffMeshConAreas = geom.OffMeshConnectionAreas()
+	params.OffMeshConFlags = geom.OffMeshConnectionFlags()
+	params.OffMeshConUserID = geom.OffMeshConnectionId()
+	params.OffMeshConCount = geom.OffMeshConnectionCount()
+}
@@ -1,5 +1,10 @@
 package sample
 
+import (
+	"github.com/arl/go-detour/detour"
+	"github.com/arl/go-detour/recast"
+)
+
 // PartitionType represents a specific heightfield partitioning method.
 type PartitionType int
 
@@ -13,7 +18,7 @@ const (
 )
 
 // These are just sample areas to use consistent values across the samples.
-// The user should specify these based on his needs.
+// The user should specify these based on their needs.
 const (
 	PolyAreaGround = iota
 	PolyAreaWater
@@ -31,3 +36,83 @@ const (
 	PolyFlagsDisabled = 0x10   // Disabled polygon
 	PolyFlagsAll      = 0xffff // All abilities.
 )
+
+// areaNames are the names of the sample areas, indexed by area id.
+var areaNames = [...]string{
+	PolyAreaGround: "ground",
+	PolyAreaWater:  "water",
+	PolyAreaRoad:   "road",
+	PolyAreaDoor:   "door",
+	PolyAreaGrass:  "grass",
+	PolyAreaJump:   "jump",
+}
+
+// AreaName returns the name of a sample area, or an empty string if area isn't
+// one of the sample areas.
+func AreaName(area uint8) string {
+	if int(area) < len(areaNames) {
+		return areaNames[area]
+	}
+	return ""
+}
+
+// AreaByName returns the id of the sample area which name is name, and false
+// if there is no such area.
+func AreaByName(name string) (uint8, bool) {
+	for i, n := range areaNames {
+		if n == name {
+			return uint8(i), true
+		}
+	}
+	return 0, false
+}
+
+// AreaFlags returns the polygon flags of a sample area, 0 if area isn't one of
+// the sample areas.
+func AreaFlags(area uint8) uint16 {
+	switch area {
+	case PolyAreaGround, PolyAreaGrass, PolyAreaRoad:
+		return PolyFlagsWalk
+	case PolyAreaWater:
+		return PolyFlagsSwim
+	case PolyAreaDoor:
+		return PolyFlagsWalk | PolyFlagsDoor
+	case PolyAreaJump:
+		return PolyFlagsJump
+	}
+	return 0
+}
+
+// SetPolyMeshFlags sets the areas and flags of the polygons of mesh, before it
+// is passed to detour.CreateNavMeshData.
+//
+// The walkable polygons (recast.WalkableArea) become ground polygons, then the
+// flags of each polygon are set from its area, as with AreaFlags. The flags of
+// the polygons which area isn't a sample area are left untouched.
+func SetPolyMeshFlags(mesh *recast.PolyMesh) {
+	for i := int32(0); i < mesh.NPolys; i++ {
+		if mesh.Areas[i] == recast.WalkableArea {
+			mesh.Areas[i] = PolyAreaGround
+		}
+		if flags := AreaFlags(mesh.Areas[i]); flags != 0 {
+			mesh.Flags[i] = flags
+		}
+	}
+}
+
+// NewQueryFilter returns a query filter using the sample areas and flags.
+//
+// It includes all polygons but the disabled ones, and sets the traversal cost
+// of the sample areas: water is 10 times as expensive as ground, grass twice
+// and jumps 1.5 times.
+func NewQueryFilter() *detour.StandardQueryFilter {
+	filter := detour.NewStandardQueryFilter()
+	filter.SetIncludeFlags(PolyFlagsAll ^ PolyFlagsDisabled)
+	filter.SetAreaCost(PolyAreaGround, 1.0)
+	filter.SetAreaCost(PolyAreaWater, 10.0)
+	filter.SetAreaCost(PolyAreaRoad, 1.0)
+	filter.SetAreaCost(PolyAreaDoor, 1.0)
+	filter.SetAreaCost(PolyAreaGrass, 2.0)
+	filter.SetAreaCost(PolyAreaJump, 1.5)
+	return filter
+}
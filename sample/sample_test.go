@@ -0,0 +1,84 @@
+package sample
+
+import (
+	"testing"
+
+	"github.com/arl/go-detour/detour"
+	"github.com/arl/go-detour/recast"
+)
+
+func TestAreaByName(t *testing.T) {
+	for area := uint8(PolyAreaGround); area <= PolyAreaJump; area++ {
+		name := AreaName(area)
+		if got, ok := AreaByName(name); !ok || got != area {
+			t.Errorf("AreaByName(%q) = %d, %t, want %d, true", name, got, ok, area)
+		}
+	}
+	if _, ok := AreaByName("lava"); ok {
+		t.Errorf("AreaByName(\"lava\") should fail")
+	}
+	if name := AreaName(recast.WalkableArea); name != "" {
+		t.Errorf("AreaName(WalkableArea) = %q, want \"\"", name)
+	}
+}
+
+func TestSetPolyMeshFlags(t *testing.T) {
+	mesh := recast.PolyMesh{
+		NPolys: 5,
+		Areas:  []uint8{recast.WalkableArea, PolyAreaWater, PolyAreaDoor, PolyAreaJump, 42},
+		Flags:  []uint16{0, 0, 0, 0, 0x100},
+	}
+	SetPolyMeshFlags(&mesh)
+
+	wantAreas := []uint8{PolyAreaGround, PolyAreaWater, PolyAreaDoor, PolyAreaJump, 42}
+	wantFlags := []uint16{PolyFlagsWalk, PolyFlagsSwim, PolyFlagsWalk | PolyFlagsDoor, PolyFlagsJump, 0x100}
+	for i := range wantAreas {
+		if mesh.Areas[i] != wantAreas[i] || mesh.Flags[i] != wantFlags[i] {
+			t.Errorf("poly %d: got area %d flags 0x%x, want area %d flags 0x%x",
+				i, mesh.Areas[i], mesh.Flags[i], wantAreas[i], wantFlags[i])
+		}
+	}
+}
+
+func TestNewQueryFilter(t *testing.T) {
+	filter := NewQueryFilter()
+	if filter.IncludeFlags()&PolyFlagsDisabled != 0 {
+		t.Errorf("disabled polygons should not be included")
+	}
+	if c := filter.AreaCost(PolyAreaWater); c != 10 {
+		t.Errorf("water cost = %v, want 10", c)
+	}
+}
+
+func TestNewNavMeshCreateParams(t *testing.T) {
+	pmesh := recast.PolyMesh{
+		Verts:  []uint16{0, 0, 0, 10, 0, 0, 10, 0, 10},
+		Polys:  []uint16{0, 1, 2, 0xffff, 0xffff, 0xffff},
+		Areas:  []uint8{PolyAreaGround},
+		Flags:  []uint16{PolyFlagsWalk},
+		NVerts: 3,
+		NPolys: 1,
+		Nvp:    3,
+		BMin:   [3]float32{-1, -2, -3},
+		BMax:   [3]float32{4, 5, 6},
+	}
+	cfg := recast.Config{Cs: 0.3, Ch: 0.2}
+	agent := AgentParams{Height: 2, Radius: 0.6, MaxClimb: 0.9}
+
+	params := NewNavMeshCreateParams(&pmesh, nil, &cfg, agent)
+	if params.PolyCount != 1 || params.VertCount != 3 || params.Nvp != 3 {
+		t.Errorf("got %d polys, %d verts, nvp %d, want 1, 3, 3", params.PolyCount, params.VertCount, params.Nvp)
+	}
+	if params.BMin != pmesh.BMin || params.BMax != pmesh.BMax {
+		t.Errorf("got bounds %v %v, want %v %v", params.BMin, params.BMax, pmesh.BMin, pmesh.BMax)
+	}
+	if params.Cs != cfg.Cs || params.Ch != cfg.Ch {
+		t.Errorf("got cs %v ch %v, want %v %v", params.Cs, params.Ch, cfg.Cs, cfg.Ch)
+	}
+	if params.WalkableHeight != 2 || params.WalkableRadius != 0.6 || params.WalkableClimb != 0.9 || !params.BuildBvTree {
+		t.Errorf("wrong agent parameters %+v", params)
+	}
+	if _, err := detour.CreateNavMeshData(params); err != nil {
+		t.Errorf("CreateNavMeshData failed: %v", err)
+	}
+}
@@ -18,16 +18,13 @@ type SoloMesh struct {
 	geom     recast.InputGeom
 	meshName string
 	cfg      recast.Config
-	// TODO: remove partitionType, it is part of BuildSettings already
-	partitionType sample.PartitionType
-	settings      recast.BuildSettings
+	settings recast.BuildSettings
 }
 
 // New creates a new solo mesh with default build settings.
 func New(ctx *recast.BuildContext) *SoloMesh {
 	sm := &SoloMesh{settings: DefaultSettings()}
 	sm.ctx = ctx
-	sm.partitionType = sample.PartitionMonotone
 	return sm
 }
 
@@ -47,9 +44,24 @@ func (sm *SoloMesh) InputGeom() *recast.InputGeom {
 	return &sm.geom
 }
 
-// Build builds the navigation mesh for the input geometry provided
-// TODO: should return an error instead of bool
-func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
+// rasterKey identifies the settings the rasterization of the input geometry
+// depends on.
+type rasterKey struct {
+	cs, ch        float32
+	slope         float32
+	walkableClimb int32
+}
+
+// buildCompactHeightfield initializes the build config and builds the compact
+// heightfield of the input geometry, with the walkable area eroded and the
+// areas marked.
+//
+// If rasters is not nil, it caches the rasterized heightfields, so that the
+// input geometry is rasterized once for all the builds sharing the same
+// rasterization settings.
+//
+// It starts the total build timer.
+func (sm *SoloMesh) buildCompactHeightfield(rasters map[rasterKey]*recast.Heightfield) (*recast.CompactHeightfield, bool) {
 	if sm.geom.Mesh() == nil {
 		// TODO: error "no vertices and triangles"
 		return nil, false
@@ -74,6 +86,10 @@ func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
 	agentHeight := sm.settings.AgentHeight
 	agentMaxClimb := sm.settings.AgentMaxClimb
 	agentRadius := sm.settings.AgentRadius
+	erosionRadius := sm.settings.ErosionRadius
+	if erosionRadius == 0 {
+		erosionRadius = agentRadius
+	}
 
 	// Region
 	regionMinSize := sm.settings.RegionMinSize
@@ -93,7 +109,7 @@ func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
 	sm.cfg.WalkableSlopeAngle = sm.settings.AgentMaxSlope
 	sm.cfg.WalkableHeight = int32(math32.Ceil(agentHeight / sm.cfg.Ch))
 	sm.cfg.WalkableClimb = int32(math32.Floor(agentMaxClimb / sm.cfg.Ch))
-	sm.cfg.WalkableRadius = int32(math32.Ceil(agentRadius / sm.cfg.Cs))
+	sm.cfg.WalkableRadius = int32(math32.Ceil(erosionRadius / sm.cfg.Cs))
 	sm.cfg.MaxEdgeLen = int32(float32(edgeMaxLen) / cellSize)
 	sm.cfg.MaxSimplificationError = edgeMaxError
 	sm.cfg.MinRegionArea = int32(regionMinSize * regionMinSize)       // Note: area = size*size
@@ -113,6 +129,10 @@ func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
 	copy(sm.cfg.BMin[:], bmin[:3])
 	copy(sm.cfg.BMax[:], bmax[:3])
 	sm.cfg.Width, sm.cfg.Height = recast.CalcGridSize(sm.cfg.BMin[:], sm.cfg.BMax[:], sm.cfg.Cs)
+	if err := sm.cfg.Validate(sm.ctx); err != nil {
+		sm.ctx.Errorf("SoloMesh.Build: %v", err)
+		return nil, false
+	}
 
 	// Reset build times gathering.
 	sm.ctx.ResetTimers()
@@ -128,22 +148,30 @@ func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
 	// Step 2. Rasterize input polygon soup.
 	//
 
-	// Allocate voxel heightfield where we rasterize our input data to.
+	key := rasterKey{sm.cfg.Cs, sm.cfg.Ch, sm.cfg.WalkableSlopeAngle, sm.cfg.WalkableClimb}
 	var solid *recast.Heightfield
-	solid = recast.NewHeightfield(sm.cfg.Width, sm.cfg.Height, sm.cfg.BMin[:], sm.cfg.BMax[:], sm.cfg.Cs, sm.cfg.Ch)
-
-	// Allocate array that can hold triangle flags.
-	// If you have multiple meshes you need to process, allocate
-	// and array which can hold the max number of triangles you need to process.
-	triAreas := make([]uint8, ntris)
-
-	// Find triangles which are walkable based on their slope and rasterize them.
-	// If your input data is multiple meshes, you can transform them here, calculate
-	// the are type for each of the meshes and rasterize them.
-	recast.MarkWalkableTriangles(sm.ctx, sm.cfg.WalkableSlopeAngle, verts, nverts, tris, ntris, triAreas)
-	if !recast.RasterizeTriangles(sm.ctx, verts, nverts, tris, triAreas, ntris, solid, sm.cfg.WalkableClimb) {
-		sm.ctx.Errorf("SoloMesh.Build: Could not rasterize triangles.")
-		return nil, false
+	if raster, ok := rasters[key]; ok {
+		// The filters modify the heightfield, keep the cached one intact.
+		solid = raster.Clone()
+	} else {
+		// Allocate voxel heightfield where we rasterize our input data to.
+		solid = recast.NewHeightfield(sm.cfg.Width, sm.cfg.Height, sm.cfg.BMin[:], sm.cfg.BMax[:], sm.cfg.Cs, sm.cfg.Ch)
+
+		// Allocate array that can hold triangle flags.
+		// If you have multiple meshes you need to process, allocate
+		// and array which can hold the max number of triangles you need to process.
+		triAreas := make([]uint8, ntris)
+
+		// Find triangles which are walkable based on their slope and rasterize them.
+		// The triangles of the input mesh parts get the area id of their part.
+		sm.geom.MarkWalkableTriangles(sm.ctx, sm.cfg.WalkableSlopeAngle, nil, ntris, triAreas)
+		if !recast.RasterizeTriangles(sm.ctx, verts, nverts, tris, triAreas, ntris, solid, sm.cfg.WalkableClimb) {
+			sm.ctx.Errorf("SoloMesh.Build: Could not rasterize triangles.")
+			return nil, false
+		}
+		if rasters != nil {
+			rasters[key] = solid.Clone()
+		}
 	}
 
 	//
@@ -166,7 +194,11 @@ func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
 		return nil, false
 	}
 
-	// Erode the walkable area by agent radius.
+	// (Optional) Apply modifier volumes, before erosion so that agents keep
+	// their distance from non-walkable volumes.
+	recast.MarkModifierVolumes(sm.ctx, sm.settings.ModifierVolumes, chf)
+
+	// Erode the walkable area by erosion radius (agent radius by default).
 	if !recast.ErodeWalkableArea(sm.ctx, sm.cfg.WalkableRadius, chf) {
 		sm.ctx.Errorf("SoloMesh.Build: Could not erode.")
 		return nil, false
@@ -179,6 +211,62 @@ func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
 	for i := int32(0); i < sm.geom.ConvexVolumesCount(); i++ {
 		recast.MarkConvexPolyArea(sm.ctx, vols[i].Verts[:], vols[i].NVerts, vols[i].HMin, vols[i].HMax, uint8(vols[i].Area), chf)
 	}
+	for _, vol := range sm.settings.ConvexVolumes {
+		recast.MarkConvexPolyArea(sm.ctx, vol.Verts, int32(len(vol.Verts)/3), vol.HMin, vol.HMax, vol.Area, chf)
+	}
+
+	// (Optional) Remove noise from area ids.
+	if sm.settings.MedianFilter {
+		if !recast.MedianFilterWalkableArea(sm.ctx, chf) {
+			sm.ctx.Errorf("SoloMesh.Build: Could not apply median filter.")
+			return nil, false
+		}
+	}
+
+	return chf, true
+}
+
+// Build builds the navigation mesh for the input geometry provided
+// TODO: should return an error instead of bool
+func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
+	return sm.build(nil)
+}
+
+// BuildProfiles builds one navigation mesh per agent profile, for the input
+// geometry provided.
+//
+// Each profile is a complete set of build settings. The input geometry is
+// rasterized once for all the profiles sharing the same cell size, cell
+// height, maximum slope and maximum climb, only the following steps are
+// performed for each profile. The navigation meshes are returned in the order
+// of the profiles.
+//
+// The build settings of the solo mesh are left unchanged.
+func (sm *SoloMesh) BuildProfiles(profiles []recast.BuildSettings) ([]*detour.NavMesh, bool) {
+	settings := sm.settings
+	defer func() { sm.settings = settings }()
+
+	rasters := make(map[rasterKey]*recast.Heightfield)
+	meshes := make([]*detour.NavMesh, len(profiles))
+	for i, p := range profiles {
+		sm.settings = p
+		nm, ok := sm.build(rasters)
+		if !ok {
+			sm.ctx.Errorf("SoloMesh.BuildProfiles: Could not build profile %d.", i)
+			return nil, false
+		}
+		meshes[i] = nm
+	}
+	return meshes, true
+}
+
+// build builds the navigation mesh, rasters is passed to
+// buildCompactHeightfield.
+func (sm *SoloMesh) build(rasters map[rasterKey]*recast.Heightfield) (*detour.NavMesh, bool) {
+	chf, ok := sm.buildCompactHeightfield(rasters)
+	if !ok {
+		return nil, false
+	}
 
 	// Partition the heightfield so that we can use simple algorithm later to
 	// triangulate the walkable areas. There are 3 partitioning methods, each
@@ -216,21 +304,20 @@ func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
 	//   * good choice to use for tiled navmesh with medium and small sized
 	//     tiles
 
-	if sm.partitionType == sample.PartitionWatershed {
+	partitionType := sample.PartitionType(sm.settings.PartitionType)
+	if partitionType == sample.PartitionWatershed {
 		// Prepare for region partitioning, by calculating distance field along the walkable surface.
-		//if (!rcBuildDistanceField(m_ctx, *m_chf))
-		//{
-		//m_ctx.log(RC_LOG_ERROR, "SoloMesh.Build: Could not build distance field.");
-		//return navData, false
-		//}
+		if !recast.BuildDistanceField(sm.ctx, chf) {
+			sm.ctx.Errorf("SoloMesh.Build: Could not build distance field.")
+			return nil, false
+		}
 
-		//// Partition the walkable surface into simple regions without holes.
-		//if (!rcBuildRegions(m_ctx, *m_chf, 0, m_cfg.minRegionArea, m_cfg.mergeRegionArea))
-		//{
-		//m_ctx.log(RC_LOG_ERROR, "SoloMesh.Build: Could not build watershed regions.");
-		//return navData, false
-		//}
-	} else if sm.partitionType == sample.PartitionMonotone {
+		// Partition the walkable surface into simple regions without holes.
+		if !recast.BuildRegions(sm.ctx, chf, 0, sm.cfg.MinRegionArea, sm.cfg.MergeRegionArea) {
+			sm.ctx.Errorf("SoloMesh.Build: Could not build watershed regions.")
+			return nil, false
+		}
+	} else if partitionType == sample.PartitionMonotone {
 		// Partition the walkable surface into simple regions without holes.
 		// Monotone partitioning does not need distancefield.
 		if !recast.BuildRegionsMonotone(sm.ctx, chf, 0, sm.cfg.MinRegionArea, sm.cfg.MergeRegionArea) {
@@ -252,7 +339,7 @@ func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
 
 	// Create contours.
 	cset := &recast.ContourSet{}
-	if !recast.BuildContours(sm.ctx, chf, sm.cfg.MaxSimplificationError, sm.cfg.MaxEdgeLen, cset, recast.ContourTessWallEdges) {
+	if !recast.BuildContours(sm.ctx, chf, sm.cfg.MaxSimplificationError, sm.cfg.MaxEdgeLen, cset, sm.settings.ContourFlags) {
 		sm.ctx.Errorf("SoloMesh.Build: Could not create contours.")
 		return nil, false
 	}
@@ -303,52 +390,12 @@ func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
 	)
 
 	// Update poly flags from areas.
-	for i := int32(0); i < pmesh.NPolys; i++ {
-		if pmesh.Areas[i] == recast.WalkableArea {
-			pmesh.Areas[i] = sample.PolyAreaGround
-		}
+	sample.SetPolyMeshFlags(pmesh)
 
-		if pmesh.Areas[i] == sample.PolyAreaGround ||
-			pmesh.Areas[i] == sample.PolyAreaGrass ||
-			pmesh.Areas[i] == sample.PolyAreaRoad {
-			pmesh.Flags[i] = sample.PolyFlagsWalk
-		} else if pmesh.Areas[i] == sample.PolyAreaWater {
-			pmesh.Flags[i] = sample.PolyFlagsSwim
-		} else if pmesh.Areas[i] == sample.PolyAreaDoor {
-			pmesh.Flags[i] = sample.PolyFlagsWalk | sample.PolyFlagsDoor
-		}
-	}
+	params := sample.NewNavMeshCreateParams(pmesh, dmesh, &sm.cfg, sample.AgentParamsFromSettings(&sm.settings))
+	sample.SetOffMeshConnections(params, &sm.geom)
 
-	var params detour.NavMeshCreateParams
-	params.Verts = pmesh.Verts
-	params.VertCount = pmesh.NVerts
-	params.Polys = pmesh.Polys
-	params.PolyAreas = pmesh.Areas
-	params.PolyFlags = pmesh.Flags
-	params.PolyCount = pmesh.NPolys
-	params.Nvp = pmesh.Nvp
-	params.DetailMeshes = dmesh.Meshes
-	params.DetailVerts = dmesh.Verts
-	params.DetailVertsCount = dmesh.NVerts
-	params.DetailTris = dmesh.Tris
-	params.DetailTriCount = dmesh.NTris
-	params.OffMeshConVerts = sm.geom.OffMeshConnectionVerts()
-	params.OffMeshConRad = sm.geom.OffMeshConnectionRads()
-	params.OffMeshConDir = sm.geom.OffMeshConnectionDirs()
-	params.OffMeshConAreas = sm.geom.OffMeshConnectionAreas()
-	params.OffMeshConFlags = sm.geom.OffMeshConnectionFlags()
-	params.OffMeshConUserID = sm.geom.OffMeshConnectionId()
-	params.OffMeshConCount = sm.geom.OffMeshConnectionCount()
-	params.WalkableHeight = agentHeight
-	params.WalkableRadius = agentRadius
-	params.WalkableClimb = agentMaxClimb
-	copy(params.BMin[:], pmesh.BMin[:])
-	copy(params.BMax[:], pmesh.BMax[:])
-	params.Cs = sm.cfg.Cs
-	params.Ch = sm.cfg.Ch
-	params.BuildBvTree = true
-
-	if navData, err = detour.CreateNavMeshData(&params); err != nil {
+	if navData, err = detour.CreateNavMeshData(params); err != nil {
 		sm.ctx.Errorf("Could not build Detour navmesh: %v", err)
 		return nil, false
 	}
@@ -377,3 +424,29 @@ func (sm *SoloMesh) Build() (*detour.NavMesh, bool) {
 
 	return &navMesh, true
 }
+
+// BuildSurface builds the walkable surface of the input geometry, as a
+// triangle mesh.
+//
+// The surface is extracted from the compact heightfield, after the walkable
+// area has been eroded and the areas marked, without building the navigation
+// mesh. (See: recast.ExtractWalkableSurface)
+func (sm *SoloMesh) BuildSurface() (*recast.SurfaceMesh, bool) {
+	chf, ok := sm.buildCompactHeightfield(nil)
+	if !ok {
+		return nil, false
+	}
+
+	surf, ok := recast.ExtractWalkableSurface(sm.ctx, chf)
+	if !ok {
+		sm.ctx.Errorf("SoloMesh.BuildSurface: Could not extract walkable surface.")
+		return nil, false
+	}
+
+	sm.ctx.StopTimer(recast.TimerTotal)
+	// Log performance stats.
+	recast.LogBuildTimes(sm.ctx, sm.ctx.AccumulatedTime(recast.TimerTotal))
+	sm.ctx.Progressf(">> Surface: %d vertices  %d triangles", surf.VertCount(), surf.TriCount())
+
+	return surf, true
+}
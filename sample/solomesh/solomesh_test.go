@@ -9,6 +9,7 @@ import (
 
 	"github.com/arl/go-detour/detour"
 	"github.com/arl/go-detour/recast"
+	"github.com/arl/go-detour/sample"
 	"github.com/arl/gogeo/f32/d3"
 	"github.com/arl/math32"
 )
@@ -346,3 +347,163 @@ func TestRaycastSoloMesh(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildSurfaceSoloMesh(t *testing.T) {
+	objName := "nav_test"
+	path := OBJDir + objName + ".obj"
+
+	ctx := recast.NewBuildContext(false)
+	soloMesh := New(ctx)
+	r, err := os.Open(path)
+	check(t, err)
+	defer r.Close()
+	if err = soloMesh.LoadGeometry(r); err != nil {
+		t.Fatalf("couldn't load mesh '%v': %s", path, err)
+	}
+	surf, ok := soloMesh.BuildSurface()
+	if !ok {
+		t.Fatalf("couldn't build walkable surface for %v", objName)
+	}
+	if surf.TriCount() == 0 {
+		t.Fatalf("walkable surface of %v is empty", objName)
+	}
+
+	// the surface and the navmesh cover the same area, except the regions
+	// too small to be kept in the navmesh.
+	navMesh, ok := soloMesh.Build()
+	if !ok {
+		t.Fatalf("couldn't build navmesh for %v", objName)
+	}
+	_, query := detour.NewNavMeshQuery(navMesh, 2048)
+	filter := detour.NewStandardQueryFilter()
+	ext := d3.NewVec3XYZ(0.5, 1, 0.5)
+	missing := 0
+	for i := 0; i < len(surf.Tris); i += 3 {
+		centroid := d3.NewVec3()
+		for _, v := range surf.Tris[i : i+3] {
+			d3.Vec3Add(centroid, centroid, surf.Verts[v*3:v*3+3])
+		}
+		d3.Vec3Scale(centroid, centroid, 1.0/3)
+		if _, ref, _ := query.FindNearestPoly(centroid, ext, filter); ref == 0 {
+			missing++
+		}
+	}
+	if missing*50 > surf.TriCount() {
+		t.Errorf("%d/%d surface triangles have no navmesh polygon nearby", missing, surf.TriCount())
+	}
+
+	var buf bytes.Buffer
+	check(t, surf.WriteOBJ(&buf))
+	obj := recast.NewMeshLoaderOBJ()
+	check(t, obj.Load(&buf))
+	if obj.VertCount() != int32(surf.VertCount()) || obj.TriCount() != int32(surf.TriCount()) {
+		t.Errorf("OBJ mesh has %d verts and %d tris, want %d and %d",
+			obj.VertCount(), obj.TriCount(), surf.VertCount(), surf.TriCount())
+	}
+}
+
+func TestBuildProfilesSoloMesh(t *testing.T) {
+	objName := "nav_test"
+	path := OBJDir + objName + ".obj"
+
+	ctx := recast.NewBuildContext(false)
+	soloMesh := New(ctx)
+	r, err := os.Open(path)
+	check(t, err)
+	defer r.Close()
+	if err = soloMesh.LoadGeometry(r); err != nil {
+		t.Fatalf("couldn't load mesh '%v': %s", path, err)
+	}
+
+	// the 2 first profiles share the same rasterization
+	small := DefaultSettings()
+	large := DefaultSettings()
+	large.AgentRadius, large.AgentHeight = 1.2, 3
+	coarse := DefaultSettings()
+	coarse.CellSize = 0.5
+	profiles := []recast.BuildSettings{small, large, coarse}
+
+	meshes, ok := soloMesh.BuildProfiles(profiles)
+	if !ok {
+		t.Fatalf("couldn't build profiles for %v", objName)
+	}
+	if len(meshes) != len(profiles) {
+		t.Fatalf("got %d navmeshes, want %d", len(meshes), len(profiles))
+	}
+
+	// each navmesh is the one built with the profile settings alone
+	for i, p := range profiles {
+		soloMesh.SetSettings(p)
+		want, ok := soloMesh.Build()
+		if !ok {
+			t.Fatalf("couldn't build navmesh for profile %d", i)
+		}
+		if !bytes.Equal(encodeNavMesh(t, want), encodeNavMesh(t, meshes[i])) {
+			t.Errorf("profile %d navmesh differs from the navmesh built alone", i)
+		}
+	}
+	if bytes.Equal(encodeNavMesh(t, meshes[0]), encodeNavMesh(t, meshes[1])) {
+		t.Errorf("profiles with different agent sizes should give different navmeshes")
+	}
+}
+
+func encodeNavMesh(t *testing.T, nm *detour.NavMesh) []byte {
+	var buf bytes.Buffer
+	check(t, nm.Encode(&buf))
+	return buf.Bytes()
+}
+
+func TestBuildWatershedSoloMesh(t *testing.T) {
+	for _, objName := range []string{"cube", "develer", "dungeon", "nav_test", "stair2", "hill", "twisted"} {
+		t.Run(objName, func(t *testing.T) {
+			path := OBJDir + objName + ".obj"
+			ctx := recast.NewBuildContext(false)
+			soloMesh := New(ctx)
+			r, err := os.Open(path)
+			check(t, err)
+			defer r.Close()
+			if err = soloMesh.LoadGeometry(r); err != nil {
+				t.Fatalf("couldn't load mesh '%v': %s", path, err)
+			}
+
+			areas := make(map[sample.PartitionType]float32)
+			for _, pt := range []sample.PartitionType{sample.PartitionMonotone, sample.PartitionWatershed} {
+				settings := DefaultSettings()
+				settings.PartitionType = int32(pt)
+				soloMesh.SetSettings(settings)
+				nm, ok := soloMesh.Build()
+				if !ok {
+					t.Fatalf("couldn't build navmesh with partition type %v", pt)
+				}
+				areas[pt] = navMeshArea(nm)
+			}
+
+			// both partitions should cover the same walkable surface, minus
+			// the small regions they filter out differently.
+			mono, ws := areas[sample.PartitionMonotone], areas[sample.PartitionWatershed]
+			if ws == 0 || math32.Abs(ws-mono) > 0.05*mono {
+				t.Errorf("watershed navmesh area = %f, monotone area = %f", ws, mono)
+			}
+		})
+	}
+}
+
+// navMeshArea returns the area of the ground polygons of a solo navmesh, on
+// the xz-plane.
+func navMeshArea(nm *detour.NavMesh) float32 {
+	tile := nm.TileAt(0, 0, 0)
+	var area float32
+	for i := range tile.Polys {
+		p := &tile.Polys[i]
+		if p.VertCount < 3 {
+			// off-mesh connection
+			continue
+		}
+		for j := uint8(0); j < p.VertCount; j++ {
+			a := tile.Verts[p.Verts[j]*3:]
+			b := tile.Verts[p.Verts[(j+1)%p.VertCount]*3:]
+			area += a[0]*b[2] - b[0]*a[2]
+		}
+	}
+	return math32.Abs(area) / 2
+}
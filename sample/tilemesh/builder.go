@@ -12,6 +12,10 @@ import (
 	"github.com/arl/math32"
 )
 
+// ProgressStage is the stage name of the progress of the tiles build, as
+// reported to recast.BuildContext.ReportProgress.
+const ProgressStage = "Build Tiles"
+
 // TileMesh allows building multi-tile navigation meshes.
 //
 // TODO: rename TileMeshBuilder or something like that to show that this is
@@ -22,7 +26,6 @@ type TileMesh struct {
 	navMesh           detour.NavMesh
 	meshName          string
 	cfg               recast.Config
-	partitionType     sample.PartitionType
 	settings          recast.BuildSettings
 	lastBuiltTileBMin d3.Vec3
 	lastBuiltTileBMax d3.Vec3
@@ -50,7 +53,6 @@ func New(ctx *recast.BuildContext) *TileMesh {
 		lastBuiltTileBMax: d3.NewVec3(),
 	}
 	sm.ctx = ctx
-	sm.partitionType = sample.PartitionMonotone
 	return sm
 }
 
@@ -111,7 +113,9 @@ func (tm *TileMesh) Build() (*detour.NavMesh, bool) {
 		return nil, false
 	}
 
-	tm.buildAllTiles()
+	if _, ok := tm.buildAllTiles(); !ok {
+		return nil, false
+	}
 
 	return &tm.navMesh, true
 }
@@ -129,6 +133,7 @@ func (tm *TileMesh) buildAllTiles() (*detour.NavMesh, bool) {
 	tm.ctx.StartTimer(recast.TimerTemp)
 	for y := int32(0); y < th; y++ {
 		for x := int32(0); x < tw; x++ {
+			tm.ctx.ReportProgress(ProgressStage, y*tw+x, tw*th)
 
 			tm.lastBuiltTileBMin[0] = bmin[0] + float32(x)*tcs
 			tm.lastBuiltTileBMin[1] = bmin[1]
@@ -139,6 +144,12 @@ func (tm *TileMesh) buildAllTiles() (*detour.NavMesh, bool) {
 			tm.lastBuiltTileBMax[2] = bmin[2] + float32(y+1)*tcs
 
 			data := tm.buildTileMesh(x, y, tm.lastBuiltTileBMin[:], tm.lastBuiltTileBMax[:])
+			if tm.ctx.Cancelled() {
+				// A cancelled tile build returns no data, as an empty tile.
+				tm.ctx.StopTimer(recast.TimerTemp)
+				tm.ctx.Errorf("TileMesh.Build: Build cancelled.")
+				return nil, false
+			}
 			if data != nil {
 				// Remove any previous data (navmesh owns and deletes the data).
 				tm.navMesh.RemoveTile(tm.navMesh.TileRefAt(x, y, 0))
@@ -148,6 +159,8 @@ func (tm *TileMesh) buildAllTiles() (*detour.NavMesh, bool) {
 		}
 	}
 
+	tm.ctx.ReportProgress(ProgressStage, tw*th, tw*th)
+
 	// Start the build process.
 	tm.ctx.StopTimer(recast.TimerTemp)
 
@@ -184,6 +197,10 @@ func (tm *TileMesh) buildTileMesh(tx, ty int32, bmin, bmax []float32) []byte {
 	agentHeight := tm.settings.AgentHeight
 	agentMaxClimb := tm.settings.AgentMaxClimb
 	agentRadius := tm.settings.AgentRadius
+	erosionRadius := tm.settings.ErosionRadius
+	if erosionRadius == 0 {
+		erosionRadius = agentRadius
+	}
 
 	// Region
 	regionMinSize := tm.settings.RegionMinSize
@@ -203,7 +220,7 @@ func (tm *TileMesh) buildTileMesh(tx, ty int32, bmin, bmax []float32) []byte {
 	tm.cfg.WalkableSlopeAngle = tm.settings.AgentMaxSlope
 	tm.cfg.WalkableHeight = int32(math32.Ceil(agentHeight / tm.cfg.Ch))
 	tm.cfg.WalkableClimb = int32(math32.Floor(agentMaxClimb / tm.cfg.Ch))
-	tm.cfg.WalkableRadius = int32(math32.Ceil(agentRadius / tm.cfg.Cs))
+	tm.cfg.WalkableRadius = int32(math32.Ceil(erosionRadius / tm.cfg.Cs))
 	tm.cfg.MaxEdgeLen = int32(float32(edgeMaxLen) / cellSize)
 	tm.cfg.MaxSimplificationError = edgeMaxError
 	tm.cfg.MinRegionArea = int32(regionMinSize * regionMinSize)       // Note: area = size*size
@@ -271,8 +288,13 @@ func (tm *TileMesh) buildTileMesh(tx, ty int32, bmin, bmax []float32) []byte {
 	// Step 2. Rasterize input polygon soup.
 	//
 
-	// Allocate voxel heightfield where we rasterize our input data to.
-	tm.solid = recast.NewHeightfield(tm.cfg.Width, tm.cfg.Height, tm.cfg.BMin[:], tm.cfg.BMax[:], tm.cfg.Cs, tm.cfg.Ch)
+	// Allocate voxel heightfield where we rasterize our input data to, or
+	// reuse the one of the previous tile.
+	if tm.solid == nil {
+		tm.solid = recast.NewHeightfield(tm.cfg.Width, tm.cfg.Height, tm.cfg.BMin[:], tm.cfg.BMax[:], tm.cfg.Cs, tm.cfg.Ch)
+	} else {
+		tm.solid.Reset(tm.cfg.Width, tm.cfg.Height, tm.cfg.BMin[:], tm.cfg.BMax[:], tm.cfg.Cs, tm.cfg.Ch)
+	}
 
 	// Allocate array that can hold triangle flags.
 	// If you have multiple meshes you need to process, allocate
@@ -302,8 +324,8 @@ func (tm *TileMesh) buildTileMesh(tx, ty int32, bmin, bmax []float32) []byte {
 		for ai := 0; ai < len(tm.triAreas); ai++ {
 			tm.triAreas[ai] = 0
 		}
-		recast.MarkWalkableTriangles(tm.ctx, tm.cfg.WalkableSlopeAngle,
-			verts, nverts, ctris, nctris, tm.triAreas)
+		tm.geom.MarkWalkableTriangles(tm.ctx, tm.cfg.WalkableSlopeAngle,
+			chunkyMesh.TriIDs[node.I:], nctris, tm.triAreas)
 
 		if !recast.RasterizeTriangles(tm.ctx, verts, nverts, ctris, tm.triAreas, nctris, tm.solid, tm.cfg.WalkableClimb) {
 			return nil
@@ -330,7 +352,11 @@ func (tm *TileMesh) buildTileMesh(tx, ty int32, bmin, bmax []float32) []byte {
 		return nil
 	}
 
-	// Erode the walkable area by agent radius.
+	// (Optional) Apply modifier volumes, before erosion so that agents keep
+	// their distance from non-walkable volumes.
+	recast.MarkModifierVolumes(tm.ctx, tm.settings.ModifierVolumes, tm.chf)
+
+	// Erode the walkable area by erosion radius (agent radius by default).
 	if !recast.ErodeWalkableArea(tm.ctx, tm.cfg.WalkableRadius, tm.chf) {
 		tm.ctx.Errorf("buildNavigation: Could not erode.")
 		return nil
@@ -343,6 +369,17 @@ func (tm *TileMesh) buildTileMesh(tx, ty int32, bmin, bmax []float32) []byte {
 	for i := int32(0); i < tm.geom.ConvexVolumesCount(); i++ {
 		recast.MarkConvexPolyArea(tm.ctx, vols[i].Verts[:], vols[i].NVerts, vols[i].HMin, vols[i].HMax, uint8(vols[i].Area), tm.chf)
 	}
+	for _, vol := range tm.settings.ConvexVolumes {
+		recast.MarkConvexPolyArea(tm.ctx, vol.Verts, int32(len(vol.Verts)/3), vol.HMin, vol.HMax, vol.Area, tm.chf)
+	}
+
+	// (Optional) Remove noise from area ids.
+	if tm.settings.MedianFilter {
+		if !recast.MedianFilterWalkableArea(tm.ctx, tm.chf) {
+			tm.ctx.Errorf("buildNavigation: Could not apply median filter.")
+			return nil
+		}
+	}
 
 	// Partition the heightfield so that we can use simple algorithm later to
 	// triangulate the walkable areas. There are 3 partitioning methods, each
@@ -380,21 +417,20 @@ func (tm *TileMesh) buildTileMesh(tx, ty int32, bmin, bmax []float32) []byte {
 	//   * good choice to use for tiled navmesh with medium and small sized
 	//     tiles
 
-	if tm.partitionType == sample.PartitionWatershed {
+	partitionType := sample.PartitionType(tm.settings.PartitionType)
+	if partitionType == sample.PartitionWatershed {
 		// Prepare for region partitioning, by calculating distance field along the walkable surface.
-		//if (!rcBuildDistanceField(m_ctx, *m_chf))
-		//{
-		//m_ctx.log(RC_LOG_ERROR, "buildNavigation: Could not build distance field.");
-		//return navData, false
-		//}
+		if !recast.BuildDistanceField(tm.ctx, tm.chf) {
+			tm.ctx.Errorf("buildNavigation: Could not build distance field.")
+			return nil
+		}
 
-		//// Partition the walkable surface into simple regions without holes.
-		//if (!rcBuildRegions(m_ctx, *m_chf, 0, m_cfg.minRegionArea, m_cfg.mergeRegionArea))
-		//{
-		//m_ctx.log(RC_LOG_ERROR, "buildNavigation: Could not build watershed regions.");
-		//return navData, false
-		//}
-	} else if tm.partitionType == sample.PartitionMonotone {
+		// Partition the walkable surface into simple regions without holes.
+		if !recast.BuildRegions(tm.ctx, tm.chf, tm.cfg.BorderSize, tm.cfg.MinRegionArea, tm.cfg.MergeRegionArea) {
+			tm.ctx.Errorf("buildNavigation: Could not build watershed regions.")
+			return nil
+		}
+	} else if partitionType == sample.PartitionMonotone {
 		// Partition the walkable surface into simple regions without holes.
 		// Monotone partitioning does not need distancefield.
 		if !recast.BuildRegionsMonotone(tm.ctx, tm.chf, tm.cfg.BorderSize, tm.cfg.MinRegionArea, tm.cfg.MergeRegionArea) {
@@ -416,7 +452,7 @@ func (tm *TileMesh) buildTileMesh(tx, ty int32, bmin, bmax []float32) []byte {
 
 	// Create contours.
 	tm.cset = &recast.ContourSet{}
-	if !recast.BuildContours(tm.ctx, tm.chf, tm.cfg.MaxSimplificationError, tm.cfg.MaxEdgeLen, tm.cset, recast.ContourTessWallEdges) {
+	if !recast.BuildContours(tm.ctx, tm.chf, tm.cfg.MaxSimplificationError, tm.cfg.MaxEdgeLen, tm.cset, tm.settings.ContourFlags) {
 		tm.ctx.Errorf("buildNavigation: Could not create contours.")
 		return nil
 	}
@@ -465,55 +501,15 @@ func (tm *TileMesh) buildTileMesh(tx, ty int32, bmin, bmax []float32) []byte {
 		}
 
 		// Update poly flags from areas.
-		for i := int32(0); i < tm.pmesh.NPolys; i++ {
-			if tm.pmesh.Areas[i] == recast.WalkableArea {
-				tm.pmesh.Areas[i] = sample.PolyAreaGround
-			}
+		sample.SetPolyMeshFlags(tm.pmesh)
 
-			if tm.pmesh.Areas[i] == sample.PolyAreaGround ||
-				tm.pmesh.Areas[i] == sample.PolyAreaGrass ||
-				tm.pmesh.Areas[i] == sample.PolyAreaRoad {
-				tm.pmesh.Flags[i] = sample.PolyFlagsWalk
-			} else if tm.pmesh.Areas[i] == sample.PolyAreaWater {
-				tm.pmesh.Flags[i] = sample.PolyFlagsSwim
-			} else if tm.pmesh.Areas[i] == sample.PolyAreaDoor {
-				tm.pmesh.Flags[i] = sample.PolyFlagsWalk | sample.PolyFlagsDoor
-			}
-		}
-
-		var params detour.NavMeshCreateParams
-		params.Verts = tm.pmesh.Verts
-		params.VertCount = tm.pmesh.NVerts
-		params.Polys = tm.pmesh.Polys
-		params.PolyAreas = tm.pmesh.Areas
-		params.PolyFlags = tm.pmesh.Flags
-		params.PolyCount = tm.pmesh.NPolys
-		params.Nvp = tm.pmesh.Nvp
-		params.DetailMeshes = tm.dmesh.Meshes
-		params.DetailVerts = tm.dmesh.Verts
-		params.DetailVertsCount = tm.dmesh.NVerts
-		params.DetailTris = tm.dmesh.Tris
-		params.DetailTriCount = tm.dmesh.NTris
-		params.OffMeshConVerts = tm.geom.OffMeshConnectionVerts()
-		params.OffMeshConRad = tm.geom.OffMeshConnectionRads()
-		params.OffMeshConDir = tm.geom.OffMeshConnectionDirs()
-		params.OffMeshConAreas = tm.geom.OffMeshConnectionAreas()
-		params.OffMeshConFlags = tm.geom.OffMeshConnectionFlags()
-		params.OffMeshConUserID = tm.geom.OffMeshConnectionId()
-		params.OffMeshConCount = tm.geom.OffMeshConnectionCount()
-		params.WalkableHeight = agentHeight
-		params.WalkableRadius = agentRadius
-		params.WalkableClimb = agentMaxClimb
+		params := sample.NewNavMeshCreateParams(tm.pmesh, tm.dmesh, &tm.cfg, sample.AgentParamsFromSettings(&tm.settings))
+		sample.SetOffMeshConnections(params, &tm.geom)
 		params.TileX = tx
 		params.TileY = ty
 		params.TileLayer = 0
-		copy(params.BMin[:], tm.pmesh.BMin[:])
-		copy(params.BMax[:], tm.pmesh.BMax[:])
-		params.Cs = tm.cfg.Cs
-		params.Ch = tm.cfg.Ch
-		params.BuildBvTree = true
 
-		if navData, err = detour.CreateNavMeshData(&params); err != nil {
+		if navData, err = detour.CreateNavMeshData(params); err != nil {
 			tm.ctx.Errorf("Could not build Detour navmesh: %v", err)
 			return nil
 		}
@@ -549,6 +545,11 @@ func (tm *TileMesh) BuildTile(pos d3.Vec3) {
 	tm.ctx.ResetLog()
 
 	data := tm.buildTileMesh(tx, ty, tm.lastBuiltTileBMin, tm.lastBuiltTileBMax)
+	if tm.ctx.Cancelled() {
+		// Keep the previous tile.
+		tm.ctx.DumpLog(os.Stdout, "Build Tile (%d,%d) cancelled:", tx, ty)
+		return
+	}
 
 	// Remove any previous data (navmesh owns and deletes the data).
 	tm.navMesh.RemoveTile(tm.navMesh.TileRefAt(tx, ty, 0))
@@ -590,3 +591,80 @@ func (tm *TileMesh) RemoveTile(pos d3.Vec3) {
 
 	tm.navMesh.RemoveTile(tm.navMesh.TileRefAt(tx, ty, 0))
 }
+
+// RebuildTile rebuilds a single tile of a navigation mesh from the input
+// geometry, for example after the geometry of the tile has been modified.
+//
+//	Arguments:
+//	 ctx       The build context to use during the operation.
+//	 nm        The navigation mesh, built with the same build settings.
+//	 tx        The tile x-location.
+//	 ty        The tile y-location.
+//	 geom      The input geometry.
+//	 settings  The build settings.
+//
+//	Return values:
+//	 ref       The reference of the rebuilt tile, or 0 if the tile is now
+//	           empty.
+//	 ok        false if the tile couldn't be rebuilt, in which case the
+//	           navigation mesh is left unchanged.
+//
+// Only the geometry overlapping the tile is rasterized. The previous tile at
+// (tx, ty) is replaced by the new one, which keeps its TileRef, so the
+// references to the previous tile polygons remain valid references to the
+// polygons of the new tile having the same index. Their generation changes
+// though (See: detour.NavMesh.RefGeneration).
+func RebuildTile(ctx *recast.BuildContext, nm *detour.NavMesh, tx, ty int32, geom *recast.InputGeom, settings recast.BuildSettings) (detour.TileRef, bool) {
+	tcs := settings.TileSize * settings.CellSize
+	if nm.Params.TileWidth != tcs || nm.Params.TileHeight != tcs {
+		ctx.Errorf("RebuildTile: navmesh tile size (%v x %v) doesn't match build settings (%v)",
+			nm.Params.TileWidth, nm.Params.TileHeight, tcs)
+		return 0, false
+	}
+
+	tm := New(ctx)
+	tm.settings = settings
+	tm.geom = *geom
+
+	orig := nm.Params.Orig
+	tm.lastBuiltTileBMin[0] = orig[0] + float32(tx)*tcs
+	tm.lastBuiltTileBMin[1] = geom.NavMeshBoundsMin()[1]
+	tm.lastBuiltTileBMin[2] = orig[2] + float32(ty)*tcs
+
+	tm.lastBuiltTileBMax[0] = orig[0] + float32(tx+1)*tcs
+	tm.lastBuiltTileBMax[1] = geom.NavMeshBoundsMax()[1]
+	tm.lastBuiltTileBMax[2] = orig[2] + float32(ty+1)*tcs
+
+	data := tm.buildTileMesh(tx, ty, tm.lastBuiltTileBMin, tm.lastBuiltTileBMax)
+	if ctx.Cancelled() {
+		ctx.Errorf("RebuildTile: Build of tile (%d,%d) cancelled.", tx, ty)
+		return 0, false
+	}
+
+	// Remove the previous tile, keeping its reference to reuse it.
+	lastRef := nm.TileRefAt(tx, ty, 0)
+	var lastData []byte
+	if lastRef != 0 {
+		var status detour.Status
+		lastData, status = nm.RemoveTile(lastRef)
+		if detour.StatusFailed(status) {
+			ctx.Errorf("RebuildTile: Could not remove tile (%d,%d)", tx, ty)
+			return 0, false
+		}
+	}
+
+	// Add the new tile, or leave the location empty.
+	if data == nil {
+		return 0, true
+	}
+	status, ref := nm.AddTile(data, lastRef)
+	if detour.StatusFailed(status) {
+		ctx.Errorf("RebuildTile: Could not add tile (%d,%d): 0x%x", tx, ty, status)
+		if lastRef != 0 {
+			// Restore the previous tile.
+			nm.AddTile(lastData, lastRef)
+		}
+		return 0, false
+	}
+	return ref, true
+}
@@ -19,6 +19,7 @@ func DefaultSettings() recast.BuildSettings {
 		RegionMergeSize:      20,
 		EdgeMaxLen:           12,
 		EdgeMaxError:         float32(1.3),
+		ContourFlags:         recast.ContourTessWallEdges,
 		VertsPerPoly:         6,
 		DetailSampleDist:     float32(6),
 		DetailSampleMaxError: float32(1),
@@ -2,6 +2,7 @@ package tilemesh
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -350,3 +351,105 @@ func TestRaycastTileMesh(t *testing.T) {
 	}
 }
 */
+
+func TestRebuildTile(t *testing.T) {
+	objName := "nav_test"
+	path := OBJDir + objName + ".obj"
+
+	ctx := recast.NewBuildContext(false)
+	tileMesh := New(ctx)
+	r, err := os.Open(path)
+	check(t, err)
+	defer r.Close()
+	if err = tileMesh.LoadGeometry(r); err != nil {
+		t.Fatalf("couldn't load mesh '%v': %s", path, err)
+	}
+	var tilesProgress float32
+	ctx.SetProgressFunc(func(stage string, percent float32) {
+		if stage == ProgressStage {
+			tilesProgress = percent
+		}
+	})
+	navMesh, ok := tileMesh.Build()
+	if !ok {
+		t.Fatalf("couldn't build navmesh for %v", objName)
+	}
+	if tilesProgress != 100 {
+		t.Errorf("build progress is %v, want 100", tilesProgress)
+	}
+	ctx.SetProgressFunc(nil)
+
+	// find a tile having polygons
+	var tile *detour.MeshTile
+	for i := range navMesh.Tiles {
+		if navMesh.Tiles[i].Header != nil && navMesh.Tiles[i].Header.PolyCount > 0 {
+			tile = &navMesh.Tiles[i]
+			break
+		}
+	}
+	if tile == nil {
+		t.Fatalf("navmesh of %v has no polygons", objName)
+	}
+	tx, ty := tile.Header.X, tile.Header.Y
+	lastRef := navMesh.TileRefAt(tx, ty, 0)
+	lastPolys := tile.Header.PolyCount
+	lastData := append([]byte(nil), tile.Data...)
+
+	ref, ok := RebuildTile(ctx, navMesh, tx, ty, tileMesh.InputGeom(), tileMesh.settings)
+	if !ok {
+		t.Fatalf("couldn't rebuild tile (%d,%d)", tx, ty)
+	}
+	if ref != lastRef {
+		t.Errorf("rebuilt tile ref = 0x%x, want 0x%x", ref, lastRef)
+	}
+	tile = navMesh.TileByRef(ref)
+	if tile == nil || tile.Header.PolyCount != lastPolys {
+		t.Fatalf("rebuilt tile should have %d polygons", lastPolys)
+	}
+	if !bytes.Equal(tile.Data, lastData) {
+		t.Errorf("rebuilding the tile from the same geometry should give the same tile")
+	}
+
+	// the tile is connected to its neighbours again
+	var external int
+	for i := int32(0); i < tile.Header.PolyCount; i++ {
+		// 0xffffffff is the null link index
+		for j := tile.Polys[i].FirstLink; j != 0xffffffff; j = tile.Links[j].Next {
+			var (
+				lt *detour.MeshTile
+				lp *detour.Poly
+			)
+			navMesh.TileAndPolyByRefUnsafe(tile.Links[j].Ref, &lt, &lp)
+			if lt != tile {
+				external++
+			}
+		}
+	}
+	if external == 0 {
+		t.Errorf("rebuilt tile should be linked to its neighbours")
+	}
+
+	// mismatching settings leave the navmesh untouched
+	settings := tileMesh.settings
+	settings.TileSize *= 2
+	if _, ok = RebuildTile(ctx, navMesh, tx, ty, tileMesh.InputGeom(), settings); ok {
+		t.Errorf("RebuildTile should fail with a different tile size")
+	}
+	if navMesh.TileRefAt(tx, ty, 0) != lastRef {
+		t.Errorf("failed RebuildTile should leave the tile in place")
+	}
+
+	// so does a cancelled build
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx.SetContext(c)
+	if _, ok = RebuildTile(ctx, navMesh, tx, ty, tileMesh.InputGeom(), tileMesh.settings); ok {
+		t.Errorf("RebuildTile should fail once cancelled")
+	}
+	if navMesh.TileRefAt(tx, ty, 0) != lastRef {
+		t.Errorf("cancelled RebuildTile should leave the tile in place")
+	}
+	if _, ok = tileMesh.Build(); ok {
+		t.Errorf("Build should fail once cancelled")
+	}
+}